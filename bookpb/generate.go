@@ -0,0 +1,5 @@
+// Package bookpb holds the protobuf messages and gRPC services of the Book
+// API, generated from bookapi.proto.
+package bookpb
+
+//go:generate protoc -I .. -I ../third_party --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative bookpb/bookapi.proto
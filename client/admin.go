@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"rest-api-golang/models"
+)
+
+// The methods in this file need an admin account
+
+// ListUsers returns every account, including disabled ones, by username
+func (c *Client) ListUsers(ctx context.Context) ([]*models.User, error) {
+	var resp struct {
+		Data []*models.User `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/users", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// CreateUser creates an account; the role is "user" unless set
+func (c *Client) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+	var resp struct {
+		Data *models.User `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/users", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// DisableUser disables an account and revokes its sessions
+func (c *Client) DisableUser(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/api/users/"+url.PathEscape(id)+"/disable", nil, nil)
+}
+
+// ResetUserPassword sets the password of an account and revokes its
+// sessions
+func (c *Client) ResetUserPassword(ctx context.Context, id, password string) error {
+	body := map[string]string{"password": password}
+	return c.do(ctx, http.MethodPut, "/api/users/"+url.PathEscape(id)+"/password", body, nil)
+}
+
+// RevokeUserTokens signs a user out everywhere and returns how many
+// sessions were revoked
+func (c *Client) RevokeUserTokens(ctx context.Context, id string) (int, error) {
+	var resp struct {
+		Data struct {
+			Revoked int `json:"revoked"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/users/"+url.PathEscape(id)+"/tokens/revoke", nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Data.Revoked, nil
+}
+
+// CleanupTokens deletes the expired session tokens and returns how many
+// were deleted
+func (c *Client) CleanupTokens(ctx context.Context) (int64, error) {
+	var resp struct {
+		Data struct {
+			Removed int64 `json:"removed"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/admin/tokens/cleanup", nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Data.Removed, nil
+}
+
+// PurgeTrash permanently deletes the books deleted longer than olderThan
+// ago. It returns how many were purged, and how many were kept because
+// they have loans on record.
+func (c *Client) PurgeTrash(ctx context.Context, olderThan time.Duration) (purged, kept int, err error) {
+	var resp struct {
+		Data struct {
+			Purged int `json:"purged"`
+			Kept   int `json:"kept"`
+		} `json:"data"`
+	}
+	path := "/api/admin/trash/purge?older_than=" + url.QueryEscape(olderThan.String())
+	if err := c.do(ctx, http.MethodPost, path, nil, &resp); err != nil {
+		return 0, 0, err
+	}
+	return resp.Data.Purged, resp.Data.Kept, nil
+}
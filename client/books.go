@@ -0,0 +1,270 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"rest-api-golang/models"
+)
+
+// DefaultPageSize is the page size of ListBooks when none is set
+const DefaultPageSize = 50
+
+// ListBooksOptions filters and sorts the book list, like the query
+// parameters of GET /api/books; zero values mean "no filter"
+type ListBooksOptions struct {
+	ISBN      string
+	Publisher string
+	Language  string
+	Format    string
+	AuthorID  string
+	// Category is a slug or ID; books in descendant categories match too
+	Category string
+	Tags     []string
+	// MatchAllTags requires every tag instead of any of them
+	MatchAllTags bool
+	MinRating    float64
+	MinPages     int
+	MaxPages     int
+	// Sort is one of models.BookSorts, prefixed with "-" for descending
+	// order; empty means newest first
+	Sort string
+	// PageSize is how many books are fetched per request, at most 100
+	PageSize int
+}
+
+// values returns the query parameters of the options
+func (o *ListBooksOptions) values() url.Values {
+	q := url.Values{}
+	for name, v := range map[string]string{
+		"isbn": o.ISBN, "publisher": o.Publisher, "language": o.Language, "format": o.Format,
+		"author_id": o.AuthorID, "category": o.Category, "sort": o.Sort,
+	} {
+		if v != "" {
+			q.Set(name, v)
+		}
+	}
+	if len(o.Tags) > 0 {
+		q.Set("tags", strings.Join(o.Tags, ","))
+	}
+	if o.MatchAllTags {
+		q.Set("tags_match", "all")
+	}
+	if o.MinRating != 0 {
+		q.Set("min_rating", strconv.FormatFloat(o.MinRating, 'f', -1, 64))
+	}
+	for name, n := range map[string]int{"min_pages": o.MinPages, "max_pages": o.MaxPages} {
+		if n != 0 {
+			q.Set(name, strconv.Itoa(n))
+		}
+	}
+	return q
+}
+
+// BookIterator walks the pages of the book list, fetching the next page
+// when the current one is used up:
+//
+//	it := c.ListBooks(ctx, nil)
+//	for it.Next() {
+//		book := it.Book()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type BookIterator struct {
+	c     *Client
+	ctx   context.Context
+	query url.Values
+
+	page   []*models.Book
+	book   *models.Book
+	offset int
+	total  int
+	done   bool
+	err    error
+}
+
+// ListBooks returns an iterator over the books matching opts, which may
+// be nil. Books created or deleted while iterating may shift the pages,
+// so a book can be skipped or seen twice.
+func (c *Client) ListBooks(ctx context.Context, opts *ListBooksOptions) *BookIterator {
+	if opts == nil {
+		opts = &ListBooksOptions{}
+	}
+	query := opts.values()
+	pageSize := opts.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	query.Set("limit", strconv.Itoa(pageSize))
+	return &BookIterator{c: c, ctx: ctx, query: query, total: -1}
+}
+
+// Next advances to the next book, and reports false when there are no
+// more books or a request failed
+func (it *BookIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			it.book = nil
+			return false
+		}
+		it.fetch()
+	}
+	it.book, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+func (it *BookIterator) fetch() {
+	it.query.Set("offset", strconv.Itoa(it.offset))
+	var resp struct {
+		Data       []*models.Book `json:"data"`
+		Total      int            `json:"total"`
+		NextOffset *int           `json:"next_offset"`
+	}
+	if err := it.c.do(it.ctx, http.MethodGet, "/api/books?"+it.query.Encode(), nil, &resp); err != nil {
+		it.err = err
+		return
+	}
+	it.page, it.total = resp.Data, resp.Total
+	if resp.NextOffset == nil || len(resp.Data) == 0 {
+		it.done = true
+		return
+	}
+	it.offset = *resp.NextOffset
+}
+
+// Book returns the current book
+func (it *BookIterator) Book() *models.Book {
+	return it.book
+}
+
+// Total returns how many books match on all pages, as of the last page
+// fetched, or -1 before the first one
+func (it *BookIterator) Total() int {
+	return it.total
+}
+
+// Err returns the error that ended the iteration, if any
+func (it *BookIterator) Err() error {
+	return it.err
+}
+
+// bookResponse is the response of the endpoints returning one book
+type bookResponse struct {
+	Data *models.Book `json:"data"`
+}
+
+// GetBook returns a book with the availability of its copies
+func (c *Client) GetBook(ctx context.Context, id string) (*models.Book, error) {
+	var resp bookResponse
+	if err := c.do(ctx, http.MethodGet, "/api/books/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// CreateBook creates a book and returns it as saved. A failed request is
+// not retried unless the server rejected it with 429, since the book may
+// have been created.
+func (c *Client) CreateBook(ctx context.Context, req *models.CreateBookRequest) (*models.Book, error) {
+	var resp bookResponse
+	if err := c.do(ctx, http.MethodPost, "/api/books", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// UpdateBook changes the fields set in req and returns the updated book
+func (c *Client) UpdateBook(ctx context.Context, id string, req *models.UpdateBookRequest) (*models.Book, error) {
+	var resp bookResponse
+	if err := c.do(ctx, http.MethodPut, "/api/books/"+url.PathEscape(id), req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// DeleteBook soft-deletes a book and returns it as it was
+func (c *Client) DeleteBook(ctx context.Context, id string) (*models.Book, error) {
+	var resp bookResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/books/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// ImportOptions control ImportBooks
+type ImportOptions struct {
+	// Format is one of models.ImportFormats; the server detects it from
+	// the contents when empty
+	Format string
+	// Upsert updates books whose ISBN already exists instead of reporting
+	// them
+	Upsert bool
+	// DryRun validates every row without saving
+	DryRun bool
+	// Async imports in a background job even if the file is small
+	Async bool
+}
+
+// ImportBooks imports a CSV, JSON Lines or MARC file. Large files, or any
+// file with Async, are imported by a background job: the returned job then
+// has an ID and its progress is polled with GetImportJob. Otherwise the
+// job holds the finished report only.
+func (c *Client) ImportBooks(ctx context.Context, data []byte, opts *ImportOptions) (*models.ImportJob, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	q := url.Values{}
+	if opts.Format != "" {
+		q.Set("format", opts.Format)
+	}
+	if opts.Upsert {
+		q.Set("mode", "upsert")
+	}
+	if opts.DryRun {
+		q.Set("dry_run", "true")
+	}
+	if opts.Async {
+		q.Set("async", "true")
+	}
+
+	var resp struct {
+		Data *models.ImportJob `json:"data"`
+	}
+	body := rawBody{contentType: "application/octet-stream", data: data}
+	if err := c.do(ctx, http.MethodPost, "/api/books/import?"+q.Encode(), body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// GetImportJob returns the status and progress of a background import
+func (c *Client) GetImportJob(ctx context.Context, id string) (*models.ImportJob, error) {
+	var resp struct {
+		Data *models.ImportJob `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/books/import/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// ExportBooks writes the books matching opts, which may be nil, to w in
+// format, one of exporter.Formats. PageSize is ignored.
+func (c *Client) ExportBooks(ctx context.Context, w io.Writer, format string, opts *ListBooksOptions) error {
+	if opts == nil {
+		opts = &ListBooksOptions{}
+	}
+	// The export takes the binding format as book_format
+	q := opts.values()
+	if f := q.Get("format"); f != "" {
+		q.Set("book_format", f)
+	}
+	q.Set("format", format)
+	return c.do(ctx, http.MethodGet, "/api/books/export?"+q.Encode(), nil, w)
+}
@@ -0,0 +1,333 @@
+// Package client is a Go client of the Book API's REST endpoints. It keeps
+// the session token of Login, logs in again when the token expires, and
+// retries requests the server could not handle at the moment.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the retry settings of a Client
+const (
+	DefaultMaxRetries = 3
+	DefaultMinBackoff = 200 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// maxResponseBody bounds how much of a response is read
+const maxResponseBody = 16 << 20
+
+// Client calls the Book API at one base URL. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	client  *http.Client
+
+	// MaxRetries is how many times a request is retried after a 429
+	// response, or after a 5xx response or network error if it is safe to
+	// repeat (every method but POST)
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the wait before a retry, which
+	// doubles with every attempt unless the server sends Retry-After. A
+	// Retry-After longer than MaxBackoff is not waited for; the error is
+	// returned with its RetryAfter instead.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	mu       sync.Mutex
+	token    string
+	username string
+	password string
+	// loginDone is closed when the login in progress ends, so concurrent
+	// requests with an expired token wait for one new token
+	loginDone chan struct{}
+}
+
+// New creates a client of the API at baseURL, such as
+// http://localhost:8080, sending through client or a client with a 30
+// second timeout when it is nil
+func New(baseURL string, client *http.Client) *Client {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		client:     client,
+		MaxRetries: DefaultMaxRetries,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+// SetToken makes the client use an existing session token. Without the
+// credentials of Login the client cannot replace it when it expires.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// Token returns the session token in use, or "" before Login
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Login signs in and keeps the token for the following requests. The
+// credentials are kept too, to sign in again when the token expires.
+// Accounts with two-factor authentication get ErrMFARequired, since the
+// second factor cannot be supplied again unattended.
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	token, err := c.login(ctx, username, password)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.username, c.password = token, username, password
+	return nil
+}
+
+func (c *Client) login(ctx context.Context, username, password string) (string, error) {
+	var resp struct {
+		Token       string `json:"token"`
+		MFARequired bool   `json:"mfa_required"`
+	}
+	body := map[string]string{"username": username, "password": password}
+	if err := c.send(ctx, http.MethodPost, "/api/login", "", body, &resp); err != nil {
+		return "", err
+	}
+	if resp.MFARequired {
+		return "", ErrMFARequired
+	}
+	if resp.Token == "" {
+		return "", errors.New("client: login response has no token")
+	}
+	return resp.Token, nil
+}
+
+// Logout revokes the session token and forgets the credentials of Login
+func (c *Client) Logout(ctx context.Context) error {
+	c.mu.Lock()
+	token := c.token
+	c.token, c.username, c.password = "", "", ""
+	c.mu.Unlock()
+
+	if token == "" {
+		return ErrNotLoggedIn
+	}
+	return c.send(ctx, http.MethodPost, "/api/logout", token, nil, nil)
+}
+
+// do sends an authenticated request. When the token is rejected and the
+// credentials of Login are known, it signs in again and repeats the
+// request once.
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) error {
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+	if token == "" {
+		return ErrNotLoggedIn
+	}
+
+	err := c.send(ctx, method, path, token, body, result)
+	if !errors.Is(err, ErrUnauthorized) {
+		return err
+	}
+	token, ok, loginErr := c.relogin(ctx, token)
+	if loginErr != nil {
+		return loginErr
+	}
+	if !ok {
+		return err
+	}
+	return c.send(ctx, method, path, token, body, result)
+}
+
+// relogin replaces the rejected token stale, unless another request has
+// already done so, and returns the new token. ok is false when there are
+// no credentials to sign in with.
+func (c *Client) relogin(ctx context.Context, stale string) (token string, ok bool, err error) {
+	for {
+		c.mu.Lock()
+		if c.token != stale {
+			token = c.token
+			c.mu.Unlock()
+			return token, token != "", nil
+		}
+		if c.username == "" {
+			c.mu.Unlock()
+			return "", false, nil
+		}
+		if c.loginDone == nil {
+			break
+		}
+		done := c.loginDone
+		c.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return "", false, ctx.Err()
+		}
+	}
+	username, password := c.username, c.password
+	done := make(chan struct{})
+	c.loginDone = done
+	c.mu.Unlock()
+
+	token, err = c.login(ctx, username, password)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loginDone = nil
+	close(done)
+	if err != nil {
+		return "", false, fmt.Errorf("client: login again failed: %w", err)
+	}
+	// A Logout or Login while signing in wins over the new token
+	if c.token == stale && c.username == username {
+		c.token = token
+	}
+	return c.token, c.token != "", nil
+}
+
+// rawBody is a request body sent as is rather than as JSON
+type rawBody struct {
+	contentType string
+	data        []byte
+}
+
+// send makes a request with the retries of the client and decodes a
+// successful response into result, or copies it to result if that is an
+// io.Writer
+func (c *Client) send(ctx context.Context, method, path, token string, body, result interface{}) error {
+	var payload []byte
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case rawBody:
+		payload, contentType = b.data, b.contentType
+	default:
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("client: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("client: %w", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := c.client.Do(req)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			return readResult(resp, result)
+		}
+		var data []byte
+		if err == nil {
+			data, err = io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+			resp.Body.Close()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if method == http.MethodPost || attempt >= c.MaxRetries {
+				return fmt.Errorf("client: %s %s: %w", method, path, err)
+			}
+			if err := c.wait(ctx, attempt, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		apiErr := newError(resp, data)
+		retry := resp.StatusCode == http.StatusTooManyRequests ||
+			(resp.StatusCode >= 500 && method != http.MethodPost)
+		if !retry || attempt >= c.MaxRetries || apiErr.RetryAfter > c.MaxBackoff {
+			return apiErr
+		}
+		if err := c.wait(ctx, attempt, apiErr.RetryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+// wait sleeps before retry attempt+1, for retryAfter if the server asked
+// for a wait
+func (c *Client) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	d := c.MinBackoff << attempt
+	if d > c.MaxBackoff || d <= 0 {
+		d = c.MaxBackoff
+	}
+	// Full jitter spreads out clients that failed at the same time
+	d = time.Duration(rand.Int63n(int64(d) + 1))
+	if retryAfter > 0 {
+		d = retryAfter
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryAfter parses a Retry-After header of seconds or an HTTP date
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	// At most 32 bits of seconds, so the duration cannot overflow
+	if n, err := strconv.ParseInt(v, 10, 32); err == nil {
+		return max(time.Duration(n)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(v); err == nil && time.Until(t) > 0 {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// readResult reads a success response into result
+func readResult(resp *http.Response, result interface{}) error {
+	defer resp.Body.Close()
+	if w, ok := result.(io.Writer); ok {
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			return fmt.Errorf("client: failed to read response: %w", err)
+		}
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return fmt.Errorf("client: failed to read response: %w", err)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("client: invalid response: %w", err)
+	}
+	return nil
+}
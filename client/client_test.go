@@ -0,0 +1,354 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"rest-api-golang/database"
+	"rest-api-golang/handlers"
+	"rest-api-golang/internal/testdb"
+	"rest-api-golang/mailer"
+	"rest-api-golang/models"
+	"rest-api-golang/storage"
+
+	"github.com/google/uuid"
+)
+
+// fault answers a request in place of the API, or passes it on to next
+type fault func(w http.ResponseWriter, r *http.Request, next http.Handler)
+
+// testAPI serves the real router on a fresh test database. Faults queued
+// for a method and path answer the next requests to it, to stand in for
+// an overloaded server or a proxy in between.
+type testAPI struct {
+	*httptest.Server
+	mu     sync.Mutex
+	calls  map[string]int
+	faults map[string][]fault
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	testdb.Open(t)
+	handlers.InitializeRepositories()
+	handlers.InitializeMailer(mailer.NewMemoryMailer(), "library@example.com")
+	handlers.InitializeBlobStore(storage.NewMemoryStore())
+	router := handlers.RequestIDMiddleware(handlers.AuthMiddleware(handlers.NewRouter()))
+
+	api := &testAPI{calls: map[string]int{}, faults: map[string][]fault{}}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path
+		api.mu.Lock()
+		api.calls[key]++
+		var f fault
+		if queued := api.faults[key]; len(queued) > 0 {
+			f, api.faults[key] = queued[0], queued[1:]
+		}
+		api.mu.Unlock()
+		if f == nil {
+			router.ServeHTTP(w, r)
+			return
+		}
+		f(w, r, router)
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// fail queues faults for the next requests to method and path
+func (api *testAPI) fail(method, path string, faults ...fault) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	key := method + " " + path
+	api.faults[key] = append(api.faults[key], faults...)
+}
+
+// count returns how many requests were made to method and path
+func (api *testAPI) count(method, path string) int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.calls[method+" "+path]
+}
+
+// status answers with code and body, and Retry-After if retryAfter is set
+func status(code int, retryAfter string, body string) fault {
+	return func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}
+}
+
+// dropConnection closes the connection without a response
+func dropConnection(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+// newTestClient logs in to api with fast retries
+func newTestClient(t *testing.T, api *testAPI, username, password string) *Client {
+	t.Helper()
+	c := New(api.URL, api.Client())
+	c.MinBackoff = time.Millisecond
+	c.MaxBackoff = 20 * time.Millisecond
+	if err := c.Login(context.Background(), username, password); err != nil {
+		t.Fatalf("login as %s: %v", username, err)
+	}
+	return c
+}
+
+func createTestBook(t *testing.T, c *Client) *models.Book {
+	t.Helper()
+	book, err := c.CreateBook(context.Background(), &models.CreateBookRequest{Judul: "Laskar Pelangi", Author: "Andrea Hirata", TahunTerbit: 2005})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return book
+}
+
+func TestClientLogsInAgainOnce(t *testing.T) {
+	api := newTestAPI(t)
+	c := newTestClient(t, api, "admin", "admin123")
+	book := createTestBook(t, c)
+
+	// The session ends, and a slow login lets every request get its 401
+	// before the new token arrives
+	if _, err := database.DB.Exec(`UPDATE tokens SET is_revoked = true WHERE token = $1`, c.Token()); err != nil {
+		t.Fatal(err)
+	}
+	stale := c.Token()
+	api.fail("POST", "/api/login", func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		time.Sleep(100 * time.Millisecond)
+		next.ServeHTTP(w, r)
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := c.GetBook(context.Background(), book.ID)
+			if err == nil && got.ID != book.ID {
+				err = errors.New("got book " + got.ID)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := api.count("POST", "/api/login"); n != 2 {
+		t.Errorf("client logged in %d times, want once more", n)
+	}
+	if c.Token() == stale || c.Token() == "" {
+		t.Error("client kept the revoked token")
+	}
+}
+
+func TestClientRetriesRateLimits(t *testing.T) {
+	api := newTestAPI(t)
+	c := newTestClient(t, api, "admin", "admin123")
+	c.MaxBackoff = 2 * time.Second
+	book := createTestBook(t, c)
+	path := "/api/books/" + book.ID
+
+	// POST too: the server did not handle the request
+	api.fail("POST", "/api/books", status(http.StatusTooManyRequests, "1", `{"success":false,"message":"Too many requests"}`))
+	start := time.Now()
+	if _, err := c.CreateBook(context.Background(), &models.CreateBookRequest{Judul: "Sang Pemimpi", Author: "Andrea Hirata", TahunTerbit: 2006}); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("retried after %s, want the Retry-After of 1s", waited)
+	}
+	if n := api.count("POST", "/api/books"); n != 3 {
+		t.Errorf("%d POST requests, want the first book, the 429 and its retry", n)
+	}
+
+	// A wait longer than MaxBackoff is left to the caller
+	api.fail("GET", path, status(http.StatusTooManyRequests, "120", `{"success":false,"message":"Too many requests"}`))
+	start = time.Now()
+	_, err := c.GetBook(context.Background(), book.ID)
+	var apiErr *Error
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &apiErr) || apiErr.RetryAfter != 2*time.Minute {
+		t.Errorf("GetBook = %v", err)
+	}
+	if time.Since(start) > time.Second || api.count("GET", path) != 1 {
+		t.Errorf("client waited %s and made %d requests", time.Since(start), api.count("GET", path))
+	}
+
+	// Retries end after MaxRetries
+	c.MaxRetries = 2
+	tooMany := status(http.StatusTooManyRequests, "", "")
+	api.fail("GET", path, tooMany, tooMany, tooMany, tooMany)
+	if _, err := c.GetBook(context.Background(), book.ID); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("GetBook after MaxRetries = %v", err)
+	}
+	if n := api.count("GET", path); n != 4 {
+		t.Errorf("%d GET requests, want 1 before and 3 now", n)
+	}
+}
+
+func TestClientDoesNotRetryPOST(t *testing.T) {
+	api := newTestAPI(t)
+	c := newTestClient(t, api, "admin", "admin123")
+	req := &models.CreateBookRequest{Judul: "Edensor", Author: "Andrea Hirata", TahunTerbit: 2007}
+
+	api.fail("POST", "/api/books", status(http.StatusBadGateway, "", "<html>Bad Gateway</html>"))
+	if _, err := c.CreateBook(context.Background(), req); !errors.Is(err, ErrServer) {
+		t.Errorf("CreateBook after a 502 = %v", err)
+	}
+	api.fail("POST", "/api/books", dropConnection)
+	if _, err := c.CreateBook(context.Background(), req); err == nil || errors.Is(err, ErrServer) {
+		t.Errorf("CreateBook after a dropped connection = %v", err)
+	}
+	// The book may have been created, so neither was sent again
+	if n := api.count("POST", "/api/books"); n != 2 {
+		t.Errorf("%d POST requests, want 2", n)
+	}
+}
+
+func TestClientRetriesIdempotentRequests(t *testing.T) {
+	api := newTestAPI(t)
+	c := newTestClient(t, api, "admin", "admin123")
+	book := createTestBook(t, c)
+	path := "/api/books/" + book.ID
+	unavailable := status(http.StatusServiceUnavailable, "", `{"success":false,"message":"Database unavailable"}`)
+
+	api.fail("GET", path, unavailable, status(http.StatusBadGateway, "", "<html>Bad Gateway</html>"))
+	if _, err := c.GetBook(context.Background(), book.ID); err != nil {
+		t.Errorf("GetBook = %v", err)
+	}
+	api.fail("PUT", path, unavailable)
+	title := "Laskar Pelangi (Edisi Baru)"
+	if updated, err := c.UpdateBook(context.Background(), book.ID, &models.UpdateBookRequest{Judul: title}); err != nil || updated.Judul != title {
+		t.Errorf("UpdateBook = %v, %v", updated, err)
+	}
+	api.fail("DELETE", path, unavailable)
+	if _, err := c.DeleteBook(context.Background(), book.ID); err != nil {
+		t.Errorf("DeleteBook = %v", err)
+	}
+
+	for method, want := range map[string]int{"GET": 3, "PUT": 2, "DELETE": 2} {
+		if n := api.count(method, path); n != want {
+			t.Errorf("%d %s requests, want %d", n, method, want)
+		}
+	}
+}
+
+func TestClientMFARequired(t *testing.T) {
+	api := newTestAPI(t)
+	admin := newTestClient(t, api, "admin", "admin123")
+	if err := admin.do(context.Background(), http.MethodPut, "/api/admin/mfa-policy", &models.MFAPolicy{RequiredRoles: []string{"user"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(api.URL, api.Client())
+	if err := c.Login(context.Background(), "user", "user123"); !errors.Is(err, ErrMFARequired) {
+		t.Errorf("Login = %v, want ErrMFARequired", err)
+	}
+	if c.Token() != "" {
+		t.Error("client kept a token")
+	}
+	if _, err := c.GetBook(context.Background(), uuid.New().String()); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("GetBook = %v, want ErrNotLoggedIn", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	api := newTestAPI(t)
+	admin := newTestClient(t, api, "admin", "admin123")
+	user := newTestClient(t, api, "user", "user123")
+	ctx := context.Background()
+
+	_, notFound := admin.GetBook(ctx, uuid.New().String())
+	_, invalidID := admin.GetBook(ctx, "not-a-uuid")
+	_, invalidBook := admin.CreateBook(ctx, &models.CreateBookRequest{Judul: "Tanpa Tahun"})
+	_, forbidden := user.CreateUser(ctx, &models.CreateUserRequest{Username: "budi", Password: "rahasia123", Email: "budi@example.com"})
+	stranger := New(api.URL, api.Client())
+	stranger.SetToken("not-a-session")
+	_, unauthorized := stranger.GetBook(ctx, uuid.New().String())
+	api.fail("GET", "/api/books", status(http.StatusBadGateway, "", "  <html>Bad Gateway</html>\n"))
+	admin.MaxRetries = 0
+	books := admin.ListBooks(ctx, nil)
+	if books.Next() {
+		t.Error("listed books through a failing proxy")
+	}
+	proxy := books.Err()
+
+	tests := []struct {
+		name    string
+		err     error
+		is      error
+		status  int
+		message string
+	}{
+		{"not found", notFound, ErrNotFound, http.StatusNotFound, "Book not found"},
+		{"invalid ID", invalidID, ErrBadRequest, http.StatusBadRequest, ""},
+		{"invalid book", invalidBook, ErrBadRequest, http.StatusBadRequest, ""},
+		{"forbidden", forbidden, ErrForbidden, http.StatusForbidden, ""},
+		{"unauthorized", unauthorized, ErrUnauthorized, http.StatusUnauthorized, ""},
+		{"proxy", proxy, ErrServer, http.StatusBadGateway, "<html>Bad Gateway</html>"},
+	}
+	for _, tt := range tests {
+		var apiErr *Error
+		if !errors.As(tt.err, &apiErr) {
+			t.Errorf("%s: %v is not an *Error", tt.name, tt.err)
+			continue
+		}
+		if !errors.Is(tt.err, tt.is) || apiErr.StatusCode != tt.status || apiErr.Message == "" {
+			t.Errorf("%s: %+v", tt.name, apiErr)
+		}
+		if tt.message != "" && apiErr.Message != tt.message {
+			t.Errorf("%s: message %q, want %q", tt.name, apiErr.Message, tt.message)
+		}
+		for _, other := range []error{ErrNotFound, ErrBadRequest, ErrForbidden, ErrUnauthorized, ErrServer} {
+			if other != tt.is && errors.Is(tt.err, other) {
+				t.Errorf("%s: also matches %v", tt.name, other)
+			}
+		}
+		// The API's errors carry the ID to find the request in its logs
+		if tt.name != "proxy" {
+			if _, err := uuid.Parse(apiErr.RequestID); err != nil {
+				t.Errorf("%s: request ID %q", tt.name, apiErr.RequestID)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":          0,
+		"3":         3 * time.Second,
+		"-3":        0,
+		"soon":      0,
+		"999999999": 999999999 * time.Second,
+		// Too many seconds for 32 bits is not trusted
+		strconv.FormatInt(1<<40, 10):                             0,
+		time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat): 0,
+	}
+	for v, want := range tests {
+		if got := retryAfter(v); got != want {
+			t.Errorf("retryAfter(%q) = %s, want %s", v, got, want)
+		}
+	}
+	future := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if future <= 55*time.Second || future > time.Minute {
+		t.Errorf("retryAfter of a date a minute from now = %s", future)
+	}
+}
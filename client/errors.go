@@ -0,0 +1,90 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors matched with errors.Is against the *Error of a failed request
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// ErrNotLoggedIn is returned by requests made before Login or SetToken
+var ErrNotLoggedIn = errors.New("client: not logged in")
+
+// ErrMFARequired is returned by Login for accounts with two-factor
+// authentication, which have to sign in through the web frontend
+var ErrMFARequired = errors.New("client: two-factor authentication required")
+
+// Error is the error response of a request, decoded from the server's
+// {"success": false, "message": ...} body
+type Error struct {
+	StatusCode int
+	Message    string
+	// RequestID is the X-Request-ID of the request, to find it in the
+	// server's logs and audit events
+	RequestID string
+	// RetryAfter is how long the server asked to wait before trying again,
+	// from the Retry-After header of a 429 or 503 response
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("client: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is matches the sentinel error of the status code class
+func (e *Error) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	}
+	return e.StatusCode >= 500 && target == ErrServer
+}
+
+// newError decodes the error response resp with body data. Bodies that
+// are not the server's JSON, such as those of a proxy, give the status
+// text or the start of the body as message.
+func newError(resp *http.Response, data []byte) *Error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-ID"),
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Message != "" {
+		e.Message = body.Message
+		return e
+	}
+	e.Message = strings.TrimSpace(string(data))
+	if len(e.Message) > 200 {
+		e.Message = e.Message[:200]
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
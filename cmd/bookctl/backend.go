@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"rest-api-golang/models"
+)
+
+// backend carries out the commands, either directly against the database
+// or through the HTTP API of a running server. migrate and seed only work
+// directly, see directBackend.
+type backend interface {
+	ListUsers(ctx context.Context) ([]*models.User, error)
+	CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error)
+	DisableUser(ctx context.Context, id string) error
+	ResetPassword(ctx context.Context, id, password string) error
+
+	// RevokeUserTokens returns how many sessions were revoked
+	RevokeUserTokens(ctx context.Context, userID string) (int, error)
+	RevokeToken(ctx context.Context, token string) error
+	// CleanupTokens returns how many expired tokens were deleted
+	CleanupTokens(ctx context.Context) (int64, error)
+
+	// ImportBooks imports a file of format, detected from fileName and the
+	// contents when empty, and returns the finished report
+	ImportBooks(ctx context.Context, fileName string, data []byte, format string, upsert, dryRun bool) (*models.ImportReport, error)
+	ExportBooks(ctx context.Context, w io.Writer, format string, filter models.BookFilter) error
+	PurgeTrash(ctx context.Context, olderThan time.Duration) (purged, kept int, err error)
+
+	Close() error
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"rest-api-golang/exporter"
+	"rest-api-golang/models"
+)
+
+// defaultTrashRetention matches the default of POST /api/admin/trash/purge
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// cli runs one command line
+type cli struct {
+	ctx context.Context
+	out *output
+	// open connects the backend of the global flags
+	open func() (backend, error)
+	// openDirect connects to the database, failing with -remote
+	openDirect func() (*directBackend, error)
+}
+
+func (c *cli) run(args []string) error {
+	command, args := args[0], args[1:]
+	switch command {
+	case "user", "token", "book":
+		if len(args) == 0 {
+			return usageError(command + " needs a subcommand")
+		}
+		sub, args := args[0], args[1:]
+		run, ok := map[string]func([]string) error{
+			"user list":           c.userList,
+			"user create":         c.userCreate,
+			"user disable":        c.userDisable,
+			"user reset-password": c.userResetPassword,
+			"token revoke":        c.tokenRevoke,
+			"token cleanup":       c.tokenCleanup,
+			"book import":         c.bookImport,
+			"book export":         c.bookExport,
+			"book purge-trash":    c.bookPurgeTrash,
+		}[command+" "+sub]
+		if !ok {
+			return usageError(fmt.Sprintf("unknown command %q", command+" "+sub))
+		}
+		return run(args)
+	case "migrate":
+		return c.migrate(args)
+	case "seed":
+		return c.seed(args)
+	}
+	return usageError(fmt.Sprintf("unknown command %q", command))
+}
+
+// parse parses the flags of a subcommand and checks it has want (0 or 1)
+// arguments. The flag package reports unknown flags and prints the flags
+// for -h itself.
+func parse(fs *flag.FlagSet, args []string, want int) error {
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return errHelp
+	} else if err != nil {
+		return usageError(fs.Name() + ": " + err.Error())
+	}
+	switch {
+	case fs.NArg() == want:
+		return nil
+	case want == 0:
+		return usageError(fs.Name() + " takes no arguments")
+	}
+	return usageError(fs.Name() + " takes exactly one argument")
+}
+
+// withBackend runs fn with the backend of the global flags
+func (c *cli) withBackend(fn func(b backend) error) error {
+	b, err := c.open()
+	if err != nil {
+		return err
+	}
+	err = fn(b)
+	if closeErr := b.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// findUser looks up an account by username, active or not
+func (c *cli) findUser(b backend, username string) (*models.User, error) {
+	users, err := b.ListUsers(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("user %q not found", username)
+}
+
+// readPassword returns flagValue, or else a line read from standard input
+func readPassword(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *cli) userList(args []string) error {
+	if err := parse(flag.NewFlagSet("user list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	return c.withBackend(func(b backend) error {
+		users, err := b.ListUsers(c.ctx)
+		if err != nil {
+			return err
+		}
+		return c.out.users(users, users)
+	})
+}
+
+func (c *cli) userCreate(args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	role := fs.String("role", models.DefaultUserRole, "role of the account")
+	email := fs.String("email", "", "email address")
+	password := fs.String("password", "", "password, read from standard input if empty")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	pw, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+
+	req := &models.CreateUserRequest{Username: fs.Arg(0), Password: pw, Email: *email, Role: *role}
+	return c.withBackend(func(b backend) error {
+		user, err := b.CreateUser(c.ctx, req)
+		if err != nil {
+			return err
+		}
+		return c.out.users(user, []*models.User{user})
+	})
+}
+
+func (c *cli) userDisable(args []string) error {
+	fs := flag.NewFlagSet("user disable", flag.ContinueOnError)
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	return c.withBackend(func(b backend) error {
+		user, err := c.findUser(b, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if err := b.DisableUser(c.ctx, user.ID); err != nil {
+			return err
+		}
+		user.IsActive = false
+		return c.out.message(user, "Disabled "+user.Username+" and revoked their sessions")
+	})
+}
+
+func (c *cli) userResetPassword(args []string) error {
+	fs := flag.NewFlagSet("user reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "new password, read from standard input if empty")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	pw, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	return c.withBackend(func(b backend) error {
+		user, err := c.findUser(b, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if err := b.ResetPassword(c.ctx, user.ID, pw); err != nil {
+			return err
+		}
+		return c.out.message(user, "Reset the password of "+user.Username+" and revoked their sessions")
+	})
+}
+
+func (c *cli) tokenRevoke(args []string) error {
+	fs := flag.NewFlagSet("token revoke", flag.ContinueOnError)
+	token := fs.String("token", "", "revoke this one session token instead of all of a user's")
+	want := 1
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		want = 0
+	}
+	if err := parse(fs, args, want); err != nil {
+		return err
+	}
+	if (*token == "") == (want == 0) {
+		return usageError("token revoke takes a USERNAME or -token")
+	}
+
+	return c.withBackend(func(b backend) error {
+		if *token != "" {
+			if err := b.RevokeToken(c.ctx, *token); err != nil {
+				return err
+			}
+			return c.out.message(map[string]int{"revoked": 1}, "Revoked the token")
+		}
+		user, err := c.findUser(b, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		revoked, err := b.RevokeUserTokens(c.ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		return c.out.message(map[string]int{"revoked": revoked}, fmt.Sprintf("Revoked %d sessions of %s", revoked, user.Username))
+	})
+}
+
+func (c *cli) tokenCleanup(args []string) error {
+	if err := parse(flag.NewFlagSet("token cleanup", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	return c.withBackend(func(b backend) error {
+		removed, err := b.CleanupTokens(c.ctx)
+		if err != nil {
+			return err
+		}
+		return c.out.message(map[string]int64{"removed": removed}, fmt.Sprintf("Removed %d expired tokens", removed))
+	})
+}
+
+func (c *cli) bookImport(args []string) error {
+	fs := flag.NewFlagSet("book import", flag.ContinueOnError)
+	format := fs.String("format", "", "csv, jsonl, marc or marcxml; detected from the file when empty")
+	upsert := fs.Bool("upsert", false, "update books whose ISBN exists instead of reporting them")
+	dryRun := fs.Bool("dry-run", false, "validate every row without saving")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	if *format != "" && !isOneOf(*format, models.ImportFormats) {
+		return usageError("-format must be one of: " + strings.Join(models.ImportFormats, ", "))
+	}
+
+	fileName := fs.Arg(0)
+	var data []byte
+	var err error
+	if fileName == "-" {
+		fileName = ""
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(fileName)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.withBackend(func(b backend) error {
+		report, err := b.ImportBooks(c.ctx, fileName, data, *format, *upsert, *dryRun)
+		if err != nil {
+			return err
+		}
+		return c.out.importReport(report, *dryRun)
+	})
+}
+
+func (c *cli) bookExport(args []string) error {
+	fs := flag.NewFlagSet("book export", flag.ContinueOnError)
+	format := fs.String("format", exporter.FormatCSV, "one of: "+strings.Join(exporter.Formats, ", "))
+	outFile := fs.String("out", "", "file to write, standard output if empty")
+	var filter models.BookFilter
+	fs.StringVar(&filter.Publisher, "publisher", "", "only books of this publisher")
+	fs.StringVar(&filter.Language, "language", "", "only books in this language")
+	fs.StringVar(&filter.Format, "book-format", "", "only books of this binding format")
+	fs.StringVar(&filter.Category, "category", "", "only books in this category slug or ID, or its descendants")
+	tags := fs.String("tags", "", "only books with any of these comma-separated tags")
+	fs.StringVar(&filter.Sort, "sort", "", "sort field, prefixed with - for descending order")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if !isOneOf(*format, exporter.Formats) {
+		return usageError("-format must be one of: " + strings.Join(exporter.Formats, ", "))
+	}
+	filter.Tags = models.NormalizeTags(splitList(*tags))
+
+	return c.withBackend(func(b backend) error {
+		if *outFile == "" {
+			return b.ExportBooks(c.ctx, os.Stdout, *format, filter)
+		}
+		f, err := os.Create(*outFile)
+		if err != nil {
+			return err
+		}
+		if err := b.ExportBooks(c.ctx, f, *format, filter); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+func (c *cli) bookPurgeTrash(args []string) error {
+	fs := flag.NewFlagSet("book purge-trash", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", defaultTrashRetention, "purge books deleted longer ago than this; 0s empties the trash")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *olderThan < 0 {
+		return usageError("-older-than must not be negative")
+	}
+	return c.withBackend(func(b backend) error {
+		purged, kept, err := b.PurgeTrash(c.ctx, *olderThan)
+		if err != nil {
+			return err
+		}
+		text := fmt.Sprintf("Purged %d deleted books", purged)
+		if kept > 0 {
+			text += fmt.Sprintf(", kept %d with loans on record", kept)
+		}
+		return c.out.message(map[string]int{"purged": purged, "kept": kept}, text)
+	})
+}
+
+func (c *cli) migrate(args []string) error {
+	if err := parse(flag.NewFlagSet("migrate", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	b, err := c.openDirect()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	if err := b.Migrate(c.ctx); err != nil {
+		return err
+	}
+	return c.out.message(map[string]bool{"migrated": true}, "Database is up to date")
+}
+
+func (c *cli) seed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	config := fs.String("config", "config.yaml", "YAML file with more users to create; empty for none")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	b, err := c.openDirect()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	created, err := b.Seed(c.ctx, *config)
+	if err != nil {
+		return err
+	}
+	return c.out.message(map[string]int{"created_users": created}, fmt.Sprintf("Seeded default data and created %d users from %s", created, *config))
+}
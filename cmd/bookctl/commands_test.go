@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rest-api-golang/client"
+	"rest-api-golang/database"
+	"rest-api-golang/handlers"
+	"rest-api-golang/internal/testdb"
+	"rest-api-golang/mailer"
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+	"rest-api-golang/storage"
+)
+
+// testDirect is the direct backend on the test database, which it leaves
+// open on Close
+type testDirect struct {
+	*directBackend
+}
+
+func (testDirect) Close() error {
+	return nil
+}
+
+// startTestAPI serves the real router on a fresh test database. It skips
+// the test without a test database.
+func startTestAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	testdb.Open(t)
+	t.Setenv("BLOB_STORE", "memory")
+	handlers.InitializeRepositories()
+	handlers.InitializeMailer(mailer.NewMemoryMailer(), "library@example.com")
+	handlers.InitializeBlobStore(storage.NewMemoryStore())
+	srv := httptest.NewServer(handlers.RequestIDMiddleware(handlers.AuthMiddleware(handlers.NewRouter())))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestCLI runs commands on the database of srv, directly or through
+// srv signed in as the seeded admin
+func newTestCLI(t *testing.T, srv *httptest.Server, remote bool) (*cli, *bytes.Buffer) {
+	t.Helper()
+	ctx := context.Background()
+	out := &bytes.Buffer{}
+	audit := &models.AuditContext{UserAgent: "bookctl"}
+	return &cli{
+		ctx: ctx,
+		out: &output{w: out},
+		open: func() (backend, error) {
+			if remote {
+				return newRemoteBackend(ctx, srv.URL, "admin", "admin123", "")
+			}
+			return testDirect{&directBackend{
+				audit:  audit,
+				users:  repositories.NewUserRepository(database.DB).WithAudit(audit),
+				tokens: repositories.NewTokenRepository(database.DB).WithAudit(audit),
+				books:  repositories.NewBookRepository(database.DB).WithAudit(audit),
+			}}, nil
+		},
+		openDirect: func() (*directBackend, error) {
+			return nil, errors.New("not available in tests")
+		},
+	}, out
+}
+
+// runCLI runs args and returns what the command printed
+func runCLI(t *testing.T, c *cli, out *bytes.Buffer, args ...string) string {
+	t.Helper()
+	out.Reset()
+	if err := c.run(args); err != nil {
+		t.Fatalf("bookctl %s: %v", strings.Join(args, " "), err)
+	}
+	return out.String()
+}
+
+func TestCommands(t *testing.T) {
+	for _, remote := range []bool{false, true} {
+		name := "direct"
+		if remote {
+			name = "remote"
+		}
+		t.Run(name, func(t *testing.T) {
+			testCommands(t, remote)
+		})
+	}
+}
+
+func testCommands(t *testing.T, remote bool) {
+	srv := startTestAPI(t)
+	c, out := newTestCLI(t, srv, remote)
+	ctx := context.Background()
+	login := func(password string) error {
+		return client.New(srv.URL, srv.Client()).Login(ctx, "alice", password)
+	}
+
+	if got := runCLI(t, c, out, "user", "create", "-email", "alice@example.com", "-password", "first-password", "alice"); !strings.Contains(got, "alice@example.com") {
+		t.Errorf("user create printed:\n%s", got)
+	}
+	c.out.json = true
+	var users []*models.User
+	if err := json.Unmarshal([]byte(runCLI(t, c, out, "user", "list")), &users); err != nil {
+		t.Fatal(err)
+	}
+	c.out.json = false
+	var alice *models.User
+	for _, u := range users {
+		if u.Username == "alice" {
+			alice = u
+		}
+	}
+	if len(users) != 3 || alice == nil || alice.Role != models.DefaultUserRole || !alice.IsActive {
+		t.Fatalf("user list: %+v", users)
+	}
+
+	if err := c.run([]string{"user", "reset-password", "-password", strings.Repeat("x", 73), "alice"}); err == nil || !strings.Contains(err.Error(), "at most 72 bytes") {
+		t.Errorf("reset to a password longer than bcrypt reads: %v", err)
+	}
+	runCLI(t, c, out, "user", "reset-password", "-password", "second-password", "alice")
+	var stored string
+	if err := database.DB.QueryRow(`SELECT password FROM users WHERE username = 'alice'`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored, "$2a$") {
+		t.Errorf("the reset password is stored as %q, not as a bcrypt hash", stored)
+	}
+	if err := login("first-password"); err == nil {
+		t.Error("the old password still works")
+	}
+	if err := login("second-password"); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+
+	session := client.New(srv.URL, srv.Client())
+	if err := session.Login(ctx, "alice", "second-password"); err != nil {
+		t.Fatal(err)
+	}
+	if got := runCLI(t, c, out, "token", "revoke", "alice"); !strings.HasPrefix(got, "Revoked ") || strings.HasPrefix(got, "Revoked 0 ") {
+		t.Errorf("token revoke printed %q", got)
+	}
+	stale := client.New(srv.URL, srv.Client())
+	stale.SetToken(session.Token())
+	if _, err := stale.GetBook(ctx, "00000000-0000-0000-0000-000000000000"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("revoked session: %v", err)
+	}
+
+	if got := runCLI(t, c, out, "user", "disable", "alice"); got != "Disabled alice and revoked their sessions\n" {
+		t.Errorf("user disable printed %q", got)
+	}
+	if err := login("second-password"); err == nil {
+		t.Error("a disabled user can sign in")
+	}
+	if err := c.run([]string{"user", "disable", "nobody"}); err == nil || !strings.Contains(err.Error(), `"nobody" not found`) {
+		t.Errorf("disable unknown user: %v", err)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "books.csv")
+	csv := "judul,author,tahun_terbit,tags\nLaskar Pelangi,Andrea Hirata,2005,novel\nCantik Itu Luka,Eka Kurniawan,2002,sejarah\n,No Title,2005,\n"
+	if err := os.WriteFile(file, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := runCLI(t, c, out, "book", "import", "-dry-run", file); !strings.HasPrefix(got, "Dry run: 3 rows: 2 created, 0 updated, 1 failed\n") {
+		t.Errorf("dry run printed:\n%s", got)
+	}
+	if got := runCLI(t, c, out, "book", "import", file); !strings.HasPrefix(got, "Imported 3 rows: 2 created, 0 updated, 1 failed\n") || !strings.Contains(got, "ROW") {
+		t.Errorf("import printed:\n%s", got)
+	}
+
+	exported := filepath.Join(dir, "export.csv")
+	runCLI(t, c, out, "book", "export", "-format", "csv", "-tags", "NOVEL", "-out", exported)
+	data, err := os.ReadFile(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Laskar Pelangi") || strings.Contains(string(data), "Cantik Itu Luka") {
+		t.Errorf("export filtered by tag:\n%s", data)
+	}
+
+	admin := client.New(srv.URL, srv.Client())
+	if err := admin.Login(ctx, "admin", "admin123"); err != nil {
+		t.Fatal(err)
+	}
+	books := admin.ListBooks(ctx, nil)
+	for books.Next() {
+		if _, err := admin.DeleteBook(ctx, books.Book().ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := books.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := runCLI(t, c, out, "book", "purge-trash"); got != "Purged 0 deleted books\n" {
+		t.Errorf("purge-trash with the default retention printed %q", got)
+	}
+	if got := runCLI(t, c, out, "book", "purge-trash", "-older-than", "0s"); got != "Purged 2 deleted books\n" {
+		t.Errorf("purge-trash -older-than 0s printed %q", got)
+	}
+
+	if got := runCLI(t, c, out, "token", "cleanup"); !strings.HasPrefix(got, "Removed ") {
+		t.Errorf("token cleanup printed %q", got)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	c := &cli{
+		ctx: context.Background(),
+		out: &output{w: &bytes.Buffer{}},
+		open: func() (backend, error) {
+			t.Error("opened the backend for a command line mistake")
+			return nil, errors.New("no backend")
+		},
+		openDirect: func() (*directBackend, error) {
+			return nil, errors.New("this command needs the database and cannot be used with -remote")
+		},
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"frobnicate"}, `unknown command "frobnicate"`},
+		{[]string{"user"}, "user needs a subcommand"},
+		{[]string{"user", "delete", "alice"}, `unknown command "user delete"`},
+		{[]string{"user", "list", "extra"}, "user list takes no arguments"},
+		{[]string{"user", "disable"}, "user disable takes exactly one argument"},
+		{[]string{"user", "create", "-password", "secret-password", "a", "b"}, "user create takes exactly one argument"},
+		{[]string{"user", "create", "-admin", "alice"}, "user create: flag provided but not defined: -admin"},
+		{[]string{"token", "revoke"}, "token revoke takes exactly one argument"},
+		{[]string{"token", "revoke", "-token", "abc", "alice"}, "token revoke takes no arguments"},
+		{[]string{"token", "revoke", "-token", ""}, "token revoke takes a USERNAME or -token"},
+		{[]string{"book", "import", "-format", "xlsx", "books.xlsx"}, "-format must be one of: "},
+		{[]string{"book", "export", "-format", "pdf"}, "-format must be one of: "},
+		{[]string{"book", "purge-trash", "-older-than", "-1h"}, "-older-than must not be negative"},
+		{[]string{"book", "purge-trash", "-older-than", "30"}, "book purge-trash: invalid value"},
+	}
+	for _, tt := range tests {
+		err := c.run(tt.args)
+		var usageErr usageError
+		if !errors.As(err, &usageErr) || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("bookctl %s: %v, want usage error %q", strings.Join(tt.args, " "), err, tt.want)
+		}
+	}
+
+	if err := c.run([]string{"book", "export", "-h"}); err != errHelp {
+		t.Errorf("-h: %v", err)
+	}
+	for _, command := range []string{"migrate", "seed"} {
+		if err := c.run([]string{command}); err == nil || !strings.Contains(err.Error(), "cannot be used with -remote") {
+			t.Errorf("%s with -remote: %v", command, err)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	var buf bytes.Buffer
+	o := &output{w: &buf}
+	report := &models.ImportReport{Total: 2, Created: 1, Failed: 1, Errors: []models.ImportRowError{{Row: 3, ISBN: "123", Message: "invalid ISBN"}}}
+	if err := o.importReport(report, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "Imported 2 rows: 1 created, 0 updated, 1 failed\n\nROW  ISBN  ERROR\n3    123   invalid ISBN\n"
+	if buf.String() != want {
+		t.Errorf("table:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	o.json = true
+	if err := o.message(map[string]int{"purged": 2}, "Purged 2 deleted books"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "{\n  \"purged\": 2\n}\n" {
+		t.Errorf("json: %q", buf.String())
+	}
+}
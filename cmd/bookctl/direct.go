@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"rest-api-golang/covers"
+	"rest-api-golang/database"
+	"rest-api-golang/exporter"
+	"rest-api-golang/handlers"
+	"rest-api-golang/importer"
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+	"rest-api-golang/storage"
+
+	"gopkg.in/yaml.v3"
+)
+
+// directBackend works on the database of DB_* like the server does, so
+// it needs no running server or admin account. Changes are audited with
+// bookctl as the user agent and no actor.
+type directBackend struct {
+	audit  *models.AuditContext
+	users  *repositories.UserRepository
+	tokens *repositories.TokenRepository
+	books  *repositories.BookRepository
+}
+
+func newDirectBackend() (*directBackend, error) {
+	if err := database.ConnectDatabase(); err != nil {
+		return nil, err
+	}
+	handlers.InitializeRepositories()
+	audit := &models.AuditContext{UserAgent: "bookctl"}
+	return &directBackend{
+		audit:  audit,
+		users:  repositories.NewUserRepository(database.DB).WithAudit(audit),
+		tokens: repositories.NewTokenRepository(database.DB).WithAudit(audit),
+		books:  repositories.NewBookRepository(database.DB).WithAudit(audit),
+	}, nil
+}
+
+func (b *directBackend) ListUsers(ctx context.Context) ([]*models.User, error) {
+	return b.users.ListUsers()
+}
+
+func (b *directBackend) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+	if msg := req.Validate(); msg != "" {
+		return nil, errors.New(msg)
+	}
+	user := models.NewUser(*req)
+	if err := b.users.CreateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (b *directBackend) DisableUser(ctx context.Context, id string) error {
+	return b.users.DisableUser(id)
+}
+
+func (b *directBackend) ResetPassword(ctx context.Context, id, password string) error {
+	if msg := models.PasswordError(password); msg != "" {
+		return errors.New(msg)
+	}
+	return b.users.SetPassword(id, password)
+}
+
+func (b *directBackend) RevokeUserTokens(ctx context.Context, userID string) (int, error) {
+	return b.tokens.RevokeUserTokens(userID)
+}
+
+func (b *directBackend) RevokeToken(ctx context.Context, token string) error {
+	return b.tokens.RevokeToken(token)
+}
+
+func (b *directBackend) CleanupTokens(ctx context.Context) (int64, error) {
+	return b.tokens.CleanupExpiredTokens()
+}
+
+func (b *directBackend) ImportBooks(ctx context.Context, fileName string, data []byte, format string, upsert, dryRun bool) (*models.ImportReport, error) {
+	if format == "" {
+		format = importer.DetectFormat(fileName, "", data)
+	}
+	if !isOneOf(format, models.ImportFormats) {
+		return nil, fmt.Errorf("unknown import format, pass -format with one of: %s", strings.Join(models.ImportFormats, ", "))
+	}
+	records, err := importer.Parse(format, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid import file: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("import file has no rows")
+	}
+	return handlers.ImportRecords(records, upsert, dryRun, b.audit), nil
+}
+
+func (b *directBackend) ExportBooks(ctx context.Context, w io.Writer, format string, filter models.BookFilter) error {
+	writer, err := exporter.New(format, w)
+	if err != nil {
+		return err
+	}
+	err = b.books.StreamBooks(filter, func(books []*models.Book) error {
+		for _, book := range books {
+			if err := writer.Write(book); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// PurgeTrash also removes the covers of the purged books that no other
+// book shows, from the blob store of STORAGE_*
+func (b *directBackend) PurgeTrash(ctx context.Context, olderThan time.Duration) (int, int, error) {
+	store, err := storage.New(storage.GetConfig())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to initialize blob store: %w", err)
+	}
+
+	purged, kept, err := b.books.PurgeDeletedBooks(time.Now().Add(-olderThan))
+	for _, book := range purged {
+		if book.CoverHash == "" {
+			continue
+		}
+		inUse, coverErr := b.books.CoverInUse(book.CoverHash)
+		if coverErr == nil && !inUse {
+			coverErr = covers.Remove(ctx, store, book.CoverHash, book.CoverFormat)
+		}
+		if coverErr != nil {
+			fmt.Fprintf(os.Stderr, "bookctl: failed to remove cover %s: %v\n", book.CoverHash, coverErr)
+		}
+	}
+	return len(purged), kept, err
+}
+
+// Migrate creates missing tables and columns and links the authors of
+// books saved before authors were separate records, like server startup
+func (b *directBackend) Migrate(ctx context.Context) error {
+	if err := database.CreateTables(); err != nil {
+		return err
+	}
+	migrated, err := repositories.NewAuthorRepository(database.DB).MigrateLegacyAuthors()
+	if err != nil {
+		return fmt.Errorf("failed to migrate book authors: %w", err)
+	}
+	if migrated > 0 {
+		fmt.Fprintf(os.Stderr, "Linked authors for %d existing books\n", migrated)
+	}
+	return nil
+}
+
+// Seed inserts the default users and loan policy into an empty database,
+// then creates the users of the config file that do not exist yet and
+// returns how many were created
+func (b *directBackend) Seed(ctx context.Context, configPath string) (int, error) {
+	if err := database.SeedData(); err != nil {
+		return 0, err
+	}
+	if configPath == "" {
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return 0, err
+	}
+	var config models.Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+
+	created := 0
+	for _, u := range config.Users {
+		exists, err := b.users.UsernameExists(u.Username)
+		if err != nil {
+			return created, err
+		}
+		if exists {
+			continue
+		}
+		req := &models.CreateUserRequest{Username: u.Username, Password: u.Password, Email: u.Email}
+		if _, err := b.CreateUser(ctx, req); err != nil {
+			return created, fmt.Errorf("failed to create user %s: %w", u.Username, err)
+		}
+		created++
+	}
+	return created, nil
+}
+
+func (b *directBackend) Close() error {
+	return database.CloseDatabase()
+}
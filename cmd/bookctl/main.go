@@ -0,0 +1,132 @@
+// Command bookctl administers a Book API installation from the command
+// line: users, sessions, book imports and exports, the trash, migrations
+// and seed data.
+//
+// By default it works directly on the database of the DB_* environment
+// variables, like the server. With -remote (or BOOKCTL_URL) it goes
+// through the HTTP API of a running server instead, signed in as an admin
+// with -username and -password or -token; migrate and seed need the
+// database and are not available remotely.
+//
+// Usage:
+//
+//	bookctl [-remote URL] [-o table|json] <command> <subcommand> [flags] [args]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const usage = `Usage: bookctl [flags] <command> [subcommand] [flags] [args]
+
+Commands:
+  user list
+  user create [-role ROLE] [-email EMAIL] [-password PASSWORD] USERNAME
+  user disable USERNAME
+  user reset-password [-password PASSWORD] USERNAME
+  token revoke USERNAME | -token TOKEN
+  token cleanup
+  book import [-format FORMAT] [-upsert] [-dry-run] FILE|-
+  book export [-format FORMAT] [-out FILE] [filters]
+  book purge-trash [-older-than DURATION]
+  migrate
+  seed [-config FILE]
+
+Passwords that are not given with -password are read from standard input.
+
+Flags:
+`
+
+func main() {
+	remote := flag.String("remote", os.Getenv("BOOKCTL_URL"), "base `URL` of a server to go through instead of the database ($BOOKCTL_URL)")
+	username := flag.String("username", os.Getenv("BOOKCTL_USERNAME"), "admin to sign in as with -remote ($BOOKCTL_USERNAME)")
+	password := flag.String("password", os.Getenv("BOOKCTL_PASSWORD"), "password of -username ($BOOKCTL_PASSWORD)")
+	token := flag.String("token", os.Getenv("BOOKCTL_TOKEN"), "session token to use with -remote instead of signing in ($BOOKCTL_TOKEN)")
+	format := flag.String("o", outputTable, "output `format`: table or json")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *format != outputTable && *format != outputJSON {
+		fatalUsage("-o must be table or json")
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cli := &cli{
+		ctx: ctx,
+		out: &output{w: os.Stdout, json: *format == outputJSON},
+		open: func() (backend, error) {
+			if *remote != "" {
+				return newRemoteBackend(ctx, *remote, *username, *password, *token)
+			}
+			return newDirectBackend()
+		},
+		openDirect: func() (*directBackend, error) {
+			if *remote != "" {
+				return nil, errors.New("this command needs the database and cannot be used with -remote")
+			}
+			return newDirectBackend()
+		},
+	}
+	if err := cli.run(flag.Args()); err != nil {
+		if err == errHelp {
+			return
+		}
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fatalUsage(string(usageErr))
+		}
+		fmt.Fprintf(os.Stderr, "bookctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// errHelp ends a run after the flags of a subcommand were printed for -h
+var errHelp = errors.New("help requested")
+
+// usageError is a command line mistake, reported with exit status 2
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func fatalUsage(msg string) {
+	fmt.Fprintf(os.Stderr, "bookctl: %s\nRun bookctl -h for usage.\n", msg)
+	os.Exit(2)
+}
+
+// isOneOf reports whether v is one of values
+func isOneOf(v string, values []string) bool {
+	for _, value := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
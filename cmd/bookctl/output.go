@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"rest-api-golang/models"
+)
+
+// Output formats of -o
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// output prints command results as aligned tables for people or as JSON
+// for scripts
+type output struct {
+	w    io.Writer
+	json bool
+}
+
+// table prints v as JSON, or header and rows as a table
+func (o *output) table(v interface{}, header []string, rows [][]string) error {
+	if o.json {
+		return o.encode(v)
+	}
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// message prints v as JSON, or the sentence text
+func (o *output) message(v interface{}, text string) error {
+	if o.json {
+		return o.encode(v)
+	}
+	_, err := fmt.Fprintln(o.w, text)
+	return err
+}
+
+func (o *output) encode(v interface{}) error {
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (o *output) users(v interface{}, users []*models.User) error {
+	rows := make([][]string, 0, len(users))
+	for _, u := range users {
+		rows = append(rows, []string{
+			u.ID, u.Username, u.Email, u.Role, fmt.Sprint(u.IsActive), u.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return o.table(v, []string{"ID", "USERNAME", "EMAIL", "ROLE", "ACTIVE", "CREATED"}, rows)
+}
+
+func (o *output) importReport(report *models.ImportReport, dryRun bool) error {
+	if o.json {
+		return o.encode(report)
+	}
+	verb := "Imported"
+	if dryRun {
+		verb = "Dry run:"
+	}
+	fmt.Fprintf(o.w, "%s %d rows: %d created, %d updated, %d failed\n", verb, report.Total, report.Created, report.Updated, report.Failed)
+	if len(report.Errors) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(o.w)
+	rows := make([][]string, 0, len(report.Errors))
+	for _, e := range report.Errors {
+		rows = append(rows, []string{fmt.Sprint(e.Row), e.ISBN, e.Message})
+	}
+	if err := o.table(nil, []string{"ROW", "ISBN", "ERROR"}, rows); err != nil {
+		return err
+	}
+	if report.ErrorsTruncated {
+		fmt.Fprintf(o.w, "Only the first %d errors are shown\n", models.MaxImportErrors)
+	}
+	return nil
+}
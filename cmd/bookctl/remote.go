@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"rest-api-golang/client"
+	"rest-api-golang/importer"
+	"rest-api-golang/models"
+)
+
+// importPollInterval is how often the job of a background import is polled
+const importPollInterval = time.Second
+
+// remoteBackend goes through the HTTP API of a server, signed in as an
+// admin. Changes are audited as made by that admin.
+type remoteBackend struct {
+	baseURL string
+	c       *client.Client
+	// loggedIn is set when the session is ours to end on Close
+	loggedIn bool
+}
+
+// newRemoteBackend signs in with username and password, or uses token if
+// there is no username
+func newRemoteBackend(ctx context.Context, baseURL, username, password, token string) (*remoteBackend, error) {
+	b := &remoteBackend{baseURL: baseURL, c: client.New(baseURL, nil)}
+	switch {
+	case username != "":
+		if err := b.c.Login(ctx, username, password); err != nil {
+			return nil, fmt.Errorf("failed to sign in: %w", err)
+		}
+		b.loggedIn = true
+	case token != "":
+		b.c.SetToken(token)
+	default:
+		return nil, errors.New("-remote needs -username and -password, or -token")
+	}
+	return b, nil
+}
+
+func (b *remoteBackend) ListUsers(ctx context.Context) ([]*models.User, error) {
+	return b.c.ListUsers(ctx)
+}
+
+func (b *remoteBackend) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+	return b.c.CreateUser(ctx, req)
+}
+
+func (b *remoteBackend) DisableUser(ctx context.Context, id string) error {
+	return b.c.DisableUser(ctx, id)
+}
+
+func (b *remoteBackend) ResetPassword(ctx context.Context, id, password string) error {
+	return b.c.ResetUserPassword(ctx, id, password)
+}
+
+func (b *remoteBackend) RevokeUserTokens(ctx context.Context, userID string) (int, error) {
+	return b.c.RevokeUserTokens(ctx, userID)
+}
+
+// RevokeToken signs out the session of token itself, since only its
+// owner can end a single session through the API
+func (b *remoteBackend) RevokeToken(ctx context.Context, token string) error {
+	c := client.New(b.baseURL, nil)
+	c.SetToken(token)
+	return c.Logout(ctx)
+}
+
+func (b *remoteBackend) CleanupTokens(ctx context.Context) (int64, error) {
+	return b.c.CleanupTokens(ctx)
+}
+
+// ImportBooks waits for the job when the server imports the file in the
+// background
+func (b *remoteBackend) ImportBooks(ctx context.Context, fileName string, data []byte, format string, upsert, dryRun bool) (*models.ImportReport, error) {
+	if format == "" {
+		format = importer.DetectFormat(fileName, "", data)
+	}
+	job, err := b.c.ImportBooks(ctx, data, &client.ImportOptions{Format: format, Upsert: upsert, DryRun: dryRun})
+	if err != nil {
+		return nil, err
+	}
+
+	for job.ID != "" && job.Status != models.ImportStatusCompleted {
+		if job.Status == models.ImportStatusFailed {
+			return &job.ImportReport, fmt.Errorf("import job %s failed: %s", job.ID, job.Message)
+		}
+		fmt.Fprintf(os.Stderr, "Import job %s %s: %d of %d rows\n", job.ID, job.Status, job.Processed, job.Total)
+		select {
+		case <-time.After(importPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if job, err = b.c.GetImportJob(ctx, job.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &job.ImportReport, nil
+}
+
+func (b *remoteBackend) ExportBooks(ctx context.Context, w io.Writer, format string, filter models.BookFilter) error {
+	return b.c.ExportBooks(ctx, w, format, &client.ListBooksOptions{
+		ISBN:         filter.ISBN,
+		Publisher:    filter.Publisher,
+		Language:     filter.Language,
+		Format:       filter.Format,
+		AuthorID:     filter.AuthorID,
+		Category:     filter.Category,
+		Tags:         filter.Tags,
+		MatchAllTags: filter.MatchAllTags,
+		MinRating:    filter.MinRating,
+		MinPages:     filter.MinPages,
+		MaxPages:     filter.MaxPages,
+		Sort:         filter.Sort,
+	})
+}
+
+func (b *remoteBackend) PurgeTrash(ctx context.Context, olderThan time.Duration) (int, int, error) {
+	return b.c.PurgeTrash(ctx, olderThan)
+}
+
+// Close signs out the session of -username, leaving a -token session
+// alone
+func (b *remoteBackend) Close() error {
+	if !b.loggedIn {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := b.c.Logout(ctx)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+// Package covers validates uploaded cover images, generates their
+// thumbnails and keeps them in a storage.BlobStore. Blobs are keyed by the
+// SHA-256 of the uploaded file, so identical uploads share storage and a
+// cover's URLs never change content.
+package covers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"regexp"
+
+	"rest-api-golang/models"
+	"rest-api-golang/storage"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels bounds the decoded size of an upload; a small file can still
+// declare huge dimensions
+const MaxPixels = 40_000_000
+
+// thumbnailQuality is the JPEG quality of generated thumbnails
+const thumbnailQuality = 85
+
+var (
+	// ErrUnsupported is returned for files that are not JPEG, PNG or WebP
+	ErrUnsupported = errors.New("unsupported image type, expected JPEG, PNG or WebP")
+	// ErrInvalid is returned for files that sniff as an image but do not decode
+	ErrInvalid = errors.New("image could not be decoded")
+	// ErrTooLarge is returned for images with more than MaxPixels pixels
+	ErrTooLarge = fmt.Errorf("image is larger than %d megapixels", MaxPixels/1_000_000)
+)
+
+// formats maps sniffed content types to the stored format
+var formats = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/webp": "webp",
+}
+
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Cover is a processed upload ready to be stored
+type Cover struct {
+	Hash        string
+	Format      string
+	ContentType string
+	Original    []byte
+	// Thumbnails holds the JPEG thumbnail for each models.CoverSizes name
+	Thumbnails map[string][]byte
+}
+
+// Process sniffs, decodes and hashes an upload and renders its thumbnails.
+// The content type is taken from the bytes, never from the client.
+func Process(data []byte) (*Cover, error) {
+	contentType := http.DetectContentType(data)
+	format, ok := formats[contentType]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrInvalid
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	sum := sha256.Sum256(data)
+	cover := &Cover{
+		Hash:        hex.EncodeToString(sum[:]),
+		Format:      format,
+		ContentType: contentType,
+		Original:    data,
+		Thumbnails:  make(map[string][]byte, len(models.CoverSizes)),
+	}
+	for _, size := range models.CoverSizes {
+		thumb, err := thumbnail(img, size.Width)
+		if err != nil {
+			return nil, err
+		}
+		cover.Thumbnails[size.Name] = thumb
+	}
+
+	return cover, nil
+}
+
+// thumbnail scales img down to width, keeping its aspect ratio, and
+// encodes it as JPEG. Smaller images are not enlarged. Transparent areas
+// are flattened onto white since JPEG has no alpha channel.
+func thumbnail(img image.Image, width int) ([]byte, error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > width {
+		h = max(1, h*width/w)
+		w = width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Key is the blob key of a cover file
+func Key(hash, name string) string {
+	return "covers/" + hash + "/" + name
+}
+
+// names lists the files stored for a cover
+func names(format string) []string {
+	list := []string{models.CoverOriginalName(format)}
+	for _, size := range models.CoverSizes {
+		list = append(list, models.CoverThumbnailName(size.Name))
+	}
+	return list
+}
+
+// Save stores the original and the thumbnails. The original is written
+// last, so a cover whose original exists is complete.
+func Save(ctx context.Context, store storage.BlobStore, cover *Cover) error {
+	for _, size := range models.CoverSizes {
+		if err := store.Put(ctx, Key(cover.Hash, models.CoverThumbnailName(size.Name)), cover.Thumbnails[size.Name], "image/jpeg"); err != nil {
+			return fmt.Errorf("failed to store %s thumbnail: %w", size.Name, err)
+		}
+	}
+	if err := store.Put(ctx, Key(cover.Hash, models.CoverOriginalName(cover.Format)), cover.Original, cover.ContentType); err != nil {
+		return fmt.Errorf("failed to store cover: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes every file of the cover stored under hash
+func Remove(ctx context.Context, store storage.BlobStore, hash, format string) error {
+	for _, name := range names(format) {
+		if err := store.Delete(ctx, Key(hash, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidName reports whether hash and name identify a file Save could have
+// written, so requests for anything else never reach the store
+func ValidName(hash, name string) bool {
+	if !hashPattern.MatchString(hash) {
+		return false
+	}
+	for _, format := range formats {
+		for _, n := range names(format) {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
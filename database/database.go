@@ -33,15 +33,18 @@ func GetDatabaseConfig() *DatabaseConfig {
 	}
 }
 
+// DSN returns the connection string for the configured database
+func (c *DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode)
+}
+
 // ConnectDatabase establishes connection to PostgreSQL database
 func ConnectDatabase() error {
 	config := GetDatabaseConfig()
 	
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		config.Host, config.Port, config.User, config.Password, config.DBName, config.SSLMode)
-	
 	var err error
-	DB, err = sql.Open("postgres", dsn)
+	DB, err = sql.Open("postgres", config.DSN())
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
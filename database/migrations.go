@@ -3,6 +3,8 @@ package database
 import (
 	"fmt"
 	"log"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // CreateTables creates all necessary tables
@@ -44,14 +46,477 @@ func CreateTables() error {
 		is_revoked BOOLEAN DEFAULT false
 	);`
 
+	// Create live_tickets table for single-use tickets that open the live
+	// feed of a session; only SHA-256 hashes are stored
+	liveTicketsTable := `
+	CREATE TABLE IF NOT EXISTS live_tickets (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		session_id UUID NOT NULL REFERENCES tokens(id) ON DELETE CASCADE,
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create user_identities table linking external (OIDC) accounts to users
+	userIdentitiesTable := `
+	CREATE TABLE IF NOT EXISTS user_identities (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		issuer VARCHAR(255) NOT NULL,
+		subject VARCHAR(255) NOT NULL,
+		email VARCHAR(255),
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		last_login TIMESTAMP WITH TIME ZONE NULL,
+		UNIQUE (issuer, subject)
+	);`
+
+	// Create oidc_login_states table holding state, nonce and PKCE verifier
+	// between the authorization redirect and the callback
+	oidcLoginStatesTable := `
+	CREATE TABLE IF NOT EXISTS oidc_login_states (
+		state VARCHAR(255) PRIMARY KEY,
+		nonce VARCHAR(255) NOT NULL,
+		code_verifier VARCHAR(255) NOT NULL,
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create user_mfa table holding each user's TOTP secret
+	userMFATable := `
+	CREATE TABLE IF NOT EXISTS user_mfa (
+		user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		secret VARCHAR(64) NOT NULL,
+		enabled BOOLEAN DEFAULT false,
+		last_used_step BIGINT DEFAULT 0,
+		confirmed_at TIMESTAMP WITH TIME ZONE NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create mfa_recovery_codes table; only SHA-256 hashes are stored
+	mfaRecoveryCodesTable := `
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash VARCHAR(64) NOT NULL,
+		used_at TIMESTAMP WITH TIME ZONE NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create mfa_challenges table for the second step of the login
+	mfaChallengesTable := `
+	CREATE TABLE IF NOT EXISTS mfa_challenges (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		attempts INTEGER DEFAULT 0,
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create app_settings table for runtime settings managed by admins
+	appSettingsTable := `
+	CREATE TABLE IF NOT EXISTS app_settings (
+		key VARCHAR(100) PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create account_tokens table for single-use email links (password
+	// reset, email verification); only SHA-256 hashes are stored
+	accountTokensTable := `
+	CREATE TABLE IF NOT EXISTS account_tokens (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		purpose VARCHAR(32) NOT NULL,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		email VARCHAR(255) NOT NULL,
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		used_at TIMESTAMP WITH TIME ZONE NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create authors table; normalized_name de-duplicates spelling variants
+	authorsTable := `
+	CREATE TABLE IF NOT EXISTS authors (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name VARCHAR(255) NOT NULL,
+		normalized_name VARCHAR(255) UNIQUE NOT NULL,
+		bio TEXT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create book_authors join table with role and display order
+	bookAuthorsTable := `
+	CREATE TABLE IF NOT EXISTS book_authors (
+		book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		author_id UUID NOT NULL REFERENCES authors(id) ON DELETE RESTRICT,
+		role VARCHAR(20) NOT NULL DEFAULT 'author' CHECK (role IN ('author', 'editor', 'translator')),
+		position INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (book_id, author_id, role)
+	);`
+
+	// Create categories table; path is the materialized slug path from the
+	// root ("fiction/fantasy") so a subtree is one prefix match
+	categoriesTable := `
+	CREATE TABLE IF NOT EXISTS categories (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name VARCHAR(100) NOT NULL,
+		slug VARCHAR(100) UNIQUE NOT NULL,
+		parent_id UUID NULL REFERENCES categories(id) ON DELETE RESTRICT,
+		path TEXT UNIQUE NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create book_categories join table
+	bookCategoriesTable := `
+	CREATE TABLE IF NOT EXISTS book_categories (
+		book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		category_id UUID NOT NULL REFERENCES categories(id) ON DELETE RESTRICT,
+		PRIMARY KEY (book_id, category_id)
+	);`
+
+	// Create tags table; names are stored normalized (lower case)
+	tagsTable := `
+	CREATE TABLE IF NOT EXISTS tags (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name VARCHAR(50) UNIQUE NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create book_tags join table
+	bookTagsTable := `
+	CREATE TABLE IF NOT EXISTS book_tags (
+		book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (book_id, tag_id)
+	);`
+
+	// Create copies table; each row is a physical item of a book
+	copiesTable := `
+	CREATE TABLE IF NOT EXISTS copies (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		barcode VARCHAR(64) UNIQUE NOT NULL,
+		condition VARCHAR(20) NOT NULL DEFAULT 'good' CHECK (condition IN ('new', 'good', 'fair', 'poor', 'damaged')),
+		location VARCHAR(100) NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'available'
+			CHECK (status IN ('available', 'on_loan', 'on_hold', 'maintenance', 'lost', 'withdrawn')),
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create loan_policies table; the "default" row applies to other roles
+	loanPoliciesTable := `
+	CREATE TABLE IF NOT EXISTS loan_policies (
+		role VARCHAR(20) PRIMARY KEY,
+		loan_days INTEGER NOT NULL CHECK (loan_days > 0),
+		renewal_days INTEGER NOT NULL CHECK (renewal_days > 0),
+		max_renewals INTEGER NOT NULL DEFAULT 0 CHECK (max_renewals >= 0),
+		max_loans INTEGER NOT NULL CHECK (max_loans > 0),
+		hold_pickup_days INTEGER NOT NULL DEFAULT 3 CHECK (hold_pickup_days > 0),
+		fine_daily_rate BIGINT NOT NULL DEFAULT 0 CHECK (fine_daily_rate >= 0),
+		fine_grace_days INTEGER NOT NULL DEFAULT 0 CHECK (fine_grace_days >= 0),
+		fine_cap BIGINT NOT NULL DEFAULT 0 CHECK (fine_cap >= 0),
+		max_balance BIGINT NOT NULL DEFAULT 0 CHECK (max_balance >= 0),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create loans table
+	loansTable := `
+	CREATE TABLE IF NOT EXISTS loans (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		copy_id UUID NOT NULL REFERENCES copies(id) ON DELETE RESTRICT,
+		book_id UUID NOT NULL REFERENCES books(id) ON DELETE RESTRICT,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+		checked_out_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		returned_at TIMESTAMP WITH TIME ZONE NULL,
+		renewal_count INTEGER NOT NULL DEFAULT 0,
+		checked_out_by UUID NULL REFERENCES users(id) ON DELETE SET NULL
+	);`
+
+	// Create holds table; the queue of a book is its waiting holds in
+	// created_at order
+	holdsTable := `
+	CREATE TABLE IF NOT EXISTS holds (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		status VARCHAR(20) NOT NULL DEFAULT 'waiting'
+			CHECK (status IN ('waiting', 'ready', 'fulfilled', 'cancelled', 'expired')),
+		copy_id UUID NULL REFERENCES copies(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		ready_at TIMESTAMP WITH TIME ZONE NULL,
+		expires_at TIMESTAMP WITH TIME ZONE NULL,
+		closed_at TIMESTAMP WITH TIME ZONE NULL
+	);`
+
+	// Create account_entries table: the append-only ledger of member fines,
+	// payments and waivers in minor currency units. At most one overdue
+	// charge is recorded per loan.
+	accountEntriesTable := `
+	CREATE TABLE IF NOT EXISTS account_entries (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+		loan_id UUID NULL REFERENCES loans(id) ON DELETE RESTRICT,
+		type VARCHAR(20) NOT NULL CHECK (type IN ('charge', 'payment', 'waiver')),
+		amount BIGINT NOT NULL CHECK ((type = 'charge') = (amount > 0) AND amount <> 0),
+		note VARCHAR(255) NULL,
+		created_by UUID NULL REFERENCES users(id) ON DELETE RESTRICT,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create reviews table; one review per member per book
+	reviewsTable := `
+	CREATE TABLE IF NOT EXISTS reviews (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+		title VARCHAR(200) NULL,
+		body TEXT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'approved' CHECK (status IN ('pending', 'approved', 'hidden')),
+		moderated_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+		moderated_at TIMESTAMP WITH TIME ZONE NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (book_id, user_id)
+	);`
+
+	// Create reading_lists and reading_list_items tables. Items of
+	// soft-deleted books are kept and hidden; hard-deleting a book removes
+	// them.
+	readingListsTable := `
+	CREATE TABLE IF NOT EXISTS reading_lists (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		description VARCHAR(500) NULL,
+		visibility VARCHAR(20) NOT NULL DEFAULT 'private' CHECK (visibility IN ('private', 'shared', 'public')),
+		share_token VARCHAR(64) NULL UNIQUE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	readingListItemsTable := `
+	CREATE TABLE IF NOT EXISTS reading_list_items (
+		list_id UUID NOT NULL REFERENCES reading_lists(id) ON DELETE CASCADE,
+		book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		note VARCHAR(1000) NULL,
+		added_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (list_id, book_id)
+	);`
+
+	importJobsTable := `
+	CREATE TABLE IF NOT EXISTS import_jobs (
+		id UUID PRIMARY KEY,
+		user_id UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+		status VARCHAR(20) NOT NULL CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+		format VARCHAR(20) NOT NULL,
+		file_name VARCHAR(255) NULL,
+		dry_run BOOLEAN NOT NULL DEFAULT false,
+		upsert BOOLEAN NOT NULL DEFAULT false,
+		total INTEGER NOT NULL DEFAULT 0,
+		processed INTEGER NOT NULL DEFAULT 0,
+		created INTEGER NOT NULL DEFAULT 0,
+		updated INTEGER NOT NULL DEFAULT 0,
+		failed INTEGER NOT NULL DEFAULT 0,
+		errors JSONB NOT NULL DEFAULT '[]',
+		errors_truncated BOOLEAN NOT NULL DEFAULT false,
+		message TEXT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		started_at TIMESTAMP WITH TIME ZONE NULL,
+		finished_at TIMESTAMP WITH TIME ZONE NULL
+	);`
+
+	// Create audit_events table: the append-only log of every change and
+	// authentication event. actor_id has no foreign key so events outlive
+	// the users who caused them.
+	auditEventsTable := `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id UUID PRIMARY KEY,
+		actor_id UUID NULL,
+		action VARCHAR(32) NOT NULL,
+		entity_type VARCHAR(32) NOT NULL,
+		entity_id VARCHAR(255) NULL,
+		before JSONB NULL,
+		after JSONB NULL,
+		request_id VARCHAR(128) NULL,
+		ip VARCHAR(45) NULL,
+		user_agent VARCHAR(512) NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create book_revisions table: a snapshot of the editable fields of a
+	// book after every change, numbered per book. changed_by has no foreign
+	// key for the same reason as audit_events.actor_id.
+	bookRevisionsTable := `
+	CREATE TABLE IF NOT EXISTS book_revisions (
+		book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		revision INTEGER NOT NULL,
+		snapshot JSONB NOT NULL,
+		changed_by UUID NULL,
+		reverted_from INTEGER NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (book_id, revision)
+	);`
+
+	// Create webhook_subscriptions table: endpoints that receive signed
+	// event deliveries. events holds the event types, "book.*"-style
+	// prefixes or "*" a subscription wants.
+	webhookSubscriptionsTable := `
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		url VARCHAR(2048) NOT NULL,
+		events TEXT[] NOT NULL,
+		description VARCHAR(500) NULL,
+		secret VARCHAR(255) NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT true,
+		consecutive_failures INTEGER NOT NULL DEFAULT 0,
+		disabled_at TIMESTAMP WITH TIME ZONE NULL,
+		disabled_reason VARCHAR(255) NULL,
+		created_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create webhook_deliveries table: the durable delivery queue and log.
+	// Rows are queued in the transaction of the change they report.
+	webhookDeliveriesTable := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		event_id UUID NOT NULL,
+		event_type VARCHAR(64) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'delivered', 'failed')),
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP WITH TIME ZONE NULL,
+		last_attempt_at TIMESTAMP WITH TIME ZONE NULL,
+		last_status_code INTEGER NULL,
+		last_error TEXT NULL,
+		delivered_at TIMESTAMP WITH TIME ZONE NULL,
+		replay_of UUID NULL REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Create outbox table: change events written in the transaction of the
+	// change and published by the relay. id orders events; event_id is the
+	// deduplication key consumers see.
+	outboxTable := `
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		event_id UUID NOT NULL UNIQUE,
+		aggregate_type VARCHAR(32) NOT NULL,
+		aggregate_id VARCHAR(255) NOT NULL,
+		event_type VARCHAR(64) NOT NULL,
+		payload JSONB NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		published_at TIMESTAMP WITH TIME ZONE NULL
+	);`
+
+	// Add columns introduced after the initial schema
+	columns := []string{
+		"ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE NULL;",
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn VARCHAR(13) NULL;",
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS publisher VARCHAR(255) NULL;",
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS language VARCHAR(35) NULL;",
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS pages INTEGER NULL CHECK (pages > 0);",
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS description TEXT NULL;",
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS edition VARCHAR(100) NULL;",
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS format VARCHAR(20) NULL;",
+		// Denormalized from approved reviews
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS average_rating NUMERIC(3, 2) NOT NULL DEFAULT 0;",
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS rating_count INTEGER NOT NULL DEFAULT 0;",
+		// Content hash and image format of the uploaded cover
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS cover_hash CHAR(64) NULL;",
+		"ALTER TABLE books ADD COLUMN IF NOT EXISTS cover_format VARCHAR(10) NULL;",
+		"ALTER TABLE loan_policies ADD COLUMN IF NOT EXISTS hold_pickup_days INTEGER NOT NULL DEFAULT 3 CHECK (hold_pickup_days > 0);",
+		"ALTER TABLE loan_policies ADD COLUMN IF NOT EXISTS fine_daily_rate BIGINT NOT NULL DEFAULT 0 CHECK (fine_daily_rate >= 0);",
+		"ALTER TABLE loan_policies ADD COLUMN IF NOT EXISTS fine_grace_days INTEGER NOT NULL DEFAULT 0 CHECK (fine_grace_days >= 0);",
+		"ALTER TABLE loan_policies ADD COLUMN IF NOT EXISTS fine_cap BIGINT NOT NULL DEFAULT 0 CHECK (fine_cap >= 0);",
+		"ALTER TABLE loan_policies ADD COLUMN IF NOT EXISTS max_balance BIGINT NOT NULL DEFAULT 0 CHECK (max_balance >= 0);",
+		// The transaction that wrote each outbox event and the transactions
+		// still running then, so a resuming reader finds events that
+		// committed late with a lower id. Older events have neither.
+		"ALTER TABLE outbox ADD COLUMN IF NOT EXISTS txid XID8 NULL;",
+		"ALTER TABLE outbox ALTER COLUMN txid SET DEFAULT pg_current_xact_id();",
+		"ALTER TABLE outbox ADD COLUMN IF NOT EXISTS snapshot PG_SNAPSHOT NULL;",
+		"ALTER TABLE outbox ALTER COLUMN snapshot SET DEFAULT pg_current_snapshot();",
+		// Copies created before holds existed lack the on_hold status. The
+		// check is only replaced while it lacks it, since replacing it locks
+		// and rescans the table.
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM pg_constraint
+				WHERE conrelid = 'copies'::regclass AND conname = 'copies_status_check'
+					AND pg_get_constraintdef(oid) LIKE '%on_hold%'
+			) THEN
+				ALTER TABLE copies DROP CONSTRAINT IF EXISTS copies_status_check;
+				ALTER TABLE copies ADD CONSTRAINT copies_status_check CHECK (status IN ('available', 'on_loan', 'on_hold', 'maintenance', 'lost', 'withdrawn'));
+			END IF;
+		END $$;`,
+	}
+
 	// Create indexes for better performance
 	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at DESC, id DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor_id, created_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_audit_events_entity ON audit_events(entity_type, entity_id, created_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_book_revisions_created_at ON book_revisions(book_id, created_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox(id) WHERE published_at IS NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_outbox_published_at ON outbox(published_at) WHERE published_at IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_outbox_txid ON outbox(txid);",
 		"CREATE INDEX IF NOT EXISTS idx_books_author ON books(author);",
 		"CREATE INDEX IF NOT EXISTS idx_books_tahun_terbit ON books(tahun_terbit);",
 		"CREATE INDEX IF NOT EXISTS idx_books_created_at ON books(created_at);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_books_isbn_active ON books(isbn) WHERE deleted_at IS NULL AND isbn IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_books_language ON books(language);",
+		"CREATE INDEX IF NOT EXISTS idx_books_publisher ON books(publisher);",
+		"CREATE INDEX IF NOT EXISTS idx_books_average_rating ON books(average_rating);",
+		"CREATE INDEX IF NOT EXISTS idx_books_rating_count ON books(rating_count);",
+		"CREATE INDEX IF NOT EXISTS idx_books_cover_hash ON books(cover_hash) WHERE cover_hash IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_reviews_book_status ON reviews(book_id, status);",
+		"CREATE INDEX IF NOT EXISTS idx_reviews_status ON reviews(status, created_at);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_reading_lists_user_name ON reading_lists(user_id, LOWER(name));",
+		"CREATE INDEX IF NOT EXISTS idx_reading_list_items_order ON reading_list_items(list_id, position);",
+		"CREATE INDEX IF NOT EXISTS idx_reading_list_items_book_id ON reading_list_items(book_id);",
+		"CREATE INDEX IF NOT EXISTS idx_book_authors_author_id ON book_authors(author_id);",
+		"CREATE INDEX IF NOT EXISTS idx_authors_name ON authors(LOWER(name));",
+		"CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);",
+		"CREATE INDEX IF NOT EXISTS idx_categories_path ON categories(path text_pattern_ops);",
+		"CREATE INDEX IF NOT EXISTS idx_book_categories_category_id ON book_categories(category_id);",
+		"CREATE INDEX IF NOT EXISTS idx_tags_name_pattern ON tags(name text_pattern_ops);",
+		"CREATE INDEX IF NOT EXISTS idx_book_tags_tag_id ON book_tags(tag_id);",
+		"CREATE INDEX IF NOT EXISTS idx_copies_book_id ON copies(book_id);",
+		// At most one open loan per copy, even under concurrent checkouts
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_loans_open_copy ON loans(copy_id) WHERE returned_at IS NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_loans_user_id ON loans(user_id);",
+		"CREATE INDEX IF NOT EXISTS idx_loans_book_id ON loans(book_id);",
+		"CREATE INDEX IF NOT EXISTS idx_loans_due_at ON loans(due_at) WHERE returned_at IS NULL;",
+		// A member has at most one open hold per book
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_holds_open_user_book ON holds(book_id, user_id) WHERE status IN ('waiting', 'ready');",
+		"CREATE INDEX IF NOT EXISTS idx_holds_queue ON holds(book_id, created_at) WHERE status = 'waiting';",
+		"CREATE INDEX IF NOT EXISTS idx_holds_expires_at ON holds(expires_at) WHERE status = 'ready';",
+		"CREATE INDEX IF NOT EXISTS idx_account_entries_user ON account_entries(user_id, created_at);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_account_entries_loan_charge ON account_entries(loan_id) WHERE type = 'charge';",
 		"CREATE INDEX IF NOT EXISTS idx_tokens_token ON tokens(token);",
 		"CREATE INDEX IF NOT EXISTS idx_tokens_user_id ON tokens(user_id);",
 		"CREATE INDEX IF NOT EXISTS idx_tokens_expires_at ON tokens(expires_at);",
+		"CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);",
+		"CREATE INDEX IF NOT EXISTS idx_oidc_login_states_expires_at ON oidc_login_states(expires_at);",
+		"CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);",
+		"CREATE INDEX IF NOT EXISTS idx_mfa_challenges_expires_at ON mfa_challenges(expires_at);",
+		"CREATE INDEX IF NOT EXISTS idx_account_tokens_user_purpose ON account_tokens(user_id, purpose);",
 	}
 
 	// Create trigger to update updated_at column
@@ -75,9 +540,94 @@ func CreateTables() error {
 		BEFORE UPDATE ON users
 		FOR EACH ROW
 		EXECUTE FUNCTION update_updated_at_column();
+
+	DROP TRIGGER IF EXISTS update_authors_updated_at ON authors;
+	CREATE TRIGGER update_authors_updated_at
+		BEFORE UPDATE ON authors
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	DROP TRIGGER IF EXISTS update_categories_updated_at ON categories;
+	CREATE TRIGGER update_categories_updated_at
+		BEFORE UPDATE ON categories
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	DROP TRIGGER IF EXISTS update_copies_updated_at ON copies;
+	CREATE TRIGGER update_copies_updated_at
+		BEFORE UPDATE ON copies
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	DROP TRIGGER IF EXISTS update_reviews_updated_at ON reviews;
+	CREATE TRIGGER update_reviews_updated_at
+		BEFORE UPDATE ON reviews
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	DROP TRIGGER IF EXISTS update_reading_lists_updated_at ON reading_lists;
+	CREATE TRIGGER update_reading_lists_updated_at
+		BEFORE UPDATE ON reading_lists
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	DROP TRIGGER IF EXISTS update_user_mfa_updated_at ON user_mfa;
+	CREATE TRIGGER update_user_mfa_updated_at
+		BEFORE UPDATE ON user_mfa
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	CREATE OR REPLACE FUNCTION reject_ledger_change()
+	RETURNS TRIGGER AS $$
+	BEGIN
+		RAISE EXCEPTION 'account_entries is append-only; record a payment or waiver instead';
+	END;
+	$$ language 'plpgsql';
+
+	DROP TRIGGER IF EXISTS account_entries_append_only ON account_entries;
+	CREATE TRIGGER account_entries_append_only
+		BEFORE UPDATE OR DELETE ON account_entries
+		FOR EACH ROW
+		EXECUTE FUNCTION reject_ledger_change();
+
+	CREATE OR REPLACE FUNCTION reject_audit_change()
+	RETURNS TRIGGER AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_events is append-only';
+	END;
+	$$ language 'plpgsql';
+
+	DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+	CREATE TRIGGER audit_events_append_only
+		BEFORE UPDATE OR DELETE ON audit_events
+		FOR EACH ROW
+		EXECUTE FUNCTION reject_audit_change();
+
+	CREATE OR REPLACE FUNCTION notify_outbox_event()
+	RETURNS TRIGGER AS $$
+	BEGIN
+		PERFORM pg_notify('outbox_events', NEW.id || ':' || NEW.event_type);
+		RETURN NEW;
+	END;
+	$$ language 'plpgsql';
+
+	DROP TRIGGER IF EXISTS outbox_notify ON outbox;
+	CREATE TRIGGER outbox_notify
+		AFTER INSERT ON outbox
+		FOR EACH ROW
+		EXECUTE FUNCTION notify_outbox_event();
 	`
 
-	tables := []string{booksTable, usersTable, tokensTable}
+	tables := []string{
+		booksTable, usersTable, tokensTable, liveTicketsTable, userIdentitiesTable, oidcLoginStatesTable,
+		userMFATable, mfaRecoveryCodesTable, mfaChallengesTable, appSettingsTable,
+		accountTokensTable, authorsTable, bookAuthorsTable,
+		categoriesTable, bookCategoriesTable, tagsTable, bookTagsTable,
+		copiesTable, loanPoliciesTable, loansTable, holdsTable, accountEntriesTable,
+		reviewsTable, readingListsTable, readingListItemsTable, importJobsTable,
+		auditEventsTable, bookRevisionsTable, webhookSubscriptionsTable, webhookDeliveriesTable,
+		outboxTable,
+	}
 	
 	// Execute table creation
 	for _, table := range tables {
@@ -86,6 +636,13 @@ func CreateTables() error {
 		}
 	}
 
+	// Execute column changes
+	for _, column := range columns {
+		if _, err := DB.Exec(column); err != nil {
+			return fmt.Errorf("failed to add column: %w", err)
+		}
+	}
+
 	// Execute indexes
 	for _, index := range indexes {
 		if _, err := DB.Exec(index); err != nil {
@@ -98,10 +655,55 @@ func CreateTables() error {
 		log.Printf("Warning: failed to create trigger: %v", err)
 	}
 
+	if err := hashPlaintextPasswords(); err != nil {
+		return err
+	}
+
 	log.Println("✅ Database tables created successfully")
 	return nil
 }
 
+// hashPlaintextPasswords replaces passwords stored before they were
+// hashed with bcrypt hashes. bcrypt only reads the first 72 bytes, so
+// longer passwords are hashed from those and still match at login.
+func hashPlaintextPasswords() error {
+	rows, err := DB.Query(`SELECT id, password FROM users WHERE password NOT LIKE '$2_$%'`)
+	if err != nil {
+		return fmt.Errorf("failed to query plaintext passwords: %w", err)
+	}
+	passwords := map[string]string{}
+	for rows.Next() {
+		var id, password string
+		if err := rows.Scan(&id, &password); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan password: %w", err)
+		}
+		passwords[id] = password
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query plaintext passwords: %w", err)
+	}
+
+	for id, password := range passwords {
+		key := []byte(password)
+		if len(key) > 72 {
+			key = key[:72]
+		}
+		hash, err := bcrypt.GenerateFromPassword(key, bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		if _, err := DB.Exec(`UPDATE users SET password = $2 WHERE id = $1 AND password = $3`, id, string(hash), password); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+	}
+	if len(passwords) > 0 {
+		log.Printf("Hashed the plaintext passwords of %d users", len(passwords))
+	}
+	return nil
+}
+
 // SeedData inserts initial data if tables are empty
 func SeedData() error {
 	// Check if users table is empty
@@ -113,17 +715,35 @@ func SeedData() error {
 
 	// Insert default users if table is empty
 	if count == 0 {
+		adminHash, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		userHash, err := bcrypt.GenerateFromPassword([]byte("user123"), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
 		seedUsers := `
 		INSERT INTO users (username, password, email, role) VALUES
-		('admin', 'admin123', 'admin@example.com', 'admin'),
-		('user', 'user123', 'user@example.com', 'user')
+		('admin', $1, 'admin@example.com', 'admin'),
+		('user', $2, 'user@example.com', 'user')
 		ON CONFLICT (username) DO NOTHING;`
 
-		if _, err := DB.Exec(seedUsers); err != nil {
+		if _, err := DB.Exec(seedUsers, string(adminHash), string(userHash)); err != nil {
 			return fmt.Errorf("failed to seed users: %w", err)
 		}
 		log.Println("✅ Default users seeded successfully")
 	}
 
+	// Default loan policy: 14 days, two 14-day renewals, 5 loans at a time
+	seedLoanPolicy := `
+	INSERT INTO loan_policies (role, loan_days, renewal_days, max_renewals, max_loans) VALUES
+	('default', 14, 14, 2, 5)
+	ON CONFLICT (role) DO NOTHING;`
+
+	if _, err := DB.Exec(seedLoanPolicy); err != nil {
+		return fmt.Errorf("failed to seed loan policy: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,95 @@
+package database_test
+
+import (
+	"strings"
+	"testing"
+
+	"rest-api-golang/database"
+	"rest-api-golang/internal/testdb"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCreateTablesReplacesCopiesStatusCheckOnce(t *testing.T) {
+	db := testdb.Open(t)
+	constraint := func() (int64, string) {
+		t.Helper()
+		var oid int64
+		var def string
+		err := db.QueryRow(`
+			SELECT oid, pg_get_constraintdef(oid) FROM pg_constraint
+			WHERE conrelid = 'copies'::regclass AND conname = 'copies_status_check'`).Scan(&oid, &def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return oid, def
+	}
+
+	before, _ := constraint()
+	if err := database.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := constraint(); after != before {
+		t.Error("copies_status_check was replaced although it allows on_hold")
+	}
+
+	// A database from before holds gets the new check
+	_, err := db.Exec(`
+		ALTER TABLE copies DROP CONSTRAINT copies_status_check;
+		ALTER TABLE copies ADD CONSTRAINT copies_status_check CHECK (status IN ('available', 'on_loan', 'maintenance', 'lost', 'withdrawn'));`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	if _, def := constraint(); !strings.Contains(def, "on_hold") {
+		t.Errorf("copies_status_check = %s", def)
+	}
+}
+
+func TestCreateTablesHashesPlaintextPasswords(t *testing.T) {
+	db := testdb.Open(t)
+	password := func(username string) string {
+		t.Helper()
+		var hash string
+		if err := db.QueryRow(`SELECT password FROM users WHERE username = $1`, username).Scan(&hash); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	// The seeded users are stored hashed
+	for username, plaintext := range map[string]string{"admin": "admin123", "user": "user123"} {
+		if err := bcrypt.CompareHashAndPassword([]byte(password(username)), []byte(plaintext)); err != nil {
+			t.Errorf("seeded %s: %v", username, err)
+		}
+	}
+	seeded := password("admin")
+
+	// Accounts from before passwords were hashed
+	long := strings.Repeat("0123456789", 8)
+	_, err := db.Exec(`
+		INSERT INTO users (username, password, email, role) VALUES
+		('legacy', 'legacy-password', 'legacy@example.com', 'user'),
+		('long', $1, 'long@example.com', 'user')`, long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+
+	for username, plaintext := range map[string]string{"legacy": "legacy-password", "long": long} {
+		hash := password(username)
+		if hash == plaintext {
+			t.Errorf("%s is still stored in plaintext", username)
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(plaintext)); err != nil {
+			t.Errorf("%s: %v", username, err)
+		}
+	}
+	if password("admin") != seeded {
+		t.Error("a hashed password was hashed again")
+	}
+}
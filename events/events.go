@@ -0,0 +1,86 @@
+// Package events publishes change events from the transactional outbox
+// through a pluggable EventPublisher: NATS or Kafka for production, stdout
+// for development, an in-memory channel for tests and in-process
+// consumers, or nowhere at all.
+package events
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"rest-api-golang/models"
+)
+
+// EventPublisher sends events to a broker. Publish returns once the broker
+// has accepted the event; the relay calls it for one event at a time, in
+// order.
+type EventPublisher interface {
+	Publish(ctx context.Context, e *models.OutboxEvent) error
+	Close() error
+}
+
+// Config selects and configures an EventPublisher from environment
+// variables
+type Config struct {
+	Driver       string // discard, stdout, memory, nats or kafka
+	NATSURL      string
+	NATSSubject  string
+	KafkaRESTURL string
+	KafkaTopic   string
+	MemoryBuffer int
+}
+
+// GetConfig returns event publisher configuration from environment
+// variables
+func GetConfig() *Config {
+	return &Config{
+		Driver:       getEnv("EVENT_PUBLISHER", "discard"),
+		NATSURL:      getEnv("NATS_URL", "nats://localhost:4222"),
+		NATSSubject:  getEnv("NATS_SUBJECT_PREFIX", "bookapi"),
+		KafkaRESTURL: getEnv("KAFKA_REST_URL", "http://localhost:8082"),
+		KafkaTopic:   getEnv("KAFKA_TOPIC", "bookapi.events"),
+		MemoryBuffer: 1024,
+	}
+}
+
+// New creates the EventPublisher selected by config.Driver
+func New(config *Config) (EventPublisher, error) {
+	switch config.Driver {
+	case "discard":
+		return Discard{}, nil
+	case "stdout":
+		return NewWriterPublisher(os.Stdout), nil
+	case "memory":
+		return NewMemoryPublisher(config.MemoryBuffer), nil
+	case "nats":
+		return NewNATSPublisher(config.NATSURL, config.NATSSubject)
+	case "kafka":
+		return NewKafkaPublisher(config.KafkaRESTURL, config.KafkaTopic, nil), nil
+	}
+	return nil, fmt.Errorf("unknown event publisher %q", config.Driver)
+}
+
+// Discard accepts every event and sends it nowhere
+type Discard struct{}
+
+func (Discard) Publish(context.Context, *models.OutboxEvent) error { return nil }
+
+func (Discard) Close() error { return nil }
+
+// subject names the topic of an event below prefix, such as
+// "bookapi.book.created"
+func subject(prefix string, e *models.OutboxEvent) string {
+	if prefix == "" {
+		return e.Type
+	}
+	return strings.TrimSuffix(prefix, ".") + "." + e.Type
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"rest-api-golang/models"
+)
+
+// kafkaRecord is a record sent to the REST Proxy stand-in
+type kafkaRecord struct {
+	Key   string             `json:"key"`
+	Value models.OutboxEvent `json:"value"`
+}
+
+// fakeKafkaREST is a stand-in for the produce endpoint of a Kafka REST
+// Proxy (API v2). It answers with respond when set.
+type fakeKafkaREST struct {
+	t       *testing.T
+	mu      sync.Mutex
+	records map[string][]kafkaRecord // by topic
+	respond func(w http.ResponseWriter)
+}
+
+func (f *fakeKafkaREST) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	topic, ok := strings.CutPrefix(r.URL.Path, "/topics/")
+	if r.Method != http.MethodPost || !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/vnd.kafka.json.v2+json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		w.Write([]byte(`{"error_code":415,"message":"HTTP 415 Unsupported Media Type"}`))
+		return
+	}
+	var body struct {
+		Records []kafkaRecord `json:"records"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		f.t.Errorf("bad produce request: %v", err)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	f.mu.Lock()
+	respond := f.respond
+	if respond == nil {
+		f.records[topic] = append(f.records[topic], body.Records...)
+	}
+	f.mu.Unlock()
+	if respond != nil {
+		respond(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.kafka.v2+json")
+	w.Write([]byte(`{"key_schema_id":null,"value_schema_id":null,"offsets":[{"partition":0,"offset":1,"error_code":null,"error":null}]}`))
+}
+
+func newFakeKafkaREST(t *testing.T) (*fakeKafkaREST, string) {
+	f := &fakeKafkaREST{t: t, records: map[string][]kafkaRecord{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	proxy, url := newFakeKafkaREST(t)
+	p := NewKafkaPublisher(url+"/", "bookapi.events", nil)
+
+	events := []*models.OutboxEvent{
+		testEvent(1, "b1", "book.created"),
+		testEvent(2, "b2", "book.created"),
+		testEvent(3, "b1", "book.updated"),
+	}
+	for _, e := range events {
+		if err := p.Publish(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := proxy.records["bookapi.events"]
+	if len(records) != len(events) {
+		t.Fatalf("topic has %d records, want %d", len(records), len(events))
+	}
+	for i, rec := range records {
+		// Keying by aggregate keeps an aggregate's events in one partition
+		if rec.Key != events[i].AggregateID {
+			t.Errorf("record %d key = %q, want %q", i, rec.Key, events[i].AggregateID)
+		}
+		if rec.Value.ID != events[i].ID || rec.Value.Type != events[i].Type || rec.Value.Sequence != events[i].Sequence {
+			t.Errorf("record %d value = %+v", i, rec.Value)
+		}
+	}
+}
+
+func TestKafkaPublisherErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(w http.ResponseWriter)
+		want    string
+	}{
+		{"status", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40401,"message":"Topic not found."}`))
+		}, "404 Not Found: {\"error_code\":40401"},
+		{"record error", func(w http.ResponseWriter) {
+			w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"Leader not available"}]}`))
+		}, "code 50003: Leader not available"},
+		{"invalid body", func(w http.ResponseWriter) {
+			w.Write([]byte(`<html>proxy error</html>`))
+		}, "invalid REST proxy response"},
+	}
+	for _, tt := range tests {
+		proxy, url := newFakeKafkaREST(t)
+		proxy.respond = tt.respond
+		err := NewKafkaPublisher(url, "bookapi.events", nil).Publish(context.Background(), testEvent(1, "b1", "book.created"))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Publish = %v, want an error with %q", tt.name, err, tt.want)
+		}
+	}
+}
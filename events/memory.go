@@ -0,0 +1,100 @@
+package events
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"rest-api-golang/models"
+)
+
+// MemoryPublisher hands events to in-process consumers through a buffered
+// channel. Publish waits while the buffer is full, so no event is lost to
+// a slow consumer.
+type MemoryPublisher struct {
+	events chan *models.OutboxEvent
+	once   sync.Once
+}
+
+// NewMemoryPublisher creates a publisher whose channel holds buffer events
+func NewMemoryPublisher(buffer int) *MemoryPublisher {
+	return &MemoryPublisher{events: make(chan *models.OutboxEvent, buffer)}
+}
+
+// Events is the channel published events arrive on. It is closed by Close.
+func (p *MemoryPublisher) Events() <-chan *models.OutboxEvent {
+	return p.events
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, e *models.OutboxEvent) error {
+	select {
+	case p.events <- e:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *MemoryPublisher) Close() error {
+	p.once.Do(func() { close(p.events) })
+	return nil
+}
+
+// WriterPublisher writes events as JSON lines, such as to stdout
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterPublisher creates a publisher writing to w
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, e *models.OutboxEvent) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
+
+func (p *WriterPublisher) Close() error {
+	return nil
+}
+
+// Deduper remembers the IDs of the most recent events, for consumers that
+// must handle each event once although it may be delivered more than once
+type Deduper struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	seen  map[string]*list.Element
+}
+
+// NewDeduper creates a deduper remembering the last size event IDs
+func NewDeduper(size int) *Deduper {
+	return &Deduper{size: size, order: list.New(), seen: map[string]*list.Element{}}
+}
+
+// Seen reports whether the event ID was seen before, and remembers it
+func (d *Deduper) Seen(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if el, ok := d.seen[id]; ok {
+		d.order.MoveToFront(el)
+		return true
+	}
+	d.seen[id] = d.order.PushFront(id)
+	if d.order.Len() > d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.seen, oldest.Value.(string))
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rest-api-golang/models"
+)
+
+// natsMessage is a message received by fakeNATS
+type natsMessage struct {
+	Subject string
+	Header  textproto.MIMEHeader
+	Body    []byte
+}
+
+// fakeNATS is a stand-in for a NATS server speaking the parts of the
+// client protocol the publisher uses: INFO, CONNECT, HPUB and PING/PONG.
+// Messages with an ID in reject get -ERR instead of being kept.
+type fakeNATS struct {
+	t        *testing.T
+	listener net.Listener
+
+	mu       sync.Mutex
+	connects []map[string]interface{}
+	messages []natsMessage
+	reject   map[string]bool
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeNATS{t: t, listener: l, reject: map[string]bool{}}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeNATS) URL() string {
+	return "nats://" + f.listener.Addr().String()
+}
+
+func (f *fakeNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"headers\":true,\"max_payload\":1048576}\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, args, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch verb {
+		case "CONNECT":
+			var options map[string]interface{}
+			if err := json.Unmarshal([]byte(args), &options); err != nil {
+				fmt.Fprintf(conn, "-ERR 'Invalid CONNECT'\r\n")
+				return
+			}
+			f.mu.Lock()
+			f.connects = append(f.connects, options)
+			f.mu.Unlock()
+		case "PING":
+			fmt.Fprintf(conn, "PONG\r\n")
+		case "HPUB":
+			msg, err := readHPUB(r, args)
+			if err != nil {
+				f.t.Errorf("bad HPUB: %v", err)
+				fmt.Fprintf(conn, "-ERR 'Protocol Error'\r\n")
+				return
+			}
+			f.mu.Lock()
+			rejected := f.reject[msg.Header.Get("Nats-Msg-Id")]
+			if !rejected {
+				f.messages = append(f.messages, msg)
+			}
+			f.mu.Unlock()
+			if rejected {
+				fmt.Fprintf(conn, "-ERR 'Permissions Violation for Publish to %s'\r\n", msg.Subject)
+			}
+		default:
+			fmt.Fprintf(conn, "-ERR 'Unknown Protocol Operation'\r\n")
+			return
+		}
+	}
+}
+
+// readHPUB reads the header block and payload announced by an HPUB line
+// with the arguments "<subject> <header bytes> <total bytes>"
+func readHPUB(r *bufio.Reader, args string) (natsMessage, error) {
+	fields := strings.Fields(args)
+	if len(fields) != 3 {
+		return natsMessage{}, fmt.Errorf("arguments %q", args)
+	}
+	headerLen, err1 := strconv.Atoi(fields[1])
+	totalLen, err2 := strconv.Atoi(fields[2])
+	if err1 != nil || err2 != nil || headerLen > totalLen {
+		return natsMessage{}, fmt.Errorf("sizes %q", args)
+	}
+	data := make([]byte, totalLen+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return natsMessage{}, err
+	}
+	if string(data[totalLen:]) != "\r\n" {
+		return natsMessage{}, fmt.Errorf("payload is not followed by CRLF")
+	}
+
+	headers := textproto.NewReader(bufio.NewReader(strings.NewReader(string(data[:headerLen]))))
+	version, err := headers.ReadLine()
+	if err != nil || version != "NATS/1.0" {
+		return natsMessage{}, fmt.Errorf("header version %q", version)
+	}
+	header, err := headers.ReadMIMEHeader()
+	if err != nil {
+		return natsMessage{}, err
+	}
+	return natsMessage{Subject: fields[0], Header: header, Body: data[headerLen:totalLen]}, nil
+}
+
+func (f *fakeNATS) received() ([]natsMessage, []map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]natsMessage(nil), f.messages...), append([]map[string]interface{}(nil), f.connects...)
+}
+
+func testEvent(sequence int64, aggregateID, eventType string) *models.OutboxEvent {
+	return &models.OutboxEvent{
+		ID:            fmt.Sprintf("00000000-0000-0000-0000-%012d", sequence),
+		Sequence:      sequence,
+		AggregateType: models.AggregateBook,
+		AggregateID:   aggregateID,
+		Type:          eventType,
+		Payload:       json.RawMessage(`{"judul":"Laskar Pelangi"}`),
+		CreatedAt:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	server := newFakeNATS(t)
+	p, err := NewNATSPublisher(strings.Replace(server.URL(), "nats://", "nats://relay:s3cret@", 1), "bookapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	events := []*models.OutboxEvent{
+		testEvent(1, "b1", "book.created"),
+		testEvent(2, "b1", "book.updated"),
+	}
+	for _, e := range events {
+		if err := p.Publish(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages, connects := server.received()
+	if len(connects) != 1 {
+		t.Fatalf("publisher connected %d times", len(connects))
+	}
+	if c := connects[0]; c["user"] != "relay" || c["pass"] != "s3cret" || c["headers"] != true || c["verbose"] != false {
+		t.Errorf("CONNECT options = %v", c)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("server has %d messages", len(messages))
+	}
+	for i, msg := range messages {
+		e := events[i]
+		if msg.Subject != "bookapi."+e.Type {
+			t.Errorf("message %d subject = %q", i, msg.Subject)
+		}
+		// JetStream drops a message whose ID it has seen
+		if id := msg.Header.Get("Nats-Msg-Id"); id != e.ID {
+			t.Errorf("message %d Nats-Msg-Id = %q, want %q", i, id, e.ID)
+		}
+		var got models.OutboxEvent
+		if err := json.Unmarshal(msg.Body, &got); err != nil || got.ID != e.ID || got.Sequence != e.Sequence || string(got.Payload) != string(e.Payload) {
+			t.Errorf("message %d body = %s, %v", i, msg.Body, err)
+		}
+	}
+}
+
+func TestNATSPublisherReconnectsAfterError(t *testing.T) {
+	server := newFakeNATS(t)
+	p, err := NewNATSPublisher(server.URL(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	rejected := testEvent(1, "b1", "book.created")
+	server.mu.Lock()
+	server.reject[rejected.ID] = true
+	server.mu.Unlock()
+
+	err = p.Publish(context.Background(), rejected)
+	if err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Fatalf("Publish of a rejected message = %v", err)
+	}
+	if err := p.Publish(context.Background(), testEvent(2, "b2", "book.created")); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, connects := server.received()
+	if len(connects) != 2 {
+		t.Errorf("publisher connected %d times, want a new connection after the error", len(connects))
+	}
+	if len(messages) != 1 || messages[0].Subject != "book.created" {
+		t.Errorf("server has %+v", messages)
+	}
+}
+
+func TestNATSPublisherUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	p, err := NewNATSPublisher("nats://"+addr, "bookapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := p.Publish(ctx, testEvent(1, "b1", "book.created")); err == nil {
+		t.Error("Publish to a closed port succeeded")
+	}
+}
+
+func TestNewNATSPublisher(t *testing.T) {
+	for _, u := range []string{"", "http://localhost:4222", "nats://", "localhost:4222"} {
+		if _, err := NewNATSPublisher(u, "bookapi"); err == nil {
+			t.Errorf("NewNATSPublisher accepted %q", u)
+		}
+	}
+	if _, err := NewNATSPublisher("nats://localhost", "bookapi"); err != nil {
+		t.Errorf("URL without a port: %v", err)
+	}
+}
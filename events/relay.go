@@ -0,0 +1,34 @@
+package events
+
+import (
+	"context"
+
+	"rest-api-golang/models"
+)
+
+// Outbox is the table events are relayed from, implemented by the outbox
+// repository
+type Outbox interface {
+	PublishPending(limit int, publish func(*models.OutboxEvent) error) (int, error)
+}
+
+// Relay moves events from the outbox to a publisher
+type Relay struct {
+	outbox    Outbox
+	publisher EventPublisher
+	batchSize int
+}
+
+// NewRelay creates a relay publishing up to batchSize events per run
+func NewRelay(outbox Outbox, publisher EventPublisher, batchSize int) *Relay {
+	return &Relay{outbox: outbox, publisher: publisher, batchSize: batchSize}
+}
+
+// RelayPending publishes the next batch of unpublished events and returns
+// how many were published. Events the publisher rejects stay in the outbox
+// and are retried on the next run.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	return r.outbox.PublishPending(r.batchSize, func(e *models.OutboxEvent) error {
+		return r.publisher.Publish(ctx, e)
+	})
+}
@@ -0,0 +1,182 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"rest-api-golang/internal/testdb"
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+)
+
+// outboxFixture empties the outbox and adds an event per aggregate ID,
+// returning the event IDs in order
+func outboxFixture(t *testing.T, db *sql.DB, aggregateIDs ...string) []string {
+	t.Helper()
+	if _, err := db.Exec(`DELETE FROM outbox`); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, aggregateID := range aggregateIDs {
+		var id string
+		err := db.QueryRow(`
+			INSERT INTO outbox (event_id, aggregate_type, aggregate_id, event_type, payload)
+			VALUES (gen_random_uuid(), 'book', $1, 'book.updated', '{}')
+			RETURNING event_id`, aggregateID).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestRelayPublishesInOrderAndBlocksFailedAggregates(t *testing.T) {
+	db := testdb.Open(t)
+	server := newFakeNATS(t)
+	publisher, err := NewNATSPublisher(server.URL(), "bookapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	relay := NewRelay(repositories.NewOutboxRepository(db), publisher, 100)
+
+	ids := outboxFixture(t, db, "b1", "b2", "b1", "b3", "b2")
+	// The broker refuses the first event of b1, so the later b1 event has
+	// to wait for it
+	server.mu.Lock()
+	server.reject[ids[0]] = true
+	server.mu.Unlock()
+
+	published, err := relay.RelayPending(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published != 3 {
+		t.Errorf("first run published %d events, want 3", published)
+	}
+	messages, _ := server.received()
+	if got := messageIDs(messages); !equalStrings(got, []string{ids[1], ids[3], ids[4]}) {
+		t.Errorf("first run sent %v, want the b2 and b3 events in order", got)
+	}
+
+	var attempts int
+	var lastError string
+	err = db.QueryRow(`SELECT attempts, last_error FROM outbox WHERE event_id = $1`, ids[0]).Scan(&attempts, &lastError)
+	if err != nil || attempts != 1 || lastError == "" {
+		t.Errorf("failed event has %d attempts, error %q, %v", attempts, lastError, err)
+	}
+	err = db.QueryRow(`SELECT attempts FROM outbox WHERE event_id = $1`, ids[2]).Scan(&attempts)
+	if err != nil || attempts != 0 {
+		t.Errorf("blocked event was tried %d times, %v", attempts, err)
+	}
+
+	// Once the broker takes it, b1 catches up in order
+	server.mu.Lock()
+	delete(server.reject, ids[0])
+	server.mu.Unlock()
+	if published, err = relay.RelayPending(context.Background()); err != nil || published != 2 {
+		t.Fatalf("second run published %d events, %v", published, err)
+	}
+	messages, _ = server.received()
+	if got := messageIDs(messages[3:]); !equalStrings(got, []string{ids[0], ids[2]}) {
+		t.Errorf("second run sent %v, want the b1 events in order", got)
+	}
+	if published, err = relay.RelayPending(context.Background()); err != nil || published != 0 {
+		t.Errorf("third run published %d events, %v", published, err)
+	}
+}
+
+// blockingPublisher signals each event it is given on started and waits
+// for release before accepting it
+type blockingPublisher struct {
+	started chan *models.OutboxEvent
+	release chan struct{}
+}
+
+func (p *blockingPublisher) Publish(ctx context.Context, e *models.OutboxEvent) error {
+	p.started <- e
+	select {
+	case <-p.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *blockingPublisher) Close() error { return nil }
+
+// failingPublisher fails every event
+type failingPublisher struct{ calls int }
+
+func (p *failingPublisher) Publish(context.Context, *models.OutboxEvent) error {
+	p.calls++
+	return errors.New("broker down")
+}
+
+func (p *failingPublisher) Close() error { return nil }
+
+func TestOnlyOneRelayRunsAtATime(t *testing.T) {
+	db := testdb.Open(t)
+	outboxFixture(t, db, "b1", "b2")
+
+	blocking := &blockingPublisher{started: make(chan *models.OutboxEvent, 2), release: make(chan struct{})}
+	first := NewRelay(repositories.NewOutboxRepository(db), blocking, 100)
+	done := make(chan error, 1)
+	var published int
+	go func() {
+		var err error
+		published, err = first.RelayPending(context.Background())
+		done <- err
+	}()
+	select {
+	case <-blocking.started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("first relay did not start publishing")
+	}
+
+	// A second relay, as in another instance of the API, finds the
+	// advisory lock taken and leaves the outbox alone
+	other := &failingPublisher{}
+	n, err := NewRelay(repositories.NewOutboxRepository(db), other, 100).RelayPending(context.Background())
+	if err != nil || n != 0 || other.calls != 0 {
+		t.Errorf("second relay published %d events with %d calls, %v", n, other.calls, err)
+	}
+
+	close(blocking.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if published != 2 {
+		t.Errorf("first relay published %d events", published)
+	}
+
+	// With the lock released, the next relay gets its turn
+	outboxFixture(t, db, "b3")
+	if _, err := NewRelay(repositories.NewOutboxRepository(db), other, 100).RelayPending(context.Background()); err != nil || other.calls != 1 {
+		t.Errorf("relay after the first one finished made %d calls, %v", other.calls, err)
+	}
+}
+
+func messageIDs(messages []natsMessage) []string {
+	var ids []string
+	for _, msg := range messages {
+		ids = append(ids, msg.Header.Get("Nats-Msg-Id"))
+	}
+	return ids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
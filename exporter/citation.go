@@ -0,0 +1,162 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"rest-api-golang/models"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// bibtexEscaper escapes the characters LaTeX treats specially
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+// bibtexWriter writes @book entries. Keys are unique within the file.
+type bibtexWriter struct {
+	w    io.Writer
+	keys map[string]int
+}
+
+// asciiWord lowercases s and keeps only ASCII letters and digits, after
+// stripping accents: "Ñúñez" becomes "nunez"
+func asciiWord(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		r = unicode.ToLower(r)
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// citationKey builds a key such as "pratchett1990good" from the first
+// author's last name, the year and the first word of the title
+func citationKey(book *models.Book) string {
+	key := ""
+	if authors := names(book, models.AuthorRoleAuthor); len(authors) > 0 {
+		parts := strings.Fields(authors[0])
+		key = asciiWord(parts[len(parts)-1])
+	}
+	key += strconv.Itoa(book.TahunTerbit)
+	for _, word := range strings.Fields(book.Judul) {
+		if w := asciiWord(word); w != "" && !isStopWord(w) {
+			key += w
+			break
+		}
+	}
+	return key
+}
+
+func isStopWord(w string) bool {
+	switch w {
+	case "a", "an", "the", "of", "and":
+		return true
+	}
+	return false
+}
+
+func (b *bibtexWriter) Write(book *models.Book) error {
+	// Repeated keys get a letter suffix: smith2001, smith2001a, ...
+	key := citationKey(book)
+	if n := b.keys[key]; n > 0 {
+		b.keys[key] = n + 1
+		key += string(rune('a' + (n-1)%26))
+		if n > 26 {
+			key += strconv.Itoa(n)
+		}
+	} else {
+		b.keys[key] = 1
+	}
+
+	var e strings.Builder
+	fmt.Fprintf(&e, "@book{%s,\n", key)
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&e, "  %s = {%s},\n", name, bibtexEscaper.Replace(value))
+		}
+	}
+	// Double braces keep the title's capitalization
+	fmt.Fprintf(&e, "  title = {{%s}},\n", bibtexEscaper.Replace(book.Judul))
+	field("author", strings.Join(names(book, models.AuthorRoleAuthor), " and "))
+	field("editor", strings.Join(names(book, models.AuthorRoleEditor), " and "))
+	field("translator", strings.Join(names(book, models.AuthorRoleTranslator), " and "))
+	field("year", strconv.Itoa(book.TahunTerbit))
+	field("publisher", book.Publisher)
+	field("edition", book.Edition)
+	field("isbn", book.ISBN)
+	field("language", book.Language)
+	if book.Pages > 0 {
+		field("pagetotal", strconv.Itoa(book.Pages))
+	}
+	field("keywords", strings.Join(book.Tags, ", "))
+	e.WriteString("}\n\n")
+
+	_, err := io.WriteString(b.w, e.String())
+	return err
+}
+
+func (b *bibtexWriter) Close() error {
+	return nil
+}
+
+// risWriter writes RIS records of type BOOK
+type risWriter struct {
+	w io.Writer
+}
+
+func (r *risWriter) Write(book *models.Book) error {
+	var e strings.Builder
+	field := func(tag, value string) {
+		// RIS values are single lines
+		if value = strings.Join(strings.Fields(value), " "); value != "" {
+			fmt.Fprintf(&e, "%s  - %s\r\n", tag, value)
+		}
+	}
+	field("TY", "BOOK")
+	field("TI", book.Judul)
+	for _, name := range names(book, models.AuthorRoleAuthor) {
+		field("AU", name)
+	}
+	for _, name := range names(book, models.AuthorRoleEditor) {
+		field("A2", name)
+	}
+	for _, name := range names(book, models.AuthorRoleTranslator) {
+		field("A4", name)
+	}
+	field("PY", strconv.Itoa(book.TahunTerbit))
+	field("PB", book.Publisher)
+	field("ET", book.Edition)
+	field("SN", book.ISBN)
+	field("LA", book.Language)
+	if book.Pages > 0 {
+		field("SP", strconv.Itoa(book.Pages))
+	}
+	field("AB", book.Description)
+	for _, tag := range book.Tags {
+		field("KW", tag)
+	}
+	e.WriteString("ER  - \r\n\r\n")
+
+	_, err := io.WriteString(r.w, e.String())
+	return err
+}
+
+func (r *risWriter) Close() error {
+	return nil
+}
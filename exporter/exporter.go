@@ -0,0 +1,182 @@
+// Package exporter writes books to catalog files one at a time, so an
+// export can be streamed to the client while it is read from the
+// database. CSV output uses the column names of POST /api/books/import so
+// it can be imported again, JSON Lines holds the books as the API returns
+// them, XLSX is for spreadsheets, and BibTeX and RIS for reference
+// managers.
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+)
+
+// Export formats accepted by GET /api/books/export
+const (
+	FormatCSV    = "csv"
+	FormatJSONL  = "jsonl"
+	FormatXLSX   = "xlsx"
+	FormatBibTeX = "bibtex"
+	FormatRIS    = "ris"
+)
+
+var Formats = []string{FormatCSV, FormatJSONL, FormatXLSX, FormatBibTeX, FormatRIS}
+
+// Writer writes books in one format. Close finishes the file but does not
+// close the underlying writer.
+type Writer interface {
+	Write(book *models.Book) error
+	Close() error
+}
+
+// New creates a Writer for format
+func New(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	case FormatBibTeX:
+		return &bibtexWriter{w: w, keys: map[string]int{}}, nil
+	case FormatRIS:
+		return &risWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// ContentType is the media type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatBibTeX:
+		return "application/x-bibtex; charset=utf-8"
+	case FormatRIS:
+		return "application/x-research-info-systems; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// Extension is the file name extension of a format
+func Extension(format string) string {
+	switch format {
+	case FormatJSONL:
+		return ".jsonl"
+	case FormatBibTeX:
+		return ".bib"
+	}
+	return "." + format
+}
+
+// columns are the CSV and XLSX columns. Their names match the import so
+// an exported CSV can be imported again.
+var columns = []string{
+	"id", "judul", "author", "tahun_terbit", "isbn", "publisher", "language", "pages",
+	"description", "edition", "format", "categories", "tags", "average_rating", "rating_count", "created_at",
+}
+
+// formulaPrefixes are the first characters that make a spreadsheet run a
+// cell as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// textCell escapes a value a spreadsheet would run as a formula with a
+// leading ', so titles such as "=HYPERLINK(...)" open as text. The CSV
+// import takes the quote off again.
+func textCell(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// row returns the column values of a book, with text escaped by textCell.
+// Categories are given by slug and, like tags, separated by "; ".
+func row(book *models.Book) []string {
+	slugs := make([]string, len(book.Categories))
+	for i, c := range book.Categories {
+		slugs[i] = c.Slug
+	}
+	pages := ""
+	if book.Pages > 0 {
+		pages = strconv.Itoa(book.Pages)
+	}
+	return []string{
+		book.ID,
+		textCell(book.Judul),
+		textCell(book.Author),
+		strconv.Itoa(book.TahunTerbit),
+		textCell(book.ISBN),
+		textCell(book.Publisher),
+		textCell(book.Language),
+		pages,
+		textCell(book.Description),
+		textCell(book.Edition),
+		textCell(book.Format),
+		textCell(strings.Join(slugs, "; ")),
+		textCell(strings.Join(book.Tags, "; ")),
+		strconv.FormatFloat(book.AverageRating, 'f', 2, 64),
+		strconv.Itoa(book.RatingCount),
+		book.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// names returns the names of a book's contributors with role, falling back
+// to the flat author string for the author role
+func names(book *models.Book, role string) []string {
+	var list []string
+	for _, a := range book.Authors {
+		if a.Role == role {
+			list = append(list, a.Name)
+		}
+	}
+	if len(list) == 0 && role == models.AuthorRoleAuthor && len(book.Authors) == 0 {
+		list = models.SplitAuthorNames(book.Author)
+	}
+	return list
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: cw}, nil
+}
+
+func (c *csvWriter) Write(book *models.Book) error {
+	return c.w.Write(row(book))
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(book *models.Book) error {
+	return j.enc.Encode(book)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
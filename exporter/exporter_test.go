@@ -0,0 +1,165 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"testing"
+	"time"
+
+	"rest-api-golang/importer"
+	"rest-api-golang/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// formulaBook has text a spreadsheet would run as formulas
+func formulaBook() *models.Book {
+	return &models.Book{
+		ID:          "b1",
+		Judul:       `=HYPERLINK("http://evil.example.com","Click")`,
+		Author:      "@SUM(A1)",
+		TahunTerbit: 2020,
+		Publisher:   "+Penerbit",
+		Description: "-2+3",
+		Edition:     "\t=1+1",
+		Tags:        []string{"=cmd", "safe"},
+		Language:    "id",
+		CreatedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func export(t *testing.T, format string, books ...*models.Book) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := New(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, book := range books {
+		if err := w.Write(book); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTextCell(t *testing.T) {
+	tests := map[string]string{
+		"":              "",
+		"Bumi Manusia":  "Bumi Manusia",
+		"=1+1":          "'=1+1",
+		"+62 21":        "'+62 21",
+		"-":             "'-",
+		"@user":         "'@user",
+		"\t=cmd":        "'\t=cmd",
+		"\r=cmd":        "'\r=cmd",
+		"a=b":           "a=b",
+		"'already text": "'already text",
+	}
+	for value, want := range tests {
+		if got := textCell(value); got != want {
+			t.Errorf("textCell(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	data := export(t, FormatCSV, formulaBook())
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%d rows", len(rows))
+	}
+	got := map[string]string{}
+	for i, name := range rows[0] {
+		got[name] = rows[1][i]
+	}
+	want := map[string]string{
+		"judul":        `'=HYPERLINK("http://evil.example.com","Click")`,
+		"author":       "'@SUM(A1)",
+		"publisher":    "'+Penerbit",
+		"description":  "'-2+3",
+		"edition":      "'\t=1+1",
+		"tags":         "'=cmd; safe",
+		"language":     "id",
+		"tahun_terbit": "2020",
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %q, want %q", name, got[name], value)
+		}
+	}
+
+	// The import takes the quotes off, so the export round-trips
+	records, err := importer.Parse(models.ImportFormatCSV, data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := formulaBook()
+	if len(records) != 1 || records[0].Err != nil {
+		t.Fatalf("imported %+v", records)
+	}
+	imported := records[0].Book
+	if imported.Judul != book.Judul || imported.Author != book.Author || imported.Description != book.Description || imported.Tags[0] != "=cmd" {
+		t.Errorf("imported %+v", imported)
+	}
+}
+
+func TestXLSXEscapesFormulas(t *testing.T) {
+	book := formulaBook()
+	book.Pages = 320
+	book.AverageRating = 4.5
+	f, err := excelize.OpenReader(bytes.NewReader(export(t, FormatXLSX, book)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(xlsxSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || !slices.Equal(rows[0], columns) {
+		t.Fatalf("rows = %q", rows)
+	}
+	for cell, want := range map[string]string{
+		"B2": `'=HYPERLINK("http://evil.example.com","Click")`,
+		"C2": "'@SUM(A1)",
+		"F2": "'+Penerbit",
+		"I2": "'-2+3",
+		"J2": "'\t=1+1",
+		"M2": "'=cmd; safe",
+	} {
+		got, err := f.GetCellValue(xlsxSheet, cell)
+		if err != nil || got != want {
+			t.Errorf("%s = %q, %v, want %q", cell, got, err, want)
+		}
+		if formula, _ := f.GetCellFormula(xlsxSheet, cell); formula != "" {
+			t.Errorf("%s has the formula %q", cell, formula)
+		}
+	}
+
+	// Counts are numbers, everything else is text
+	for cell, want := range map[string]excelize.CellType{
+		"D2": excelize.CellTypeUnset, "H2": excelize.CellTypeUnset, "N2": excelize.CellTypeUnset,
+		"A2": excelize.CellTypeInlineString, "B2": excelize.CellTypeInlineString,
+	} {
+		if got, err := f.GetCellType(xlsxSheet, cell); err != nil || got != want {
+			t.Errorf("type of %s = %v, %v, want %v", cell, got, err, want)
+		}
+	}
+	if got, _ := f.GetCellValue(xlsxSheet, "H2"); got != "320" {
+		t.Errorf("pages = %q", got)
+	}
+
+	panes, err := f.GetPanes(xlsxSheet)
+	if err != nil || !panes.Freeze || panes.YSplit != 1 {
+		t.Errorf("panes = %+v, %v", panes, err)
+	}
+}
@@ -0,0 +1,95 @@
+package exporter
+
+import (
+	"io"
+	"strconv"
+
+	"rest-api-golang/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxSheet is the name of the only worksheet
+const xlsxSheet = "Books"
+
+// numericColumns are written as numbers rather than text
+var numericColumns = map[string]bool{"tahun_terbit": true, "pages": true, "average_rating": true, "rating_count": true}
+
+// xlsxWriter streams rows into an excelize worksheet, which keeps them in a
+// temporary file once they outgrow memory. The workbook is a zip archive
+// with the sheet inside, so nothing reaches w before Close.
+type xlsxWriter struct {
+	w     io.Writer
+	file  *excelize.File
+	sheet *excelize.StreamWriter
+	rows  int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	x := &xlsxWriter{w: w, file: file}
+	if err := x.start(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return x, nil
+}
+
+// start names the sheet, freezes the bold header row and writes it
+func (x *xlsxWriter) start() error {
+	if err := x.file.SetSheetName("Sheet1", xlsxSheet); err != nil {
+		return err
+	}
+	sheet, err := x.file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		return err
+	}
+	x.sheet = sheet
+	bold, err := x.file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	err = sheet.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	if err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = excelize.Cell{StyleID: bold, Value: column}
+	}
+	return x.writeRow(header)
+}
+
+func (x *xlsxWriter) writeRow(values []interface{}) error {
+	x.rows++
+	cell, err := excelize.CoordinatesToCellName(1, x.rows)
+	if err != nil {
+		return err
+	}
+	return x.sheet.SetRow(cell, values)
+}
+
+// Write adds a row. Text goes in as plain strings, never as formulas, and
+// row has already escaped values a spreadsheet would run as one.
+func (x *xlsxWriter) Write(book *models.Book) error {
+	values := row(book)
+	cells := make([]interface{}, len(values))
+	for i, value := range values {
+		cells[i] = value
+		if numericColumns[columns[i]] && value != "" {
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				cells[i] = n
+			}
+		}
+	}
+	return x.writeRow(cells)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.w)
+}
@@ -6,6 +6,9 @@ let books = [];
 let currentEditingBook = null;
 let currentDeletingBook = null;
 let authToken = localStorage.getItem('book_api_token') || '';
+let liveFeed = null;
+let liveReloadTimer = null;
+let liveRetryTimer = null;
 
 // DOM Elements
 const booksGrid = document.getElementById('booksGrid');
@@ -371,6 +374,59 @@ function updateAuthUI() {
     logoutBtn.style.display = loggedIn ? 'inline-flex' : 'none';
     addBookBtn.disabled = !loggedIn;
     addBookBtn.title = loggedIn ? '' : 'Login to add a book';
+    connectLiveFeed();
+}
+
+// Live Feed: reload when colleagues change books. The feed is opened with
+// a single-use ticket, so after the connection drops a new ticket is
+// fetched and the feed resumes from the last event it received.
+async function connectLiveFeed(lastEventId = '') {
+    clearTimeout(liveRetryTimer);
+    if (liveFeed) {
+        liveFeed.close();
+        liveFeed = null;
+    }
+    if (!authToken || !window.EventSource) return;
+
+    const token = authToken;
+    let ticket;
+    try {
+        const response = await fetch(`${API_BASE_URL}/events/ticket`, {
+            method: 'POST',
+            headers: authHeaders()
+        });
+        if (!response.ok) throw new Error(`ticket request failed with ${response.status}`);
+        ticket = (await response.json()).ticket;
+    } catch (error) {
+        console.error('Live feed:', error);
+        liveRetryTimer = setTimeout(() => connectLiveFeed(lastEventId), 5000);
+        return;
+    }
+    // Logged out or in again while the ticket was requested
+    if (token !== authToken) return;
+
+    let url = `${API_BASE_URL}/events?ticket=${encodeURIComponent(ticket)}`;
+    if (lastEventId) url += `&last_event_id=${encodeURIComponent(lastEventId)}`;
+    const feed = new EventSource(url);
+    ['book.created', 'book.updated', 'book.deleted', 'book.restored', 'reset'].forEach(type => {
+        feed.addEventListener(type, event => {
+            lastEventId = event.lastEventId || lastEventId;
+            scheduleLiveReload();
+        });
+    });
+    // The ticket is spent, so EventSource cannot reconnect by itself
+    feed.onerror = () => {
+        feed.close();
+        if (liveFeed !== feed) return;
+        liveFeed = null;
+        liveRetryTimer = setTimeout(() => connectLiveFeed(lastEventId), 3000);
+    };
+    liveFeed = feed;
+}
+
+function scheduleLiveReload() {
+    clearTimeout(liveReloadTimer);
+    liveReloadTimer = setTimeout(loadBooks, 300);
 }
 
 function authHeaders() {
@@ -0,0 +1,168 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Schema is a graphql-go schema
+type Schema = gql.Schema
+
+// Thunk is a value that is computed later. graphql-go resolves every field
+// of a level of a query before calling any of the thunks they returned, so
+// a Loader can fetch the keys of all of them at once.
+type Thunk = func() (interface{}, error)
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is absent when the request
+// failed before execution, and null when a non-null root field failed.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is an error in the errors list of a response
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Location is a position in the query document
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Options limit the operations Execute runs; zero limits are unlimited
+type Options struct {
+	// MaxDepth is the deepest nesting of fields allowed, where root
+	// fields are at depth 1
+	MaxDepth int
+	// MaxComplexity is the highest total cost allowed; see measure
+	MaxComplexity int
+	// QueryOnly rejects mutations, for requests that must not change
+	// anything such as GET requests
+	QueryOnly bool
+}
+
+// Execute parses and validates the operation of req, checks it against
+// the limits of opts and runs it with graphql-go
+func Execute(ctx context.Context, schema *Schema, req *Request, opts Options) *Response {
+	fail := func(err error) *Response {
+		e, ok := err.(*Error)
+		if !ok {
+			e = &Error{Message: err.Error()}
+		}
+		return &Response{Errors: []*Error{e}}
+	}
+
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return fail(err)
+	}
+	if result := gql.ValidateDocument(schema, doc, nil); !result.IsValid {
+		return &Response{Errors: responseErrors(result.Errors)}
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return fail(err)
+	}
+
+	root := schema.QueryType()
+	switch op.Operation {
+	case ast.OperationTypeSubscription:
+		return fail(&Error{Message: "Subscriptions are not supported.", Locations: nodeLocations(op)})
+	case ast.OperationTypeMutation:
+		if schema.MutationType() == nil {
+			return fail(&Error{Message: "Schema is not configured for mutations.", Locations: nodeLocations(op)})
+		}
+		if opts.QueryOnly {
+			return fail(&Error{Message: "Can only perform a mutation operation from a POST request.", Locations: nodeLocations(op)})
+		}
+		root = schema.MutationType()
+	}
+
+	depth, complexity := measure(schema, doc, op, root, req.Variables)
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return fail(&Error{Message: fmt.Sprintf("Query depth %d exceeds the maximum of %d.", depth, opts.MaxDepth), Locations: nodeLocations(op)})
+	}
+	if opts.MaxComplexity > 0 && complexity > opts.MaxComplexity {
+		return fail(&Error{Message: fmt.Sprintf("Query complexity %d exceeds the maximum of %d.", complexity, opts.MaxComplexity), Locations: nodeLocations(op)})
+	}
+
+	result := gql.Execute(gql.ExecuteParams{
+		Schema:        *schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	response := &Response{Data: result.Data, Errors: responseErrors(result.Errors)}
+	if result.Data == nil {
+		response.Data = json.RawMessage("null")
+	}
+	return response
+}
+
+// selectOperation picks the operation to run from a document
+func selectOperation(doc *ast.Document, name string) (*ast.OperationDefinition, error) {
+	var ops []*ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok {
+			ops = append(ops, op)
+		}
+	}
+	if name == "" {
+		if len(ops) != 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		return ops[0], nil
+	}
+	for _, op := range ops {
+		if op.Name != nil && op.Name.Value == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+}
+
+// responseErrors converts graphql-go errors for a response
+func responseErrors(errs []gqlerrors.FormattedError) []*Error {
+	if len(errs) == 0 {
+		return nil
+	}
+	out := make([]*Error, len(errs))
+	for i, err := range errs {
+		e := &Error{Message: err.Message, Path: err.Path}
+		for _, l := range err.Locations {
+			e.Locations = append(e.Locations, Location{Line: l.Line, Column: l.Column})
+		}
+		out[i] = e
+	}
+	return out
+}
+
+// nodeLocations returns the location of the start of node
+func nodeLocations(node ast.Node) []Location {
+	loc := node.GetLoc()
+	if loc == nil || loc.Source == nil {
+		return nil
+	}
+	return []Location{locationAt(loc.Source, loc.Start)}
+}
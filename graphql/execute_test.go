@@ -0,0 +1,292 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+type testAuthor struct {
+	ID   string
+	Name string
+}
+
+type testBook struct {
+	ID       string
+	Title    string
+	AuthorID string
+}
+
+// testLibrary is the data behind the test schema, counting the fetches
+// its loaders make
+type testLibrary struct {
+	authors map[string]*testAuthor
+	books   []*testBook
+
+	mu      sync.Mutex
+	fetches [][]string
+}
+
+func newTestLibrary() *testLibrary {
+	lib := &testLibrary{authors: map[string]*testAuthor{}}
+	for i := 1; i <= 3; i++ {
+		id := fmt.Sprintf("a%d", i)
+		lib.authors[id] = &testAuthor{ID: id, Name: fmt.Sprintf("Author %d", i)}
+	}
+	for i := 1; i <= 6; i++ {
+		lib.books = append(lib.books, &testBook{ID: fmt.Sprintf("b%d", i), Title: fmt.Sprintf("Book %d", i), AuthorID: fmt.Sprintf("a%d", (i-1)%3+1)})
+	}
+	return lib
+}
+
+func (lib *testLibrary) fetchAuthors(ids []string) (map[string]*testAuthor, error) {
+	lib.mu.Lock()
+	lib.fetches = append(lib.fetches, append([]string(nil), ids...))
+	lib.mu.Unlock()
+	found := map[string]*testAuthor{}
+	for _, id := range ids {
+		if a, ok := lib.authors[id]; ok {
+			found[id] = a
+		}
+	}
+	return found, nil
+}
+
+type loaderContextKey struct{}
+
+// testSchema has books, their authors loaded through a Loader from the
+// context, and the books of each author
+func testSchema(t *testing.T, lib *testLibrary) *Schema {
+	t.Helper()
+	authorType := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "Author",
+		Fields: graphql.Fields{"id": {Type: graphql.NewNonNull(graphql.ID)}, "name": {Type: graphql.NewNonNull(graphql.String)}},
+	})
+	bookType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Book",
+		Fields: graphql.Fields{
+			"id":    {Type: graphql.NewNonNull(graphql.ID)},
+			"title": {Type: graphql.NewNonNull(graphql.String)},
+			"author": {Type: authorType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				loader := p.Context.Value(loaderContextKey{}).(*Loader[string, *testAuthor])
+				return loader.Load(p.Source.(*testBook).AuthorID), nil
+			}},
+		},
+	})
+	first := graphql.FieldConfigArgument{"first": {Type: graphql.Int, DefaultValue: 10}}
+	authorType.AddFieldConfig("books", &graphql.Field{
+		Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookType))),
+		Args: first,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			var books []*testBook
+			for _, b := range lib.books {
+				if b.AuthorID == p.Source.(*testAuthor).ID && len(books) < p.Args["first"].(int) {
+					books = append(books, b)
+				}
+			}
+			return books, nil
+		},
+	})
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"books": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookType))),
+				Args: first,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					n := min(p.Args["first"].(int), len(lib.books))
+					return lib.books[:n], nil
+				},
+			},
+			"broken": {Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return nil, errors.New("database down")
+			}},
+			"author": {
+				Type: authorType,
+				Args: graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					loader := p.Context.Value(loaderContextKey{}).(*Loader[string, *testAuthor])
+					return loader.Load(p.Args["id"].(string)), nil
+				},
+			},
+		},
+	})
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"addBook": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return true, nil
+			}},
+		},
+	})
+	s, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &s
+}
+
+// execute runs query with a fresh loader, like a request would, and
+// returns the response as JSON
+func execute(t *testing.T, s *Schema, lib *testLibrary, query string, opts Options) (map[string]interface{}, []*Error) {
+	t.Helper()
+	ctx := context.WithValue(context.Background(), loaderContextKey{}, NewLoader(lib.fetchAuthors))
+	resp := Execute(ctx, s, &Request{Query: query}, opts)
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out, resp.Errors
+}
+
+func TestExecuteLimits(t *testing.T) {
+	lib := newTestLibrary()
+	s := testSchema(t, lib)
+	tests := []struct {
+		name  string
+		query string
+		opts  Options
+		want  string
+	}{
+		{"depth at the limit", "{ books { author { name } } }", Options{MaxDepth: 3}, ""},
+		{"depth", "{ books { author { books { id } } } }", Options{MaxDepth: 3}, "Query depth 4 exceeds the maximum of 3."},
+		{"depth through fragments", "{ books { ...F } } fragment F on Book { author { books { id } } }", Options{MaxDepth: 3}, "Query depth 4 exceeds the maximum of 3."},
+		// books: 1 + 10 * (id + title) = 21
+		{"complexity at the limit", "{ books { id title } }", Options{MaxComplexity: 21}, ""},
+		{"complexity", "{ books { id title } }", Options{MaxComplexity: 20}, "Query complexity 21 exceeds the maximum of 20."},
+		// 1 + 100 * (author 1 + 1 + 100 * id 1) = 10201
+		{"complexity of nested lists", "{ books(first: 100) { author { books(first: 100) { id } } } }", Options{MaxComplexity: 10200}, "Query complexity 10201 exceeds the maximum of 10200."},
+		{"first from a variable", "query($n: Int = 100) { books(first: $n) { author { books(first: 100) { id } } } }", Options{MaxComplexity: 10200}, "Query complexity 10201 exceeds the maximum of 10200."},
+		{"aliases add up", "{ a: books { id } b: books { id } }", Options{MaxComplexity: 21}, "Query complexity 22 exceeds the maximum of 21."},
+		// Introspection is not counted
+		{"introspection", "{ __schema { types { fields { type { ofType { ofType { name } } } } } } }", Options{MaxDepth: 1, MaxComplexity: 1}, ""},
+		{"no limits", "{ books(first: 100) { author { books(first: 100) { author { name } } } } }", Options{}, ""},
+	}
+	for _, tt := range tests {
+		data, errs := execute(t, s, lib, tt.query, tt.opts)
+		if tt.want == "" {
+			if len(errs) > 0 || data == nil {
+				t.Errorf("%s: %v", tt.name, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 || errs[0].Message != tt.want || data != nil {
+			t.Errorf("%s: data %v, errors %v, want %q", tt.name, data, errs, tt.want)
+		}
+	}
+}
+
+func TestExecuteBatchesLoads(t *testing.T) {
+	lib := newTestLibrary()
+	s := testSchema(t, lib)
+
+	// Six books by three authors take one fetch with each author once;
+	// the authors of their books, a level deeper, are already loaded
+	data, errs := execute(t, s, lib, "{ books { title author { name books { author { id } } } } }", Options{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(lib.fetches) != 1 {
+		t.Fatalf("authors were fetched %d times: %v", len(lib.fetches), lib.fetches)
+	}
+	ids := lib.fetches[0]
+	sort.Strings(ids)
+	if strings.Join(ids, ",") != "a1,a2,a3" {
+		t.Errorf("fetch of %v, want each author once", ids)
+	}
+	books := data["books"].([]interface{})
+	if len(books) != 6 {
+		t.Fatalf("%d books", len(books))
+	}
+	for i, b := range books {
+		book := b.(map[string]interface{})
+		author := book["author"].(map[string]interface{})
+		if want := fmt.Sprintf("Author %d", i%3+1); author["name"] != want {
+			t.Errorf("book %d author = %v, want %s", i, author["name"], want)
+		}
+	}
+
+	// Keys loaded once are not fetched again in the same request, and a
+	// missing key resolves to null
+	lib.fetches = nil
+	data, errs = execute(t, s, lib, `{ a: author(id: "a1") { name } b: author(id: "a1") { id } c: author(id: "none") { id } }`, Options{})
+	if len(errs) > 0 || data["c"] != nil || data["a"].(map[string]interface{})["name"] != "Author 1" {
+		t.Errorf("data %v, errors %v", data, errs)
+	}
+	if len(lib.fetches) != 1 || len(lib.fetches[0]) != 2 {
+		t.Errorf("fetches %v, want one of a1 and none", lib.fetches)
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	calls := 0
+	l := NewLoader(func(keys []int) (map[int]string, error) {
+		calls++
+		return nil, fmt.Errorf("database down")
+	})
+	first, second := l.Load(1), l.Load(2)
+	if _, err := second(); err == nil {
+		t.Error("no error from a failed fetch")
+	}
+	if _, err := first(); err == nil || calls != 1 {
+		t.Errorf("thunk of the same batch = %v after %d fetches", err, calls)
+	}
+}
+
+func TestExecuteQueryOnly(t *testing.T) {
+	lib := newTestLibrary()
+	s := testSchema(t, lib)
+
+	if data, errs := execute(t, s, lib, "mutation { addBook }", Options{}); len(errs) > 0 || data["addBook"] != true {
+		t.Errorf("mutation: data %v, errors %v", data, errs)
+	}
+	_, errs := execute(t, s, lib, "mutation { addBook }", Options{QueryOnly: true})
+	if len(errs) != 1 || errs[0].Message != "Can only perform a mutation operation from a POST request." {
+		t.Errorf("mutation with QueryOnly: %v", errs)
+	}
+	if data, errs := execute(t, s, lib, "{ books(first: 1) { id } }", Options{QueryOnly: true}); len(errs) > 0 || data == nil {
+		t.Errorf("query with QueryOnly: %v", errs)
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	lib := newTestLibrary()
+	s := testSchema(t, lib)
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"{ books { id ", "Syntax Error"},
+		{"{ books { isbn } }", `Cannot query field "isbn" on type "Book".`},
+		{"query A { books { id } } query B { books { id } }", "Must provide operation name if query contains multiple operations."},
+	}
+	for _, tt := range tests {
+		data, errs := execute(t, s, lib, tt.query, Options{})
+		if data != nil || len(errs) != 1 || !strings.Contains(errs[0].Message, tt.want) {
+			t.Errorf("%q: data %v, errors %v, want %q", tt.query, data, errs, tt.want)
+		}
+	}
+
+	// Errors before execution leave out data; a failed non-null root
+	// field makes it null
+	for query, want := range map[string]string{
+		"{ books { isbn } }": `{"errors":[{"message":"Cannot query field \"isbn\" on type \"Book\".","locations":[{"line":1,"column":11}]}]}`,
+		"{ broken }":         `{"data":null,"errors":[{"message":"database down","locations":[{"line":1,"column":3}],"path":["broken"]}]}`,
+	} {
+		body, err := json.Marshal(Execute(context.Background(), s, &Request{Query: query}, Options{}))
+		if err != nil || string(body) != want {
+			t.Errorf("%q = %s, %v, want %s", query, body, err, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package graphql
+
+import (
+	"html/template"
+	"net/http"
+)
+
+var graphiqlPage = template.Must(template.New("graphiql").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>GraphiQL - Book Management API</title>
+    <style>body { margin: 0; height: 100vh; } #graphiql { height: 100vh; }</style>
+    <link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+    <script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+    <script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+    <script crossorigin src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+</head>
+<body>
+    <div id="graphiql">Loading...</div>
+    <script>
+        const token = localStorage.getItem('book_api_token') || 'YOUR_TOKEN_HERE';
+        const fetcher = GraphiQL.createFetcher({ url: {{.}} });
+        ReactDOM.createRoot(document.getElementById('graphiql')).render(
+            React.createElement(GraphiQL, {
+                fetcher,
+                defaultEditorToolsVisibility: 'headers',
+                defaultHeaders: JSON.stringify({ Authorization: 'Bearer ' + token }, null, 2),
+            })
+        );
+    </script>
+</body>
+</html>
+`))
+
+// GraphiQL serves an in-browser IDE sending queries to endpoint. The
+// Authorization header is prefilled from the token the frontend keeps in
+// local storage.
+func GraphiQL(endpoint string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		graphiqlPage.Execute(w, endpoint)
+	}
+}
@@ -0,0 +1,47 @@
+// Package graphql serves GraphQL requests with graphql-go, adding limits
+// on the size, depth and complexity of queries. Resolvers may return a
+// Thunk, so a Loader can batch the loads of all objects of a list into one
+// query.
+package graphql
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// Config holds the limits of the GraphQL endpoint from environment
+// variables
+type Config struct {
+	MaxDepth      int
+	MaxComplexity int
+	// DevMode serves the GraphiQL IDE
+	DevMode bool
+}
+
+// GetConfig returns GraphQL configuration from environment variables
+func GetConfig() *Config {
+	return &Config{
+		MaxDepth:      getEnvInt("GRAPHQL_MAX_DEPTH", 8),
+		MaxComplexity: getEnvInt("GRAPHQL_MAX_COMPLEXITY", 5000),
+		DevMode:       os.Getenv("GRAPHQL_DEV_MODE") == "true",
+	}
+}
+
+// Options returns the execution options of the configured limits
+func (c *Config) Options() Options {
+	return Options{MaxDepth: c.MaxDepth, MaxComplexity: c.MaxComplexity}
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Printf("Invalid %s %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
@@ -0,0 +1,188 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/lexer"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Limits of the documents Parse accepts. The graphql-go parser is
+// recursive, so without them a query nested thousands of levels deep would
+// be parsed in full before its depth is rejected.
+const (
+	maxDocumentSize = 128 << 10
+	maxNesting      = 64
+)
+
+// Parse parses a query document after checking its size and how deeply it
+// nests selection sets, lists and input objects. Errors are *Error values
+// with the location of the problem.
+func Parse(query string) (*ast.Document, error) {
+	if len(query) > maxDocumentSize {
+		return nil, &Error{Message: fmt.Sprintf("Document is larger than the maximum of %d bytes.", maxDocumentSize)}
+	}
+	src := source.NewSource(&source.Source{Body: []byte(query), Name: "GraphQL request"})
+	if err := checkNesting(src); err != nil {
+		return nil, err
+	}
+	doc, err := parser.Parse(parser.ParseParams{Source: src})
+	if err != nil {
+		return nil, responseErrors(gqlerrors.FormatErrors(err))[0]
+	}
+	return doc, nil
+}
+
+// checkNesting scans the tokens of src for braces and brackets nested more
+// than maxNesting levels deep. Tokens that do not lex are left for the
+// parser to report.
+func checkNesting(src *source.Source) error {
+	next := lexer.Lex(src)
+	depth := 0
+	for {
+		tok, err := next(0)
+		if err != nil || tok.Kind == lexer.EOF {
+			return nil
+		}
+		switch tok.Kind {
+		case lexer.BRACE_L, lexer.BRACKET_L:
+			depth++
+			if depth > maxNesting {
+				return &Error{
+					Message:   fmt.Sprintf("Document is nested more than %d levels deep.", maxNesting),
+					Locations: []Location{locationAt(src, tok.Start)},
+				}
+			}
+		case lexer.BRACE_R, lexer.BRACKET_R:
+			depth--
+		}
+	}
+}
+
+func locationAt(src *source.Source, position int) Location {
+	l := location.GetLocation(src, position)
+	return Location{Line: l.Line, Column: l.Column}
+}
+
+// measurer walks the selections of a validated operation
+type measurer struct {
+	schema    *Schema
+	fragments map[string]*ast.FragmentDefinition
+	vars      map[string]interface{}
+	depth     int
+}
+
+// measure returns the depth and complexity of op, whose root type is root.
+// Introspection fields count towards neither. A field with a first
+// argument, which bounds the length of the list it returns, costs 1 plus
+// first times the cost of its selections; any other field costs 1 plus
+// the cost of its selections.
+func measure(schema *Schema, doc *ast.Document, op *ast.OperationDefinition, root *gql.Object, variables map[string]interface{}) (depth, complexity int) {
+	m := &measurer{schema: schema, fragments: map[string]*ast.FragmentDefinition{}, vars: map[string]interface{}{}}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			m.fragments[f.Name.Value] = f
+		}
+	}
+	for _, v := range op.VariableDefinitions {
+		if v.DefaultValue != nil {
+			m.vars[v.Variable.Name.Value] = v.DefaultValue.GetValue()
+		}
+	}
+	for name, value := range variables {
+		m.vars[name] = value
+	}
+	complexity = m.selections(root, op.SelectionSet, 1)
+	return m.depth, complexity
+}
+
+// selections returns the complexity of a selection set on t at depth
+func (m *measurer) selections(t gql.Named, set *ast.SelectionSet, depth int) int {
+	if set == nil {
+		return 0
+	}
+	var fields gql.FieldDefinitionMap
+	switch t := t.(type) {
+	case *gql.Object:
+		fields = t.Fields()
+	case *gql.Interface:
+		fields = t.Fields()
+	}
+
+	total := 0
+	for _, sel := range set.Selections {
+		switch s := sel.(type) {
+		case *ast.Field:
+			def := fields[s.Name.Value]
+			if def == nil || strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			if depth > m.depth {
+				m.depth = depth
+			}
+			child := m.selections(gql.GetNamed(def.Type), s.SelectionSet, depth+1)
+			if first, ok := m.first(def, s); ok {
+				total += 1 + max(first, 1)*child
+			} else {
+				total += 1 + child
+			}
+		case *ast.InlineFragment:
+			var on gql.Named = t
+			if s.TypeCondition != nil {
+				on = m.schema.Type(s.TypeCondition.Name.Value)
+			}
+			total += m.selections(on, s.SelectionSet, depth)
+		case *ast.FragmentSpread:
+			if f := m.fragments[s.Name.Value]; f != nil {
+				total += m.selections(m.schema.Type(f.TypeCondition.Name.Value), f.SelectionSet, depth)
+			}
+		}
+	}
+	return total
+}
+
+// first returns the first argument of field, and whether def has one
+func (m *measurer) first(def *gql.FieldDefinition, field *ast.Field) (int, bool) {
+	var value interface{}
+	found := false
+	for _, arg := range def.Args {
+		if arg.Name() == "first" {
+			value, found = arg.DefaultValue, true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		value = arg.Value.GetValue()
+		if v, ok := arg.Value.(*ast.Variable); ok {
+			value = m.vars[v.Name.Value]
+		}
+	}
+
+	switch n := value.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), true
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i), true
+	case string:
+		// An Int literal
+		i, _ := strconv.Atoi(n)
+		return i, true
+	}
+	return 0, true
+}
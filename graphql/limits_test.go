@@ -0,0 +1,45 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+// nested wraps inner in levels of open and close
+func nested(levels int, open, inner, close string) string {
+	return strings.Repeat(open, levels) + inner + strings.Repeat(close, levels)
+}
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		ok    bool
+	}{
+		{"selections at the limit", nested(maxNesting, "{a", "", "}"), true},
+		{"selections", nested(maxNesting+1, "{a", "", "}"), false},
+		{"inline fragments", "{" + nested(maxNesting, "... on Q {", "a", "}") + "}", false},
+		// Arguments are inside the operation's selection set
+		{"lists at the limit", "{ a(x: " + nested(maxNesting-1, "[", "1", "]") + ") }", true},
+		{"lists", "{ a(x: " + nested(maxNesting, "[", "1", "]") + ") }", false},
+		{"objects", "{ a(x: " + nested(maxNesting, "{a: ", "1", "}") + ") }", false},
+		{"variable type at the limit", "query($x: " + nested(maxNesting, "[", "Int", "]") + ") { a }", true},
+		{"variable type", "query($x: " + nested(maxNesting+1, "[", "Int", "]") + ") { a }", false},
+		// Braces in strings and comments are not counted
+		{"strings", `{ a(x: "` + strings.Repeat("{", 2*maxNesting) + `") }`, true},
+		{"comments", "{ a }\n# " + strings.Repeat("[", 2*maxNesting), true},
+		{"size at the limit", "{ a }" + strings.Repeat(" ", maxDocumentSize-5), true},
+		{"size", "{ a }" + strings.Repeat(" ", maxDocumentSize-4), false},
+		// Refused before any of it is parsed
+		{"nested 200k levels", nested(200000, "{a", "", "}"), false},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if tt.ok && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if !tt.ok && (err == nil || !strings.Contains(err.Error(), "Document is")) {
+			t.Errorf("%s: Parse = %v, want a limit error", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package graphql
+
+import "sync"
+
+// Loader batches and caches loads by key within one request. Load
+// registers a key and returns a Thunk; when the first of the thunks is
+// forced, the keys of all thunks not yet fetched are passed to fetch
+// together, which avoids one query per object of a list.
+type Loader[K comparable, V any] struct {
+	fetch   func(keys []K) (map[K]V, error)
+	mu      sync.Mutex
+	pending []K
+	results map[K]*loaderResult[V]
+}
+
+type loaderResult[V any] struct {
+	done  bool
+	value V
+	err   error
+}
+
+// NewLoader creates a loader around fetch, which returns the values found
+// for keys. Keys missing from its result load as the zero value of V.
+func NewLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, results: map[K]*loaderResult[V]{}}
+}
+
+// Load returns a thunk resolving to the value of key
+func (l *Loader[K, V]) Load(key K) Thunk {
+	l.mu.Lock()
+	if _, ok := l.results[key]; !ok {
+		l.results[key] = &loaderResult[V]{}
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		value, err := l.get(key)
+		return value, err
+	}
+}
+
+// get returns the value of key, fetching all pending keys if it has not
+// been fetched yet
+func (l *Loader[K, V]) get(key K) (V, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := l.results[key]
+	if !result.done {
+		keys := l.pending
+		l.pending = nil
+		values, err := l.fetch(keys)
+		for _, k := range keys {
+			r := l.results[k]
+			r.done = true
+			r.value, r.err = values[k], err
+		}
+	}
+	return result.value, result.err
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"rest-api-golang/mailer"
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 24 * time.Hour
+	// accountEmailInterval throttles repeated emails of the same kind
+	accountEmailInterval = time.Minute
+)
+
+// Mailer used for account emails
+var appMailer mailer.Mailer
+var mailFrom string
+
+// InitializeMailer sets the mailer and sender address for account emails
+func InitializeMailer(m mailer.Mailer, from string) {
+	appMailer = m
+	mailFrom = from
+}
+
+// appBaseURL is the public URL that emailed links point to
+func appBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// sendAccountEmail issues a token of the given purpose for user and emails
+// a link containing it. Sending happens in the background so response
+// times do not reveal whether an account exists.
+func sendAccountEmail(user *models.User, purpose, template, path string, ttl time.Duration) error {
+	last, err := accountTokenRepo.LastTokenCreatedAt(user.ID, purpose)
+	if err != nil {
+		return err
+	}
+	if last != nil && time.Since(*last) < accountEmailInterval {
+		return nil
+	}
+
+	tokenValue, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	token := &models.AccountToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(tokenValue),
+		Email:     user.Email,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := accountTokenRepo.CreateToken(token); err != nil {
+		return err
+	}
+
+	msg, err := mailer.Render(template, map[string]interface{}{
+		"Username":  user.Username,
+		"Email":     user.Email,
+		"URL":       appBaseURL() + path + "?token=" + url.QueryEscape(tokenValue),
+		"ExpiresIn": humanDuration(ttl),
+	})
+	if err != nil {
+		return err
+	}
+	msg.From = mailFrom
+	msg.To = []string{user.Email}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := appMailer.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send %s email to user %s: %v", template, user.ID, err)
+		}
+	}()
+	return nil
+}
+
+func humanDuration(d time.Duration) string {
+	if d >= time.Hour {
+		hours := int(d.Hours())
+		if hours == 1 {
+			return "1 hour"
+		}
+		return strconv.Itoa(hours) + " hours"
+	}
+	return strconv.Itoa(int(d.Minutes())) + " minutes"
+}
+
+// ForgotPassword handles POST /api/password/forgot. The response is the
+// same whether or not the email belongs to an account.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+	if strings.TrimSpace(req.Email) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Email is required",
+		})
+		return
+	}
+
+	if user, err := userRepo.GetUserByEmail(strings.TrimSpace(req.Email)); err == nil {
+		err := sendAccountEmail(user, models.TokenPurposePasswordReset, "password_reset", "/reset-password", passwordResetTTL)
+		if err != nil {
+			log.Printf("Failed to issue password reset for user %s: %v", user.ID, err)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "If the email belongs to an account, a password reset link has been sent",
+	})
+}
+
+// ResetPassword handles POST /api/password/reset. All existing sessions of
+// the user are revoked.
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+	if msg := models.PasswordError(req.Password); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	if _, err := accountTokenRepo.WithAudit(auditContext(r)).ResetPassword(hashToken(req.Token), req.Password); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Reset token is invalid or expired",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Password has been reset. Please login again.",
+	})
+}
+
+// RequestEmailVerification handles POST /api/email/verify/request and
+// emails a verification link to the current user's address
+func RequestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	if user.Email == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Account has no email address",
+		})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Email is already verified",
+		})
+		return
+	}
+
+	err := sendAccountEmail(user, models.TokenPurposeEmailVerification, "verify_email", "/verify-email", emailVerificationTTL)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to send verification email",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Verification email sent",
+	})
+}
+
+// VerifyEmail handles POST /api/email/verify with the token from the link
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	if _, err := accountTokenRepo.VerifyEmail(hashToken(req.Token)); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Verification token is invalid or expired",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Email verified successfully",
+	})
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api-golang/database"
+	"rest-api-golang/mailer"
+	"rest-api-golang/models"
+)
+
+var emailedToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// waitForMail returns the token in the n-th message of the outbox, which
+// is sent in the background
+func waitForMail(t *testing.T, outbox *mailer.MemoryMailer, n int, to string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(outbox.Messages()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d emails, want %d", len(outbox.Messages()), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	msg := outbox.Messages()[n-1]
+	if len(msg.To) != 1 || msg.To[0] != to {
+		t.Fatalf("email sent to %v, want %s", msg.To, to)
+	}
+	m := emailedToken.FindStringSubmatch(msg.TextBody)
+	if m == nil {
+		t.Fatalf("email has no link with a token: %q", msg.TextBody)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// expireAccountTokens backdates the emailed tokens of a user
+func expireAccountTokens(t *testing.T, username string) {
+	t.Helper()
+	_, err := database.DB.Exec(`
+		UPDATE account_tokens SET expires_at = NOW() - INTERVAL '1 second'
+		WHERE user_id = (SELECT id FROM users WHERE username = $1)`, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	srv := newTestServer(t)
+	session := srv.login(t, "user", "user123")
+
+	forgot := models.ForgotPasswordRequest{Email: "user@example.com"}
+	if status := srv.do(t, "POST", "/api/password/forgot", "", forgot, nil); status != http.StatusOK {
+		t.Fatalf("forgot: status %d", status)
+	}
+	token := waitForMail(t, srv.mail, 1, "user@example.com")
+
+	// Asking again right away does not send another email
+	srv.do(t, "POST", "/api/password/forgot", "", forgot, nil)
+	// Unknown addresses get the same answer and no email
+	if status := srv.do(t, "POST", "/api/password/forgot", "", models.ForgotPasswordRequest{Email: "nobody@example.com"}, nil); status != http.StatusOK {
+		t.Errorf("forgot for an unknown email: status %d", status)
+	}
+
+	if status := srv.do(t, "POST", "/api/password/reset", "", models.ResetPasswordRequest{Token: token, Password: "short"}, nil); status != http.StatusBadRequest {
+		t.Errorf("short password: status %d", status)
+	}
+	if status := srv.do(t, "POST", "/api/password/reset", "", models.ResetPasswordRequest{Token: token, Password: strings.Repeat("x", 73)}, nil); status != http.StatusBadRequest {
+		t.Errorf("password longer than 72 bytes: status %d", status)
+	}
+	if status := srv.do(t, "POST", "/api/password/reset", "", models.ResetPasswordRequest{Token: "wrong", Password: "new-password"}, nil); status != http.StatusBadRequest {
+		t.Errorf("unknown token: status %d", status)
+	}
+	if status := srv.do(t, "POST", "/api/password/reset", "", models.ResetPasswordRequest{Token: token, Password: "new-password"}, nil); status != http.StatusOK {
+		t.Fatalf("reset: status %d", status)
+	}
+
+	// The token works once
+	if status := srv.do(t, "POST", "/api/password/reset", "", models.ResetPasswordRequest{Token: token, Password: "other-password"}, nil); status != http.StatusBadRequest {
+		t.Errorf("reused token: status %d", status)
+	}
+	// Sessions from before the reset are revoked
+	if status := srv.do(t, "GET", "/api/books", session, nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("old session after the reset: status %d", status)
+	}
+	if status := srv.do(t, "POST", "/api/login", "", LoginRequest{Username: "user", Password: "user123"}, nil); status != http.StatusUnauthorized {
+		t.Errorf("login with the old password: status %d", status)
+	}
+	srv.login(t, "user", "new-password")
+
+	var stored string
+	if err := database.DB.QueryRow(`SELECT password FROM users WHERE username = 'user'`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored, "$2a$") {
+		t.Errorf("the new password is stored as %q, not as a bcrypt hash", stored)
+	}
+
+	if n := len(srv.mail.Messages()); n != 1 {
+		t.Errorf("sent %d emails, want 1", n)
+	}
+}
+
+func TestPasswordResetTokenExpires(t *testing.T) {
+	srv := newTestServer(t)
+
+	srv.do(t, "POST", "/api/password/forgot", "", models.ForgotPasswordRequest{Email: "user@example.com"}, nil)
+	token := waitForMail(t, srv.mail, 1, "user@example.com")
+	expireAccountTokens(t, "user")
+
+	if status := srv.do(t, "POST", "/api/password/reset", "", models.ResetPasswordRequest{Token: token, Password: "new-password"}, nil); status != http.StatusBadRequest {
+		t.Errorf("expired token: status %d", status)
+	}
+	srv.login(t, "user", "user123")
+}
+
+func TestEmailVerification(t *testing.T) {
+	srv := newTestServer(t)
+	session := srv.login(t, "user", "user123")
+
+	if status := srv.do(t, "POST", "/api/email/verify/request", session, nil, nil); status != http.StatusOK {
+		t.Fatalf("request verification: status %d", status)
+	}
+	token := waitForMail(t, srv.mail, 1, "user@example.com")
+
+	if status := srv.do(t, "POST", "/api/email/verify", "", models.VerifyEmailRequest{Token: "wrong"}, nil); status != http.StatusBadRequest {
+		t.Errorf("unknown token: status %d", status)
+	}
+	if status := srv.do(t, "POST", "/api/email/verify", "", models.VerifyEmailRequest{Token: token}, nil); status != http.StatusOK {
+		t.Fatalf("verify: status %d", status)
+	}
+	if status := srv.do(t, "POST", "/api/email/verify", "", models.VerifyEmailRequest{Token: token}, nil); status != http.StatusBadRequest {
+		t.Errorf("reused token: status %d", status)
+	}
+
+	user, err := userRepo.GetUserByUsername("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("email_verified_at is not set")
+	}
+
+	// A verified address needs no new email
+	var resp struct {
+		Message string `json:"message"`
+	}
+	srv.do(t, "POST", "/api/email/verify/request", session, nil, &resp)
+	if resp.Message != "Email is already verified" {
+		t.Errorf("second request: %q", resp.Message)
+	}
+}
+
+func TestEmailVerificationTokenExpires(t *testing.T) {
+	srv := newTestServer(t)
+	session := srv.login(t, "admin", "admin123")
+
+	srv.do(t, "POST", "/api/email/verify/request", session, nil, nil)
+	token := waitForMail(t, srv.mail, 1, "admin@example.com")
+	expireAccountTokens(t, "admin")
+
+	if status := srv.do(t, "POST", "/api/email/verify", "", models.VerifyEmailRequest{Token: token}, nil); status != http.StatusBadRequest {
+		t.Errorf("expired token: status %d", status)
+	}
+	user, err := userRepo.GetUserByUsername("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerifiedAt != nil {
+		t.Error("expired token verified the email")
+	}
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultAuditLimit is the page size of GET /api/audit without ?limit=
+	defaultAuditLimit = 100
+	// maxAuditLimit is the largest page size ?limit= may ask for
+	maxAuditLimit = 1000
+)
+
+const requestIDContextKey contextKey = "request_id"
+
+// auditActions and auditEntities are the values the audit filters accept
+var (
+	auditActions = []string{
+		models.AuditActionCreate, models.AuditActionUpdate, models.AuditActionDelete, models.AuditActionRestore,
+		models.AuditActionLogin, models.AuditActionLogout, models.AuditActionLoginFailed,
+	}
+	auditEntities = []string{
+		models.AuditEntityBook, models.AuditEntityAuthor, models.AuditEntityCategory, models.AuditEntityCopy,
+		models.AuditEntityLoan, models.AuditEntityLoanPolicy, models.AuditEntityHold, models.AuditEntityReview,
+		models.AuditEntityReadingList, models.AuditEntityAccount, models.AuditEntityUser, models.AuditEntityMFA,
+		models.AuditEntitySetting, models.AuditEntitySession, models.AuditEntityWebhook,
+	}
+)
+
+// RequestIDMiddleware gives every request an ID, echoed in the X-Request-ID
+// response header and recorded with its audit events. A client may pass
+// its own ID of up to 128 letters, digits, '-', '_' and '.' in the
+// X-Request-ID header; anything else is replaced by a new UUID.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID reports whether a client supplied request ID can be kept
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only
+// trusted when TRUST_PROXY=true, since clients can set it to anything.
+func clientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY") == "true" {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); net.ParseIP(ip) != nil {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditContext describes the request for the audit events of the changes it
+// makes: the signed-in user, the request ID, the client IP and user agent
+func auditContext(r *http.Request) *models.AuditContext {
+	a := &models.AuditContext{
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	if len(a.UserAgent) > 512 {
+		a.UserAgent = a.UserAgent[:512]
+	}
+	if user := currentUser(r); user != nil {
+		a.ActorID = user.ID
+	}
+	a.RequestID, _ = r.Context().Value(requestIDContextKey).(string)
+	return a
+}
+
+// loginAuditContext is the audit context of a sign-in by user, who is not
+// yet the current user of the request
+func loginAuditContext(r *http.Request, user *models.User) *models.AuditContext {
+	a := auditContext(r)
+	a.ActorID = user.ID
+	return a
+}
+
+// recordLoginFailure records a failed sign-in as username, whose user ID is
+// given when the account exists
+func recordLoginFailure(r *http.Request, username, userID, reason string) {
+	if len(username) > 255 {
+		username = username[:255]
+	}
+	err := auditRepo.RecordEvent(auditContext(r), models.AuditActionLoginFailed, models.AuditEntityUser, userID,
+		map[string]string{"username": username, "reason": reason})
+	if err != nil {
+		log.Printf("Failed to record failed login: %v", err)
+	}
+}
+
+// writeAuditError writes a JSON error response
+func writeAuditError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// parseAuditFilter reads the audit log filters from the query string. It
+// returns an error message, or "" if the filters are valid.
+func parseAuditFilter(r *http.Request) (models.AuditFilter, string) {
+	q := r.URL.Query()
+	filter := models.AuditFilter{
+		ActorID:    q.Get("actor_id"),
+		Action:     q.Get("action"),
+		EntityType: q.Get("entity_type"),
+		EntityID:   q.Get("entity_id"),
+		Before:     q.Get("before"),
+		Limit:      defaultAuditLimit,
+	}
+
+	for name, id := range map[string]string{"actor_id": filter.ActorID, "before": filter.Before} {
+		if _, err := uuid.Parse(id); id != "" && err != nil {
+			return filter, "Invalid " + name + ", expected a UUID"
+		}
+	}
+	if filter.Action != "" && !isOneOf(filter.Action, auditActions) {
+		return filter, "Invalid action filter, expected one of: " + strings.Join(auditActions, ", ")
+	}
+	if filter.EntityType != "" && !isOneOf(filter.EntityType, auditEntities) {
+		return filter, "Invalid entity_type filter, expected one of: " + strings.Join(auditEntities, ", ")
+	}
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, "Invalid " + name + " filter, expected an RFC 3339 time"
+			}
+			*target = &t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			return filter, "Invalid limit, expected an integer from 1 to " + strconv.Itoa(maxAuditLimit)
+		}
+		filter.Limit = n
+	}
+
+	return filter, ""
+}
+
+// GetAuditEvents handles GET /api/audit (admin only): the audit log, newest
+// first, filtered by ?actor_id=, ?action=, ?entity_type=, ?entity_id= and
+// the RFC 3339 range ?from= and ?to=. Pages hold ?limit= events (100 by
+// default); the next page is ?before= the ID of the last event, which the
+// response gives as next_before.
+func GetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	filter, msg := parseAuditFilter(r)
+	if msg != "" {
+		writeAuditError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	events, err := auditRepo.ListAuditEvents(filter)
+	if err != nil {
+		log.Printf("Failed to list audit events: %v", err)
+		writeAuditError(w, http.StatusInternalServerError, "Failed to retrieve audit events")
+		return
+	}
+	if events == nil {
+		events = []*models.AuditEvent{}
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Audit events retrieved successfully",
+		"data":    events,
+		"count":   len(events),
+	}
+	if len(events) == filter.Limit {
+		response["next_before"] = events[len(events)-1].ID
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// writeAuthorError maps author repository errors to a response
+func writeAuthorError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Author not found",
+		})
+	case errors.Is(err, repositories.ErrDuplicate):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "An author with this name already exists",
+		})
+	case errors.Is(err, repositories.ErrInUse):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Author is still credited on books",
+		})
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": fallback,
+		})
+	}
+}
+
+// GetAuthors handles GET /api/authors; ?q= filters by name
+func GetAuthors(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authors, err := authorRepo.ListAuthors(strings.TrimSpace(r.URL.Query().Get("q")))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to fetch authors",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    authors,
+		"count":   len(authors),
+	})
+}
+
+// GetAuthor handles GET /api/authors/{id}
+func GetAuthor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	author, err := authorRepo.GetAuthorByID(mux.Vars(r)["id"])
+	if err != nil {
+		writeAuthorError(w, err, "Failed to fetch author")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    author,
+	})
+}
+
+// GetAuthorBooks handles GET /api/authors/{id}/books. The book list
+// filters apply as on GET /api/books.
+func GetAuthorBooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	author, err := authorRepo.GetAuthorByID(mux.Vars(r)["id"])
+	if err != nil {
+		writeAuthorError(w, err, "Failed to fetch author")
+		return
+	}
+
+	filter, msg := parseBookFilter(r)
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+	filter.AuthorID = author.ID
+
+	books, err := bookRepo.ListBooks(filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to fetch books",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    books,
+		"count":   len(books),
+	})
+}
+
+// CreateAuthor handles POST /api/authors
+func CreateAuthor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateAuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	name := strings.Join(strings.Fields(req.Name), " ")
+	if name == "" || len(name) > 255 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Name is required and must be at most 255 characters",
+		})
+		return
+	}
+
+	now := time.Now()
+	author := &models.Author{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Bio:       strings.TrimSpace(req.Bio),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := authorRepo.WithAudit(auditContext(r)).CreateAuthor(author); err != nil {
+		writeAuthorError(w, err, "Failed to create author")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Author created successfully",
+		"data":    author,
+	})
+}
+
+// UpdateAuthor handles PUT /api/authors/{id}. Renaming an author updates
+// the flat author string of their books.
+func UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	author, err := authorRepo.GetAuthorByID(mux.Vars(r)["id"])
+	if err != nil {
+		writeAuthorError(w, err, "Failed to fetch author")
+		return
+	}
+
+	var req models.UpdateAuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	if name := strings.Join(strings.Fields(req.Name), " "); name != "" {
+		if len(name) > 255 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Name must be at most 255 characters",
+			})
+			return
+		}
+		author.Name = name
+	}
+	if req.Bio != "" {
+		author.Bio = strings.TrimSpace(req.Bio)
+	}
+	author.UpdatedAt = time.Now()
+
+	if err := authorRepo.WithAudit(auditContext(r)).UpdateAuthor(author); err != nil {
+		writeAuthorError(w, err, "Failed to update author")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Author updated successfully",
+		"data":    author,
+	})
+}
+
+// DeleteAuthor handles DELETE /api/authors/{id}. Authors credited on any
+// book, including soft-deleted ones, cannot be deleted.
+func DeleteAuthor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	author, err := authorRepo.GetAuthorByID(mux.Vars(r)["id"])
+	if err != nil {
+		writeAuthorError(w, err, "Failed to fetch author")
+		return
+	}
+
+	if err := authorRepo.WithAudit(auditContext(r)).DeleteAuthor(author.ID); err != nil {
+		writeAuthorError(w, err, "Failed to delete author")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Author deleted successfully",
+		"data":    author,
+	})
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+)
+
+// defaultBookBatchMax is the largest batch when BOOK_BATCH_MAX is unset
+const defaultBookBatchMax = 100
+
+// writeBatchError writes a JSON error response
+func writeBatchError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// prepareBatchOperation validates one operation like the single-book
+// endpoint would, loading the book an update or delete refers to. It
+// returns the operation to run and, for updates, the function restoring
+// unchanged relations after saving; or the status and message of the
+// failure.
+func prepareBatchOperation(op models.BatchOperation) (repositories.BookOperation, func(), int, string) {
+	prepared := repositories.BookOperation{Op: op.Op}
+	restore := func() {}
+
+	switch op.Op {
+	case models.BatchOpCreate:
+		var req models.CreateBookRequest
+		if len(op.Book) > 0 {
+			if err := json.Unmarshal(op.Book, &req); err != nil {
+				return prepared, restore, http.StatusBadRequest, "Invalid JSON format"
+			}
+		}
+		book, msg := newBookFromRequest(req)
+		if msg != "" {
+			return prepared, restore, http.StatusBadRequest, msg
+		}
+		prepared.Book = book
+		return prepared, restore, 0, ""
+
+	case models.BatchOpUpdate, models.BatchOpDelete:
+		if op.ID == "" {
+			return prepared, restore, http.StatusBadRequest, "An id is required for " + op.Op
+		}
+		book, err := bookRepo.GetBookByID(op.ID)
+		if err != nil {
+			return prepared, restore, http.StatusNotFound, "Book not found"
+		}
+		prepared.Book = book
+		if op.Op == models.BatchOpDelete {
+			return prepared, restore, 0, ""
+		}
+
+		var req models.UpdateBookRequest
+		if len(op.Book) > 0 {
+			if err := json.Unmarshal(op.Book, &req); err != nil {
+				return prepared, restore, http.StatusBadRequest, "Invalid JSON format"
+			}
+		}
+		restore, msg := applyBookUpdate(book, req)
+		if msg != "" {
+			return prepared, restore, http.StatusBadRequest, msg
+		}
+		return prepared, restore, 0, ""
+	}
+
+	return prepared, restore, http.StatusBadRequest, "Invalid op, expected one of: " + strings.Join(models.BatchOps, ", ")
+}
+
+// batchSaveError returns the status and message for an operation the
+// repository failed to apply
+func batchSaveError(op string, err error) (int, string) {
+	switch op {
+	case models.BatchOpCreate:
+		return bookSaveError(err, "Failed to create book")
+	case models.BatchOpDelete:
+		if errors.Is(err, repositories.ErrNotFound) {
+			return http.StatusNotFound, "Book not found"
+		}
+		return http.StatusInternalServerError, "Failed to delete book"
+	}
+	return bookSaveError(err, "Failed to update book")
+}
+
+// batchSuccess fills in the result of an applied operation, as the
+// single-book endpoint would have responded
+func batchSuccess(result *models.BatchResult, book *models.Book) {
+	result.Success = true
+	result.ID = book.ID
+	result.Data = book
+	switch result.Op {
+	case models.BatchOpCreate:
+		result.Status, result.Message = http.StatusCreated, "Book created successfully"
+	case models.BatchOpUpdate:
+		result.Status, result.Message = http.StatusOK, "Book updated successfully"
+	case models.BatchOpDelete:
+		result.Status, result.Message = http.StatusOK, "Book deleted successfully"
+	}
+}
+
+// BatchBooks handles POST /api/books/batch: a list of create, update and
+// delete operations validated like POST, PUT and DELETE /api/books. In the
+// default "atomic" mode the operations run in one transaction and any
+// failure rolls them all back; in "best_effort" mode every operation that
+// succeeds is kept. The response has a result per operation, in order.
+// BOOK_BATCH_MAX bounds the number of operations.
+func BatchBooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBatchError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = models.BatchModeAtomic
+	}
+	if !isOneOf(req.Mode, models.BatchModes) {
+		writeBatchError(w, http.StatusBadRequest, "Invalid mode, expected one of: "+strings.Join(models.BatchModes, ", "))
+		return
+	}
+	if len(req.Operations) == 0 {
+		writeBatchError(w, http.StatusBadRequest, "Batch has no operations")
+		return
+	}
+	if limit := importLimit("BOOK_BATCH_MAX", defaultBookBatchMax); int64(len(req.Operations)) > limit {
+		writeBatchError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Batch must have at most %d operations", limit))
+		return
+	}
+	atomic := req.Mode == models.BatchModeAtomic
+
+	results := make([]models.BatchResult, len(req.Operations))
+	var ops []repositories.BookOperation
+	var indexes []int
+	var restores []func()
+	seen := map[string]int{}
+	failed := -1
+	for i, op := range req.Operations {
+		results[i] = models.BatchResult{Index: i, Op: op.Op, ID: op.ID}
+		// An update or delete works on the book as loaded before the batch,
+		// so a second change to the same book would undo the first
+		if op.ID != "" {
+			if first, ok := seen[op.ID]; ok {
+				results[i].Status = http.StatusBadRequest
+				results[i].Message = fmt.Sprintf("Book is already changed by operation %d", first)
+				if failed < 0 {
+					failed = i
+				}
+				continue
+			}
+			seen[op.ID] = i
+		}
+
+		prepared, restore, status, msg := prepareBatchOperation(op)
+		if msg != "" {
+			results[i].Status, results[i].Message = status, msg
+			if failed < 0 {
+				failed = i
+			}
+			continue
+		}
+		ops = append(ops, prepared)
+		indexes = append(indexes, i)
+		restores = append(restores, restore)
+	}
+
+	var errs []error
+	if !atomic || failed < 0 {
+		var err error
+		errs, err = bookRepo.WithAudit(auditContext(r)).ApplyBookBatch(ops, atomic)
+		if err != nil {
+			log.Printf("Failed to apply book batch: %v", err)
+			writeBatchError(w, http.StatusInternalServerError, "Failed to apply batch")
+			return
+		}
+	}
+
+	succeeded := 0
+	// errs is nil when an atomic batch failed validation and never ran
+	for j, i := range indexes {
+		if errs == nil {
+			break
+		}
+		if errs[j] != nil {
+			results[i].Status, results[i].Message = batchSaveError(results[i].Op, errs[j])
+			if atomic {
+				// The operations after it were not run
+				failed = i
+				break
+			}
+			continue
+		}
+		restores[j]()
+		batchSuccess(&results[i], ops[j].Book)
+		succeeded++
+	}
+
+	if atomic && failed >= 0 {
+		// Nothing was committed: operations that did not fail themselves
+		// are reported as not applied
+		for i := range results {
+			if i != failed && (results[i].Status == 0 || results[i].Success) {
+				results[i] = models.BatchResult{Index: i, Op: results[i].Op, ID: req.Operations[i].ID,
+					Status: http.StatusFailedDependency, Message: "Not applied because the batch was rolled back"}
+			}
+		}
+		w.WriteHeader(results[failed].Status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Batch rolled back: operation %d failed: %s", failed, results[failed].Message),
+			"data":    results,
+			"count":   len(results),
+		})
+		return
+	}
+
+	message := fmt.Sprintf("Batch applied: %d operations", succeeded)
+	if !atomic {
+		message = fmt.Sprintf("Batch applied: %d succeeded, %d failed", succeeded, len(results)-succeeded)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": message,
+		"data":    results,
+		"count":   len(results),
+	})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+// batchResponse is the body of POST /api/books/batch
+type batchResponse struct {
+	Success bool                 `json:"success"`
+	Message string               `json:"message"`
+	Data    []models.BatchResult `json:"data"`
+}
+
+func batchOp(op, id string, book interface{}) models.BatchOperation {
+	o := models.BatchOperation{Op: op, ID: id}
+	if book != nil {
+		o.Book, _ = json.Marshal(book)
+	}
+	return o
+}
+
+// statuses lists the status of each result
+func (b *batchResponse) statuses() []int {
+	var statuses []int
+	for _, r := range b.Data {
+		statuses = append(statuses, r.Status)
+	}
+	return statuses
+}
+
+func TestBatchAtomic(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+	kept := srv.createBook(t, admin, models.CreateBookRequest{Judul: "Kept", Author: "Penulis", TahunTerbit: 2000, ISBN: "9780306406157"})
+	removed := srv.createBook(t, admin, models.CreateBookRequest{Judul: "Removed", Author: "Penulis", TahunTerbit: 2000})
+	newBook := models.CreateBookRequest{Judul: "New", Author: "Penulis", TahunTerbit: 2010}
+
+	tests := []struct {
+		name     string
+		ops      []models.BatchOperation
+		status   int
+		statuses []int
+	}{
+		{
+			name: "validation failure",
+			ops: []models.BatchOperation{
+				batchOp(models.BatchOpCreate, "", newBook),
+				batchOp(models.BatchOpCreate, "", models.CreateBookRequest{Author: "No Title", TahunTerbit: 2000}),
+				batchOp(models.BatchOpDelete, removed.ID, nil),
+			},
+			status:   http.StatusBadRequest,
+			statuses: []int{http.StatusFailedDependency, http.StatusBadRequest, http.StatusFailedDependency},
+		},
+		{
+			name: "failure while saving",
+			ops: []models.BatchOperation{
+				batchOp(models.BatchOpCreate, "", newBook),
+				batchOp(models.BatchOpCreate, "", models.CreateBookRequest{Judul: "Same ISBN", Author: "Penulis", TahunTerbit: 2000, ISBN: "0-306-40615-2"}),
+				batchOp(models.BatchOpDelete, removed.ID, nil),
+			},
+			status:   http.StatusConflict,
+			statuses: []int{http.StatusFailedDependency, http.StatusConflict, http.StatusFailedDependency},
+		},
+		{
+			name: "same book twice",
+			ops: []models.BatchOperation{
+				batchOp(models.BatchOpUpdate, kept.ID, models.UpdateBookRequest{Judul: "First"}),
+				batchOp(models.BatchOpDelete, kept.ID, nil),
+			},
+			status:   http.StatusBadRequest,
+			statuses: []int{http.StatusFailedDependency, http.StatusBadRequest},
+		},
+		{
+			name: "unknown book",
+			ops: []models.BatchOperation{
+				batchOp(models.BatchOpCreate, "", newBook),
+				batchOp(models.BatchOpUpdate, uuid.New().String(), models.UpdateBookRequest{Judul: "Missing"}),
+			},
+			status:   http.StatusNotFound,
+			statuses: []int{http.StatusFailedDependency, http.StatusNotFound},
+		},
+	}
+	for _, tt := range tests {
+		var resp batchResponse
+		status := srv.do(t, "POST", "/api/books/batch", admin, models.BatchRequest{Operations: tt.ops}, &resp)
+		if status != tt.status || resp.Success || !slices.Equal(resp.statuses(), tt.statuses) {
+			t.Errorf("%s: status %d, results %v, want %d, %v", tt.name, status, resp.statuses(), tt.status, tt.statuses)
+		}
+		for _, r := range resp.Data {
+			if r.Success || r.Data != nil {
+				t.Errorf("%s: operation %d reported as applied", tt.name, r.Index)
+			}
+		}
+		// Nothing of a failed batch is kept
+		if got := strings.Join(srv.bookTitles(t, admin, "/api/books"), ","); got != "Kept,Removed" {
+			t.Fatalf("%s: books after rollback: %s", tt.name, got)
+		}
+	}
+
+	var resp batchResponse
+	ops := []models.BatchOperation{
+		batchOp(models.BatchOpCreate, "", newBook),
+		batchOp(models.BatchOpUpdate, kept.ID, models.UpdateBookRequest{Judul: "Kept, renamed"}),
+		batchOp(models.BatchOpDelete, removed.ID, nil),
+	}
+	if status := srv.do(t, "POST", "/api/books/batch", admin, models.BatchRequest{Mode: models.BatchModeAtomic, Operations: ops}, &resp); status != http.StatusOK || !resp.Success {
+		t.Fatalf("batch: status %d, %s", status, resp.Message)
+	}
+	if want := []int{http.StatusCreated, http.StatusOK, http.StatusOK}; !slices.Equal(resp.statuses(), want) {
+		t.Errorf("results %v, want %v", resp.statuses(), want)
+	}
+	if resp.Data[0].ID == "" || resp.Data[0].Data == nil || resp.Data[0].Data.Judul != "New" {
+		t.Errorf("create result %+v", resp.Data[0])
+	}
+	if resp.Data[1].Data == nil || resp.Data[1].Data.ISBN != kept.ISBN {
+		t.Errorf("update result lost fields it did not change: %+v", resp.Data[1].Data)
+	}
+	if got := strings.Join(srv.bookTitles(t, admin, "/api/books"), ","); got != "Kept, renamed,New" {
+		t.Errorf("books after the batch: %s", got)
+	}
+}
+
+func TestBatchBestEffort(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+	existing := srv.createBook(t, admin, models.CreateBookRequest{Judul: "Existing", Author: "Penulis", TahunTerbit: 2000, ISBN: "9780306406157"})
+	removed := srv.createBook(t, admin, models.CreateBookRequest{Judul: "Removed", Author: "Penulis", TahunTerbit: 2000})
+
+	ops := []models.BatchOperation{
+		batchOp(models.BatchOpCreate, "", models.CreateBookRequest{Judul: "First", Author: "Penulis", TahunTerbit: 2001}),
+		batchOp(models.BatchOpCreate, "", models.CreateBookRequest{Judul: "Invalid", Author: "Penulis", TahunTerbit: 99}),
+		batchOp(models.BatchOpCreate, "", models.CreateBookRequest{Judul: "Duplicate", Author: "Penulis", TahunTerbit: 2001, ISBN: "9780306406157"}),
+		batchOp(models.BatchOpDelete, uuid.New().String(), nil),
+		batchOp("archive", existing.ID, nil),
+		batchOp(models.BatchOpDelete, removed.ID, nil),
+		batchOp(models.BatchOpCreate, "", models.CreateBookRequest{Judul: "Last", Author: "Penulis", TahunTerbit: 2002}),
+	}
+	var resp batchResponse
+	if status := srv.do(t, "POST", "/api/books/batch", admin, models.BatchRequest{Mode: models.BatchModeBestEffort, Operations: ops}, &resp); status != http.StatusOK {
+		t.Fatalf("batch: status %d, %s", status, resp.Message)
+	}
+	want := []int{http.StatusCreated, http.StatusBadRequest, http.StatusConflict, http.StatusNotFound, http.StatusBadRequest, http.StatusOK, http.StatusCreated}
+	if !slices.Equal(resp.statuses(), want) {
+		t.Errorf("results %v, want %v", resp.statuses(), want)
+	}
+	for i, r := range resp.Data {
+		if r.Index != i || r.Success != (r.Status < 300) {
+			t.Errorf("result %d: %+v", i, r)
+		}
+	}
+	if !resp.Success || resp.Message != "Batch applied: 3 succeeded, 4 failed" {
+		t.Errorf("summary %v %q", resp.Success, resp.Message)
+	}
+
+	// The failed operations are undone on their own; the ones around
+	// them, including those after the failed save, are kept
+	if got := strings.Join(srv.bookTitles(t, admin, "/api/books"), ","); got != "Existing,First,Last" {
+		t.Errorf("books after the batch: %s", got)
+	}
+}
+
+func TestBatchRequestErrors(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+	t.Setenv("BOOK_BATCH_MAX", "2")
+
+	create := batchOp(models.BatchOpCreate, "", models.CreateBookRequest{Judul: "Buku", Author: "Penulis", TahunTerbit: 2000})
+	tests := []struct {
+		req  models.BatchRequest
+		want int
+	}{
+		{models.BatchRequest{}, http.StatusBadRequest},
+		{models.BatchRequest{Mode: "some", Operations: []models.BatchOperation{create}}, http.StatusBadRequest},
+		{models.BatchRequest{Operations: []models.BatchOperation{create, create, create}}, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		if status := srv.do(t, "POST", "/api/books/batch", admin, tt.req, nil); status != tt.want {
+			t.Errorf("%+v: status %d, want %d", tt.req, status, tt.want)
+		}
+	}
+}
@@ -1,45 +1,114 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"math/rand"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"rest-api-golang/database"
+	"rest-api-golang/isbn"
 	"rest-api-golang/models"
 	"rest-api-golang/repositories"
 
 	"github.com/gorilla/mux"
 	"github.com/google/uuid"
+	"golang.org/x/text/language"
 )
 
 // Repositories
 var bookRepo *repositories.BookRepository
 var userRepo *repositories.UserRepository
 var tokenRepo *repositories.TokenRepository
+var identityRepo *repositories.IdentityRepository
+var mfaRepo *repositories.MFARepository
+var settingsRepo *repositories.SettingsRepository
+var accountTokenRepo *repositories.AccountTokenRepository
+var authorRepo *repositories.AuthorRepository
+var categoryRepo *repositories.CategoryRepository
+var tagRepo *repositories.TagRepository
+var copyRepo *repositories.CopyRepository
+var loanRepo *repositories.LoanRepository
+var holdRepo *repositories.HoldRepository
+var ledgerRepo *repositories.LedgerRepository
+var reviewRepo *repositories.ReviewRepository
+var readingListRepo *repositories.ReadingListRepository
+var importRepo *repositories.ImportRepository
+var auditRepo *repositories.AuditRepository
+var webhookRepo *repositories.WebhookRepository
 
 // InitializeRepositories initializes all repositories
 func InitializeRepositories() {
 	bookRepo = repositories.NewBookRepository(database.DB)
 	userRepo = repositories.NewUserRepository(database.DB)
 	tokenRepo = repositories.NewTokenRepository(database.DB)
+	identityRepo = repositories.NewIdentityRepository(database.DB)
+	mfaRepo = repositories.NewMFARepository(database.DB)
+	settingsRepo = repositories.NewSettingsRepository(database.DB)
+	accountTokenRepo = repositories.NewAccountTokenRepository(database.DB)
+	authorRepo = repositories.NewAuthorRepository(database.DB)
+	categoryRepo = repositories.NewCategoryRepository(database.DB)
+	tagRepo = repositories.NewTagRepository(database.DB)
+	copyRepo = repositories.NewCopyRepository(database.DB)
+	loanRepo = repositories.NewLoanRepository(database.DB)
+	holdRepo = repositories.NewHoldRepository(database.DB)
+	ledgerRepo = repositories.NewLedgerRepository(database.DB)
+	reviewRepo = repositories.NewReviewRepository(database.DB)
+	readingListRepo = repositories.NewReadingListRepository(database.DB)
+	importRepo = repositories.NewImportRepository(database.DB)
+	auditRepo = repositories.NewAuditRepository(database.DB)
+	webhookRepo = repositories.NewWebhookRepository(database.DB)
+}
+
+// publicPath reports whether path is served without token: login,
+// password reset, email verification links, shared reading lists, cover
+// images, health, the API documentation, and the GraphiQL page (its
+// queries still need a token)
+func publicPath(path string) bool {
+	return strings.HasPrefix(path, "/api/login") ||
+		strings.HasPrefix(path, "/api/password/") ||
+		path == "/api/email/verify" ||
+		strings.HasPrefix(path, "/api/shared-lists/") ||
+		strings.HasPrefix(path, "/covers/") ||
+		strings.HasPrefix(path, "/health") ||
+		strings.HasPrefix(path, "/docs") ||
+		path == "/openapi.json" ||
+		path == "/graphiql" ||
+		strings.HasPrefix(path, "/mock-idp")
 }
 
 // AuthMiddleware protects endpoints with Bearer token except excluded paths
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow login, health, and docs without token
-		if strings.HasPrefix(r.URL.Path, "/api/login") || 
-		   strings.HasPrefix(r.URL.Path, "/health") ||
-		   strings.HasPrefix(r.URL.Path, "/docs") ||
-		   strings.HasPrefix(r.URL.Path, "/swagger") {
+		if publicPath(r.URL.Path) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		authHeader := r.Header.Get("Authorization")
+		// Browsers cannot set headers on EventSource and WebSocket
+		// connections, so the live feed also opens with a single-use
+		// ticket from POST /api/events/ticket
+		if ticket := r.URL.Query().Get("ticket"); authHeader == "" && ticket != "" && r.Method == http.MethodGet && isLiveFeedPath(r.URL.Path) {
+			user, err := userForLiveTicket(ticket)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"success": false,
+					"message": "Ticket expired or invalid",
+				})
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, user)))
+			return
+		}
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]interface{}{
@@ -58,8 +127,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Check token in database
-		_, err := tokenRepo.GetTokenByValue(token)
+		user, err := userForToken(token)
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]interface{}{
@@ -69,27 +137,53 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// userForToken checks a session token in the database and loads its
+// owner, so handlers can check identity and role
+func userForToken(token string) (*models.User, error) {
+	session, err := tokenRepo.GetTokenByValue(token)
+	if err != nil {
+		return nil, err
+	}
+	return userRepo.GetUserByID(session.UserID)
+}
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// currentUser returns the user authenticated by AuthMiddleware, or nil
+func currentUser(r *http.Request) *models.User {
+	user, _ := r.Context().Value(userContextKey).(*models.User)
+	return user
+}
+
+// requireAdmin writes a 403 response and returns false unless the
+// authenticated user has the admin role
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	user := currentUser(r)
+	if user != nil && user.Role == "admin" {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": "Admin access required",
+	})
+	return false
+}
+
 // LoginRequest represents login payload
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
-// randomToken creates a random token string
-func randomToken() string {
-	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, 32)
-	rand.Seed(time.Now().UnixNano())
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
-}
-
 // Login handles POST /api/login
 func Login(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -104,9 +198,8 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user from database
-	user, err := userRepo.GetUserByUsername(req.Username)
-	if err != nil {
+	login, err := passwordLogin(r, req.Username, req.Password)
+	if errors.Is(err, errInvalidLogin) {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -114,45 +207,98 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-
-	// Simple password check (in production, use bcrypt)
-	if user.Password != req.Password {
-		w.WriteHeader(http.StatusUnauthorized)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"message": "Invalid username or password",
+			"message": "Failed to create session",
 		})
 		return
 	}
 
-	// Generate token
-	tokenValue := randomToken()
+	// Accounts with two-factor authentication get a challenge instead of a
+	// session; the token is issued by POST /api/login/mfa
+	if login.MFARequired {
+		writeMFAChallenge(w, login.User, login.SetupRequired)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"token":   login.Token,
+	})
+}
+
+// errInvalidLogin is returned by passwordLogin for an unknown user or a
+// wrong password, which callers must not tell apart
+var errInvalidLogin = errors.New("invalid username or password")
+
+// loginResult is the outcome of a password check: a session token, or
+// the user when a second factor has to be checked first
+type loginResult struct {
+	User          *models.User
+	Token         string
+	MFARequired   bool
+	SetupRequired bool
+}
+
+// passwordLogin checks a username and password for every login endpoint,
+// recording failures in the audit log. Accounts with two-factor
+// authentication, enabled or required by their role, get no session.
+func passwordLogin(r *http.Request, username, password string) (*loginResult, error) {
+	user, err := userRepo.GetUserByUsername(username)
+	if err != nil {
+		recordLoginFailure(r, username, "", "unknown user")
+		return nil, errInvalidLogin
+	}
+
+	if !user.CheckPassword(password) {
+		recordLoginFailure(r, username, user.ID, "wrong password")
+		return nil, errInvalidLogin
+	}
+
+	mfaRequired, setupRequired, err := mfaRequiredForLogin(user)
+	if err != nil {
+		return nil, err
+	}
+	if mfaRequired {
+		return &loginResult{User: user, MFARequired: true, SetupRequired: setupRequired}, nil
+	}
+
+	token, err := createSession(r, user)
+	if err != nil {
+		return nil, err
+	}
+	return &loginResult{User: user, Token: token}, nil
+}
+
+// sessionTTL is how long a session token is valid
+const sessionTTL = 24 * time.Hour
+
+// createSession issues a 24 hour session token for user and records the
+// login, in the audit log as made through r
+func createSession(r *http.Request, user *models.User) (string, error) {
+	tokenValue, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
 	token := &models.Token{
 		ID:        uuid.New().String(),
 		Token:     tokenValue,
 		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(24 * time.Hour), // 24 hours
+		ExpiresAt: time.Now().Add(sessionTTL),
 		CreatedAt: time.Now(),
 		IsRevoked: false,
 	}
 
-	// Save token to database
-	if err := tokenRepo.CreateToken(token); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "Failed to create session",
-		})
-		return
+	if err := tokenRepo.WithAudit(loginAuditContext(r, user)).CreateToken(token); err != nil {
+		return "", err
 	}
 
 	// Update last login
 	userRepo.UpdateLastLogin(user.ID)
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"token":   tokenValue,
-	})
+	return tokenValue, nil
 }
 
 // Logout handles POST /api/logout (requires Bearer token)
@@ -179,7 +325,7 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Revoke token in database
-	if err := tokenRepo.RevokeToken(token); err != nil {
+	if err := tokenRepo.WithAudit(auditContext(r)).RevokeToken(token); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -194,11 +340,336 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetBooks handles GET /api/books
+// parseBookFilter reads list filters from the query string
+func parseBookFilter(r *http.Request) (models.BookFilter, string) {
+	return bookFilterFromValues(r.URL.Query())
+}
+
+// bookFilterFromValues reads list filters from query parameters. It returns
+// an error message, or "" if the filters are valid.
+func bookFilterFromValues(q url.Values) (models.BookFilter, string) {
+	filter := models.BookFilter{
+		Publisher: strings.TrimSpace(q.Get("publisher")),
+		Format:    strings.ToLower(strings.TrimSpace(q.Get("format"))),
+	}
+
+	if v := q.Get("isbn"); v != "" {
+		normalized, err := isbn.Normalize(v)
+		if err != nil {
+			return filter, "Invalid isbn filter: " + err.Error()
+		}
+		filter.ISBN = normalized
+	}
+	if v := q.Get("language"); v != "" {
+		tag, err := language.Parse(v)
+		if err != nil {
+			return filter, "Invalid language filter, expected a BCP 47 tag such as en or id-ID"
+		}
+		filter.Language = tag.String()
+	}
+	if v := q.Get("author_id"); v != "" {
+		if _, err := uuid.Parse(v); err != nil {
+			return filter, "Invalid author_id filter"
+		}
+		filter.AuthorID = v
+	}
+	if v := strings.TrimSpace(q.Get("category")); v != "" {
+		filter.Category = strings.ToLower(v)
+	}
+	if v := q.Get("tags"); v != "" {
+		filter.Tags = models.NormalizeTags(strings.Split(v, ","))
+	}
+	switch q.Get("tags_match") {
+	case "", "any":
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		return filter, "Invalid tags_match, expected any or all"
+	}
+	if v := q.Get("min_rating"); v != "" {
+		rating, err := strconv.ParseFloat(v, 64)
+		if err != nil || rating < 1 || rating > 5 {
+			return filter, "Invalid min_rating filter, expected a number from 1 to 5"
+		}
+		filter.MinRating = rating
+	}
+	if v := q.Get("sort"); v != "" {
+		if !isOneOf(strings.TrimPrefix(v, "-"), models.BookSorts) {
+			return filter, "Invalid sort, expected one of: " + strings.Join(models.BookSorts, ", ") + " (prefix - for descending)"
+		}
+		filter.Sort = v
+	}
+	for name, target := range map[string]*int{"min_pages": &filter.MinPages, "max_pages": &filter.MaxPages} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return filter, "Invalid " + name + " filter, expected a positive integer"
+			}
+			*target = n
+		}
+	}
+
+	return filter, ""
+}
+
+// validateBookMetadata checks the extended metadata fields and normalizes
+// ISBN (to ISBN-13) and language (to canonical BCP 47) in place. It
+// returns an error message, or "" if the book is valid.
+func validateBookMetadata(book *models.Book) string {
+	if book.ISBN != "" {
+		normalized, err := isbn.Normalize(book.ISBN)
+		if err != nil {
+			return "Invalid ISBN: " + err.Error()
+		}
+		book.ISBN = normalized
+	}
+	if book.Language != "" {
+		tag, err := language.Parse(book.Language)
+		if err != nil {
+			return "Invalid language, expected a BCP 47 tag such as en or id-ID"
+		}
+		book.Language = tag.String()
+	}
+	if book.Pages < 0 {
+		return "Pages must be a positive number"
+	}
+	if book.Format != "" {
+		book.Format = strings.ToLower(book.Format)
+		valid := false
+		for _, f := range models.BookFormats {
+			if f == book.Format {
+				valid = true
+			}
+		}
+		if !valid {
+			return "Invalid format, expected one of: " + strings.Join(models.BookFormats, ", ")
+		}
+	}
+	if len(book.Publisher) > 255 || len(book.Edition) > 100 {
+		return "Publisher or edition is too long"
+	}
+	return ""
+}
+
+// newBookFromRequest builds a book from a create request and validates it
+// like POST /api/books. It returns an error message, or "" if it is valid.
+func newBookFromRequest(req models.CreateBookRequest) (*models.Book, string) {
+	// Basic validation
+	if req.Judul == "" || (req.Author == "" && len(req.Authors) == 0) || req.TahunTerbit < 1000 || req.TahunTerbit > 2024 {
+		return nil, "Invalid book data. Judul and Author are required, TahunTerbit must be between 1000-2024"
+	}
+
+	book := models.NewBook(req)
+	if len(book.Authors) == 0 {
+		book.Authors = models.AuthorsFromString(book.Author)
+	}
+	msg := validateBookAuthors(book.Authors)
+	if msg == "" {
+		msg = validateBookTags(book)
+	}
+	if msg == "" {
+		msg = validateBookMetadata(book)
+	}
+	return book, msg
+}
+
+// applyBookUpdate copies the fields set in req onto book and validates it
+// like PUT /api/books/{id}. It returns an error message, or "" if the book
+// is valid. Authors, categories and tags are left nil unless req changes
+// them, so only changed relations are relinked; restore puts the loaded
+// ones back after saving.
+func applyBookUpdate(book *models.Book, req models.UpdateBookRequest) (restore func(), msg string) {
+	// Update fields if provided
+	if req.Judul != "" {
+		book.Judul = req.Judul
+	}
+	// Authors, categories and tags are only relinked when the request
+	// changes them; an empty categories or tags list clears them
+	currentAuthors := book.Authors
+	book.Authors = nil
+	if len(req.Authors) > 0 {
+		book.Authors = req.Authors
+	} else if req.Author != "" {
+		book.Author = req.Author
+		book.Authors = models.AuthorsFromString(req.Author)
+	}
+	currentCategories, currentTags := book.Categories, book.Tags
+	book.Categories = models.CategoryRefsFromStrings(req.Categories)
+	book.Tags = req.Tags
+	restore = func() {
+		if book.Authors == nil {
+			book.Authors = currentAuthors
+		}
+		if book.Categories == nil {
+			book.Categories = currentCategories
+		}
+		if book.Tags == nil {
+			book.Tags = currentTags
+		}
+	}
+
+	msg = validateBookAuthors(book.Authors)
+	if msg == "" {
+		msg = validateBookTags(book)
+	}
+	if msg != "" {
+		return restore, msg
+	}
+	if req.TahunTerbit > 0 {
+		if req.TahunTerbit < 1000 || req.TahunTerbit > 2024 {
+			return restore, "TahunTerbit must be between 1000-2024"
+		}
+		book.TahunTerbit = req.TahunTerbit
+	}
+	if req.ISBN != "" {
+		book.ISBN = req.ISBN
+	}
+	if req.Publisher != "" {
+		book.Publisher = req.Publisher
+	}
+	if req.Language != "" {
+		book.Language = req.Language
+	}
+	if req.Pages != 0 {
+		book.Pages = req.Pages
+	}
+	if req.Description != "" {
+		book.Description = req.Description
+	}
+	if req.Edition != "" {
+		book.Edition = req.Edition
+	}
+	if req.Format != "" {
+		book.Format = req.Format
+	}
+	if msg := validateBookMetadata(book); msg != "" {
+		return restore, msg
+	}
+
+	book.UpdatedAt = time.Now()
+	return restore, ""
+}
+
+// validateBookAuthors checks a structured author list and defaults empty
+// roles to "author". It returns an error message, or "" if it is valid.
+func validateBookAuthors(authors []models.BookAuthor) string {
+	for i := range authors {
+		a := &authors[i]
+		a.Name = strings.TrimSpace(a.Name)
+		if a.AuthorID == "" && a.Name == "" {
+			return "Each author needs an author_id or a name"
+		}
+		if len(a.Name) > 255 {
+			return "Author name is too long"
+		}
+		if a.Role == "" {
+			a.Role = models.AuthorRoleAuthor
+		}
+		valid := false
+		for _, role := range models.AuthorRoles {
+			if role == a.Role {
+				valid = true
+			}
+		}
+		if !valid {
+			return "Invalid author role, expected one of: " + strings.Join(models.AuthorRoles, ", ")
+		}
+	}
+	return ""
+}
+
+// validateBookTags normalizes tags in place. It returns an error message,
+// or "" if they are valid.
+func validateBookTags(book *models.Book) string {
+	if book.Tags == nil {
+		return ""
+	}
+	book.Tags = models.NormalizeTags(book.Tags)
+	sort.Strings(book.Tags)
+	for _, tag := range book.Tags {
+		if len(tag) > 50 {
+			return "Tags must be at most 50 characters"
+		}
+	}
+	return ""
+}
+
+// writeBookSaveError maps repository errors from creating or updating a
+// book to a response
+func writeBookSaveError(w http.ResponseWriter, err error, fallback string) {
+	status, message := bookSaveError(err, fallback)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// bookSaveError returns the status and message for an error from creating
+// or updating a book
+func bookSaveError(err error, fallback string) (int, string) {
+	switch {
+	case errors.Is(err, repositories.ErrDuplicate):
+		return http.StatusConflict, "A book with this ISBN already exists"
+	case errors.Is(err, repositories.ErrNotFound):
+		// Only author or category references can be missing at this point
+		return http.StatusBadRequest, "Unknown reference: " + err.Error()
+	}
+	return http.StatusInternalServerError, fallback
+}
+
+// maxBookPageLimit bounds the ?limit= of GET /api/books
+const maxBookPageLimit = 100
+
+// parseBookPage reads the optional ?limit= and ?offset= of the book list;
+// limit is 0 when the whole list is wanted. It returns an error message,
+// or "" if they are valid.
+func parseBookPage(q url.Values) (limit, offset int, msg string) {
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxBookPageLimit {
+			return 0, 0, "Invalid limit, expected an integer from 1 to " + strconv.Itoa(maxBookPageLimit)
+		}
+		limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, "Invalid offset, expected a non-negative integer"
+		}
+		if limit == 0 {
+			return 0, 0, "offset requires limit"
+		}
+		offset = n
+	}
+	return limit, offset, ""
+}
+
+// GetBooks handles GET /api/books. With ?limit= it returns one page after
+// skipping ?offset= books, with the total and the next_offset of the
+// following page if there is one.
 func GetBooks(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	books, err := bookRepo.GetAllBooks()
+	filter, msg := parseBookFilter(r)
+	var limit, offset int
+	if msg == "" {
+		limit, offset, msg = parseBookPage(r.URL.Query())
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+	if limit > 0 {
+		getBooksPage(w, filter, limit, offset)
+		return
+	}
+
+	books, err := bookRepo.ListBooks(filter)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -215,6 +686,33 @@ func GetBooks(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// getBooksPage writes one page of the book list
+func getBooksPage(w http.ResponseWriter, filter models.BookFilter, limit, offset int) {
+	books, total, err := bookRepo.ListBooksPage(filter, limit, offset)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to fetch books",
+		})
+		return
+	}
+	if books == nil {
+		books = []*models.Book{}
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data":    books,
+		"count":   len(books),
+		"total":   total,
+	}
+	if next := offset + len(books); next < total {
+		response["next_offset"] = next
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
 // GetBook handles GET /api/books/{id}
 func GetBook(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -222,6 +720,11 @@ func GetBook(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+		getBookAsOf(w, id, asOf)
+		return
+	}
+
 	book, err := bookRepo.GetBookByID(id)
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
@@ -232,6 +735,48 @@ func GetBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	availability, err := copyRepo.GetAvailability(book.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to fetch availability",
+		})
+		return
+	}
+	book.Availability = availability
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    book,
+	})
+}
+
+// GetBookByISBN handles GET /api/books/isbn/{isbn}. Both ISBN-10 and
+// ISBN-13, with or without hyphens, find the same book.
+func GetBookByISBN(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	normalized, err := isbn.Normalize(mux.Vars(r)["isbn"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid ISBN: " + err.Error(),
+		})
+		return
+	}
+
+	book, err := bookRepo.GetBookByISBN(normalized)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Book not found",
+		})
+		return
+	}
+
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
 		"data":    book,
@@ -252,24 +797,18 @@ func CreateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Basic validation
-	if req.Judul == "" || req.Author == "" || req.TahunTerbit < 1000 || req.TahunTerbit > 2024 {
+	book, msg := newBookFromRequest(req)
+	if msg != "" {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"message": "Invalid book data. Judul and Author are required, TahunTerbit must be between 1000-2024",
+			"message": msg,
 		})
 		return
 	}
-
-	book := models.NewBook(req)
 	
-	if err := bookRepo.CreateBook(book); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "Failed to create book",
-		})
+	if err := bookRepo.WithAudit(auditContext(r)).CreateBook(book); err != nil {
+		writeBookSaveError(w, err, "Failed to create book")
 		return
 	}
 
@@ -309,35 +848,21 @@ func UpdateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update fields if provided
-	if req.Judul != "" {
-		book.Judul = req.Judul
-	}
-	if req.Author != "" {
-		book.Author = req.Author
-	}
-	if req.TahunTerbit > 0 {
-		if req.TahunTerbit < 1000 || req.TahunTerbit > 2024 {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"success": false,
-				"message": "TahunTerbit must be between 1000-2024",
-			})
-			return
-		}
-		book.TahunTerbit = req.TahunTerbit
-	}
-
-	book.UpdatedAt = time.Now()
-	
-	if err := bookRepo.UpdateBook(book); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	restore, msg := applyBookUpdate(book, req)
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"message": "Failed to update book",
+			"message": msg,
 		})
 		return
 	}
+	
+	if err := bookRepo.WithAudit(auditContext(r)).UpdateBook(book); err != nil {
+		writeBookSaveError(w, err, "Failed to update book")
+		return
+	}
+	restore()
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -365,7 +890,7 @@ func DeleteBook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Soft delete the book
-	if err := bookRepo.DeleteBook(id); err != nil {
+	if err := bookRepo.WithAudit(auditContext(r)).DeleteBook(id); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -380,3 +905,107 @@ func DeleteBook(w http.ResponseWriter, r *http.Request) {
 		"data":    book,
 	})
 }
+
+// RestoreBook handles POST /api/books/{id}/restore (admin): brings back a
+// soft-deleted book, which reappears in listings and reading lists
+func RestoreBook(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Deleted book not found",
+		})
+		return
+	}
+
+	if err := bookRepo.WithAudit(auditContext(r)).RestoreBook(id); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to restore book"
+		if errors.Is(err, repositories.ErrNotFound) {
+			status = http.StatusNotFound
+			message = "Deleted book not found"
+		} else if errors.Is(err, repositories.ErrDuplicate) {
+			status = http.StatusConflict
+			message = "Another book now uses this ISBN"
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	book, err := bookRepo.GetBookByID(id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to fetch book",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Book restored successfully",
+		"data":    book,
+	})
+}
+
+// defaultTrashRetention is how long deleted books stay restorable when
+// POST /api/admin/trash/purge has no ?older_than=
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// PurgeTrash handles POST /api/admin/trash/purge (admin): permanently
+// deletes the books deleted more than ?older_than= (a duration such as
+// 720h, 30 days by default; 0s empties the trash) ago, and covers no other
+// book shows. Books with loans on record are kept for the loan history.
+func PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	retention := defaultTrashRetention
+	if v := r.URL.Query().Get("older_than"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Invalid older_than, expected a duration such as 720h",
+			})
+			return
+		}
+		retention = d
+	}
+
+	purged, kept, err := bookRepo.WithAudit(auditContext(r)).PurgeDeletedBooks(time.Now().Add(-retention))
+	for _, book := range purged {
+		if book.CoverHash != "" {
+			removeUnusedCover(r.Context(), book.CoverHash, book.CoverFormat)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to purge deleted books: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to purge deleted books",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Purged %d deleted books", len(purged)),
+		"data":    map[string]int{"purged": len(purged), "kept": kept},
+	})
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// writeCategoryError maps category repository errors to a response
+func writeCategoryError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Category not found",
+		})
+	case errors.Is(err, repositories.ErrDuplicate):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "A category with this slug already exists",
+		})
+	case errors.Is(err, repositories.ErrInUse):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Category still has subcategories or books",
+		})
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": fallback,
+		})
+	}
+}
+
+// categorySlug returns the slug for a category: the requested one if it is
+// already in slug form, otherwise derived from the name
+func categorySlug(slug, name string) (string, string) {
+	if slug == "" {
+		slug = models.Slugify(name)
+	} else if models.Slugify(slug) != slug {
+		return "", "Slug may only contain lower-case letters, digits and single hyphens"
+	}
+	if slug == "" || len(slug) > 100 {
+		return "", "Slug must be between 1 and 100 characters"
+	}
+	return slug, ""
+}
+
+// GetCategories handles GET /api/categories. The flat list is ordered by
+// path; ?tree=true nests children under their parents.
+func GetCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	categories, err := categoryRepo.ListCategories()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to fetch categories",
+		})
+		return
+	}
+
+	count := len(categories)
+	if r.URL.Query().Get("tree") == "true" {
+		categories = models.BuildCategoryTree(categories)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    categories,
+		"count":   count,
+	})
+}
+
+// GetCategory handles GET /api/categories/{id}; the slug works as well
+func GetCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	category, err := categoryRepo.GetCategory(mux.Vars(r)["id"])
+	if err != nil {
+		writeCategoryError(w, err, "Failed to fetch category")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    category,
+	})
+}
+
+// CreateCategory handles POST /api/categories
+func CreateCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	name := strings.Join(strings.Fields(req.Name), " ")
+	slug, msg := categorySlug(strings.TrimSpace(req.Slug), name)
+	if name == "" || len(name) > 100 {
+		msg = "Name is required and must be at most 100 characters"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	now := time.Now()
+	category := &models.Category{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Slug:      slug,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if req.ParentID != "" {
+		parent, err := categoryRepo.GetCategory(req.ParentID)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Parent category not found",
+			})
+			return
+		}
+		category.ParentID = &parent.ID
+	}
+
+	if err := categoryRepo.WithAudit(auditContext(r)).CreateCategory(category); err != nil {
+		writeCategoryError(w, err, "Failed to create category")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Category created successfully",
+		"data":    category,
+	})
+}
+
+// UpdateCategory handles PUT /api/categories/{id}. Changing the slug or
+// parent moves the whole subtree.
+func UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	category, err := categoryRepo.GetCategory(mux.Vars(r)["id"])
+	if err != nil {
+		writeCategoryError(w, err, "Failed to fetch category")
+		return
+	}
+
+	var req models.UpdateCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	msg := ""
+	if name := strings.Join(strings.Fields(req.Name), " "); name != "" {
+		if len(name) > 100 {
+			msg = "Name must be at most 100 characters"
+		}
+		category.Name = name
+	}
+	if req.Slug != "" {
+		slug, slugMsg := categorySlug(strings.TrimSpace(req.Slug), category.Name)
+		if slugMsg != "" {
+			msg = slugMsg
+		}
+		category.Slug = slug
+	}
+	if req.ParentID != nil && msg == "" {
+		category.ParentID = nil
+		if *req.ParentID != "" {
+			parent, err := categoryRepo.GetCategory(*req.ParentID)
+			switch {
+			case err != nil:
+				msg = "Parent category not found"
+			case parent.Path == category.Path || strings.HasPrefix(parent.Path, category.Path+"/"):
+				msg = "A category cannot be moved under itself or its descendants"
+			default:
+				category.ParentID = &parent.ID
+			}
+		}
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+	category.UpdatedAt = time.Now()
+
+	if err := categoryRepo.WithAudit(auditContext(r)).UpdateCategory(category); err != nil {
+		writeCategoryError(w, err, "Failed to update category")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Category updated successfully",
+		"data":    category,
+	})
+}
+
+// DeleteCategory handles DELETE /api/categories/{id}. Only empty leaf
+// categories can be deleted.
+func DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	category, err := categoryRepo.GetCategory(mux.Vars(r)["id"])
+	if err != nil {
+		writeCategoryError(w, err, "Failed to fetch category")
+		return
+	}
+
+	if err := categoryRepo.WithAudit(auditContext(r)).DeleteCategory(category.ID); err != nil {
+		writeCategoryError(w, err, "Failed to delete category")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Category deleted successfully",
+		"data":    category,
+	})
+}
+
+// GetTags handles GET /api/tags for autocomplete: ?q= is a prefix and
+// ?limit= caps the result (default 10, at most 100)
+func GetTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Invalid limit, expected 1-100",
+			})
+			return
+		}
+		limit = n
+	}
+
+	tags, err := tagRepo.SearchTags(models.NormalizeTag(r.URL.Query().Get("q")), limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to fetch tags",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    tags,
+		"count":   len(tags),
+	})
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"rest-api-golang/models"
+)
+
+// createCategory creates a category and returns it
+func (s *testServer) createCategory(t *testing.T, token string, req models.CreateCategoryRequest) *models.Category {
+	t.Helper()
+	var resp struct {
+		Data models.Category `json:"data"`
+	}
+	if status := s.do(t, "POST", "/api/categories", token, req, &resp); status != http.StatusCreated {
+		t.Fatalf("create category %q: status %d", req.Name, status)
+	}
+	return &resp.Data
+}
+
+// createBook creates a book and returns it
+func (s *testServer) createBook(t *testing.T, token string, req models.CreateBookRequest) *models.Book {
+	t.Helper()
+	var resp struct {
+		Data models.Book `json:"data"`
+	}
+	if status := s.do(t, "POST", "/api/books", token, req, &resp); status != http.StatusCreated {
+		t.Fatalf("create book %q: status %d", req.Judul, status)
+	}
+	return &resp.Data
+}
+
+// bookTitles lists the titles of GET path, sorted
+func (s *testServer) bookTitles(t *testing.T, token, path string) []string {
+	t.Helper()
+	var resp struct {
+		Data []models.Book `json:"data"`
+	}
+	if status := s.do(t, "GET", path, token, nil, &resp); status != http.StatusOK {
+		t.Fatalf("GET %s: status %d", path, status)
+	}
+	titles := []string{}
+	for _, b := range resp.Data {
+		titles = append(titles, b.Judul)
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+func TestCategoryTree(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+
+	fiction := srv.createCategory(t, admin, models.CreateCategoryRequest{Name: "Fiction"})
+	fantasy := srv.createCategory(t, admin, models.CreateCategoryRequest{Name: "Fantasy", ParentID: fiction.ID})
+	epic := srv.createCategory(t, admin, models.CreateCategoryRequest{Name: "Epic Fantasy", ParentID: "fantasy"})
+	if fiction.Path != "fiction" || fantasy.Path != "fiction/fantasy" || epic.Path != "fiction/fantasy/epic-fantasy" || epic.Depth != 2 {
+		t.Fatalf("paths %q, %q, %q (depth %d)", fiction.Path, fantasy.Path, epic.Path, epic.Depth)
+	}
+
+	tests := []struct {
+		req  models.CreateCategoryRequest
+		want int
+	}{
+		{models.CreateCategoryRequest{Name: "Fantasy Again", Slug: "fantasy"}, http.StatusConflict},
+		{models.CreateCategoryRequest{Name: "Bad", Slug: "Not A Slug"}, http.StatusBadRequest},
+		{models.CreateCategoryRequest{Name: "  "}, http.StatusBadRequest},
+		{models.CreateCategoryRequest{Name: "Orphan", ParentID: "no-such-category"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if status := srv.do(t, "POST", "/api/categories", admin, tt.req, nil); status != tt.want {
+			t.Errorf("create %+v: status %d, want %d", tt.req, status, tt.want)
+		}
+	}
+
+	// A category cannot move under its own subtree
+	if status := srv.do(t, "PUT", "/api/categories/fiction", admin, map[string]string{"parent_id": epic.ID}, nil); status != http.StatusBadRequest {
+		t.Errorf("move under a descendant: status %d", status)
+	}
+
+	// Changing a slug rewrites the paths of the whole subtree
+	var updated struct {
+		Data models.Category `json:"data"`
+	}
+	if status := srv.do(t, "PUT", "/api/categories/"+fiction.ID, admin, models.UpdateCategoryRequest{Slug: "novels"}, &updated); status != http.StatusOK {
+		t.Fatalf("rename slug: status %d", status)
+	}
+	if updated.Data.Path != "novels" || updated.Data.Name != "Fiction" {
+		t.Errorf("renamed %+v", updated.Data)
+	}
+	var got struct {
+		Data models.Category `json:"data"`
+	}
+	if status := srv.do(t, "GET", "/api/categories/epic-fantasy", admin, nil, &got); status != http.StatusOK || got.Data.Path != "novels/fantasy/epic-fantasy" {
+		t.Errorf("descendant after rename: status %d, path %q", status, got.Data.Path)
+	}
+
+	// Moving to the root with "" shortens the subtree paths
+	root := ""
+	if status := srv.do(t, "PUT", "/api/categories/fantasy", admin, models.UpdateCategoryRequest{ParentID: &root}, nil); status != http.StatusOK {
+		t.Fatalf("move to root: status %d", status)
+	}
+	var list struct {
+		Data  []*models.Category `json:"data"`
+		Count int                `json:"count"`
+	}
+	if status := srv.do(t, "GET", "/api/categories", admin, nil, &list); status != http.StatusOK {
+		t.Fatalf("list: status %d", status)
+	}
+	var paths []string
+	for _, c := range list.Data {
+		paths = append(paths, c.Path)
+	}
+	if strings.Join(paths, " ") != "fantasy fantasy/epic-fantasy novels" || list.Count != 3 {
+		t.Errorf("paths after move: %v (count %d)", paths, list.Count)
+	}
+	if status := srv.do(t, "GET", "/api/categories?tree=true", admin, nil, &list); status != http.StatusOK {
+		t.Fatalf("tree: status %d", status)
+	}
+	if len(list.Data) != 2 || list.Data[0].Slug != "fantasy" || len(list.Data[0].Children) != 1 || list.Data[0].Children[0].Slug != "epic-fantasy" {
+		t.Errorf("tree %+v", list.Data)
+	}
+
+	// Only empty leaves can be deleted
+	if status := srv.do(t, "DELETE", "/api/categories/fantasy", admin, nil, nil); status != http.StatusConflict {
+		t.Errorf("delete a parent: status %d", status)
+	}
+	book := srv.createBook(t, admin, models.CreateBookRequest{Judul: "Kisah", Author: "Penulis", TahunTerbit: 2001, Categories: []string{"epic-fantasy"}})
+	if status := srv.do(t, "DELETE", "/api/categories/epic-fantasy", admin, nil, nil); status != http.StatusConflict {
+		t.Errorf("delete a category with books: status %d", status)
+	}
+	if status := srv.do(t, "PUT", "/api/books/"+book.ID, admin, map[string][]string{"categories": {}}, nil); status != http.StatusOK {
+		t.Fatalf("clear categories: status %d", status)
+	}
+	for _, slug := range []string{"epic-fantasy", "fantasy"} {
+		if status := srv.do(t, "DELETE", "/api/categories/"+slug, admin, nil, nil); status != http.StatusOK {
+			t.Errorf("delete %s: status %d", slug, status)
+		}
+	}
+	if status := srv.do(t, "GET", "/api/categories/fantasy", admin, nil, nil); status != http.StatusNotFound {
+		t.Errorf("deleted category: status %d", status)
+	}
+}
+
+func TestBookCategoriesAndTags(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+	user := srv.login(t, "user", "user123")
+
+	fiction := srv.createCategory(t, admin, models.CreateCategoryRequest{Name: "Fiction"})
+	srv.createCategory(t, admin, models.CreateCategoryRequest{Name: "Fantasy", ParentID: fiction.ID})
+	srv.createCategory(t, admin, models.CreateCategoryRequest{Name: "History"})
+
+	hobbit := srv.createBook(t, admin, models.CreateBookRequest{
+		Judul: "The Hobbit", Author: "J.R.R. Tolkien", TahunTerbit: 1937,
+		Categories: []string{"fantasy"}, Tags: []string{"Dragons", " classic ", "dragons"},
+	})
+	srv.createBook(t, admin, models.CreateBookRequest{
+		Judul: "Bumi Manusia", Author: "Pramoedya Ananta Toer", TahunTerbit: 1980,
+		Categories: []string{fiction.ID, "history"}, Tags: []string{"classic"},
+	})
+	srv.createBook(t, admin, models.CreateBookRequest{Judul: "Untagged", Author: "Anon", TahunTerbit: 2000})
+
+	if len(hobbit.Categories) != 1 || hobbit.Categories[0].Path != "fiction/fantasy" {
+		t.Errorf("categories %+v", hobbit.Categories)
+	}
+	tags := append([]string{}, hobbit.Tags...)
+	sort.Strings(tags)
+	if strings.Join(tags, ",") != "classic,dragons" {
+		t.Errorf("tags %q are not normalized", hobbit.Tags)
+	}
+
+	// Unknown categories are rejected rather than created
+	unknown := models.CreateBookRequest{Judul: "X", Author: "Y", TahunTerbit: 2000, Categories: []string{"no-such-category"}}
+	if status := srv.do(t, "POST", "/api/books", admin, unknown, nil); status != http.StatusBadRequest {
+		t.Errorf("unknown category: status %d", status)
+	}
+
+	filters := map[string]string{
+		"category=fiction":                    "Bumi Manusia,The Hobbit",
+		"category=fantasy":                    "The Hobbit",
+		"category=" + fiction.ID:              "Bumi Manusia,The Hobbit",
+		"tags=classic":                        "Bumi Manusia,The Hobbit",
+		"tags=dragons,classic":                "Bumi Manusia,The Hobbit",
+		"tags=dragons,classic&tags_match=all": "The Hobbit",
+		"tags=DRAGONS":                        "The Hobbit",
+		"category=history&tags=dragons":       "",
+	}
+	for query, want := range filters {
+		if got := strings.Join(srv.bookTitles(t, user, "/api/books?"+query), ","); got != want {
+			t.Errorf("?%s: %q, want %q", query, got, want)
+		}
+	}
+
+	// Book counts only include books directly in the category
+	var category struct {
+		Data models.Category `json:"data"`
+	}
+	srv.do(t, "GET", "/api/categories/fiction", user, nil, &category)
+	if category.Data.BookCount != 1 {
+		t.Errorf("fiction book_count %d, want 1", category.Data.BookCount)
+	}
+
+	// Autocomplete ranks tags by use and leaves out deleted books
+	var tagList struct {
+		Data []models.Tag `json:"data"`
+	}
+	srv.do(t, "GET", "/api/tags?q=", user, nil, &tagList)
+	if len(tagList.Data) != 2 || tagList.Data[0] != (models.Tag{Name: "classic", BookCount: 2}) || tagList.Data[1] != (models.Tag{Name: "dragons", BookCount: 1}) {
+		t.Errorf("tags %+v", tagList.Data)
+	}
+	if status := srv.do(t, "DELETE", "/api/books/"+hobbit.ID, admin, nil, nil); status != http.StatusOK {
+		t.Fatalf("delete book: status %d", status)
+	}
+	srv.do(t, "GET", "/api/tags?q=Dr", user, nil, &tagList)
+	if len(tagList.Data) != 0 {
+		t.Errorf("tags of a deleted book: %+v", tagList.Data)
+	}
+	if status := srv.do(t, "GET", "/api/tags?limit=0", user, nil, nil); status != http.StatusBadRequest {
+		t.Errorf("limit=0: status %d", status)
+	}
+}
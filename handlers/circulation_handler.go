@@ -0,0 +1,622 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func isOneOf(value string, values []string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCirculationError maps copy and loan repository errors to a response
+func writeCirculationError(w http.ResponseWriter, err error, fallback string) {
+	status := http.StatusInternalServerError
+	message := fallback
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		status = http.StatusNotFound
+		message = capitalize(err.Error())
+	case errors.Is(err, repositories.ErrDuplicate):
+		status = http.StatusConflict
+		message = "A copy with this barcode already exists"
+	case errors.Is(err, repositories.ErrInUse):
+		status = http.StatusConflict
+		message = "Copy has loan history and cannot be deleted; set its status to withdrawn instead"
+	case errors.Is(err, repositories.ErrNotAvailable):
+		status = http.StatusConflict
+		message = capitalize(err.Error())
+	case errors.Is(err, repositories.ErrLimitReached):
+		status = http.StatusConflict
+		message = capitalize(err.Error())
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// GetBookCopies handles GET /api/books/{id}/copies
+func GetBookCopies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	book, err := bookRepo.GetBookByID(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Book not found",
+		})
+		return
+	}
+
+	copies, err := copyRepo.GetCopiesByBook(book.ID)
+	if err != nil {
+		writeCirculationError(w, err, "Failed to fetch copies")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    copies,
+		"count":   len(copies),
+	})
+}
+
+// CreateCopy handles POST /api/books/{id}/copies (admin)
+func CreateCopy(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	book, err := bookRepo.GetBookByID(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Book not found",
+		})
+		return
+	}
+
+	var req models.CreateCopyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	now := time.Now()
+	c := &models.Copy{
+		ID:        uuid.New().String(),
+		BookID:    book.ID,
+		Barcode:   strings.TrimSpace(req.Barcode),
+		Condition: strings.ToLower(strings.TrimSpace(req.Condition)),
+		Location:  strings.TrimSpace(req.Location),
+		Status:    models.CopyStatusAvailable,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if c.Condition == "" {
+		c.Condition = "good"
+	}
+	if msg := validateCopy(c); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	if err := copyRepo.WithAudit(auditContext(r)).CreateCopy(c); err != nil {
+		writeCirculationError(w, err, "Failed to create copy")
+		return
+	}
+	fillHolds(r, c)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Copy created successfully",
+		"data":    c,
+	})
+}
+
+// fillHolds hands a copy that is available to the hold queue of its book,
+// on behalf of the request r, and refreshes c.Status. Failures are logged:
+// the copy is saved and the queue is filled again on the next return.
+func fillHolds(r *http.Request, c *models.Copy) {
+	if c.Status != models.CopyStatusAvailable {
+		return
+	}
+	if _, err := holdRepo.WithAudit(auditContext(r)).FillHolds(c.BookID); err != nil {
+		log.Printf("Failed to fill holds for book %s: %v", c.BookID, err)
+		return
+	}
+	if updated, err := copyRepo.GetCopyByID(c.ID); err == nil {
+		c.Status = updated.Status
+	}
+}
+
+// validateCopy returns an error message, or "" if the copy is valid
+func validateCopy(c *models.Copy) string {
+	if c.Barcode == "" || len(c.Barcode) > 64 {
+		return "Barcode is required and must be at most 64 characters"
+	}
+	if !isOneOf(c.Condition, models.CopyConditions) {
+		return "Invalid condition, expected one of: " + strings.Join(models.CopyConditions, ", ")
+	}
+	if len(c.Location) > 100 {
+		return "Location must be at most 100 characters"
+	}
+	return ""
+}
+
+// GetCopy handles GET /api/copies/{id}
+func GetCopy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	c, err := copyRepo.GetCopyByID(mux.Vars(r)["id"])
+	if err != nil {
+		writeCirculationError(w, err, "Failed to fetch copy")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    c,
+	})
+}
+
+// UpdateCopy handles PUT /api/copies/{id} (admin). The status of a copy
+// on loan or on hold only changes through circulation.
+func UpdateCopy(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	c, err := copyRepo.GetCopyByID(mux.Vars(r)["id"])
+	if err != nil {
+		writeCirculationError(w, err, "Failed to fetch copy")
+		return
+	}
+
+	var req models.UpdateCopyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	if req.Barcode != "" {
+		c.Barcode = strings.TrimSpace(req.Barcode)
+	}
+	if req.Condition != "" {
+		c.Condition = strings.ToLower(strings.TrimSpace(req.Condition))
+	}
+	if req.Location != "" {
+		c.Location = strings.TrimSpace(req.Location)
+	}
+	msg := validateCopy(c)
+	if req.Status != "" && msg == "" {
+		switch {
+		case !isOneOf(req.Status, models.CopyStatuses):
+			msg = "Invalid status, expected one of: " + strings.Join(models.CopyStatuses, ", ")
+		case c.Status == models.CopyStatusOnLoan && req.Status != c.Status:
+			msg = "Copy is on loan; return it before changing its status"
+		case c.Status == models.CopyStatusOnHold && req.Status != c.Status:
+			msg = "Copy is on hold; cancel the hold before changing its status"
+		default:
+			c.Status = req.Status
+		}
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+	c.UpdatedAt = time.Now()
+
+	if err := copyRepo.WithAudit(auditContext(r)).UpdateCopy(c); err != nil {
+		writeCirculationError(w, err, "Failed to update copy")
+		return
+	}
+	fillHolds(r, c)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Copy updated successfully",
+		"data":    c,
+	})
+}
+
+// DeleteCopy handles DELETE /api/copies/{id} (admin)
+func DeleteCopy(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	c, err := copyRepo.GetCopyByID(mux.Vars(r)["id"])
+	if err != nil {
+		writeCirculationError(w, err, "Failed to fetch copy")
+		return
+	}
+
+	if err := copyRepo.WithAudit(auditContext(r)).DeleteCopy(c.ID); err != nil {
+		writeCirculationError(w, err, "Failed to delete copy")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Copy deleted successfully",
+		"data":    c,
+	})
+}
+
+// GetLoans handles GET /api/loans. Admins see all loans and may filter by
+// ?user_id= and ?book_id=; members only see their own. ?status= is one of
+// active, overdue or returned.
+func GetLoans(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+	q := r.URL.Query()
+
+	filter := models.LoanFilter{
+		UserID: q.Get("user_id"),
+		BookID: q.Get("book_id"),
+		Status: q.Get("status"),
+	}
+	if user.Role != "admin" {
+		filter.UserID = user.ID
+	}
+
+	msg := ""
+	for _, id := range []string{filter.UserID, filter.BookID} {
+		if _, err := uuid.Parse(id); id != "" && err != nil {
+			msg = "Invalid user_id or book_id filter"
+		}
+	}
+	if filter.Status != "" && !isOneOf(filter.Status, []string{models.LoanStatusActive, models.LoanStatusOverdue, models.LoanStatusReturned}) {
+		msg = "Invalid status filter, expected active, overdue or returned"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	loans, err := loanRepo.ListLoans(filter)
+	if err != nil {
+		writeCirculationError(w, err, "Failed to fetch loans")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    loans,
+		"count":   len(loans),
+	})
+}
+
+// getOwnLoan loads the loan in the URL and writes an error response unless
+// it belongs to the current user or the user is an admin
+func getOwnLoan(w http.ResponseWriter, r *http.Request) (*models.Loan, bool) {
+	loan, err := loanRepo.GetLoanByID(mux.Vars(r)["id"])
+	user := currentUser(r)
+	if err == nil && loan.UserID != user.ID && user.Role != "admin" {
+		err = fmt.Errorf("loan %w", repositories.ErrNotFound)
+	}
+	if err != nil {
+		writeCirculationError(w, err, "Failed to fetch loan")
+		return nil, false
+	}
+	return loan, true
+}
+
+// GetLoan handles GET /api/loans/{id}
+func GetLoan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	loan, ok := getOwnLoan(w, r)
+	if !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    loan,
+	})
+}
+
+// CheckoutLoan handles POST /api/loans (admin): lends a copy, identified
+// by copy_id or barcode, to the member user_id
+func CheckoutLoan(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+	if _, err := uuid.Parse(req.UserID); err != nil || (req.CopyID == "" && req.Barcode == "") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "user_id and either copy_id or barcode are required",
+		})
+		return
+	}
+
+	var c *models.Copy
+	var err error
+	if req.CopyID != "" {
+		c, err = copyRepo.GetCopyByID(req.CopyID)
+	} else {
+		c, err = copyRepo.GetCopyByBarcode(strings.TrimSpace(req.Barcode))
+	}
+	if err != nil {
+		writeCirculationError(w, err, "Failed to fetch copy")
+		return
+	}
+
+	loan, err := loanRepo.WithAudit(auditContext(r)).CheckOut(c.ID, req.UserID, currentUser(r).ID)
+	if err != nil {
+		writeCirculationError(w, err, "Failed to check out copy")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Copy checked out successfully",
+		"data":    loan,
+	})
+}
+
+// ReturnLoan handles POST /api/loans/{id}/return (admin). The optional
+// body {"condition": "..."} records the condition of the returned copy.
+func ReturnLoan(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.ReturnRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Invalid JSON format",
+			})
+			return
+		}
+	}
+	req.Condition = strings.ToLower(strings.TrimSpace(req.Condition))
+	if req.Condition != "" && !isOneOf(req.Condition, models.CopyConditions) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid condition, expected one of: " + strings.Join(models.CopyConditions, ", "),
+		})
+		return
+	}
+
+	loan, err := loanRepo.GetLoanByID(mux.Vars(r)["id"])
+	if err != nil {
+		writeCirculationError(w, err, "Failed to fetch loan")
+		return
+	}
+	if loan.ReturnedAt != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Loan has already been returned",
+		})
+		return
+	}
+
+	loan, err = loanRepo.WithAudit(auditContext(r)).ReturnLoan(loan.ID, req.Condition)
+	if err != nil {
+		writeCirculationError(w, err, "Failed to return loan")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Copy returned successfully",
+		"data":    loan,
+	})
+}
+
+// RenewLoan handles POST /api/loans/{id}/renew for the borrower or an admin
+func RenewLoan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	loan, ok := getOwnLoan(w, r)
+	if !ok {
+		return
+	}
+
+	msg := ""
+	switch {
+	case loan.ReturnedAt != nil:
+		msg = "Loan has already been returned"
+	case loan.Overdue:
+		msg = "Overdue loans cannot be renewed"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	loan, err := loanRepo.WithAudit(auditContext(r)).RenewLoan(loan.ID)
+	if err != nil {
+		writeCirculationError(w, err, "Failed to renew loan")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Loan renewed successfully",
+		"data":    loan,
+	})
+}
+
+// GetLoanPolicies handles GET /api/loan-policies
+func GetLoanPolicies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	policies, err := loanRepo.ListPolicies()
+	if err != nil {
+		writeCirculationError(w, err, "Failed to fetch loan policies")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    policies,
+		"count":   len(policies),
+	})
+}
+
+// SaveLoanPolicy handles PUT /api/loan-policies/{role} (admin); the role
+// "default" applies to members whose role has no policy of its own
+func SaveLoanPolicy(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.UpdateLoanPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+	role := mux.Vars(r)["role"]
+	if req.HoldPickupDays == 0 {
+		req.HoldPickupDays = 3
+	}
+	msg := ""
+	switch {
+	case len(role) > 20 || req.LoanDays < 1 || req.RenewalDays < 1 || req.MaxRenewals < 0 || req.MaxLoans < 1 || req.HoldPickupDays < 1:
+		msg = "loan_days, renewal_days, max_loans and hold_pickup_days must be positive and max_renewals not negative"
+	case req.FineDailyRate < 0 || req.FineGraceDays < 0 || req.FineCap < 0 || req.MaxBalance < 0:
+		msg = "fine_daily_rate, fine_grace_days, fine_cap and max_balance must not be negative"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	policy := &models.LoanPolicy{
+		Role:           role,
+		LoanDays:       req.LoanDays,
+		RenewalDays:    req.RenewalDays,
+		MaxRenewals:    req.MaxRenewals,
+		MaxLoans:       req.MaxLoans,
+		HoldPickupDays: req.HoldPickupDays,
+		FineDailyRate:  req.FineDailyRate,
+		FineGraceDays:  req.FineGraceDays,
+		FineCap:        req.FineCap,
+		MaxBalance:     req.MaxBalance,
+		UpdatedAt:      time.Now(),
+	}
+	if err := loanRepo.WithAudit(auditContext(r)).SavePolicy(policy); err != nil {
+		writeCirculationError(w, err, "Failed to save loan policy")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Loan policy saved successfully",
+		"data":    policy,
+	})
+}
+
+// DeleteLoanPolicy handles DELETE /api/loan-policies/{role} (admin)
+func DeleteLoanPolicy(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := loanRepo.WithAudit(auditContext(r)).DeletePolicy(mux.Vars(r)["role"]); err != nil {
+		if errors.Is(err, repositories.ErrInUse) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "The default loan policy cannot be deleted",
+			})
+			return
+		}
+		writeCirculationError(w, err, "Failed to delete loan policy")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Loan policy deleted successfully",
+	})
+}
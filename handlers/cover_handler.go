@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"rest-api-golang/covers"
+	"rest-api-golang/repositories"
+	"rest-api-golang/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultCoverMaxBytes is the upload limit when COVER_MAX_BYTES is unset
+const defaultCoverMaxBytes = 5 << 20
+
+// Blob store holding cover images and thumbnails
+var blobStore storage.BlobStore
+
+// InitializeBlobStore sets the store used for cover images
+func InitializeBlobStore(s storage.BlobStore) {
+	blobStore = s
+}
+
+// coverMaxBytes is the largest cover file accepted, from COVER_MAX_BYTES
+func coverMaxBytes() int64 {
+	if n, err := strconv.ParseInt(os.Getenv("COVER_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return defaultCoverMaxBytes
+}
+
+// writeCoverError writes a JSON error response
+func writeCoverError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// readCoverUpload returns the contents of the "cover" part of a multipart
+// request, reading at most limit bytes. The first bool is false when the
+// file is over the limit.
+func readCoverUpload(r *http.Request, limit int64) ([]byte, bool, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, true, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, true, errors.New("missing cover file")
+		}
+		if err != nil {
+			return nil, true, err
+		}
+		if part.FormName() != "cover" {
+			part.Close()
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(part, limit+1))
+		part.Close()
+		if err != nil {
+			return nil, true, err
+		}
+		return data, int64(len(data)) <= limit, nil
+	}
+}
+
+// removeUnusedCover deletes the files of a replaced cover unless another
+// book still shows it. Failures only leave orphaned files, so they are
+// logged rather than returned.
+func removeUnusedCover(ctx context.Context, hash, format string) {
+	inUse, err := bookRepo.CoverInUse(hash)
+	if err == nil && !inUse {
+		err = covers.Remove(ctx, blobStore, hash, format)
+	}
+	if err != nil {
+		log.Printf("Failed to remove cover %s: %v", hash, err)
+	}
+}
+
+// UploadBookCover handles PUT /api/books/{id}/cover. The image is sent as
+// the "cover" field of a multipart/form-data body and must be a JPEG, PNG
+// or WebP file no larger than COVER_MAX_BYTES; the type is sniffed from
+// the file itself. Thumbnails are generated for every models.CoverSizes.
+func UploadBookCover(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	book, err := bookRepo.GetBookByID(mux.Vars(r)["id"])
+	if err != nil {
+		writeCoverError(w, http.StatusNotFound, "Book not found")
+		return
+	}
+
+	// Leave room for the multipart headers around the file
+	limit := coverMaxBytes()
+	r.Body = http.MaxBytesReader(w, r.Body, limit+64<<10)
+	data, withinLimit, err := readCoverUpload(r, limit)
+	var maxBytesErr *http.MaxBytesError
+	if !withinLimit || errors.As(err, &maxBytesErr) {
+		writeCoverError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Cover file must not be larger than %d bytes", limit))
+		return
+	}
+	if err != nil {
+		writeCoverError(w, http.StatusBadRequest, "Expected a multipart/form-data body with a cover file")
+		return
+	}
+
+	cover, err := covers.Process(data)
+	switch {
+	case errors.Is(err, covers.ErrUnsupported):
+		writeCoverError(w, http.StatusUnsupportedMediaType, capitalize(err.Error()))
+		return
+	case errors.Is(err, covers.ErrInvalid), errors.Is(err, covers.ErrTooLarge):
+		writeCoverError(w, http.StatusBadRequest, capitalize(err.Error()))
+		return
+	case err != nil:
+		writeCoverError(w, http.StatusInternalServerError, "Failed to process cover")
+		return
+	}
+
+	if err := covers.Save(r.Context(), blobStore, cover); err != nil {
+		log.Printf("Failed to store cover for book %s: %v", book.ID, err)
+		writeCoverError(w, http.StatusInternalServerError, "Failed to store cover")
+		return
+	}
+	oldHash, oldFormat, err := bookRepo.WithAudit(auditContext(r)).SetCover(book.ID, cover.Hash, cover.Format)
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeCoverError(w, http.StatusNotFound, "Book not found")
+		return
+	}
+	if err != nil {
+		writeCoverError(w, http.StatusInternalServerError, "Failed to update cover")
+		return
+	}
+	if oldHash != "" && oldHash != cover.Hash {
+		removeUnusedCover(r.Context(), oldHash, oldFormat)
+	}
+
+	book.CoverHash = cover.Hash
+	book.CoverFormat = cover.Format
+	book.SetCoverURLs()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Cover uploaded successfully",
+		"data":    book,
+	})
+}
+
+// DeleteBookCover handles DELETE /api/books/{id}/cover
+func DeleteBookCover(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	oldHash, oldFormat, err := bookRepo.WithAudit(auditContext(r)).SetCover(mux.Vars(r)["id"], "", "")
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeCoverError(w, http.StatusNotFound, "Book not found")
+		return
+	}
+	if err != nil {
+		writeCoverError(w, http.StatusInternalServerError, "Failed to remove cover")
+		return
+	}
+	if oldHash == "" {
+		writeCoverError(w, http.StatusNotFound, "Book has no cover")
+		return
+	}
+	removeUnusedCover(r.Context(), oldHash, oldFormat)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Cover removed successfully",
+	})
+}
+
+// ServeCover handles GET /covers/{hash}/{name} without authentication.
+// The hash in the path is the content hash of the upload, so responses
+// are cached as immutable.
+func ServeCover(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	hash, name := vars["hash"], vars["name"]
+	if !covers.ValidName(hash, name) {
+		w.Header().Set("Content-Type", "application/json")
+		writeCoverError(w, http.StatusNotFound, "Cover not found")
+		return
+	}
+
+	etag := `"` + hash + "-" + name + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	blob, err := blobStore.Get(r.Context(), covers.Key(hash, name))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, storage.ErrNotFound) {
+			writeCoverError(w, http.StatusNotFound, "Cover not found")
+			return
+		}
+		log.Printf("Failed to read cover %s/%s: %v", hash, name, err)
+		writeCoverError(w, http.StatusInternalServerError, "Failed to read cover")
+		return
+	}
+
+	w.Header().Set("Content-Type", blob.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(blob.Data)))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(blob.Data)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"rest-api-golang/models"
+)
+
+// export downloads /api/books/export?query as JSON Lines, or returns
+// only the status when it fails
+func (s *testServer) export(t *testing.T, token, query string) (int, []models.Book) {
+	t.Helper()
+	req, err := http.NewRequest("GET", s.URL+"/api/books/export?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	var books []models.Book
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		var book models.Book
+		if err := json.Unmarshal(lines.Bytes(), &book); err != nil {
+			t.Fatalf("line %q: %v", lines.Text(), err)
+		}
+		books = append(books, book)
+	}
+	return resp.StatusCode, books
+}
+
+func TestExportBooksFilters(t *testing.T) {
+	srv := newTestServer(t)
+	token := srv.login(t, "admin", "admin123")
+
+	for _, format := range []string{"paperback", "ebook"} {
+		book := models.CreateBookRequest{Judul: "Export " + format, Author: "Test Author", TahunTerbit: 2020, Format: format}
+		if status := srv.do(t, "POST", "/api/books", token, book, nil); status != http.StatusCreated {
+			t.Fatalf("create %s book: status %d", format, status)
+		}
+	}
+
+	// ?format= picks the file type, so the binding filter is ?book_format=
+	status, books := srv.export(t, token, "format=jsonl&book_format=ebook")
+	if status != http.StatusOK {
+		t.Fatalf("export: status %d", status)
+	}
+	if len(books) != 1 || books[0].Judul != "Export ebook" || books[0].Author != "Test Author" {
+		t.Errorf("exported %+v", books)
+	}
+
+	if status, _ := srv.export(t, token, "format=jsonl&book_format=csv"); status != http.StatusBadRequest {
+		t.Errorf("unknown binding format: status %d", status)
+	}
+	if status, _ := srv.export(t, token, "format=pdf"); status != http.StatusBadRequest {
+		t.Errorf("unknown file format: status %d", status)
+	}
+}
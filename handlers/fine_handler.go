@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// fineCurrency is the ISO 4217 code that ledger amounts are minor units of
+func fineCurrency() string {
+	if currency := os.Getenv("FINE_CURRENCY"); currency != "" {
+		return strings.ToUpper(currency)
+	}
+	return "IDR"
+}
+
+// writeLedgerError maps ledger repository errors to a response
+func writeLedgerError(w http.ResponseWriter, err error, fallback string) {
+	status := http.StatusInternalServerError
+	message := fallback
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		status = http.StatusNotFound
+		message = capitalize(err.Error())
+	case errors.Is(err, repositories.ErrExceedsBalance):
+		status = http.StatusConflict
+		message = capitalize(err.Error())
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// writeAccount responds with the balance and ledger of a member
+func writeAccount(w http.ResponseWriter, userID string) {
+	entries, err := ledgerRepo.ListEntries(userID)
+	if err != nil {
+		writeLedgerError(w, err, "Failed to fetch account")
+		return
+	}
+
+	// Entries are newest first, so the first running balance is the total
+	account := &models.Account{
+		UserID:   userID,
+		Currency: fineCurrency(),
+		Entries:  entries,
+	}
+	if len(entries) > 0 {
+		account.Balance = entries[0].Balance
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    account,
+		"count":   len(entries),
+	})
+}
+
+// GetMyAccount handles GET /api/account: the current member's balance and
+// ledger history
+func GetMyAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	writeAccount(w, currentUser(r).ID)
+}
+
+// accountMember returns the member in the URL and writes a 404 response
+// if there is none
+func accountMember(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err == nil {
+		if member, err := userRepo.GetUserByID(id); err == nil {
+			return member, true
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": "Member not found",
+	})
+	return nil, false
+}
+
+// GetUserAccount handles GET /api/users/{id}/account (admin)
+func GetUserAccount(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	member, ok := accountMember(w, r)
+	if !ok {
+		return
+	}
+	writeAccount(w, member.ID)
+}
+
+// RecordPayment handles POST /api/users/{id}/account/payments (admin)
+func RecordPayment(w http.ResponseWriter, r *http.Request) {
+	creditAccount(w, r, models.EntryTypePayment)
+}
+
+// RecordWaiver handles POST /api/users/{id}/account/waivers (admin)
+func RecordWaiver(w http.ResponseWriter, r *http.Request) {
+	creditAccount(w, r, models.EntryTypeWaiver)
+}
+
+// creditAccount records a payment or waiver against a member's balance
+func creditAccount(w http.ResponseWriter, r *http.Request, entryType string) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	member, ok := accountMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AccountCreditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+
+	msg := ""
+	switch {
+	case req.Amount < 1:
+		msg = "amount must be a positive number of minor currency units"
+	case len(req.Note) > 255:
+		msg = "Note must be at most 255 characters"
+	case entryType == models.EntryTypeWaiver && req.Note == "":
+		msg = "A note explaining the waiver is required"
+	}
+	if _, err := uuid.Parse(req.LoanID); req.LoanID != "" && err != nil {
+		msg = "Invalid loan_id"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	entry, err := ledgerRepo.WithAudit(auditContext(r)).Credit(member.ID, entryType, req.Amount, req.LoanID, req.Note, currentUser(r).ID)
+	if err != nil {
+		writeLedgerError(w, err, "Failed to record "+entryType)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": capitalize(entryType) + " recorded successfully",
+		"data":    entry,
+	})
+}
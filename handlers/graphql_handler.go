@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"rest-api-golang/graphql"
+	"rest-api-golang/models"
+)
+
+// graphqlMaxBody bounds the size of a GraphQL request body
+const graphqlMaxBody = 1 << 20
+
+// Schema and limits of the GraphQL endpoint
+var (
+	graphqlSchema *graphql.Schema
+	graphqlConfig = &graphql.Config{}
+)
+
+// InitializeGraphQL builds the schema served at /graphql, which runs
+// operations within the limits of config
+func InitializeGraphQL(config *graphql.Config) error {
+	schema, err := newGraphQLSchema()
+	if err != nil {
+		return err
+	}
+	graphqlSchema = schema
+	graphqlConfig = config
+	return nil
+}
+
+type graphqlContextKey struct{}
+
+// graphqlRequest is the state the resolvers of one request share: the HTTP
+// request, which carries the authenticated user and the audit context, and
+// the loaders that batch the loads of all objects of a level of the query
+type graphqlRequest struct {
+	r            *http.Request
+	authors      *graphql.Loader[string, *models.Author]
+	availability *graphql.Loader[string, *models.BookAvailability]
+	// authorBooks has a loader per value of the first argument of
+	// Author.books, since the limit applies to the whole batch
+	authorBooks map[int]*graphql.Loader[string, []*models.Book]
+}
+
+func newGraphQLRequest(r *http.Request) *graphqlRequest {
+	return &graphqlRequest{
+		r:            r,
+		authors:      graphql.NewLoader(authorRepo.GetAuthorsByIDs),
+		availability: graphql.NewLoader(copyRepo.GetAvailabilities),
+		authorBooks:  map[int]*graphql.Loader[string, []*models.Book]{},
+	}
+}
+
+// graphqlState returns the state of the request a resolver runs in
+func graphqlState(ctx context.Context) *graphqlRequest {
+	return ctx.Value(graphqlContextKey{}).(*graphqlRequest)
+}
+
+// booksByAuthor returns the loader of the first books of authors
+func (g *graphqlRequest) booksByAuthor(first int) *graphql.Loader[string, []*models.Book] {
+	l, ok := g.authorBooks[first]
+	if !ok {
+		l = graphql.NewLoader(func(authorIDs []string) (map[string][]*models.Book, error) {
+			return bookRepo.ListBooksByAuthors(authorIDs, first)
+		})
+		g.authorBooks[first] = l
+	}
+	return l
+}
+
+// writeGraphQLError writes a request that could not be run at all, in the
+// shape of a GraphQL response
+func writeGraphQLError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&graphql.Response{
+		Errors: []*graphql.Error{{Message: message}},
+	})
+}
+
+// GraphQL handles POST /graphql, and GET /graphql?query= which can only run
+// queries. Errors of a request that could be parsed, including validation
+// errors and errors of single fields, are reported in the errors list of a
+// 200 response.
+func GraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, "Variables are invalid JSON.")
+				return
+			}
+		}
+	} else {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, graphqlMaxBody)).Decode(&req); err != nil {
+			writeGraphQLError(w, http.StatusBadRequest, "Request body is invalid JSON.")
+			return
+		}
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeGraphQLError(w, http.StatusBadRequest, "Must provide query string.")
+		return
+	}
+
+	opts := graphqlConfig.Options()
+	opts.QueryOnly = r.Method == http.MethodGet
+	ctx := context.WithValue(r.Context(), graphqlContextKey{}, newGraphQLRequest(r))
+	response := graphql.Execute(ctx, graphqlSchema, &req, opts)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
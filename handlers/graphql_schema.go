@@ -0,0 +1,549 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// graphqlMaxPage is the largest page a list field of the schema returns
+const graphqlMaxPage = 100
+
+var dateTimeScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "DateTime",
+	Description: "A point in time as an RFC 3339 string.",
+	Serialize: func(v interface{}) interface{} {
+		switch t := v.(type) {
+		case time.Time:
+			return t.Format(time.RFC3339Nano)
+		case *time.Time:
+			if t != nil {
+				return t.Format(time.RFC3339Nano)
+			}
+		}
+		return nil
+	},
+	ParseValue: parseDateTime,
+	ParseLiteral: func(v ast.Value) interface{} {
+		if s, ok := v.(*ast.StringValue); ok {
+			return parseDateTime(s.Value)
+		}
+		return nil
+	},
+})
+
+// parseDateTime returns the time of an RFC 3339 string, or nil
+func parseDateTime(v interface{}) interface{} {
+	s, _ := v.(string)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil
+	}
+	return t
+}
+
+// enumOf builds an enum whose values are the given strings
+func enumOf(name, description string, values []string) *graphql.Enum {
+	config := graphql.EnumValueConfigMap{}
+	for _, v := range values {
+		config[v] = &graphql.EnumValueConfig{Value: v}
+	}
+	return graphql.NewEnum(graphql.EnumConfig{Name: name, Description: description, Values: config})
+}
+
+// omitEmpty resolves a field like the default resolver, but as null when
+// the value is the zero value of its type, such as an empty string
+func omitEmpty(p graphql.ResolveParams) (interface{}, error) {
+	v, err := graphql.DefaultResolveFn(p)
+	if err != nil || v == nil || reflect.ValueOf(v).IsZero() {
+		return nil, err
+	}
+	return v, nil
+}
+
+// bookPage is the result of the books query
+type bookPage struct {
+	Items      []*models.Book
+	TotalCount int
+	HasMore    bool
+}
+
+// pageSize returns the first argument of a list field after checking it.
+// The complexity of a query counts each list field as first items.
+func pageSize(args map[string]interface{}) (int, error) {
+	first, _ := args["first"].(int)
+	if first < 1 || first > graphqlMaxPage {
+		return 0, fmt.Errorf("first must be between 1 and %d", graphqlMaxPage)
+	}
+	return first, nil
+}
+
+// newGraphQLSchema builds the schema of /graphql. Its types mirror the JSON
+// of the REST endpoints with camelCase field names.
+func newGraphQLSchema() (*graphql.Schema, error) {
+	bookFormatEnum := enumOf("BookFormat", "The physical or digital form of a book.", models.BookFormats)
+	authorRoleEnum := enumOf("AuthorRole", "The part an author had in a book.", models.AuthorRoles)
+
+	categoryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Category",
+		Fields: graphql.Fields{
+			"id":   {Type: graphql.NewNonNull(graphql.ID)},
+			"name": {Type: graphql.NewNonNull(graphql.String)},
+			"slug": {Type: graphql.NewNonNull(graphql.String)},
+			"path": {Type: graphql.NewNonNull(graphql.String), Description: "Slugs of the category and its ancestors, from the root."},
+		},
+	})
+
+	availabilityType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Availability",
+		Description: "Copies of a book by circulation state; withdrawn copies are not counted.",
+		Fields: graphql.Fields{
+			"total":     {Type: graphql.NewNonNull(graphql.Int)},
+			"available": {Type: graphql.NewNonNull(graphql.Int)},
+			"onLoan":    {Type: graphql.NewNonNull(graphql.Int)},
+			"onHold":    {Type: graphql.NewNonNull(graphql.Int)},
+			"holds":     {Type: graphql.NewNonNull(graphql.Int), Description: "Members waiting for a copy."},
+		},
+	})
+
+	authorType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Author",
+		Fields: graphql.Fields{
+			"id":        {Type: graphql.NewNonNull(graphql.ID)},
+			"name":      {Type: graphql.NewNonNull(graphql.String)},
+			"bio":       {Type: graphql.String, Resolve: omitEmpty},
+			"bookCount": {Type: graphql.NewNonNull(graphql.Int)},
+			"createdAt": {Type: graphql.NewNonNull(dateTimeScalar)},
+			"updatedAt": {Type: graphql.NewNonNull(dateTimeScalar)},
+		},
+	})
+
+	bookAuthorType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "BookAuthor",
+		Description: "An author of a book in display order.",
+		Fields: graphql.Fields{
+			"author": {Type: authorType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				a := p.Source.(models.BookAuthor)
+				if a.AuthorID == "" {
+					return nil, nil
+				}
+				return graphqlState(p.Context).authors.Load(a.AuthorID), nil
+			}},
+			"name":     {Type: graphql.NewNonNull(graphql.String)},
+			"role":     {Type: graphql.NewNonNull(authorRoleEnum)},
+			"position": {Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	bookType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Book",
+		Fields: graphql.Fields{
+			"id":            {Type: graphql.NewNonNull(graphql.ID)},
+			"judul":         {Type: graphql.NewNonNull(graphql.String)},
+			"author":        {Type: graphql.NewNonNull(graphql.String), Description: "The authors as one line of text."},
+			"tahunTerbit":   {Type: graphql.NewNonNull(graphql.Int)},
+			"isbn":          {Type: graphql.String, Resolve: omitEmpty, Description: "ISBN-13 without hyphens."},
+			"publisher":     {Type: graphql.String, Resolve: omitEmpty},
+			"language":      {Type: graphql.String, Resolve: omitEmpty, Description: "BCP 47 language tag."},
+			"pages":         {Type: graphql.Int, Resolve: omitEmpty},
+			"description":   {Type: graphql.String, Resolve: omitEmpty},
+			"edition":       {Type: graphql.String, Resolve: omitEmpty},
+			"format":        {Type: bookFormatEnum, Resolve: omitEmpty},
+			"authors":       {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookAuthorType)))},
+			"categories":    {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType)))},
+			"tags":          {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"averageRating": {Type: graphql.NewNonNull(graphql.Float), Description: "Average of the approved reviews."},
+			"ratingCount":   {Type: graphql.NewNonNull(graphql.Int)},
+			"coverUrl":      {Type: graphql.String, Resolve: omitEmpty},
+			"availability": {Type: graphql.NewNonNull(availabilityType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return graphqlState(p.Context).availability.Load(p.Source.(*models.Book).ID), nil
+			}},
+			"createdAt": {Type: graphql.NewNonNull(dateTimeScalar)},
+			"updatedAt": {Type: graphql.NewNonNull(dateTimeScalar)},
+		},
+	})
+
+	authorType.AddFieldConfig("books", &graphql.Field{
+		Description: "The newest books of the author.",
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookType))),
+		Args:        graphql.FieldConfigArgument{"first": {Type: graphql.Int, DefaultValue: 10}},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			first, err := pageSize(p.Args)
+			if err != nil {
+				return nil, err
+			}
+			return graphqlState(p.Context).booksByAuthor(first).Load(p.Source.(*models.Author).ID), nil
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":              {Type: graphql.NewNonNull(graphql.ID)},
+			"username":        {Type: graphql.NewNonNull(graphql.String)},
+			"email":           {Type: graphql.String, Resolve: omitEmpty},
+			"role":            {Type: graphql.NewNonNull(graphql.String)},
+			"isActive":        {Type: graphql.NewNonNull(graphql.Boolean)},
+			"createdAt":       {Type: graphql.NewNonNull(dateTimeScalar)},
+			"lastLogin":       {Type: dateTimeScalar},
+			"emailVerifiedAt": {Type: dateTimeScalar},
+		},
+	})
+
+	bookPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "BookPage",
+		Fields: graphql.Fields{
+			"items":      {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookType)))},
+			"totalCount": {Type: graphql.NewNonNull(graphql.Int), Description: "Books matching the filter on all pages."},
+			"hasMore":    {Type: graphql.NewNonNull(graphql.Boolean)},
+		},
+	})
+
+	bookFilterInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "BookFilter",
+		Description: "Filters of the book list, as the query parameters of GET /api/books.",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"isbn":         {Type: graphql.String},
+			"publisher":    {Type: graphql.String},
+			"language":     {Type: graphql.String},
+			"format":       {Type: bookFormatEnum},
+			"authorId":     {Type: graphql.ID},
+			"category":     {Type: graphql.String, Description: "Slug or ID; books in descendant categories match too."},
+			"tags":         {Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"matchAllTags": {Type: graphql.Boolean, DefaultValue: false},
+			"minRating":    {Type: graphql.Float},
+			"minPages":     {Type: graphql.Int},
+			"maxPages":     {Type: graphql.Int},
+		},
+	})
+
+	bookAuthorInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "BookAuthorInput",
+		Description: "An existing author by authorId, or an author by name, which is created when unknown.",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"authorId": {Type: graphql.ID},
+			"name":     {Type: graphql.String},
+			"role":     {Type: authorRoleEnum, DefaultValue: models.AuthorRoleAuthor},
+		},
+	})
+	bookInputFields := func(required bool) graphql.InputObjectConfigFieldMap {
+		judul, tahunTerbit := graphql.Input(graphql.String), graphql.Input(graphql.Int)
+		if required {
+			judul, tahunTerbit = graphql.NewNonNull(judul), graphql.NewNonNull(tahunTerbit)
+		}
+		return graphql.InputObjectConfigFieldMap{
+			"judul":       {Type: judul},
+			"author":      {Type: graphql.String, Description: "Authors as one line of text, used when authors is not given."},
+			"tahunTerbit": {Type: tahunTerbit},
+			"isbn":        {Type: graphql.String},
+			"publisher":   {Type: graphql.String},
+			"language":    {Type: graphql.String},
+			"pages":       {Type: graphql.Int},
+			"description": {Type: graphql.String},
+			"edition":     {Type: graphql.String},
+			"format":      {Type: bookFormatEnum},
+			"authors":     {Type: graphql.NewList(graphql.NewNonNull(bookAuthorInput))},
+			"categories":  {Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Description: "Category slugs or IDs."},
+			"tags":        {Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+		}
+	}
+	createBookInput := graphql.NewInputObject(graphql.InputObjectConfig{Name: "CreateBookInput", Fields: bookInputFields(true)})
+	updateBookInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "UpdateBookInput",
+		Description: "Fields to change; authors, categories and tags replace the current ones, and an empty list clears categories or tags.",
+		Fields:      bookInputFields(false),
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"book": {
+				Type: bookType,
+				Args: graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return findGraphQLBook(p.Args["id"].(string)), nil
+				},
+			},
+			"books": {
+				Description: "Books that are not deleted, newest first unless sorted.",
+				Type:        graphql.NewNonNull(bookPageType),
+				Args: graphql.FieldConfigArgument{
+					"first":  {Type: graphql.Int, DefaultValue: 20},
+					"offset": {Type: graphql.Int, DefaultValue: 0},
+					"filter": {Type: bookFilterInput},
+					"sort":   {Type: graphql.String, Description: "One of created_at, judul, tahun_terbit, average_rating and rating_count, prefixed with - for descending order."},
+				},
+				Resolve: resolveBooks,
+			},
+			"author": {
+				Type: authorType,
+				Args: graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					author, err := authorRepo.GetAuthorByID(p.Args["id"].(string))
+					if errors.Is(err, repositories.ErrNotFound) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, errors.New("Failed to fetch author")
+					}
+					return author, nil
+				},
+			},
+			"authors": {
+				Description: "Authors ordered by name.",
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(authorType))),
+				Args: graphql.FieldConfigArgument{
+					"search": {Type: graphql.String, Description: "Part of the name."},
+					"first":  {Type: graphql.Int, DefaultValue: 50},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					first, err := pageSize(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					search, _ := p.Args["search"].(string)
+					authors, err := authorRepo.ListAuthors(strings.TrimSpace(search))
+					if err != nil {
+						return nil, errors.New("Failed to fetch authors")
+					}
+					return authors[:min(first, len(authors))], nil
+				},
+			},
+			"me": {
+				Description: "The authenticated user.",
+				Type:        graphql.NewNonNull(userType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return currentUser(graphqlState(p.Context).r), nil
+				},
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createBook": {
+				Type:    graphql.NewNonNull(bookType),
+				Args:    graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(createBookInput)}},
+				Resolve: resolveCreateBook,
+			},
+			"updateBook": {
+				Type: graphql.NewNonNull(bookType),
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.ID)},
+					"input": {Type: graphql.NewNonNull(updateBookInput)},
+				},
+				Resolve: resolveUpdateBook,
+			},
+			"deleteBook": {
+				Description: "Soft-deletes a book and returns it as it was.",
+				Type:        graphql.NewNonNull(bookType),
+				Args:        graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve:     resolveDeleteBook,
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// findGraphQLBook returns the book with id, or nil when there is none
+func findGraphQLBook(id string) *models.Book {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil
+	}
+	book, err := bookRepo.GetBookByID(id)
+	if err != nil {
+		return nil
+	}
+	return book
+}
+
+func resolveBooks(p graphql.ResolveParams) (interface{}, error) {
+	first, err := pageSize(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	offset, _ := p.Args["offset"].(int)
+	if offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+
+	// The filter is checked exactly like the query string of GET /api/books
+	values := url.Values{}
+	if sort, ok := p.Args["sort"].(string); ok {
+		values.Set("sort", sort)
+	}
+	if in, ok := p.Args["filter"].(map[string]interface{}); ok {
+		for param, field := range map[string]string{
+			"isbn": "isbn", "publisher": "publisher", "language": "language",
+			"format": "format", "author_id": "authorId", "category": "category",
+		} {
+			if v := inputString(in, field); v != "" {
+				values.Set(param, v)
+			}
+		}
+		if tags := inputStrings(in, "tags"); len(tags) > 0 {
+			values.Set("tags", strings.Join(tags, ","))
+		}
+		if in["matchAllTags"] == true {
+			values.Set("tags_match", "all")
+		}
+		if rating, ok := in["minRating"].(float64); ok {
+			values.Set("min_rating", strconv.FormatFloat(rating, 'f', -1, 64))
+		}
+		for param, field := range map[string]string{"min_pages": "minPages", "max_pages": "maxPages"} {
+			if n, ok := in[field].(int); ok {
+				values.Set(param, strconv.Itoa(n))
+			}
+		}
+	}
+	filter, msg := bookFilterFromValues(values)
+	if msg != "" {
+		return nil, errors.New(msg)
+	}
+
+	books, total, err := bookRepo.ListBooksPage(filter, first, offset)
+	if err != nil {
+		return nil, errors.New("Failed to fetch books")
+	}
+	return &bookPage{Items: books, TotalCount: total, HasMore: offset+len(books) < total}, nil
+}
+
+func resolveCreateBook(p graphql.ResolveParams) (interface{}, error) {
+	in := p.Args["input"].(map[string]interface{})
+	book, msg := newBookFromRequest(models.CreateBookRequest{
+		Judul:       inputString(in, "judul"),
+		Author:      inputString(in, "author"),
+		TahunTerbit: inputInt(in, "tahunTerbit"),
+		ISBN:        inputString(in, "isbn"),
+		Publisher:   inputString(in, "publisher"),
+		Language:    inputString(in, "language"),
+		Pages:       inputInt(in, "pages"),
+		Description: inputString(in, "description"),
+		Edition:     inputString(in, "edition"),
+		Format:      inputString(in, "format"),
+		Authors:     inputBookAuthors(in),
+		Categories:  inputStrings(in, "categories"),
+		Tags:        inputStrings(in, "tags"),
+	})
+	if msg != "" {
+		return nil, errors.New(msg)
+	}
+
+	r := graphqlState(p.Context).r
+	if err := bookRepo.WithAudit(auditContext(r)).CreateBook(book); err != nil {
+		_, message := bookSaveError(err, "Failed to create book")
+		return nil, errors.New(message)
+	}
+	return book, nil
+}
+
+func resolveUpdateBook(p graphql.ResolveParams) (interface{}, error) {
+	book := findGraphQLBook(p.Args["id"].(string))
+	if book == nil {
+		return nil, errors.New("Book not found")
+	}
+
+	in := p.Args["input"].(map[string]interface{})
+	restore, msg := applyBookUpdate(book, models.UpdateBookRequest{
+		Judul:       inputString(in, "judul"),
+		Author:      inputString(in, "author"),
+		TahunTerbit: inputInt(in, "tahunTerbit"),
+		ISBN:        inputString(in, "isbn"),
+		Publisher:   inputString(in, "publisher"),
+		Language:    inputString(in, "language"),
+		Pages:       inputInt(in, "pages"),
+		Description: inputString(in, "description"),
+		Edition:     inputString(in, "edition"),
+		Format:      inputString(in, "format"),
+		Authors:     inputBookAuthors(in),
+		Categories:  inputStrings(in, "categories"),
+		Tags:        inputStrings(in, "tags"),
+	})
+	if msg != "" {
+		return nil, errors.New(msg)
+	}
+
+	r := graphqlState(p.Context).r
+	if err := bookRepo.WithAudit(auditContext(r)).UpdateBook(book); err != nil {
+		_, message := bookSaveError(err, "Failed to update book")
+		return nil, errors.New(message)
+	}
+	restore()
+	return book, nil
+}
+
+func resolveDeleteBook(p graphql.ResolveParams) (interface{}, error) {
+	book := findGraphQLBook(p.Args["id"].(string))
+	if book == nil {
+		return nil, errors.New("Book not found")
+	}
+
+	r := graphqlState(p.Context).r
+	if err := bookRepo.WithAudit(auditContext(r)).DeleteBook(book.ID); err != nil {
+		return nil, errors.New("Failed to delete book")
+	}
+	return book, nil
+}
+
+// inputString returns a string field of an input object, or ""
+func inputString(in map[string]interface{}, name string) string {
+	s, _ := in[name].(string)
+	return s
+}
+
+// inputInt returns an Int field of an input object, or 0
+func inputInt(in map[string]interface{}, name string) int {
+	n, _ := in[name].(int)
+	return n
+}
+
+// inputStrings returns a list field of strings of an input object. It is
+// nil when the field is absent or null and empty when the list is.
+func inputStrings(in map[string]interface{}, name string) []string {
+	items, ok := in[name].([]interface{})
+	if !ok {
+		return nil
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// inputBookAuthors returns the authors field of a book input
+func inputBookAuthors(in map[string]interface{}) []models.BookAuthor {
+	items, _ := in["authors"].([]interface{})
+	var authors []models.BookAuthor
+	for _, item := range items {
+		a, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		authors = append(authors, models.BookAuthor{
+			AuthorID: inputString(a, "authorId"),
+			Name:     inputString(a, "name"),
+			Role:     inputString(a, "role"),
+		})
+	}
+	return authors
+}
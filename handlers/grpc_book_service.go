@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"rest-api-golang/bookpb"
+	"rest-api-golang/live"
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Page sizes of BookService.ListBooks
+const (
+	grpcDefaultPageSize = 20
+	grpcMaxPageSize     = 100
+)
+
+// bookService implements bookpb.BookServiceServer over the same
+// repositories and validation as the REST book handlers
+type bookService struct {
+	bookpb.UnimplementedBookServiceServer
+}
+
+func (s *bookService) ListBooks(ctx context.Context, req *bookpb.ListBooksRequest) (*bookpb.ListBooksResponse, error) {
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = grpcDefaultPageSize
+	}
+	if pageSize < 1 || pageSize > grpcMaxPageSize {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be between 1 and %d", grpcMaxPageSize)
+	}
+	// The page token is the offset of the page
+	offset := 0
+	if token := req.GetPageToken(); token != "" {
+		n, err := strconv.Atoi(token)
+		if err != nil || n < 0 {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page_token")
+		}
+		offset = n
+	}
+
+	// The filters are checked exactly like the query string of GET /api/books
+	values := url.Values{}
+	for param, v := range map[string]string{
+		"isbn": req.GetIsbn(), "publisher": req.GetPublisher(), "language": req.GetLanguage(),
+		"format": req.GetFormat(), "author_id": req.GetAuthorId(), "category": req.GetCategory(),
+		"sort": req.GetSort(),
+	} {
+		if v != "" {
+			values.Set(param, v)
+		}
+	}
+	if len(req.GetTags()) > 0 {
+		values.Set("tags", strings.Join(req.GetTags(), ","))
+	}
+	if req.GetMatchAllTags() {
+		values.Set("tags_match", "all")
+	}
+	if req.GetMinRating() != 0 {
+		values.Set("min_rating", strconv.FormatFloat(req.GetMinRating(), 'f', -1, 64))
+	}
+	for param, n := range map[string]int32{"min_pages": req.GetMinPages(), "max_pages": req.GetMaxPages()} {
+		if n != 0 {
+			values.Set(param, strconv.Itoa(int(n)))
+		}
+	}
+	filter, msg := bookFilterFromValues(values)
+	if msg != "" {
+		return nil, status.Error(codes.InvalidArgument, msg)
+	}
+
+	books, total, err := bookRepo.ListBooksPage(filter, pageSize, offset)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to fetch books")
+	}
+
+	resp := &bookpb.ListBooksResponse{TotalSize: int32(total)}
+	for _, book := range books {
+		resp.Books = append(resp.Books, bookToProto(book))
+	}
+	if next := offset + len(books); next < total {
+		resp.NextPageToken = strconv.Itoa(next)
+	}
+	return resp, nil
+}
+
+// findBook returns the book with id, or a NotFound error
+func (s *bookService) findBook(id string) (*models.Book, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, status.Error(codes.NotFound, "Book not found")
+	}
+	book, err := bookRepo.GetBookByID(id)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Book not found")
+	}
+	return book, nil
+}
+
+func (s *bookService) GetBook(ctx context.Context, req *bookpb.GetBookRequest) (*bookpb.Book, error) {
+	book, err := s.findBook(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	availability, err := copyRepo.GetAvailability(book.ID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to fetch availability")
+	}
+	book.Availability = availability
+	return bookToProto(book), nil
+}
+
+func (s *bookService) CreateBook(ctx context.Context, req *bookpb.CreateBookRequest) (*bookpb.Book, error) {
+	in := req.GetBook()
+	book, msg := newBookFromRequest(models.CreateBookRequest{
+		Judul:       in.GetJudul(),
+		Author:      in.GetAuthor(),
+		TahunTerbit: int(in.GetTahunTerbit()),
+		ISBN:        in.GetIsbn(),
+		Publisher:   in.GetPublisher(),
+		Language:    in.GetLanguage(),
+		Pages:       int(in.GetPages()),
+		Description: in.GetDescription(),
+		Edition:     in.GetEdition(),
+		Format:      in.GetFormat(),
+		Authors:     bookAuthorsFromProto(in.GetAuthors()),
+		Categories:  in.GetCategories(),
+		Tags:        in.GetTags(),
+	})
+	if msg != "" {
+		return nil, status.Error(codes.InvalidArgument, msg)
+	}
+
+	if err := bookRepo.WithAudit(auditContext(grpcHTTPRequest(ctx))).CreateBook(book); err != nil {
+		return nil, grpcError(bookSaveError(err, "Failed to create book"))
+	}
+	return bookToProto(book), nil
+}
+
+func (s *bookService) UpdateBook(ctx context.Context, req *bookpb.UpdateBookRequest) (*bookpb.Book, error) {
+	book, err := s.findBook(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	update, msg := bookUpdateFromProto(req)
+	if msg != "" {
+		return nil, status.Error(codes.InvalidArgument, msg)
+	}
+	restore, msg := applyBookUpdate(book, update)
+	if msg != "" {
+		return nil, status.Error(codes.InvalidArgument, msg)
+	}
+
+	if err := bookRepo.WithAudit(auditContext(grpcHTTPRequest(ctx))).UpdateBook(book); err != nil {
+		return nil, grpcError(bookSaveError(err, "Failed to update book"))
+	}
+	restore()
+	return bookToProto(book), nil
+}
+
+// bookUpdateFromProto converts an update request. Without an update mask
+// the fields that are set are changed; with one only the listed fields
+// are, and categories or tags listed with an empty list are cleared. It
+// returns an error message, or "" if the mask is valid.
+func bookUpdateFromProto(req *bookpb.UpdateBookRequest) (models.UpdateBookRequest, string) {
+	in := req.GetBook()
+	all := models.UpdateBookRequest{
+		Judul:       in.GetJudul(),
+		Author:      in.GetAuthor(),
+		TahunTerbit: int(in.GetTahunTerbit()),
+		ISBN:        in.GetIsbn(),
+		Publisher:   in.GetPublisher(),
+		Language:    in.GetLanguage(),
+		Pages:       int(in.GetPages()),
+		Description: in.GetDescription(),
+		Edition:     in.GetEdition(),
+		Format:      in.GetFormat(),
+		Authors:     bookAuthorsFromProto(in.GetAuthors()),
+		Categories:  in.GetCategories(),
+		Tags:        in.GetTags(),
+	}
+	if len(req.GetUpdateMask().GetPaths()) == 0 {
+		// An empty list means unchanged without a mask
+		if len(all.Categories) == 0 {
+			all.Categories = nil
+		}
+		if len(all.Tags) == 0 {
+			all.Tags = nil
+		}
+		return all, ""
+	}
+
+	var update models.UpdateBookRequest
+	for _, path := range req.GetUpdateMask().GetPaths() {
+		switch path {
+		case "judul":
+			update.Judul = all.Judul
+		case "author":
+			update.Author = all.Author
+		case "tahun_terbit":
+			update.TahunTerbit = all.TahunTerbit
+		case "isbn":
+			update.ISBN = all.ISBN
+		case "publisher":
+			update.Publisher = all.Publisher
+		case "language":
+			update.Language = all.Language
+		case "pages":
+			update.Pages = all.Pages
+		case "description":
+			update.Description = all.Description
+		case "edition":
+			update.Edition = all.Edition
+		case "format":
+			update.Format = all.Format
+		case "authors":
+			update.Authors = all.Authors
+		case "categories":
+			update.Categories = append([]string{}, all.Categories...)
+		case "tags":
+			update.Tags = append([]string{}, all.Tags...)
+		default:
+			return update, "Unknown field in update_mask: " + path
+		}
+	}
+	return update, ""
+}
+
+func (s *bookService) DeleteBook(ctx context.Context, req *bookpb.DeleteBookRequest) (*bookpb.Book, error) {
+	book, err := s.findBook(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := bookRepo.WithAudit(auditContext(grpcHTTPRequest(ctx))).DeleteBook(book.ID); err != nil {
+		return nil, status.Error(codes.Internal, "Failed to delete book")
+	}
+	return bookToProto(book), nil
+}
+
+// WatchBooks streams book changes from the live feed hub, after replaying
+// the events since after_sequence
+func (s *bookService) WatchBooks(req *bookpb.WatchBooksRequest, stream bookpb.BookService_WatchBooksServer) error {
+	if liveHub == nil {
+		return status.Error(codes.Unavailable, "Live feed is not available")
+	}
+	filter := live.Filter{AggregateID: req.GetBookId()}
+	for _, t := range req.GetTypes() {
+		if !isOneOf(t, liveEventTypes) {
+			return status.Error(codes.InvalidArgument, "types must be one of "+strings.Join(liveEventTypes, ", "))
+		}
+		filter.Types = append(filter.Types, t)
+	}
+	if req.GetAfterSequence() < 0 {
+		return status.Error(codes.InvalidArgument, "Invalid after_sequence")
+	}
+
+	feed, err := subscribeLiveFeed(filter, req.GetAfterSequence())
+	if err != nil {
+		log.Printf("Live feed replay failed: %v", err)
+		return status.Error(codes.Internal, "Failed to replay events")
+	}
+	defer liveHub.Unsubscribe(feed.sub)
+
+	if feed.reset {
+		reset := &bookpb.WatchBooksResponse{Message: &bookpb.WatchBooksResponse_StreamReset{StreamReset: &bookpb.StreamReset{}}}
+		if err := stream.Send(reset); err != nil {
+			return err
+		}
+	}
+	// Idle streams are kept alive by the server's keepalive pings
+	lagged, err := feed.run(stream.Context().Done(), func(e *models.OutboxEvent) error {
+		return stream.Send(&bookpb.WatchBooksResponse{
+			Message: &bookpb.WatchBooksResponse_Event{Event: bookEventToProto(e)},
+		})
+	}, func() error { return nil })
+	if lagged {
+		return status.Error(codes.ResourceExhausted, "Client fell behind; resume with after_sequence")
+	}
+	return err
+}
+
+func bookEventToProto(e *models.OutboxEvent) *bookpb.BookEvent {
+	event := &bookpb.BookEvent{
+		Sequence:  e.Sequence,
+		Id:        e.ID,
+		Type:      e.Type,
+		BookId:    e.AggregateID,
+		CreatedAt: timestamppb.New(e.CreatedAt),
+	}
+	var book models.Book
+	if err := json.Unmarshal(e.Payload, &book); err == nil {
+		event.Book = bookToProto(&book)
+	}
+	return event
+}
+
+func bookToProto(book *models.Book) *bookpb.Book {
+	b := &bookpb.Book{
+		Id:            book.ID,
+		Judul:         book.Judul,
+		Author:        book.Author,
+		TahunTerbit:   int32(book.TahunTerbit),
+		Isbn:          book.ISBN,
+		Publisher:     book.Publisher,
+		Language:      book.Language,
+		Pages:         int32(book.Pages),
+		Description:   book.Description,
+		Edition:       book.Edition,
+		Format:        book.Format,
+		Tags:          book.Tags,
+		AverageRating: book.AverageRating,
+		RatingCount:   int32(book.RatingCount),
+		CoverUrl:      book.CoverURL,
+		CreatedAt:     timestamppb.New(book.CreatedAt),
+		UpdatedAt:     timestamppb.New(book.UpdatedAt),
+	}
+	for _, a := range book.Authors {
+		b.Authors = append(b.Authors, &bookpb.BookAuthor{
+			AuthorId: a.AuthorID,
+			Name:     a.Name,
+			Role:     a.Role,
+			Position: int32(a.Position),
+		})
+	}
+	for _, c := range book.Categories {
+		b.Categories = append(b.Categories, &bookpb.Category{Id: c.ID, Name: c.Name, Slug: c.Slug, Path: c.Path})
+	}
+	if a := book.Availability; a != nil {
+		b.Availability = &bookpb.Availability{
+			Total:     int32(a.Total),
+			Available: int32(a.Available),
+			OnLoan:    int32(a.OnLoan),
+			OnHold:    int32(a.OnHold),
+			Holds:     int32(a.Holds),
+		}
+	}
+	return b
+}
+
+func bookAuthorsFromProto(authors []*bookpb.BookAuthor) []models.BookAuthor {
+	var result []models.BookAuthor
+	for _, a := range authors {
+		result = append(result, models.BookAuthor{
+			AuthorID: a.GetAuthorId(),
+			Name:     a.GetName(),
+			Role:     a.GetRole(),
+			Position: int(a.GetPosition()),
+		})
+	}
+	return result
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"rest-api-golang/bookpb"
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcPublicMethods can be called without a session token, besides the
+// health and reflection services
+var grpcPublicMethods = []string{bookpb.AuthService_Login_FullMethodName}
+
+// NewGRPCServer creates the gRPC server of BookService and AuthService,
+// which authenticates calls with the same session tokens as
+// AuthMiddleware. It also serves the standard health service and server
+// reflection for tools such as grpcurl.
+func NewGRPCServer() *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(grpcUnaryAuth),
+		grpc.ChainStreamInterceptor(grpcStreamAuth),
+		// WatchBooks streams may be idle for long; pings keep them open
+		// through proxies and detect dead clients
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: liveHeartbeat}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: 10 * time.Second, PermitWithoutStream: true}),
+	)
+	bookpb.RegisterBookServiceServer(s, &bookService{})
+	bookpb.RegisterAuthServiceServer(s, &authService{})
+
+	healthServer := health.NewServer()
+	for _, service := range []string{"", bookpb.BookService_ServiceDesc.ServiceName, bookpb.AuthService_ServiceDesc.ServiceName} {
+		healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+	healthpb.RegisterHealthServer(s, healthServer)
+	reflection.Register(s)
+	return s
+}
+
+type grpcTokenContextKey struct{}
+
+// grpcAuthenticate checks the bearer token in the authorization metadata
+// of a call like AuthMiddleware, and returns the context of the call with
+// its user and request ID
+func grpcAuthenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := firstMetadata(md, "x-request-id")
+	if !validRequestID(id) {
+		id = uuid.New().String()
+	}
+	ctx = context.WithValue(ctx, requestIDContextKey, id)
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+
+	if isOneOf(method, grpcPublicMethods) ||
+		strings.HasPrefix(method, "/grpc.health.") ||
+		strings.HasPrefix(method, "/grpc.reflection.") {
+		return ctx, nil
+	}
+
+	authHeader := firstMetadata(md, "authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "Missing or invalid authorization metadata")
+	}
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "Invalid token")
+	}
+	user, err := userForToken(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Token expired or invalid")
+	}
+
+	ctx = context.WithValue(ctx, userContextKey, user)
+	return context.WithValue(ctx, grpcTokenContextKey{}, token), nil
+}
+
+func grpcUnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := grpcAuthenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func grpcStreamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := grpcAuthenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream is a server stream with the context of its
+// authenticated call
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// grpcHTTPRequest describes a call as an HTTP request, so the helpers
+// taking one (current user, audit context, sessions) serve gRPC too
+func grpcHTTPRequest(ctx context.Context) *http.Request {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range []string{"user-agent", "x-forwarded-for"} {
+		if v := firstMetadata(md, key); v != "" {
+			r.Header.Set(key, v)
+		}
+	}
+	return r
+}
+
+// grpcError converts the status and message of a REST error response to
+// a gRPC status error
+func grpcError(httpStatus int, message string) error {
+	code := codes.Internal
+	switch httpStatus {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	}
+	return status.Error(code, message)
+}
+
+// timestampOrNil converts an optional time
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// authService implements bookpb.AuthServiceServer
+type authService struct {
+	bookpb.UnimplementedAuthServiceServer
+}
+
+// Login checks the password like POST /api/login and issues a session
+// token. Accounts needing a second factor must use the REST flow.
+func (s *authService) Login(ctx context.Context, req *bookpb.LoginRequest) (*bookpb.LoginResponse, error) {
+	login, err := passwordLogin(grpcHTTPRequest(ctx), req.GetUsername(), req.GetPassword())
+	if errors.Is(err, errInvalidLogin) {
+		return nil, status.Error(codes.Unauthenticated, "Invalid username or password")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to create session")
+	}
+	if login.MFARequired {
+		return nil, status.Error(codes.FailedPrecondition, "Two-factor authentication is required; sign in through POST /api/login")
+	}
+	return &bookpb.LoginResponse{Token: login.Token, ExpiresAt: timestamppb.New(time.Now().Add(sessionTTL))}, nil
+}
+
+// Logout revokes the token of the call like POST /api/logout
+func (s *authService) Logout(ctx context.Context, req *bookpb.LogoutRequest) (*bookpb.LogoutResponse, error) {
+	token, _ := ctx.Value(grpcTokenContextKey{}).(string)
+	if err := tokenRepo.WithAudit(auditContext(grpcHTTPRequest(ctx))).RevokeToken(token); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, "Token not found or already revoked")
+	}
+	return &bookpb.LogoutResponse{}, nil
+}
+
+func (s *authService) GetCurrentUser(ctx context.Context, req *bookpb.GetCurrentUserRequest) (*bookpb.User, error) {
+	return userToProto(currentUser(grpcHTTPRequest(ctx))), nil
+}
+
+func userToProto(user *models.User) *bookpb.User {
+	return &bookpb.User{
+		Id:              user.ID,
+		Username:        user.Username,
+		Email:           user.Email,
+		Role:            user.Role,
+		IsActive:        user.IsActive,
+		CreatedAt:       timestamppb.New(user.CreatedAt),
+		LastLogin:       timestampOrNil(user.LastLogin),
+		EmailVerifiedAt: timestampOrNil(user.EmailVerifiedAt),
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"rest-api-golang/bookpb"
+	"rest-api-golang/database"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// loginFailures counts the failed logins in the audit log for a reason
+func loginFailures(t *testing.T, reason string) int {
+	t.Helper()
+	var n int
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*) FROM audit_events
+		WHERE action = 'login_failed' AND after->>'reason' = $1`, reason).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// TestLoginsShareChecks logs in through REST and gRPC, which must check
+// and record the same way
+func TestLoginsShareChecks(t *testing.T) {
+	srv := newTestServer(t)
+	auth := &authService{}
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		username, password, reason string
+	}{
+		{"user", "wrong", "wrong password"},
+		{"nobody", "user123", "unknown user"},
+	} {
+		before := loginFailures(t, tt.reason)
+		var resp struct {
+			Message string `json:"message"`
+		}
+		if status := srv.do(t, "POST", "/api/login", "", LoginRequest{Username: tt.username, Password: tt.password}, &resp); status != http.StatusUnauthorized || resp.Message != "Invalid username or password" {
+			t.Errorf("REST login as %s/%s: %d %q", tt.username, tt.password, status, resp.Message)
+		}
+		_, err := auth.Login(ctx, &bookpb.LoginRequest{Username: tt.username, Password: tt.password})
+		if s, _ := status.FromError(err); s.Code() != codes.Unauthenticated || s.Message() != "Invalid username or password" {
+			t.Errorf("gRPC login as %s/%s: %v", tt.username, tt.password, err)
+		}
+		if n := loginFailures(t, tt.reason) - before; n != 2 {
+			t.Errorf("%d failures recorded for %q, want one per login", n, tt.reason)
+		}
+	}
+
+	resp, err := auth.Login(ctx, &bookpb.LoginRequest{Username: "user", Password: "user123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := srv.do(t, "GET", "/api/mfa", resp.GetToken(), nil, nil); status != http.StatusOK {
+		t.Errorf("gRPC session on REST: status %d", status)
+	}
+}
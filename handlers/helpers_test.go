@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rest-api-golang/graphql"
+	"rest-api-golang/internal/testdb"
+	"rest-api-golang/mailer"
+	"rest-api-golang/models"
+	"rest-api-golang/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// testServer serves the real router, behind the auth middleware, on a
+// fresh test database
+type testServer struct {
+	*httptest.Server
+	mail *mailer.MemoryMailer
+}
+
+// newTestServer sets up the handlers like main does, with in-memory mail
+// and blob storage. It skips the test without a test database.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return startTestServer(t, func(router *mux.Router) http.Handler { return router })
+}
+
+// startTestServer is newTestServer with wrap applied to the router inside
+// the middleware, like OPENAPI_VALIDATE in main
+func startTestServer(t *testing.T, wrap func(*mux.Router) http.Handler) *testServer {
+	t.Helper()
+	testdb.Open(t)
+	InitializeRepositories()
+	mail := mailer.NewMemoryMailer()
+	InitializeMailer(mail, "library@example.com")
+	InitializeBlobStore(storage.NewMemoryStore())
+	if err := InitializeGraphQL(&graphql.Config{MaxDepth: 10, MaxComplexity: 1000}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(RequestIDMiddleware(AuthMiddleware(wrap(NewRouter()))))
+	t.Cleanup(srv.Close)
+	return &testServer{Server: srv, mail: mail}
+}
+
+// do sends body as JSON with the session token, if any, decodes the JSON
+// response into out, if given, and returns the status
+func (s *testServer) do(t *testing.T, method, path, token string, body, out interface{}) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// login returns a session token of a user without two-factor
+// authentication
+func (s *testServer) login(t *testing.T, username, password string) string {
+	t.Helper()
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if status := s.do(t, "POST", "/api/login", "", LoginRequest{Username: username, Password: password}, &resp); status != http.StatusOK || resp.Token == "" {
+		t.Fatalf("login as %s: status %d", username, status)
+	}
+	return resp.Token
+}
+
+// addMember creates a member with the default role and returns a session
+// token for it
+func (s *testServer) addMember(t *testing.T, admin, username string) string {
+	t.Helper()
+	req := models.CreateUserRequest{Username: username, Password: username + "-password"}
+	if status := s.do(t, "POST", "/api/users", admin, req, nil); status != http.StatusCreated {
+		t.Fatalf("create user %s: status %d", username, status)
+	}
+	return s.login(t, username, req.Password)
+}
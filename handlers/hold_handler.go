@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// writeHoldError maps hold repository errors to a response
+func writeHoldError(w http.ResponseWriter, err error, fallback string) {
+	status := http.StatusInternalServerError
+	message := fallback
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		status = http.StatusNotFound
+		message = capitalize(err.Error())
+	case errors.Is(err, repositories.ErrDuplicate):
+		status = http.StatusConflict
+		message = "Member already has an open hold on this book"
+	case errors.Is(err, repositories.ErrNotAvailable):
+		status = http.StatusConflict
+		message = "Hold is already closed"
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// PlaceHold handles POST /api/books/{id}/holds. Members join the queue for
+// themselves; admins may pass {"user_id": "..."} to place a hold for a
+// member. If a copy is available it is set aside right away.
+func PlaceHold(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	book, err := bookRepo.GetBookByID(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Book not found",
+		})
+		return
+	}
+
+	var req models.PlaceHoldRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Invalid JSON format",
+			})
+			return
+		}
+	}
+	userID := user.ID
+	if req.UserID != "" && req.UserID != user.ID {
+		if !requireAdmin(w, r) {
+			return
+		}
+		if _, err := uuid.Parse(req.UserID); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Invalid user_id",
+			})
+			return
+		}
+		userID = req.UserID
+	}
+
+	loans, err := loanRepo.ListLoans(models.LoanFilter{UserID: userID, BookID: book.ID, Status: models.LoanStatusActive})
+	if err != nil {
+		writeHoldError(w, err, "Failed to fetch loans")
+		return
+	}
+	if len(loans) > 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Member already has this book on loan",
+		})
+		return
+	}
+
+	hold, err := holdRepo.WithAudit(auditContext(r)).PlaceHold(book.ID, userID)
+	if err != nil {
+		writeHoldError(w, err, "Failed to place hold")
+		return
+	}
+
+	message := fmt.Sprintf("Hold placed, position %d in the queue", hold.Position)
+	if hold.Status == models.HoldStatusReady {
+		message = "Hold placed, a copy is ready for pickup"
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": message,
+		"data":    hold,
+	})
+}
+
+// GetHolds handles GET /api/holds. Admins see all holds and may filter by
+// ?user_id= and ?book_id=; members only see their own. ?status= filters by
+// hold status.
+func GetHolds(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+	q := r.URL.Query()
+
+	filter := models.HoldFilter{
+		UserID: q.Get("user_id"),
+		BookID: q.Get("book_id"),
+		Status: q.Get("status"),
+	}
+	if user.Role != "admin" {
+		filter.UserID = user.ID
+	}
+
+	msg := ""
+	for _, id := range []string{filter.UserID, filter.BookID} {
+		if _, err := uuid.Parse(id); id != "" && err != nil {
+			msg = "Invalid user_id or book_id filter"
+		}
+	}
+	if filter.Status != "" && !isOneOf(filter.Status, models.HoldStatuses) {
+		msg = "Invalid status filter, expected one of: " + strings.Join(models.HoldStatuses, ", ")
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	holds, err := holdRepo.ListHolds(filter)
+	if err != nil {
+		writeHoldError(w, err, "Failed to fetch holds")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    holds,
+		"count":   len(holds),
+	})
+}
+
+// GetBookHolds handles GET /api/books/{id}/holds (admin): the open holds
+// on a book, ready holds first and then the waiting queue in order
+func GetBookHolds(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	book, err := bookRepo.GetBookByID(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Book not found",
+		})
+		return
+	}
+
+	var holds []*models.Hold
+	for _, status := range []string{models.HoldStatusReady, models.HoldStatusWaiting} {
+		list, err := holdRepo.ListHolds(models.HoldFilter{BookID: book.ID, Status: status})
+		if err != nil {
+			writeHoldError(w, err, "Failed to fetch holds")
+			return
+		}
+		holds = append(holds, list...)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    holds,
+		"count":   len(holds),
+	})
+}
+
+// getOwnHold loads the hold in the URL and writes an error response unless
+// it belongs to the current user or the user is an admin
+func getOwnHold(w http.ResponseWriter, r *http.Request) (*models.Hold, bool) {
+	hold, err := holdRepo.GetHoldByID(mux.Vars(r)["id"])
+	user := currentUser(r)
+	if err == nil && hold.UserID != user.ID && user.Role != "admin" {
+		err = fmt.Errorf("hold %w", repositories.ErrNotFound)
+	}
+	if err != nil {
+		writeHoldError(w, err, "Failed to fetch hold")
+		return nil, false
+	}
+	return hold, true
+}
+
+// GetHold handles GET /api/holds/{id}
+func GetHold(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	hold, ok := getOwnHold(w, r)
+	if !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    hold,
+	})
+}
+
+// CancelHold handles DELETE /api/holds/{id} for the member or an admin. A
+// copy set aside for the hold passes to the next member in the queue.
+func CancelHold(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	hold, ok := getOwnHold(w, r)
+	if !ok {
+		return
+	}
+
+	hold, err := holdRepo.WithAudit(auditContext(r)).CancelHold(hold.ID)
+	if err != nil {
+		writeHoldError(w, err, "Failed to cancel hold")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Hold cancelled successfully",
+		"data":    hold,
+	})
+}
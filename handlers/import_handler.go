@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"rest-api-golang/importer"
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// importBatchSize is the number of rows saved per transaction
+	importBatchSize = 500
+	// defaultImportMaxBytes is the upload limit when IMPORT_MAX_BYTES is unset
+	defaultImportMaxBytes = 50 << 20
+	// defaultImportSyncRows is the largest file imported within the request
+	// when IMPORT_SYNC_ROWS is unset; larger files run as a job
+	defaultImportSyncRows = 1000
+)
+
+// importLimit reads a positive integer setting from the environment
+func importLimit(key string, def int64) int64 {
+	if n, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// writeImportError writes a JSON error response
+func writeImportError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// importUpload is a file sent to POST /api/books/import
+type importUpload struct {
+	data        []byte
+	fileName    string
+	contentType string
+	mapping     string
+}
+
+// readImportUpload reads the "file" and optional "mapping" parts of a
+// multipart body, or the raw body for any other content type
+func readImportUpload(r *http.Request) (*importUpload, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &importUpload{data: data, contentType: r.Header.Get("Content-Type")}, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	upload := &importUpload{}
+	found := false
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch part.FormName() {
+		case "file":
+			upload.data, err = io.ReadAll(part)
+			upload.fileName = part.FileName()
+			upload.contentType = part.Header.Get("Content-Type")
+			found = true
+		case "mapping":
+			var mapping []byte
+			mapping, err = io.ReadAll(io.LimitReader(part, 64<<10))
+			upload.mapping = string(mapping)
+		}
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, errors.New("missing file")
+	}
+	return upload, nil
+}
+
+// importSaveErrorMessage describes why a row could not be saved, in the
+// words of writeBookSaveError
+func importSaveErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, repositories.ErrDuplicate):
+		return "A book with this ISBN already exists"
+	case errors.Is(err, repositories.ErrNotFound):
+		return "Unknown reference: " + err.Error()
+	}
+	log.Printf("Failed to import book: %v", err)
+	return "Failed to save book"
+}
+
+// runImport validates the records and saves them in batches, calling
+// progress after every batch. Rows are validated like POST /api/books, and
+// an ISBN repeated within the file fails every row after the first. The
+// books saved are audited as changed in the context a.
+func runImport(job *models.ImportJob, records []importer.Record, a *models.AuditContext, progress func()) {
+	seen := map[string]int{}
+	var batch []*models.Book
+	var rows []importer.Record
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		results, err := bookRepo.WithAudit(a).ImportBooks(batch, job.Upsert, job.DryRun)
+		for i, record := range rows {
+			switch {
+			case err != nil:
+				job.AddError(record.Row, batch[i].ISBN, "Failed to save batch")
+			case results[i].Err != nil:
+				job.AddError(record.Row, batch[i].ISBN, importSaveErrorMessage(results[i].Err))
+			case results[i].Updated:
+				job.Updated++
+			default:
+				job.Created++
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to import batch: %v", err)
+		}
+		job.Processed += len(batch)
+		batch, rows = nil, nil
+		progress()
+	}
+
+	for _, record := range records {
+		if record.Err != nil {
+			job.AddError(record.Row, record.Book.ISBN, capitalize(record.Err.Error()))
+			job.Processed++
+			continue
+		}
+		book, msg := newBookFromRequest(record.Book)
+		if msg == "" && book.ISBN != "" {
+			if first, ok := seen[book.ISBN]; ok {
+				msg = fmt.Sprintf("Duplicate ISBN, already on row %d", first)
+			} else {
+				seen[book.ISBN] = record.Row
+			}
+		}
+		if msg != "" {
+			job.AddError(record.Row, record.Book.ISBN, msg)
+			job.Processed++
+			continue
+		}
+
+		batch = append(batch, book)
+		rows = append(rows, record)
+		if len(batch) == importBatchSize {
+			flush()
+		}
+	}
+	flush()
+}
+
+// ImportRecords imports parsed records outside of a request, validated and
+// saved like POST /api/books/import, and returns the report. The books
+// are audited as changed in the context a. InitializeRepositories must
+// have been called.
+func ImportRecords(records []importer.Record, upsert, dryRun bool, a *models.AuditContext) *models.ImportReport {
+	job := models.NewImportJob("", "", "", dryRun, upsert, len(records))
+	runImport(job, records, a, func() {})
+	return &job.ImportReport
+}
+
+// runImportJob runs an import in the background, storing its progress
+// after every batch so it can be polled. The job fails when no row could
+// be imported, and when the import panics, which is logged instead of
+// taking the server down.
+func runImportJob(job *models.ImportJob, records []importer.Record, a *models.AuditContext) {
+	save := func() {
+		if err := importRepo.UpdateImportJob(job); err != nil {
+			log.Printf("Failed to save import job %s: %v", job.ID, err)
+		}
+	}
+	finish := func(status, message string) {
+		finished := time.Now()
+		job.Status = status
+		job.Message = message
+		job.FinishedAt = &finished
+		save()
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Import job %s panicked: %v\n%s", job.ID, p, debug.Stack())
+			finish(models.ImportStatusFailed, "Stopped by an internal error")
+		}
+	}()
+
+	started := time.Now()
+	job.Status = models.ImportStatusRunning
+	job.StartedAt = &started
+	save()
+
+	runImport(job, records, a, save)
+
+	if job.Created+job.Updated == 0 {
+		finish(models.ImportStatusFailed, "No rows could be imported")
+	} else {
+		finish(models.ImportStatusCompleted, "")
+	}
+	log.Printf("Import job %s %s: %d created, %d updated, %d failed", job.ID, job.Status, job.Created, job.Updated, job.Failed)
+}
+
+// ImportBooks handles POST /api/books/import (admin). The file is sent as
+// the "file" field of a multipart body, or as the raw request body.
+// ?format=csv|jsonl|marc|marcxml overrides detection from the file name,
+// content type and contents. ?mapping= (or a "mapping" form field) is a
+// JSON object renaming CSV columns to book fields. ?mode=upsert updates
+// books whose ISBN already exists instead of reporting them, and
+// ?dry_run=true validates everything without saving. Files with more than
+// IMPORT_SYNC_ROWS rows, or any file with ?async=true, are imported by a
+// background job that is polled at GET /api/books/import/{id}.
+func ImportBooks(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	mode := q.Get("mode")
+	if mode != "" && mode != "insert" && mode != "upsert" {
+		writeImportError(w, http.StatusBadRequest, "Invalid mode, expected insert or upsert")
+		return
+	}
+
+	limit := importLimit("IMPORT_MAX_BYTES", defaultImportMaxBytes)
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	upload, err := readImportUpload(r)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeImportError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import file must not be larger than %d bytes", limit))
+		return
+	}
+	if err != nil || len(upload.data) == 0 {
+		writeImportError(w, http.StatusBadRequest, "Expected an import file as the \"file\" field of a multipart body or as the request body")
+		return
+	}
+
+	format := q.Get("format")
+	if format == "" {
+		format = importer.DetectFormat(upload.fileName, upload.contentType, upload.data)
+	}
+	if !isOneOf(format, models.ImportFormats) {
+		writeImportError(w, http.StatusBadRequest, "Unknown import format, pass ?format= with one of: "+strings.Join(models.ImportFormats, ", "))
+		return
+	}
+
+	var mapping map[string]string
+	if raw := q.Get("mapping"); raw != "" {
+		upload.mapping = raw
+	}
+	if upload.mapping != "" {
+		if err := json.Unmarshal([]byte(upload.mapping), &mapping); err != nil {
+			writeImportError(w, http.StatusBadRequest, `Invalid mapping, expected a JSON object such as {"Title": "judul"}`)
+			return
+		}
+	}
+
+	records, err := importer.Parse(format, upload.data, mapping)
+	if err != nil {
+		writeImportError(w, http.StatusBadRequest, "Invalid import file: "+err.Error())
+		return
+	}
+	if len(records) == 0 {
+		writeImportError(w, http.StatusBadRequest, "Import file has no rows")
+		return
+	}
+
+	job := models.NewImportJob(currentUser(r).ID, format, upload.fileName, q.Get("dry_run") == "true", mode == "upsert", len(records))
+
+	if q.Get("async") == "true" || int64(len(records)) > importLimit("IMPORT_SYNC_ROWS", defaultImportSyncRows) {
+		if err := importRepo.CreateImportJob(job); err != nil {
+			writeImportError(w, http.StatusInternalServerError, "Failed to create import job")
+			return
+		}
+		go runImportJob(job, records, auditContext(r))
+
+		w.Header().Set("Location", "/api/books/import/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Import of %d rows started", len(records)),
+			"data":    job,
+		})
+		return
+	}
+
+	runImport(job, records, auditContext(r), func() {})
+	message := fmt.Sprintf("Imported %d books: %d created, %d updated, %d failed", job.Created+job.Updated, job.Created, job.Updated, job.Failed)
+	if job.DryRun {
+		message = fmt.Sprintf("Dry run: %d books would be created, %d updated, %d failed", job.Created, job.Updated, job.Failed)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": message,
+		"data":    job.ImportReport,
+	})
+}
+
+// GetImportJob handles GET /api/books/import/{id} (admin): the status and
+// progress of a background import
+func GetImportJob(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	job, err := importRepo.GetImportJob(mux.Vars(r)["id"])
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeImportError(w, http.StatusNotFound, "Import job not found")
+		return
+	}
+	if err != nil {
+		writeImportError(w, http.StatusInternalServerError, "Failed to fetch import job")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    job,
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api-golang/models"
+)
+
+// importAsync starts a background import of a CSV file and waits for the
+// job to finish
+func (s *testServer) importAsync(t *testing.T, token, csv string) *models.ImportJob {
+	t.Helper()
+	req, err := http.NewRequest("POST", s.URL+"/api/books/import?async=true", strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var started struct {
+		Data models.ImportJob `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&started); err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("start import: status %d, %v", resp.StatusCode, err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var polled struct {
+			Data models.ImportJob `json:"data"`
+		}
+		if status := s.do(t, "GET", "/api/books/import/"+started.Data.ID, token, nil, &polled); status != http.StatusOK {
+			t.Fatalf("poll import: status %d", status)
+		}
+		job := polled.Data
+		if job.Status != models.ImportStatusQueued && job.Status != models.ImportStatusRunning {
+			return &job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("import job still %s", job.Status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestImportJobStatus(t *testing.T) {
+	srv := newTestServer(t)
+	token := srv.login(t, "admin", "admin123")
+
+	job := srv.importAsync(t, token, "judul,author,tahun_terbit\nBumi Manusia,Pramoedya Ananta Toer,1980\nNo Year,Someone,\n")
+	if job.Status != models.ImportStatusCompleted || job.Created != 1 || job.Failed != 1 || job.Message != "" {
+		t.Errorf("partly failed import: %+v", job)
+	}
+
+	// Nothing imported is a failed job, not a completed one
+	job = srv.importAsync(t, token, "judul,author,tahun_terbit\nNo Year,Someone,\nOld,Someone,999\n")
+	if job.Status != models.ImportStatusFailed || job.Failed != 2 || job.Message != "No rows could be imported" {
+		t.Errorf("failed import: %+v", job)
+	}
+}
+
+func TestImportJobPanicFailsJob(t *testing.T) {
+	srv := newTestServer(t)
+	token := srv.login(t, "admin", "admin123")
+
+	// Saving the batch dereferences the missing repository
+	books := bookRepo
+	bookRepo = nil
+	defer func() { bookRepo = books }()
+
+	job := srv.importAsync(t, token, "judul,author,tahun_terbit\nBumi Manusia,Pramoedya Ananta Toer,1980\n")
+	if job.Status != models.ImportStatusFailed || job.Message != "Stopped by an internal error" || job.FinishedAt == nil {
+		t.Errorf("panicked import: %+v", job)
+	}
+	// The server is still up
+	if status := srv.do(t, "GET", "/health", "", nil, nil); status != http.StatusOK {
+		t.Errorf("health after the panic: status %d", status)
+	}
+}
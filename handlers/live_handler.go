@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rest-api-golang/live"
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// liveReplayLimit is the most events replayed to a client that
+	// resumes; one that missed more is told to reload instead
+	liveReplayLimit = 1000
+	// liveWriteTimeout is how long a client may take to accept a message
+	// before it is disconnected
+	liveWriteTimeout = 10 * time.Second
+	// liveTicketTTL is how long a ticket from POST /api/events/ticket can
+	// be used to open the feed
+	liveTicketTTL = 30 * time.Second
+)
+
+// liveEventTypes are the values the live feed ?types= filter accepts
+var liveEventTypes = []string{
+	models.WebhookEventBookCreated, models.WebhookEventBookUpdated,
+	models.WebhookEventBookDeleted, models.WebhookEventBookRestored,
+}
+
+// Hub of the live feed, how often idle connections are pinged and the
+// WebSocket upgrader checking their origin
+var (
+	liveHub       *live.Hub
+	liveHeartbeat = 15 * time.Second
+	liveUpgrader  = live.NewUpgrader(nil)
+)
+
+// InitializeLiveFeed sets the hub serving GET /api/events, the interval
+// of keep-alive messages on idle connections and the origins besides the
+// API's own that may open the WebSocket feed
+func InitializeLiveFeed(h *live.Hub, heartbeat time.Duration, allowedOrigins []string) {
+	liveHub = h
+	liveHeartbeat = heartbeat
+	liveUpgrader = live.NewUpgrader(allowedOrigins)
+}
+
+// isLiveFeedPath reports whether path opens the live feed, which takes a
+// ticket in place of the Authorization header
+func isLiveFeedPath(path string) bool {
+	return path == "/api/events" || path == "/api/events/ws"
+}
+
+// userForLiveTicket redeems a ticket and returns the user of its session
+func userForLiveTicket(ticket string) (*models.User, error) {
+	userID, err := tokenRepo.RedeemLiveTicket(hashToken(ticket))
+	if err != nil {
+		return nil, err
+	}
+	return userRepo.GetUserByID(userID)
+}
+
+// CreateLiveTicket handles POST /api/events/ticket. Browsers cannot set
+// headers on EventSource and WebSocket connections, and a session token
+// in the URL ends up in logs, so they open the feed with ?ticket= instead:
+// a random value that works once, within liveTicketTTL, and only while
+// the session is valid.
+func CreateLiveTicket(w http.ResponseWriter, r *http.Request) {
+	session, err := tokenRepo.GetTokenByValue(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		writeLiveError(w, http.StatusUnauthorized, "Token expired or invalid")
+		return
+	}
+
+	ticket, err := newSecretToken()
+	if err == nil {
+		now := time.Now()
+		err = tokenRepo.CreateLiveTicket(&models.LiveTicket{
+			ID:        uuid.New().String(),
+			TokenHash: hashToken(ticket),
+			SessionID: session.ID,
+			ExpiresAt: now.Add(liveTicketTTL),
+			CreatedAt: now,
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to create live ticket: %v", err)
+		writeLiveError(w, http.StatusInternalServerError, "Failed to create ticket")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"ticket":     ticket,
+		"expires_in": int(liveTicketTTL.Seconds()),
+	})
+}
+
+func writeLiveError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// liveFeed is a subscription to the hub together with the events the
+// client missed since the Last-Event-ID it resumed from
+type liveFeed struct {
+	sub *live.Subscription
+	// replay holds the missed events, unless reset is set because too
+	// many were missed to replay
+	replay []*models.OutboxEvent
+	reset  bool
+}
+
+// openLiveFeed subscribes to the hub with the filters of the request and
+// loads the events to replay. It writes an error response and returns nil
+// if the request is invalid.
+func openLiveFeed(w http.ResponseWriter, r *http.Request) *liveFeed {
+	if liveHub == nil {
+		writeLiveError(w, http.StatusServiceUnavailable, "Live feed is not available")
+		return nil
+	}
+
+	q := r.URL.Query()
+	filter := live.Filter{AggregateID: q.Get("book_id")}
+	if types := q.Get("types"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			if !isOneOf(t, liveEventTypes) {
+				writeLiveError(w, http.StatusBadRequest, "types must be a comma separated list of "+strings.Join(liveEventTypes, ", "))
+				return nil
+			}
+			filter.Types = append(filter.Types, t)
+		}
+	}
+
+	// EventSource sends the Last-Event-ID header when it reconnects; a new
+	// connection can pass the last ID it saw as ?last_event_id=
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = q.Get("last_event_id")
+	}
+	var after int64
+	if lastEventID != "" {
+		n, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || n < 0 {
+			writeLiveError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return nil
+		}
+		after = n
+	}
+
+	feed, err := subscribeLiveFeed(filter, after)
+	if err != nil {
+		log.Printf("Live feed replay failed: %v", err)
+		writeLiveError(w, http.StatusInternalServerError, "Failed to replay events")
+		return nil
+	}
+	return feed
+}
+
+// subscribeLiveFeed subscribes to the hub and loads the events matching
+// filter after the sequence number after, if it is set
+func subscribeLiveFeed(filter live.Filter, after int64) (*liveFeed, error) {
+	// Subscribe before replaying so no event falls in between; events
+	// seen in both are sent once
+	feed := &liveFeed{sub: liveHub.Subscribe(filter)}
+	if after > 0 {
+		events, complete, err := liveHub.Replay(after, filter, liveReplayLimit)
+		if err != nil {
+			liveHub.Unsubscribe(feed.sub)
+			return nil, err
+		}
+		feed.replay = events
+		feed.reset = !complete
+		if feed.reset {
+			feed.replay = nil
+		}
+	}
+	return feed, nil
+}
+
+// run sends the replayed events and then the live ones to send until the
+// client falls behind, sending fails or done is closed. ping is called
+// when the connection was idle for a heartbeat interval. It returns
+// whether the subscriber lagged.
+func (f *liveFeed) run(done <-chan struct{}, send func(*models.OutboxEvent) error, ping func() error) (bool, error) {
+	replayed := make(map[int64]bool, len(f.replay))
+	for _, e := range f.replay {
+		if err := send(e); err != nil {
+			return false, err
+		}
+		replayed[e.Sequence] = true
+	}
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-done:
+			return false, nil
+		case <-f.sub.Lagged():
+			return true, nil
+		case e := <-f.sub.Events():
+			if replayed[e.Sequence] {
+				delete(replayed, e.Sequence)
+				continue
+			}
+			if err := send(e); err != nil {
+				return false, err
+			}
+			heartbeat.Reset(liveHeartbeat)
+		case <-heartbeat.C:
+			if err := ping(); err != nil {
+				return false, err
+			}
+		}
+	}
+}
+
+// StreamEvents handles GET /api/events, streaming book changes as
+// server-sent events. Each event's ID is its outbox sequence number, so a
+// reconnecting client resumes where it left off.
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	feed := openLiveFeed(w, r)
+	if feed == nil {
+		return
+	}
+	defer liveHub.Unsubscribe(feed.sub)
+
+	rc := http.NewResponseController(w)
+	write := func(format string, args ...interface{}) error {
+		rc.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := write("retry: 3000\n\n"); err != nil {
+		return
+	}
+	if feed.reset {
+		if err := write("event: reset\ndata: {}\n\n"); err != nil {
+			return
+		}
+	}
+
+	lagged, _ := feed.run(r.Context().Done(), func(e *models.OutboxEvent) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return write("id: %d\nevent: %s\ndata: %s\n\n", e.Sequence, e.Type, data)
+	}, func() error {
+		return write(": ping\n\n")
+	})
+	if lagged {
+		// The browser reconnects by itself and resumes from the last ID
+		write("event: lagged\ndata: {}\n\n")
+	}
+}
+
+// StreamEventsWebSocket handles GET /api/events/ws, streaming the same
+// events as GET /api/events as JSON text messages over a WebSocket.
+// Control messages have a type of "reset" or "lagged".
+func StreamEventsWebSocket(w http.ResponseWriter, r *http.Request) {
+	feed := openLiveFeed(w, r)
+	if feed == nil {
+		return
+	}
+	defer liveHub.Unsubscribe(feed.sub)
+
+	// The upgrader answers failed handshakes, such as from a foreign
+	// origin, itself
+	conn, err := liveUpgrader.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+
+	// The read loop answers pings and ends when the client goes away
+	done := make(chan struct{})
+	go func() {
+		conn.ReadLoop()
+		close(done)
+	}()
+
+	writeJSON := func(v interface{}) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return conn.WriteText(data, liveWriteTimeout)
+	}
+	if feed.reset {
+		if err := writeJSON(map[string]string{"type": "reset"}); err != nil {
+			conn.Close(live.CloseGoingAway, "")
+			return
+		}
+	}
+
+	lagged, err := feed.run(done, func(e *models.OutboxEvent) error {
+		return writeJSON(e)
+	}, func() error {
+		return conn.Ping(liveWriteTimeout)
+	})
+	switch {
+	case lagged:
+		writeJSON(map[string]string{"type": "lagged"})
+		conn.Close(live.CloseTryAgainLater, "client fell behind, reconnect with last_event_id")
+	case err != nil:
+		conn.Close(live.CloseGoingAway, "")
+	default:
+		conn.Close(live.CloseNormal, "")
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api-golang/database"
+	"rest-api-golang/live"
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/gorilla/websocket"
+)
+
+// liveTicket asks for a ticket to open the live feed
+func (s *testServer) liveTicket(t *testing.T, token string) string {
+	t.Helper()
+	var resp struct {
+		Ticket    string `json:"ticket"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	if status := s.do(t, "POST", "/api/events/ticket", token, nil, &resp); status != http.StatusOK || resp.Ticket == "" {
+		t.Fatalf("ticket: status %d", status)
+	}
+	if resp.ExpiresIn != int(liveTicketTTL.Seconds()) {
+		t.Errorf("ticket expires in %d seconds", resp.ExpiresIn)
+	}
+	return resp.Ticket
+}
+
+// dialLiveFeed opens the WebSocket feed with a ticket from origin
+func (s *testServer) dialLiveFeed(ticket, origin string) (*websocket.Conn, int, error) {
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/api/events/ws?ticket=" + ticket
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if resp == nil {
+		return conn, 0, err
+	}
+	return conn, resp.StatusCode, err
+}
+
+func newTestLiveFeed(t *testing.T) *live.Hub {
+	hub := live.NewHub(repositories.NewOutboxRepository(database.DB), models.AggregateBook, 16)
+	InitializeLiveFeed(hub, time.Minute, []string{"https://app.example.com"})
+	t.Cleanup(func() { InitializeLiveFeed(nil, 15*time.Second, nil) })
+	return hub
+}
+
+func TestLiveFeedTicket(t *testing.T) {
+	srv := newTestServer(t)
+	hub := newTestLiveFeed(t)
+	session := srv.login(t, "user", "user123")
+
+	conn, status, err := srv.dialLiveFeed(srv.liveTicket(t, session), srv.URL)
+	if err != nil {
+		t.Fatalf("dial: status %d, %v", status, err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Clients() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	hub.Broadcast(&models.OutboxEvent{Sequence: 1 << 40, AggregateType: models.AggregateBook, AggregateID: "b1", Type: "book.created", Payload: json.RawMessage(`{}`)})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event models.OutboxEvent
+	if err := conn.ReadJSON(&event); err != nil || event.Type != "book.created" || event.AggregateID != "b1" {
+		t.Errorf("feed sent %+v, %v", event, err)
+	}
+
+	// A ticket opens the feed once
+	ticket := srv.liveTicket(t, session)
+	if status := srv.do(t, "GET", "/api/events/ws?ticket="+ticket, "", nil, nil); status == http.StatusUnauthorized {
+		t.Fatal("unused ticket was refused")
+	}
+	if _, status, _ := srv.dialLiveFeed(ticket, ""); status != http.StatusUnauthorized {
+		t.Errorf("reused ticket: status %d", status)
+	}
+
+	// Nor does it outlive its time
+	ticket = srv.liveTicket(t, session)
+	if _, err := database.DB.Exec(`UPDATE live_tickets SET expires_at = NOW() - INTERVAL '1 second'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, status, _ := srv.dialLiveFeed(ticket, ""); status != http.StatusUnauthorized {
+		t.Errorf("expired ticket: status %d", status)
+	}
+
+	// Only the live feed takes tickets
+	ticket = srv.liveTicket(t, session)
+	if status := srv.do(t, "GET", "/api/books?ticket="+ticket, "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("ticket on another route: status %d", status)
+	}
+
+	// Nor does it outlive its session
+	if status := srv.do(t, "POST", "/api/logout", session, nil, nil); status != http.StatusOK {
+		t.Fatalf("logout: status %d", status)
+	}
+	if _, status, _ := srv.dialLiveFeed(ticket, ""); status != http.StatusUnauthorized {
+		t.Errorf("ticket of a revoked session: status %d", status)
+	}
+
+	// The session token itself is not accepted in the URL
+	session = srv.login(t, "user", "user123")
+	if status := srv.do(t, "GET", "/api/events?access_token="+session, "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("access_token: status %d", status)
+	}
+	if status := srv.do(t, "POST", "/api/events/ticket", "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("ticket without a session: status %d", status)
+	}
+}
+
+func TestLiveFeedOrigin(t *testing.T) {
+	srv := newTestServer(t)
+	newTestLiveFeed(t)
+	session := srv.login(t, "user", "user123")
+
+	for _, origin := range []string{srv.URL, "https://app.example.com", ""} {
+		conn, status, err := srv.dialLiveFeed(srv.liveTicket(t, session), origin)
+		if err != nil {
+			t.Errorf("origin %q: status %d, %v", origin, status, err)
+			continue
+		}
+		conn.Close()
+	}
+	// A page on another site cannot open the feed with the visitor's
+	// credentials
+	if _, status, _ := srv.dialLiveFeed(srv.liveTicket(t, session), "https://evil.example.com"); status != http.StatusForbidden {
+		t.Errorf("foreign origin: status %d", status)
+	}
+}
@@ -0,0 +1,637 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+	"rest-api-golang/totp"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// mfaChallengeTTL is how long the MFA token from /api/login stays valid
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaMaxAttempts is the number of wrong codes allowed per challenge
+	mfaMaxAttempts = 5
+	// recoveryCodeCount is the number of recovery codes issued at a time
+	recoveryCodeCount = 10
+	// mfaPolicySetting is the app_settings key of the enforced roles
+	mfaPolicySetting = "mfa_required_roles"
+)
+
+// mfaIssuer is shown as the account issuer in authenticator apps
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Book Management API"
+}
+
+// hashToken returns the hex SHA-256 of a high-entropy secret such as a
+// challenge token or recovery code; only this hash is stored
+func hashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSecretToken returns a random token safe to put in URLs and headers
+func newSecretToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// mfaRequiredRoles returns the roles an admin has made MFA mandatory for
+func mfaRequiredRoles() ([]string, error) {
+	value, err := settingsRepo.GetSetting(mfaPolicySetting, "")
+	if err != nil {
+		return nil, err
+	}
+	var roles []string
+	for _, role := range strings.Split(value, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+// roleRequiresMFA reports whether the MFA policy covers role
+func roleRequiresMFA(role string) (bool, error) {
+	roles, err := mfaRequiredRoles()
+	if err != nil {
+		return false, err
+	}
+	for _, r := range roles {
+		if r == role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mfaRequiredForLogin reports whether a second factor is needed and, if
+// so, whether the user still has to enroll because of the MFA policy
+func mfaRequiredForLogin(user *models.User) (bool, bool, error) {
+	enabled := false
+	mfa, err := mfaRepo.GetMFA(user.ID)
+	switch {
+	case err == nil:
+		enabled = mfa.Enabled
+	case !errors.Is(err, repositories.ErrNotFound):
+		return false, false, err
+	}
+
+	enforced, err := roleRequiresMFA(user.Role)
+	if err != nil {
+		return false, false, err
+	}
+
+	return enabled || enforced, enforced && !enabled, nil
+}
+
+// writeMFAChallenge answers a successful password check with a short-lived
+// MFA token instead of a session token
+func writeMFAChallenge(w http.ResponseWriter, user *models.User, setupRequired bool) {
+	tokenValue, err := newSecretToken()
+	if err == nil {
+		mfaRepo.CleanupExpiredChallenges()
+		now := time.Now()
+		err = mfaRepo.CreateChallenge(&models.MFAChallenge{
+			ID:        uuid.New().String(),
+			TokenHash: hashToken(tokenValue),
+			UserID:    user.ID,
+			ExpiresAt: now.Add(mfaChallengeTTL),
+			CreatedAt: now,
+		})
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to create session",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":            true,
+		"mfa_required":       true,
+		"mfa_setup_required": setupRequired,
+		"mfa_token":          tokenValue,
+		"expires_in":         int(mfaChallengeTTL.Seconds()),
+	})
+}
+
+// generateRecoveryCodes returns plaintext codes (shown once) and their hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		for j := range b {
+			b[j] = alphabet[int(b[j])%len(alphabet)]
+		}
+		code := string(b[:5]) + "-" + string(b[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// verifyTOTP validates a code for an enrollment and records its time step
+// so the same code cannot be used twice
+func verifyTOTP(mfa *models.UserMFA, code string) error {
+	step, ok := totp.Validate(mfa.Secret, code, time.Now())
+	if !ok || step <= mfa.LastUsedStep {
+		return fmt.Errorf("invalid code")
+	}
+	return mfaRepo.MarkStepUsed(mfa.UserID, step)
+}
+
+// startEnrollment creates a pending secret for user and writes it along
+// with the otpauth:// provisioning URI for QR codes
+func startEnrollment(w http.ResponseWriter, user *models.User) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to start enrollment",
+		})
+		return
+	}
+
+	if err := mfaRepo.SavePendingSecret(user.ID, secret); err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	account := user.Username
+	if user.Email != "" {
+		account = user.Email
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Scan the provisioning URI and confirm with a code",
+		"data": map[string]interface{}{
+			"secret":           secret,
+			"provisioning_uri": totp.ProvisioningURI(mfaIssuer(), account, secret),
+			"digits":           totp.Digits,
+			"period":           totp.Period,
+		},
+	})
+}
+
+// loadChallenge resolves an MFA token to its challenge and user
+func loadChallenge(w http.ResponseWriter, mfaToken string) (*models.MFAChallenge, *models.User, bool) {
+	challenge, err := mfaRepo.GetChallenge(hashToken(mfaToken))
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "MFA token expired or invalid",
+		})
+		return nil, nil, false
+	}
+
+	user, err := userRepo.GetUserByID(challenge.UserID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "MFA token expired or invalid",
+		})
+		return nil, nil, false
+	}
+
+	return challenge, user, true
+}
+
+// LoginMFA handles POST /api/login/mfa, exchanging an MFA token and a TOTP
+// or recovery code for a session token. For users who enrolled during
+// login (policy-enforced setup) the first valid code also enables MFA.
+func LoginMFA(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Code or recovery_code is required",
+		})
+		return
+	}
+
+	challenge, user, ok := loadChallenge(w, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	mfa, err := mfaRepo.GetMFA(user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Two-factor enrollment required; call POST /api/login/mfa/enroll first",
+		})
+		return
+	}
+
+	var recoveryCodes []string
+	switch {
+	case !mfa.Enabled && req.Code != "":
+		// Completing a policy-enforced enrollment
+		step, valid := totp.Validate(mfa.Secret, req.Code, time.Now())
+		if valid {
+			var hashes []string
+			recoveryCodes, hashes, err = generateRecoveryCodes()
+			if err == nil {
+				err = mfaRepo.WithAudit(loginAuditContext(r, user)).Enable(user.ID, step, hashes)
+			}
+		} else {
+			err = fmt.Errorf("invalid code")
+		}
+	case !mfa.Enabled:
+		err = fmt.Errorf("enrollment must be confirmed with a code")
+	case req.Code != "":
+		err = verifyTOTP(mfa, req.Code)
+	default:
+		err = mfaRepo.UseRecoveryCode(user.ID, hashToken(normalizeRecoveryCode(req.RecoveryCode)))
+	}
+
+	if err != nil {
+		recordLoginFailure(r, user.Username, user.ID, "invalid two-factor code")
+		attempts, countErr := mfaRepo.IncrementChallengeAttempts(challenge.ID)
+		if countErr != nil || attempts >= mfaMaxAttempts {
+			mfaRepo.DeleteChallenge(challenge.ID)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid two-factor code",
+		})
+		return
+	}
+
+	// The challenge is single-use; losing the race to delete it means a
+	// concurrent request already redeemed it
+	if err := mfaRepo.DeleteChallenge(challenge.ID); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "MFA token expired or invalid",
+		})
+		return
+	}
+
+	tokenValue, err := createSession(r, user)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to create session",
+		})
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"token":   tokenValue,
+	}
+	if recoveryCodes != nil {
+		response["recovery_codes"] = recoveryCodes
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// LoginMFAEnroll handles POST /api/login/mfa/enroll, letting a user whose
+// role requires MFA enroll with the MFA token before having a session
+func LoginMFAEnroll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	_, user, ok := loadChallenge(w, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	startEnrollment(w, user)
+}
+
+// GetMFAStatus handles GET /api/mfa
+func GetMFAStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	enabled := false
+	remaining := 0
+	if mfa, err := mfaRepo.GetMFA(user.ID); err == nil && mfa.Enabled {
+		enabled = true
+		remaining, _ = mfaRepo.CountRecoveryCodes(user.ID)
+	}
+	required, err := roleRequiresMFA(user.Role)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to fetch MFA status",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"enabled":                  enabled,
+			"required":                 required,
+			"recovery_codes_remaining": remaining,
+		},
+	})
+}
+
+// EnrollMFA handles POST /api/mfa/enroll
+func EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	startEnrollment(w, currentUser(r))
+}
+
+// ConfirmMFA handles POST /api/mfa/confirm, enabling MFA once the user
+// proves the authenticator works. Recovery codes are returned only here.
+func ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	mfa, err := mfaRepo.GetMFA(user.ID)
+	if err != nil || mfa.Enabled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "No pending enrollment; call POST /api/mfa/enroll first",
+		})
+		return
+	}
+
+	step, valid := totp.Validate(mfa.Secret, req.Code, time.Now())
+	if !valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid two-factor code",
+		})
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		err = mfaRepo.WithAudit(auditContext(r)).Enable(user.ID, step, hashes)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to enable two-factor authentication",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Two-factor authentication enabled. Store the recovery codes safely; they are shown only once.",
+		"data": map[string]interface{}{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// RegenerateRecoveryCodes handles POST /api/mfa/recovery-codes
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	mfa, ok := requireValidTOTP(w, r, user)
+	if !ok {
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		err = mfaRepo.ReplaceRecoveryCodes(mfa.UserID, hashes)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to regenerate recovery codes",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Recovery codes regenerated; previous codes no longer work",
+		"data": map[string]interface{}{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableMFA handles DELETE /api/mfa. It is refused while the MFA policy
+// covers the user's role.
+func DisableMFA(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	required, err := roleRequiresMFA(user.Role)
+	if err != nil || required {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Two-factor authentication is required for your role",
+		})
+		return
+	}
+
+	if _, ok := requireValidTOTP(w, r, user); !ok {
+		return
+	}
+
+	if err := mfaRepo.WithAudit(auditContext(r)).Disable(user.ID); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to disable two-factor authentication",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// requireValidTOTP decodes an MFACodeRequest and checks the code against
+// the user's enabled enrollment, writing an error response on failure
+func requireValidTOTP(w http.ResponseWriter, r *http.Request, user *models.User) (*models.UserMFA, bool) {
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return nil, false
+	}
+
+	mfa, err := mfaRepo.GetMFA(user.ID)
+	if err != nil || !mfa.Enabled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Two-factor authentication is not enabled",
+		})
+		return nil, false
+	}
+
+	if err := verifyTOTP(mfa, req.Code); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid two-factor code",
+		})
+		return nil, false
+	}
+
+	return mfa, true
+}
+
+// GetMFAPolicy handles GET /api/admin/mfa-policy (admin only)
+func GetMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	roles, err := mfaRequiredRoles()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to fetch MFA policy",
+		})
+		return
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    models.MFAPolicy{RequiredRoles: roles},
+	})
+}
+
+// UpdateMFAPolicy handles PUT /api/admin/mfa-policy (admin only). Users in
+// the listed roles must enroll on their next login.
+func UpdateMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.MFAPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	roles := []string{}
+	for _, role := range req.RequiredRoles {
+		role = strings.TrimSpace(role)
+		if role == "" || strings.Contains(role, ",") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Invalid role name",
+			})
+			return
+		}
+		roles = append(roles, role)
+	}
+
+	if err := settingsRepo.WithAudit(auditContext(r)).SetSetting(mfaPolicySetting, strings.Join(roles, ",")); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to update MFA policy",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "MFA policy updated",
+		"data":    models.MFAPolicy{RequiredRoles: roles},
+	})
+}
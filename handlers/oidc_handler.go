@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+	"rest-api-golang/oidc"
+
+	"github.com/google/uuid"
+)
+
+// oidcProvider is nil when SSO login is not configured
+var oidcProvider *oidc.Provider
+
+// oidcStateTTL bounds how long a user may take at the identity provider
+const oidcStateTTL = 10 * time.Minute
+
+// InitializeOIDC enables SSO login against the given provider
+func InitializeOIDC(provider *oidc.Provider) {
+	oidcProvider = provider
+}
+
+// OIDCLogin handles GET /api/login/oidc by redirecting to the identity
+// provider with a fresh state, nonce and PKCE challenge
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if oidcProvider == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "SSO login is not configured",
+		})
+		return
+	}
+
+	state, errState := oidc.RandomString(24)
+	nonce, errNonce := oidc.RandomString(24)
+	verifier, errVerifier := oidc.NewCodeVerifier()
+	if errState != nil || errNonce != nil || errVerifier != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to start SSO login",
+		})
+		return
+	}
+
+	// Drop abandoned attempts before adding a new one
+	if err := identityRepo.CleanupExpiredLoginStates(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	now := time.Now()
+	loginState := &models.OIDCLoginState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(oidcStateTTL),
+		CreatedAt:    now,
+	}
+	if err := identityRepo.CreateLoginState(loginState); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to start SSO login",
+		})
+		return
+	}
+
+	extra := url.Values{}
+	if hint := r.URL.Query().Get("login_hint"); hint != "" {
+		extra.Set("login_hint", hint)
+	}
+	authURL, err := oidcProvider.AuthCodeURL(r.Context(), state, nonce, verifier, extra)
+	if err != nil {
+		log.Printf("OIDC discovery failed: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Identity provider is unavailable",
+		})
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback handles GET /api/login/oidc/callback. It redeems the
+// authorization code, validates the ID token, provisions or links the
+// local user and returns a session token like POST /api/login. The IdP
+// does not count as a second factor, so accounts with two-factor
+// authentication get the same MFA challenge as a password login.
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if oidcProvider == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "SSO login is not configured",
+		})
+		return
+	}
+
+	q := r.URL.Query()
+	if idpErr := q.Get("error"); idpErr != "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Identity provider returned an error: %s", idpErr),
+		})
+		return
+	}
+
+	loginState, err := identityRepo.ConsumeLoginState(q.Get("state"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid or expired login state",
+		})
+		return
+	}
+
+	tokens, err := oidcProvider.Exchange(r.Context(), q.Get("code"), loginState.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to redeem authorization code",
+		})
+		return
+	}
+
+	claims, err := oidcProvider.VerifyIDToken(r.Context(), tokens.IDToken, loginState.Nonce)
+	if err != nil {
+		log.Printf("OIDC ID token rejected: %v", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid ID token",
+		})
+		return
+	}
+
+	user, err := provisionOIDCUser(auditContext(r), claims, oidcProvider.Groups(claims))
+	if err != nil {
+		log.Printf("OIDC provisioning failed: %v", err)
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Unable to sign in with this account",
+		})
+		return
+	}
+
+	mfaRequired, setupRequired, err := mfaRequiredForLogin(user)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to create session",
+		})
+		return
+	}
+	if mfaRequired {
+		writeMFAChallenge(w, user, setupRequired)
+		return
+	}
+
+	tokenValue, err := createSession(r, user)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to create session",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"token":   tokenValue,
+		"user":    user,
+	})
+}
+
+// provisionOIDCUser finds the user linked to the identity, links an
+// existing user by verified email, or creates a new one. The role is
+// synced from the IdP groups on every sign-in. Changes are audited as made
+// in the context a.
+func provisionOIDCUser(a *models.AuditContext, claims *oidc.IDTokenClaims, groups []string) (*models.User, error) {
+	role := oidcProvider.Config.RoleForGroups(groups)
+
+	identity, err := identityRepo.GetIdentity(claims.Issuer, claims.Subject)
+	var user *models.User
+	switch {
+	case err == nil:
+		user, err = userRepo.GetUserByID(identity.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("linked user is missing or disabled: %w", err)
+		}
+		if err := identityRepo.UpdateLastLogin(identity.ID, claims.Email); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+
+	case claims.Email != "" && claims.EmailVerified:
+		user, err = userRepo.GetUserByEmail(claims.Email)
+		if err != nil {
+			user, err = createOIDCUser(a, claims, role)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := linkOIDCIdentity(user, claims); err != nil {
+			return nil, err
+		}
+
+	default:
+		user, err = createOIDCUser(a, claims, role)
+		if err != nil {
+			return nil, err
+		}
+		if err := linkOIDCIdentity(user, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.Role != role {
+		if err := userRepo.WithAudit(a).UpdateRole(user.ID, role); err != nil {
+			return nil, err
+		}
+		user.Role = role
+	}
+
+	return user, nil
+}
+
+func linkOIDCIdentity(user *models.User, claims *oidc.IDTokenClaims) error {
+	now := time.Now()
+	return identityRepo.CreateIdentity(&models.UserIdentity{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Issuer:    claims.Issuer,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: now,
+		LastLogin: &now,
+	})
+}
+
+// createOIDCUser creates a local account for a first-time SSO user. The
+// password is random so the account can only sign in through the IdP.
+func createOIDCUser(a *models.AuditContext, claims *oidc.IDTokenClaims, role string) (*models.User, error) {
+	username, err := uniqueUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+	password, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		ID:        uuid.New().String(),
+		Username:  username,
+		Password:  password,
+		Email:     claims.Email,
+		Role:      role,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := userRepo.WithAudit(a).CreateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// uniqueUsername derives a free username from the ID token claims
+func uniqueUsername(claims *oidc.IDTokenClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" && claims.Email != "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if base == "" {
+		base = "sso-" + usernameInvalidChars.ReplaceAllString(claims.Subject, "")
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	candidate := base
+	for i := 2; i < 100; i++ {
+		exists, err := userRepo.UsernameExists(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
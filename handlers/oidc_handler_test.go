@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"rest-api-golang/models"
+	"rest-api-golang/oidc"
+)
+
+var (
+	ssoReader = oidc.MockUser{
+		Subject:           "reader-1",
+		PreferredUsername: "sso-reader",
+		Email:             "sso-reader@example.com",
+		EmailVerified:     true,
+		Groups:            []string{"readers"},
+	}
+	ssoAdmin = oidc.MockUser{
+		Subject:           "admin-1",
+		PreferredUsername: "sso-admin",
+		Email:             "sso-admin@example.com",
+		EmailVerified:     true,
+		Groups:            []string{"library-admins"},
+	}
+)
+
+// ssoResponse is the body of the callback
+type ssoResponse struct {
+	Success          bool         `json:"success"`
+	Token            string       `json:"token"`
+	User             *models.User `json:"user"`
+	MFARequired      bool         `json:"mfa_required"`
+	MFASetupRequired bool         `json:"mfa_setup_required"`
+	MFAToken         string       `json:"mfa_token"`
+}
+
+// newTestIdP serves a mock identity provider and enables SSO login
+// against it, mapping the library-admins group to the admin role
+func newTestIdP(t *testing.T, srv *testServer, users ...oidc.MockUser) *oidc.MockProvider {
+	t.Helper()
+	var handler http.Handler
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(idp.Close)
+
+	mock, err := oidc.NewMockProvider(idp.URL, "book-api", users...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler = mock.Handler()
+	InitializeOIDC(oidc.NewProvider(&oidc.Config{
+		Issuer:      idp.URL,
+		ClientID:    "book-api",
+		RedirectURL: srv.URL + "/api/login/oidc/callback",
+		Scopes:      []string{"openid", "profile", "email", "groups"},
+		GroupsClaim: "groups",
+		GroupRoles:  map[string]string{"library-admins": "admin"},
+		DefaultRole: "user",
+	}, nil))
+	t.Cleanup(func() { InitializeOIDC(nil) })
+	return mock
+}
+
+// ssoLogin follows the redirects of an SSO login as the IdP user named
+// loginHint and decodes the response of the callback
+func ssoLogin(t *testing.T, srv *testServer, loginHint string) (int, *ssoResponse) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	next := srv.URL + "/api/login/oidc?login_hint=" + url.QueryEscape(loginHint)
+	// The login redirects to the IdP, which redirects to the callback
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode == http.StatusFound {
+			resp.Body.Close()
+			next = resp.Header.Get("Location")
+			continue
+		}
+		defer resp.Body.Close()
+		body := &ssoResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+			t.Fatalf("decode callback response: %v", err)
+		}
+		return resp.StatusCode, body
+	}
+	t.Fatalf("SSO login did not finish, last redirect to %s", next)
+	return 0, nil
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	srv := newTestServer(t)
+	newTestIdP(t, srv, ssoReader)
+
+	status, first := ssoLogin(t, srv, "sso-reader")
+	if status != http.StatusOK || first.Token == "" || first.User == nil {
+		t.Fatalf("first login: status %d, %+v", status, first)
+	}
+	if first.User.Username != "sso-reader" || first.User.Email != ssoReader.Email || first.User.Role != "user" {
+		t.Errorf("provisioned user = %+v", first.User)
+	}
+	if status := srv.do(t, "GET", "/api/books", first.Token, nil, nil); status != http.StatusOK {
+		t.Errorf("GET /api/books with the SSO session: status %d", status)
+	}
+
+	// The identity stays linked to the account
+	status, second := ssoLogin(t, srv, "sso-reader")
+	if status != http.StatusOK || second.User == nil || second.User.ID != first.User.ID {
+		t.Errorf("second login: status %d, %+v", status, second.User)
+	}
+}
+
+func TestOIDCLoginLinksUserByVerifiedEmail(t *testing.T) {
+	srv := newTestServer(t)
+	newTestIdP(t, srv,
+		// Seeded as user@example.com
+		oidc.MockUser{Subject: "verified-1", PreferredUsername: "verified", Email: "user@example.com", EmailVerified: true},
+		// Seeded as admin@example.com, but the IdP does not vouch for it
+		oidc.MockUser{Subject: "unverified-1", PreferredUsername: "unverified", Email: "admin@example.com"},
+	)
+
+	existing, err := userRepo.GetUserByUsername("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, linked := ssoLogin(t, srv, "verified")
+	if status != http.StatusOK || linked.User == nil || linked.User.ID != existing.ID {
+		t.Errorf("verified email: status %d, user %+v, want the existing user %s", status, linked.User, existing.ID)
+	}
+
+	admin, err := userRepo.GetUserByUsername("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, created := ssoLogin(t, srv, "unverified")
+	if status != http.StatusOK || created.User == nil {
+		t.Fatalf("unverified email: status %d", status)
+	}
+	if created.User.ID == admin.ID || created.User.Role != "user" {
+		t.Errorf("unverified email signed in as %+v", created.User)
+	}
+}
+
+func TestOIDCLoginMapsGroupsToRole(t *testing.T) {
+	srv := newTestServer(t)
+	mock := newTestIdP(t, srv, ssoAdmin)
+
+	status, resp := ssoLogin(t, srv, "sso-admin")
+	if status != http.StatusOK || resp.User == nil || resp.User.Role != "admin" {
+		t.Fatalf("library-admins member: status %d, user %+v", status, resp.User)
+	}
+	if status := srv.do(t, "GET", "/api/users", resp.Token, nil, nil); status != http.StatusOK {
+		t.Errorf("GET /api/users as the SSO admin: status %d", status)
+	}
+
+	// The role follows the groups on every login
+	demoted := ssoAdmin
+	demoted.Groups = []string{"readers"}
+	mock.AddUser(demoted)
+	status, resp = ssoLogin(t, srv, "sso-admin")
+	if status != http.StatusOK || resp.User == nil || resp.User.Role != "user" {
+		t.Fatalf("after leaving library-admins: status %d, user %+v", status, resp.User)
+	}
+	if status := srv.do(t, "GET", "/api/users", resp.Token, nil, nil); status != http.StatusForbidden {
+		t.Errorf("GET /api/users after the demotion: status %d", status)
+	}
+}
+
+func TestOIDCLoginAppliesMFAPolicy(t *testing.T) {
+	srv := newTestServer(t)
+	newTestIdP(t, srv, ssoAdmin, ssoReader)
+
+	adminToken := srv.login(t, "admin", "admin123")
+	if status := srv.do(t, "PUT", "/api/admin/mfa-policy", adminToken, models.MFAPolicy{RequiredRoles: []string{"admin"}}, nil); status != http.StatusOK {
+		t.Fatalf("set MFA policy: status %d", status)
+	}
+
+	status, resp := ssoLogin(t, srv, "sso-admin")
+	if status != http.StatusOK || resp.Token != "" || !resp.MFARequired || !resp.MFASetupRequired || resp.MFAToken == "" {
+		t.Errorf("admin covered by the MFA policy: status %d, %+v", status, resp)
+	}
+
+	status, resp = ssoLogin(t, srv, "sso-reader")
+	if status != http.StatusOK || resp.Token == "" || resp.MFARequired {
+		t.Errorf("user outside the MFA policy: status %d, %+v", status, resp)
+	}
+}
+
+func TestOIDCCallbackRejectsUnknownState(t *testing.T) {
+	srv := newTestServer(t)
+	newTestIdP(t, srv, ssoReader)
+
+	if status := srv.do(t, "GET", "/api/login/oidc/callback?code=abc&state=forged", "", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("forged state: status %d", status)
+	}
+	if status := srv.do(t, "GET", "/api/login/oidc/callback?error=access_denied", "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("IdP error: status %d", status)
+	}
+}
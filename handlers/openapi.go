@@ -0,0 +1,907 @@
+package handlers
+
+import (
+	"time"
+
+	"rest-api-golang/exporter"
+	"rest-api-golang/graphql"
+	"rest-api-golang/models"
+	"rest-api-golang/openapi"
+
+	"github.com/gorilla/mux"
+)
+
+// OpenAPI describes the routes of router, which must all be registered,
+// with the operations below. The document is complete even with an error,
+// which lists the routes missing from apiOperations and the operations
+// whose route is gone, so the two are kept in step.
+func OpenAPI(router *mux.Router) (*openapi.Document, error) {
+	routes, err := openapi.FromRouter(router)
+	if err != nil {
+		return nil, err
+	}
+	return openapi.Build(openapi.Config{
+		Info: openapi.Info{
+			Title:       "Book Management API",
+			Version:     "1.0",
+			Description: "A REST API for managing a library: books, authors, categories, circulation, reviews and reading lists.",
+		},
+		Unqualified: []string{"rest-api-golang/models", "rest-api-golang/handlers"},
+		Error: openapi.Object{
+			{Name: "success", Value: false},
+			{Name: "message", Value: ""},
+			// A rolled back batch reports the result of every operation
+			{Name: "data", Value: []models.BatchResult{}, Optional: true},
+			{Name: "count", Value: 0, Optional: true},
+		},
+		Public: publicPath,
+	}, routes, apiOperations)
+}
+
+// envelope is the {"success", "message", "data"} body most handlers
+// write, with extra properties such as count
+func envelope(data interface{}, extra ...openapi.Property) openapi.Body {
+	body := openapi.Object{
+		{Name: "success", Value: true},
+		{Name: "message", Value: "", Optional: true},
+	}
+	if data != nil {
+		body = append(body, openapi.Property{Name: "data", Value: data})
+	}
+	return openapi.JSON(body.With(extra...))
+}
+
+// ok and created describe a 200 or 201 envelope of data
+func ok(data interface{}, extra ...openapi.Property) map[int]openapi.Body {
+	return map[int]openapi.Body{200: envelope(data, extra...)}
+}
+
+func created(data interface{}) map[int]openapi.Body {
+	return map[int]openapi.Body{201: envelope(data)}
+}
+
+// list describes a 200 envelope of a list and its count
+func list(items interface{}, extra ...openapi.Property) map[int]openapi.Body {
+	return ok(items, append([]openapi.Property{countProperty}, extra...)...)
+}
+
+var (
+	countProperty = openapi.Property{Name: "count", Value: 0}
+	message       = ok(nil)
+
+	uuidParam = func(name, description string) *openapi.Parameter {
+		return openapi.Query(name, openapi.String("uuid"), description)
+	}
+	limitParam = func(max float64, description string) *openapi.Parameter {
+		return openapi.Query("limit", openapi.Integer().Between(1, max), description)
+	}
+	idPath = func(name string) *openapi.Parameter {
+		return openapi.Path(name, openapi.String("uuid"), "")
+	}
+	revisionPath = openapi.Path("n", openapi.Integer().AtLeast(1), "Revision number, counting from 1")
+
+	loginObject = openapi.Object{
+		{Name: "success", Value: true},
+		{Name: "token", Value: "", Optional: true},
+		{Name: "mfa_required", Value: true, Optional: true},
+		{Name: "mfa_setup_required", Value: true, Optional: true},
+		{Name: "mfa_token", Value: "", Optional: true},
+		{Name: "expires_in", Value: 0, Optional: true},
+	}
+	loginResponse = openapi.JSON(loginObject)
+	mfaEnrollment = ok(openapi.Object{
+		{Name: "secret", Value: ""},
+		{Name: "provisioning_uri", Value: ""},
+		{Name: "digits", Value: 0},
+		{Name: "period", Value: 0},
+	})
+	recoveryCodes = ok(openapi.Object{{Name: "recovery_codes", Value: []string{}}})
+
+	graphqlResponses = map[int]openapi.Body{
+		200: openapi.JSON(graphql.Response{}),
+		400: openapi.JSON(graphql.Response{}),
+	}
+)
+
+// bookFilterParams are the filters of the book list; the export takes the
+// binding format as formatParam since format is its file format
+func bookFilterParams(formatParam string) []*openapi.Parameter {
+	sorts := []string{}
+	for _, s := range models.BookSorts {
+		sorts = append(sorts, s, "-"+s)
+	}
+	return []*openapi.Parameter{
+		openapi.Query("isbn", openapi.String(""), "ISBN-10 or ISBN-13, with or without hyphens"),
+		openapi.Query("publisher", openapi.String(""), ""),
+		openapi.Query("language", openapi.String(""), "BCP 47 tag such as en or id-ID"),
+		openapi.Query(formatParam, openapi.Enum(models.BookFormats...), "Binding format"),
+		uuidParam("author_id", ""),
+		openapi.Query("category", openapi.String(""), "Category slug or ID; books in its descendants match too"),
+		openapi.Query("tags", openapi.String(""), "Comma separated tags"),
+		openapi.Query("tags_match", openapi.Enum("any", "all"), "Whether books need any (default) or all of the tags"),
+		openapi.Query("min_rating", (&openapi.Schema{Type: openapi.SchemaType{openapi.TypeNumber}}).Between(1, 5), ""),
+		openapi.Query("min_pages", openapi.Integer().AtLeast(1), ""),
+		openapi.Query("max_pages", openapi.Integer().AtLeast(1), ""),
+		openapi.Query("sort", openapi.Enum(sorts...), "Sort field, prefixed with - for descending order"),
+	}
+}
+
+// exportContent is the body of each export format
+func exportContent() openapi.Body {
+	types := []string{"application/gzip"}
+	for _, format := range exporter.Formats {
+		types = append(types, exporter.ContentType(format))
+	}
+	return openapi.Content(types...)
+}
+
+// apiOperations describes every route of main.go by method and path
+// template. OpenAPI reports routes missing here.
+var apiOperations = map[string]openapi.OperationSpec{
+	// Authentication
+	"POST /api/login": {
+		Tag: "Auth", Summary: "Log in",
+		Description: "Returns a session token, or an MFA challenge to finish with POST /api/login/mfa when the account uses two-factor authentication.",
+		Request:     openapi.JSON(LoginRequest{}),
+		Responses:   map[int]openapi.Body{200: loginResponse},
+		Errors:      []int{400, 401},
+	},
+	"POST /api/login/mfa": {
+		Tag: "Auth", Summary: "Finish an MFA login with a TOTP or recovery code",
+		Request: openapi.JSON(models.MFALoginRequest{}),
+		Responses: map[int]openapi.Body{200: openapi.JSON(openapi.Object{
+			{Name: "success", Value: true},
+			{Name: "token", Value: ""},
+			{Name: "recovery_codes", Value: []string{}, Optional: true},
+		})},
+		Errors: []int{400, 401},
+	},
+	"POST /api/login/mfa/enroll": {
+		Tag: "Auth", Summary: "Start the MFA enrollment an MFA challenge asks for",
+		Request:   openapi.JSON(models.MFALoginRequest{}),
+		Responses: mfaEnrollment,
+		Errors:    []int{400, 401, 409},
+	},
+	"GET /api/login/oidc": {
+		Tag: "Auth", Summary: "Log in with SSO",
+		Description: "Redirects to the OpenID Connect provider.",
+		Params:      []*openapi.Parameter{openapi.Query("login_hint", openapi.String(""), "")},
+		Responses:   map[int]openapi.Body{302: nil},
+		Errors:      []int{404, 502},
+	},
+	"GET /api/login/oidc/callback": {
+		Tag: "Auth", Summary: "Finish an SSO login",
+		Description: "Returns a session token, or an MFA challenge like POST /api/login when the account uses two-factor authentication.",
+		Params: []*openapi.Parameter{
+			openapi.Query("code", openapi.String(""), ""),
+			openapi.Query("state", openapi.String(""), ""),
+			openapi.Query("error", openapi.String(""), ""),
+			openapi.Query("error_description", openapi.String(""), ""),
+		},
+		Responses: map[int]openapi.Body{200: openapi.JSON(loginObject.With(
+			openapi.Property{Name: "user", Value: &models.User{}, Optional: true},
+		))},
+		Errors: []int{400, 401, 403, 404, 502},
+	},
+	"POST /api/logout": {
+		Tag: "Auth", Summary: "End the session",
+		Responses: message,
+		Errors:    []int{400},
+	},
+	"POST /api/password/forgot": {
+		Tag: "Auth", Summary: "Email a password reset link",
+		Request:   openapi.JSON(models.ForgotPasswordRequest{}),
+		Responses: message,
+		Errors:    []int{400},
+	},
+	"POST /api/password/reset": {
+		Tag: "Auth", Summary: "Set a new password with an emailed token",
+		Request:   openapi.JSON(models.ResetPasswordRequest{}),
+		Responses: message,
+		Errors:    []int{400},
+	},
+	"POST /api/email/verify/request": {
+		Tag: "Auth", Summary: "Email a verification link",
+		Responses: message,
+		Errors:    []int{400},
+	},
+	"POST /api/email/verify": {
+		Tag: "Auth", Summary: "Verify an email address with an emailed token",
+		Request:   openapi.JSON(models.VerifyEmailRequest{}),
+		Responses: message,
+		Errors:    []int{400},
+	},
+
+	// Two-factor authentication
+	"GET /api/mfa": {
+		Tag: "MFA", Summary: "Two-factor status",
+		Responses: ok(openapi.Object{
+			{Name: "enabled", Value: true},
+			{Name: "required", Value: true},
+			{Name: "recovery_codes_remaining", Value: 0},
+		}),
+	},
+	"DELETE /api/mfa": {
+		Tag: "MFA", Summary: "Turn off two-factor authentication",
+		Request:   openapi.JSON(models.MFACodeRequest{}),
+		Responses: message,
+		Errors:    []int{400, 403, 409},
+	},
+	"POST /api/mfa/enroll": {
+		Tag: "MFA", Summary: "Start enrolling an authenticator app",
+		Responses: mfaEnrollment,
+		Errors:    []int{409},
+	},
+	"POST /api/mfa/confirm": {
+		Tag: "MFA", Summary: "Turn on two-factor authentication with a first code",
+		Request:   openapi.JSON(models.MFACodeRequest{}),
+		Responses: recoveryCodes,
+		Errors:    []int{400, 409},
+	},
+	"POST /api/mfa/recovery-codes": {
+		Tag: "MFA", Summary: "Replace the recovery codes",
+		Request:   openapi.JSON(models.MFACodeRequest{}),
+		Responses: recoveryCodes,
+		Errors:    []int{400, 409},
+	},
+	"GET /api/admin/mfa-policy": {
+		Tag: "MFA", Summary: "Roles that must use two-factor authentication",
+		Responses: ok(models.MFAPolicy{}),
+		Errors:    []int{403},
+	},
+	"PUT /api/admin/mfa-policy": {
+		Tag: "MFA", Summary: "Set the roles that must use two-factor authentication",
+		Request:   openapi.JSON(models.MFAPolicy{}),
+		Responses: ok(models.MFAPolicy{}),
+		Errors:    []int{400, 403},
+	},
+
+	// Authors
+	"GET /api/authors": {
+		Tag: "Authors", Summary: "List authors",
+		Params:    []*openapi.Parameter{openapi.Query("q", openapi.String(""), "Search by name")},
+		Responses: list([]*models.Author{}),
+	},
+	"POST /api/authors": {
+		Tag: "Authors", Summary: "Create an author",
+		Request:   openapi.JSON(models.CreateAuthorRequest{}),
+		Responses: created(&models.Author{}),
+		Errors:    []int{400},
+	},
+	"GET /api/authors/{id}": {
+		Tag: "Authors", Summary: "Get an author",
+		Responses: ok(&models.Author{}),
+		Errors:    []int{404},
+	},
+	"PUT /api/authors/{id}": {
+		Tag: "Authors", Summary: "Update an author",
+		Request:   openapi.JSON(models.UpdateAuthorRequest{}),
+		Responses: ok(&models.Author{}),
+		Errors:    []int{400, 404},
+	},
+	"DELETE /api/authors/{id}": {
+		Tag: "Authors", Summary: "Delete an author",
+		Responses: ok(&models.Author{}),
+		Errors:    []int{404, 409},
+	},
+	"GET /api/authors/{id}/books": {
+		Tag: "Authors", Summary: "Books by an author",
+		Responses: list([]*models.Book{}),
+		Errors:    []int{404},
+	},
+
+	// Categories and tags
+	"GET /api/categories": {
+		Tag: "Categories", Summary: "List categories",
+		Params:    []*openapi.Parameter{openapi.Query("tree", openapi.Boolean(), "Nest categories under their parents")},
+		Responses: list([]*models.Category{}),
+	},
+	"POST /api/categories": {
+		Tag: "Categories", Summary: "Create a category",
+		Request:   openapi.JSON(models.CreateCategoryRequest{}),
+		Responses: created(&models.Category{}),
+		Errors:    []int{400, 403, 409},
+	},
+	"GET /api/categories/{id}": {
+		Tag: "Categories", Summary: "Get a category by ID or slug",
+		Params:    []*openapi.Parameter{openapi.Path("id", openapi.String(""), "ID or slug")},
+		Responses: ok(&models.Category{}),
+		Errors:    []int{404},
+	},
+	"PUT /api/categories/{id}": {
+		Tag: "Categories", Summary: "Update a category",
+		Request:   openapi.JSON(models.UpdateCategoryRequest{}),
+		Responses: ok(&models.Category{}),
+		Errors:    []int{400, 403, 404, 409},
+	},
+	"DELETE /api/categories/{id}": {
+		Tag: "Categories", Summary: "Delete a category",
+		Responses: ok(&models.Category{}),
+		Errors:    []int{403, 404, 409},
+	},
+	"GET /api/tags": {
+		Tag: "Categories", Summary: "Tag autocomplete",
+		Params: []*openapi.Parameter{
+			openapi.Query("q", openapi.String(""), "Tag prefix"),
+			limitParam(100, "Most tags to return, 10 by default"),
+		},
+		Responses: list([]*models.Tag{}),
+		Errors:    []int{400},
+	},
+
+	// Books
+	"GET /api/books": {
+		Tag: "Books", Summary: "List books",
+		Description: "With limit, returns one page after skipping offset books, with the total and the next_offset of the following page if there is one.",
+		Params: append(bookFilterParams("format"),
+			limitParam(maxBookPageLimit, "Page size"),
+			openapi.Query("offset", openapi.Integer().AtLeast(0), "Books to skip; needs limit"),
+		),
+		Responses: list([]*models.Book{},
+			openapi.Property{Name: "total", Value: 0, Optional: true},
+			openapi.Property{Name: "next_offset", Value: 0, Optional: true},
+		),
+		Errors: []int{400},
+	},
+	"POST /api/books": {
+		Tag: "Books", Summary: "Create a book",
+		Request:   openapi.JSON(models.CreateBookRequest{}),
+		Responses: created(&models.Book{}),
+		Errors:    []int{400, 409},
+	},
+	"GET /api/books/isbn/{isbn}": {
+		Tag: "Books", Summary: "Get a book by ISBN-10 or ISBN-13",
+		Responses: ok(&models.Book{}),
+		Errors:    []int{400, 404},
+	},
+	"POST /api/books/batch": {
+		Tag: "Books", Summary: "Create, update and delete many books",
+		Description: "In atomic mode a failing operation rolls back the batch, answered with the status of that operation and the results of all of them.",
+		Request:     openapi.JSON(models.BatchRequest{}),
+		Responses:   list([]models.BatchResult{}),
+		Errors:      []int{400, 403, 404, 409, 413},
+	},
+	"GET /api/books/{id}": {
+		Tag: "Books", Summary: "Get a book",
+		Params: []*openapi.Parameter{
+			openapi.Query("as_of", openapi.String("date-time"), "Return the book as it was at this time"),
+		},
+		Responses: ok(&models.Book{},
+			openapi.Property{Name: "as_of", Value: time.Time{}, Optional: true},
+			openapi.Property{Name: "revision", Value: 0, Optional: true},
+		),
+		Errors: []int{400, 404},
+	},
+	"PUT /api/books/{id}": {
+		Tag: "Books", Summary: "Update a book",
+		Request:   openapi.JSON(models.UpdateBookRequest{}),
+		Responses: ok(&models.Book{}),
+		Errors:    []int{400, 404, 409},
+	},
+	"DELETE /api/books/{id}": {
+		Tag: "Books", Summary: "Move a book to the trash",
+		Responses: ok(&models.Book{}),
+		Errors:    []int{404},
+	},
+	"POST /api/books/{id}/restore": {
+		Tag: "Books", Summary: "Restore a book from the trash",
+		Responses: ok(&models.Book{}),
+		Errors:    []int{403, 404, 409},
+	},
+	"PUT /api/books/{id}/cover": {
+		Tag: "Books", Summary: "Upload a cover image",
+		Request: openapi.Body{"multipart/form-data": openapi.Object{
+			{Name: "cover", Value: openapi.String("binary")},
+		}},
+		Responses: ok(&models.Book{}),
+		Errors:    []int{400, 404, 413},
+	},
+	"DELETE /api/books/{id}/cover": {
+		Tag: "Books", Summary: "Remove the cover image",
+		Responses: ok(&models.Book{}),
+		Errors:    []int{404},
+	},
+	"GET /covers/{hash}/{name}": {
+		Tag: "Books", Summary: "Cover image or thumbnail",
+		Params: []*openapi.Parameter{
+			{Name: "If-None-Match", In: "header", Schema: openapi.String("")},
+		},
+		Responses: map[int]openapi.Body{200: openapi.Content("image/*"), 304: nil},
+		Errors:    []int{404},
+	},
+
+	// Import and export
+	"POST /api/books/import": {
+		Tag: "Import and export", Summary: "Import books from CSV, JSON Lines, MARC or MARCXML",
+		Description: "The file is the request body or the file part of a multipart body. Large files, or any with async=true, are imported in the background: the response is then 202 with the job to poll.",
+		Params: []*openapi.Parameter{
+			openapi.Query("format", openapi.Enum(models.ImportFormats...), "Detected from the file when missing"),
+			openapi.Query("mode", openapi.Enum("insert", "upsert"), "upsert updates books whose ISBN exists"),
+			openapi.Query("dry_run", openapi.Boolean(), "Validate every row without saving"),
+			openapi.Query("async", openapi.Boolean(), "Import in the background"),
+			openapi.Query("mapping", openapi.String(""), "JSON object mapping CSV columns to book fields"),
+		},
+		Request: openapi.Body{
+			"multipart/form-data": openapi.Object{
+				{Name: "file", Value: openapi.String("binary")},
+				{Name: "mapping", Value: "", Optional: true},
+			},
+			"*/*": nil,
+		},
+		Responses: map[int]openapi.Body{
+			200: envelope(models.ImportReport{}),
+			202: envelope(&models.ImportJob{}),
+		},
+		Errors: []int{400, 403, 413},
+	},
+	"GET /api/books/import/{id}": {
+		Tag: "Import and export", Summary: "Progress and report of a background import",
+		Responses: ok(&models.ImportJob{}),
+		Errors:    []int{403, 404},
+	},
+	"GET /api/books/export": {
+		Tag: "Import and export", Summary: "Export books",
+		Description: "Streams the books matching the list filters. The response is gzip encoded when the client accepts it; gzip=true downloads a .gz file instead.",
+		Params: append(bookFilterParams("book_format"),
+			openapi.Query("format", openapi.Enum(exporter.Formats...), "File format, csv by default"),
+			openapi.Query("gzip", openapi.Boolean(), "Download a gzip file"),
+		),
+		Responses: map[int]openapi.Body{200: exportContent()},
+		Errors:    []int{400},
+	},
+
+	// Revisions
+	"GET /api/books/{id}/revisions": {
+		Tag: "Revisions", Summary: "Revision history of a book",
+		Responses: list([]*models.BookRevision{}),
+		Errors:    []int{404},
+	},
+	"GET /api/books/{id}/revisions/diff": {
+		Tag: "Revisions", Summary: "Fields that differ between two revisions",
+		Params: []*openapi.Parameter{
+			openapi.Query("from", openapi.Integer().AtLeast(1), "The revision before to by default"),
+			openapi.Query("to", openapi.Integer().AtLeast(1), "The latest revision by default"),
+		},
+		Responses: list(&models.BookRevisionDiff{}),
+		Errors:    []int{400, 404},
+	},
+	"GET /api/books/{id}/revisions/{n}": {
+		Tag: "Revisions", Summary: "Get a revision",
+		Params:    []*openapi.Parameter{revisionPath},
+		Responses: ok(&models.BookRevision{}),
+		Errors:    []int{400, 404},
+	},
+	"POST /api/books/{id}/revisions/{n}/revert": {
+		Tag: "Revisions", Summary: "Revert a book to a revision",
+		Params:    []*openapi.Parameter{revisionPath},
+		Responses: ok(&models.Book{}, openapi.Property{Name: "revision", Value: 0}),
+		Errors:    []int{400, 403, 404, 409},
+	},
+
+	// Circulation
+	"GET /api/books/{id}/copies": {
+		Tag: "Circulation", Summary: "Copies of a book",
+		Responses: list([]*models.Copy{}),
+		Errors:    []int{404},
+	},
+	"POST /api/books/{id}/copies": {
+		Tag: "Circulation", Summary: "Add a copy",
+		Request:   openapi.JSON(models.CreateCopyRequest{}),
+		Responses: created(&models.Copy{}),
+		Errors:    []int{400, 403, 404, 409},
+	},
+	"GET /api/copies/{id}": {
+		Tag: "Circulation", Summary: "Get a copy",
+		Responses: ok(&models.Copy{}),
+		Errors:    []int{404},
+	},
+	"PUT /api/copies/{id}": {
+		Tag: "Circulation", Summary: "Update a copy",
+		Request:   openapi.JSON(models.UpdateCopyRequest{}),
+		Responses: ok(&models.Copy{}),
+		Errors:    []int{400, 403, 404, 409},
+	},
+	"DELETE /api/copies/{id}": {
+		Tag: "Circulation", Summary: "Delete a copy",
+		Responses: ok(&models.Copy{}),
+		Errors:    []int{403, 404, 409},
+	},
+	"GET /api/loans": {
+		Tag: "Circulation", Summary: "List loans",
+		Description: "Members only see their own loans.",
+		Params: []*openapi.Parameter{
+			uuidParam("user_id", "Admins only"),
+			uuidParam("book_id", ""),
+			openapi.Query("status", openapi.Enum(models.LoanStatusActive, models.LoanStatusOverdue, models.LoanStatusReturned), ""),
+		},
+		Responses: list([]*models.Loan{}),
+		Errors:    []int{400},
+	},
+	"POST /api/loans": {
+		Tag: "Circulation", Summary: "Check out a copy",
+		Request:   openapi.JSON(models.CheckoutRequest{}),
+		Responses: created(&models.Loan{}),
+		Errors:    []int{400, 403, 404, 409},
+	},
+	"GET /api/loans/{id}": {
+		Tag: "Circulation", Summary: "Get a loan",
+		Responses: ok(&models.Loan{}),
+		Errors:    []int{404},
+	},
+	"POST /api/loans/{id}/return": {
+		Tag: "Circulation", Summary: "Return a copy",
+		Request:         openapi.JSON(models.ReturnRequest{}),
+		OptionalRequest: true,
+		Responses:       ok(&models.Loan{}),
+		Errors:          []int{400, 403, 404, 409},
+	},
+	"POST /api/loans/{id}/renew": {
+		Tag: "Circulation", Summary: "Renew a loan",
+		Responses: ok(&models.Loan{}),
+		Errors:    []int{403, 404, 409},
+	},
+	"GET /api/loan-policies": {
+		Tag: "Circulation", Summary: "Loan policies by role",
+		Responses: list([]*models.LoanPolicy{}),
+	},
+	"PUT /api/loan-policies/{role}": {
+		Tag: "Circulation", Summary: "Set the loan policy of a role",
+		Params:    []*openapi.Parameter{openapi.Path("role", openapi.String(""), "")},
+		Request:   openapi.JSON(models.UpdateLoanPolicyRequest{}),
+		Responses: ok(&models.LoanPolicy{}),
+		Errors:    []int{400, 403},
+	},
+	"DELETE /api/loan-policies/{role}": {
+		Tag: "Circulation", Summary: "Remove the loan policy of a role",
+		Params:    []*openapi.Parameter{openapi.Path("role", openapi.String(""), "")},
+		Responses: message,
+		Errors:    []int{403, 404, 409},
+	},
+
+	// Holds
+	"GET /api/books/{id}/holds": {
+		Tag: "Holds", Summary: "Hold queue of a book",
+		Responses: list([]*models.Hold{}),
+		Errors:    []int{404},
+	},
+	"POST /api/books/{id}/holds": {
+		Tag: "Holds", Summary: "Place a hold",
+		Request:         openapi.JSON(models.PlaceHoldRequest{}),
+		OptionalRequest: true,
+		Responses:       created(&models.Hold{}),
+		Errors:          []int{400, 403, 404, 409},
+	},
+	"GET /api/holds": {
+		Tag: "Holds", Summary: "List holds",
+		Description: "Members only see their own holds.",
+		Params: []*openapi.Parameter{
+			uuidParam("user_id", "Admins only"),
+			uuidParam("book_id", ""),
+			openapi.Query("status", openapi.Enum(models.HoldStatuses...), ""),
+		},
+		Responses: list([]*models.Hold{}),
+		Errors:    []int{400},
+	},
+	"GET /api/holds/{id}": {
+		Tag: "Holds", Summary: "Get a hold",
+		Responses: ok(&models.Hold{}),
+		Errors:    []int{404},
+	},
+	"DELETE /api/holds/{id}": {
+		Tag: "Holds", Summary: "Cancel a hold",
+		Responses: ok(&models.Hold{}),
+		Errors:    []int{404, 409},
+	},
+
+	// Reviews
+	"GET /api/books/{id}/reviews": {
+		Tag: "Reviews", Summary: "Reviews of a book",
+		Params: []*openapi.Parameter{
+			openapi.Query("status", openapi.Enum(models.ReviewStatuses...), "Admins only; others see approved reviews"),
+		},
+		Responses: list([]*models.Review{},
+			openapi.Property{Name: "average_rating", Value: 0.0},
+			openapi.Property{Name: "rating_count", Value: 0},
+		),
+		Errors: []int{400, 404},
+	},
+	"POST /api/books/{id}/reviews": {
+		Tag: "Reviews", Summary: "Review a book",
+		Request:   openapi.JSON(models.ReviewRequest{}),
+		Responses: created(&models.Review{}),
+		Errors:    []int{400, 404, 409},
+	},
+	"GET /api/reviews": {
+		Tag: "Reviews", Summary: "List reviews",
+		Description: "Members only see their own reviews.",
+		Params: []*openapi.Parameter{
+			uuidParam("user_id", "Admins only"),
+			uuidParam("book_id", ""),
+			openapi.Query("status", openapi.Enum(models.ReviewStatuses...), ""),
+		},
+		Responses: list([]*models.Review{}),
+		Errors:    []int{400},
+	},
+	"GET /api/reviews/{id}": {
+		Tag: "Reviews", Summary: "Get a review",
+		Responses: ok(&models.Review{}),
+		Errors:    []int{404},
+	},
+	"PUT /api/reviews/{id}": {
+		Tag: "Reviews", Summary: "Update your review",
+		Request:   openapi.JSON(models.ReviewRequest{}),
+		Responses: ok(&models.Review{}),
+		Errors:    []int{400, 403, 404},
+	},
+	"DELETE /api/reviews/{id}": {
+		Tag: "Reviews", Summary: "Delete a review",
+		Responses: message,
+		Errors:    []int{403, 404},
+	},
+	"PUT /api/reviews/{id}/status": {
+		Tag: "Reviews", Summary: "Moderate a review",
+		Request:   openapi.JSON(models.ModerateReviewRequest{}),
+		Responses: ok(&models.Review{}),
+		Errors:    []int{400, 403, 404},
+	},
+
+	// Reading lists
+	"GET /api/lists": {
+		Tag: "Reading lists", Summary: "Your reading lists, or the public lists of a user",
+		Params:    []*openapi.Parameter{uuidParam("user_id", "")},
+		Responses: list([]*models.ReadingList{}),
+		Errors:    []int{400},
+	},
+	"POST /api/lists": {
+		Tag: "Reading lists", Summary: "Create a reading list",
+		Request:   openapi.JSON(models.ReadingListRequest{}),
+		Responses: created(&models.ReadingList{}),
+		Errors:    []int{400},
+	},
+	"GET /api/lists/{id}": {
+		Tag: "Reading lists", Summary: "Get a reading list with its books",
+		Responses: ok(&models.ReadingList{}),
+		Errors:    []int{403, 404},
+	},
+	"PUT /api/lists/{id}": {
+		Tag: "Reading lists", Summary: "Update a reading list",
+		Request:   openapi.JSON(models.ReadingListRequest{}),
+		Responses: ok(&models.ReadingList{}),
+		Errors:    []int{400, 403, 404},
+	},
+	"DELETE /api/lists/{id}": {
+		Tag: "Reading lists", Summary: "Delete a reading list",
+		Responses: message,
+		Errors:    []int{403, 404},
+	},
+	"POST /api/lists/{id}/items": {
+		Tag: "Reading lists", Summary: "Add a book",
+		Request:   openapi.JSON(models.ReadingListItemRequest{}),
+		Responses: map[int]openapi.Body{201: envelope(&models.ReadingList{})},
+		Errors:    []int{400, 403, 404, 409},
+	},
+	"PUT /api/lists/{id}/items/{bookId}": {
+		Tag: "Reading lists", Summary: "Update the note or position of a book",
+		Params:    []*openapi.Parameter{idPath("bookId")},
+		Request:   openapi.JSON(models.ReadingListItemRequest{}),
+		Responses: ok(&models.ReadingList{}),
+		Errors:    []int{400, 403, 404},
+	},
+	"DELETE /api/lists/{id}/items/{bookId}": {
+		Tag: "Reading lists", Summary: "Remove a book",
+		Params:    []*openapi.Parameter{idPath("bookId")},
+		Responses: ok(&models.ReadingList{}),
+		Errors:    []int{403, 404},
+	},
+	"PUT /api/lists/{id}/order": {
+		Tag: "Reading lists", Summary: "Reorder the books",
+		Request:   openapi.JSON(models.ReorderListRequest{}),
+		Responses: ok(&models.ReadingList{}),
+		Errors:    []int{400, 403, 404},
+	},
+	"GET /api/shared-lists/{token}": {
+		Tag: "Reading lists", Summary: "Open a shared reading list",
+		Params:    []*openapi.Parameter{openapi.Path("token", openapi.String(""), "Share token of the list")},
+		Responses: ok(&models.ReadingList{}),
+		Errors:    []int{404},
+	},
+
+	// Fines
+	"GET /api/account": {
+		Tag: "Fines", Summary: "Your fine balance and ledger",
+		Responses: list(&models.Account{}),
+	},
+	"GET /api/users/{id}/account": {
+		Tag: "Fines", Summary: "Fine balance and ledger of a user",
+		Responses: list(&models.Account{}),
+		Errors:    []int{403, 404},
+	},
+	"POST /api/users/{id}/account/payments": {
+		Tag: "Fines", Summary: "Record a payment",
+		Request:   openapi.JSON(models.AccountCreditRequest{}),
+		Responses: created(&models.AccountEntry{}),
+		Errors:    []int{400, 403, 404},
+	},
+	"POST /api/users/{id}/account/waivers": {
+		Tag: "Fines", Summary: "Waive fines",
+		Request:   openapi.JSON(models.AccountCreditRequest{}),
+		Responses: created(&models.AccountEntry{}),
+		Errors:    []int{400, 403, 404},
+	},
+
+	// Administration
+	"GET /api/users": {
+		Tag: "Administration", Summary: "List users",
+		Responses: list([]*models.User{}),
+		Errors:    []int{403},
+	},
+	"POST /api/users": {
+		Tag: "Administration", Summary: "Create a user",
+		Request:   openapi.JSON(models.CreateUserRequest{}),
+		Responses: created(&models.User{}),
+		Errors:    []int{400, 403, 409},
+	},
+	"POST /api/users/{id}/disable": {
+		Tag: "Administration", Summary: "Disable a user and revoke their sessions",
+		Responses: message,
+		Errors:    []int{400, 403, 404},
+	},
+	"PUT /api/users/{id}/password": {
+		Tag: "Administration", Summary: "Reset the password of a user",
+		Request: openapi.JSON(struct {
+			Password string `json:"password" validate:"required"`
+		}{}),
+		Responses: message,
+		Errors:    []int{400, 403, 404},
+	},
+	"POST /api/users/{id}/tokens/revoke": {
+		Tag: "Administration", Summary: "Revoke the sessions of a user",
+		Responses: ok(map[string]int{}),
+		Errors:    []int{403, 404},
+	},
+	"POST /api/admin/tokens/cleanup": {
+		Tag: "Administration", Summary: "Delete expired session tokens",
+		Responses: ok(map[string]int64{}),
+		Errors:    []int{403},
+	},
+	"POST /api/admin/trash/purge": {
+		Tag: "Administration", Summary: "Permanently delete books deleted long ago",
+		Description: "Books with loans on record are kept for the loan history.",
+		Params: []*openapi.Parameter{
+			openapi.Query("older_than", openapi.String(""), "Go duration such as 720h, the default; 0s empties the trash"),
+		},
+		Responses: ok(openapi.Object{{Name: "purged", Value: 0}, {Name: "kept", Value: 0}}),
+		Errors:    []int{400, 403},
+	},
+	"GET /api/audit": {
+		Tag: "Administration", Summary: "Audit log, newest first",
+		Params: []*openapi.Parameter{
+			uuidParam("actor_id", ""),
+			openapi.Query("action", openapi.Enum(auditActions...), ""),
+			openapi.Query("entity_type", openapi.Enum(auditEntities...), ""),
+			openapi.Query("entity_id", openapi.String(""), ""),
+			openapi.Query("from", openapi.String("date-time"), ""),
+			openapi.Query("to", openapi.String("date-time"), ""),
+			uuidParam("before", "Only events older than this event, for the next page"),
+			limitParam(maxAuditLimit, ""),
+		},
+		Responses: list([]*models.AuditEvent{}, openapi.Property{Name: "next_before", Value: "", Optional: true}),
+		Errors:    []int{400, 403},
+	},
+
+	// Webhooks
+	"GET /api/webhooks": {
+		Tag: "Webhooks", Summary: "List webhook subscriptions",
+		Responses: list([]*models.WebhookSubscription{}),
+		Errors:    []int{403},
+	},
+	"POST /api/webhooks": {
+		Tag: "Webhooks", Summary: "Subscribe to events",
+		Request:   openapi.JSON(models.WebhookRequest{}),
+		Responses: created(&models.WebhookSubscription{}),
+		Errors:    []int{400, 403},
+	},
+	"GET /api/webhooks/{id}": {
+		Tag: "Webhooks", Summary: "Get a subscription",
+		Responses: ok(&models.WebhookSubscription{}),
+		Errors:    []int{403, 404},
+	},
+	"PUT /api/webhooks/{id}": {
+		Tag: "Webhooks", Summary: "Update a subscription",
+		Request:   openapi.JSON(models.WebhookRequest{}),
+		Responses: ok(&models.WebhookSubscription{}),
+		Errors:    []int{400, 403, 404},
+	},
+	"DELETE /api/webhooks/{id}": {
+		Tag: "Webhooks", Summary: "Delete a subscription",
+		Responses: message,
+		Errors:    []int{403, 404},
+	},
+	"GET /api/webhooks/{id}/deliveries": {
+		Tag: "Webhooks", Summary: "Delivery log, newest first",
+		Params: []*openapi.Parameter{
+			openapi.Query("status", openapi.Enum(models.DeliveryStatuses...), ""),
+			uuidParam("before", "Only deliveries older than this one, for the next page"),
+			limitParam(maxDeliveryLimit, ""),
+		},
+		Responses: list([]*models.WebhookDelivery{}, openapi.Property{Name: "next_before", Value: "", Optional: true}),
+		Errors:    []int{400, 403, 404},
+	},
+	"GET /api/webhooks/{id}/deliveries/{delivery_id}": {
+		Tag: "Webhooks", Summary: "Get a delivery",
+		Params:    []*openapi.Parameter{idPath("delivery_id")},
+		Responses: ok(&models.WebhookDelivery{}),
+		Errors:    []int{403, 404},
+	},
+	"POST /api/webhooks/{id}/deliveries/{delivery_id}/replay": {
+		Tag: "Webhooks", Summary: "Send a delivery again",
+		Params:    []*openapi.Parameter{idPath("delivery_id")},
+		Responses: map[int]openapi.Body{202: envelope(&models.WebhookDelivery{})},
+		Errors:    []int{403, 404},
+	},
+
+	// Live feed
+	"GET /api/events": {
+		Tag: "Live feed", Summary: "Server-sent events of book changes",
+		Params: []*openapi.Parameter{
+			uuidParam("book_id", "Only events of this book"),
+			openapi.Query("types", openapi.String(""), "Comma separated event types"),
+			openapi.Query("last_event_id", openapi.String(""), "Resume after this event, like the Last-Event-ID header"),
+			openapi.Query("ticket", openapi.String(""), "Ticket from POST /api/events/ticket, for clients that cannot set headers"),
+			{Name: "Last-Event-ID", In: "header", Schema: openapi.String("")},
+		},
+		Responses: map[int]openapi.Body{200: openapi.Content("text/event-stream")},
+		Errors:    []int{400, 503},
+	},
+	"GET /api/events/ws": {
+		Tag: "Live feed", Summary: "WebSocket feed of book changes",
+		Params: []*openapi.Parameter{
+			uuidParam("book_id", "Only events of this book"),
+			openapi.Query("types", openapi.String(""), "Comma separated event types"),
+			openapi.Query("last_event_id", openapi.String(""), "Resume after this event"),
+			openapi.Query("ticket", openapi.String(""), "Ticket from POST /api/events/ticket, for clients that cannot set headers"),
+		},
+		Responses: map[int]openapi.Body{101: nil},
+		Errors:    []int{400, 403, 503},
+	},
+	"POST /api/events/ticket": {
+		Tag: "Live feed", Summary: "Ticket to open the live feed without an Authorization header",
+		Description: "The ticket is passed as ?ticket= to GET /api/events or GET /api/events/ws. It works once, within expires_in seconds, and only while the session is valid.",
+		Responses: map[int]openapi.Body{200: openapi.JSON(openapi.Object{
+			{Name: "success", Value: true},
+			{Name: "ticket", Value: ""},
+			{Name: "expires_in", Value: 0},
+		})},
+		Errors: []int{},
+	},
+
+	// GraphQL
+	"GET /graphql": {
+		Tag: "GraphQL", Summary: "Run a GraphQL query",
+		Params: []*openapi.Parameter{
+			{Name: "query", In: "query", Required: true, Schema: openapi.String("")},
+			openapi.Query("operationName", openapi.String(""), ""),
+			openapi.Query("variables", openapi.String(""), "JSON object"),
+		},
+		Responses: graphqlResponses,
+	},
+	"POST /graphql": {
+		Tag: "GraphQL", Summary: "Run a GraphQL query or mutation",
+		Request:   openapi.JSON(graphql.Request{}),
+		Responses: graphqlResponses,
+	},
+	"GET /graphiql": {
+		ID: "GraphiQL", Tag: "GraphQL", Summary: "GraphiQL IDE",
+		Description: "Only served with GRAPHQL_DEV_MODE=true.",
+		Responses:   map[int]openapi.Body{200: openapi.Content("text/html")},
+		Optional:    true,
+	},
+
+	"GET /health": {
+		ID: "Health", Tag: "Health", Summary: "Health check",
+		Responses: map[int]openapi.Body{200: openapi.JSON(openapi.Object{
+			{Name: "status", Value: ""},
+			{Name: "message", Value: ""},
+		})},
+	},
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rest-api-golang/models"
+	"rest-api-golang/openapi"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	spec, err := OpenAPI(NewRouter())
+	if err != nil {
+		t.Fatalf("the OpenAPI document has drifted from the routes:\n%v", err)
+	}
+	if spec == nil || len(spec.Paths) == 0 {
+		t.Fatal("empty OpenAPI document")
+	}
+
+	// A route without an operation spec is drift too
+	router := NewRouter()
+	router.HandleFunc("/api/undocumented", Health).Methods("GET")
+	if _, err := OpenAPI(router); err == nil || !strings.Contains(err.Error(), "GET /api/undocumented") {
+		t.Errorf("undocumented route: %v", err)
+	}
+
+	// Requests refused before the database are validated without one
+	handler := validated(t)(NewRouter())
+	for _, body := range []string{"{", "[]"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/login", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("login with %s: status %d", body, w.Code)
+		}
+	}
+}
+
+// validated wraps the router in the OpenAPI validator, like OPENAPI_VALIDATE
+// in main, and fails the test on every violation
+func validated(t *testing.T) func(*mux.Router) http.Handler {
+	return func(router *mux.Router) http.Handler {
+		spec, err := OpenAPI(router)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return openapi.NewValidator(spec, func(v *openapi.Violation) { t.Error(v) }).Middleware(router)
+	}
+}
+
+// TestOpenAPIValidatesRequests sends real requests through the validator,
+// which reports any request or response the document does not describe
+func TestOpenAPIValidatesRequests(t *testing.T) {
+	srv := startTestServer(t, validated(t))
+	admin := srv.login(t, "admin", "admin123")
+	user := srv.login(t, "user", "user123")
+
+	var created struct {
+		Data models.Book `json:"data"`
+	}
+	book := models.CreateBookRequest{Judul: "OpenAPI", Author: "Test Author", TahunTerbit: 2020, Tags: []string{"spec"}}
+	if status := srv.do(t, "POST", "/api/books", admin, book, &created); status != http.StatusCreated {
+		t.Fatalf("create book: status %d", status)
+	}
+	id := created.Data.ID
+
+	tests := []struct {
+		method, path, token string
+		body                interface{}
+		want                int
+	}{
+		{"GET", "/health", "", nil, http.StatusOK},
+		{"GET", "/api/books", user, nil, http.StatusOK},
+		{"GET", "/api/books?format=paperback&limit=5", user, nil, http.StatusOK},
+		{"GET", "/api/books/" + id, user, nil, http.StatusOK},
+		{"PUT", "/api/books/" + id, admin, models.UpdateBookRequest{Judul: "OpenAPI, revised", TahunTerbit: 2021}, http.StatusOK},
+		{"GET", "/api/books/" + id + "/revisions", admin, nil, http.StatusOK},
+		{"GET", "/api/books/" + id + "/reviews", user, nil, http.StatusOK},
+		{"GET", "/api/books/" + id + "/copies", user, nil, http.StatusOK},
+		{"GET", "/api/authors", user, nil, http.StatusOK},
+		{"GET", "/api/categories", user, nil, http.StatusOK},
+		{"GET", "/api/tags", user, nil, http.StatusOK},
+		{"GET", "/api/mfa", user, nil, http.StatusOK},
+		{"GET", "/api/loans", user, nil, http.StatusOK},
+		{"GET", "/api/holds", user, nil, http.StatusOK},
+		{"GET", "/api/reviews", user, nil, http.StatusOK},
+		{"GET", "/api/lists", user, nil, http.StatusOK},
+		{"GET", "/api/account", user, nil, http.StatusOK},
+		{"POST", "/api/events/ticket", user, nil, http.StatusOK},
+		{"GET", "/api/users", admin, nil, http.StatusOK},
+		{"GET", "/api/audit", admin, nil, http.StatusOK},
+		{"GET", "/api/webhooks", admin, nil, http.StatusOK},
+		{"GET", "/api/admin/mfa-policy", admin, nil, http.StatusOK},
+		// Errors are checked against the documented error responses
+		{"GET", "/api/books/" + uuid.New().String(), user, nil, http.StatusNotFound},
+		{"POST", "/api/books", admin, models.CreateBookRequest{TahunTerbit: 2020}, http.StatusBadRequest},
+		{"GET", "/api/users", user, nil, http.StatusForbidden},
+		{"GET", "/api/books", "", nil, http.StatusUnauthorized},
+		{"DELETE", "/api/books/" + id, admin, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if status := srv.do(t, tt.method, tt.path, tt.token, tt.body, nil); status != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, status, tt.want)
+		}
+	}
+}
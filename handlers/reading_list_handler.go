@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// writeReadingListError maps reading list repository errors to a response
+func writeReadingListError(w http.ResponseWriter, err error, fallback string) {
+	status := http.StatusInternalServerError
+	message := fallback
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		status = http.StatusNotFound
+		message = capitalize(err.Error())
+	case errors.Is(err, repositories.ErrDuplicate):
+		status = http.StatusConflict
+		message = capitalize(err.Error())
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// applyReadingListRequest validates req and copies it onto l, creating a
+// share token when the list becomes shared. It returns an error message,
+// or "" if the request is valid.
+func applyReadingListRequest(l *models.ReadingList, req models.ReadingListRequest) string {
+	if name := strings.TrimSpace(req.Name); name != "" {
+		l.Name = name
+	}
+	if req.Description != "" {
+		l.Description = strings.TrimSpace(req.Description)
+	}
+	if req.Visibility != "" {
+		l.Visibility = strings.ToLower(req.Visibility)
+	}
+
+	switch {
+	case l.Name == "" || len(l.Name) > 100:
+		return "Name is required and must be at most 100 characters"
+	case len(l.Description) > 500:
+		return "Description must be at most 500 characters"
+	case !isOneOf(l.Visibility, models.ListVisibilities):
+		return "Invalid visibility, expected one of: " + strings.Join(models.ListVisibilities, ", ")
+	}
+
+	if l.Visibility == models.ListVisibilityShared && l.ShareToken == "" {
+		token, err := newSecretToken()
+		if err != nil {
+			return "Failed to create share link"
+		}
+		l.ShareToken = token
+	}
+	if l.Visibility != models.ListVisibilityShared {
+		l.ShareToken = ""
+	}
+	return ""
+}
+
+// getOwnReadingList loads the list in the URL and writes an error response
+// unless the current user owns it, or is an admin when allowAdmin is set
+func getOwnReadingList(w http.ResponseWriter, r *http.Request, allowAdmin bool) (*models.ReadingList, bool) {
+	l, err := readingListRepo.GetReadingList(mux.Vars(r)["id"])
+	user := currentUser(r)
+	if err == nil && l.UserID != user.ID && !(allowAdmin && user.Role == "admin") {
+		if l.Visibility == models.ListVisibilityPublic {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Only the owner can change this list",
+			})
+			return nil, false
+		}
+		err = repositories.ErrNotFound
+	}
+	if err != nil {
+		writeReadingListError(w, err, "Failed to fetch reading list")
+		return nil, false
+	}
+	return l, true
+}
+
+// GetReadingLists handles GET /api/lists: the current user's lists, or the
+// public lists of ?user_id= (all of them for admins)
+func GetReadingLists(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	userID := user.ID
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		if _, err := uuid.Parse(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Invalid user_id filter",
+			})
+			return
+		}
+		userID = v
+	}
+	own := userID == user.ID
+
+	lists, err := readingListRepo.ListReadingLists(userID, !own && user.Role != "admin")
+	if err != nil {
+		writeReadingListError(w, err, "Failed to fetch reading lists")
+		return
+	}
+	if !own {
+		for _, l := range lists {
+			l.ShareToken = ""
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    lists,
+		"count":   len(lists),
+	})
+}
+
+// CreateReadingList handles POST /api/lists
+func CreateReadingList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.ReadingListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	now := time.Now()
+	l := &models.ReadingList{
+		ID:         uuid.New().String(),
+		UserID:     currentUser(r).ID,
+		Visibility: models.ListVisibilityPrivate,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if msg := applyReadingListRequest(l, req); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	if err := readingListRepo.WithAudit(auditContext(r)).CreateReadingList(l); err != nil {
+		writeReadingListError(w, err, "Failed to create reading list")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Reading list created successfully",
+		"data":    l,
+	})
+}
+
+// writeReadingList responds with a list and its books
+func writeReadingList(w http.ResponseWriter, l *models.ReadingList) {
+	items, err := readingListRepo.ListItems(l.ID)
+	if err != nil {
+		writeReadingListError(w, err, "Failed to fetch reading list")
+		return
+	}
+	l.Items = items
+	l.ItemCount = len(items)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    l,
+	})
+}
+
+// GetReadingList handles GET /api/lists/{id} for the owner, admins, and
+// anyone if the list is public
+func GetReadingList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	l, err := readingListRepo.GetReadingList(mux.Vars(r)["id"])
+	if err == nil && l.UserID != user.ID && user.Role != "admin" && l.Visibility != models.ListVisibilityPublic {
+		err = repositories.ErrNotFound
+	}
+	if err != nil {
+		writeReadingListError(w, err, "Failed to fetch reading list")
+		return
+	}
+	if l.UserID != user.ID {
+		l.ShareToken = ""
+	}
+
+	writeReadingList(w, l)
+}
+
+// GetSharedReadingList handles GET /api/shared-lists/{token}, which needs
+// no login: the share link of a list with visibility "shared"
+func GetSharedReadingList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	l, err := readingListRepo.GetSharedReadingList(mux.Vars(r)["token"])
+	if err != nil {
+		writeReadingListError(w, err, "Failed to fetch reading list")
+		return
+	}
+	l.ShareToken = ""
+
+	writeReadingList(w, l)
+}
+
+// UpdateReadingList handles PUT /api/lists/{id} for the owner. Making a
+// list shared creates a share link; making it private or public revokes it.
+func UpdateReadingList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	l, ok := getOwnReadingList(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req models.ReadingListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+	if msg := applyReadingListRequest(l, req); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	if err := readingListRepo.WithAudit(auditContext(r)).UpdateReadingList(l); err != nil {
+		writeReadingListError(w, err, "Failed to update reading list")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Reading list updated successfully",
+		"data":    l,
+	})
+}
+
+// DeleteReadingList handles DELETE /api/lists/{id} for the owner or an
+// admin
+func DeleteReadingList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	l, ok := getOwnReadingList(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := readingListRepo.WithAudit(auditContext(r)).DeleteReadingList(l.ID); err != nil {
+		writeReadingListError(w, err, "Failed to delete reading list")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Reading list deleted successfully",
+	})
+}
+
+// decodeListItem reads a list item payload. It writes an error response
+// and returns false if the payload is invalid.
+func decodeListItem(w http.ResponseWriter, r *http.Request) (models.ReadingListItemRequest, bool) {
+	var req models.ReadingListItemRequest
+	msg := ""
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		msg = "Invalid JSON format"
+	} else if req.Position < 0 {
+		msg = "Position must be a positive number"
+	} else if req.Note != nil {
+		note := strings.TrimSpace(*req.Note)
+		req.Note = &note
+		if len(note) > 1000 {
+			msg = "Note must be at most 1000 characters"
+		}
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return req, false
+	}
+	return req, true
+}
+
+// AddReadingListItem handles POST /api/lists/{id}/items for the owner with
+// {"book_id": "...", "note": "...", "position": 1}; without a position the
+// book is appended
+func AddReadingListItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	l, ok := getOwnReadingList(w, r, false)
+	if !ok {
+		return
+	}
+	req, ok := decodeListItem(w, r)
+	if !ok {
+		return
+	}
+
+	book, err := bookRepo.GetBookByID(req.BookID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Book not found",
+		})
+		return
+	}
+	note := ""
+	if req.Note != nil {
+		note = *req.Note
+	}
+
+	if err := readingListRepo.WithAudit(auditContext(r)).AddItem(l.ID, book.ID, note, req.Position); err != nil {
+		writeReadingListError(w, err, "Failed to add book to list")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	writeReadingList(w, l)
+}
+
+// UpdateReadingListItem handles PUT /api/lists/{id}/items/{bookId} for the
+// owner: {"note": "..."} edits the note and {"position": 2} moves the book
+func UpdateReadingListItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	l, ok := getOwnReadingList(w, r, false)
+	if !ok {
+		return
+	}
+	req, ok := decodeListItem(w, r)
+	if !ok {
+		return
+	}
+
+	bookID := mux.Vars(r)["bookId"]
+	if _, err := uuid.Parse(bookID); err != nil {
+		writeReadingListError(w, fmt.Errorf("book in list %w", repositories.ErrNotFound), "")
+		return
+	}
+	if err := readingListRepo.WithAudit(auditContext(r)).UpdateItem(l.ID, bookID, req.Note, req.Position); err != nil {
+		writeReadingListError(w, err, "Failed to update book in list")
+		return
+	}
+
+	writeReadingList(w, l)
+}
+
+// RemoveReadingListItem handles DELETE /api/lists/{id}/items/{bookId} for
+// the owner
+func RemoveReadingListItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	l, ok := getOwnReadingList(w, r, false)
+	if !ok {
+		return
+	}
+
+	bookID := mux.Vars(r)["bookId"]
+	if _, err := uuid.Parse(bookID); err != nil {
+		writeReadingListError(w, fmt.Errorf("book in list %w", repositories.ErrNotFound), "")
+		return
+	}
+	if err := readingListRepo.WithAudit(auditContext(r)).RemoveItem(l.ID, bookID); err != nil {
+		writeReadingListError(w, err, "Failed to remove book from list")
+		return
+	}
+
+	writeReadingList(w, l)
+}
+
+// ReorderReadingList handles PUT /api/lists/{id}/order for the owner with
+// {"book_ids": [...]} listing every book shown in the list in the new order
+func ReorderReadingList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	l, ok := getOwnReadingList(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req models.ReorderListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	items, err := readingListRepo.ListItems(l.ID)
+	if err != nil {
+		writeReadingListError(w, err, "Failed to fetch reading list")
+		return
+	}
+	inList := make(map[string]bool, len(items))
+	for _, item := range items {
+		inList[item.BookID] = true
+	}
+	valid := len(req.BookIDs) == len(items)
+	for _, id := range req.BookIDs {
+		valid = valid && inList[id]
+		delete(inList, id)
+	}
+	if !valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "book_ids must list every book in the list exactly once",
+		})
+		return
+	}
+
+	if err := readingListRepo.WithAudit(auditContext(r)).ReorderItems(l.ID, req.BookIDs); err != nil {
+		writeReadingListError(w, err, "Failed to reorder reading list")
+		return
+	}
+
+	writeReadingList(w, l)
+}
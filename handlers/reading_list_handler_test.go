@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"rest-api-golang/models"
+)
+
+// readingList fetches a list with the token and returns it with status
+func (s *testServer) readingList(t *testing.T, token, path string) (*models.ReadingList, int) {
+	t.Helper()
+	var resp struct {
+		Data models.ReadingList `json:"data"`
+	}
+	status := s.do(t, "GET", path, token, nil, &resp)
+	return &resp.Data, status
+}
+
+// listOrder returns the titles of a list in order, checking that the
+// positions count from 1
+func (s *testServer) listOrder(t *testing.T, token, listID string) string {
+	t.Helper()
+	l, status := s.readingList(t, token, "/api/lists/"+listID)
+	if status != http.StatusOK {
+		t.Fatalf("get list: status %d", status)
+	}
+	var titles []string
+	for i, item := range l.Items {
+		if item.Position != i+1 {
+			t.Errorf("%s is at position %d, want %d", item.Book.Judul, item.Position, i+1)
+		}
+		titles = append(titles, item.Book.Judul)
+	}
+	if l.ItemCount != len(l.Items) {
+		t.Errorf("item_count %d for %d items", l.ItemCount, len(l.Items))
+	}
+	return strings.Join(titles, ",")
+}
+
+func TestReadingListOrder(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+	user := srv.login(t, "user", "user123")
+
+	books := map[string]string{}
+	for _, title := range []string{"A", "B", "C", "D"} {
+		books[title] = srv.createBook(t, admin, models.CreateBookRequest{Judul: title, Author: "Penulis", TahunTerbit: 2000}).ID
+	}
+
+	var created struct {
+		Data models.ReadingList `json:"data"`
+	}
+	if status := srv.do(t, "POST", "/api/lists", user, models.ReadingListRequest{Name: "To read"}, &created); status != http.StatusCreated {
+		t.Fatalf("create list: status %d", status)
+	}
+	id := created.Data.ID
+	if created.Data.Visibility != models.ListVisibilityPrivate {
+		t.Errorf("new list is %s", created.Data.Visibility)
+	}
+	if status := srv.do(t, "POST", "/api/lists", user, models.ReadingListRequest{Name: "TO READ"}, nil); status != http.StatusConflict {
+		t.Errorf("list name differing only in case: status %d", status)
+	}
+
+	add := func(title string, position int, want int) {
+		t.Helper()
+		req := models.ReadingListItemRequest{BookID: books[title], Position: position}
+		if status := srv.do(t, "POST", "/api/lists/"+id+"/items", user, req, nil); status != want {
+			t.Errorf("add %s at %d: status %d, want %d", title, position, status, want)
+		}
+	}
+	add("A", 0, http.StatusCreated)
+	add("B", 0, http.StatusCreated)
+	add("C", 0, http.StatusCreated)
+	add("D", 1, http.StatusCreated)
+	add("A", 0, http.StatusConflict)
+	add("B", -1, http.StatusBadRequest)
+	if got := srv.listOrder(t, user, id); got != "D,A,B,C" {
+		t.Errorf("after adding: %s", got)
+	}
+
+	move := func(title string, req models.ReadingListItemRequest) {
+		t.Helper()
+		if status := srv.do(t, "PUT", "/api/lists/"+id+"/items/"+books[title], user, req, nil); status != http.StatusOK {
+			t.Errorf("update %s: status %d", title, status)
+		}
+	}
+	move("C", models.ReadingListItemRequest{Position: 2})
+	if got := srv.listOrder(t, user, id); got != "D,C,A,B" {
+		t.Errorf("after moving C up: %s", got)
+	}
+	move("D", models.ReadingListItemRequest{Position: 4})
+	note := "Rekomendasi teman"
+	move("A", models.ReadingListItemRequest{Note: &note})
+	if got := srv.listOrder(t, user, id); got != "C,A,B,D" {
+		t.Errorf("after moving D down and editing a note: %s", got)
+	}
+
+	if status := srv.do(t, "DELETE", "/api/lists/"+id+"/items/"+books["C"], user, nil, nil); status != http.StatusOK {
+		t.Fatalf("remove: status %d", status)
+	}
+	if got := srv.listOrder(t, user, id); got != "A,B,D" {
+		t.Errorf("after removing C: %s", got)
+	}
+
+	reorder := func(want int, titles ...string) {
+		t.Helper()
+		req := models.ReorderListRequest{BookIDs: []string{}}
+		for _, title := range titles {
+			req.BookIDs = append(req.BookIDs, books[title])
+		}
+		if status := srv.do(t, "PUT", "/api/lists/"+id+"/order", user, req, nil); status != want {
+			t.Errorf("reorder %v: status %d, want %d", titles, status, want)
+		}
+	}
+	reorder(http.StatusOK, "D", "A", "B")
+	reorder(http.StatusBadRequest, "D", "A")
+	reorder(http.StatusBadRequest, "D", "D", "A")
+	reorder(http.StatusBadRequest, "D", "A", "C")
+	if got := srv.listOrder(t, user, id); got != "D,A,B" {
+		t.Errorf("after reordering: %s", got)
+	}
+
+	// A deleted book is hidden and comes back at its place when restored
+	deleteA := func() {
+		t.Helper()
+		if status := srv.do(t, "DELETE", "/api/books/"+books["A"], admin, nil, nil); status != http.StatusOK {
+			t.Fatalf("delete book: status %d", status)
+		}
+	}
+	restoreA := func() {
+		t.Helper()
+		if status := srv.do(t, "POST", "/api/books/"+books["A"]+"/restore", admin, nil, nil); status != http.StatusOK {
+			t.Fatalf("restore book: status %d", status)
+		}
+	}
+	deleteA()
+	if got := srv.listOrder(t, user, id); got != "D,B" {
+		t.Errorf("with A deleted: %s", got)
+	}
+	restoreA()
+	if got := srv.listOrder(t, user, id); got != "D,A,B" {
+		t.Errorf("with A restored: %s", got)
+	}
+
+	// Reordering while a book is hidden puts it after the listed books
+	deleteA()
+	reorder(http.StatusOK, "B", "D")
+	restoreA()
+	if got := srv.listOrder(t, user, id); got != "B,D,A" {
+		t.Errorf("with A restored after a reorder: %s", got)
+	}
+	note = ""
+	l, _ := srv.readingList(t, user, "/api/lists/"+id)
+	for _, item := range l.Items {
+		if item.BookID == books["A"] {
+			note = item.Note
+		}
+	}
+	if note != "Rekomendasi teman" {
+		t.Errorf("note of the restored book: %q", note)
+	}
+}
+
+func TestReadingListVisibility(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+	owner := srv.login(t, "user", "user123")
+	other := srv.addMember(t, admin, "pembaca")
+	bookID := srv.createBook(t, admin, models.CreateBookRequest{Judul: "Bumi Manusia", Author: "Pramoedya Ananta Toer", TahunTerbit: 1980}).ID
+
+	var created struct {
+		Data models.ReadingList `json:"data"`
+	}
+	srv.do(t, "POST", "/api/lists", owner, models.ReadingListRequest{Name: "Favorites"}, &created)
+	id, ownerID := created.Data.ID, created.Data.UserID
+	srv.do(t, "POST", "/api/lists/"+id+"/items", owner, models.ReadingListItemRequest{BookID: bookID}, nil)
+
+	// access returns the status of each kind of access by other, and the
+	// number of the owner's lists other can see
+	type access struct{ get, update, delete, listed int }
+	check := func(step string, want access) {
+		t.Helper()
+		var got access
+		_, got.get = srv.readingList(t, other, "/api/lists/"+id)
+		got.update = srv.do(t, "PUT", "/api/lists/"+id, other, models.ReadingListRequest{Name: "Taken"}, nil)
+		got.delete = srv.do(t, "DELETE", "/api/lists/"+id+"/items/"+bookID, other, nil, nil)
+		var lists struct {
+			Data []models.ReadingList `json:"data"`
+		}
+		srv.do(t, "GET", "/api/lists?user_id="+ownerID, other, nil, &lists)
+		got.listed = len(lists.Data)
+		for _, l := range lists.Data {
+			if l.ShareToken != "" {
+				t.Errorf("%s: share token shown to another member", step)
+			}
+		}
+		if got != want {
+			t.Errorf("%s: access %+v, want %+v", step, got, want)
+		}
+	}
+	setVisibility := func(visibility string) *models.ReadingList {
+		t.Helper()
+		var resp struct {
+			Data models.ReadingList `json:"data"`
+		}
+		if status := srv.do(t, "PUT", "/api/lists/"+id, owner, models.ReadingListRequest{Visibility: visibility}, &resp); status != http.StatusOK {
+			t.Fatalf("set %s: status %d", visibility, status)
+		}
+		return &resp.Data
+	}
+
+	check("private", access{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound, 0})
+	if l, status := srv.readingList(t, admin, "/api/lists/"+id); status != http.StatusOK || len(l.Items) != 1 {
+		t.Errorf("admin reading a private list: status %d, %d items", status, len(l.Items))
+	}
+
+	shared := setVisibility("shared")
+	if shared.ShareToken == "" {
+		t.Fatal("shared list has no share token")
+	}
+	check("shared", access{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound, 0})
+	l, status := srv.readingList(t, "", "/api/shared-lists/"+shared.ShareToken)
+	if status != http.StatusOK || len(l.Items) != 1 || l.ShareToken != "" {
+		t.Errorf("share link: status %d, %d items, token %q", status, len(l.Items), l.ShareToken)
+	}
+	if again := setVisibility("shared"); again.ShareToken != shared.ShareToken {
+		t.Error("saving a shared list changed its share link")
+	}
+
+	public := setVisibility("public")
+	if public.ShareToken != "" {
+		t.Error("public list kept its share token")
+	}
+	if _, status := srv.readingList(t, "", "/api/shared-lists/"+shared.ShareToken); status != http.StatusNotFound {
+		t.Errorf("revoked share link: status %d", status)
+	}
+	check("public", access{http.StatusOK, http.StatusForbidden, http.StatusForbidden, 1})
+
+	if status := srv.do(t, "PUT", "/api/lists/"+id, owner, models.ReadingListRequest{Visibility: "friends"}, nil); status != http.StatusBadRequest {
+		t.Errorf("unknown visibility: status %d", status)
+	}
+	if status := srv.do(t, "DELETE", "/api/lists/"+id, other, nil, nil); status != http.StatusForbidden {
+		t.Errorf("delete by another member: status %d", status)
+	}
+	if status := srv.do(t, "DELETE", "/api/lists/"+id, admin, nil, nil); status != http.StatusOK {
+		t.Errorf("delete by admin: status %d", status)
+	}
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// newReviewStatus is the status of a new or edited review: pending when
+// REVIEW_MODERATION=true, approved otherwise
+func newReviewStatus() string {
+	if os.Getenv("REVIEW_MODERATION") == "true" {
+		return models.ReviewStatusPending
+	}
+	return models.ReviewStatusApproved
+}
+
+// writeReviewError maps review repository errors to a response
+func writeReviewError(w http.ResponseWriter, err error, fallback string) {
+	status := http.StatusInternalServerError
+	message := fallback
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		status = http.StatusNotFound
+		message = capitalize(err.Error())
+	case errors.Is(err, repositories.ErrDuplicate):
+		status = http.StatusConflict
+		message = "You have already reviewed this book; edit your review instead"
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// decodeReview reads and validates a review payload into review. It writes
+// an error response and returns false if the payload is invalid.
+func decodeReview(w http.ResponseWriter, r *http.Request, review *models.Review) bool {
+	var req models.ReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return false
+	}
+
+	review.Rating = req.Rating
+	review.Title = strings.TrimSpace(req.Title)
+	review.Body = strings.TrimSpace(req.Body)
+
+	msg := ""
+	switch {
+	case review.Rating < 1 || review.Rating > 5:
+		msg = "Rating must be a whole number from 1 to 5"
+	case len(review.Title) > 200:
+		msg = "Title must be at most 200 characters"
+	case len(review.Body) > 5000:
+		msg = "Body must be at most 5000 characters"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return false
+	}
+	return true
+}
+
+// GetBookReviews handles GET /api/books/{id}/reviews. Members see approved
+// reviews and their own; admins see all and may filter by ?status=.
+func GetBookReviews(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	book, err := bookRepo.GetBookByID(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Book not found",
+		})
+		return
+	}
+
+	filter := models.ReviewFilter{BookID: book.ID}
+	if user.Role == "admin" {
+		filter.Status = r.URL.Query().Get("status")
+		if filter.Status != "" && !isOneOf(filter.Status, models.ReviewStatuses) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Invalid status filter, expected one of: " + strings.Join(models.ReviewStatuses, ", "),
+			})
+			return
+		}
+	} else {
+		filter.VisibleTo = user.ID
+	}
+
+	reviews, err := reviewRepo.ListReviews(filter)
+	if err != nil {
+		writeReviewError(w, err, "Failed to fetch reviews")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":        true,
+		"data":           reviews,
+		"count":          len(reviews),
+		"average_rating": book.AverageRating,
+		"rating_count":   book.RatingCount,
+	})
+}
+
+// CreateReview handles POST /api/books/{id}/reviews. Each member can
+// review a book once.
+func CreateReview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	book, err := bookRepo.GetBookByID(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Book not found",
+		})
+		return
+	}
+
+	now := time.Now()
+	review := &models.Review{
+		ID:        uuid.New().String(),
+		BookID:    book.ID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Status:    newReviewStatus(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if !decodeReview(w, r, review) {
+		return
+	}
+
+	if err := reviewRepo.WithAudit(auditContext(r)).CreateReview(review); err != nil {
+		writeReviewError(w, err, "Failed to create review")
+		return
+	}
+
+	message := "Review created successfully"
+	if review.Status == models.ReviewStatusPending {
+		message = "Review submitted and awaiting moderation"
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": message,
+		"data":    review,
+	})
+}
+
+// GetReviews handles GET /api/reviews. Admins see all reviews and may
+// filter by ?status=, ?user_id= and ?book_id=, e.g. ?status=pending for the
+// moderation queue; members see their own reviews.
+func GetReviews(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+	q := r.URL.Query()
+
+	filter := models.ReviewFilter{
+		UserID: q.Get("user_id"),
+		BookID: q.Get("book_id"),
+		Status: q.Get("status"),
+	}
+	if user.Role != "admin" {
+		filter.UserID = user.ID
+	}
+
+	msg := ""
+	for _, id := range []string{filter.UserID, filter.BookID} {
+		if _, err := uuid.Parse(id); id != "" && err != nil {
+			msg = "Invalid user_id or book_id filter"
+		}
+	}
+	if filter.Status != "" && !isOneOf(filter.Status, models.ReviewStatuses) {
+		msg = "Invalid status filter, expected one of: " + strings.Join(models.ReviewStatuses, ", ")
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": msg,
+		})
+		return
+	}
+
+	reviews, err := reviewRepo.ListReviews(filter)
+	if err != nil {
+		writeReviewError(w, err, "Failed to fetch reviews")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    reviews,
+		"count":   len(reviews),
+	})
+}
+
+// getVisibleReview loads the review in the URL and writes a 404 response
+// unless it is approved, belongs to the current user or the user is an
+// admin
+func getVisibleReview(w http.ResponseWriter, r *http.Request) (*models.Review, bool) {
+	review, err := reviewRepo.GetReviewByID(mux.Vars(r)["id"])
+	user := currentUser(r)
+	if err == nil && review.Status != models.ReviewStatusApproved && review.UserID != user.ID && user.Role != "admin" {
+		err = repositories.ErrNotFound
+	}
+	if err != nil {
+		writeReviewError(w, err, "Failed to fetch review")
+		return nil, false
+	}
+	return review, true
+}
+
+// GetReview handles GET /api/reviews/{id}
+func GetReview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	review, ok := getVisibleReview(w, r)
+	if !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    review,
+	})
+}
+
+// UpdateReview handles PUT /api/reviews/{id} for the author of the review.
+// An edited review goes back to moderation when REVIEW_MODERATION=true; a
+// hidden review stays hidden.
+func UpdateReview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	review, ok := getVisibleReview(w, r)
+	if !ok {
+		return
+	}
+	if review.UserID != currentUser(r).ID {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Only the author can edit a review",
+		})
+		return
+	}
+
+	if !decodeReview(w, r, review) {
+		return
+	}
+	if review.Status != models.ReviewStatusHidden {
+		review.Status = newReviewStatus()
+	}
+	review.UpdatedAt = time.Now()
+
+	if err := reviewRepo.WithAudit(auditContext(r)).UpdateReview(review); err != nil {
+		writeReviewError(w, err, "Failed to update review")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Review updated successfully",
+		"data":    review,
+	})
+}
+
+// DeleteReview handles DELETE /api/reviews/{id} for the author or an admin
+func DeleteReview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user := currentUser(r)
+
+	review, ok := getVisibleReview(w, r)
+	if !ok {
+		return
+	}
+	if review.UserID != user.ID && user.Role != "admin" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Only the author or an admin can delete a review",
+		})
+		return
+	}
+
+	if err := reviewRepo.WithAudit(auditContext(r)).DeleteReview(review); err != nil {
+		writeReviewError(w, err, "Failed to delete review")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Review deleted successfully",
+	})
+}
+
+// ModerateReview handles PUT /api/reviews/{id}/status (admin) with
+// {"status": "pending|approved|hidden"}
+func ModerateReview(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	review, err := reviewRepo.GetReviewByID(mux.Vars(r)["id"])
+	if err != nil {
+		writeReviewError(w, err, "Failed to fetch review")
+		return
+	}
+
+	var req models.ModerateReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+	if !isOneOf(req.Status, models.ReviewStatuses) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid status, expected one of: " + strings.Join(models.ReviewStatuses, ", "),
+		})
+		return
+	}
+
+	review.Status = req.Status
+	if err := reviewRepo.WithAudit(auditContext(r)).ModerateReview(review, currentUser(r).ID); err != nil {
+		writeReviewError(w, err, "Failed to moderate review")
+		return
+	}
+	review, err = reviewRepo.GetReviewByID(review.ID)
+	if err != nil {
+		writeReviewError(w, err, "Failed to fetch review")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Review status updated successfully",
+		"data":    review,
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"rest-api-golang/models"
+)
+
+// rating returns the average rating and rating count of a book
+func (s *testServer) rating(t *testing.T, token, bookID string) (float64, int) {
+	t.Helper()
+	var resp struct {
+		Data models.Book `json:"data"`
+	}
+	if status := s.do(t, "GET", "/api/books/"+bookID, token, nil, &resp); status != http.StatusOK {
+		t.Fatalf("get book: status %d", status)
+	}
+	return resp.Data.AverageRating, resp.Data.RatingCount
+}
+
+// review posts a review and returns it
+func (s *testServer) review(t *testing.T, token, bookID string, rating int) *models.Review {
+	t.Helper()
+	var resp struct {
+		Data models.Review `json:"data"`
+	}
+	if status := s.do(t, "POST", "/api/books/"+bookID+"/reviews", token, models.ReviewRequest{Rating: rating, Title: "Ulasan"}, &resp); status != http.StatusCreated {
+		t.Fatalf("review with %d stars: status %d", rating, status)
+	}
+	return &resp.Data
+}
+
+func TestReviewRatings(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+	user := srv.login(t, "user", "user123")
+	other := srv.addMember(t, admin, "pembaca")
+	book := srv.createBook(t, admin, models.CreateBookRequest{Judul: "Laskar Pelangi", Author: "Andrea Hirata", TahunTerbit: 2005})
+	id := book.ID
+
+	check := func(step string, average float64, count int) {
+		t.Helper()
+		if a, c := srv.rating(t, user, id); a != average || c != count {
+			t.Errorf("%s: rating %.2f from %d reviews, want %.2f from %d", step, a, c, average, count)
+		}
+	}
+	check("no reviews", 0, 0)
+
+	mine := srv.review(t, user, id, 4)
+	check("first review", 4, 1)
+	if status := srv.do(t, "POST", "/api/books/"+id+"/reviews", user, models.ReviewRequest{Rating: 2}, nil); status != http.StatusConflict {
+		t.Errorf("second review by the same member: status %d", status)
+	}
+	for _, rating := range []int{0, 6} {
+		if status := srv.do(t, "POST", "/api/books/"+id+"/reviews", other, models.ReviewRequest{Rating: rating}, nil); status != http.StatusBadRequest {
+			t.Errorf("rating %d: status %d", rating, status)
+		}
+	}
+	theirs := srv.review(t, other, id, 5)
+	adminReview := srv.review(t, admin, id, 5)
+	check("three reviews", 4.67, 3)
+
+	// Editing a rating updates the average; only the author may edit
+	if status := srv.do(t, "PUT", "/api/reviews/"+mine.ID, user, models.ReviewRequest{Rating: 2}, nil); status != http.StatusOK {
+		t.Fatalf("edit: status %d", status)
+	}
+	check("edited", 4, 3)
+	if status := srv.do(t, "PUT", "/api/reviews/"+mine.ID, other, models.ReviewRequest{Rating: 5}, nil); status != http.StatusForbidden {
+		t.Errorf("edit by another member: status %d", status)
+	}
+
+	// Hidden reviews do not count and stay hidden when edited
+	if status := srv.do(t, "PUT", "/api/reviews/"+theirs.ID+"/status", admin, models.ModerateReviewRequest{Status: models.ReviewStatusHidden}, nil); status != http.StatusOK {
+		t.Fatalf("hide: status %d", status)
+	}
+	check("hidden", 3.5, 2)
+	var edited struct {
+		Data models.Review `json:"data"`
+	}
+	srv.do(t, "PUT", "/api/reviews/"+theirs.ID, other, models.ReviewRequest{Rating: 1}, &edited)
+	if edited.Data.Status != models.ReviewStatusHidden {
+		t.Errorf("edited hidden review is %s", edited.Data.Status)
+	}
+	check("hidden review edited", 3.5, 2)
+
+	// Members see approved reviews and their own
+	var list struct {
+		Data          []models.Review `json:"data"`
+		AverageRating float64         `json:"average_rating"`
+		RatingCount   int             `json:"rating_count"`
+	}
+	srv.do(t, "GET", "/api/books/"+id+"/reviews", user, nil, &list)
+	if len(list.Data) != 2 || list.AverageRating != 3.5 || list.RatingCount != 2 {
+		t.Errorf("reviews seen by another member: %d, %.2f from %d", len(list.Data), list.AverageRating, list.RatingCount)
+	}
+	srv.do(t, "GET", "/api/books/"+id+"/reviews", other, nil, &list)
+	if len(list.Data) != 3 {
+		t.Errorf("the author of a hidden review sees %d reviews", len(list.Data))
+	}
+	if status := srv.do(t, "GET", "/api/reviews/"+theirs.ID, user, nil, nil); status != http.StatusNotFound {
+		t.Errorf("another member's hidden review: status %d", status)
+	}
+
+	if status := srv.do(t, "PUT", "/api/reviews/"+theirs.ID+"/status", admin, models.ModerateReviewRequest{Status: models.ReviewStatusApproved}, nil); status != http.StatusOK {
+		t.Fatalf("approve: status %d", status)
+	}
+	check("approved again", 2.67, 3)
+	if status := srv.do(t, "PUT", "/api/reviews/"+theirs.ID+"/status", user, models.ModerateReviewRequest{Status: models.ReviewStatusHidden}, nil); status != http.StatusForbidden {
+		t.Errorf("moderation by a member: status %d", status)
+	}
+
+	// Deleting by the author or an admin; not by other members
+	if status := srv.do(t, "DELETE", "/api/reviews/"+adminReview.ID, user, nil, nil); status != http.StatusForbidden {
+		t.Errorf("delete by another member: status %d", status)
+	}
+	if status := srv.do(t, "DELETE", "/api/reviews/"+adminReview.ID, admin, nil, nil); status != http.StatusOK {
+		t.Fatalf("delete: status %d", status)
+	}
+	if status := srv.do(t, "DELETE", "/api/reviews/"+mine.ID, admin, nil, nil); status != http.StatusOK {
+		t.Fatalf("delete by admin: status %d", status)
+	}
+	check("deleted", 1, 1)
+
+	// The stored rating drives filtering and sorting of the book list
+	srv.createBook(t, admin, models.CreateBookRequest{Judul: "Ronggeng Dukuh Paruk", Author: "Ahmad Tohari", TahunTerbit: 1982})
+	if titles := srv.bookTitles(t, user, "/api/books?min_rating=1"); len(titles) != 1 || titles[0] != "Laskar Pelangi" {
+		t.Errorf("min_rating=1: %v", titles)
+	}
+}
+
+func TestReviewModeration(t *testing.T) {
+	t.Setenv("REVIEW_MODERATION", "true")
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+	user := srv.login(t, "user", "user123")
+	id := srv.createBook(t, admin, models.CreateBookRequest{Judul: "Cantik Itu Luka", Author: "Eka Kurniawan", TahunTerbit: 2002}).ID
+
+	review := srv.review(t, user, id, 5)
+	if review.Status != models.ReviewStatusPending {
+		t.Fatalf("new review is %s", review.Status)
+	}
+	if average, count := srv.rating(t, user, id); average != 0 || count != 0 {
+		t.Errorf("pending review counted: %.2f from %d", average, count)
+	}
+
+	var queue struct {
+		Data []models.Review `json:"data"`
+	}
+	srv.do(t, "GET", "/api/reviews?status=pending", admin, nil, &queue)
+	if len(queue.Data) != 1 || queue.Data[0].ID != review.ID {
+		t.Fatalf("moderation queue %+v", queue.Data)
+	}
+
+	var approved struct {
+		Data models.Review `json:"data"`
+	}
+	srv.do(t, "PUT", "/api/reviews/"+review.ID+"/status", admin, models.ModerateReviewRequest{Status: models.ReviewStatusApproved}, &approved)
+	if approved.Data.Status != models.ReviewStatusApproved || approved.Data.ModeratedAt == nil || approved.Data.ModeratedBy == "" {
+		t.Errorf("approved review %+v", approved.Data)
+	}
+	if average, count := srv.rating(t, user, id); average != 5 || count != 1 {
+		t.Errorf("approved review: %.2f from %d", average, count)
+	}
+
+	// An edit goes back to the queue and out of the average
+	srv.do(t, "PUT", "/api/reviews/"+review.ID, user, models.ReviewRequest{Rating: 1}, nil)
+	if average, count := srv.rating(t, user, id); average != 0 || count != 0 {
+		t.Errorf("edited review still counted: %.2f from %d", average, count)
+	}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rest-api-golang/repositories"
+
+	"github.com/gorilla/mux"
+)
+
+// writeRevisionError writes a JSON error response
+func writeRevisionError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// revisionNumber parses a revision number from a path or query value
+func revisionNumber(v string) (int, bool) {
+	n, err := strconv.Atoi(v)
+	return n, err == nil && n >= 1
+}
+
+// getBookAsOf answers GET /api/books/{id}?as_of=: the book with the
+// editable fields it had at the RFC 3339 time asOf
+func getBookAsOf(w http.ResponseWriter, id, asOf string) {
+	at, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		writeRevisionError(w, http.StatusBadRequest, "Invalid as_of, expected an RFC 3339 time")
+		return
+	}
+
+	book, revision, err := bookRepo.GetBookAsOf(id, at)
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeRevisionError(w, http.StatusNotFound, "Book not found at that time")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get book %s as of %s: %v", id, asOf, err)
+		writeRevisionError(w, http.StatusInternalServerError, "Failed to retrieve book")
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data":    book,
+		"as_of":   at,
+	}
+	if revision != nil {
+		response["revision"] = revision.Revision
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetBookRevisions handles GET /api/books/{id}/revisions: the revisions of a
+// book, oldest first, with who made each one and the fields it changed
+func GetBookRevisions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	if _, err := bookRepo.GetBookByID(id); err != nil {
+		writeRevisionError(w, http.StatusNotFound, "Book not found")
+		return
+	}
+
+	revisions, err := bookRepo.ListBookRevisions(id)
+	if err != nil {
+		log.Printf("Failed to list revisions of book %s: %v", id, err)
+		writeRevisionError(w, http.StatusInternalServerError, "Failed to retrieve revisions")
+		return
+	}
+	// Snapshots are left out of the list; GET .../revisions/{n} has them
+	for _, v := range revisions {
+		v.Book = nil
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Revisions retrieved successfully",
+		"data":    revisions,
+		"count":   len(revisions),
+	})
+}
+
+// GetBookRevision handles GET /api/books/{id}/revisions/{n}: revision n of a
+// book with its snapshot
+func GetBookRevision(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+
+	n, ok := revisionNumber(vars["n"])
+	if !ok {
+		writeRevisionError(w, http.StatusBadRequest, "Invalid revision number")
+		return
+	}
+	if _, err := bookRepo.GetBookByID(vars["id"]); err != nil {
+		writeRevisionError(w, http.StatusNotFound, "Book not found")
+		return
+	}
+
+	revision, err := bookRepo.GetBookRevision(vars["id"], n)
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeRevisionError(w, http.StatusNotFound, "Revision not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get revision %d of book %s: %v", n, vars["id"], err)
+		writeRevisionError(w, http.StatusInternalServerError, "Failed to retrieve revision")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    revision,
+	})
+}
+
+// DiffBookRevisions handles GET /api/books/{id}/revisions/diff: the fields
+// that differ between revision ?from= and revision ?to= of a book. to
+// defaults to the latest revision and from to the one before to.
+func DiffBookRevisions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+	q := r.URL.Query()
+
+	if _, err := bookRepo.GetBookByID(id); err != nil {
+		writeRevisionError(w, http.StatusNotFound, "Book not found")
+		return
+	}
+
+	to, ok := 0, true
+	if v := q.Get("to"); v != "" {
+		to, ok = revisionNumber(v)
+	} else {
+		latest, err := bookRepo.LatestBookRevision(id)
+		if err != nil {
+			log.Printf("Failed to get latest revision of book %s: %v", id, err)
+			writeRevisionError(w, http.StatusInternalServerError, "Failed to compare revisions")
+			return
+		}
+		to = latest
+	}
+	if !ok {
+		writeRevisionError(w, http.StatusBadRequest, "Invalid to, expected a revision number")
+		return
+	}
+	from := to - 1
+	if v := q.Get("from"); v != "" {
+		if from, ok = revisionNumber(v); !ok {
+			writeRevisionError(w, http.StatusBadRequest, "Invalid from, expected a revision number")
+			return
+		}
+	}
+
+	diff, err := bookRepo.DiffBookRevisions(id, from, to)
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeRevisionError(w, http.StatusNotFound, "Revision not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to diff revisions %d and %d of book %s: %v", from, to, id, err)
+		writeRevisionError(w, http.StatusInternalServerError, "Failed to compare revisions")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    diff,
+		"count":   len(diff.Changes),
+	})
+}
+
+// RevertBook handles POST /api/books/{id}/revisions/{n}/revert: sets the book
+// back to revision n. The result is a new revision; none are removed.
+func RevertBook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	n, ok := revisionNumber(vars["n"])
+	if !ok {
+		writeRevisionError(w, http.StatusBadRequest, "Invalid revision number")
+		return
+	}
+	if _, err := bookRepo.GetBookByID(id); err != nil {
+		writeRevisionError(w, http.StatusNotFound, "Book not found")
+		return
+	}
+	if _, err := bookRepo.GetBookRevision(id, n); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			writeRevisionError(w, http.StatusNotFound, "Revision not found")
+			return
+		}
+		log.Printf("Failed to get revision %d of book %s: %v", n, id, err)
+		writeRevisionError(w, http.StatusInternalServerError, "Failed to revert book")
+		return
+	}
+
+	book, err := bookRepo.WithAudit(auditContext(r)).RevertBook(id, n)
+	switch {
+	case errors.Is(err, repositories.ErrDuplicate):
+		writeRevisionError(w, http.StatusConflict, "The ISBN of that revision is now used by another book")
+		return
+	case errors.Is(err, repositories.ErrNotFound):
+		// The book and revision exist, so an author or category is gone
+		writeRevisionError(w, http.StatusConflict, "Revision refers to something that no longer exists: "+err.Error())
+		return
+	case err != nil:
+		log.Printf("Failed to revert book %s to revision %d: %v", id, n, err)
+		writeRevisionError(w, http.StatusInternalServerError, "Failed to revert book")
+		return
+	}
+
+	latest, err := bookRepo.LatestBookRevision(id)
+	if err != nil {
+		log.Printf("Failed to get latest revision of book %s: %v", id, err)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Book reverted to revision " + strconv.Itoa(n),
+		"data":     book,
+		"revision": latest,
+	})
+}
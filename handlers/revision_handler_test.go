@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"rest-api-golang/database"
+	"rest-api-golang/models"
+)
+
+// updateBook sends PUT /api/books/{id} and fails the test unless it works
+func (s *testServer) updateBook(t *testing.T, token, id string, req interface{}) {
+	t.Helper()
+	if status := s.do(t, "PUT", "/api/books/"+id, token, req, nil); status != http.StatusOK {
+		t.Fatalf("update book: status %d", status)
+	}
+}
+
+// backdateRevisions moves the book and its revisions to the first days of
+// 2024: the book and revision 1 to January 1, revision 2 to February 1,
+// and so on
+func backdateRevisions(t *testing.T, bookID string) {
+	t.Helper()
+	_, err := database.DB.Exec(`
+		UPDATE book_revisions
+		SET created_at = TIMESTAMPTZ '2024-01-01 00:00:00Z' + (revision - 1) * INTERVAL '1 month'
+		WHERE book_id = $1`, bookID)
+	if err == nil {
+		_, err = database.DB.Exec(`UPDATE books SET created_at = TIMESTAMPTZ '2024-01-01 00:00:00Z' WHERE id = $1`, bookID)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBookAsOf(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+	user := srv.login(t, "user", "user123")
+	book := srv.createBook(t, admin, models.CreateBookRequest{Judul: "Draft", Author: "Penulis", TahunTerbit: 2001, Tags: []string{"awal"}})
+	srv.updateBook(t, admin, book.ID, models.UpdateBookRequest{Judul: "Second"})
+	srv.updateBook(t, admin, book.ID, models.UpdateBookRequest{TahunTerbit: 2003, Tags: []string{"akhir"}})
+	backdateRevisions(t, book.ID)
+
+	tests := []struct {
+		asOf     string
+		status   int
+		revision int
+		judul    string
+		year     int
+		tags     []string
+	}{
+		{"2023-12-31T23:59:59Z", http.StatusNotFound, 0, "", 0, nil},
+		{"2024-01-01T00:00:00Z", http.StatusOK, 1, "Draft", 2001, []string{"awal"}},
+		{"2024-01-20T10:00:00+07:00", http.StatusOK, 1, "Draft", 2001, []string{"awal"}},
+		{"2024-02-01T00:00:00Z", http.StatusOK, 2, "Second", 2001, []string{"awal"}},
+		// The offset is applied: this is 2024-02-29T23:00:00Z
+		{"2024-03-01T06:00:00+07:00", http.StatusOK, 2, "Second", 2001, []string{"awal"}},
+		{"2030-01-01T00:00:00Z", http.StatusOK, 3, "Second", 2003, []string{"akhir"}},
+	}
+	for _, tt := range tests {
+		var resp struct {
+			Data     models.Book `json:"data"`
+			Revision int         `json:"revision"`
+		}
+		status := srv.do(t, "GET", "/api/books/"+book.ID+"?as_of="+url.QueryEscape(tt.asOf), user, nil, &resp)
+		if status != tt.status {
+			t.Errorf("as_of %s: status %d, want %d", tt.asOf, status, tt.status)
+			continue
+		}
+		if status != http.StatusOK {
+			continue
+		}
+		if resp.Revision != tt.revision || resp.Data.Judul != tt.judul || resp.Data.TahunTerbit != tt.year || !slices.Equal(resp.Data.Tags, tt.tags) {
+			t.Errorf("as_of %s: revision %d %q %d %v, want revision %d %q %d %v", tt.asOf,
+				resp.Revision, resp.Data.Judul, resp.Data.TahunTerbit, resp.Data.Tags, tt.revision, tt.judul, tt.year, tt.tags)
+		}
+	}
+	if status := srv.do(t, "GET", "/api/books/"+book.ID+"?as_of=yesterday", user, nil, nil); status != http.StatusBadRequest {
+		t.Errorf("invalid as_of: status %d", status)
+	}
+
+	// A book from before revisions were kept is only known as it is now
+	legacy := srv.createBook(t, admin, models.CreateBookRequest{Judul: "Legacy", Author: "Penulis", TahunTerbit: 1999})
+	backdateRevisions(t, legacy.ID)
+	if _, err := database.DB.Exec(`DELETE FROM book_revisions WHERE book_id = $1`, legacy.ID); err != nil {
+		t.Fatal(err)
+	}
+	var resp struct {
+		Data     models.Book `json:"data"`
+		Revision *int        `json:"revision"`
+	}
+	if status := srv.do(t, "GET", "/api/books/"+legacy.ID+"?as_of=2024-06-01T00:00:00Z", user, nil, &resp); status != http.StatusOK || resp.Data.Judul != "Legacy" || resp.Revision != nil {
+		t.Errorf("book without revisions: status %d, %q, revision %v", status, resp.Data.Judul, resp.Revision)
+	}
+	if status := srv.do(t, "GET", "/api/books/"+legacy.ID+"?as_of=2023-06-01T00:00:00Z", user, nil, nil); status != http.StatusNotFound {
+		t.Errorf("book without revisions before it was created: status %d", status)
+	}
+}
+
+func TestRevertBook(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.login(t, "admin", "admin123")
+	srv.createCategory(t, admin, models.CreateCategoryRequest{Name: "Fantasy"})
+	book := srv.createBook(t, admin, models.CreateBookRequest{
+		Judul: "Original", Author: "Penulis", TahunTerbit: 2001, ISBN: "9780306406157", Categories: []string{"fantasy"},
+	})
+	srv.updateBook(t, admin, book.ID, models.UpdateBookRequest{Judul: "Changed", ISBN: "9780804429573", Publisher: "Gramedia"})
+
+	var diff struct {
+		Data models.BookRevisionDiff `json:"data"`
+	}
+	srv.do(t, "GET", "/api/books/"+book.ID+"/revisions/diff", admin, nil, &diff)
+	var fields []string
+	for _, c := range diff.Data.Changes {
+		fields = append(fields, c.Field)
+	}
+	if diff.Data.From != 1 || diff.Data.To != 2 || !slices.Equal(fields, []string{"judul", "isbn", "publisher"}) {
+		t.Errorf("diff %d..%d: %v", diff.Data.From, diff.Data.To, fields)
+	}
+
+	var reverted struct {
+		Data     models.Book `json:"data"`
+		Revision int         `json:"revision"`
+	}
+	if status := srv.do(t, "POST", "/api/books/"+book.ID+"/revisions/1/revert", admin, nil, &reverted); status != http.StatusOK {
+		t.Fatalf("revert: status %d", status)
+	}
+	if reverted.Revision != 3 || reverted.Data.Judul != "Original" || reverted.Data.ISBN != "9780306406157" || reverted.Data.Publisher != "" {
+		t.Errorf("reverted to %+v as revision %d", reverted.Data, reverted.Revision)
+	}
+
+	// Reverting adds a revision and keeps the history
+	var revisions struct {
+		Data []models.BookRevision `json:"data"`
+	}
+	srv.do(t, "GET", "/api/books/"+book.ID+"/revisions", admin, nil, &revisions)
+	if len(revisions.Data) != 3 {
+		t.Fatalf("%d revisions", len(revisions.Data))
+	}
+	last := revisions.Data[2]
+	if last.Revision != 3 || last.RevertedFrom != 1 || last.ChangedBy != "admin" || !slices.Equal(last.ChangedFields, []string{"judul", "isbn", "publisher"}) {
+		t.Errorf("revert revision %+v", last)
+	}
+	if revisions.Data[1].Revision != 2 || revisions.Data[1].RevertedFrom != 0 {
+		t.Errorf("revision 2 was changed: %+v", revisions.Data[1])
+	}
+
+	// The ISBN of revision 2 now belongs to another book
+	srv.createBook(t, admin, models.CreateBookRequest{Judul: "Other", Author: "Penulis", TahunTerbit: 2001, ISBN: "080442957X"})
+	if status := srv.do(t, "POST", "/api/books/"+book.ID+"/revisions/2/revert", admin, nil, nil); status != http.StatusConflict {
+		t.Errorf("revert to a taken ISBN: status %d", status)
+	}
+
+	// Revision 3 refers to a category that is gone
+	srv.updateBook(t, admin, book.ID, map[string][]string{"categories": {}})
+	if status := srv.do(t, "DELETE", "/api/categories/fantasy", admin, nil, nil); status != http.StatusOK {
+		t.Fatalf("delete category: status %d", status)
+	}
+	if status := srv.do(t, "POST", "/api/books/"+book.ID+"/revisions/3/revert", admin, nil, nil); status != http.StatusConflict {
+		t.Errorf("revert to a deleted category: status %d", status)
+	}
+
+	for path, want := range map[string]int{
+		"/revisions/99/revert": http.StatusNotFound,
+		"/revisions/0/revert":  http.StatusBadRequest,
+		"/revisions/x/revert":  http.StatusBadRequest,
+	} {
+		if status := srv.do(t, "POST", "/api/books/"+book.ID+path, admin, nil, nil); status != want {
+			t.Errorf("POST %s: status %d, want %d", path, status, want)
+		}
+	}
+	srv.do(t, "GET", "/api/books/"+book.ID+"/revisions", admin, nil, &revisions)
+	if len(revisions.Data) != 4 {
+		t.Errorf("failed reverts added revisions: %d", len(revisions.Data))
+	}
+}
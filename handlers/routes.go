@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// NewRouter registers the REST, GraphQL and health routes. The
+// repositories and services behind them are set up by the Initialize
+// functions; development tools like GraphiQL and the mock identity
+// provider are added by main.
+func NewRouter() *mux.Router {
+	r := mux.NewRouter()
+
+	// API routes
+	api := r.PathPrefix("/api").Subrouter()
+
+	// Auth routes
+	api.HandleFunc("/login", Login).Methods("POST")
+	api.HandleFunc("/login/mfa", LoginMFA).Methods("POST")
+	api.HandleFunc("/login/mfa/enroll", LoginMFAEnroll).Methods("POST")
+	api.HandleFunc("/login/oidc", OIDCLogin).Methods("GET")
+	api.HandleFunc("/login/oidc/callback", OIDCCallback).Methods("GET")
+	api.HandleFunc("/logout", Logout).Methods("POST")
+
+	// Password reset and email verification routes
+	api.HandleFunc("/password/forgot", ForgotPassword).Methods("POST")
+	api.HandleFunc("/password/reset", ResetPassword).Methods("POST")
+	api.HandleFunc("/email/verify/request", RequestEmailVerification).Methods("POST")
+	api.HandleFunc("/email/verify", VerifyEmail).Methods("POST")
+
+	// Two-factor authentication (TOTP) routes
+	api.HandleFunc("/mfa", GetMFAStatus).Methods("GET")
+	api.HandleFunc("/mfa", DisableMFA).Methods("DELETE")
+	api.HandleFunc("/mfa/enroll", EnrollMFA).Methods("POST")
+	api.HandleFunc("/mfa/confirm", ConfirmMFA).Methods("POST")
+	api.HandleFunc("/mfa/recovery-codes", RegenerateRecoveryCodes).Methods("POST")
+	api.HandleFunc("/admin/mfa-policy", GetMFAPolicy).Methods("GET")
+	api.HandleFunc("/admin/mfa-policy", UpdateMFAPolicy).Methods("PUT")
+
+	// Author routes
+	api.HandleFunc("/authors", GetAuthors).Methods("GET")
+	api.HandleFunc("/authors", CreateAuthor).Methods("POST")
+	api.HandleFunc("/authors/{id}", GetAuthor).Methods("GET")
+	api.HandleFunc("/authors/{id}", UpdateAuthor).Methods("PUT")
+	api.HandleFunc("/authors/{id}", DeleteAuthor).Methods("DELETE")
+	api.HandleFunc("/authors/{id}/books", GetAuthorBooks).Methods("GET")
+
+	// Category and tag routes
+	api.HandleFunc("/categories", GetCategories).Methods("GET")
+	api.HandleFunc("/categories", CreateCategory).Methods("POST")
+	api.HandleFunc("/categories/{id}", GetCategory).Methods("GET")
+	api.HandleFunc("/categories/{id}", UpdateCategory).Methods("PUT")
+	api.HandleFunc("/categories/{id}", DeleteCategory).Methods("DELETE")
+	api.HandleFunc("/tags", GetTags).Methods("GET")
+
+	// Book routes
+	api.HandleFunc("/books", GetBooks).Methods("GET")
+	api.HandleFunc("/books", CreateBook).Methods("POST")
+	api.HandleFunc("/books/isbn/{isbn}", GetBookByISBN).Methods("GET")
+	api.HandleFunc("/books/import", ImportBooks).Methods("POST")
+	api.HandleFunc("/books/import/{id}", GetImportJob).Methods("GET")
+	api.HandleFunc("/books/export", ExportBooks).Methods("GET")
+	api.HandleFunc("/books/batch", BatchBooks).Methods("POST")
+	api.HandleFunc("/books/{id}/revisions", GetBookRevisions).Methods("GET")
+	api.HandleFunc("/books/{id}/revisions/diff", DiffBookRevisions).Methods("GET")
+	api.HandleFunc("/books/{id}/revisions/{n}", GetBookRevision).Methods("GET")
+	api.HandleFunc("/books/{id}/revisions/{n}/revert", RevertBook).Methods("POST")
+	api.HandleFunc("/books/{id}", GetBook).Methods("GET")
+	api.HandleFunc("/books/{id}", UpdateBook).Methods("PUT")
+	api.HandleFunc("/books/{id}", DeleteBook).Methods("DELETE")
+	api.HandleFunc("/books/{id}/restore", RestoreBook).Methods("POST")
+
+	// Cover image routes; images are served without login under /covers
+	api.HandleFunc("/books/{id}/cover", UploadBookCover).Methods("PUT")
+	api.HandleFunc("/books/{id}/cover", DeleteBookCover).Methods("DELETE")
+	r.HandleFunc("/covers/{hash}/{name}", ServeCover).Methods("GET")
+
+	// Circulation routes: copies, loans and loan policies
+	api.HandleFunc("/books/{id}/copies", GetBookCopies).Methods("GET")
+	api.HandleFunc("/books/{id}/copies", CreateCopy).Methods("POST")
+	api.HandleFunc("/copies/{id}", GetCopy).Methods("GET")
+	api.HandleFunc("/copies/{id}", UpdateCopy).Methods("PUT")
+	api.HandleFunc("/copies/{id}", DeleteCopy).Methods("DELETE")
+	api.HandleFunc("/loans", GetLoans).Methods("GET")
+	api.HandleFunc("/loans", CheckoutLoan).Methods("POST")
+	api.HandleFunc("/loans/{id}", GetLoan).Methods("GET")
+	api.HandleFunc("/loans/{id}/return", ReturnLoan).Methods("POST")
+	api.HandleFunc("/loans/{id}/renew", RenewLoan).Methods("POST")
+	api.HandleFunc("/loan-policies", GetLoanPolicies).Methods("GET")
+	api.HandleFunc("/loan-policies/{role}", SaveLoanPolicy).Methods("PUT")
+	api.HandleFunc("/loan-policies/{role}", DeleteLoanPolicy).Methods("DELETE")
+
+	// Hold routes
+	api.HandleFunc("/books/{id}/holds", GetBookHolds).Methods("GET")
+	api.HandleFunc("/books/{id}/holds", PlaceHold).Methods("POST")
+	api.HandleFunc("/holds", GetHolds).Methods("GET")
+	api.HandleFunc("/holds/{id}", GetHold).Methods("GET")
+	api.HandleFunc("/holds/{id}", CancelHold).Methods("DELETE")
+
+	// Review routes
+	api.HandleFunc("/books/{id}/reviews", GetBookReviews).Methods("GET")
+	api.HandleFunc("/books/{id}/reviews", CreateReview).Methods("POST")
+	api.HandleFunc("/reviews", GetReviews).Methods("GET")
+	api.HandleFunc("/reviews/{id}", GetReview).Methods("GET")
+	api.HandleFunc("/reviews/{id}", UpdateReview).Methods("PUT")
+	api.HandleFunc("/reviews/{id}", DeleteReview).Methods("DELETE")
+	api.HandleFunc("/reviews/{id}/status", ModerateReview).Methods("PUT")
+
+	// Reading list routes; shared lists are opened by token without login
+	api.HandleFunc("/lists", GetReadingLists).Methods("GET")
+	api.HandleFunc("/lists", CreateReadingList).Methods("POST")
+	api.HandleFunc("/lists/{id}", GetReadingList).Methods("GET")
+	api.HandleFunc("/lists/{id}", UpdateReadingList).Methods("PUT")
+	api.HandleFunc("/lists/{id}", DeleteReadingList).Methods("DELETE")
+	api.HandleFunc("/lists/{id}/items", AddReadingListItem).Methods("POST")
+	api.HandleFunc("/lists/{id}/items/{bookId}", UpdateReadingListItem).Methods("PUT")
+	api.HandleFunc("/lists/{id}/items/{bookId}", RemoveReadingListItem).Methods("DELETE")
+	api.HandleFunc("/lists/{id}/order", ReorderReadingList).Methods("PUT")
+	api.HandleFunc("/shared-lists/{token}", GetSharedReadingList).Methods("GET")
+
+	// Fines and member account ledger routes
+	api.HandleFunc("/account", GetMyAccount).Methods("GET")
+	api.HandleFunc("/users/{id}/account", GetUserAccount).Methods("GET")
+	api.HandleFunc("/users/{id}/account/payments", RecordPayment).Methods("POST")
+	api.HandleFunc("/users/{id}/account/waivers", RecordWaiver).Methods("POST")
+
+	// User administration routes
+	api.HandleFunc("/users", GetUsers).Methods("GET")
+	api.HandleFunc("/users", CreateUser).Methods("POST")
+	api.HandleFunc("/users/{id}/disable", DisableUser).Methods("POST")
+	api.HandleFunc("/users/{id}/password", ResetUserPassword).Methods("PUT")
+	api.HandleFunc("/users/{id}/tokens/revoke", RevokeUserTokens).Methods("POST")
+	api.HandleFunc("/admin/tokens/cleanup", CleanupTokens).Methods("POST")
+	api.HandleFunc("/admin/trash/purge", PurgeTrash).Methods("POST")
+
+	// Audit log route
+	api.HandleFunc("/audit", GetAuditEvents).Methods("GET")
+
+	// Webhook subscription routes
+	api.HandleFunc("/webhooks", GetWebhooks).Methods("GET")
+	api.HandleFunc("/webhooks", CreateWebhook).Methods("POST")
+	api.HandleFunc("/webhooks/{id}", GetWebhook).Methods("GET")
+	api.HandleFunc("/webhooks/{id}", UpdateWebhook).Methods("PUT")
+	api.HandleFunc("/webhooks/{id}", DeleteWebhook).Methods("DELETE")
+	api.HandleFunc("/webhooks/{id}/deliveries", GetWebhookDeliveries).Methods("GET")
+	api.HandleFunc("/webhooks/{id}/deliveries/{delivery_id}", GetWebhookDelivery).Methods("GET")
+	api.HandleFunc("/webhooks/{id}/deliveries/{delivery_id}/replay", ReplayWebhookDelivery).Methods("POST")
+
+	// Live feed routes
+	api.HandleFunc("/events", StreamEvents).Methods("GET")
+	api.HandleFunc("/events/ticket", CreateLiveTicket).Methods("POST")
+	api.HandleFunc("/events/ws", StreamEventsWebSocket).Methods("GET")
+
+	// GraphQL endpoint, set up by InitializeGraphQL
+	r.HandleFunc("/graphql", GraphQL).Methods("GET", "POST")
+
+	// Health check endpoint
+	r.HandleFunc("/health", Health).Methods("GET")
+
+	return r
+}
+
+// Health handles GET /health
+func Health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `{"status": "healthy", "message": "Server is running"}`)
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// writeUserError writes a JSON error response
+func writeUserError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// writeUserRepoError maps a repository error about a user to a response
+func writeUserRepoError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		writeUserError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, repositories.ErrDuplicate):
+		writeUserError(w, http.StatusConflict, "Username is already taken")
+	default:
+		log.Printf("%s: %v", fallback, err)
+		writeUserError(w, http.StatusInternalServerError, fallback)
+	}
+}
+
+// GetUsers handles GET /api/users (admin): every account, including
+// disabled ones, by username
+func GetUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	users, err := userRepo.ListUsers()
+	if err != nil {
+		log.Printf("Failed to list users: %v", err)
+		writeUserError(w, http.StatusInternalServerError, "Failed to retrieve users")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Users retrieved successfully",
+		"data":    users,
+		"count":   len(users),
+	})
+}
+
+// CreateUser handles POST /api/users (admin) with a
+// models.CreateUserRequest. The role is "user" unless set.
+func CreateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req models.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeUserError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	if msg := req.Validate(); msg != "" {
+		writeUserError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	user := models.NewUser(req)
+	if err := userRepo.WithAudit(auditContext(r)).CreateUser(user); err != nil {
+		writeUserRepoError(w, err, "Failed to create user")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "User created successfully",
+		"data":    user,
+	})
+}
+
+// userIDFromPath returns the {id} of the request, or "" after writing a
+// 404 if it is not a UUID
+func userIDFromPath(w http.ResponseWriter, r *http.Request) string {
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		writeUserError(w, http.StatusNotFound, "User not found")
+		return ""
+	}
+	return id
+}
+
+// DisableUser handles POST /api/users/{id}/disable (admin): the account
+// can no longer sign in and its sessions are revoked
+func DisableUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+	id := userIDFromPath(w, r)
+	if id == "" {
+		return
+	}
+	if id == currentUser(r).ID {
+		writeUserError(w, http.StatusBadRequest, "You cannot disable your own account")
+		return
+	}
+
+	if err := userRepo.WithAudit(auditContext(r)).DisableUser(id); err != nil {
+		writeUserRepoError(w, err, "Failed to disable user")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "User disabled successfully",
+	})
+}
+
+// ResetUserPassword handles PUT /api/users/{id}/password (admin) with body
+// {"password": "..."}; the user's sessions are revoked
+func ResetUserPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+	id := userIDFromPath(w, r)
+	if id == "" {
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeUserError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	if msg := models.PasswordError(req.Password); msg != "" {
+		writeUserError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := userRepo.WithAudit(auditContext(r)).SetPassword(id, req.Password); err != nil {
+		writeUserRepoError(w, err, "Failed to reset password")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Password reset successfully",
+	})
+}
+
+// RevokeUserTokens handles POST /api/users/{id}/tokens/revoke (admin):
+// signs the user out everywhere
+func RevokeUserTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+	id := userIDFromPath(w, r)
+	if id == "" {
+		return
+	}
+
+	revoked, err := tokenRepo.WithAudit(auditContext(r)).RevokeUserTokens(id)
+	if err != nil {
+		writeUserRepoError(w, err, "Failed to revoke tokens")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Tokens revoked successfully",
+		"data":    map[string]int{"revoked": revoked},
+	})
+}
+
+// CleanupTokens handles POST /api/admin/tokens/cleanup (admin): deletes
+// the expired session tokens
+func CleanupTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	removed, err := tokenRepo.CleanupExpiredTokens()
+	if err != nil {
+		log.Printf("Failed to clean up tokens: %v", err)
+		writeUserError(w, http.StatusInternalServerError, "Failed to clean up tokens")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Expired tokens removed",
+		"data":    map[string]int64{"removed": removed},
+	})
+}
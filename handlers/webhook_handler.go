@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// defaultDeliveryLimit is the page size of the delivery log without
+	// ?limit=
+	defaultDeliveryLimit = 50
+	// maxDeliveryLimit is the largest page size ?limit= may ask for
+	maxDeliveryLimit = 500
+)
+
+// writeWebhookError writes a JSON error response
+func writeWebhookError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
+
+// writeWebhookRepoError maps webhook repository errors to a response
+func writeWebhookRepoError(w http.ResponseWriter, err error, fallback string) {
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeWebhookError(w, http.StatusNotFound, capitalize(err.Error()))
+		return
+	}
+	log.Printf("%s: %v", fallback, err)
+	writeWebhookError(w, http.StatusInternalServerError, fallback)
+}
+
+// validWebhookEvent reports whether a subscription may filter on event: an
+// event type, a "book.*"-style prefix or "*"
+func validWebhookEvent(event string) bool {
+	if event == "*" || isOneOf(event, models.WebhookEvents) {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(event, ".*")
+	if !ok {
+		return false
+	}
+	for _, e := range models.WebhookEvents {
+		if strings.HasPrefix(e, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyWebhookRequest validates req and copies it onto s, generating a
+// secret when s has none. It returns an error message, or "" if the
+// request is valid.
+func applyWebhookRequest(s *models.WebhookSubscription, req models.WebhookRequest) string {
+	if req.URL != nil {
+		s.URL = strings.TrimSpace(*req.URL)
+	}
+	if req.Events != nil {
+		s.Events = nil
+		for _, event := range req.Events {
+			event = strings.ToLower(strings.TrimSpace(event))
+			if !validWebhookEvent(event) {
+				return "Invalid event " + strconv.Quote(event) + ", expected one of: " +
+					strings.Join(models.WebhookEvents, ", ") + ", a prefix such as book.* or *"
+			}
+			if !isOneOf(event, s.Events) {
+				s.Events = append(s.Events, event)
+			}
+		}
+	}
+	if req.Description != nil {
+		s.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Secret != nil {
+		s.Secret = *req.Secret
+	}
+	if req.Active != nil {
+		s.Active = *req.Active
+	}
+
+	u, err := url.Parse(s.URL)
+	switch {
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(s.URL) > 2048:
+		return "A valid http or https url of at most 2048 characters is required"
+	case len(s.Events) == 0:
+		return "At least one event is required"
+	case len(s.Description) > 500:
+		return "Description must be at most 500 characters"
+	case s.Secret != "" && (len(s.Secret) < 16 || len(s.Secret) > 255):
+		return "Secret must be 16 to 255 characters"
+	}
+
+	if s.Secret == "" {
+		secret, err := newSecretToken()
+		if err != nil {
+			return "Failed to create webhook secret"
+		}
+		s.Secret = "whsec_" + secret
+	}
+	return ""
+}
+
+// GetWebhooks handles GET /api/webhooks (admin): all subscriptions. Secrets
+// are not shown.
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	webhooks, err := webhookRepo.ListWebhooks()
+	if err != nil {
+		writeWebhookRepoError(w, err, "Failed to retrieve webhooks")
+		return
+	}
+	for _, s := range webhooks {
+		s.Secret = ""
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Webhooks retrieved successfully",
+		"data":    webhooks,
+		"count":   len(webhooks),
+	})
+}
+
+// CreateWebhook handles POST /api/webhooks (admin). The response holds the
+// signing secret; it is not shown again.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req models.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeWebhookError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	now := time.Now()
+	s := &models.WebhookSubscription{
+		ID:        uuid.New().String(),
+		Active:    true,
+		CreatedBy: currentUser(r).ID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if msg := applyWebhookRequest(s, req); msg != "" {
+		writeWebhookError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := webhookRepo.WithAudit(auditContext(r)).CreateWebhook(s); err != nil {
+		writeWebhookRepoError(w, err, "Failed to create webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Webhook created successfully",
+		"data":    s,
+	})
+}
+
+// GetWebhook handles GET /api/webhooks/{id} (admin)
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	s, err := webhookRepo.GetWebhook(mux.Vars(r)["id"])
+	if err != nil {
+		writeWebhookRepoError(w, err, "Failed to retrieve webhook")
+		return
+	}
+	s.Secret = ""
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    s,
+	})
+}
+
+// UpdateWebhook handles PUT /api/webhooks/{id} (admin). Setting "active"
+// to true enables a subscription that was disabled after failing; an empty
+// "secret" rotates the secret to a new generated one, which the response
+// shows.
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	s, err := webhookRepo.GetWebhook(mux.Vars(r)["id"])
+	if err != nil {
+		writeWebhookRepoError(w, err, "Failed to retrieve webhook")
+		return
+	}
+
+	var req models.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeWebhookError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	if msg := applyWebhookRequest(s, req); msg != "" {
+		writeWebhookError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := webhookRepo.WithAudit(auditContext(r)).UpdateWebhook(s); err != nil {
+		writeWebhookRepoError(w, err, "Failed to update webhook")
+		return
+	}
+	s, err = webhookRepo.GetWebhook(s.ID)
+	if err != nil {
+		writeWebhookRepoError(w, err, "Failed to retrieve webhook")
+		return
+	}
+	if req.Secret == nil {
+		s.Secret = ""
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Webhook updated successfully",
+		"data":    s,
+	})
+}
+
+// DeleteWebhook handles DELETE /api/webhooks/{id} (admin): removes the
+// subscription and its delivery log
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	if err := webhookRepo.WithAudit(auditContext(r)).DeleteWebhook(mux.Vars(r)["id"]); err != nil {
+		writeWebhookRepoError(w, err, "Failed to delete webhook")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// GetWebhookDeliveries handles GET /api/webhooks/{id}/deliveries (admin):
+// the delivery log, newest first, optionally filtered by ?status=. Pages
+// hold ?limit= deliveries (50 by default); the next page is ?before= the
+// ID of the last delivery, which the response gives as next_before.
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	s, err := webhookRepo.GetWebhook(mux.Vars(r)["id"])
+	if err != nil {
+		writeWebhookRepoError(w, err, "Failed to retrieve webhook")
+		return
+	}
+
+	q := r.URL.Query()
+	status, before, limit := q.Get("status"), q.Get("before"), defaultDeliveryLimit
+	if status != "" && !isOneOf(status, models.DeliveryStatuses) {
+		writeWebhookError(w, http.StatusBadRequest, "Invalid status, expected one of: "+strings.Join(models.DeliveryStatuses, ", "))
+		return
+	}
+	if _, err := uuid.Parse(before); before != "" && err != nil {
+		writeWebhookError(w, http.StatusBadRequest, "Invalid before, expected a UUID")
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveryLimit {
+			writeWebhookError(w, http.StatusBadRequest, "Invalid limit, expected an integer from 1 to "+strconv.Itoa(maxDeliveryLimit))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := webhookRepo.ListDeliveries(s.ID, status, before, limit)
+	if err != nil {
+		writeWebhookRepoError(w, err, "Failed to retrieve deliveries")
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Deliveries retrieved successfully",
+		"data":    deliveries,
+		"count":   len(deliveries),
+	}
+	if len(deliveries) == limit {
+		response["next_before"] = deliveries[len(deliveries)-1].ID
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetWebhookDelivery handles GET /api/webhooks/{id}/deliveries/{delivery_id}
+// (admin)
+func GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	d, err := webhookRepo.GetDelivery(vars["id"], vars["delivery_id"])
+	if err != nil {
+		writeWebhookRepoError(w, err, "Failed to retrieve delivery")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    d,
+	})
+}
+
+// ReplayWebhookDelivery handles POST
+// /api/webhooks/{id}/deliveries/{delivery_id}/replay (admin): sends the
+// event again as a new delivery with the same event ID
+func ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	d, err := webhookRepo.ReplayDelivery(vars["id"], vars["delivery_id"])
+	if err != nil {
+		writeWebhookRepoError(w, err, "Failed to replay delivery")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Delivery queued for replay",
+		"data":    d,
+	})
+}
@@ -0,0 +1,199 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"rest-api-golang/models"
+)
+
+// csvFields lists the book fields a CSV column can map to and the header
+// names recognized for each without an explicit mapping
+var csvFields = map[string][]string{
+	"judul":        {"title", "book_title"},
+	"author":       {"authors", "penulis", "pengarang"},
+	"tahun_terbit": {"year", "tahun", "published_year", "publication_year"},
+	"isbn":         {"isbn13", "isbn_13", "isbn10", "isbn_10"},
+	"publisher":    {"penerbit"},
+	"language":     {"bahasa", "lang"},
+	"pages":        {"halaman", "page_count"},
+	"description":  {"deskripsi", "summary"},
+	"edition":      {"edisi"},
+	"format":       {"binding"},
+	"categories":   {"category", "kategori"},
+	"tags":         {"tag", "subjects"},
+}
+
+// headerKey normalizes a header name for matching: "Published Year"
+// becomes "published_year"
+func headerKey(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+// csvColumns maps each header position to a book field. An explicit
+// mapping of header name to field wins over the built-in names; columns
+// that match nothing are ignored.
+func csvColumns(header []string, mapping map[string]string) ([]string, error) {
+	aliases := map[string]string{}
+	for field, names := range csvFields {
+		aliases[field] = field
+		for _, name := range names {
+			aliases[name] = field
+		}
+	}
+	explicit := map[string]string{}
+	for column, field := range mapping {
+		if _, ok := csvFields[field]; !ok {
+			return nil, fmt.Errorf("mapping for column %q names unknown field %q", column, field)
+		}
+		explicit[headerKey(column)] = field
+	}
+
+	columns := make([]string, len(header))
+	found := map[string]bool{}
+	for i, name := range header {
+		key := headerKey(name)
+		field, ok := explicit[key]
+		if !ok {
+			field = aliases[key]
+		}
+		if field == "" {
+			continue
+		}
+		if found[field] {
+			return nil, fmt.Errorf("more than one column maps to %s", field)
+		}
+		found[field] = true
+		columns[i] = field
+	}
+	for _, required := range []string{"judul", "author", "tahun_terbit"} {
+		if !found[required] {
+			return nil, fmt.Errorf("no column maps to %s", required)
+		}
+	}
+	return columns, nil
+}
+
+// splitList splits a multi-value cell such as "fantasy; humor"
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == '|' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// unescapeFormula takes off the ' a catalog export puts before cells a
+// spreadsheet would otherwise run as formulas
+func unescapeFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// setCSVField stores one cell in the book request
+func setCSVField(book *models.CreateBookRequest, field, value string) error {
+	value = unescapeFormula(strings.TrimSpace(value))
+	if value == "" {
+		return nil
+	}
+	switch field {
+	case "judul":
+		book.Judul = value
+	case "author":
+		book.Author = value
+	case "tahun_terbit", "pages":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number", field)
+		}
+		if field == "pages" {
+			book.Pages = n
+		} else {
+			book.TahunTerbit = n
+		}
+	case "isbn":
+		book.ISBN = value
+	case "publisher":
+		book.Publisher = value
+	case "language":
+		book.Language = value
+	case "description":
+		book.Description = value
+	case "edition":
+		book.Edition = value
+	case "format":
+		book.Format = value
+	case "categories":
+		book.Categories = splitList(value)
+	case "tags":
+		book.Tags = splitList(value)
+	}
+	return nil
+}
+
+// parseCSV reads a CSV file with a header row. The delimiter is a comma,
+// or a semicolon when the header has semicolons but no commas, as in
+// spreadsheets saved with some regional settings. Authors are separated
+// like the author field of POST /api/books; categories and tags by ";" or
+// "|".
+func parseCSV(data []byte, mapping map[string]string) ([]Record, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Contains(firstLine, []byte(";")) && !bytes.Contains(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns, err := csvColumns(header, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				records = append(records, Record{Row: parseErr.StartLine, Err: fmt.Errorf("invalid CSV: %w", parseErr.Err)})
+				continue
+			}
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		record := Record{Row: line}
+		for i, value := range row {
+			if i >= len(columns) || columns[i] == "" {
+				continue
+			}
+			if err := setCSVField(&record.Book, columns[i], value); err != nil && record.Err == nil {
+				record.Err = err
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,86 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"rest-api-golang/models"
+)
+
+func TestParseCSV(t *testing.T) {
+	data := "\xef\xbb\xbfTitle,Penulis,Published Year,ISBN13,Halaman,Kategori,Subjects\n" +
+		"Bumi Manusia,Pramoedya Ananta Toer,1980,9789799731234,535,Fiksi; Sejarah,novel|klasik\n" +
+		"\"Laskar Pelangi, edisi baru\",Andrea Hirata,2005,,,,\n" +
+		"'=Formula,Someone,2020,,,,\n"
+	records, err := Parse(models.ImportFormatCSV, []byte(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{Row: 2, Book: models.CreateBookRequest{
+			Judul: "Bumi Manusia", Author: "Pramoedya Ananta Toer", TahunTerbit: 1980,
+			ISBN: "9789799731234", Pages: 535,
+			Categories: []string{"Fiksi", "Sejarah"}, Tags: []string{"novel", "klasik"},
+		}},
+		{Row: 3, Book: models.CreateBookRequest{Judul: "Laskar Pelangi, edisi baru", Author: "Andrea Hirata", TahunTerbit: 2005}},
+		{Row: 4, Book: models.CreateBookRequest{Judul: "=Formula", Author: "Someone", TahunTerbit: 2020}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got  %+v\nwant %+v", records, want)
+	}
+}
+
+func TestParseCSVSemicolonsAndMapping(t *testing.T) {
+	data := "Nama Buku;Penulis;Tahun;Catatan\n" +
+		"Ronggeng Dukuh Paruk;Ahmad Tohari;1982;dipinjam\n"
+	mapping := map[string]string{"Nama Buku": "judul", "Catatan": "description"}
+	records, err := Parse(models.ImportFormatCSV, []byte(data), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.CreateBookRequest{Judul: "Ronggeng Dukuh Paruk", Author: "Ahmad Tohari", TahunTerbit: 1982, Description: "dipinjam"}
+	if len(records) != 1 || records[0].Err != nil || !reflect.DeepEqual(records[0].Book, want) {
+		t.Errorf("got %+v", records)
+	}
+}
+
+func TestParseCSVRowErrors(t *testing.T) {
+	data := "judul,author,tahun_terbit,pages\n" +
+		"Satu,A,tahun lalu,10\n" +
+		"Dua,B,2001,\"unterminated\n"
+	records, err := Parse(models.ImportFormatCSV, []byte(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("%d records", len(records))
+	}
+	if records[0].Row != 2 || records[0].Err == nil || !strings.Contains(records[0].Err.Error(), "tahun_terbit") {
+		t.Errorf("bad year: %+v", records[0])
+	}
+	if records[0].Book.Pages != 10 {
+		t.Errorf("the rest of a row with an error is still read: %+v", records[0].Book)
+	}
+	if records[1].Row != 3 || records[1].Err == nil || !strings.Contains(records[1].Err.Error(), "invalid CSV") {
+		t.Errorf("broken quote: %+v", records[1])
+	}
+}
+
+func TestParseCSVFileErrors(t *testing.T) {
+	tests := []struct {
+		data    string
+		mapping map[string]string
+		want    string
+	}{
+		{"", nil, "empty"},
+		{"judul,tahun_terbit\nA,2000\n", nil, "no column maps to author"},
+		{"title,judul,author,year\n", nil, "more than one column maps to judul"},
+		{"name,author,year\n", map[string]string{"name": "nama"}, `unknown field "nama"`},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(models.ImportFormatCSV, []byte(tt.data), tt.mapping); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: %v, want %q", tt.data, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package importer parses catalog files for the bulk book import: CSV with
+// a header row, JSON Lines, and MARC 21 records in ISO 2709 (binary) or
+// MARCXML form. Parsing only maps records to book requests; validation and
+// saving are left to the caller.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"rest-api-golang/models"
+)
+
+// Record is one book parsed from an import file. Row is the line number
+// for CSV and JSON Lines and the 1-based record number for MARC. Err is
+// set when the record could not be mapped to a book.
+type Record struct {
+	Row  int
+	Book models.CreateBookRequest
+	Err  error
+}
+
+// Parse reads every record of data in format. mapping renames CSV columns
+// to book fields and is ignored for other formats. An error is returned
+// only when the file as a whole cannot be read; problems with single
+// records are reported in Record.Err.
+func Parse(format string, data []byte, mapping map[string]string) ([]Record, error) {
+	switch format {
+	case models.ImportFormatCSV:
+		return parseCSV(data, mapping)
+	case models.ImportFormatJSONL:
+		return parseJSONL(data)
+	case models.ImportFormatMARC:
+		return parseMARC(data)
+	case models.ImportFormatMARCXML:
+		return parseMARCXML(data)
+	}
+	return nil, fmt.Errorf("unknown import format %q", format)
+}
+
+// DetectFormat guesses the format of an upload from its file name, then
+// its content type, then its first bytes. It returns "" when unsure.
+func DetectFormat(fileName, contentType string, data []byte) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".csv":
+		return models.ImportFormatCSV
+	case ".jsonl", ".ndjson":
+		return models.ImportFormatJSONL
+	case ".mrc", ".marc":
+		return models.ImportFormatMARC
+	case ".xml":
+		return models.ImportFormatMARCXML
+	}
+
+	contentType, _, _ = strings.Cut(contentType, ";")
+	switch strings.TrimSpace(strings.ToLower(contentType)) {
+	case "text/csv":
+		return models.ImportFormatCSV
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return models.ImportFormatJSONL
+	case "application/marc":
+		return models.ImportFormatMARC
+	case "application/marcxml+xml", "application/xml", "text/xml":
+		return models.ImportFormatMARCXML
+	}
+
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, utf8BOM), " \t\r\n")
+	switch {
+	case len(trimmed) == 0:
+		return ""
+	case trimmed[0] == '<':
+		return models.ImportFormatMARCXML
+	case trimmed[0] == '{':
+		return models.ImportFormatJSONL
+	case looksLikeMARC(trimmed):
+		return models.ImportFormatMARC
+	}
+	return ""
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// maxJSONLine bounds a single JSON Lines record
+const maxJSONLine = 1 << 20
+
+func parseJSONL(data []byte) ([]Record, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLine)
+
+	var records []Record
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		record := Record{Row: line}
+		if err := json.Unmarshal(text, &record.Book); err != nil {
+			record.Err = fmt.Errorf("invalid JSON: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read JSON Lines: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,367 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"rest-api-golang/isbn"
+	"rest-api-golang/models"
+
+	"golang.org/x/text/language"
+)
+
+// ISO 2709 delimiters
+const (
+	marcSubfieldDelimiter = 0x1F
+	marcFieldTerminator   = 0x1E
+	marcRecordTerminator  = 0x1D
+)
+
+// marcRecord is a MARC 21 bibliographic record read from either encoding
+type marcRecord struct {
+	leader   string
+	controls map[string]string
+	fields   []marcField
+}
+
+type marcField struct {
+	tag       string
+	ind2      byte
+	subfields []marcSubfield
+}
+
+type marcSubfield struct {
+	code  byte
+	value string
+}
+
+// looksLikeMARC reports whether data starts with an ISO 2709 leader
+func looksLikeMARC(data []byte) bool {
+	if len(data) < 24 || bytes.IndexByte(data, marcRecordTerminator) < 0 {
+		return false
+	}
+	for _, c := range data[:5] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseMARC reads ISO 2709 records. Records are split on the record
+// terminator rather than trusting the length in the leader. Only UTF-8
+// records are supported; MARC-8 records are reported as row errors.
+func parseMARC(data []byte) ([]Record, error) {
+	if !looksLikeMARC(bytes.TrimLeft(data, " \t\r\n")) {
+		return nil, errors.New("file is not MARC 21 (ISO 2709)")
+	}
+
+	var records []Record
+	n := 0
+	for _, raw := range bytes.Split(data, []byte{marcRecordTerminator}) {
+		raw = bytes.TrimLeft(raw, " \t\r\n")
+		if len(raw) == 0 {
+			continue
+		}
+		n++
+		record := Record{Row: n}
+		rec, err := decodeISO2709(raw)
+		if err == nil {
+			record.Book, err = rec.bookRequest()
+		}
+		record.Err = err
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func decodeISO2709(raw []byte) (*marcRecord, error) {
+	if len(raw) < 24 {
+		return nil, errors.New("MARC record is shorter than its leader")
+	}
+	if !utf8.Valid(raw) {
+		return nil, errors.New("MARC record is not UTF-8; MARC-8 records are not supported")
+	}
+	leader := string(raw[:24])
+	base, err := strconv.Atoi(leader[12:17])
+	if err != nil || base < 25 || base > len(raw) {
+		return nil, errors.New("MARC record has an invalid base address")
+	}
+
+	rec := &marcRecord{leader: leader, controls: map[string]string{}}
+	directory := raw[24 : base-1]
+	if len(directory)%12 != 0 {
+		return nil, errors.New("MARC record has an invalid directory")
+	}
+	for i := 0; i < len(directory); i += 12 {
+		entry := directory[i : i+12]
+		tag := string(entry[:3])
+		length, err1 := strconv.Atoi(string(entry[3:7]))
+		start, err2 := strconv.Atoi(string(entry[7:12]))
+		if err1 != nil || err2 != nil || base+start+length > len(raw) {
+			return nil, fmt.Errorf("MARC field %s is out of bounds", tag)
+		}
+		value := bytes.TrimSuffix(raw[base+start:base+start+length], []byte{marcFieldTerminator})
+
+		if tag < "010" {
+			rec.controls[tag] = string(value)
+			continue
+		}
+		field := marcField{tag: tag}
+		if len(value) >= 2 {
+			field.ind2 = value[1]
+			value = value[2:]
+		}
+		for _, sub := range bytes.Split(value, []byte{marcSubfieldDelimiter}) {
+			if len(sub) < 2 {
+				continue
+			}
+			field.subfields = append(field.subfields, marcSubfield{code: sub[0], value: string(sub[1:])})
+		}
+		rec.fields = append(rec.fields, field)
+	}
+	return rec, nil
+}
+
+// MARCXML elements; tags match the MARC 21 slim schema in any namespace
+type xmlMARCRecord struct {
+	Leader   string `xml:"leader"`
+	Controls []struct {
+		Tag   string `xml:"tag,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"controlfield"`
+	Fields []struct {
+		Tag       string `xml:"tag,attr"`
+		Ind2      string `xml:"ind2,attr"`
+		Subfields []struct {
+			Code  string `xml:"code,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"subfield"`
+	} `xml:"datafield"`
+}
+
+// parseMARCXML streams <record> elements from a MARCXML document, which
+// may be a <collection> or a single record
+func parseMARCXML(data []byte) ([]Record, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var records []Record
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid MARCXML: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var x xmlMARCRecord
+		if err := decoder.DecodeElement(&x, &start); err != nil {
+			return nil, fmt.Errorf("invalid MARCXML record %d: %w", len(records)+1, err)
+		}
+		rec := &marcRecord{leader: x.Leader, controls: map[string]string{}}
+		for _, c := range x.Controls {
+			rec.controls[c.Tag] = c.Value
+		}
+		for _, f := range x.Fields {
+			field := marcField{tag: f.Tag}
+			if f.Ind2 != "" {
+				field.ind2 = f.Ind2[0]
+			}
+			for _, s := range f.Subfields {
+				if s.Code != "" {
+					field.subfields = append(field.subfields, marcSubfield{code: s.Code[0], value: s.Value})
+				}
+			}
+			rec.fields = append(rec.fields, field)
+		}
+
+		record := Record{Row: len(records) + 1}
+		record.Book, record.Err = rec.bookRequest()
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("MARCXML file has no records")
+	}
+	return records, nil
+}
+
+// field returns the first field with one of the tags, in tag order
+func (r *marcRecord) field(tags ...string) *marcField {
+	for _, tag := range tags {
+		for i := range r.fields {
+			if r.fields[i].tag == tag {
+				return &r.fields[i]
+			}
+		}
+	}
+	return nil
+}
+
+// sub returns the first subfield with code, or ""
+func (f *marcField) sub(code byte) string {
+	if f == nil {
+		return ""
+	}
+	for _, s := range f.subfields {
+		if s.code == code {
+			return strings.TrimSpace(s.value)
+		}
+	}
+	return ""
+}
+
+// publication returns the 264 field for publication (second indicator 1)
+// or the older 260 field
+func (r *marcRecord) publication() *marcField {
+	for i := range r.fields {
+		if r.fields[i].tag == "264" && r.fields[i].ind2 == '1' {
+			return &r.fields[i]
+		}
+	}
+	return r.field("260", "264")
+}
+
+// trimISBD removes the ISBD punctuation MARC leaves at the end of
+// subfields, such as the " /" before a statement of responsibility
+func trimISBD(s string) string {
+	s = strings.TrimSpace(s)
+	for len(s) > 0 && strings.ContainsRune(" /:;,=", rune(s[len(s)-1])) {
+		s = s[:len(s)-1]
+	}
+	// Keep the period of an abbreviation such as "Jr." or "Ltd." but
+	// drop a final full stop after a word
+	if strings.HasSuffix(s, ".") {
+		word := s[strings.LastIndexAny(s, " ")+1:]
+		if len(word) > 4 {
+			s = strings.TrimSuffix(s, ".")
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// personalName turns the inverted "Pratchett, Terry," of a MARC heading
+// into "Terry Pratchett"
+func personalName(s string) string {
+	s = trimISBD(s)
+	last, first, ok := strings.Cut(s, ", ")
+	if !ok || strings.Contains(first, ",") {
+		return s
+	}
+	return first + " " + last
+}
+
+// authorRole maps the relator term ($e) or code ($4) of a heading
+func authorRole(f *marcField) string {
+	relator := strings.ToLower(f.sub('e') + " " + f.sub('4'))
+	switch {
+	case strings.Contains(relator, "edit") || strings.Contains(relator, "edt"):
+		return models.AuthorRoleEditor
+	case strings.Contains(relator, "transl") || strings.Contains(relator, "trl"):
+		return models.AuthorRoleTranslator
+	}
+	return models.AuthorRoleAuthor
+}
+
+var (
+	// A year may follow a letter, as in "c2005" for a copyright date
+	yearPattern  = regexp.MustCompile(`(?:^|[^0-9])(1[0-9]{3}|20[0-9]{2})(?:[^0-9]|$)`)
+	pagesPattern = regexp.MustCompile(`(\d+)\s*(?:p\b|pages|hlm|halaman)`)
+)
+
+// bookRequest maps the fields of a bibliographic record to a book:
+// 020 ISBN, 041/008 language, 100/110/700/710 authors, 245 title,
+// 250 edition, 260/264 publisher and year, 300 pages, 520 summary and
+// 650 subjects as tags
+func (r *marcRecord) bookRequest() (models.CreateBookRequest, error) {
+	var book models.CreateBookRequest
+
+	title := r.field("245")
+	book.Judul = trimISBD(title.sub('a'))
+	if subtitle := trimISBD(title.sub('b')); subtitle != "" {
+		book.Judul += ": " + subtitle
+	}
+
+	for _, f := range r.fields {
+		f := f
+		switch f.tag {
+		case "100", "700":
+			if name := personalName(f.sub('a')); name != "" {
+				book.Authors = append(book.Authors, models.BookAuthor{Name: name, Role: authorRole(&f)})
+			}
+		case "110", "710":
+			if name := trimISBD(f.sub('a')); name != "" {
+				book.Authors = append(book.Authors, models.BookAuthor{Name: name, Role: authorRole(&f)})
+			}
+		case "020":
+			if book.ISBN != "" {
+				continue
+			}
+			// "0552137030 (pbk.)": the number is the first word
+			if words := strings.Fields(f.sub('a')); len(words) > 0 {
+				if normalized, err := isbn.Normalize(words[0]); err == nil {
+					book.ISBN = normalized
+					book.Format = bindingFormat(f.sub('a') + " " + f.sub('q'))
+				}
+			}
+		case "650":
+			if tag := strings.ToLower(trimISBD(f.sub('a'))); tag != "" && len(tag) <= 50 {
+				book.Tags = append(book.Tags, tag)
+			}
+		}
+	}
+
+	pub := r.publication()
+	book.Publisher = trimISBD(pub.sub('b'))
+	if m := yearPattern.FindStringSubmatch(pub.sub('c')); m != nil {
+		book.TahunTerbit, _ = strconv.Atoi(m[1])
+	} else if fixed := r.controls["008"]; len(fixed) >= 11 {
+		// Date 1 of the fixed-length data elements
+		book.TahunTerbit, _ = strconv.Atoi(fixed[7:11])
+	}
+
+	book.Edition = trimISBD(r.field("250").sub('a'))
+	book.Description = strings.TrimSpace(r.field("520").sub('a'))
+	if m := pagesPattern.FindStringSubmatch(r.field("300").sub('a')); m != nil {
+		book.Pages, _ = strconv.Atoi(m[1])
+	}
+
+	code := r.field("041").sub('a')
+	if fixed := r.controls["008"]; code == "" && len(fixed) >= 38 {
+		code = fixed[35:38]
+	}
+	if code = strings.TrimSpace(code); code != "" && code != "und" && code != "mul" && code != "zxx" {
+		if tag, err := language.Parse(code); err == nil {
+			book.Language = tag.String()
+		}
+	}
+
+	if book.Judul == "" {
+		return book, errors.New("MARC record has no title (245 $a)")
+	}
+	return book, nil
+}
+
+// bindingFormat reads the binding qualifier of an ISBN, such as "pbk."
+func bindingFormat(qualifier string) string {
+	qualifier = strings.ToLower(qualifier)
+	switch {
+	case strings.Contains(qualifier, "pbk") || strings.Contains(qualifier, "paperback"):
+		return "paperback"
+	case strings.Contains(qualifier, "hbk") || strings.Contains(qualifier, "hardcover") || strings.Contains(qualifier, "hardback"):
+		return "hardcover"
+	case strings.Contains(qualifier, "ebook") || strings.Contains(qualifier, "e-book"):
+		return "ebook"
+	}
+	return ""
+}
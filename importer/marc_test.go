@@ -0,0 +1,145 @@
+package importer
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rest-api-golang/models"
+)
+
+// testdata/records.xml holds the Library of Congress MARCXML sample record
+// for Sandburg's "Arithmetic" and an RDA record with 264 and 041 fields;
+// records.mrc is the same two records in ISO 2709
+var wantMARC = []models.CreateBookRequest{
+	{
+		Judul:       "Arithmetic",
+		TahunTerbit: 1993,
+		ISBN:        "9780152038656",
+		Publisher:   "Harcourt Brace Jovanovich",
+		Language:    "en",
+		Description: "A poem about numbers and their characteristics. Features anamorphic, or distorted, drawings which can be restored to normal by viewing from a particular angle or by viewing the image's reflection in the provided Mylar cone.",
+		Edition:     "1st ed.",
+		Authors: []models.BookAuthor{
+			{Name: "Carl Sandburg", Role: models.AuthorRoleAuthor},
+			{Name: "Ted Rand", Role: models.AuthorRoleAuthor},
+		},
+		Tags: []string{"arithmetic", "children's poetry, american", "visual perception"},
+	},
+	{
+		Judul:       "Guards! Guards!: a Discworld novel",
+		TahunTerbit: 2014,
+		ISBN:        "9780552166669",
+		Publisher:   "Corgi Books",
+		Language:    "id",
+		Pages:       413,
+		Format:      "paperback",
+		Authors: []models.BookAuthor{
+			{Name: "Terry Pratchett", Role: models.AuthorRoleAuthor},
+			{Name: "Budi Santoso", Role: models.AuthorRoleTranslator},
+		},
+		Tags: []string{"dragons"},
+	},
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseMARC(t *testing.T) {
+	for _, tt := range []struct{ file, format string }{
+		{"records.mrc", models.ImportFormatMARC},
+		{"records.xml", models.ImportFormatMARCXML},
+	} {
+		data := readTestdata(t, tt.file)
+		if format := DetectFormat("", "", data); format != tt.format {
+			t.Errorf("%s: detected %q", tt.file, format)
+		}
+		records, err := Parse(tt.format, data, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if len(records) != len(wantMARC) {
+			t.Fatalf("%s: %d records", tt.file, len(records))
+		}
+		for i, record := range records {
+			if record.Row != i+1 || record.Err != nil {
+				t.Errorf("%s record %d: row %d, %v", tt.file, i+1, record.Row, record.Err)
+			}
+			if !reflect.DeepEqual(record.Book, wantMARC[i]) {
+				t.Errorf("%s record %d:\n got %+v\nwant %+v", tt.file, i+1, record.Book, wantMARC[i])
+			}
+		}
+	}
+}
+
+func TestParseMARCRecordErrors(t *testing.T) {
+	data := readTestdata(t, "records.mrc")
+	first := bytes.IndexByte(data, marcRecordTerminator) + 1
+
+	// MARC-8 writes the acute accent as the byte 0xE2 before the letter
+	marc8 := bytes.Replace(data[:first], []byte("Sandburg"), []byte("Sandb\xe2urg"), 1)
+	// Without 245 the record still parses but cannot become a book
+	untitled := bytes.Replace(data[first:], []byte("245"), []byte("246"), 1)
+
+	records, err := parseMARC(append(marc8, untitled...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("%d records", len(records))
+	}
+	if err := records[0].Err; err == nil || !strings.Contains(err.Error(), "MARC-8") {
+		t.Errorf("MARC-8 record: %v", err)
+	}
+	if err := records[1].Err; err == nil || !strings.Contains(err.Error(), "245") {
+		t.Errorf("record without a title: %v", err)
+	}
+	if records[1].Book.ISBN != "9780552166669" {
+		t.Errorf("record without a title lost its other fields: %+v", records[1].Book)
+	}
+
+	corrupt := append([]byte{}, data[:first]...)
+	copy(corrupt[24+3:], "9999")
+	if records, _ := parseMARC(corrupt); len(records) != 1 || records[0].Err == nil {
+		t.Errorf("out of bounds directory entry: %+v", records)
+	}
+}
+
+func TestParseMARCFileErrors(t *testing.T) {
+	tests := []struct {
+		format, data, want string
+	}{
+		{models.ImportFormatMARC, "judul,author,tahun_terbit\n", "not MARC 21"},
+		{models.ImportFormatMARCXML, `<collection xmlns="http://www.loc.gov/MARC21/slim"></collection>`, "no records"},
+		{models.ImportFormatMARCXML, `<collection><record><leader>`, "invalid MARCXML"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.format, []byte(tt.data), nil); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %q: %v, want %q", tt.format, tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestTrimISBD(t *testing.T) {
+	tests := map[string]string{
+		"Arithmetic /":                 "Arithmetic",
+		"Harcourt Brace Jovanovich,":   "Harcourt Brace Jovanovich",
+		"Children's poetry, American.": "Children's poetry, American",
+		"1st ed.":                      "1st ed.",
+		"Smith, John, Jr.":             "Smith, John, Jr.",
+		" = ":                          "",
+	}
+	for in, want := range tests {
+		if got := trimISBD(in); got != want {
+			t.Errorf("trimISBD(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Package testdb gives tests their own PostgreSQL schema. Tests that need
+// a database call Open, which skips them unless TEST_DATABASE_URL names a
+// database to create schemas in, for example
+//
+//	TEST_DATABASE_URL="host=localhost user=postgres password=password dbname=bookdb_test sslmode=disable" go test ./...
+package testdb
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"rest-api-golang/database"
+
+	_ "github.com/lib/pq"
+)
+
+// Open creates a fresh schema, points database.DB at it, creates the
+// tables and seeds the default users. The schema is dropped when the test
+// ends.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	schema := "test_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+
+	db, err := sql.Open("postgres", withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatalf("open test schema: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		db.Close()
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Logf("drop schema %s: %v", schema, err)
+		}
+		admin.Close()
+	})
+
+	if err := database.CreateTables(); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	if err := database.SeedData(); err != nil {
+		t.Fatalf("seed data: %v", err)
+	}
+	return db
+}
+
+// withSearchPath adds search_path to a URL or key=value connection
+// string; lib/pq sends settings it does not know to the server
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
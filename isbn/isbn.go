@@ -0,0 +1,119 @@
+// Package isbn validates and normalizes ISBN-10 and ISBN-13 numbers
+package isbn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Clean strips hyphens and spaces and upper-cases a trailing x
+func Clean(s string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == 'x' || r == 'X':
+			b.WriteRune('X')
+		case r == '-' || r == ' ':
+			// separators are ignored
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ValidISBN10 checks length, characters and the mod 11 check digit
+func ValidISBN10(s string) bool {
+	if len(s) != 10 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 10; i++ {
+		c := s[i]
+		var v int
+		switch {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c == 'X' && i == 9:
+			v = 10
+		default:
+			return false
+		}
+		sum += v * (10 - i)
+	}
+	return sum%11 == 0
+}
+
+// ValidISBN13 checks length, prefix, characters and the mod 10 check digit
+func ValidISBN13(s string) bool {
+	if len(s) != 13 || !(strings.HasPrefix(s, "978") || strings.HasPrefix(s, "979")) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 13; i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		v := int(c - '0')
+		if i%2 == 1 {
+			v *= 3
+		}
+		sum += v
+	}
+	return sum%10 == 0
+}
+
+// Normalize validates an ISBN-10 or ISBN-13 in any common notation and
+// returns it as an unhyphenated ISBN-13, so both forms of the same book
+// compare equal
+func Normalize(s string) (string, error) {
+	clean := Clean(s)
+	switch len(clean) {
+	case 10:
+		if !ValidISBN10(clean) {
+			return "", fmt.Errorf("invalid ISBN-10 check digit")
+		}
+		return ToISBN13(clean), nil
+	case 13:
+		if !ValidISBN13(clean) {
+			return "", fmt.Errorf("invalid ISBN-13 check digit")
+		}
+		return clean, nil
+	}
+	return "", fmt.Errorf("ISBN must have 10 or 13 digits")
+}
+
+// ToISBN13 converts a valid ISBN-10 to ISBN-13 with the 978 prefix
+func ToISBN13(isbn10 string) string {
+	body := "978" + isbn10[:9]
+	sum := 0
+	for i := 0; i < 12; i++ {
+		v := int(body[i] - '0')
+		if i%2 == 1 {
+			v *= 3
+		}
+		sum += v
+	}
+	return body + string(rune('0'+(10-sum%10)%10))
+}
+
+// ToISBN10 converts an ISBN-13 with the 978 prefix back to ISBN-10. It
+// returns false for 979 numbers, which have no ISBN-10 form.
+func ToISBN10(isbn13 string) (string, bool) {
+	if len(isbn13) != 13 || !strings.HasPrefix(isbn13, "978") {
+		return "", false
+	}
+	body := isbn13[3:12]
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X", true
+	}
+	return body + string(rune('0'+check)), true
+}
@@ -0,0 +1,78 @@
+package isbn
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"9780306406157", "9780306406157", true},
+		{"978-0-306-40615-7", "9780306406157", true},
+		{"978 0 306 40615 7", "9780306406157", true},
+		{"0306406152", "9780306406157", true},
+		{"0-306-40615-2", "9780306406157", true},
+		{" 0-306-40615-2 ", "9780306406157", true},
+		{"080442957X", "9780804429573", true},
+		{"0-8044-2957-x", "9780804429573", true},
+		{"979-10-90636-07-1", "9791090636071", true},
+		// Wrong check digits
+		{"9780306406158", "", false},
+		{"0306406153", "", false},
+		{"0804429570", "", false},
+		// X is only a check digit, and only in ISBN-10
+		{"08044X9575", "", false},
+		{"978030640615X", "", false},
+		// Wrong length, prefix or characters
+		{"", "", false},
+		{"030640615", "", false},
+		{"97803064061570", "", false},
+		{"9770306406157", "", false},
+		{"0306406I52", "", false},
+		{"0.306.40615.2", "", false},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestConversion(t *testing.T) {
+	tests := []struct{ isbn10, isbn13 string }{
+		{"0306406152", "9780306406157"},
+		{"080442957X", "9780804429573"},
+		{"0152038655", "9780152038656"},
+		{"0552134627", "9780552134620"},
+	}
+	for _, tt := range tests {
+		if got := ToISBN13(tt.isbn10); got != tt.isbn13 {
+			t.Errorf("ToISBN13(%s) = %s, want %s", tt.isbn10, got, tt.isbn13)
+		}
+		if got, ok := ToISBN10(tt.isbn13); !ok || got != tt.isbn10 {
+			t.Errorf("ToISBN10(%s) = %s, %v, want %s", tt.isbn13, got, ok, tt.isbn10)
+		}
+		if !ValidISBN10(tt.isbn10) || !ValidISBN13(tt.isbn13) {
+			t.Errorf("%s or %s is not valid", tt.isbn10, tt.isbn13)
+		}
+	}
+
+	// 979 numbers have no ISBN-10 form
+	if got, ok := ToISBN10("9791090636071"); ok {
+		t.Errorf("ToISBN10 of a 979 number = %s", got)
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := map[string]string{
+		"978-0-306-40615-7": "9780306406157",
+		" 0 8044 2957 x ":   "080442957X",
+		"ISBN 0306406152":   "ISBN0306406152",
+	}
+	for in, want := range tests {
+		if got := Clean(in); got != want {
+			t.Errorf("Clean(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
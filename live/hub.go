@@ -0,0 +1,162 @@
+// Package live pushes change events to connected clients. Events come
+// from the outbox table: PostgreSQL LISTEN/NOTIFY tells every API process
+// of each committed event, and each process fans it out to its own
+// subscribers. Clients that reconnect are replayed what they missed from
+// the outbox.
+package live
+
+import (
+	"sync"
+
+	"rest-api-golang/models"
+)
+
+// Store reads events from the outbox, implemented by the outbox repository
+type Store interface {
+	GetOutboxEvent(sequence int64) (*models.OutboxEvent, error)
+	ListOutboxEvents(aggregateType string, after int64, limit int) ([]*models.OutboxEvent, error)
+}
+
+// Filter selects the events a subscriber receives; zero values match all
+type Filter struct {
+	Types       []string
+	AggregateID string
+}
+
+// Match reports whether e passes the filter
+func (f Filter) Match(e *models.OutboxEvent) bool {
+	if f.AggregateID != "" && e.AggregateID != f.AggregateID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription receives the events of a hub that match its filter
+type Subscription struct {
+	filter Filter
+	events chan *models.OutboxEvent
+	lagged chan struct{}
+}
+
+// Events is the channel matching events arrive on
+func (s *Subscription) Events() <-chan *models.OutboxEvent {
+	return s.events
+}
+
+// Lagged is closed when the subscriber fell so far behind that its buffer
+// filled up. It receives no further events and should reconnect, resuming
+// from the last event it handled.
+func (s *Subscription) Lagged() <-chan struct{} {
+	return s.lagged
+}
+
+// Hub fans the events of one aggregate type out to subscribers
+type Hub struct {
+	mu        sync.Mutex
+	subs      map[*Subscription]struct{}
+	store     Store
+	aggregate string
+	buffer    int
+}
+
+// NewHub creates a hub for events of aggregateType whose subscribers each
+// buffer up to buffer events
+func NewHub(store Store, aggregateType string, buffer int) *Hub {
+	return &Hub{
+		subs:      map[*Subscription]struct{}{},
+		store:     store,
+		aggregate: aggregateType,
+		buffer:    buffer,
+	}
+}
+
+// Subscribe starts delivering events matching f
+func (h *Hub) Subscribe(f Filter) *Subscription {
+	s := &Subscription{
+		filter: f,
+		events: make(chan *models.OutboxEvent, h.buffer),
+		lagged: make(chan struct{}),
+	}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Unsubscribe stops delivering events to s
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	delete(h.subs, s)
+	h.mu.Unlock()
+}
+
+// Clients returns the number of subscribers
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// Broadcast delivers e to every matching subscriber without waiting. A
+// subscriber whose buffer is full is dropped rather than holding up the
+// others.
+func (h *Hub) Broadcast(e *models.OutboxEvent) {
+	if e.AggregateType != h.aggregate {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			delete(h.subs, s)
+			close(s.lagged)
+		}
+	}
+}
+
+// Replay returns up to limit events matching f that followed the event
+// with sequence number after, including ones with a lower sequence number
+// that committed after it; the client may have seen some of those, and
+// skips them by sequence number. complete is false when more events were
+// missed than the limit, or when some were already purged from the
+// outbox; the client should then reload its data instead.
+func (h *Hub) Replay(after int64, f Filter, limit int) ([]*models.OutboxEvent, bool, error) {
+	events, err := h.store.ListOutboxEvents(h.aggregate, after, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	complete := len(events) < limit
+	// Events that committed late come first, with lower sequence numbers
+	next := 0
+	for next < len(events) && events[next].Sequence < after {
+		next++
+	}
+	if next < len(events) && events[next].Sequence > after+1 {
+		// A gap right after the last event seen may be events of other
+		// aggregates, or ones that were purged; only the latter matter
+		if _, err := h.store.GetOutboxEvent(after); err != nil {
+			complete = false
+		}
+	}
+
+	matched := events[:0]
+	for _, e := range events {
+		if f.Match(e) {
+			matched = append(matched, e)
+		}
+	}
+	return matched, complete, nil
+}
@@ -0,0 +1,103 @@
+package live
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"rest-api-golang/models"
+)
+
+// fakeStore holds outbox events by sequence number and returns those
+// listed in after for ListOutboxEvents
+type fakeStore struct {
+	events map[int64]*models.OutboxEvent
+	after  map[int64][]int64
+}
+
+func (s *fakeStore) GetOutboxEvent(sequence int64) (*models.OutboxEvent, error) {
+	if e, ok := s.events[sequence]; ok {
+		return e, nil
+	}
+	return nil, errors.New("event not found")
+}
+
+func (s *fakeStore) ListOutboxEvents(aggregateType string, after int64, limit int) ([]*models.OutboxEvent, error) {
+	var events []*models.OutboxEvent
+	for _, sequence := range s.after[after] {
+		if len(events) < limit {
+			events = append(events, s.events[sequence])
+		}
+	}
+	return events, nil
+}
+
+func newFakeStore(sequences ...int64) *fakeStore {
+	s := &fakeStore{events: map[int64]*models.OutboxEvent{}, after: map[int64][]int64{}}
+	for _, n := range sequences {
+		s.events[n] = &models.OutboxEvent{Sequence: n, AggregateType: "book", Type: "book.updated"}
+	}
+	return s
+}
+
+func sequences(events []*models.OutboxEvent) []int64 {
+	list := []int64{}
+	for _, e := range events {
+		list = append(list, e.Sequence)
+	}
+	return list
+}
+
+func TestReplay(t *testing.T) {
+	store := newFakeStore(3, 5, 8, 9, 10)
+	hub := NewHub(store, "book", 10)
+	tests := []struct {
+		name     string
+		after    int64
+		listed   []int64
+		want     []int64
+		complete bool
+	}{
+		{"nothing missed", 9, nil, []int64{}, true},
+		// 3 committed after 5 was written
+		{"late commit", 5, []int64{3, 8, 9}, []int64{3, 8, 9}, true},
+		{"only late commits", 9, []int64{3}, []int64{3}, true},
+		// 6 and 7 belong to other aggregates
+		{"gap of other events", 5, []int64{8}, []int64{8}, true},
+		{"purged", 4, []int64{8, 9}, nil, false},
+		{"late commit before purged", 4, []int64{3, 8, 9}, nil, false},
+		{"over the limit", 3, []int64{5, 8, 9, 10}, nil, false},
+	}
+	for _, tt := range tests {
+		store.after[tt.after] = tt.listed
+		events, complete, err := hub.Replay(tt.after, Filter{}, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if complete != tt.complete {
+			t.Errorf("%s: complete = %v", tt.name, complete)
+		}
+		if tt.want != nil && !slices.Equal(sequences(events), tt.want) {
+			t.Errorf("%s: replayed %v, want %v", tt.name, sequences(events), tt.want)
+		}
+	}
+}
+
+func TestCatchUpKeepsHighestSequence(t *testing.T) {
+	store := newFakeStore(3, 5, 8)
+	store.after[5] = []int64{3, 8}
+	store.after[8] = []int64{3}
+	hub := NewHub(store, "book", 10)
+	sub := hub.Subscribe(Filter{})
+
+	if last := hub.catchUp(5); last != 8 {
+		t.Errorf("catchUp(5) = %d, want 8", last)
+	}
+	// A late commit seen again does not move the position back
+	if last := hub.catchUp(8); last != 8 {
+		t.Errorf("catchUp(8) = %d, want 8", last)
+	}
+	if got := len(sub.Events()); got != 3 {
+		t.Errorf("broadcast %d events, want 3", got)
+	}
+}
@@ -0,0 +1,97 @@
+package live
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// NotifyChannel is the PostgreSQL channel the outbox trigger notifies with
+// "<sequence>:<event type>" for every event
+const NotifyChannel = "outbox_events"
+
+// catchUpLimit bounds the events read after the listener reconnects
+const catchUpLimit = 1000
+
+// Listen broadcasts the hub's events as PostgreSQL announces them, until
+// ctx is done. dsn is the database connection string; the listener keeps
+// its own connection and reconnects by itself. Events committed while it
+// was disconnected are read from the outbox once it is back.
+func (h *Hub) Listen(ctx context.Context, dsn string) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Live feed listener: %v", err)
+		}
+	})
+	if err := listener.Listen(NotifyChannel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		defer listener.Close()
+		ping := time.NewTicker(time.Minute)
+		defer ping.Stop()
+		var last int64
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-listener.Notify:
+				if n == nil {
+					// The connection was re-established and notifications
+					// sent meanwhile are lost
+					last = h.catchUp(last)
+					continue
+				}
+				last = max(last, h.notified(n.Extra))
+			case <-ping.C:
+				// Notice a dead connection even when nothing happens
+				if err := listener.Ping(); err != nil {
+					log.Printf("Live feed listener ping failed: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// notified broadcasts the event of a notification and returns its
+// sequence number, or 0 when it is not for this hub
+func (h *Hub) notified(payload string) int64 {
+	id, eventType, _ := strings.Cut(payload, ":")
+	sequence, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || !strings.HasPrefix(eventType, h.aggregate+".") {
+		return 0
+	}
+	e, err := h.store.GetOutboxEvent(sequence)
+	if err != nil {
+		log.Printf("Live feed failed to load event %d: %v", sequence, err)
+		return 0
+	}
+	h.Broadcast(e)
+	return sequence
+}
+
+// catchUp broadcasts the events that committed after the event last and
+// returns the new last sequence number. Before any event was seen there is
+// nothing to catch up.
+func (h *Hub) catchUp(last int64) int64 {
+	if last == 0 {
+		return 0
+	}
+	events, err := h.store.ListOutboxEvents(h.aggregate, last, catchUpLimit)
+	if err != nil {
+		log.Printf("Live feed failed to catch up: %v", err)
+		return last
+	}
+	for _, e := range events {
+		h.Broadcast(e)
+		last = max(last, e.Sequence)
+	}
+	return last
+}
@@ -0,0 +1,138 @@
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxClientMessage bounds messages read from clients, which only need to
+// send control frames
+const maxClientMessage = 64 << 10
+
+// WebSocket close codes
+const (
+	CloseNormal        = websocket.CloseNormalClosure
+	CloseGoingAway     = websocket.CloseGoingAway
+	CloseProtocolError = websocket.CloseProtocolError
+	CloseTooBig        = websocket.CloseMessageTooBig
+	CloseTryAgainLater = websocket.CloseTryAgainLater
+)
+
+// ErrClosed is returned by ReadLoop when the client closed the connection
+var ErrClosed = errors.New("websocket closed")
+
+// Conn is a server side WebSocket connection for pushing text messages.
+// Writes are safe for concurrent use; one goroutine should run ReadLoop to
+// answer pings and notice when the client goes away.
+type Conn struct {
+	ws        *websocket.Conn
+	mu        sync.Mutex
+	closeOnce sync.Once
+}
+
+// Upgrader completes WebSocket handshakes for the live feed
+type Upgrader struct {
+	upgrader websocket.Upgrader
+}
+
+// NewUpgrader creates an upgrader accepting browsers on the API's own
+// origin and on allowedOrigins, such as "https://app.example.com"; "*"
+// allows every origin. Clients that send no Origin header, which browsers
+// always do, are accepted. Checking the origin keeps other sites from
+// opening the feed with a visitor's credentials.
+func NewUpgrader(allowedOrigins []string) *Upgrader {
+	allowed := map[string]bool{}
+	for _, origin := range allowedOrigins {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+			allowed[strings.ToLower(origin)] = true
+		}
+	}
+	u := &Upgrader{}
+	u.upgrader = websocket.Upgrader{
+		HandshakeTimeout: 10 * time.Second,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" || allowed["*"] || allowed[strings.ToLower(origin)] {
+				return true
+			}
+			o, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(o.Host, r.Host)
+		},
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": reason.Error(),
+			})
+		},
+	}
+	return u
+}
+
+// Upgrade completes the WebSocket handshake of r and takes over its
+// connection. On error a JSON error response has been written to w.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	ws, err := u.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	ws.SetReadLimit(maxClientMessage)
+	return &Conn{ws: ws}, nil
+}
+
+// WriteText sends a text message, failing if the client does not take it
+// within timeout
+func (c *Conn) WriteText(data []byte, timeout time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(timeout))
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// Ping sends a ping; the client answers with a pong
+func (c *Conn) Ping(timeout time.Duration) error {
+	return c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(timeout))
+}
+
+// Close sends a close frame with code and reason and closes the
+// connection
+func (c *Conn) Close(code int, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+		err = c.ws.Close()
+	})
+	return err
+}
+
+// ReadLoop reads from the client until it closes the connection or an
+// error occurs; pings are answered as they arrive. Data messages are
+// ignored. It returns ErrClosed after a clean close.
+func (c *Conn) ReadLoop() error {
+	for {
+		// Messages are read to the end, which enforces the read limit
+		_, r, err := c.ws.NextReader()
+		if err == nil {
+			_, err = io.Copy(io.Discard, r)
+		}
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				return ErrClosed
+			}
+			if errors.Is(err, websocket.ErrReadLimit) {
+				c.Close(CloseTooBig, "message too large")
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package live
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsServer upgrades every request with u and hands the connection to serve
+func wsServer(t *testing.T, u *Upgrader, serve func(*Conn)) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := u.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		serve(conn)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dial(t *testing.T, url, origin string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	return websocket.DefaultDialer.Dial(url, header)
+}
+
+func TestUpgraderChecksOrigin(t *testing.T) {
+	u := NewUpgrader([]string{" https://App.example.com/ ", ""})
+	url := wsServer(t, u, func(c *Conn) { c.Close(CloseNormal, "") })
+	self := "http" + strings.TrimPrefix(url, "ws")
+
+	for _, origin := range []string{"", self, "https://app.example.com"} {
+		conn, _, err := dial(t, url, origin)
+		if err != nil {
+			t.Errorf("origin %q refused: %v", origin, err)
+			continue
+		}
+		conn.Close()
+	}
+	for _, origin := range []string{"https://evil.example.com", "https://app.example.com.evil.com", "null"} {
+		_, resp, err := dial(t, url, origin)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Errorf("origin %q: %v, %v", origin, resp, err)
+		}
+	}
+
+	anyOrigin := wsServer(t, NewUpgrader([]string{"*"}), func(c *Conn) { c.Close(CloseNormal, "") })
+	conn, _, err := dial(t, anyOrigin, "https://evil.example.com")
+	if err != nil {
+		t.Fatalf("* refused an origin: %v", err)
+	}
+	conn.Close()
+}
+
+func TestUpgradeRejectsPlainRequest(t *testing.T) {
+	url := wsServer(t, NewUpgrader(nil), func(c *Conn) { t.Error("plain request was upgraded") })
+	resp, err := http.Get("http" + strings.TrimPrefix(url, "ws"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestConnMessages(t *testing.T) {
+	closed := make(chan error, 1)
+	url := wsServer(t, NewUpgrader(nil), func(c *Conn) {
+		for _, msg := range []string{"first", strings.Repeat("x", 70000)} {
+			if err := c.WriteText([]byte(msg), time.Second); err != nil {
+				t.Error(err)
+			}
+		}
+		if err := c.Ping(time.Second); err != nil {
+			t.Error(err)
+		}
+		closed <- c.ReadLoop()
+	})
+
+	conn, _, err := dial(t, url, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		pinged <- struct{}{}
+		return nil
+	})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []int{5, 70000} {
+		kind, data, err := conn.ReadMessage()
+		if err != nil || kind != websocket.TextMessage || len(data) != want {
+			t.Fatalf("read %d: kind %d, %d bytes, %v", want, kind, len(data), err)
+		}
+	}
+
+	// Pings are read along with messages; the client's own pings are
+	// answered by the server's read loop
+	pongs := make(chan struct{}, 1)
+	conn.SetPongHandler(func(string) error {
+		pongs <- struct{}{}
+		return nil
+	})
+	if err := conn.WriteControl(websocket.PingMessage, []byte("hi"), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	go conn.ReadMessage()
+	for _, ch := range []chan struct{}{pinged, pongs} {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no ping or pong")
+		}
+	}
+
+	// A clean close from the client ends the read loop with ErrClosed
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"), time.Now().Add(time.Second))
+	select {
+	case err := <-closed:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("ReadLoop = %v, want ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read loop did not end")
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	url := wsServer(t, NewUpgrader(nil), func(c *Conn) {
+		c.Close(CloseTryAgainLater, "server busy")
+		// Closing twice sends one close frame
+		c.Close(CloseNormal, "")
+	})
+	conn, _, err := dial(t, url, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseTryAgainLater || closeErr.Text != "server busy" {
+		t.Errorf("read after close = %v", err)
+	}
+}
+
+func TestConnReadLimit(t *testing.T) {
+	result := make(chan error, 1)
+	url := wsServer(t, NewUpgrader(nil), func(c *Conn) { result <- c.ReadLoop() })
+	conn, _, err := dial(t, url, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("small messages are ignored")); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, make([]byte, maxClientMessage+1)); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseTooBig {
+		t.Errorf("read after a large message = %v", err)
+	}
+	if err := <-result; !errors.Is(err, websocket.ErrReadLimit) {
+		t.Errorf("ReadLoop = %v", err)
+	}
+}
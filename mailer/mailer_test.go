@@ -0,0 +1,192 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testMessage() *Message {
+	return &Message{
+		From:     "Book API <no-reply@example.com>",
+		To:       []string{"Reader <reader@example.com>"},
+		Subject:  "Reset your password",
+		TextBody: "Open http://localhost:8080/reset-password?token=abc",
+		HTMLBody: `<p><a href="http://localhost:8080/reset-password?token=abc">Reset</a></p>`,
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	if m.Last() != nil {
+		t.Fatal("new outbox is not empty")
+	}
+
+	msg := testMessage()
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	// The outbox keeps a copy, not the caller's message
+	msg.To[0] = "someone-else@example.com"
+	msg.Subject = "changed"
+	if last := m.Last(); last.Subject != "Reset your password" || last.To[0] != "Reader <reader@example.com>" {
+		t.Errorf("stored message changed with the original: %+v", last)
+	}
+
+	if err := m.Send(context.Background(), &Message{From: "no-reply@example.com", To: []string{"not an address"}}); err == nil {
+		t.Error("message with an invalid recipient was accepted")
+	}
+	if err := m.Send(context.Background(), &Message{From: "no-reply@example.com"}); err == nil {
+		t.Error("message without recipients was accepted")
+	}
+	if n := len(m.Messages()); n != 1 {
+		t.Errorf("outbox has %d messages, want 1", n)
+	}
+
+	m.Reset()
+	if len(m.Messages()) != 0 || m.Last() != nil {
+		t.Error("Reset did not empty the outbox")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m, err := NewFileMailer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.Send(context.Background(), testMessage()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("wrote %d files, want one per message", len(files))
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	parsed, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("file is not a valid message: %v", err)
+	}
+	if got := parsed.Header.Get("Subject"); got != "Reset your password" {
+		t.Errorf("Subject = %q", got)
+	}
+	if to, err := parsed.Header.AddressList("To"); err != nil || len(to) != 1 || to[0].Address != "reader@example.com" {
+		t.Errorf("To = %v, %v", to, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q", parsed.Header.Get("Content-Type"))
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	var types []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, part.Header.Get("Content-Type"))
+		// multipart.Reader decodes quoted-printable parts itself
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), "reset-password?token=abc") {
+			t.Errorf("%s part lacks the link: %q", part.Header.Get("Content-Type"), body)
+		}
+	}
+	if strings.Join(types, ",") != "text/plain; charset=utf-8,text/html; charset=utf-8" {
+		t.Errorf("parts = %v", types)
+	}
+}
+
+func TestTextOnlyMessage(t *testing.T) {
+	msg := testMessage()
+	msg.HTMLBody = ""
+	msg.TextBody = strings.Repeat("a long line that needs soft line breaks ", 5)
+	data, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != msg.TextBody {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestRender(t *testing.T) {
+	msg, err := Render("password_reset", map[string]interface{}{
+		"Username":  "<reader>",
+		"Email":     "reader@example.com",
+		"URL":       "http://localhost:8080/reset-password?token=abc",
+		"ExpiresIn": "1 hour",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Reset your Book API password" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	if !strings.Contains(msg.TextBody, "Hi <reader>,") || !strings.Contains(msg.TextBody, "token=abc") || !strings.Contains(msg.TextBody, "1 hour") {
+		t.Errorf("text body = %q", msg.TextBody)
+	}
+	if !strings.Contains(msg.HTMLBody, "&lt;reader&gt;") || strings.Contains(msg.HTMLBody, "<reader>") {
+		t.Errorf("HTML body does not escape the username: %q", msg.HTMLBody)
+	}
+
+	if _, err := Render("no_such_template", nil); err == nil {
+		t.Error("unknown template rendered")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for driver, want := range map[string]string{
+		"smtp":   "*mailer.SMTPMailer",
+		"file":   "*mailer.FileMailer",
+		"memory": "*mailer.MemoryMailer",
+	} {
+		m, err := New(&Config{Driver: driver, FileDir: t.TempDir(), SMTPHost: "localhost", SMTPPort: "587"})
+		if err != nil {
+			t.Errorf("New(%q): %v", driver, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", m); got != want {
+			t.Errorf("New(%q) = %s, want %s", driver, got, want)
+		}
+	}
+	if _, err := New(&Config{Driver: "pigeon"}); err == nil {
+		t.Error("unknown driver accepted")
+	}
+}
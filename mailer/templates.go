@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html.tmpl"))
+)
+
+// Render builds a message from the templates named <name>.txt.tmpl and
+// <name>.html.tmpl. The subject comes from the {{define "subject"}} block
+// of the text template.
+func Render(name string, data interface{}) (*Message, error) {
+	textTmpl := textTemplates.Lookup(name + ".txt.tmpl")
+	if textTmpl == nil {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, text bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render text body: %w", err)
+	}
+
+	msg := &Message{
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: text.String(),
+	}
+	if htmlTmpl := htmlTemplates.Lookup(name + ".html.tmpl"); htmlTmpl != nil {
+		var html bytes.Buffer
+		if err := htmlTmpl.Execute(&html, data); err != nil {
+			return nil, fmt.Errorf("failed to render html body: %w", err)
+		}
+		msg.HTMLBody = html.String()
+	}
+	return msg, nil
+}
@@ -1,28 +1,32 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"rest-api-golang/database"
+	"rest-api-golang/events"
+	"rest-api-golang/graphql"
 	"rest-api-golang/handlers"
+	"rest-api-golang/jobs"
+	"rest-api-golang/live"
+	"rest-api-golang/mailer"
 	"rest-api-golang/models"
+	"rest-api-golang/oidc"
+	"rest-api-golang/openapi"
+	"rest-api-golang/repositories"
+	"rest-api-golang/storage"
+	"rest-api-golang/webhooks"
 
-	"github.com/gorilla/mux"
 	"gopkg.in/yaml.v3"
 )
 
-// @title Book Management API
-// @version 1.0
-// @description A REST API for managing books with authentication
-// @host localhost:8080
-// @BasePath /api
-// @securityDefinitions.apikey BearerAuth
-// @in header
-// @name Authorization
-// @description Type "Bearer" followed by a space and JWT token.
 func main() {
 	// Connect to database
 	if err := database.ConnectDatabase(); err != nil {
@@ -41,124 +45,143 @@ func main() {
 	// Initialize repositories
 	handlers.InitializeRepositories()
 
+	// Link books created before the authors table to parsed authors; this
+	// runs once per database
+	migrated, err := repositories.NewAuthorRepository(database.DB).MigrateLegacyAuthors()
+	if err != nil {
+		log.Fatalf("Failed to migrate book authors: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("Linked authors for %d existing books", migrated)
+	}
+
+	// Import jobs only live in memory while they run
+	interrupted, err := repositories.NewImportRepository(database.DB).FailInterruptedJobs()
+	if err != nil {
+		log.Fatalf("Failed to clean up import jobs: %v", err)
+	}
+	if interrupted > 0 {
+		log.Printf("Marked %d interrupted import jobs as failed", interrupted)
+	}
+
+	// Expire ready holds that were not picked up and pass their copies on.
+	// The audit log shows the job as the user agent, without an actor.
+	holds := repositories.NewHoldRepository(database.DB).WithAudit(&models.AuditContext{UserAgent: "hold expiry"})
+	jobs.Every(context.Background(), jobs.Interval("HOLD_EXPIRY_INTERVAL", time.Minute), "hold expiry", func() error {
+		expired, err := holds.ExpireHolds()
+		if expired > 0 {
+			log.Printf("Expired %d holds", expired)
+		}
+		return err
+	})
+
+	// Send queued webhook deliveries, retrying failures with backoff
+	dispatcher := webhooks.NewDispatcher(repositories.NewWebhookRepository(database.DB), nil, webhooks.GetConfig())
+	jobs.Every(context.Background(), jobs.Interval("WEBHOOK_INTERVAL", 5*time.Second), "webhook delivery", func() error {
+		_, err := dispatcher.DeliverDue(context.Background())
+		return err
+	})
+
+	// Relay change events from the outbox (EVENT_PUBLISHER=discard|stdout|memory|nats|kafka)
+	publisher, err := events.New(events.GetConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize event publisher: %v", err)
+	}
+	defer publisher.Close()
+	outbox := repositories.NewOutboxRepository(database.DB)
+	relay := events.NewRelay(outbox, publisher, 100)
+	jobs.Every(context.Background(), jobs.Interval("OUTBOX_INTERVAL", time.Second), "outbox relay", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_, err := relay.RelayPending(ctx)
+		return err
+	})
+	retention := jobs.Interval("OUTBOX_RETENTION", 7*24*time.Hour)
+	jobs.Every(context.Background(), time.Hour, "outbox cleanup", func() error {
+		_, err := outbox.PurgePublished(time.Now().Add(-retention))
+		return err
+	})
+
+	// Push book changes to connected clients; LISTEN/NOTIFY lets every
+	// replica see the events written by the others
+	hub := live.NewHub(outbox, models.AggregateBook, 256)
+	if err := hub.Listen(context.Background(), database.GetDatabaseConfig().DSN()); err != nil {
+		log.Printf("Warning: live feed cannot listen for events: %v", err)
+	}
+	handlers.InitializeLiveFeed(hub, jobs.Interval("EVENTS_HEARTBEAT", 15*time.Second), strings.Split(os.Getenv("EVENTS_ALLOWED_ORIGINS"), ","))
+
+	// Initialize mailer for account emails (MAILER=smtp|file|memory)
+	mailConfig := mailer.GetConfig()
+	appMailer, err := mailer.New(mailConfig)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	handlers.InitializeMailer(appMailer, mailConfig.From)
+
+	// Initialize blob storage for cover images (BLOB_STORE=fs|memory|s3)
+	blobStore, err := storage.New(storage.GetConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize blob store: %v", err)
+	}
+	handlers.InitializeBlobStore(blobStore)
+	if base := os.Getenv("COVER_BASE_URL"); base != "" {
+		models.CoverBaseURL = base
+	}
+
 	// Create router
-	r := mux.NewRouter()
-
-	// API routes
-	api := r.PathPrefix("/api").Subrouter()
-
-	// Auth routes
-	api.HandleFunc("/login", handlers.Login).Methods("POST")
-	api.HandleFunc("/logout", handlers.Logout).Methods("POST")
-
-	// Book routes
-	api.HandleFunc("/books", handlers.GetBooks).Methods("GET")
-	api.HandleFunc("/books", handlers.CreateBook).Methods("POST")
-	api.HandleFunc("/books/{id}", handlers.GetBook).Methods("GET")
-	api.HandleFunc("/books/{id}", handlers.UpdateBook).Methods("PUT")
-	api.HandleFunc("/books/{id}", handlers.DeleteBook).Methods("DELETE")
-
-	// Health check endpoint
-	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, `{"status": "healthy", "message": "Server is running"}`)
-	}).Methods("GET")
-
-	// API Documentation endpoint
-	r.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html")
-		html := `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>Book Management API Documentation</title>
-    <style>
-        body { font-family: Arial, sans-serif; margin: 40px; }
-        .endpoint { background: #f5f5f5; padding: 15px; margin: 10px 0; border-radius: 5px; }
-        .method { font-weight: bold; color: #007bff; }
-        .auth { color: #dc3545; font-weight: bold; }
-        .no-auth { color: #28a745; font-weight: bold; }
-    </style>
-</head>
-<body>
-    <h1>📚 Book Management API</h1>
-    <p>REST API for managing books with authentication</p>
-    
-    <h2>🔐 Authentication</h2>
-    <p>Most endpoints require a Bearer token. Login first to get a token.</p>
-    
-    <h2>📋 Endpoints</h2>
-    
-    <div class="endpoint">
-        <span class="method">POST</span> /api/login <span class="no-auth">(No Auth)</span><br>
-        <strong>Description:</strong> Login to get authentication token<br>
-        <strong>Body:</strong> {"username": "admin", "password": "admin123"}<br>
-        <strong>Response:</strong> {"success": true, "token": "..."}
-    </div>
-    
-    <div class="endpoint">
-        <span class="method">POST</span> /api/logout <span class="auth">(Auth Required)</span><br>
-        <strong>Description:</strong> Logout and revoke token<br>
-        <strong>Headers:</strong> Authorization: Bearer YOUR_TOKEN
-    </div>
-    
-    <div class="endpoint">
-        <span class="method">GET</span> /api/books <span class="auth">(Auth Required)</span><br>
-        <strong>Description:</strong> Get all books<br>
-        <strong>Headers:</strong> Authorization: Bearer YOUR_TOKEN
-    </div>
-    
-    <div class="endpoint">
-        <span class="method">POST</span> /api/books <span class="auth">(Auth Required)</span><br>
-        <strong>Description:</strong> Create a new book<br>
-        <strong>Headers:</strong> Authorization: Bearer YOUR_TOKEN<br>
-        <strong>Body:</strong> {"judul": "Book Title", "author": "Author Name", "tahun_terbit": 2024}
-    </div>
-    
-    <div class="endpoint">
-        <span class="method">GET</span> /api/books/{id} <span class="auth">(Auth Required)</span><br>
-        <strong>Description:</strong> Get book by ID<br>
-        <strong>Headers:</strong> Authorization: Bearer YOUR_TOKEN
-    </div>
-    
-    <div class="endpoint">
-        <span class="method">PUT</span> /api/books/{id} <span class="auth">(Auth Required)</span><br>
-        <strong>Description:</strong> Update book by ID<br>
-        <strong>Headers:</strong> Authorization: Bearer YOUR_TOKEN<br>
-        <strong>Body:</strong> {"judul": "New Title", "author": "New Author", "tahun_terbit": 2024}
-    </div>
-    
-    <div class="endpoint">
-        <span class="method">DELETE</span> /api/books/{id} <span class="auth">(Auth Required)</span><br>
-        <strong>Description:</strong> Delete book by ID<br>
-        <strong>Headers:</strong> Authorization: Bearer YOUR_TOKEN
-    </div>
-    
-    <div class="endpoint">
-        <span class="method">GET</span> /health <span class="no-auth">(No Auth)</span><br>
-        <strong>Description:</strong> Health check endpoint
-    </div>
-    
-    <h2>👥 Default Users</h2>
-    <ul>
-        <li><strong>Username:</strong> admin, <strong>Password:</strong> admin123</li>
-        <li><strong>Username:</strong> user, <strong>Password:</strong> user123</li>
-    </ul>
-    
-    <h2>🧪 Testing</h2>
-    <p>Use tools like Postman, Insomnia, or curl to test the API endpoints.</p>
-</body>
-</html>`
-		fmt.Fprint(w, html)
-	}).Methods("GET")
+	r := handlers.NewRouter()
+
+	// SSO login (OpenID Connect). OIDC_MOCK=true serves an in-process mock
+	// identity provider under /mock-idp for development without a real IdP.
+	oidcConfig := oidc.GetConfig()
+	if os.Getenv("OIDC_MOCK") == "true" {
+		oidcConfig.Issuer = "http://localhost:8080/mock-idp"
+		if oidcConfig.ClientID == "" {
+			oidcConfig.ClientID = "book-api"
+		}
+		mockIdP, err := oidc.NewMockProvider(oidcConfig.Issuer, oidcConfig.ClientID, mockIdentityUsers()...)
+		if err != nil {
+			log.Fatalf("Failed to start mock identity provider: %v", err)
+		}
+		r.PathPrefix("/mock-idp").Handler(http.StripPrefix("/mock-idp", mockIdP.Handler()))
+	}
+	if oidcConfig.Enabled() {
+		handlers.InitializeOIDC(oidc.NewProvider(oidcConfig, nil))
+	}
+
+	// GraphQL schema; GRAPHQL_DEV_MODE=true also serves the GraphiQL IDE
+	graphqlConfig := graphql.GetConfig()
+	if err := handlers.InitializeGraphQL(graphqlConfig); err != nil {
+		log.Fatalf("Failed to build GraphQL schema: %v", err)
+	}
+	if graphqlConfig.DevMode {
+		r.HandleFunc("/graphiql", graphql.GraphiQL("/graphql")).Methods("GET")
+	}
+
+	// OpenAPI document of the routes above, browsable with Swagger UI and
+	// Redoc. OPENAPI_VALIDATE=true logs requests and responses that do not
+	// match it.
+	spec, err := handlers.OpenAPI(r)
+	if spec == nil {
+		log.Fatalf("Failed to build OpenAPI document: %v", err)
+	}
+	if err != nil {
+		log.Printf("Warning: OpenAPI document does not match the routes:\n%v", err)
+	}
+	r.HandleFunc("/openapi.json", openapi.Handler(spec)).Methods("GET")
+	r.HandleFunc("/docs", openapi.SwaggerUI("/openapi.json")).Methods("GET")
+	r.HandleFunc("/docs/redoc", openapi.Redoc("/openapi.json")).Methods("GET")
+	var routes http.Handler = r
+	if os.Getenv("OPENAPI_VALIDATE") == "true" {
+		routes = openapi.NewValidator(spec, nil).Middleware(r)
+	}
 
 	// CORS middleware
 	corsHandler := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -169,23 +192,87 @@ func main() {
 		})
 	}
 
-	// Apply CORS and Auth middleware
-	secured := handlers.AuthMiddleware(r)
-	handler := corsHandler(secured)
+	// Apply request ID, CORS and Auth middleware
+	secured := handlers.AuthMiddleware(routes)
+	handler := handlers.RequestIDMiddleware(corsHandler(secured))
+
+	// gRPC server of BookService and AuthService on its own port, when
+	// GRPC_PORT is set. The REST API keeps running if it cannot start.
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort != "" {
+		grpcListener, err := net.Listen("tcp", grpcPort)
+		if err != nil {
+			log.Printf("gRPC disabled: failed to listen on %s: %v", grpcPort, err)
+			grpcPort = ""
+		} else {
+			go func() {
+				if err := handlers.NewGRPCServer().Serve(grpcListener); err != nil {
+					log.Printf("gRPC server stopped: %v", err)
+				}
+			}()
+		}
+	}
 
 	// Start server
 	port := ":8080"
 	fmt.Printf("🚀 Server starting on port %s\n", port)
 	fmt.Println("📚 Book API Endpoints:")
 	fmt.Println("  POST   /api/login       - Login to get token")
+	fmt.Println("  POST   /api/login/mfa   - Complete login with a TOTP or recovery code")
+	fmt.Println("  GET    /api/login/oidc  - Login with SSO (OpenID Connect)")
 	fmt.Println("  POST   /api/logout      - Logout (requires token)")
+	fmt.Println("  POST   /api/password/forgot - Email a password reset link")
+	fmt.Println("  POST   /api/password/reset  - Set a new password with the emailed token")
+	fmt.Println("  POST   /api/email/verify    - Verify email with the emailed token")
+	fmt.Println("  GET    /api/mfa         - Two-factor status; enroll/confirm/recovery-codes (requires token)")
 	fmt.Println("  GET    /api/books       - Get all books (requires token)")
 	fmt.Println("  POST   /api/books       - Create a new book (requires token)")
+	fmt.Println("  GET    /api/books/isbn/{isbn} - Get book by ISBN-10/13 (requires token)")
+	fmt.Println("  POST   /api/books/import - Bulk import CSV, JSON Lines or MARC (admin)")
+	fmt.Println("  GET    /api/books/export - Export CSV, JSON Lines, XLSX, BibTeX or RIS (requires token)")
+	fmt.Println("  POST   /api/books/batch - Create/update/delete many books, atomic or best effort (requires token)")
 	fmt.Println("  GET    /api/books/{id}  - Get book by ID (requires token)")
 	fmt.Println("  PUT    /api/books/{id}  - Update book by ID (requires token)")
 	fmt.Println("  DELETE /api/books/{id}  - Delete book by ID (requires token)")
+	fmt.Println("  POST   /api/books/{id}/restore - Restore a deleted book (admin)")
+	fmt.Println("  GET    /api/books/{id}/revisions - Revision history, diff and revert (requires token)")
+	fmt.Println("  PUT    /api/books/{id}/cover   - Upload a cover image (requires token)")
+	fmt.Println("  GET    /covers/{hash}/{name}   - Cover image or thumbnail")
+	fmt.Println("  GET    /api/authors     - List/create authors (requires token)")
+	fmt.Println("  GET    /api/authors/{id}/books - Books by author (requires token)")
+	fmt.Println("  GET    /api/categories  - Category tree; create/update/delete (requires token)")
+	fmt.Println("  GET    /api/tags        - Tag autocomplete (requires token)")
+	fmt.Println("  POST   /api/books/{id}/copies - Add a copy (admin)")
+	fmt.Println("  POST   /api/loans       - Check out a copy (admin)")
+	fmt.Println("  POST   /api/loans/{id}/return - Return a copy (admin)")
+	fmt.Println("  POST   /api/loans/{id}/renew  - Renew a loan (requires token)")
+	fmt.Println("  GET    /api/loans       - List loans (requires token)")
+	fmt.Println("  POST   /api/books/{id}/holds - Place a hold (requires token)")
+	fmt.Println("  GET    /api/holds       - List holds (requires token)")
+	fmt.Println("  DELETE /api/holds/{id}  - Cancel a hold (requires token)")
+	fmt.Println("  POST   /api/books/{id}/reviews - Review a book (requires token)")
+	fmt.Println("  PUT    /api/reviews/{id}/status - Moderate a review (admin)")
+	fmt.Println("  GET    /api/lists       - Reading lists; items and order under /api/lists/{id} (requires token)")
+	fmt.Println("  GET    /api/shared-lists/{token} - Open a shared reading list")
+	fmt.Println("  GET    /api/account     - Fine balance and ledger (requires token)")
+	fmt.Println("  POST   /api/users/{id}/account/payments - Record a payment (admin)")
+	fmt.Println("  POST   /api/users/{id}/account/waivers  - Waive fines (admin)")
+	fmt.Println("  GET    /api/users       - List/create users; disable, reset password, revoke tokens (admin)")
+	fmt.Println("  POST   /api/admin/trash/purge - Permanently delete old deleted books (admin)")
+	fmt.Println("  POST   /api/admin/tokens/cleanup - Delete expired session tokens (admin)")
+	fmt.Println("  GET    /api/audit       - Audit log of changes and logins (admin)")
+	fmt.Println("  POST   /api/webhooks    - Webhook subscriptions and delivery log (admin)")
+	fmt.Println("  GET    /api/events      - Live feed of book changes, SSE or /api/events/ws (requires token)")
+	fmt.Println("  POST   /graphql         - GraphQL queries and book mutations (requires token)")
+	if graphqlConfig.DevMode {
+		fmt.Println("  GET    /graphiql        - GraphiQL IDE")
+	}
 	fmt.Println("  GET    /health          - Health check")
-	fmt.Println("  GET    /docs            - API documentation")
+	if grpcPort != "" {
+		fmt.Printf("  gRPC   %s            - BookService and AuthService, health and reflection\n", grpcPort)
+	}
+	fmt.Println("  GET    /docs            - API documentation (Swagger UI; Redoc at /docs/redoc)")
+	fmt.Println("  GET    /openapi.json    - OpenAPI 3.1 document")
 	fmt.Println()
 
 	log.Fatal(http.ListenAndServe(port, handler))
@@ -208,3 +295,25 @@ func loadUsersFromConfig() []models.User {
 	}
 	return raw.Users
 }
+
+// mockIdentityUsers returns the accounts offered by the mock identity provider
+func mockIdentityUsers() []oidc.MockUser {
+	return []oidc.MockUser{
+		{
+			Subject:           "mock-admin-1",
+			PreferredUsername: "sso-admin",
+			Name:              "SSO Admin",
+			Email:             "sso-admin@example.com",
+			EmailVerified:     true,
+			Groups:            []string{"library-admins"},
+		},
+		{
+			Subject:           "mock-reader-1",
+			PreferredUsername: "sso-reader",
+			Name:              "SSO Reader",
+			Email:             "sso-reader@example.com",
+			EmailVerified:     true,
+			Groups:            []string{"readers"},
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions. Mutations are create, update, delete and restore; the
+// rest are authentication events.
+const (
+	AuditActionCreate      = "create"
+	AuditActionUpdate      = "update"
+	AuditActionDelete      = "delete"
+	AuditActionRestore     = "restore"
+	AuditActionLogin       = "login"
+	AuditActionLogout      = "logout"
+	AuditActionLoginFailed = "login_failed"
+)
+
+// Audited entity types
+const (
+	AuditEntityBook        = "book"
+	AuditEntityAuthor      = "author"
+	AuditEntityCategory    = "category"
+	AuditEntityCopy        = "copy"
+	AuditEntityLoan        = "loan"
+	AuditEntityLoanPolicy  = "loan_policy"
+	AuditEntityHold        = "hold"
+	AuditEntityReview      = "review"
+	AuditEntityReadingList = "reading_list"
+	AuditEntityAccount     = "account_entry"
+	AuditEntityUser        = "user"
+	AuditEntityMFA         = "mfa"
+	AuditEntitySetting     = "setting"
+	AuditEntitySession     = "session"
+	AuditEntityWebhook     = "webhook"
+)
+
+// AuditContext describes who made a change and through which request.
+// Changes made without one, such as by background jobs, are recorded
+// without an actor.
+type AuditContext struct {
+	ActorID   string
+	RequestID string
+	IP        string
+	UserAgent string
+}
+
+// AuditEvent is one entry of the append-only audit log. Before and After
+// hold the entity as JSON: only After for a create, only Before for a
+// delete, and otherwise just the fields that changed.
+type AuditEvent struct {
+	ID         string          `json:"id"`
+	ActorID    string          `json:"actor_id,omitempty"`
+	Actor      string          `json:"actor,omitempty"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows the audit log; zero values mean "no filter"
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	EntityType string
+	EntityID   string
+	From       *time.Time
+	To         *time.Time
+	// Before pages backwards: only events older than the event with this ID
+	Before string
+	Limit  int
+}
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Author represents a person credited on books
+type Author struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Bio       string    `json:"bio,omitempty" db:"bio"`
+	BookCount int       `json:"book_count" db:"book_count"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BookAuthor links an author to a book with a role and display order. In
+// requests either AuthorID or Name identifies the author; an unknown name
+// creates the author.
+type BookAuthor struct {
+	AuthorID string `json:"author_id,omitempty" db:"author_id"`
+	Name     string `json:"name,omitempty" db:"name"`
+	Role     string `json:"role" db:"role"`
+	Position int    `json:"position" db:"position"`
+}
+
+// Author roles on a book
+const (
+	AuthorRoleAuthor     = "author"
+	AuthorRoleEditor     = "editor"
+	AuthorRoleTranslator = "translator"
+)
+
+// AuthorRoles lists the accepted values of BookAuthor.Role
+var AuthorRoles = []string{AuthorRoleAuthor, AuthorRoleEditor, AuthorRoleTranslator}
+
+// CreateAuthorRequest represents the request payload for creating an author
+type CreateAuthorRequest struct {
+	Name string `json:"name" validate:"required"`
+	Bio  string `json:"bio,omitempty"`
+}
+
+// UpdateAuthorRequest represents the request payload for updating an author
+type UpdateAuthorRequest struct {
+	Name string `json:"name,omitempty"`
+	Bio  string `json:"bio,omitempty"`
+}
+
+// NormalizeAuthorName returns the key used to de-duplicate authors:
+// lower case, without dots, with whitespace collapsed and runs of initials
+// joined, so "J.K. Rowling", "J. K. Rowling" and "JK Rowling" are equal
+func NormalizeAuthorName(name string) string {
+	name = strings.ToLower(strings.ReplaceAll(name, ".", " "))
+	var tokens []string
+	initials := ""
+	for _, token := range strings.Fields(name) {
+		if utf8.RuneCountInString(token) == 1 {
+			initials += token
+			continue
+		}
+		if initials != "" {
+			tokens = append(tokens, initials)
+			initials = ""
+		}
+		tokens = append(tokens, token)
+	}
+	if initials != "" {
+		tokens = append(tokens, initials)
+	}
+	return strings.Join(tokens, " ")
+}
+
+// authorSeparators splits on ; and & anywhere, and on the words and, dan
+// and with only as whole words between two names, so names like "Zaïdan"
+// or "Abu-dan" stay whole
+var authorSeparators = regexp.MustCompile(`\s*[;&]\s*|\s+(?:and|dan|with)\s+`)
+
+// surnameParticles may precede a surname, as in "van der Berg" or
+// "bin Laden"
+var surnameParticles = map[string]bool{
+	"al": true, "bin": true, "binti": true, "da": true, "de": true, "del": true, "della": true,
+	"den": true, "der": true, "di": true, "du": true, "la": true, "le": true, "st.": true,
+	"te": true, "ten": true, "ter": true, "van": true, "von": true,
+}
+
+// SplitAuthorNames splits a free-text author field such as "A, B & C" into
+// individual names. Catalog style "Last, First" is kept together: a
+// comma-separated part that is a single surname, possibly after particles
+// like "van der", or that is followed by initials only, takes the next
+// part as its given names. So "Hirata, Andrea" and "Tolkien, J.R.R." are
+// one author each ("Andrea Hirata", "J.R.R. Tolkien") and "Pratchett,
+// Terry, Gaiman, Neil" is two. A list of bare surnames such as
+// "Kernighan, Ritchie" cannot be told apart from that and is read as one
+// name; separate those with ; or & instead.
+func SplitAuthorNames(s string) []string {
+	var names []string
+	for _, group := range authorSeparators.Split(s, -1) {
+		parts := strings.Split(group, ",")
+		for i := 0; i < len(parts); i++ {
+			part := strings.Join(strings.Fields(parts[i]), " ")
+			if part == "" {
+				continue
+			}
+			if i+1 < len(parts) && strings.TrimSpace(parts[i+1]) != "" && (isSurname(part) || isInitials(parts[i+1])) {
+				part = strings.Join(strings.Fields(parts[i+1]), " ") + " " + part
+				i++
+			}
+			names = append(names, part)
+		}
+	}
+
+	// Drop duplicates within the same field
+	seen := map[string]bool{}
+	unique := names[:0]
+	for _, name := range names {
+		key := NormalizeAuthorName(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, name)
+	}
+	return unique
+}
+
+// isSurname reports whether s is one word, possibly after surname
+// particles
+func isSurname(s string) bool {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return false
+	}
+	for _, word := range words[:len(words)-1] {
+		if !surnameParticles[strings.ToLower(word)] {
+			return false
+		}
+	}
+	return true
+}
+
+func isInitials(s string) bool {
+	tokens := strings.Fields(strings.ReplaceAll(s, ".", " "))
+	if len(tokens) == 0 {
+		return false
+	}
+	for _, token := range tokens {
+		if utf8.RuneCountInString(token) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// FlatAuthorString builds the legacy books.author value: the names with
+// role "author" in order, or every name if there are none
+func FlatAuthorString(authors []BookAuthor) string {
+	var names []string
+	for _, a := range authors {
+		if a.Role == AuthorRoleAuthor {
+			names = append(names, a.Name)
+		}
+	}
+	if len(names) == 0 {
+		for _, a := range authors {
+			names = append(names, a.Name)
+		}
+	}
+	flat := strings.Join(names, ", ")
+	if len(flat) > 255 {
+		cut := 252
+		for cut > 0 && !utf8.RuneStart(flat[cut]) {
+			cut--
+		}
+		flat = flat[:cut] + "..."
+	}
+	return flat
+}
+
+// AuthorsFromString converts a legacy free-text author field into an
+// ordered list of BookAuthor entries with role "author"
+func AuthorsFromString(s string) []BookAuthor {
+	var authors []BookAuthor
+	for i, name := range SplitAuthorNames(s) {
+		authors = append(authors, BookAuthor{Name: name, Role: AuthorRoleAuthor, Position: i + 1})
+	}
+	return authors
+}
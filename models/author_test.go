@@ -0,0 +1,54 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitAuthorNames(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Neil Gaiman", []string{"Neil Gaiman"}},
+		{"Terry Pratchett & Neil Gaiman", []string{"Terry Pratchett", "Neil Gaiman"}},
+		{"A. Author; B. Author, C. Author", []string{"A. Author", "B. Author", "C. Author"}},
+		{"Tolkien, J.R.R.", []string{"J.R.R. Tolkien"}},
+		// Last, First
+		{"Hirata, Andrea", []string{"Andrea Hirata"}},
+		{"Toer, Pramoedya Ananta", []string{"Pramoedya Ananta Toer"}},
+		{"Pratchett, Terry & Gaiman, Neil", []string{"Terry Pratchett", "Neil Gaiman"}},
+		{"Pratchett, Terry; Gaiman, Neil", []string{"Terry Pratchett", "Neil Gaiman"}},
+		{"Pratchett, Terry, Gaiman, Neil", []string{"Terry Pratchett", "Neil Gaiman"}},
+		{"Hirata, Andrea dan Lestari, Dee", []string{"Andrea Hirata", "Dee Lestari"}},
+		{"van der Berg, Anna", []string{"Anna van der Berg"}},
+		{"Le Guin, Ursula K.", []string{"Ursula K. Le Guin"}},
+		{"García Márquez, G.", []string{"G. García Márquez"}},
+		{"Tolkien, J.R.R., Lewis, C.S.", []string{"J.R.R. Tolkien", "C.S. Lewis"}},
+		{"Neil Gaiman, Pratchett, Terry", []string{"Neil Gaiman", "Terry Pratchett"}},
+		// Full names in a comma list stay separate
+		{"Neil Gaiman, Terry Pratchett", []string{"Neil Gaiman", "Terry Pratchett"}},
+		{"Ursula Le Guin, Neil Gaiman", []string{"Ursula Le Guin", "Neil Gaiman"}},
+		// A surname with nothing after the comma is a name of its own
+		{"Hirata,", []string{"Hirata"}},
+		{"Hirata, , Lestari", []string{"Hirata", "Lestari"}},
+		{"Kernighan and Ritchie", []string{"Kernighan", "Ritchie"}},
+		{"Andrea Hirata dan Dee Lestari", []string{"Andrea Hirata", "Dee Lestari"}},
+		{"Ahmad Tohari  dan\tPramoedya Ananta Toer", []string{"Ahmad Tohari", "Pramoedya Ananta Toer"}},
+		{"Jane Doe with John Roe", []string{"Jane Doe", "John Roe"}},
+		// dan, and and with inside a name are not separators
+		{"Zaïdan Ramadan", []string{"Zaïdan Ramadan"}},
+		{"Abu-dan Jordan", []string{"Abu-dan Jordan"}},
+		{"Randy Sandanson", []string{"Randy Sandanson"}},
+		{"Alexander Wither-Sandberg", []string{"Alexander Wither-Sandberg"}},
+		{"dan Brown", []string{"dan Brown"}},
+		{"Dan Brown and Dan Simmons", []string{"Dan Brown", "Dan Simmons"}},
+		{"Neil Gaiman & neil gaiman", []string{"Neil Gaiman"}},
+		{" ; & ", nil},
+	}
+	for _, tt := range tests {
+		if got := SplitAuthorNames(tt.in); !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("SplitAuthorNames(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import "encoding/json"
+
+// Operations of POST /api/books/batch
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+var BatchOps = []string{BatchOpCreate, BatchOpUpdate, BatchOpDelete}
+
+// Batch modes: atomic applies all operations or none, best_effort applies
+// every operation that succeeds
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+)
+
+var BatchModes = []string{BatchModeAtomic, BatchModeBestEffort}
+
+// BatchRequest is the payload of POST /api/books/batch
+type BatchRequest struct {
+	Mode       string           `json:"mode"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation is one operation of a batch. Book holds a
+// CreateBookRequest for create and an UpdateBookRequest for update; ID
+// names the book to update or delete.
+type BatchOperation struct {
+	Op   string          `json:"op"`
+	ID   string          `json:"id,omitempty"`
+	Book json.RawMessage `json:"book,omitempty"`
+}
+
+// BatchResult is the outcome of one operation, with the status and
+// message the single-book endpoint would have returned
+type BatchResult struct {
+	Index   int    `json:"index"`
+	Op      string `json:"op"`
+	ID      string `json:"id,omitempty"`
+	Success bool   `json:"success"`
+	Status  int    `json:"status"`
+	Message string `json:"message,omitempty"`
+	Data    *Book  `json:"data,omitempty"`
+}
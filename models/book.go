@@ -8,29 +8,97 @@ import (
 
 // Book represents a book entity
 type Book struct {
-	ID          string     `json:"id" db:"id"`
-	Judul       string     `json:"judul" db:"judul"`
-	Author      string     `json:"author" db:"author"`
-	TahunTerbit int        `json:"tahun_terbit" db:"tahun_terbit"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
-	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	ID          string        `json:"id" db:"id"`
+	Judul       string        `json:"judul" db:"judul"`
+	Author      string        `json:"author" db:"author"`
+	TahunTerbit int           `json:"tahun_terbit" db:"tahun_terbit"`
+	ISBN        string        `json:"isbn,omitempty" db:"isbn"`
+	Publisher   string        `json:"publisher,omitempty" db:"publisher"`
+	Language    string        `json:"language,omitempty" db:"language"`
+	Pages       int           `json:"pages,omitempty" db:"pages"`
+	Description string        `json:"description,omitempty" db:"description"`
+	Edition     string        `json:"edition,omitempty" db:"edition"`
+	Format      string        `json:"format,omitempty" db:"format"`
+	Authors     []BookAuthor  `json:"authors,omitempty"`
+	Categories  []CategoryRef `json:"categories,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	// AverageRating and RatingCount summarize the approved reviews
+	AverageRating float64 `json:"average_rating" db:"average_rating"`
+	RatingCount   int     `json:"rating_count" db:"rating_count"`
+	// CoverURL and CoverThumbnails are derived from the cover's content
+	// hash, so a new upload always gets new URLs; see SetCoverURLs
+	CoverURL        string            `json:"cover_url,omitempty"`
+	CoverThumbnails map[string]string `json:"cover_thumbnails,omitempty"`
+	CoverHash       string            `json:"-" db:"cover_hash"`
+	CoverFormat     string            `json:"-" db:"cover_format"`
+	// Availability is only filled in by GET /api/books/{id}
+	Availability *BookAvailability `json:"availability,omitempty"`
+	CreatedAt    time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at" db:"updated_at"`
+	DeletedAt    *time.Time        `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
-// CreateBookRequest represents the request payload for creating a book
+// Book formats accepted in the format field
+var BookFormats = []string{"hardcover", "paperback", "ebook", "audiobook", "other"}
+
+// CreateBookRequest represents the request payload for creating a book.
+// Either the free-text Author or the structured Authors list is required.
 type CreateBookRequest struct {
-	Judul       string `json:"judul" validate:"required"`
-	Author      string `json:"author" validate:"required"`
-	TahunTerbit int    `json:"tahun_terbit" validate:"required,min=1000,max=2024"`
+	Judul       string       `json:"judul" validate:"required"`
+	Author      string       `json:"author,omitempty"`
+	TahunTerbit int          `json:"tahun_terbit" validate:"required,min=1000,max=2024"`
+	ISBN        string       `json:"isbn,omitempty"`
+	Publisher   string       `json:"publisher,omitempty"`
+	Language    string       `json:"language,omitempty"`
+	Pages       int          `json:"pages,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Edition     string       `json:"edition,omitempty"`
+	Format      string       `json:"format,omitempty"`
+	Authors     []BookAuthor `json:"authors,omitempty"`
+	Categories  []string     `json:"categories,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
 }
 
 // UpdateBookRequest represents the request payload for updating a book
 type UpdateBookRequest struct {
-	Judul       string `json:"judul,omitempty"`
-	Author      string `json:"author,omitempty"`
-	TahunTerbit int    `json:"tahun_terbit,omitempty"`
+	Judul       string       `json:"judul,omitempty"`
+	Author      string       `json:"author,omitempty"`
+	TahunTerbit int          `json:"tahun_terbit,omitempty"`
+	ISBN        string       `json:"isbn,omitempty"`
+	Publisher   string       `json:"publisher,omitempty"`
+	Language    string       `json:"language,omitempty"`
+	Pages       int          `json:"pages,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Edition     string       `json:"edition,omitempty"`
+	Format      string       `json:"format,omitempty"`
+	Authors     []BookAuthor `json:"authors,omitempty"`
+	Categories  []string     `json:"categories,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+}
+
+// BookFilter narrows the book list; zero values mean "no filter"
+type BookFilter struct {
+	ISBN      string
+	Publisher string
+	Language  string
+	Format    string
+	MinPages  int
+	MaxPages  int
+	AuthorID  string
+	// Category is a slug or ID; books in descendant categories match too
+	Category string
+	Tags     []string
+	// MatchAllTags requires every tag instead of any of them
+	MatchAllTags bool
+	MinRating    float64
+	// Sort is one of BookSorts, prefixed with "-" for descending order;
+	// empty means newest first
+	Sort string
 }
 
+// BookSorts lists the fields the book list can be sorted by
+var BookSorts = []string{"created_at", "judul", "tahun_terbit", "average_rating", "rating_count"}
+
 // Config represents application configuration loaded from YAML
 type Config struct {
 	Users []User `yaml:"users"`
@@ -38,15 +106,16 @@ type Config struct {
 
 // User represents a simple user credential pair
 type User struct {
-	ID        string     `json:"id" db:"id"`
-	Username  string     `json:"username" yaml:"username" db:"username"`
-	Password  string     `json:"-" yaml:"password" db:"password"`
-	Email     string     `json:"email" db:"email"`
-	Role      string     `json:"role" db:"role"`
-	IsActive  bool       `json:"is_active" db:"is_active"`
-	CreatedAt time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
-	LastLogin *time.Time `json:"last_login,omitempty" db:"last_login"`
+	ID              string     `json:"id" db:"id"`
+	Username        string     `json:"username" yaml:"username" db:"username"`
+	Password        string     `json:"-" yaml:"password" db:"password"`
+	Email           string     `json:"email" db:"email"`
+	Role            string     `json:"role" db:"role"`
+	IsActive        bool       `json:"is_active" db:"is_active"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	LastLogin       *time.Time `json:"last_login,omitempty" db:"last_login"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
 }
 
 // NewBook creates a new Book instance
@@ -57,6 +126,16 @@ func NewBook(req CreateBookRequest) *Book {
 		Judul:       req.Judul,
 		Author:      req.Author,
 		TahunTerbit: req.TahunTerbit,
+		ISBN:        req.ISBN,
+		Publisher:   req.Publisher,
+		Language:    req.Language,
+		Pages:       req.Pages,
+		Description: req.Description,
+		Edition:     req.Edition,
+		Format:      req.Format,
+		Authors:     req.Authors,
+		Categories:  CategoryRefsFromStrings(req.Categories),
+		Tags:        req.Tags,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
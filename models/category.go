@@ -0,0 +1,134 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Category is a node in the category tree. Path is the slash-separated
+// list of slugs from the root, e.g. "fiction/fantasy".
+type Category struct {
+	ID        string      `json:"id" db:"id"`
+	Name      string      `json:"name" db:"name"`
+	Slug      string      `json:"slug" db:"slug"`
+	ParentID  *string     `json:"parent_id" db:"parent_id"`
+	Path      string      `json:"path" db:"path"`
+	Depth     int         `json:"depth"`
+	BookCount int         `json:"book_count"`
+	Children  []*Category `json:"children,omitempty"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// CategoryRef is a category as embedded in a book. In requests only one of
+// ID or Slug needs to be set.
+type CategoryRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+	Path string `json:"path"`
+}
+
+// Tag is a free-form label with the number of books using it
+type Tag struct {
+	Name      string `json:"name"`
+	BookCount int    `json:"book_count"`
+}
+
+// CreateCategoryRequest represents the request payload for creating a
+// category; the slug is derived from the name when omitted
+type CreateCategoryRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Slug     string `json:"slug,omitempty"`
+	ParentID string `json:"parent_id,omitempty"`
+}
+
+// UpdateCategoryRequest represents the request payload for updating a
+// category. ParentID "" moves the category to the root; omit it to keep
+// the current parent.
+type UpdateCategoryRequest struct {
+	Name     string  `json:"name,omitempty"`
+	Slug     string  `json:"slug,omitempty"`
+	ParentID *string `json:"parent_id,omitempty"`
+}
+
+// Slugify lower-cases s and replaces every run of characters other than
+// letters and digits with a single hyphen
+func Slugify(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		hyphen = true
+	}
+	return b.String()
+}
+
+// NormalizeTag lower-cases a tag and collapses whitespace
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+}
+
+// NormalizeTags normalizes tags and drops empty and duplicate entries,
+// keeping the first occurrence order
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// BuildCategoryTree nests a flat list of categories ordered by path under
+// their parents and returns the roots
+func BuildCategoryTree(categories []*Category) []*Category {
+	byID := make(map[string]*Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+	var roots []*Category
+	for _, c := range categories {
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, c)
+				continue
+			}
+		}
+		roots = append(roots, c)
+	}
+	return roots
+}
+
+// CategoryRefsFromStrings converts category IDs or slugs from a request
+// into references to resolve. A nil slice stays nil.
+func CategoryRefsFromStrings(values []string) []CategoryRef {
+	if values == nil {
+		return nil
+	}
+	refs := make([]CategoryRef, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if _, err := uuid.Parse(v); err == nil {
+			refs = append(refs, CategoryRef{ID: v})
+		} else {
+			refs = append(refs, CategoryRef{Slug: strings.ToLower(v)})
+		}
+	}
+	return refs
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Fantasy":                 "fantasy",
+		"Science Fiction":         "science-fiction",
+		"  Sejarah & Budaya!  ":   "sejarah-budaya",
+		"Cerita--Anak":            "cerita-anak",
+		"Ilmu Pengetahuan Alam 2": "ilmu-pengetahuan-alam-2",
+		"Bücher":                  "bücher",
+		"!!!":                     "",
+	}
+	for in, want := range tests {
+		if got := Slugify(in); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{" Dragons ", "classic", "DRAGONS", "", "Science   Fiction", "classic"})
+	want := []string{"dragons", "classic", "science fiction"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := NormalizeTags(nil); got == nil || len(got) != 0 {
+		t.Errorf("NormalizeTags(nil) = %#v, want an empty list", got)
+	}
+}
+
+func TestBuildCategoryTree(t *testing.T) {
+	id := func(s string) *string { return &s }
+	fiction := &Category{ID: "1", Path: "fiction"}
+	fantasy := &Category{ID: "2", ParentID: id("1"), Path: "fiction/fantasy"}
+	epic := &Category{ID: "3", ParentID: id("2"), Path: "fiction/fantasy/epic"}
+	history := &Category{ID: "4", Path: "history"}
+	// A parent missing from the list makes its child a root
+	orphan := &Category{ID: "5", ParentID: id("9"), Path: "lost/orphan"}
+
+	roots := BuildCategoryTree([]*Category{fiction, fantasy, epic, history, orphan})
+	if !reflect.DeepEqual(roots, []*Category{fiction, history, orphan}) {
+		t.Fatalf("roots %v", roots)
+	}
+	if len(fiction.Children) != 1 || fiction.Children[0] != fantasy {
+		t.Errorf("fiction children %v", fiction.Children)
+	}
+	if len(fantasy.Children) != 1 || fantasy.Children[0] != epic {
+		t.Errorf("fantasy children %v", fantasy.Children)
+	}
+}
+
+func TestCategoryRefsFromStrings(t *testing.T) {
+	if refs := CategoryRefsFromStrings(nil); refs != nil {
+		t.Errorf("nil input: %v", refs)
+	}
+	id := "5f0c8a8e-1b7a-4c1e-9d51-3c4a2b6e7f10"
+	got := CategoryRefsFromStrings([]string{id, " Fantasy "})
+	want := []CategoryRef{{ID: id}, {Slug: "fantasy"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
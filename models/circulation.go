@@ -0,0 +1,220 @@
+package models
+
+import "time"
+
+// Copy is a physical, lendable item of a book identified by its barcode
+type Copy struct {
+	ID        string    `json:"id" db:"id"`
+	BookID    string    `json:"book_id" db:"book_id"`
+	Barcode   string    `json:"barcode" db:"barcode"`
+	Condition string    `json:"condition" db:"condition"`
+	Location  string    `json:"location,omitempty" db:"location"`
+	Status    string    `json:"status" db:"status"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Copy statuses. on_loan and on_hold are managed by checkouts, returns and
+// holds and cannot be set directly.
+const (
+	CopyStatusAvailable   = "available"
+	CopyStatusOnLoan      = "on_loan"
+	CopyStatusOnHold      = "on_hold"
+	CopyStatusMaintenance = "maintenance"
+	CopyStatusLost        = "lost"
+	CopyStatusWithdrawn   = "withdrawn"
+)
+
+// CopyStatuses lists the values accepted when setting a copy status
+var CopyStatuses = []string{CopyStatusAvailable, CopyStatusMaintenance, CopyStatusLost, CopyStatusWithdrawn}
+
+// CopyConditions lists the accepted values of Copy.Condition
+var CopyConditions = []string{"new", "good", "fair", "poor", "damaged"}
+
+// CreateCopyRequest represents the request payload for adding a copy
+type CreateCopyRequest struct {
+	Barcode   string `json:"barcode" validate:"required"`
+	Condition string `json:"condition,omitempty"`
+	Location  string `json:"location,omitempty"`
+}
+
+// UpdateCopyRequest represents the request payload for updating a copy
+type UpdateCopyRequest struct {
+	Barcode   string `json:"barcode,omitempty"`
+	Condition string `json:"condition,omitempty"`
+	Location  string `json:"location,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+// BookAvailability summarizes the copies of a book
+type BookAvailability struct {
+	Total     int `json:"total"`
+	Available int `json:"available"`
+	OnLoan    int `json:"on_loan"`
+	OnHold    int `json:"on_hold"`
+	// Holds is the number of members waiting for a copy
+	Holds int `json:"holds"`
+}
+
+// LoanPolicy holds the lending rules for members of a role. The policy
+// with role "default" applies to roles without their own policy.
+type LoanPolicy struct {
+	Role        string `json:"role" db:"role"`
+	LoanDays    int    `json:"loan_days" db:"loan_days"`
+	RenewalDays int    `json:"renewal_days" db:"renewal_days"`
+	MaxRenewals int    `json:"max_renewals" db:"max_renewals"`
+	MaxLoans    int    `json:"max_loans" db:"max_loans"`
+	// HoldPickupDays is how long a copy is kept for a member's ready hold
+	HoldPickupDays int `json:"hold_pickup_days" db:"hold_pickup_days"`
+	// Overdue fines in minor currency units: FineDailyRate for each day
+	// late beyond the first FineGraceDays, at most FineCap per loan (0
+	// means no cap). Fines are charged when the loan is returned.
+	// Checkouts are refused while a member's balance is over MaxBalance.
+	FineDailyRate int64     `json:"fine_daily_rate" db:"fine_daily_rate"`
+	FineGraceDays int       `json:"fine_grace_days" db:"fine_grace_days"`
+	FineCap       int64     `json:"fine_cap" db:"fine_cap"`
+	MaxBalance    int64     `json:"max_balance" db:"max_balance"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Fine returns the overdue fine for a loan returned daysOverdue days late.
+// Grace days are never charged, so a loan returned one day after the
+// grace period pays for one day.
+func (p *LoanPolicy) Fine(daysOverdue int) int64 {
+	if daysOverdue <= p.FineGraceDays {
+		return 0
+	}
+	fine := int64(daysOverdue-p.FineGraceDays) * p.FineDailyRate
+	if p.FineCap > 0 && fine > p.FineCap {
+		fine = p.FineCap
+	}
+	return fine
+}
+
+// DefaultLoanPolicyRole names the fallback loan policy
+const DefaultLoanPolicyRole = "default"
+
+// UpdateLoanPolicyRequest represents the request payload for creating or
+// replacing a loan policy
+type UpdateLoanPolicyRequest struct {
+	LoanDays    int `json:"loan_days" validate:"required,min=1"`
+	RenewalDays int `json:"renewal_days" validate:"required,min=1"`
+	MaxRenewals int `json:"max_renewals" validate:"min=0"`
+	MaxLoans    int `json:"max_loans" validate:"required,min=1"`
+	// HoldPickupDays defaults to 3 when omitted
+	HoldPickupDays int `json:"hold_pickup_days,omitempty"`
+	// Fine settings in minor currency units; omitted means no fines
+	FineDailyRate int64 `json:"fine_daily_rate,omitempty"`
+	FineGraceDays int   `json:"fine_grace_days,omitempty"`
+	FineCap       int64 `json:"fine_cap,omitempty"`
+	MaxBalance    int64 `json:"max_balance,omitempty"`
+}
+
+// Loan is a copy checked out to a member
+type Loan struct {
+	ID           string     `json:"id" db:"id"`
+	CopyID       string     `json:"copy_id" db:"copy_id"`
+	BookID       string     `json:"book_id" db:"book_id"`
+	UserID       string     `json:"user_id" db:"user_id"`
+	Barcode      string     `json:"barcode" db:"barcode"`
+	BookTitle    string     `json:"book_title" db:"book_title"`
+	CheckedOutAt time.Time  `json:"checked_out_at" db:"checked_out_at"`
+	DueAt        time.Time  `json:"due_at" db:"due_at"`
+	ReturnedAt   *time.Time `json:"returned_at,omitempty" db:"returned_at"`
+	RenewalCount int        `json:"renewal_count" db:"renewal_count"`
+	CheckedOutBy string     `json:"checked_out_by,omitempty" db:"checked_out_by"`
+	Overdue      bool       `json:"overdue"`
+	DaysOverdue  int        `json:"days_overdue,omitempty"`
+	// Fine is the overdue fine charged when the loan was returned
+	Fine int64 `json:"fine,omitempty"`
+}
+
+// DaysOverdue counts the started days between due and end, or 0 if end is
+// not after due
+func DaysOverdue(due, end time.Time) int {
+	if !end.After(due) {
+		return 0
+	}
+	return int(end.Sub(due).Hours()/24) + 1
+}
+
+// SetOverdue computes Overdue and DaysOverdue as of now. A returned loan
+// keeps the lateness it was returned with.
+func (l *Loan) SetOverdue(now time.Time) {
+	end := now
+	if l.ReturnedAt != nil {
+		end = *l.ReturnedAt
+	}
+	l.Overdue = end.After(l.DueAt)
+	l.DaysOverdue = DaysOverdue(l.DueAt, end)
+}
+
+// Loan statuses accepted by the list filter
+const (
+	LoanStatusActive   = "active"
+	LoanStatusOverdue  = "overdue"
+	LoanStatusReturned = "returned"
+)
+
+// LoanFilter narrows the loan list; zero values mean "no filter"
+type LoanFilter struct {
+	UserID string
+	BookID string
+	Status string
+}
+
+// CheckoutRequest represents the request payload for lending a copy. The
+// copy is identified by CopyID or Barcode.
+type CheckoutRequest struct {
+	CopyID  string `json:"copy_id,omitempty"`
+	Barcode string `json:"barcode,omitempty"`
+	UserID  string `json:"user_id" validate:"required"`
+}
+
+// ReturnRequest represents the optional payload for returning a copy
+type ReturnRequest struct {
+	Condition string `json:"condition,omitempty"`
+}
+
+// Hold is a member's place in the queue for a book. A waiting hold becomes
+// ready when a copy is set aside for the member, who then has until
+// ExpiresAt to pick it up.
+type Hold struct {
+	ID        string     `json:"id" db:"id"`
+	BookID    string     `json:"book_id" db:"book_id"`
+	BookTitle string     `json:"book_title" db:"book_title"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	Status    string     `json:"status" db:"status"`
+	Position  int        `json:"position,omitempty"`
+	CopyID    string     `json:"copy_id,omitempty" db:"copy_id"`
+	Barcode   string     `json:"barcode,omitempty" db:"barcode"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ReadyAt   *time.Time `json:"ready_at,omitempty" db:"ready_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty" db:"closed_at"`
+}
+
+// Hold statuses; waiting and ready holds are open
+const (
+	HoldStatusWaiting   = "waiting"
+	HoldStatusReady     = "ready"
+	HoldStatusFulfilled = "fulfilled"
+	HoldStatusCancelled = "cancelled"
+	HoldStatusExpired   = "expired"
+)
+
+// HoldStatuses lists the accepted values of the hold status filter
+var HoldStatuses = []string{HoldStatusWaiting, HoldStatusReady, HoldStatusFulfilled, HoldStatusCancelled, HoldStatusExpired}
+
+// HoldFilter narrows the hold list; zero values mean "no filter"
+type HoldFilter struct {
+	UserID string
+	BookID string
+	Status string
+}
+
+// PlaceHoldRequest represents the optional payload for placing a hold;
+// admins may place a hold for another member
+type PlaceHoldRequest struct {
+	UserID string `json:"user_id,omitempty"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoanPolicyFine(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate, cap   int64
+		grace, days int
+		want        int64
+	}{
+		{"on time", 1000, 0, 0, 0, 0},
+		{"one day late", 1000, 0, 0, 1, 1000},
+		{"within grace", 1000, 0, 2, 2, 0},
+		{"first day after grace", 1000, 0, 2, 3, 1000},
+		{"grace days are not charged", 1000, 0, 2, 10, 8000},
+		{"under the cap", 1000, 5000, 0, 4, 4000},
+		{"at the cap", 1000, 5000, 0, 5, 5000},
+		{"over the cap", 1000, 5000, 0, 30, 5000},
+		{"cap after grace", 1000, 5000, 3, 9, 5000},
+		{"cap not reached because of grace", 1000, 5000, 3, 7, 4000},
+		{"no fines", 0, 0, 0, 30, 0},
+	}
+	for _, tt := range tests {
+		p := &LoanPolicy{FineDailyRate: tt.rate, FineGraceDays: tt.grace, FineCap: tt.cap}
+		if got := p.Fine(tt.days); got != tt.want {
+			t.Errorf("%s: Fine(%d) = %d, want %d", tt.name, tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestDaysOverdue(t *testing.T) {
+	due := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		end  time.Time
+		want int
+	}{
+		{due.Add(-time.Hour), 0},
+		{due, 0},
+		{due.Add(time.Minute), 1},
+		{due.Add(24 * time.Hour), 2},
+		{due.Add(72*time.Hour - time.Second), 3},
+	}
+	for _, tt := range tests {
+		if got := DaysOverdue(due, tt.end); got != tt.want {
+			t.Errorf("DaysOverdue(%v) = %d, want %d", tt.end.Sub(due), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import "strings"
+
+// CoverSize is a thumbnail generated for every uploaded cover
+type CoverSize struct {
+	Name  string
+	Width int
+}
+
+// CoverSizes are the thumbnails generated for a cover, smallest first
+var CoverSizes = []CoverSize{
+	{Name: "small", Width: 160},
+	{Name: "medium", Width: 320},
+	{Name: "large", Width: 640},
+}
+
+// CoverBaseURL is the URL prefix covers are served under. It defaults to
+// the API's own /covers route and may point at a CDN or bucket instead.
+var CoverBaseURL = "/covers"
+
+// CoverOriginalName is the file name of the uploaded image for a format
+func CoverOriginalName(format string) string {
+	return "original." + format
+}
+
+// CoverThumbnailName is the file name of a thumbnail; thumbnails are
+// always JPEG
+func CoverThumbnailName(size string) string {
+	return size + ".jpg"
+}
+
+// CoverURLFor returns the public URL of a cover file
+func CoverURLFor(hash, name string) string {
+	return strings.TrimSuffix(CoverBaseURL, "/") + "/" + hash + "/" + name
+}
+
+// SetCoverURLs fills in CoverURL and CoverThumbnails from the cover hash.
+// The URLs change whenever the image does, so they can be cached forever.
+func (b *Book) SetCoverURLs() {
+	b.CoverURL = ""
+	b.CoverThumbnails = nil
+	if b.CoverHash == "" {
+		return
+	}
+	b.CoverURL = CoverURLFor(b.CoverHash, CoverOriginalName(b.CoverFormat))
+	b.CoverThumbnails = make(map[string]string, len(CoverSizes))
+	for _, size := range CoverSizes {
+		b.CoverThumbnails[size.Name] = CoverURLFor(b.CoverHash, CoverThumbnailName(size.Name))
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// UserIdentity links an external identity provider account to a user
+type UserIdentity struct {
+	ID        string     `json:"id" db:"id"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	Issuer    string     `json:"issuer" db:"issuer"`
+	Subject   string     `json:"subject" db:"subject"`
+	Email     string     `json:"email" db:"email"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	LastLogin *time.Time `json:"last_login,omitempty" db:"last_login"`
+}
+
+// OIDCLoginState holds the per-login secrets kept between the redirect to
+// the identity provider and the callback
+type OIDCLoginState struct {
+	State        string    `json:"state" db:"state"`
+	Nonce        string    `json:"-" db:"nonce"`
+	CodeVerifier string    `json:"-" db:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
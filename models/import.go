@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Import file formats accepted by POST /api/books/import
+const (
+	ImportFormatCSV     = "csv"
+	ImportFormatJSONL   = "jsonl"
+	ImportFormatMARC    = "marc"
+	ImportFormatMARCXML = "marcxml"
+)
+
+var ImportFormats = []string{ImportFormatCSV, ImportFormatJSONL, ImportFormatMARC, ImportFormatMARCXML}
+
+// Import job statuses
+const (
+	ImportStatusQueued    = "queued"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+// MaxImportErrors bounds the row errors kept in a report; Failed still
+// counts every failed row
+const MaxImportErrors = 1000
+
+// ImportRowError explains why one row of an import was not saved. Row is
+// the line number for CSV and JSON Lines and the record number for MARC.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	ISBN    string `json:"isbn,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportReport counts the outcome of an import's rows
+type ImportReport struct {
+	Total           int              `json:"total"`
+	Processed       int              `json:"processed"`
+	Created         int              `json:"created"`
+	Updated         int              `json:"updated"`
+	Failed          int              `json:"failed"`
+	Errors          []ImportRowError `json:"errors"`
+	ErrorsTruncated bool             `json:"errors_truncated,omitempty"`
+}
+
+// AddError records a failed row
+func (r *ImportReport) AddError(row int, isbn, message string) {
+	r.Failed++
+	if len(r.Errors) >= MaxImportErrors {
+		r.ErrorsTruncated = true
+		return
+	}
+	r.Errors = append(r.Errors, ImportRowError{Row: row, ISBN: isbn, Message: message})
+}
+
+// ImportJob is an import running in the background. Its report is updated
+// as batches are saved so clients can poll for progress.
+type ImportJob struct {
+	ID       string `json:"id"`
+	UserID   string `json:"user_id"`
+	Status   string `json:"status"`
+	Format   string `json:"format"`
+	FileName string `json:"file_name,omitempty"`
+	DryRun   bool   `json:"dry_run"`
+	Upsert   bool   `json:"upsert"`
+	ImportReport
+	// Message explains why a failed job stopped
+	Message    string     `json:"message,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// NewImportJob creates a queued job for total rows
+func NewImportJob(userID, format, fileName string, dryRun, upsert bool, total int) *ImportJob {
+	return &ImportJob{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Status:       ImportStatusQueued,
+		Format:       format,
+		FileName:     fileName,
+		DryRun:       dryRun,
+		Upsert:       upsert,
+		ImportReport: ImportReport{Total: total, Errors: []ImportRowError{}},
+		CreatedAt:    time.Now(),
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Account entry types. Charges add to a member's balance; payments and
+// waivers reduce it.
+const (
+	EntryTypeCharge  = "charge"
+	EntryTypePayment = "payment"
+	EntryTypeWaiver  = "waiver"
+)
+
+// AccountEntry is one line of a member's append-only ledger. Amounts are in
+// minor currency units: positive for charges, negative for payments and
+// waivers.
+type AccountEntry struct {
+	ID     string `json:"id" db:"id"`
+	UserID string `json:"user_id" db:"user_id"`
+	LoanID string `json:"loan_id,omitempty" db:"loan_id"`
+	Type   string `json:"type" db:"type"`
+	Amount int64  `json:"amount" db:"amount"`
+	// Balance is the running balance after this entry
+	Balance   int64     `json:"balance"`
+	Note      string    `json:"note,omitempty" db:"note"`
+	CreatedBy string    `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Account is a member's balance and ledger history, newest entry first
+type Account struct {
+	UserID   string          `json:"user_id"`
+	Balance  int64           `json:"balance"`
+	Currency string          `json:"currency"`
+	Entries  []*AccountEntry `json:"entries"`
+}
+
+// AccountCreditRequest represents the request payload for recording a
+// payment or waiver. Amount is positive, in minor currency units.
+type AccountCreditRequest struct {
+	Amount int64  `json:"amount" validate:"required,min=1"`
+	Note   string `json:"note,omitempty"`
+	LoanID string `json:"loan_id,omitempty"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// UserMFA holds a user's TOTP enrollment
+type UserMFA struct {
+	UserID       string     `json:"user_id" db:"user_id"`
+	Secret       string     `json:"-" db:"secret"`
+	Enabled      bool       `json:"enabled" db:"enabled"`
+	LastUsedStep int64      `json:"-" db:"last_used_step"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// MFAChallenge is the short-lived token issued between password and TOTP
+// verification. Only the hash of the token is stored.
+type MFAChallenge struct {
+	ID        string    `json:"id" db:"id"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Attempts  int       `json:"attempts" db:"attempts"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// MFAPolicy lists the roles that must use two-factor authentication
+type MFAPolicy struct {
+	RequiredRoles []string `json:"required_roles"`
+}
+
+// MFALoginRequest represents the second login step payload. Either a TOTP
+// code or a recovery code must be given.
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// MFACodeRequest carries a TOTP code for confirming or changing enrollment
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Aggregates whose changes are published through the outbox
+const (
+	AggregateBook = "book"
+	AggregateUser = "user"
+)
+
+// OutboxEvent is a change event written to the outbox in the transaction
+// of the change and published afterwards by the relay. Delivery is at
+// least once: consumers drop duplicates by ID. Sequence grows with every
+// event, so events of one aggregate can be put in order by it.
+type OutboxEvent struct {
+	ID            string          `json:"id"`
+	Sequence      int64           `json:"sequence"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	// Type is the aggregate type and what happened to it, such as
+	// "book.created" or "user.updated"
+	Type string `json:"type"`
+	// Payload is the state of the aggregate after the change, or before
+	// it when it was removed
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// ReadingList is a member's named, ordered list of books such as "to read"
+// or "favorites"
+type ReadingList struct {
+	ID          string `json:"id" db:"id"`
+	UserID      string `json:"user_id" db:"user_id"`
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description,omitempty" db:"description"`
+	Visibility  string `json:"visibility" db:"visibility"`
+	// ShareToken opens a shared list without logging in; it is only shown
+	// to the owner
+	ShareToken string `json:"share_token,omitempty" db:"share_token"`
+	// ItemCount counts the books in the list that are not deleted
+	ItemCount int                `json:"item_count"`
+	Items     []*ReadingListItem `json:"items,omitempty"`
+	CreatedAt time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+// Reading list visibilities. Shared lists can be opened by anyone with the
+// share link; public lists by any logged-in user.
+const (
+	ListVisibilityPrivate = "private"
+	ListVisibilityShared  = "shared"
+	ListVisibilityPublic  = "public"
+)
+
+// ListVisibilities lists the accepted values of ReadingList.Visibility
+var ListVisibilities = []string{ListVisibilityPrivate, ListVisibilityShared, ListVisibilityPublic}
+
+// ReadingListItem is a book in a reading list. Books that are soft-deleted
+// stay in the list but are hidden until they are restored.
+type ReadingListItem struct {
+	BookID   string    `json:"book_id" db:"book_id"`
+	Position int       `json:"position" db:"position"`
+	Note     string    `json:"note,omitempty" db:"note"`
+	AddedAt  time.Time `json:"added_at" db:"added_at"`
+	Book     *Book     `json:"book,omitempty"`
+}
+
+// ReadingListRequest represents the request payload for creating or
+// updating a reading list
+type ReadingListRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description,omitempty"`
+	Visibility  string `json:"visibility,omitempty"`
+}
+
+// ReadingListItemRequest represents the request payload for adding a book
+// to a reading list or editing its entry. Position is 1-based; 0 appends
+// the book when adding and keeps its place when editing.
+type ReadingListItemRequest struct {
+	BookID   string  `json:"book_id,omitempty"`
+	Note     *string `json:"note,omitempty"`
+	Position int     `json:"position,omitempty"`
+}
+
+// ReorderListRequest represents the request payload for reordering a
+// reading list: every visible book ID in the new order
+type ReorderListRequest struct {
+	BookIDs []string `json:"book_ids" validate:"required"`
+}
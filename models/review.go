@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Review is a member's 1–5 star rating of a book with optional text. Only
+// approved reviews count towards the book's average rating.
+type Review struct {
+	ID          string     `json:"id" db:"id"`
+	BookID      string     `json:"book_id" db:"book_id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Username    string     `json:"username" db:"username"`
+	Rating      int        `json:"rating" db:"rating"`
+	Title       string     `json:"title,omitempty" db:"title"`
+	Body        string     `json:"body,omitempty" db:"body"`
+	Status      string     `json:"status" db:"status"`
+	ModeratedBy string     `json:"moderated_by,omitempty" db:"moderated_by"`
+	ModeratedAt *time.Time `json:"moderated_at,omitempty" db:"moderated_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Review moderation statuses
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusHidden   = "hidden"
+)
+
+// ReviewStatuses lists the accepted values of Review.Status
+var ReviewStatuses = []string{ReviewStatusPending, ReviewStatusApproved, ReviewStatusHidden}
+
+// ReviewRequest represents the request payload for writing or editing a
+// review
+type ReviewRequest struct {
+	Rating int    `json:"rating" validate:"required,min=1,max=5"`
+	Title  string `json:"title,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+// ModerateReviewRequest represents the request payload for setting the
+// moderation status of a review
+type ModerateReviewRequest struct {
+	Status string `json:"status" validate:"required"`
+}
+
+// ReviewFilter narrows the review list; zero values mean "no filter"
+type ReviewFilter struct {
+	BookID string
+	UserID string
+	Status string
+	// VisibleTo, if set, limits the list to approved reviews plus the
+	// reviews of this user
+	VisibleTo string
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// BookSnapshot holds the editable fields of a book as stored in a
+// revision. Ratings, covers and availability are not part of it.
+type BookSnapshot struct {
+	Judul       string        `json:"judul"`
+	Author      string        `json:"author"`
+	TahunTerbit int           `json:"tahun_terbit"`
+	ISBN        string        `json:"isbn"`
+	Publisher   string        `json:"publisher"`
+	Language    string        `json:"language"`
+	Pages       int           `json:"pages"`
+	Description string        `json:"description"`
+	Edition     string        `json:"edition"`
+	Format      string        `json:"format"`
+	Authors     []BookAuthor  `json:"authors"`
+	Categories  []CategoryRef `json:"categories"`
+	Tags        []string      `json:"tags"`
+}
+
+// NewBookSnapshot takes the editable fields of b. Missing relations are
+// stored as empty lists, so they compare equal to removed ones.
+func NewBookSnapshot(b *Book) *BookSnapshot {
+	return &BookSnapshot{
+		Judul:       b.Judul,
+		Author:      b.Author,
+		TahunTerbit: b.TahunTerbit,
+		ISBN:        b.ISBN,
+		Publisher:   b.Publisher,
+		Language:    b.Language,
+		Pages:       b.Pages,
+		Description: b.Description,
+		Edition:     b.Edition,
+		Format:      b.Format,
+		Authors:     append([]BookAuthor{}, b.Authors...),
+		Categories:  append([]CategoryRef{}, b.Categories...),
+		Tags:        append([]string{}, b.Tags...),
+	}
+}
+
+// Apply sets the editable fields of b to those of the snapshot
+func (s *BookSnapshot) Apply(b *Book) {
+	b.Judul = s.Judul
+	b.Author = s.Author
+	b.TahunTerbit = s.TahunTerbit
+	b.ISBN = s.ISBN
+	b.Publisher = s.Publisher
+	b.Language = s.Language
+	b.Pages = s.Pages
+	b.Description = s.Description
+	b.Edition = s.Edition
+	b.Format = s.Format
+	b.Authors = append([]BookAuthor{}, s.Authors...)
+	b.Categories = append([]CategoryRef{}, s.Categories...)
+	b.Tags = append([]string{}, s.Tags...)
+}
+
+// BookFieldChange is one field that differs between two revisions
+type BookFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// DiffBookSnapshots lists the fields that differ from one snapshot to the
+// next, in field order. A nil snapshot is a book that did not exist yet.
+func DiffBookSnapshots(from, to *BookSnapshot) []BookFieldChange {
+	if from == nil {
+		from = &BookSnapshot{}
+	}
+	if to == nil {
+		to = &BookSnapshot{}
+	}
+	changes := []BookFieldChange{}
+	a, b := reflect.ValueOf(from).Elem(), reflect.ValueOf(to).Elem()
+	for i := 0; i < a.NumField(); i++ {
+		x, y := a.Field(i).Interface(), b.Field(i).Interface()
+		if reflect.DeepEqual(x, y) {
+			continue
+		}
+		name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("json"), ",")
+		changes = append(changes, BookFieldChange{Field: name, From: x, To: y})
+	}
+	return changes
+}
+
+// BookRevision is a stored state of a book. Every create, update and
+// revert adds one, numbered from 1; reverting adds a new revision copying
+// an old one, so history is never rewritten.
+type BookRevision struct {
+	BookID   string `json:"book_id"`
+	Revision int    `json:"revision"`
+	// ChangedByID is the user who made the change; ChangedBy is their
+	// username. Both are empty for changes made by the system, and for
+	// the state a book had before revisions were kept.
+	ChangedByID string `json:"changed_by_id,omitempty"`
+	ChangedBy   string `json:"changed_by,omitempty"`
+	// RevertedFrom is the revision this one restores, if any
+	RevertedFrom int `json:"reverted_from,omitempty"`
+	// ChangedFields are the fields that differ from the previous revision
+	ChangedFields []string      `json:"changed_fields"`
+	Book          *BookSnapshot `json:"book,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// BookRevisionDiff is the field-level difference between two revisions
+type BookRevisionDiff struct {
+	BookID  string            `json:"book_id"`
+	From    int               `json:"from"`
+	To      int               `json:"to"`
+	Changes []BookFieldChange `json:"changes"`
+}
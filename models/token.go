@@ -11,3 +11,14 @@ type Token struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	IsRevoked bool      `json:"is_revoked" db:"is_revoked"`
 }
+
+// LiveTicket is a short-lived, single-use ticket that opens the live feed
+// for a session, for browsers that cannot send the session token in a
+// header. Only the hash of the ticket is stored.
+type LiveTicket struct {
+	ID        string    `json:"id" db:"id"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	SessionID string    `json:"session_id" db:"session_id"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password accepted for an account
+const MinPasswordLength = 8
+
+// MaxPasswordLength is the longest password accepted, in bytes: bcrypt
+// ignores anything after the first 72
+const MaxPasswordLength = 72
+
+// DefaultUserRole is the role of accounts created without one
+const DefaultUserRole = "user"
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,50}$`)
+
+// CreateUserRequest represents the request payload for creating a user
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// Validate trims the username, defaults the role and checks the request.
+// It returns an error message, or "" if the request is valid.
+func (r *CreateUserRequest) Validate() string {
+	r.Username = strings.TrimSpace(r.Username)
+	r.Email = strings.TrimSpace(r.Email)
+	if r.Role == "" {
+		r.Role = DefaultUserRole
+	}
+	if !usernamePattern.MatchString(r.Username) {
+		return "Username must be 1-50 letters, digits, dots, underscores or hyphens"
+	}
+	if msg := PasswordError(r.Password); msg != "" {
+		return msg
+	}
+	switch {
+	case len(r.Email) > 255 || (r.Email != "" && !strings.Contains(r.Email, "@")):
+		return "Invalid email"
+	case len(r.Role) > 20:
+		return "Role is too long"
+	}
+	return ""
+}
+
+// NewUser creates an active user from a validated request
+func NewUser(req CreateUserRequest) *User {
+	now := time.Now()
+	return &User{
+		ID:        uuid.New().String(),
+		Username:  req.Username,
+		Password:  req.Password,
+		Email:     req.Email,
+		Role:      req.Role,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// PasswordError checks the length of a new password. It returns an error
+// message, or "" if the password is acceptable.
+func PasswordError(password string) string {
+	switch {
+	case len(password) < MinPasswordLength:
+		return "Password must be at least 8 characters"
+	case len(password) > MaxPasswordLength:
+		return "Password must be at most 72 bytes"
+	}
+	return ""
+}
+
+// HashPassword hashes a password with bcrypt for storing
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches the stored hash of u
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) == nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordError(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"", "Password must be at least 8 characters"},
+		{"1234567", "Password must be at least 8 characters"},
+		{"12345678", ""},
+		{strings.Repeat("x", 72), ""},
+		{strings.Repeat("x", 73), "Password must be at most 72 bytes"},
+		// The limit is in bytes: 24 three-byte runes fit, 25 do not
+		{strings.Repeat("€", 24), ""},
+		{strings.Repeat("€", 25), "Password must be at most 72 bytes"},
+	}
+	for _, tt := range tests {
+		if got := PasswordError(tt.password); got != tt.want {
+			t.Errorf("PasswordError(%d bytes) = %q, want %q", len(tt.password), got, tt.want)
+		}
+	}
+
+	req := &CreateUserRequest{Username: "alice", Password: strings.Repeat("x", 73)}
+	if got := req.Validate(); got != "Password must be at most 72 bytes" {
+		t.Errorf("Validate with a long password = %q", got)
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$2a$") || strings.Contains(hash, "correct horse") {
+		t.Errorf("hash %q", hash)
+	}
+	again, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == hash {
+		t.Error("hashes of the same password are equal, the salt is not random")
+	}
+
+	u := &User{Password: hash}
+	for password, want := range map[string]bool{
+		"correct horse":  true,
+		"correct horse ": false,
+		"Correct horse":  false,
+		"":               false,
+	} {
+		if got := u.CheckPassword(password); got != want {
+			t.Errorf("CheckPassword(%q) = %v, want %v", password, got, want)
+		}
+	}
+
+	// A password stored in plaintext never matches
+	if (&User{Password: "correct horse"}).CheckPassword("correct horse") {
+		t.Error("a plaintext password matched")
+	}
+	if _, err := HashPassword(strings.Repeat("x", 73)); err == nil {
+		t.Error("hashed a password longer than bcrypt reads")
+	}
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types. A subscription may also filter on "book.*",
+// "loan.*", "hold.*" or "*".
+const (
+	WebhookEventBookCreated  = "book.created"
+	WebhookEventBookUpdated  = "book.updated"
+	WebhookEventBookDeleted  = "book.deleted"
+	WebhookEventBookRestored = "book.restored"
+	WebhookEventLoanCreated  = "loan.created"
+	WebhookEventLoanRenewed  = "loan.renewed"
+	WebhookEventLoanReturned = "loan.returned"
+	WebhookEventHoldReady    = "hold.ready"
+	WebhookEventHoldExpired  = "hold.expired"
+)
+
+// WebhookEvents lists the event types subscriptions can filter on
+var WebhookEvents = []string{
+	WebhookEventBookCreated, WebhookEventBookUpdated, WebhookEventBookDeleted, WebhookEventBookRestored,
+	WebhookEventLoanCreated, WebhookEventLoanRenewed, WebhookEventLoanReturned,
+	WebhookEventHoldReady, WebhookEventHoldExpired,
+}
+
+// Webhook delivery statuses. A pending delivery is retried with backoff
+// until it is delivered or runs out of attempts and fails.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
+
+var DeliveryStatuses = []string{DeliveryStatusPending, DeliveryStatusDelivered, DeliveryStatusFailed}
+
+// WebhookSubscription is an endpoint that receives events as signed POST
+// requests. It is disabled automatically after too many failed deliveries
+// in a row.
+type WebhookSubscription struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description,omitempty"`
+	// Secret signs deliveries; it is only shown when it is set
+	Secret              string     `json:"secret,omitempty"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason      string     `json:"disabled_reason,omitempty"`
+	CreatedBy           string     `json:"created_by,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// WebhookRequest represents the request payload for creating or updating a
+// webhook subscription. Omitted fields keep their value on update; a
+// missing secret is generated on create.
+type WebhookRequest struct {
+	URL         *string  `json:"url,omitempty"`
+	Events      []string `json:"events,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	Secret      *string  `json:"secret,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// WebhookPayload is the JSON body of a delivery. ID identifies the event
+// and stays the same across retries and replays, so receivers can drop
+// duplicates.
+type WebhookPayload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookDelivery is one event queued for one subscription, with the
+// outcome of its latest attempt
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	// ReplayOf is the delivery this one was replayed from
+	ReplayOf  string    `json:"replay_of,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookTarget is a delivery claimed for sending, with the endpoint it
+// goes to
+type WebhookTarget struct {
+	Delivery *WebhookDelivery
+	URL      string
+	Secret   string
+}
@@ -0,0 +1,206 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// JSONWebKey is a single RSA public key as published in a JWKS document
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JSONWebKeySet is the document served at the provider's jwks_uri
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// IDTokenClaims holds the ID token claims this service cares about
+type IDTokenClaims struct {
+	Issuer            string    `json:"iss"`
+	Subject           string    `json:"sub"`
+	Audience          audience  `json:"aud"`
+	AuthorizedParty   string    `json:"azp,omitempty"`
+	ExpiresAt         int64     `json:"exp"`
+	IssuedAt          int64     `json:"iat"`
+	Nonce             string    `json:"nonce,omitempty"`
+	Email             string    `json:"email,omitempty"`
+	EmailVerified     bool      `json:"email_verified,omitempty"`
+	Name              string    `json:"name,omitempty"`
+	PreferredUsername string    `json:"preferred_username,omitempty"`
+	Raw               rawClaims `json:"-"`
+}
+
+// audience accepts both the string and the array form of the aud claim
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("invalid aud claim: %w", err)
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(v string) bool {
+	for _, item := range a {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// rawClaims keeps every claim so custom ones such as groups can be read
+type rawClaims map[string]interface{}
+
+// Strings returns a claim as a string slice, accepting a single string too
+func (c rawClaims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// PublicKey converts the JWK into an RSA public key
+func (k JSONWebKey) PublicKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// NewJSONWebKey builds the public JWK for an RSA key
+func NewJSONWebKey(kid string, pub *rsa.PublicKey) JSONWebKey {
+	return JSONWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+// SignRS256 produces a compact JWT signed with RS256
+func SignRS256(key *rsa.PrivateKey, kid string, claims interface{}) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "RS256", Kid: kid, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// parseJWT splits a compact JWT and decodes its header and claims
+func parseJWT(token string) (*jwtHeader, *IDTokenClaims, string, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, "", nil, fmt.Errorf("malformed token")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+
+	header := &jwtHeader{}
+	if err := json.Unmarshal(headerJSON, header); err != nil {
+		return nil, nil, "", nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	claims := &IDTokenClaims{}
+	if err := json.Unmarshal(payloadJSON, claims); err != nil {
+		return nil, nil, "", nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err := json.Unmarshal(payloadJSON, &claims.Raw); err != nil {
+		return nil, nil, "", nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	return header, claims, parts[0] + "." + parts[1], sig, nil
+}
+
+// verifyClaims checks the standard ID token claims (OIDC Core 3.1.3.7)
+func verifyClaims(claims *IDTokenClaims, issuer, clientID, nonce string, now time.Time, skew time.Duration) error {
+	if claims.Issuer != issuer {
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if !claims.Audience.contains(clientID) {
+		return fmt.Errorf("token not issued for this client")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != clientID {
+		return fmt.Errorf("unexpected authorized party %q", claims.AuthorizedParty)
+	}
+	if claims.Subject == "" {
+		return fmt.Errorf("missing subject")
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(skew)) {
+		return fmt.Errorf("token expired")
+	}
+	if claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(skew)) {
+		return fmt.Errorf("token issued in the future")
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return fmt.Errorf("nonce mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockUser is an identity known to the mock identity provider
+type MockUser struct {
+	Subject           string
+	PreferredUsername string
+	Name              string
+	Email             string
+	EmailVerified     bool
+	Groups            []string
+}
+
+// MockProvider is an in-process OpenID Connect provider for development
+// and offline testing. It implements discovery, JWKS, an authorization
+// endpoint that signs in a user picked by login_hint without a password,
+// and a token endpoint that enforces PKCE (S256).
+type MockProvider struct {
+	Issuer   string
+	ClientID string
+	// TokenTTL controls the lifetime of issued ID tokens
+	TokenTTL time.Duration
+
+	key *rsa.PrivateKey
+	kid string
+
+	mu    sync.Mutex
+	users map[string]MockUser
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	user          MockUser
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+// NewMockProvider creates a mock provider with a freshly generated key.
+// The issuer must be the absolute URL the handler is reachable at.
+func NewMockProvider(issuer, clientID string, users ...MockUser) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	kid, err := RandomString(8)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MockProvider{
+		Issuer:   strings.TrimSuffix(issuer, "/"),
+		ClientID: clientID,
+		TokenTTL: 5 * time.Minute,
+		key:      key,
+		kid:      kid,
+		users:    map[string]MockUser{},
+		codes:    map[string]mockGrant{},
+	}
+	for _, u := range users {
+		m.AddUser(u)
+	}
+	return m, nil
+}
+
+// AddUser registers (or replaces) a user, keyed by preferred username
+func (m *MockProvider) AddUser(u MockUser) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.PreferredUsername] = u
+}
+
+// Handler serves the provider endpoints relative to the issuer URL. When
+// mounted under a path prefix, strip that prefix before calling it.
+func (m *MockProvider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.handleDiscovery)
+	mux.HandleFunc("/jwks", m.handleJWKS)
+	mux.HandleFunc("/authorize", m.handleAuthorize)
+	mux.HandleFunc("/token", m.handleToken)
+	return mux
+}
+
+func (m *MockProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                m.Issuer,
+		"authorization_endpoint":                m.Issuer + "/authorize",
+		"token_endpoint":                        m.Issuer + "/token",
+		"jwks_uri":                              m.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "profile", "email", "groups"},
+	})
+}
+
+func (m *MockProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JSONWebKeySet{
+		Keys: []JSONWebKey{NewJSONWebKey(m.kid, &m.key.PublicKey)},
+	})
+}
+
+// handleAuthorize signs in the user named by login_hint, or lists the
+// known users as links when no hint is given
+func (m *MockProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" {
+		http.Error(w, "unsupported response_type", http.StatusBadRequest)
+		return
+	}
+	if q.Get("client_id") != m.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	hint := q.Get("login_hint")
+	m.mu.Lock()
+	user, ok := m.users[hint]
+	m.mu.Unlock()
+	if !ok {
+		m.renderUserPicker(w, r)
+		return
+	}
+
+	code, err := RandomString(24)
+	if err != nil {
+		http.Error(w, "failed to issue code", http.StatusInternalServerError)
+		return
+	}
+	m.mu.Lock()
+	m.codes[code] = mockGrant{
+		user:          user,
+		clientID:      q.Get("client_id"),
+		redirectURI:   q.Get("redirect_uri"),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	m.mu.Unlock()
+
+	back := redirectURI.Query()
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	redirectURI.RawQuery = back.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (m *MockProvider) renderUserPicker(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprint(w, "<!DOCTYPE html><html><head><title>Mock IdP</title></head><body><h1>Mock IdP sign-in</h1><ul>")
+	for name, u := range m.users {
+		q := r.URL.Query()
+		q.Set("login_hint", name)
+		fmt.Fprintf(w, `<li><a href="?%s">%s</a> (%s)</li>`, html.EscapeString(q.Encode()), html.EscapeString(name), html.EscapeString(strings.Join(u.Groups, ", ")))
+	}
+	fmt.Fprint(w, "</ul></body></html>")
+}
+
+func (m *MockProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, "invalid_request", "malformed form body")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeTokenError(w, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	m.mu.Lock()
+	grant, ok := m.codes[code]
+	delete(m.codes, code)
+	m.mu.Unlock()
+
+	clientID := r.PostForm.Get("client_id")
+	if basicID, _, hasBasic := r.BasicAuth(); hasBasic {
+		clientID, _ = url.QueryUnescape(basicID)
+	}
+
+	switch {
+	case !ok || time.Now().After(grant.expiresAt):
+		writeTokenError(w, "invalid_grant", "unknown or expired code")
+		return
+	case clientID != grant.clientID:
+		writeTokenError(w, "invalid_grant", "client mismatch")
+		return
+	case r.PostForm.Get("redirect_uri") != grant.redirectURI:
+		writeTokenError(w, "invalid_grant", "redirect_uri mismatch")
+		return
+	case subtle.ConstantTimeCompare([]byte(CodeChallengeS256(r.PostForm.Get("code_verifier"))), []byte(grant.codeChallenge)) != 1:
+		writeTokenError(w, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	idToken, err := m.SignIDToken(grant.user, grant.nonce)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	accessToken, _ := RandomString(24)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(m.TokenTTL.Seconds()),
+		IDToken:     idToken,
+	})
+}
+
+// SignIDToken issues an ID token for user, as the token endpoint would
+func (m *MockProvider) SignIDToken(user MockUser, nonce string) (string, error) {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":                m.Issuer,
+		"sub":                user.Subject,
+		"aud":                m.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(m.TokenTTL).Unix(),
+		"preferred_username": user.PreferredUsername,
+		"name":               user.Name,
+		"email":              user.Email,
+		"email_verified":     user.EmailVerified,
+		"groups":             user.Groups,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	return SignRS256(m.key, m.kid, claims)
+}
+
+func writeTokenError(w http.ResponseWriter, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
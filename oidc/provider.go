@@ -0,0 +1,334 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config holds the relying party settings for an OpenID Connect provider
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+	// GroupRoles maps an IdP group to a value of the users.role column
+	GroupRoles  map[string]string
+	DefaultRole string
+}
+
+// Enabled reports whether enough settings are present to use the provider
+func (c *Config) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != "" && c.RedirectURL != ""
+}
+
+// GetConfig returns OIDC configuration from environment variables
+func GetConfig() *Config {
+	return &Config{
+		Issuer:       getEnv("OIDC_ISSUER", ""),
+		ClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		ClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:  getEnv("OIDC_REDIRECT_URL", "http://localhost:8080/api/login/oidc/callback"),
+		Scopes:       strings.Fields(getEnv("OIDC_SCOPES", "openid profile email groups")),
+		GroupsClaim:  getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		GroupRoles:   ParseGroupRoles(getEnv("OIDC_GROUP_ROLES", "library-admins=admin")),
+		DefaultRole:  getEnv("OIDC_DEFAULT_ROLE", "user"),
+	}
+}
+
+// ParseGroupRoles parses "group=role,group=role" into a lookup map
+func ParseGroupRoles(s string) map[string]string {
+	roles := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		group, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || group == "" || role == "" {
+			continue
+		}
+		roles[strings.TrimSpace(group)] = strings.TrimSpace(role)
+	}
+	return roles
+}
+
+// RoleForGroups picks the role for a set of IdP groups. "admin" wins over
+// any other mapped role; the default role applies when nothing matches.
+func (c *Config) RoleForGroups(groups []string) string {
+	role := ""
+	for _, g := range groups {
+		mapped, ok := c.GroupRoles[g]
+		if !ok {
+			continue
+		}
+		if mapped == "admin" {
+			return mapped
+		}
+		if role == "" {
+			role = mapped
+		}
+	}
+	if role == "" {
+		return c.DefaultRole
+	}
+	return role
+}
+
+// Discovery is the subset of the provider metadata document used here
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint,omitempty"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// TokenResponse is the token endpoint response of the authorization code grant
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in,omitempty"`
+	IDToken     string `json:"id_token"`
+}
+
+// Provider performs the authorization code + PKCE flow against one issuer
+type Provider struct {
+	Config *Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      map[string]*rsa.PublicKey
+	keysAt    time.Time
+}
+
+// jwksMinRefresh limits how often an unknown kid triggers a JWKS refetch
+const jwksMinRefresh = 30 * time.Second
+
+// clockSkew is the leeway applied to exp/iat checks
+const clockSkew = time.Minute
+
+// NewProvider creates a provider; discovery happens lazily on first use
+func NewProvider(config *Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{Config: config, client: client}
+}
+
+// Discover fetches and caches the provider metadata document
+func (p *Provider) Discover(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.discoverLocked(ctx)
+}
+
+func (p *Provider) discoverLocked(ctx context.Context) (*Discovery, error) {
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.Config.Issuer, "/") + "/.well-known/openid-configuration"
+	doc := &Discovery{}
+	if err := p.getJSON(ctx, wellKnown, doc); err != nil {
+		return nil, fmt.Errorf("failed to discover provider: %w", err)
+	}
+	if doc.Issuer != p.Config.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: expected %q, got %q", p.Config.Issuer, doc.Issuer)
+	}
+	p.discovery = doc
+	return doc, nil
+}
+
+// AuthCodeURL builds the authorization request URL with PKCE parameters.
+// Extra parameters such as login_hint or prompt are passed through as-is.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string, extra url.Values) (string, error) {
+	doc, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	for k, v := range extra {
+		q[k] = v
+	}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.Config.ClientID)
+	q.Set("redirect_uri", p.Config.RedirectURL)
+	q.Set("scope", strings.Join(p.Config.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallengeS256(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
+	doc, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("client_id", p.Config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	token := &TokenResponse{}
+	if err := json.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+	return token, nil
+}
+
+// VerifyIDToken checks the signature against the provider JWKS and
+// validates issuer, audience, expiry and nonce
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	header, claims, signingInput, sig, err := parseJWT(rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+
+	key, err := p.publicKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(signingInput))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig); err != nil {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	if err := verifyClaims(claims, p.Config.Issuer, p.Config.ClientID, nonce, time.Now(), clockSkew); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Groups returns the groups claim configured for this provider
+func (p *Provider) Groups(claims *IDTokenClaims) []string {
+	return claims.Raw.Strings(p.Config.GroupsClaim)
+}
+
+// publicKey returns the key for kid, refreshing the JWKS on a cache miss
+func (p *Provider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := p.lookupKeyLocked(kid); key != nil {
+		return key, nil
+	}
+	if p.keys != nil && time.Since(p.keysAt) < jwksMinRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	doc, err := p.discoverLocked(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set := &JSONWebKeySet{}
+	if err := p.getJSON(ctx, doc.JWKSURI, set); err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = pub
+	}
+	p.keys = keys
+	p.keysAt = time.Now()
+
+	if key := p.lookupKeyLocked(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *Provider) lookupKeyLocked(kid string) *rsa.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+func (p *Provider) getJSON(ctx context.Context, rawURL string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", rawURL, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// getEnv gets environment variable with fallback to default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
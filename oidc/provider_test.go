@@ -0,0 +1,226 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testUser = MockUser{
+	Subject:           "subject-1",
+	PreferredUsername: "reader",
+	Email:             "reader@example.com",
+	EmailVerified:     true,
+	Groups:            []string{"readers", "library-admins"},
+}
+
+// newTestProvider serves a mock provider and returns it with a relying
+// party configured for it
+func newTestProvider(t *testing.T) (*MockProvider, *Provider) {
+	t.Helper()
+	var handler http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	mock, err := NewMockProvider(srv.URL, "book-api", testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler = mock.Handler()
+	return mock, NewProvider(&Config{
+		Issuer:      srv.URL,
+		ClientID:    "book-api",
+		RedirectURL: "http://app.example/callback",
+		Scopes:      []string{"openid", "email"},
+		GroupsClaim: "groups",
+	}, srv.Client())
+}
+
+// claimsFor returns the claims the mock provider would sign for testUser
+func claimsFor(m *MockProvider, nonce string) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":   m.Issuer,
+		"sub":   testUser.Subject,
+		"aud":   m.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": nonce,
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	_, provider := newTestProvider(t)
+	ctx := context.Background()
+
+	verifier, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier, url.Values{"login_hint": {"reader"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d", resp.StatusCode)
+	}
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(back.String(), "http://app.example/callback?") || back.Query().Get("state") != "state-1" {
+		t.Fatalf("redirected to %s", back)
+	}
+	code := back.Query().Get("code")
+
+	if _, err := provider.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Fatal("exchange with the wrong PKCE verifier succeeded")
+	}
+	// A failed exchange uses the code up
+	if _, err := provider.Exchange(ctx, code, verifier); err == nil {
+		t.Fatal("code could be redeemed twice")
+	}
+
+	resp, err = client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	back, _ = url.Parse(resp.Header.Get("Location"))
+	tokens, err := provider.Exchange(ctx, back.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != testUser.Subject || claims.Email != testUser.Email || !claims.EmailVerified {
+		t.Errorf("claims = %+v", claims)
+	}
+	if groups := provider.Groups(claims); len(groups) != 2 || groups[1] != "library-admins" {
+		t.Errorf("groups = %v", groups)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	mock, provider := newTestProvider(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token func() (string, error)
+		want  string
+	}{
+		{"wrong audience", func() (string, error) {
+			claims := claimsFor(mock, "nonce-1")
+			claims["aud"] = "another-client"
+			return SignRS256(mock.key, mock.kid, claims)
+		}, "not issued for this client"},
+		{"audience list without azp", func() (string, error) {
+			claims := claimsFor(mock, "nonce-1")
+			claims["aud"] = []string{"another-client", mock.ClientID}
+			return SignRS256(mock.key, mock.kid, claims)
+		}, "authorized party"},
+		{"wrong issuer", func() (string, error) {
+			claims := claimsFor(mock, "nonce-1")
+			claims["iss"] = "https://evil.example"
+			return SignRS256(mock.key, mock.kid, claims)
+		}, "unexpected issuer"},
+		{"wrong nonce", func() (string, error) {
+			return SignRS256(mock.key, mock.kid, claimsFor(mock, "nonce-2"))
+		}, "nonce mismatch"},
+		{"expired", func() (string, error) {
+			claims := claimsFor(mock, "nonce-1")
+			claims["iat"] = time.Now().Add(-time.Hour).Unix()
+			claims["exp"] = time.Now().Add(-clockSkew - time.Second).Unix()
+			return SignRS256(mock.key, mock.kid, claims)
+		}, "token expired"},
+		{"issued in the future", func() (string, error) {
+			claims := claimsFor(mock, "nonce-1")
+			claims["iat"] = time.Now().Add(time.Hour).Unix()
+			return SignRS256(mock.key, mock.kid, claims)
+		}, "issued in the future"},
+		{"bad signature", func() (string, error) {
+			return SignRS256(otherKey, mock.kid, claimsFor(mock, "nonce-1"))
+		}, "invalid token signature"},
+		{"tampered claims", func() (string, error) {
+			token, err := SignRS256(mock.key, mock.kid, claimsFor(mock, "nonce-1"))
+			if err != nil {
+				return "", err
+			}
+			other, err := SignRS256(mock.key, mock.kid, map[string]interface{}{"sub": "admin"})
+			parts, otherParts := strings.Split(token, "."), strings.Split(other, ".")
+			return parts[0] + "." + otherParts[1] + "." + parts[2], err
+		}, "invalid token signature"},
+		{"unknown kid", func() (string, error) {
+			return SignRS256(otherKey, "unknown-kid", claimsFor(mock, "nonce-1"))
+		}, "unknown signing key"},
+		{"unsigned", func() (string, error) {
+			token, err := SignRS256(mock.key, mock.kid, claimsFor(mock, "nonce-1"))
+			parts := strings.Split(token, ".")
+			// {"alg":"none","kid":...} with the signature dropped
+			header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"` + mock.kid + `"}`))
+			return header + "." + parts[1] + ".", err
+		}, "unsupported signing algorithm"},
+		{"malformed", func() (string, error) { return "not-a-jwt", nil }, "malformed token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.token()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = provider.VerifyIDToken(context.Background(), token, "nonce-1")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("VerifyIDToken() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// The checks above must not have failed for a reason every token shares
+	token, err := SignRS256(mock.key, mock.kid, claimsFor(mock, "nonce-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.VerifyIDToken(context.Background(), token, "nonce-1"); err != nil {
+		t.Errorf("valid token rejected: %v", err)
+	}
+}
+
+func TestRoleForGroups(t *testing.T) {
+	config := &Config{GroupRoles: ParseGroupRoles("library-admins=admin, staff=librarian,broken"), DefaultRole: "user"}
+	tests := []struct {
+		groups []string
+		want   string
+	}{
+		{nil, "user"},
+		{[]string{"readers"}, "user"},
+		{[]string{"staff"}, "librarian"},
+		{[]string{"staff", "library-admins"}, "admin"},
+	}
+	for _, tt := range tests {
+		if got := config.RoleForGroups(tt.groups); got != tt.want {
+			t.Errorf("RoleForGroups(%v) = %q, want %q", tt.groups, got, tt.want)
+		}
+	}
+}
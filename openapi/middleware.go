@@ -0,0 +1,341 @@
+package openapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxValidatedBody is the most of a response body kept for validation;
+// longer bodies, like exports and event streams, are passed through
+// without checking their contents
+const maxValidatedBody = 4 << 20
+
+// Violation is a request or response that does not match the document
+type Violation struct {
+	Method      string
+	Path        string
+	OperationID string
+	// Status is the status of the response, or 0 when the request did not
+	// match yet was served successfully
+	Status   int
+	Problems []string
+}
+
+func (v *Violation) Error() string {
+	side := "request"
+	if v.Status != 0 {
+		side = "response " + strconv.Itoa(v.Status)
+	}
+	return fmt.Sprintf("%s %s (%s): %s does not match the OpenAPI document: %s",
+		v.Method, v.Path, v.OperationID, side, strings.Join(v.Problems, "; "))
+}
+
+// Validator checks requests and responses passing through it against a
+// document. It only reports, never changes or rejects traffic, so it can
+// wrap the router in tests, reporting to t.Error, or in a staging server.
+//
+// A request that does not match is reported only if the handler still
+// succeeded, since rejecting it with an error is what the document says
+// should happen. Requests to paths the document does not describe are
+// passed through unchecked.
+type Validator struct {
+	doc    *Document
+	paths  []pathPattern
+	report func(*Violation)
+}
+
+// pathPattern matches request paths to a path template
+type pathPattern struct {
+	template string
+	pattern  *regexp.Regexp
+	names    []string
+	literal  int
+}
+
+// NewValidator returns a validator of doc calling report for every
+// violation, or logging it if report is nil
+func NewValidator(doc *Document, report func(*Violation)) *Validator {
+	if report == nil {
+		report = func(v *Violation) { log.Print(v) }
+	}
+	val := &Validator{doc: doc, report: report}
+	for template := range doc.Paths {
+		p := pathPattern{template: template}
+		var expr strings.Builder
+		expr.WriteString("^")
+		rest := template
+		for {
+			loc := pathParamPattern.FindStringSubmatchIndex(rest)
+			if loc == nil {
+				break
+			}
+			expr.WriteString(regexp.QuoteMeta(rest[:loc[0]]))
+			expr.WriteString("([^/]+)")
+			p.literal += loc[0]
+			p.names = append(p.names, rest[loc[2]:loc[3]])
+			rest = rest[loc[1]:]
+		}
+		expr.WriteString(regexp.QuoteMeta(rest) + "$")
+		p.literal += len(rest)
+		p.pattern = regexp.MustCompile(expr.String())
+		val.paths = append(val.paths, p)
+	}
+	// Prefer templates with fewer variables and then longer literals, so
+	// /api/books/export wins over /api/books/{id}
+	sort.Slice(val.paths, func(i, j int) bool {
+		a, b := val.paths[i], val.paths[j]
+		if len(a.names) != len(b.names) {
+			return len(a.names) < len(b.names)
+		}
+		if a.literal != b.literal {
+			return a.literal > b.literal
+		}
+		return a.template < b.template
+	})
+	return val
+}
+
+// match returns the template of path and its variables
+func (v *Validator) match(path string) (string, map[string]string, bool) {
+	for _, p := range v.paths {
+		m := p.pattern.FindStringSubmatch(path)
+		if m == nil {
+			continue
+		}
+		vars := map[string]string{}
+		for i, name := range p.names {
+			vars[name] = m[i+1]
+		}
+		return p.template, vars, true
+	}
+	return "", nil, false
+}
+
+// Middleware wraps next with validation
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		template, vars, ok := v.match(r.URL.Path)
+		op := v.doc.Operation(r.Method, template)
+		if !ok || op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestProblems := v.checkRequest(op, r, vars)
+		rec := &recorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.hijacked {
+			return
+		}
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if len(requestProblems) > 0 && rec.status < 400 {
+			v.report(&Violation{Method: r.Method, Path: r.URL.Path, OperationID: op.OperationID, Problems: requestProblems})
+		}
+		if problems := v.checkResponse(op, rec); len(problems) > 0 {
+			v.report(&Violation{Method: r.Method, Path: r.URL.Path, OperationID: op.OperationID, Status: rec.status, Problems: problems})
+		}
+	})
+}
+
+func (v *Validator) checkRequest(op *Operation, r *http.Request, vars map[string]string) []string {
+	var problems []string
+	query := r.URL.Query()
+	documented := map[string]bool{}
+	for _, p := range op.Parameters {
+		var raw string
+		switch p.In {
+		case "path":
+			raw = vars[p.Name]
+		case "query":
+			documented[p.Name] = true
+			if !query.Has(p.Name) {
+				if p.Required {
+					problems = append(problems, "missing query parameter "+p.Name)
+				}
+				continue
+			}
+			raw = query.Get(p.Name)
+		case "header":
+			raw = r.Header.Get(p.Name)
+			if raw == "" {
+				if p.Required {
+					problems = append(problems, "missing header "+p.Name)
+				}
+				continue
+			}
+		}
+		problems = append(problems, v.doc.Validate(p.Schema, parseParam(p.Schema, raw), p.In+" parameter "+p.Name)...)
+	}
+	for name := range query {
+		if !documented[name] {
+			problems = append(problems, "query parameter "+name+" is not documented")
+		}
+	}
+	sort.Strings(problems)
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return append(problems, "reading body: "+err.Error())
+	}
+	switch {
+	case op.RequestBody == nil:
+		if len(body) > 0 {
+			problems = append(problems, "request body is not documented")
+		}
+		return problems
+	case len(body) == 0:
+		if op.RequestBody.Required {
+			problems = append(problems, "missing request body")
+		}
+		return problems
+	}
+	contentType, media := mediaTypeOf(op.RequestBody.Content, r.Header.Get("Content-Type"))
+	if media == nil {
+		return append(problems, fmt.Sprintf("request content type %q is not documented", contentType))
+	}
+	return append(problems, v.checkBody(media, contentType, body, "request body")...)
+}
+
+func (v *Validator) checkResponse(op *Operation, rec *recorder) []string {
+	resp := op.Responses[strconv.Itoa(rec.status)]
+	if resp == nil && rec.status >= 400 {
+		resp = op.Responses["default"]
+	}
+	if resp != nil && resp.Ref != "" {
+		resp = v.doc.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]
+	}
+	if resp == nil {
+		return []string{"status is not documented"}
+	}
+	if len(resp.Content) == 0 || rec.truncated {
+		return nil
+	}
+	contentType, media := mediaTypeOf(resp.Content, rec.Header().Get("Content-Type"))
+	if media == nil {
+		return []string{fmt.Sprintf("content type %q is not documented", contentType)}
+	}
+	return v.checkBody(media, contentType, rec.body.Bytes(), "body")
+}
+
+// checkBody validates a JSON body against the schema of media
+func (v *Validator) checkBody(media *MediaType, contentType string, body []byte, name string) []string {
+	if media.Schema == nil || !isJSON(contentType) {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{name + " is not valid JSON: " + err.Error()}
+	}
+	return v.doc.Validate(media.Schema, value, name)
+}
+
+// mediaTypeOf returns the media type of header and its entry in content,
+// which may be a wildcard like image/*
+func mediaTypeOf(content map[string]*MediaType, header string) (string, *MediaType) {
+	contentType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return header, nil
+	}
+	if media, ok := content[contentType]; ok {
+		return contentType, media
+	}
+	major, _, _ := strings.Cut(contentType, "/")
+	if media, ok := content[major+"/*"]; ok {
+		return contentType, media
+	}
+	return contentType, content["*/*"]
+}
+
+func isJSON(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+// parseParam turns a raw parameter into the JSON value its schema
+// describes, leaving it a string when it does not parse so the schema
+// reports it
+func parseParam(s *Schema, raw string) interface{} {
+	if s == nil {
+		return raw
+	}
+	switch {
+	case s.Type.Has(TypeInteger), s.Type.Has(TypeNumber):
+		if n, err := strconv.ParseFloat(raw, 64); err == nil {
+			return n
+		}
+	case s.Type.Has(TypeBoolean):
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+// recorder passes a response through while keeping its status and the
+// start of its body
+type recorder struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+	hijacked  bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if !r.truncated {
+		if r.body.Len()+len(p) > maxValidatedBody {
+			r.truncated = true
+			r.body.Reset()
+		} else {
+			r.body.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Flush supports streaming responses like the live feed
+func (r *recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports WebSocket upgrades, whose traffic is not validated
+func (r *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("openapi: response writer cannot be hijacked")
+	}
+	r.hijacked = true
+	return h.Hijack()
+}
+
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
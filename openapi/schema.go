@@ -0,0 +1,383 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema (draft 2020-12, as used by OpenAPI 3.1)
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        SchemaType         `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []interface{}      `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties is false, or the *Schema of the values of a map
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	Items                *Schema     `json:"items,omitempty"`
+	AnyOf                []*Schema   `json:"anyOf,omitempty"`
+	Minimum              *float64    `json:"minimum,omitempty"`
+	Maximum              *float64    `json:"maximum,omitempty"`
+	MinLength            *int        `json:"minLength,omitempty"`
+	MaxLength            *int        `json:"maxLength,omitempty"`
+	MinItems             *int        `json:"minItems,omitempty"`
+	MaxItems             *int        `json:"maxItems,omitempty"`
+}
+
+// SchemaType is the list of JSON types a value may have, written as a
+// single string when there is one
+type SchemaType []string
+
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *SchemaType) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = SchemaType{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Has reports whether the type list contains typ
+func (t SchemaType) Has(typ string) bool {
+	for _, v := range t {
+		if v == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON types of a SchemaType
+const (
+	TypeNull    = "null"
+	TypeBoolean = "boolean"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeString  = "string"
+	TypeArray   = "array"
+	TypeObject  = "object"
+)
+
+// Ref returns a schema referring to the component schema name
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// String returns a string schema of the given format, which may be empty
+func String(format string) *Schema {
+	return &Schema{Type: SchemaType{TypeString}, Format: format}
+}
+
+// Integer returns an integer schema
+func Integer() *Schema {
+	return &Schema{Type: SchemaType{TypeInteger}}
+}
+
+// Boolean returns a boolean schema
+func Boolean() *Schema {
+	return &Schema{Type: SchemaType{TypeBoolean}}
+}
+
+// Enum returns a string schema allowing only values
+func Enum(values ...string) *Schema {
+	s := String("")
+	for _, v := range values {
+		s.Enum = append(s.Enum, v)
+	}
+	return s
+}
+
+// Between sets the minimum and maximum of a number schema and returns it
+func (s *Schema) Between(min, max float64) *Schema {
+	s.Minimum, s.Maximum = &min, &max
+	return s
+}
+
+// AtLeast sets the minimum of a number schema and returns it
+func (s *Schema) AtLeast(min float64) *Schema {
+	s.Minimum = &min
+	return s
+}
+
+// Nullable returns a schema that also allows null
+func Nullable(s *Schema) *Schema {
+	switch {
+	case s.Ref != "":
+		return &Schema{AnyOf: []*Schema{s, {Type: SchemaType{TypeNull}}}}
+	case len(s.AnyOf) > 0:
+		n := *s
+		n.AnyOf = append(append([]*Schema(nil), s.AnyOf...), &Schema{Type: SchemaType{TypeNull}})
+		return &n
+	case len(s.Type) == 0 || s.Type.Has(TypeNull):
+		return s
+	}
+	n := *s
+	n.Type = append(append(SchemaType(nil), s.Type...), TypeNull)
+	return &n
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// schemaKey tells apart the schemas of a type read from requests and
+// written in responses
+type schemaKey struct {
+	t     reflect.Type
+	input bool
+}
+
+// generator derives schemas from Go types the way encoding/json reads
+// and writes them. Named structs become component schemas.
+//
+// In responses a field is required unless it is omitempty. In request
+// bodies it is required when its validate tag says so, and the min and
+// max of the tag become bounds, matching the checks of the handlers.
+type generator struct {
+	schemas     map[string]*Schema
+	names       map[schemaKey]string
+	unqualified map[string]bool
+}
+
+func newGenerator(unqualified []string) *generator {
+	g := &generator{
+		schemas:     map[string]*Schema{},
+		names:       map[schemaKey]string{},
+		unqualified: map[string]bool{},
+	}
+	for _, pkg := range unqualified {
+		g.unqualified[pkg] = true
+	}
+	return g
+}
+
+// schema returns the schema of v: a *Schema, an Object, or a Go value
+// whose type gives the schema
+func (g *generator) schema(v interface{}, input bool) *Schema {
+	switch v := v.(type) {
+	case *Schema:
+		return v
+	case Object:
+		return g.object(v, input)
+	}
+	return g.schemaOf(reflect.TypeOf(v), input)
+}
+
+func (g *generator) schemaOf(t reflect.Type, input bool) *Schema {
+	switch t {
+	case timeType:
+		return String("date-time")
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return Nullable(g.schemaOf(t.Elem(), input))
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Integer()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		min := 0.0
+		return &Schema{Type: SchemaType{TypeInteger}, Minimum: &min}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: SchemaType{TypeNumber}}
+	case reflect.String:
+		return String("")
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Nullable(String("byte"))
+		}
+		return &Schema{Type: SchemaType{TypeArray, TypeNull}, Items: g.schemaOf(t.Elem(), input)}
+	case reflect.Array:
+		return &Schema{Type: SchemaType{TypeArray}, Items: g.schemaOf(t.Elem(), input)}
+	case reflect.Map:
+		return &Schema{Type: SchemaType{TypeObject, TypeNull}, AdditionalProperties: g.schemaOf(t.Elem(), input)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, input)
+		}
+		return g.component(t, input)
+	}
+	panic(fmt.Sprintf("openapi: no schema for %s", t))
+}
+
+// component returns a reference to the component schema of the named
+// struct t, adding it on first use
+func (g *generator) component(t reflect.Type, input bool) *Schema {
+	key := schemaKey{t, input}
+	if name, ok := g.names[key]; ok {
+		return Ref(name)
+	}
+
+	name := t.Name()
+	if !g.unqualified[t.PkgPath()] {
+		name = path.Base(t.PkgPath()) + "_" + name
+	}
+	if input && !strings.HasSuffix(name, "Request") {
+		name += "Input"
+	}
+	name = exportedName(name)
+	for i, base := 2, name; g.schemas[name] != nil; i++ {
+		name = base + strconv.Itoa(i)
+	}
+
+	// Register the name before generating so recursive types refer to it
+	g.names[key] = name
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.structSchema(t, input)
+	return Ref(name)
+}
+
+// exportedName turns a package qualified Go name like graphql_Request
+// into GraphqlRequest
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// structSchema returns the closed object schema of the fields of t
+func (g *generator) structSchema(t reflect.Type, input bool) *Schema {
+	s := &Schema{Type: SchemaType{TypeObject}, Properties: map[string]*Schema{}, AdditionalProperties: false}
+	g.addFields(s, t, input)
+	return s
+}
+
+// addFields adds the JSON fields of t to s, flattening embedded structs
+// whose fields are not shadowed by the outer struct
+func (g *generator) addFields(s *Schema, t reflect.Type, input bool) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.schemaOf(ft, input)
+		if hasOption(opts, "string") {
+			fs = String("")
+		}
+		required := !hasOption(opts, "omitempty")
+		if input {
+			required = g.applyValidate(fs, f.Tag.Get("validate"))
+		}
+		s.Properties[name] = fs
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	for _, et := range embedded {
+		inner := &Schema{Properties: map[string]*Schema{}}
+		g.addFields(inner, et, input)
+		for name, fs := range inner.Properties {
+			if _, shadowed := s.Properties[name]; !shadowed {
+				s.Properties[name] = fs
+			}
+		}
+		for _, name := range inner.Required {
+			if !contains(s.Required, name) {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+}
+
+// applyValidate sets the bounds of a validate tag like
+// "required,min=1,max=5" on s and reports whether it has required
+func (g *generator) applyValidate(s *Schema, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		if key == "required" {
+			required = true
+			continue
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || (key != "min" && key != "max") {
+			continue
+		}
+		switch {
+		case s.Type.Has(TypeInteger) || s.Type.Has(TypeNumber):
+			if key == "min" {
+				s.Minimum = &n
+			} else {
+				s.Maximum = &n
+			}
+		case s.Type.Has(TypeString):
+			l := int(n)
+			if key == "min" {
+				s.MinLength = &l
+			} else {
+				s.MaxLength = &l
+			}
+		case s.Type.Has(TypeArray):
+			l := int(n)
+			if key == "min" {
+				s.MinItems = &l
+			} else {
+				s.MaxItems = &l
+			}
+		}
+	}
+	return required
+}
+
+func hasOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Route is one method of a route of the router
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.Handler
+}
+
+// FromRouter lists the routes of r and its subrouters that match by
+// method. Catch-all routes without methods, like mounted handlers, are
+// left out.
+func FromRouter(r *mux.Router) ([]Route, error) {
+	var routes []Route
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil || route.GetHandler() == nil {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			routes = append(routes, Route{Method: method, Path: path, Handler: route.GetHandler()})
+		}
+		return nil
+	})
+	return routes, err
+}
+
+// Body is a request or response body by content type. A value is a Go
+// value whose type gives the schema, a *Schema, an Object, or nil for
+// content without a schema such as files.
+type Body map[string]interface{}
+
+// JSON returns an application/json body shaped like v
+func JSON(v interface{}) Body {
+	return Body{"application/json": v}
+}
+
+// Content returns a body of any of the content types, without a schema
+func Content(types ...string) Body {
+	b := Body{}
+	for _, t := range types {
+		b[t] = nil
+	}
+	return b
+}
+
+// Or returns a body accepting the content types of b and other
+func (b Body) Or(other Body) Body {
+	merged := Body{}
+	for t, v := range b {
+		merged[t] = v
+	}
+	for t, v := range other {
+		merged[t] = v
+	}
+	return merged
+}
+
+// Object is a closed JSON object of the listed properties, for bodies
+// that are not written from a single Go type
+type Object []Property
+
+// Property is a property of an Object. Value is a Go value whose type
+// gives the schema, a *Schema, or an Object.
+type Property struct {
+	Name     string
+	Value    interface{}
+	Optional bool
+}
+
+// With returns o with more properties
+func (o Object) With(props ...Property) Object {
+	return append(append(Object(nil), o...), props...)
+}
+
+// OperationSpec describes what the handler of a route reads and writes.
+// Path parameters are taken from the route; Params overrides them or adds
+// query and header parameters.
+type OperationSpec struct {
+	// ID overrides the operationId, which is the name of the handler
+	// function. Routes served by closures need one.
+	ID          string
+	Tag         string
+	Summary     string
+	Description string
+	Params      []*Parameter
+	Request     Body
+	// OptionalRequest is set when the request body may be left out
+	OptionalRequest bool
+	// Responses holds the bodies by status; nil means no body
+	Responses map[int]Body
+	// Errors lists the error statuses answered with the error body of
+	// Config, besides 401 on routes that need a token
+	Errors []int
+	// Optional routes, such as development tools, are not always served
+	Optional bool
+}
+
+// Config holds what Build needs besides the routes
+type Config struct {
+	Info Info
+	// Unqualified lists the import paths of packages whose types are named
+	// without their package in component schemas
+	Unqualified []string
+	// Error is the body of error responses
+	Error interface{}
+	// Public reports whether a path needs no token
+	Public func(path string) bool
+}
+
+// Query returns a query parameter of the given schema
+func Query(name string, schema *Schema, description string) *Parameter {
+	return &Parameter{Name: name, In: "query", Schema: schema, Description: description}
+}
+
+// Path returns a path parameter of the given schema
+func Path(name string, schema *Schema, description string) *Parameter {
+	return &Parameter{Name: name, In: "path", Required: true, Schema: schema, Description: description}
+}
+
+// Build describes routes with specs, keyed by method and path template
+// like "GET /api/books/{id}". The error lists every route without a spec
+// and every spec without a route; the document describes the rest.
+func Build(cfg Config, routes []Route, specs map[string]OperationSpec) (*Document, error) {
+	g := newGenerator(cfg.Unqualified)
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    cfg.Info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: g.schemas,
+			Responses: map[string]*Response{
+				"Error": {Description: "Error", Content: map[string]*MediaType{
+					"application/json": {Schema: g.schema(cfg.Error, false)},
+				}},
+			},
+			SecuritySchemes: map[string]*SecurityScheme{
+				BearerAuth: {Type: "http", Scheme: "bearer", Description: "Session token from POST /api/login"},
+			},
+		},
+		Security: []SecurityRequirement{{BearerAuth: []string{}}},
+	}
+
+	var errs []error
+	names := map[string]int{}
+	for _, route := range routes {
+		names[handlerName(route.Handler)]++
+	}
+	seen := map[string]bool{}
+	tagged := map[string]bool{}
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		seen[key] = true
+		spec, ok := specs[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: route has no operation spec", key))
+			continue
+		}
+
+		op := buildOperation(g, cfg, route, spec)
+		if op.OperationID == "" {
+			errs = append(errs, fmt.Errorf("%s: handler is not a named function, the spec needs an ID", key))
+			op.OperationID = route.Method + " " + route.Path
+		} else if spec.ID == "" && names[op.OperationID] > 1 {
+			op.OperationID += exportedName(strings.ToLower(route.Method))
+		}
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = PathItem{}
+		}
+		doc.Paths[route.Path][strings.ToLower(route.Method)] = op
+		if spec.Tag != "" && !tagged[spec.Tag] {
+			tagged[spec.Tag] = true
+			doc.Tags = append(doc.Tags, Tag{Name: spec.Tag})
+		}
+	}
+
+	keys := make([]string, 0, len(specs))
+	for key := range specs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !seen[key] && !specs[key].Optional {
+			errs = append(errs, fmt.Errorf("%s: operation spec has no route", key))
+		}
+	}
+	return doc, errors.Join(errs...)
+}
+
+func buildOperation(g *generator, cfg Config, route Route, spec OperationSpec) *Operation {
+	op := &Operation{
+		OperationID: spec.ID,
+		Summary:     spec.Summary,
+		Description: spec.Description,
+		Responses:   map[string]*Response{"default": {Ref: "#/components/responses/Error"}},
+	}
+	if op.OperationID == "" {
+		op.OperationID = handlerName(route.Handler)
+	}
+	if spec.Tag != "" {
+		op.Tags = []string{spec.Tag}
+	}
+
+	for _, name := range pathParams(route.Path) {
+		op.Parameters = append(op.Parameters, Path(name, String(""), ""))
+	}
+	for _, p := range spec.Params {
+		replaced := false
+		for i, existing := range op.Parameters {
+			if existing.Name == p.Name && existing.In == p.In {
+				op.Parameters[i], replaced = p, true
+			}
+		}
+		if !replaced {
+			op.Parameters = append(op.Parameters, p)
+		}
+	}
+
+	if spec.Request != nil {
+		op.RequestBody = &RequestBody{Required: !spec.OptionalRequest, Content: content(g, spec.Request, true)}
+	}
+
+	if cfg.Public == nil || !cfg.Public(route.Path) {
+		op.Responses["401"] = &Response{Ref: "#/components/responses/Error"}
+	} else {
+		op.Security = &[]SecurityRequirement{}
+	}
+	for _, status := range spec.Errors {
+		op.Responses[strconv.Itoa(status)] = &Response{Ref: "#/components/responses/Error"}
+	}
+	for status, body := range spec.Responses {
+		resp := &Response{Description: http.StatusText(status)}
+		if body != nil {
+			resp.Content = content(g, body, false)
+		}
+		op.Responses[strconv.Itoa(status)] = resp
+	}
+	return op
+}
+
+// content turns a Body into media types
+func content(g *generator, body Body, input bool) map[string]*MediaType {
+	media := map[string]*MediaType{}
+	for contentType, v := range body {
+		if v == nil {
+			media[contentType] = &MediaType{}
+		} else {
+			media[contentType] = &MediaType{Schema: g.schema(v, input)}
+		}
+	}
+	return media
+}
+
+// object returns the closed schema of o
+func (g *generator) object(o Object, input bool) *Schema {
+	s := &Schema{Type: SchemaType{TypeObject}, Properties: map[string]*Schema{}, AdditionalProperties: false}
+	for _, p := range o {
+		s.Properties[p.Name] = g.schema(p.Value, input)
+		if !p.Optional {
+			s.Required = append(s.Required, p.Name)
+		}
+	}
+	return s
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// pathParams returns the names of the variables of a path template
+func pathParams(path string) []string {
+	var names []string
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// handlerName returns the name of the function serving h, or "" for
+// closures
+func handlerName(h http.Handler) string {
+	v := reflect.ValueOf(h)
+	if v.Kind() != reflect.Func {
+		return ""
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	if strings.Contains(name, ".func") {
+		return ""
+	}
+	return name[strings.LastIndex(name, ".")+1:]
+}
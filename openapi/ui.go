@@ -0,0 +1,81 @@
+package openapi
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+)
+
+// Handler serves doc as JSON
+func Handler(doc *Document) http.HandlerFunc {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic("openapi: cannot encode document: " + err.Error())
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}
+}
+
+var swaggerUIPage = template.Must(template.New("swagger-ui").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>Book Management API Documentation</title>
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+    <script crossorigin src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+</head>
+<body>
+    <div id="swagger-ui">Loading...</div>
+    <script>
+        const ui = SwaggerUIBundle({
+            url: {{.}},
+            dom_id: '#swagger-ui',
+            persistAuthorization: true,
+            onComplete: () => {
+                const token = localStorage.getItem('book_api_token');
+                if (token) {
+                    ui.preauthorizeApiKey('BearerAuth', token);
+                }
+            },
+        });
+    </script>
+</body>
+</html>
+`))
+
+var redocPage = template.Must(template.New("redoc").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>Book Management API Reference</title>
+    <style>body { margin: 0; }</style>
+    <script crossorigin src="https://unpkg.com/redoc@2/bundles/redoc.standalone.js"></script>
+</head>
+<body>
+    <div id="redoc">Loading...</div>
+    <script>
+        Redoc.init({{.}}, {}, document.getElementById('redoc'));
+    </script>
+</body>
+</html>
+`))
+
+// SwaggerUI serves Swagger UI for the document at specURL. The token the
+// frontend keeps in local storage is filled in for trying out requests.
+func SwaggerUI(specURL string) http.HandlerFunc {
+	return page(swaggerUIPage, specURL)
+}
+
+// Redoc serves a Redoc reference of the document at specURL
+func Redoc(specURL string) http.HandlerFunc {
+	return page(redocPage, specURL)
+}
+
+func page(t *template.Template, specURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		t.Execute(w, specURL)
+	}
+}
@@ -0,0 +1,152 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Validate checks a decoded JSON value (as encoding/json decodes into an
+// interface{}) against s, resolving references in the components of d. It
+// returns a problem per mismatch, each starting with where in v it is;
+// name is used for v itself.
+func (d *Document) Validate(s *Schema, v interface{}, name string) []string {
+	var problems []string
+	d.check(s, v, name, &problems)
+	return problems
+}
+
+func (d *Document) check(s *Schema, v interface{}, at string, problems *[]string) {
+	if s == nil {
+		return
+	}
+	if s.Ref != "" {
+		target := d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if target == nil {
+			*problems = append(*problems, fmt.Sprintf("%s: unresolved $ref %s", at, s.Ref))
+			return
+		}
+		d.check(target, v, at, problems)
+		return
+	}
+
+	if len(s.AnyOf) > 0 {
+		var first []string
+		for _, alt := range s.AnyOf {
+			var alternative []string
+			d.check(alt, v, at, &alternative)
+			if len(alternative) == 0 {
+				return
+			}
+			if first == nil && !(v != nil && len(alt.Type) == 1 && alt.Type[0] == TypeNull) {
+				first = alternative
+			}
+		}
+		*problems = append(*problems, first...)
+		return
+	}
+
+	if len(s.Type) > 0 {
+		got := jsonType(v)
+		if !s.Type.Has(got) && !(got == TypeInteger && s.Type.Has(TypeNumber)) {
+			*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", at, strings.Join(s.Type, " or "), got))
+			return
+		}
+	}
+	if len(s.Enum) > 0 && v != nil {
+		found := false
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is not one of %v", at, v, s.Enum))
+		}
+	}
+
+	switch v := v.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is less than %v", at, v, *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is more than %v", at, v, *s.Maximum))
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			*problems = append(*problems, fmt.Sprintf("%s: shorter than %d characters", at, *s.MinLength))
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			*problems = append(*problems, fmt.Sprintf("%s: longer than %d characters", at, *s.MaxLength))
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s: %q is not a date-time", at, v))
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			*problems = append(*problems, fmt.Sprintf("%s: fewer than %d items", at, *s.MinItems))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			*problems = append(*problems, fmt.Sprintf("%s: more than %d items", at, *s.MaxItems))
+		}
+		for i, item := range v {
+			d.check(s.Items, item, fmt.Sprintf("%s[%d]", at, i), problems)
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: missing property %s", at, name))
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if ps, ok := s.Properties[name]; ok {
+				d.check(ps, v[name], at+"."+name, problems)
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case bool:
+				if !extra {
+					*problems = append(*problems, fmt.Sprintf("%s: property %s is not documented", at, name))
+				}
+			case *Schema:
+				d.check(extra, v[name], at+"."+name, problems)
+			}
+		}
+	}
+}
+
+// jsonType returns the JSON Schema type of a decoded JSON value
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBoolean
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return TypeInteger
+		}
+		return TypeNumber
+	case string:
+		return TypeString
+	case []interface{}:
+		return TypeArray
+	case map[string]interface{}:
+		return TypeObject
+	}
+	return fmt.Sprintf("%T", v)
+}
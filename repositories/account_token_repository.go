@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rest-api-golang/models"
+)
+
+type AccountTokenRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewAccountTokenRepository(db *sql.DB) *AccountTokenRepository {
+	return &AccountTokenRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository whose password resets are
+// audited as made in the context a
+func (r *AccountTokenRepository) WithAudit(a *models.AuditContext) *AccountTokenRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// CreateToken stores a new token and invalidates any earlier unused token
+// of the same purpose, so only the latest emailed link works
+func (r *AccountTokenRepository) CreateToken(token *models.AccountToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE account_tokens SET used_at = $3
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		token.UserID, token.Purpose, token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate previous tokens: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO account_tokens (id, user_id, purpose, token_hash, email, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		token.ID,
+		token.UserID,
+		token.Purpose,
+		token.TokenHash,
+		token.Email,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create account token: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// LastTokenCreatedAt returns when the newest token of a purpose was issued
+// for a user, or nil if none exists
+func (r *AccountTokenRepository) LastTokenCreatedAt(userID, purpose string) (*time.Time, error) {
+	var createdAt *time.Time
+	err := r.db.QueryRow(`
+		SELECT MAX(created_at) FROM account_tokens
+		WHERE user_id = $1 AND purpose = $2`, userID, purpose).Scan(&createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last token: %w", err)
+	}
+	return createdAt, nil
+}
+
+// consumeToken marks an unused, unexpired token as used inside tx and
+// returns it. The conditional UPDATE makes redemption single-use even
+// under concurrent requests.
+func consumeToken(tx *sql.Tx, tokenHash, purpose string) (*models.AccountToken, error) {
+	query := `
+		UPDATE account_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING id, user_id, purpose, token_hash, email, expires_at, used_at, created_at`
+
+	token := &models.AccountToken{}
+	err := tx.QueryRow(query, tokenHash, purpose, time.Now()).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.Email,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("token %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ResetPassword redeems a password reset token, sets a bcrypt hash of the
+// new password and revokes all sessions of the user in one transaction
+func (r *AccountTokenRepository) ResetPassword(tokenHash, password string) (string, error) {
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	token, err := consumeToken(tx, tokenHash, models.TokenPurposePasswordReset)
+	if err != nil {
+		return "", err
+	}
+
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityUser, token.UserID, func() error {
+		result, err := tx.Exec(`UPDATE users SET password = $2 WHERE id = $1 AND is_active = true`, token.UserID, hash)
+		if err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("user %w", ErrNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec(`UPDATE tokens SET is_revoked = true WHERE user_id = $1 AND is_revoked = false`, token.UserID); err != nil {
+		return "", fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit password reset: %w", err)
+	}
+	return token.UserID, nil
+}
+
+// VerifyEmail redeems an email verification token. The address is only
+// marked verified if it is still the one the token was sent to.
+func (r *AccountTokenRepository) VerifyEmail(tokenHash string) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	token, err := consumeToken(tx, tokenHash, models.TokenPurposeEmailVerification)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE users SET email_verified_at = $3
+		WHERE id = $1 AND LOWER(email) = LOWER($2)`, token.UserID, token.Email, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to verify email: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return "", fmt.Errorf("email changed since the token was issued")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit email verification: %w", err)
+	}
+	return token.UserID, nil
+}
+
+// CleanupExpiredTokens removes tokens that can no longer be redeemed
+func (r *AccountTokenRepository) CleanupExpiredTokens() error {
+	_, err := r.db.Exec(`DELETE FROM account_tokens WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to cleanup expired account tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package repositories
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// auditSnapshots are the queries reading the state of an entity as JSON
+// for the audit log; they return no row once it is deleted. Secrets such
+// as password and token hashes are left out.
+var auditSnapshots = map[string]string{
+	models.AuditEntityBook: `SELECT to_jsonb(b) || jsonb_build_object(
+			'authors', ARRAY(SELECT ba.author_id || ':' || ba.role FROM book_authors ba WHERE ba.book_id = b.id ORDER BY ba.position),
+			'categories', ARRAY(SELECT bc.category_id FROM book_categories bc WHERE bc.book_id = b.id ORDER BY bc.category_id),
+			'tags', ARRAY(SELECT t.name FROM book_tags bt JOIN tags t ON t.id = bt.tag_id WHERE bt.book_id = b.id ORDER BY t.name))
+		FROM books b WHERE b.id = $1`,
+	models.AuditEntityAuthor:     `SELECT to_jsonb(a) FROM authors a WHERE a.id = $1`,
+	models.AuditEntityCategory:   `SELECT to_jsonb(c) FROM categories c WHERE c.id = $1`,
+	models.AuditEntityCopy:       `SELECT to_jsonb(c) FROM copies c WHERE c.id = $1`,
+	models.AuditEntityLoan:       `SELECT to_jsonb(l) FROM loans l WHERE l.id = $1`,
+	models.AuditEntityLoanPolicy: `SELECT to_jsonb(p) FROM loan_policies p WHERE p.role = $1`,
+	models.AuditEntityHold:       `SELECT to_jsonb(h) FROM holds h WHERE h.id = $1`,
+	models.AuditEntityReview:     `SELECT to_jsonb(rv) FROM reviews rv WHERE rv.id = $1`,
+	models.AuditEntityReadingList: `SELECT to_jsonb(l) - 'share_token' || jsonb_build_object(
+			'items', ARRAY(SELECT jsonb_build_object('book_id', i.book_id, 'note', i.note)
+				FROM reading_list_items i WHERE i.list_id = l.id ORDER BY i.position))
+		FROM reading_lists l WHERE l.id = $1`,
+	models.AuditEntityAccount: `SELECT to_jsonb(e) FROM account_entries e WHERE e.id = $1`,
+	models.AuditEntityUser:    `SELECT to_jsonb(u) - 'password' FROM users u WHERE u.id = $1`,
+	models.AuditEntityMFA:     `SELECT to_jsonb(m) - 'secret' FROM user_mfa m WHERE m.user_id = $1`,
+	models.AuditEntitySetting: `SELECT to_jsonb(s) FROM app_settings s WHERE s.key = $1`,
+	models.AuditEntitySession: `SELECT to_jsonb(t) - 'token' FROM tokens t WHERE t.id = $1`,
+	models.AuditEntityWebhook: `SELECT to_jsonb(s) - 'secret' FROM webhook_subscriptions s WHERE s.id = $1`,
+}
+
+// auditTextKeys are the entity types not keyed by a UUID
+var auditTextKeys = map[string]bool{models.AuditEntityLoanPolicy: true, models.AuditEntitySetting: true}
+
+// auditSnapshot reads the state of an entity within tx, or nil when it does
+// not exist
+func auditSnapshot(tx *sql.Tx, entityType, entityID string) (json.RawMessage, error) {
+	query, ok := auditSnapshots[entityType]
+	if !ok {
+		return nil, fmt.Errorf("unknown audit entity type %q", entityType)
+	}
+	if _, err := uuid.Parse(entityID); err != nil && !auditTextKeys[entityType] {
+		return nil, nil
+	}
+	var state []byte
+	err := tx.QueryRow(query, entityID).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s for audit: %w", entityType, err)
+	}
+	return state, nil
+}
+
+// auditDiff reduces the states before and after an update to the top-level
+// fields that changed
+func auditDiff(before, after json.RawMessage) (json.RawMessage, json.RawMessage, error) {
+	var old, cur map[string]json.RawMessage
+	if err := json.Unmarshal(before, &old); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(after, &cur); err != nil {
+		return nil, nil, err
+	}
+	changedOld, changedCur := map[string]json.RawMessage{}, map[string]json.RawMessage{}
+	for key, value := range cur {
+		// PostgreSQL writes jsonb in a canonical form, so equal values
+		// have equal bytes
+		if prev, ok := old[key]; !ok || !bytes.Equal(prev, value) {
+			changedOld[key], changedCur[key] = old[key], value
+		}
+	}
+	for key, value := range old {
+		if _, ok := cur[key]; !ok {
+			changedOld[key] = value
+		}
+	}
+	b, err := json.Marshal(changedOld)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := json.Marshal(changedCur)
+	return b, a, err
+}
+
+// insertAuditEvent appends an event to the audit log
+func insertAuditEvent(q execer, e *models.AuditEvent) error {
+	_, err := q.Exec(`
+		INSERT INTO audit_events (id, actor_id, action, entity_type, entity_id, before, after,
+			request_id, ip, user_agent, created_at)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, NULLIF($5, ''), $6, $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11)`,
+		e.ID, e.ActorID, e.Action, e.EntityType, e.EntityID, nullJSON(e.Before), nullJSON(e.After),
+		e.RequestID, e.IP, e.UserAgent, e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+// nullJSON passes empty JSON as NULL
+func nullJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return []byte(data)
+}
+
+// newAuditEvent creates an event for a change made in the context a
+func newAuditEvent(a *models.AuditContext, action, entityType, entityID string) *models.AuditEvent {
+	e := &models.AuditEvent{
+		ID:         uuid.New().String(),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		CreatedAt:  time.Now(),
+	}
+	if a != nil {
+		e.ActorID, e.RequestID, e.IP, e.UserAgent = a.ActorID, a.RequestID, a.IP, a.UserAgent
+	}
+	return e
+}
+
+// auditTx runs write within tx and records it as an audit event for the
+// entity in the same transaction, so the event is stored exactly when the
+// change commits. The entity is read before and after write to fill in the
+// event; for a create only after, so write may also finish an entity that
+// was inserted earlier in tx. Webhook deliveries and the outbox event for
+// the change are written in the same way.
+func auditTx(tx *sql.Tx, a *models.AuditContext, action, entityType, entityID string, write func() error) error {
+	var before json.RawMessage
+	if action != models.AuditActionCreate {
+		var err error
+		if before, err = auditSnapshot(tx, entityType, entityID); err != nil {
+			return err
+		}
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	after, err := auditSnapshot(tx, entityType, entityID)
+	if err != nil {
+		return err
+	}
+
+	e := newAuditEvent(a, action, entityType, entityID)
+	switch {
+	case before == nil:
+		e.After = after
+	case after == nil:
+		e.Before = before
+	default:
+		if e.Before, e.After, err = auditDiff(before, after); err != nil {
+			return fmt.Errorf("failed to compare %s for audit: %w", entityType, err)
+		}
+	}
+	if err := insertAuditEvent(tx, e); err != nil {
+		return err
+	}
+	if err := insertOutboxEvent(tx, e, before, after); err != nil {
+		return err
+	}
+	return enqueueWebhooks(tx, e, before, after)
+}
+
+// audited runs write in a new transaction through auditTx
+func audited(db *sql.DB, a *models.AuditContext, action, entityType, entityID string, write func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := auditTx(tx, a, action, entityType, entityID, func() error { return write(tx) }); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %s: %w", entityType, err)
+	}
+	return nil
+}
+
+// RecordEvent stores an event that is not part of a change, such as a
+// failed login, with details as its after state
+func (r *AuditRepository) RecordEvent(a *models.AuditContext, action, entityType, entityID string, details map[string]string) error {
+	e := newAuditEvent(a, action, entityType, entityID)
+	if len(details) > 0 {
+		data, err := json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit details: %w", err)
+		}
+		e.After = data
+	}
+	return insertAuditEvent(r.db, e)
+}
+
+// ListAuditEvents retrieves events matching filter, newest first
+func (r *AuditRepository) ListAuditEvents(filter models.AuditFilter) ([]*models.AuditEvent, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != "" {
+		add("e.actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("e.action = $%d", filter.Action)
+	}
+	if filter.EntityType != "" {
+		add("e.entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		add("e.entity_id = $%d", filter.EntityID)
+	}
+	if filter.From != nil {
+		add("e.created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("e.created_at < $%d", *filter.To)
+	}
+	if filter.Before != "" {
+		add("(e.created_at, e.id) < (SELECT created_at, id FROM audit_events WHERE id = $%d)", filter.Before)
+	}
+
+	query := `
+		SELECT e.id, COALESCE(e.actor_id::text, ''), COALESCE(u.username, ''), e.action, e.entity_type,
+			COALESCE(e.entity_id, ''), e.before, e.after, COALESCE(e.request_id, ''),
+			COALESCE(e.ip, ''), COALESCE(e.user_agent, ''), e.created_at
+		FROM audit_events e
+		LEFT JOIN users u ON u.id = e.actor_id`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY e.created_at DESC, e.id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		e := &models.AuditEvent{}
+		var before, after []byte
+		err := rows.Scan(&e.ID, &e.ActorID, &e.Actor, &e.Action, &e.EntityType, &e.EntityID,
+			&before, &after, &e.RequestID, &e.IP, &e.UserAgent, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		e.Before, e.After = before, after
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,481 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type AuthorRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewAuthorRepository(db *sql.DB) *AuthorRepository {
+	return &AuthorRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository recording its changes in the
+// audit log as made in the context a
+func (r *AuthorRepository) WithAudit(a *models.AuditContext) *AuthorRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// authorColumns is the select list matching scanAuthor; book_count only
+// counts books that are not soft-deleted
+const authorColumns = `a.id, a.name, COALESCE(a.bio, ''),
+		(SELECT COUNT(DISTINCT ba.book_id) FROM book_authors ba
+			JOIN books b ON b.id = ba.book_id
+			WHERE ba.author_id = a.id AND b.deleted_at IS NULL),
+		a.created_at, a.updated_at`
+
+func scanAuthor(row rowScanner) (*models.Author, error) {
+	author := &models.Author{}
+	err := row.Scan(
+		&author.ID,
+		&author.Name,
+		&author.Bio,
+		&author.BookCount,
+		&author.CreatedAt,
+		&author.UpdatedAt,
+	)
+	return author, err
+}
+
+// ListAuthors retrieves authors ordered by name, optionally only those
+// whose name contains search
+func (r *AuthorRepository) ListAuthors(search string) ([]*models.Author, error) {
+	query := `SELECT ` + authorColumns + ` FROM authors a`
+	var args []interface{}
+	if search != "" {
+		query += ` WHERE a.name ILIKE '%' || $1 || '%'`
+		args = append(args, search)
+	}
+	query += ` ORDER BY LOWER(a.name)`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query authors: %w", err)
+	}
+	defer rows.Close()
+
+	var authors []*models.Author
+	for rows.Next() {
+		author, err := scanAuthor(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan author: %w", err)
+		}
+		authors = append(authors, author)
+	}
+
+	return authors, rows.Err()
+}
+
+// GetAuthorByID retrieves an author by ID
+func (r *AuthorRepository) GetAuthorByID(id string) (*models.Author, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("author %w", ErrNotFound)
+	}
+
+	author, err := scanAuthor(r.db.QueryRow(`SELECT `+authorColumns+` FROM authors a WHERE a.id = $1`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("author %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get author: %w", err)
+	}
+
+	return author, nil
+}
+
+// GetAuthorsByIDs retrieves the authors with the given IDs, keyed by ID.
+// IDs that are not valid or not found are missing from the result.
+func (r *AuthorRepository) GetAuthorsByIDs(ids []string) (map[string]*models.Author, error) {
+	var valid []string
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err == nil {
+			valid = append(valid, id)
+		}
+	}
+	authors := map[string]*models.Author{}
+	if len(valid) == 0 {
+		return authors, nil
+	}
+
+	rows, err := r.db.Query(`SELECT `+authorColumns+` FROM authors a WHERE a.id = ANY($1)`, pq.Array(valid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query authors: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		author, err := scanAuthor(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan author: %w", err)
+		}
+		authors[author.ID] = author
+	}
+
+	return authors, rows.Err()
+}
+
+// CreateAuthor creates a new author. Names that normalize to an existing
+// author are rejected with ErrDuplicate.
+func (r *AuthorRepository) CreateAuthor(author *models.Author) error {
+	return audited(r.db, r.audit, models.AuditActionCreate, models.AuditEntityAuthor, author.ID, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO authors (id, name, normalized_name, bio, created_at, updated_at)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)`
+
+		_, err := tx.Exec(query,
+			author.ID,
+			author.Name,
+			models.NormalizeAuthorName(author.Name),
+			author.Bio,
+			author.CreatedAt,
+			author.UpdatedAt,
+		)
+
+		if isUniqueViolation(err) {
+			return fmt.Errorf("author %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create author: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// UpdateAuthor updates an author and refreshes the flat author string of
+// every book crediting them
+func (r *AuthorRepository) UpdateAuthor(author *models.Author) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityAuthor, author.ID, func() error {
+		query := `
+			UPDATE authors
+			SET name = $2, normalized_name = $3, bio = NULLIF($4, ''), updated_at = $5
+			WHERE id = $1`
+
+		result, err := tx.Exec(query,
+			author.ID,
+			author.Name,
+			models.NormalizeAuthorName(author.Name),
+			author.Bio,
+			author.UpdatedAt,
+		)
+
+		if isUniqueViolation(err) {
+			return fmt.Errorf("author %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update author: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("author %w", ErrNotFound)
+		}
+
+		rows, err := tx.Query(`SELECT DISTINCT book_id FROM book_authors WHERE author_id = $1`, author.ID)
+		if err != nil {
+			return fmt.Errorf("failed to query author books: %w", err)
+		}
+		var bookIDs []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan book id: %w", err)
+			}
+			bookIDs = append(bookIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to query author books: %w", err)
+		}
+
+		for _, bookID := range bookIDs {
+			if err := refreshFlatAuthor(tx, bookID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit author update: %w", err)
+	}
+	return nil
+}
+
+// DeleteAuthor deletes an author. Authors still credited on a book are
+// rejected with ErrInUse.
+func (r *AuthorRepository) DeleteAuthor(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("author %w", ErrNotFound)
+	}
+
+	return audited(r.db, r.audit, models.AuditActionDelete, models.AuditEntityAuthor, id, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM authors WHERE id = $1`, id)
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("author %w", ErrInUse)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete author: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("author %w", ErrNotFound)
+		}
+
+		return nil
+	})
+}
+
+// legacyAuthorsSetting is the app_settings key recording that the books
+// from before the authors table have been migrated
+const legacyAuthorsSetting = "legacy_authors_migrated"
+
+// MigrateLegacyAuthors links books that have no book_authors rows yet to
+// authors parsed from their free-text author column, and returns the number
+// of books migrated. It runs once per database: a marker in app_settings,
+// written in the same transaction, makes later calls return 0 without
+// scanning the books, and makes concurrent calls wait for the first one.
+func (r *AuthorRepository) MigrateLegacyAuthors() (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO app_settings (key, value, updated_at) VALUES ($1, 'true', CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO NOTHING`, legacyAuthorsSetting)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark author migration: %w", err)
+	}
+	marked, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark author migration: %w", err)
+	}
+	if marked == 0 {
+		return 0, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, author FROM books b
+		WHERE author <> '' AND NOT EXISTS (SELECT 1 FROM book_authors ba WHERE ba.book_id = b.id)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query legacy authors: %w", err)
+	}
+	type legacyBook struct{ id, author string }
+	var books []legacyBook
+	for rows.Next() {
+		var b legacyBook
+		if err := rows.Scan(&b.id, &b.author); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan legacy author: %w", err)
+		}
+		books = append(books, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query legacy authors: %w", err)
+	}
+
+	migrated := 0
+	for _, b := range books {
+		authors := models.AuthorsFromString(b.author)
+		if len(authors) == 0 {
+			continue
+		}
+		// The flat string is left as entered so existing responses do not change
+		if _, err := setBookAuthors(tx, b.id, authors); err != nil {
+			return 0, err
+		}
+		migrated++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit author migration: %w", err)
+	}
+	return migrated, nil
+}
+
+// resolveAuthor fills in AuthorID and Name of a to the stored author. An
+// entry with only a name reuses the author with the same normalized name
+// or creates one.
+func resolveAuthor(tx *sql.Tx, a *models.BookAuthor) error {
+	if a.AuthorID != "" {
+		if _, err := uuid.Parse(a.AuthorID); err != nil {
+			return fmt.Errorf("author %s %w", a.AuthorID, ErrNotFound)
+		}
+		err := tx.QueryRow(`SELECT name FROM authors WHERE id = $1`, a.AuthorID).Scan(&a.Name)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("author %s %w", a.AuthorID, ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get author: %w", err)
+		}
+		return nil
+	}
+
+	name := strings.Join(strings.Fields(a.Name), " ")
+	normalized := models.NormalizeAuthorName(name)
+	now := time.Now()
+	_, err := tx.Exec(`
+		INSERT INTO authors (id, name, normalized_name, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (normalized_name) DO NOTHING`,
+		uuid.New().String(), name, normalized, now)
+	if err != nil {
+		return fmt.Errorf("failed to create author: %w", err)
+	}
+
+	err = tx.QueryRow(`SELECT id, name FROM authors WHERE normalized_name = $1`, normalized).Scan(&a.AuthorID, &a.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get author: %w", err)
+	}
+	return nil
+}
+
+// setBookAuthors replaces the authors of a book. Entries are resolved,
+// ordered by Position (ties keep their list order), de-duplicated per
+// role and renumbered from 1. It returns the stored list.
+func setBookAuthors(tx *sql.Tx, bookID string, authors []models.BookAuthor) ([]models.BookAuthor, error) {
+	resolved := make([]models.BookAuthor, len(authors))
+	copy(resolved, authors)
+	sort.SliceStable(resolved, func(i, j int) bool {
+		return resolved[i].Position < resolved[j].Position
+	})
+
+	if _, err := tx.Exec(`DELETE FROM book_authors WHERE book_id = $1`, bookID); err != nil {
+		return nil, fmt.Errorf("failed to clear book authors: %w", err)
+	}
+
+	stored := make([]models.BookAuthor, 0, len(resolved))
+	seen := map[string]bool{}
+	for _, a := range resolved {
+		if a.Role == "" {
+			a.Role = models.AuthorRoleAuthor
+		}
+		if err := resolveAuthor(tx, &a); err != nil {
+			return nil, err
+		}
+		key := a.AuthorID + "/" + a.Role
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		a.Position = len(stored) + 1
+
+		_, err := tx.Exec(`
+			INSERT INTO book_authors (book_id, author_id, role, position)
+			VALUES ($1, $2, $3, $4)`,
+			bookID, a.AuthorID, a.Role, a.Position)
+		if err != nil {
+			return nil, fmt.Errorf("failed to link book author: %w", err)
+		}
+		stored = append(stored, a)
+	}
+
+	return stored, nil
+}
+
+// refreshFlatAuthor rebuilds books.author from the linked authors
+func refreshFlatAuthor(tx *sql.Tx, bookID string) error {
+	rows, err := tx.Query(`
+		SELECT a.name, ba.role FROM book_authors ba
+		JOIN authors a ON a.id = ba.author_id
+		WHERE ba.book_id = $1
+		ORDER BY ba.position`, bookID)
+	if err != nil {
+		return fmt.Errorf("failed to query book authors: %w", err)
+	}
+	var authors []models.BookAuthor
+	for rows.Next() {
+		var a models.BookAuthor
+		if err := rows.Scan(&a.Name, &a.Role); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan book author: %w", err)
+		}
+		authors = append(authors, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query book authors: %w", err)
+	}
+
+	if len(authors) == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(`UPDATE books SET author = $2 WHERE id = $1`, bookID, models.FlatAuthorString(authors)); err != nil {
+		return fmt.Errorf("failed to update book author: %w", err)
+	}
+	return nil
+}
+
+// attachAuthors loads the linked authors of books with a single query
+func attachAuthors(db querier, books []*models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	ids := make([]string, len(books))
+	byID := make(map[string]*models.Book, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+		byID[book.ID] = book
+		book.Authors = nil
+	}
+
+	rows, err := db.Query(`
+		SELECT ba.book_id, ba.author_id, a.name, ba.role, ba.position
+		FROM book_authors ba
+		JOIN authors a ON a.id = ba.author_id
+		WHERE ba.book_id = ANY($1)
+		ORDER BY ba.book_id, ba.position`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query book authors: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID string
+		var a models.BookAuthor
+		if err := rows.Scan(&bookID, &a.AuthorID, &a.Name, &a.Role, &a.Position); err != nil {
+			return fmt.Errorf("failed to scan book author: %w", err)
+		}
+		if book, ok := byID[bookID]; ok {
+			book.Authors = append(book.Authors, a)
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"testing"
+
+	"rest-api-golang/internal/testdb"
+)
+
+func TestMigrateLegacyAuthorsRunsOnce(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewAuthorRepository(db)
+
+	insertLegacyBook := func(author string) string {
+		t.Helper()
+		var id string
+		err := db.QueryRow(`INSERT INTO books (judul, author, tahun_terbit) VALUES ('Legacy', $1, 2001) RETURNING id`, author).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	linkedAuthors := func(bookID string) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM book_authors WHERE book_id = $1`, bookID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	first := insertLegacyBook("Andrea Hirata dan Dee Lestari")
+	migrated, err := repo.MigrateLegacyAuthors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 1 || linkedAuthors(first) != 2 {
+		t.Fatalf("first run migrated %d books, linked %d authors", migrated, linkedAuthors(first))
+	}
+
+	// Later runs do not scan the books again
+	second := insertLegacyBook("Ahmad Tohari")
+	migrated, err = repo.MigrateLegacyAuthors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 0 || linkedAuthors(second) != 0 {
+		t.Errorf("second run migrated %d books", migrated)
+	}
+}
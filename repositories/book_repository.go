@@ -2,29 +2,171 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"rest-api-golang/models"
+
+	"github.com/lib/pq"
 )
 
 type BookRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	audit *models.AuditContext
 }
 
 func NewBookRepository(db *sql.DB) *BookRepository {
 	return &BookRepository{db: db}
 }
 
+// WithAudit returns a copy of the repository whose changes are recorded in
+// the audit log as made in the context a
+func (r *BookRepository) WithAudit(a *models.AuditContext) *BookRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// bookColumns is the select list matching scanBook
+const bookColumns = `id, judul, author, tahun_terbit,
+		COALESCE(isbn, ''), COALESCE(publisher, ''), COALESCE(language, ''), COALESCE(pages, 0),
+		COALESCE(description, ''), COALESCE(edition, ''), COALESCE(format, ''),
+		average_rating, rating_count, COALESCE(cover_hash, ''), COALESCE(cover_format, ''),
+		created_at, updated_at, deleted_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBook reads a row selected with bookColumns
+func scanBook(row rowScanner) (*models.Book, error) {
+	book := &models.Book{}
+	err := row.Scan(
+		&book.ID,
+		&book.Judul,
+		&book.Author,
+		&book.TahunTerbit,
+		&book.ISBN,
+		&book.Publisher,
+		&book.Language,
+		&book.Pages,
+		&book.Description,
+		&book.Edition,
+		&book.Format,
+		&book.AverageRating,
+		&book.RatingCount,
+		&book.CoverHash,
+		&book.CoverFormat,
+		&book.CreatedAt,
+		&book.UpdatedAt,
+		&book.DeletedAt,
+	)
+	book.SetCoverURLs()
+	return book, err
+}
+
 // GetAllBooks retrieves all non-deleted books
 func (r *BookRepository) GetAllBooks() ([]*models.Book, error) {
+	return r.ListBooks(models.BookFilter{})
+}
+
+// bookFilterClause builds the WHERE clause for a filter. Conditions start
+// at placeholder $1 and always exclude soft-deleted books.
+func bookFilterClause(filter models.BookFilter) (string, []interface{}) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ISBN != "" {
+		add("isbn = $%d", filter.ISBN)
+	}
+	if filter.Publisher != "" {
+		add("publisher ILIKE '%%' || $%d || '%%'", filter.Publisher)
+	}
+	if filter.Language != "" {
+		// "en" also matches regional variants such as "en-US"
+		args = append(args, filter.Language)
+		conditions = append(conditions, fmt.Sprintf("(LOWER(language) = LOWER($%d) OR LOWER(language) LIKE LOWER($%d) || '-%%')", len(args), len(args)))
+	}
+	if filter.Format != "" {
+		add("format = $%d", filter.Format)
+	}
+	if filter.MinPages > 0 {
+		add("pages >= $%d", filter.MinPages)
+	}
+	if filter.MaxPages > 0 {
+		add("pages <= $%d", filter.MaxPages)
+	}
+	if filter.MinRating > 0 {
+		add("average_rating >= $%d", filter.MinRating)
+	}
+	if filter.AuthorID != "" {
+		add("id IN (SELECT book_id FROM book_authors WHERE author_id = $%d)", filter.AuthorID)
+	}
+	if filter.Category != "" {
+		// Match the category and every category below it
+		args = append(args, filter.Category)
+		conditions = append(conditions, fmt.Sprintf(`id IN (
+			SELECT bc.book_id FROM book_categories bc
+			JOIN categories c ON c.id = bc.category_id
+			JOIN categories root ON root.slug = $%d OR root.id::text = $%d
+			WHERE c.path = root.path OR c.path LIKE root.path || '/%%')`, len(args), len(args)))
+	}
+	if len(filter.Tags) > 0 {
+		if filter.MatchAllTags {
+			args = append(args, pq.Array(filter.Tags), len(filter.Tags))
+			conditions = append(conditions, fmt.Sprintf(`id IN (
+				SELECT bt.book_id FROM book_tags bt
+				JOIN tags t ON t.id = bt.tag_id
+				WHERE t.name = ANY($%d)
+				GROUP BY bt.book_id
+				HAVING COUNT(*) = $%d)`, len(args)-1, len(args)))
+		} else {
+			add(`id IN (
+				SELECT bt.book_id FROM book_tags bt
+				JOIN tags t ON t.id = bt.tag_id
+				WHERE t.name = ANY($%d))`, pq.Array(filter.Tags))
+		}
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// bookOrderClause builds the ORDER BY clause for a filter's sort. Unknown
+// fields fall back to newest first; ties are broken by ID so the order is
+// stable.
+func bookOrderClause(sort string) string {
+	direction := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+		sort = sort[1:]
+	}
+	known := false
+	for _, field := range models.BookSorts {
+		known = known || field == sort
+	}
+	if !known {
+		sort, direction = "created_at", "DESC"
+	}
+	return "ORDER BY " + sort + " " + direction + ", id"
+}
+
+// ListBooks retrieves non-deleted books matching filter
+func (r *BookRepository) ListBooks(filter models.BookFilter) ([]*models.Book, error) {
+	where, args := bookFilterClause(filter)
 	query := `
-		SELECT id, judul, author, tahun_terbit, created_at, updated_at, deleted_at
+		SELECT ` + bookColumns + `
 		FROM books 
-		WHERE deleted_at IS NULL
-		ORDER BY created_at DESC`
+		` + where + `
+		` + bookOrderClause(filter.Sort)
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query books: %w", err)
 	}
@@ -32,42 +174,135 @@ func (r *BookRepository) GetAllBooks() ([]*models.Book, error) {
 
 	var books []*models.Book
 	for rows.Next() {
-		book := &models.Book{}
-		err := rows.Scan(
-			&book.ID,
-			&book.Judul,
-			&book.Author,
-			&book.TahunTerbit,
-			&book.CreatedAt,
-			&book.UpdatedAt,
-			&book.DeletedAt,
-		)
+		book, err := scanBook(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan book: %w", err)
 		}
 		books = append(books, book)
 	}
+	rows.Close()
+
+	if err := r.attachRelations(books); err != nil {
+		return nil, err
+	}
 
 	return books, nil
 }
 
+// ListBooksPage retrieves at most limit books matching filter after
+// skipping offset of them, and the number of matching books in total
+func (r *BookRepository) ListBooksPage(filter models.BookFilter, limit, offset int) ([]*models.Book, int, error) {
+	where, args := bookFilterClause(filter)
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM books `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count books: %w", err)
+	}
+
+	args = append(args, limit, offset)
+	query := `
+		SELECT ` + bookColumns + `
+		FROM books
+		` + where + `
+		` + bookOrderClause(filter.Sort) + fmt.Sprintf(`
+		LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query books: %w", err)
+	}
+	defer rows.Close()
+
+	var books []*models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan book: %w", err)
+		}
+		books = append(books, book)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query books: %w", err)
+	}
+
+	if err := r.attachRelations(books); err != nil {
+		return nil, 0, err
+	}
+
+	return books, total, nil
+}
+
+// authorIDScanner reads the author ID that precedes bookColumns in a row
+type authorIDScanner struct {
+	row      rowScanner
+	authorID *string
+}
+
+func (s authorIDScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append([]interface{}{s.authorID}, dest...)...)
+}
+
+// ListBooksByAuthors retrieves the newest books of several authors in one
+// query, at most limit per author, keyed by author ID
+func (r *BookRepository) ListBooksByAuthors(authorIDs []string, limit int) (map[string][]*models.Book, error) {
+	query := `
+		SELECT ranked.author_id, ` + bookColumns + `
+		FROM (
+			SELECT ab.author_id, ab.book_id,
+				ROW_NUMBER() OVER (PARTITION BY ab.author_id ORDER BY b.created_at DESC, b.id) AS rank
+			FROM (SELECT DISTINCT author_id, book_id FROM book_authors WHERE author_id = ANY($1::uuid[])) ab
+			JOIN books b ON b.id = ab.book_id AND b.deleted_at IS NULL
+		) ranked
+		JOIN books ON books.id = ranked.book_id
+		WHERE ranked.rank <= $2
+		ORDER BY ranked.author_id, ranked.rank`
+
+	rows, err := r.db.Query(query, pq.Array(authorIDs), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query books: %w", err)
+	}
+	defer rows.Close()
+
+	byAuthor := map[string][]*models.Book{}
+	byID := map[string]*models.Book{}
+	var books []*models.Book
+	for rows.Next() {
+		var authorID string
+		book, err := scanBook(authorIDScanner{row: rows, authorID: &authorID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan book: %w", err)
+		}
+		// A book by several of the authors is returned once per author;
+		// they share one value so its relations are attached once
+		if seen, ok := byID[book.ID]; ok {
+			book = seen
+		} else {
+			byID[book.ID] = book
+			books = append(books, book)
+		}
+		byAuthor[authorID] = append(byAuthor[authorID], book)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query books: %w", err)
+	}
+
+	if err := r.attachRelations(books); err != nil {
+		return nil, err
+	}
+
+	return byAuthor, nil
+}
+
 // GetBookByID retrieves a book by ID
 func (r *BookRepository) GetBookByID(id string) (*models.Book, error) {
 	query := `
-		SELECT id, judul, author, tahun_terbit, created_at, updated_at, deleted_at
+		SELECT ` + bookColumns + `
 		FROM books 
 		WHERE id = $1 AND deleted_at IS NULL`
 
-	book := &models.Book{}
-	err := r.db.QueryRow(query, id).Scan(
-		&book.ID,
-		&book.Judul,
-		&book.Author,
-		&book.TahunTerbit,
-		&book.CreatedAt,
-		&book.UpdatedAt,
-		&book.DeletedAt,
-	)
+	book, err := scanBook(r.db.QueryRow(query, id))
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("book not found")
@@ -75,71 +310,252 @@ func (r *BookRepository) GetBookByID(id string) (*models.Book, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get book: %w", err)
 	}
+	if err := r.attachRelations([]*models.Book{book}); err != nil {
+		return nil, err
+	}
 
 	return book, nil
 }
 
-// CreateBook creates a new book
-func (r *BookRepository) CreateBook(book *models.Book) error {
+// GetBookByISBN retrieves a non-deleted book by normalized ISBN-13
+func (r *BookRepository) GetBookByISBN(isbn string) (*models.Book, error) {
 	query := `
-		INSERT INTO books (id, judul, author, tahun_terbit, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`
+		SELECT ` + bookColumns + `
+		FROM books 
+		WHERE isbn = $1 AND deleted_at IS NULL`
 
-	_, err := r.db.Exec(query,
-		book.ID,
-		book.Judul,
-		book.Author,
-		book.TahunTerbit,
-		book.CreatedAt,
-		book.UpdatedAt,
-	)
+	book, err := scanBook(r.db.QueryRow(query, isbn))
 
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create book: %w", err)
+		return nil, fmt.Errorf("failed to get book: %w", err)
+	}
+	if err := r.attachRelations([]*models.Book{book}); err != nil {
+		return nil, err
 	}
 
+	return book, nil
+}
+
+// CreateBook creates a new book and links book.Authors, resolving or
+// creating authors by name. When Authors is set the flat author string is
+// derived from it.
+func (r *BookRepository) CreateBook(book *models.Book) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := r.createBook(tx, book); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit book: %w", err)
+	}
 	return nil
 }
 
-// UpdateBook updates an existing book
-func (r *BookRepository) UpdateBook(book *models.Book) error {
-	query := `
-		UPDATE books 
-		SET judul = $2, author = $3, tahun_terbit = $4, updated_at = $5
-		WHERE id = $1 AND deleted_at IS NULL`
+// createBook inserts book and links its relations within tx
+func (r *BookRepository) createBook(tx *sql.Tx, book *models.Book) error {
+	return auditTx(tx, r.audit, models.AuditActionCreate, models.AuditEntityBook, book.ID, func() error {
+		query := `
+			INSERT INTO books (id, judul, author, tahun_terbit, isbn, publisher, language, pages,
+				description, edition, format, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, 0),
+				NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), $12, $13)`
 
-	result, err := r.db.Exec(query,
-		book.ID,
-		book.Judul,
-		book.Author,
-		book.TahunTerbit,
-		book.UpdatedAt,
-	)
+		_, err := tx.Exec(query,
+			book.ID,
+			book.Judul,
+			book.Author,
+			book.TahunTerbit,
+			book.ISBN,
+			book.Publisher,
+			book.Language,
+			book.Pages,
+			book.Description,
+			book.Edition,
+			book.Format,
+			book.CreatedAt,
+			book.UpdatedAt,
+		)
 
+		if isUniqueViolation(err) {
+			return fmt.Errorf("isbn %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create book: %w", err)
+		}
+
+		if err := r.linkRelations(tx, book); err != nil {
+			return err
+		}
+		return addBookRevision(tx, r.audit, book.ID, 0)
+	})
+}
+
+// attachRelations loads authors, categories and tags of books
+func (r *BookRepository) attachRelations(books []*models.Book) error {
+	return attachBookRelations(r.db, books)
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	queryRower
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// attachBookRelations loads authors, categories and tags of books through q
+func attachBookRelations(q querier, books []*models.Book) error {
+	if err := attachAuthors(q, books); err != nil {
+		return err
+	}
+	if err := attachCategories(q, books); err != nil {
+		return err
+	}
+	return attachTags(q, books)
+}
+
+// linkRelations stores the authors, categories and tags of book. A nil
+// list leaves the corresponding links untouched.
+func (r *BookRepository) linkRelations(tx *sql.Tx, book *models.Book) error {
+	if err := r.linkAuthors(tx, book); err != nil {
+		return err
+	}
+	if book.Categories != nil {
+		stored, err := setBookCategories(tx, book.ID, book.Categories)
+		if err != nil {
+			return err
+		}
+		book.Categories = stored
+	}
+	if book.Tags != nil {
+		if err := setBookTags(tx, book.ID, book.Tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkAuthors stores book.Authors and refreshes book.Author from them.
+// A nil Authors list leaves the links untouched.
+func (r *BookRepository) linkAuthors(tx *sql.Tx, book *models.Book) error {
+	if book.Authors == nil {
+		return nil
+	}
+	stored, err := setBookAuthors(tx, book.ID, book.Authors)
 	if err != nil {
-		return fmt.Errorf("failed to update book: %w", err)
+		return err
+	}
+	book.Authors = stored
+	if len(stored) == 0 {
+		return nil
 	}
+	if err := refreshFlatAuthor(tx, book.ID); err != nil {
+		return err
+	}
+	book.Author = models.FlatAuthorString(stored)
+	return nil
+}
 
-	rowsAffected, err := result.RowsAffected()
+// UpdateBook updates an existing book and, when book.Authors is not nil,
+// replaces its author links
+func (r *BookRepository) UpdateBook(book *models.Book) error {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("book not found")
+	if err := r.updateBook(tx, book, 0); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit book: %w", err)
+	}
 	return nil
 }
 
-// DeleteBook soft deletes a book
+// updateBook updates book and relinks its changed relations within tx,
+// storing the result as a new revision. revertedFrom is the revision the
+// update restores, or 0.
+func (r *BookRepository) updateBook(tx *sql.Tx, book *models.Book, revertedFrom int) error {
+	if err := ensureBookRevision(tx, book.ID); err != nil {
+		return err
+	}
+	return auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityBook, book.ID, func() error {
+		query := `
+			UPDATE books 
+			SET judul = $2, author = $3, tahun_terbit = $4, updated_at = $5,
+				isbn = NULLIF($6, ''), publisher = NULLIF($7, ''), language = NULLIF($8, ''),
+				pages = NULLIF($9, 0), description = NULLIF($10, ''), edition = NULLIF($11, ''),
+				format = NULLIF($12, '')
+			WHERE id = $1 AND deleted_at IS NULL`
+
+		result, err := tx.Exec(query,
+			book.ID,
+			book.Judul,
+			book.Author,
+			book.TahunTerbit,
+			book.UpdatedAt,
+			book.ISBN,
+			book.Publisher,
+			book.Language,
+			book.Pages,
+			book.Description,
+			book.Edition,
+			book.Format,
+		)
+
+		if isUniqueViolation(err) {
+			return fmt.Errorf("isbn %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update book: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			return fmt.Errorf("book %w", ErrNotFound)
+		}
+
+		if err := r.linkRelations(tx, book); err != nil {
+			return err
+		}
+		return addBookRevision(tx, r.audit, book.ID, revertedFrom)
+	})
+}
+
+// DeleteBook soft deletes a book. It drops out of listings and reading
+// lists but keeps its relations, and RestoreBook brings it back.
 func (r *BookRepository) DeleteBook(id string) error {
+	return audited(r.db, r.audit, models.AuditActionDelete, models.AuditEntityBook, id, func(tx *sql.Tx) error {
+		return deleteBook(tx, id)
+	})
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// deleteBook soft deletes a book through db
+func deleteBook(db execer, id string) error {
 	query := `
 		UPDATE books 
 		SET deleted_at = $2
 		WHERE id = $1 AND deleted_at IS NULL`
 
-	result, err := r.db.Exec(query, id, time.Now())
+	result, err := db.Exec(query, id, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to delete book: %w", err)
 	}
@@ -150,29 +566,537 @@ func (r *BookRepository) DeleteBook(id string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("book not found")
+		return fmt.Errorf("book %w", ErrNotFound)
 	}
 
 	return nil
 }
 
+// RestoreBook undoes a soft delete, which also shows the book again in the
+// reading lists it was in. A book whose ISBN has since been reused fails
+// with ErrDuplicate.
+func (r *BookRepository) RestoreBook(id string) error {
+	return audited(r.db, r.audit, models.AuditActionRestore, models.AuditEntityBook, id, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`UPDATE books SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("isbn %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore book: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("deleted book %w", ErrNotFound)
+		}
+
+		return nil
+	})
+}
+
+// SetCover points a book at the cover stored under hash, or removes its
+// cover when hash is empty. It returns the hash and format of the cover
+// it replaced so the caller can clean up blobs no other book uses.
+func (r *BookRepository) SetCover(id, hash, format string) (string, string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldHash, oldFormat string
+	err = tx.QueryRow(`
+		SELECT COALESCE(cover_hash, ''), COALESCE(cover_format, '')
+		FROM books
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE`, id).Scan(&oldHash, &oldFormat)
+	if err == sql.ErrNoRows {
+		return "", "", fmt.Errorf("book %w", ErrNotFound)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get book cover: %w", err)
+	}
+
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityBook, id, func() error {
+		_, err := tx.Exec(`UPDATE books SET cover_hash = NULLIF($2, ''), cover_format = NULLIF($3, '') WHERE id = $1`, id, hash, format)
+		if err != nil {
+			return fmt.Errorf("failed to update book cover: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return oldHash, oldFormat, nil
+}
+
+// CoverInUse reports whether any book, including soft-deleted ones that
+// may be restored, still uses the cover stored under hash
+func (r *BookRepository) CoverInUse(hash string) (bool, error) {
+	var inUse bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM books WHERE cover_hash = $1)`, hash).Scan(&inUse)
+	if err != nil {
+		return false, fmt.Errorf("failed to check cover usage: %w", err)
+	}
+	return inUse, nil
+}
+
 // HardDeleteBook permanently deletes a book
 func (r *BookRepository) HardDeleteBook(id string) error {
-	query := `DELETE FROM books WHERE id = $1`
+	return audited(r.db, r.audit, models.AuditActionDelete, models.AuditEntityBook, id, func(tx *sql.Tx) error {
+		query := `DELETE FROM books WHERE id = $1`
+
+		result, err := tx.Exec(query, id)
+		if err != nil {
+			return fmt.Errorf("failed to hard delete book: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			return fmt.Errorf("book %w", ErrNotFound)
+		}
+
+		return nil
+	})
+}
+
+// PurgedBook is a book removed by PurgeDeletedBooks, with the cover it
+// showed, if any
+type PurgedBook struct {
+	ID          string
+	CoverHash   string
+	CoverFormat string
+}
 
-	result, err := r.db.Exec(query, id)
+// PurgeDeletedBooks permanently deletes the books soft-deleted before
+// cutoff. Books with loans on record are kept, since the loan history
+// refers to them, and counted as kept. Each purge is recorded in the audit
+// log only: the book.deleted event was already sent on the soft delete.
+func (r *BookRepository) PurgeDeletedBooks(cutoff time.Time) (purged []PurgedBook, kept int, err error) {
+	rows, err := r.db.Query(`
+		SELECT b.id, COALESCE(b.cover_hash, ''), COALESCE(b.cover_format, ''),
+			EXISTS (SELECT 1 FROM loans l WHERE l.book_id = b.id)
+		FROM books b
+		WHERE b.deleted_at IS NOT NULL AND b.deleted_at < $1
+		ORDER BY b.deleted_at`, cutoff)
 	if err != nil {
-		return fmt.Errorf("failed to hard delete book: %w", err)
+		return nil, 0, fmt.Errorf("failed to list deleted books: %w", err)
+	}
+	var candidates []PurgedBook
+	for rows.Next() {
+		var book PurgedBook
+		var hasLoans bool
+		if err := rows.Scan(&book.ID, &book.CoverHash, &book.CoverFormat, &hasLoans); err != nil {
+			rows.Close()
+			return nil, 0, fmt.Errorf("failed to scan book: %w", err)
+		}
+		if hasLoans {
+			kept++
+			continue
+		}
+		candidates = append(candidates, book)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list deleted books: %w", err)
 	}
 
+	for _, book := range candidates {
+		err := r.purgeBook(book.ID)
+		if isForeignKeyViolation(err) {
+			// A loan was recorded since the book was listed
+			kept++
+			continue
+		}
+		if errors.Is(err, ErrNotFound) {
+			// Restored or purged by someone else meanwhile
+			continue
+		}
+		if err != nil {
+			return purged, kept, err
+		}
+		purged = append(purged, book)
+	}
+	return purged, kept, nil
+}
+
+// purgeBook deletes a soft-deleted book and its relations, recording the
+// deletion in the audit log
+func (r *BookRepository) purgeBook(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := auditSnapshot(tx, models.AuditEntityBook, id)
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM books WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to purge book: %w", err)
+	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("book %w", ErrNotFound)
+	}
+
+	e := newAuditEvent(r.audit, models.AuditActionDelete, models.AuditEntityBook, id)
+	e.Before = before
+	if err := insertAuditEvent(tx, e); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit book: %w", err)
+	}
+	return nil
+}
+
+// ImportResult is the outcome of one book of an import batch
+type ImportResult struct {
+	Updated bool
+	Err     error
+}
+
+// importInsertChunk bounds the rows of one multi-row INSERT, keeping its
+// placeholders well under PostgreSQL's limit of 65535
+const importInsertChunk = 1000
+
+// ImportBooks saves a batch of validated books in one transaction and
+// returns the outcome of each. New books are inserted with multi-row
+// INSERTs; their relations and every update are written under a savepoint,
+// so a failing book is undone without aborting the rest of the batch. A
+// book whose ISBN is already in the catalog fails with ErrDuplicate, or
+// with upsert updates that book instead, keeping stored values for fields
+// the import leaves empty. With dryRun everything is checked and then
+// rolled back. The error is set only when the whole batch failed.
+func (r *BookRepository) ImportBooks(books []*models.Book, upsert, dryRun bool) ([]ImportResult, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := existingISBNs(tx, books)
+	if err != nil {
+		return nil, err
+	}
 
+	results := make([]ImportResult, len(books))
+	var inserts []int
+	for i, book := range books {
+		id, found := existing[book.ISBN]
+		switch {
+		case !found:
+			inserts = append(inserts, i)
+		case !upsert:
+			results[i].Err = fmt.Errorf("isbn %w", ErrDuplicate)
+		default:
+			book.ID = id
+			results[i].Updated = true
+			results[i].Err = inSavepoint(tx, func() error {
+				if err := ensureBookRevision(tx, book.ID); err != nil {
+					return err
+				}
+				return auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityBook, book.ID, func() error {
+					if err := updateImportedBook(tx, book); err != nil {
+						return err
+					}
+					if err := r.linkRelations(tx, book); err != nil {
+						return err
+					}
+					return addBookRevision(tx, r.audit, book.ID, 0)
+				})
+			})
+		}
+	}
+
+	for start := 0; start < len(inserts); start += importInsertChunk {
+		chunk := inserts[start:min(start+importInsertChunk, len(inserts))]
+		inserted, err := insertBooks(tx, books, chunk)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range chunk {
+			book := books[i]
+			if !inserted[book.ID] {
+				// The ISBN was taken after existingISBNs looked
+				results[i].Err = fmt.Errorf("isbn %w", ErrDuplicate)
+				continue
+			}
+			err := inSavepoint(tx, func() error {
+				return auditTx(tx, r.audit, models.AuditActionCreate, models.AuditEntityBook, book.ID, func() error {
+					if err := r.linkRelations(tx, book); err != nil {
+						return err
+					}
+					return addBookRevision(tx, r.audit, book.ID, 0)
+				})
+			})
+			if err != nil {
+				if _, err := tx.Exec(`DELETE FROM books WHERE id = $1`, book.ID); err != nil {
+					return nil, fmt.Errorf("failed to undo imported book: %w", err)
+				}
+				results[i].Err = err
+			}
+		}
+	}
+
+	if dryRun {
+		return results, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return results, nil
+}
+
+// existingISBNs maps the ISBNs of books that are already used by an active
+// book to that book's ID
+func existingISBNs(tx *sql.Tx, books []*models.Book) (map[string]string, error) {
+	var isbns []string
+	for _, book := range books {
+		if book.ISBN != "" {
+			isbns = append(isbns, book.ISBN)
+		}
+	}
+	existing := map[string]string{}
+	if len(isbns) == 0 {
+		return existing, nil
+	}
+
+	rows, err := tx.Query(`SELECT isbn, id FROM books WHERE isbn = ANY($1) AND deleted_at IS NULL`, pq.Array(isbns))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up ISBNs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var isbn, id string
+		if err := rows.Scan(&isbn, &id); err != nil {
+			return nil, fmt.Errorf("failed to scan ISBN: %w", err)
+		}
+		existing[isbn] = id
+	}
+	return existing, rows.Err()
+}
+
+// insertBooks inserts the books at the given indexes with one statement
+// and returns the IDs that were inserted. Books whose ISBN conflicts are
+// skipped rather than failing the statement.
+func insertBooks(tx *sql.Tx, books []*models.Book, indexes []int) (map[string]bool, error) {
+	values := make([]string, 0, len(indexes))
+	args := make([]interface{}, 0, len(indexes)*13)
+	for _, i := range indexes {
+		b := books[i]
+		n := len(args)
+		values = append(values, fmt.Sprintf(
+			"($%d, $%d, $%d, $%d, NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, 0), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13))
+		args = append(args, b.ID, b.Judul, b.Author, b.TahunTerbit, b.ISBN, b.Publisher, b.Language,
+			b.Pages, b.Description, b.Edition, b.Format, b.CreatedAt, b.UpdatedAt)
+	}
+
+	rows, err := tx.Query(`
+		INSERT INTO books (id, judul, author, tahun_terbit, isbn, publisher, language, pages,
+			description, edition, format, created_at, updated_at)
+		VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT DO NOTHING
+		RETURNING id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert books: %w", err)
+	}
+	defer rows.Close()
+
+	inserted := make(map[string]bool, len(indexes))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan book id: %w", err)
+		}
+		inserted[id] = true
+	}
+	return inserted, rows.Err()
+}
+
+// updateImportedBook overwrites the stored fields an imported book sets
+func updateImportedBook(tx *sql.Tx, book *models.Book) error {
+	query := `
+		UPDATE books
+		SET judul = $2, tahun_terbit = $3,
+			publisher = COALESCE(NULLIF($4, ''), publisher), language = COALESCE(NULLIF($5, ''), language),
+			pages = COALESCE(NULLIF($6, 0), pages), description = COALESCE(NULLIF($7, ''), description),
+			edition = COALESCE(NULLIF($8, ''), edition), format = COALESCE(NULLIF($9, ''), format),
+			updated_at = $10
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := tx.Exec(query,
+		book.ID,
+		book.Judul,
+		book.TahunTerbit,
+		book.Publisher,
+		book.Language,
+		book.Pages,
+		book.Description,
+		book.Edition,
+		book.Format,
+		book.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update book: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("book not found")
+		return fmt.Errorf("book %w", ErrNotFound)
 	}
+	return nil
+}
 
+// inSavepoint runs fn under a savepoint and rolls back to it when fn
+// fails, leaving the transaction usable
+func inSavepoint(tx *sql.Tx, fn func() error) error {
+	if _, err := tx.Exec(`SAVEPOINT import_row`); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := tx.Exec(`ROLLBACK TO SAVEPOINT import_row`); rbErr != nil {
+			return fmt.Errorf("failed to roll back to savepoint: %w", rbErr)
+		}
+		return err
+	}
+	if _, err := tx.Exec(`RELEASE SAVEPOINT import_row`); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
 	return nil
 }
+
+// exportFetchSize is the number of books fetched from the cursor at once
+const exportFetchSize = 500
+
+// StreamBooks calls fn with the non-deleted books matching filter, a chunk
+// at a time, in the order of the book list. Rows are read through a
+// server-side cursor so the result is never held in memory as a whole,
+// and relations are attached to each chunk within the cursor's
+// transaction, so an export holds a single connection. Streaming stops at
+// the first error fn returns.
+func (r *BookRepository) StreamBooks(filter models.BookFilter, fn func([]*models.Book) error) error {
+	// Cursors only live inside a transaction
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	where, args := bookFilterClause(filter)
+	query := `
+		DECLARE book_export NO SCROLL CURSOR FOR
+		SELECT ` + bookColumns + `
+		FROM books
+		` + where + `
+		` + bookOrderClause(filter.Sort)
+	if _, err := tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to open book cursor: %w", err)
+	}
+
+	for {
+		books, err := fetchBooks(tx, fmt.Sprintf(`FETCH %d FROM book_export`, exportFetchSize))
+		if err != nil {
+			return err
+		}
+		if len(books) == 0 {
+			return nil
+		}
+		if err := attachBookRelations(tx, books); err != nil {
+			return err
+		}
+		if err := fn(books); err != nil {
+			return err
+		}
+	}
+}
+
+// fetchBooks runs a query selecting bookColumns and scans the rows
+func fetchBooks(tx *sql.Tx, query string) ([]*models.Book, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch books: %w", err)
+	}
+	defer rows.Close()
+
+	var books []*models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan book: %w", err)
+		}
+		books = append(books, book)
+	}
+	return books, rows.Err()
+}
+
+// BookOperation is one operation of a batch. Op is one of models.BatchOps
+// and Book is the validated book to create or update, or the book to
+// delete.
+type BookOperation struct {
+	Op   string
+	Book *models.Book
+}
+
+// ApplyBookBatch runs operations in order in one transaction and returns
+// the error of each, nil where it succeeded. When atomic, the first failing
+// operation rolls back the whole batch and the operations after it are not
+// run. Otherwise each operation runs under a savepoint, so a failure undoes
+// only that operation and the rest are committed. The error is set only
+// when the whole batch failed.
+func (r *BookRepository) ApplyBookBatch(ops []BookOperation, atomic bool) ([]error, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	errs := make([]error, len(ops))
+	for i, op := range ops {
+		run := func() error {
+			switch op.Op {
+			case models.BatchOpCreate:
+				return r.createBook(tx, op.Book)
+			case models.BatchOpUpdate:
+				return r.updateBook(tx, op.Book, 0)
+			case models.BatchOpDelete:
+				return auditTx(tx, r.audit, models.AuditActionDelete, models.AuditEntityBook, op.Book.ID, func() error {
+					return deleteBook(tx, op.Book.ID)
+				})
+			}
+			return fmt.Errorf("unknown book operation %q", op.Op)
+		}
+		if atomic {
+			if errs[i] = run(); errs[i] != nil {
+				return errs, nil
+			}
+			continue
+		}
+		errs[i] = inSavepoint(tx, run)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return errs, nil
+}
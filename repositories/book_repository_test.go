@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"rest-api-golang/internal/testdb"
+	"rest-api-golang/models"
+)
+
+func TestStreamBooksAttachesRelationsInsideTheCursor(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewBookRepository(db)
+
+	book := &models.Book{
+		Judul:       "Laskar Pelangi",
+		TahunTerbit: 2005,
+		Format:      "paperback",
+		Authors:     []models.BookAuthor{{Name: "Andrea Hirata", Role: models.AuthorRoleAuthor}},
+		Tags:        []string{"novel"},
+	}
+	if err := repo.CreateBook(book); err != nil {
+		t.Fatal(err)
+	}
+
+	// With a single connection, loading relations outside the cursor's
+	// transaction would wait forever for a second one
+	db.SetMaxOpenConns(1)
+	done := make(chan error, 1)
+	var streamed []*models.Book
+	go func() {
+		done <- repo.StreamBooks(models.BookFilter{Format: "paperback"}, func(books []*models.Book) error {
+			streamed = append(streamed, books...)
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("StreamBooks needs a second connection")
+	}
+
+	if len(streamed) != 1 || streamed[0].ID != book.ID {
+		t.Fatalf("streamed %d books", len(streamed))
+	}
+	if got := streamed[0]; len(got.Authors) != 1 || got.Authors[0].Name != "Andrea Hirata" || len(got.Tags) != 1 {
+		t.Errorf("relations = %+v, %v", got.Authors, got.Tags)
+	}
+}
@@ -0,0 +1,347 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type CategoryRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewCategoryRepository(db *sql.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository whose changes are audited as
+// made in the context a
+func (r *CategoryRepository) WithAudit(a *models.AuditContext) *CategoryRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// categoryColumns is the select list matching scanCategory; book_count
+// only counts books directly in the category that are not soft-deleted
+const categoryColumns = `c.id, c.name, c.slug, c.parent_id, c.path,
+		(SELECT COUNT(*) FROM book_categories bc
+			JOIN books b ON b.id = bc.book_id
+			WHERE bc.category_id = c.id AND b.deleted_at IS NULL),
+		c.created_at, c.updated_at`
+
+func scanCategory(row rowScanner) (*models.Category, error) {
+	category := &models.Category{}
+	var parentID sql.NullString
+	err := row.Scan(
+		&category.ID,
+		&category.Name,
+		&category.Slug,
+		&parentID,
+		&category.Path,
+		&category.BookCount,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+	)
+	if parentID.Valid {
+		category.ParentID = &parentID.String
+	}
+	category.Depth = pathDepth(category.Path)
+	return category, err
+}
+
+// pathDepth returns 0 for a root path and one more per nesting level
+func pathDepth(path string) int {
+	depth := 0
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' {
+			depth++
+		}
+	}
+	return depth
+}
+
+// ListCategories retrieves all categories ordered by path, so parents come
+// before their children
+func (r *CategoryRepository) ListCategories() ([]*models.Category, error) {
+	rows, err := r.db.Query(`SELECT ` + categoryColumns + ` FROM categories c ORDER BY c.path`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []*models.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// GetCategory retrieves a category by ID or slug
+func (r *CategoryRepository) GetCategory(idOrSlug string) (*models.Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories c WHERE c.slug = $1`
+	if _, err := uuid.Parse(idOrSlug); err == nil {
+		query = `SELECT ` + categoryColumns + ` FROM categories c WHERE c.id = $1`
+	}
+
+	category, err := scanCategory(r.db.QueryRow(query, idOrSlug))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("category %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+
+	return category, nil
+}
+
+// categoryPath builds the path of a category with the given slug under
+// parentID, which may be nil for a root category
+func categoryPath(tx *sql.Tx, parentID *string, slug string) (string, error) {
+	if parentID == nil {
+		return slug, nil
+	}
+	var parentPath string
+	err := tx.QueryRow(`SELECT path FROM categories WHERE id = $1`, *parentID).Scan(&parentPath)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("parent category %w", ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get parent category: %w", err)
+	}
+	return parentPath + "/" + slug, nil
+}
+
+// CreateCategory creates a category and sets its path. A slug that is
+// already taken is rejected with ErrDuplicate.
+func (r *CategoryRepository) CreateCategory(category *models.Category) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	path, err := categoryPath(tx, category.ParentID, category.Slug)
+	if err != nil {
+		return err
+	}
+
+	err = auditTx(tx, r.audit, models.AuditActionCreate, models.AuditEntityCategory, category.ID, func() error {
+		query := `
+			INSERT INTO categories (id, name, slug, parent_id, path, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+		_, err := tx.Exec(query,
+			category.ID,
+			category.Name,
+			category.Slug,
+			category.ParentID,
+			path,
+			category.CreatedAt,
+			category.UpdatedAt,
+		)
+
+		if isUniqueViolation(err) {
+			return fmt.Errorf("category %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create category: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit category: %w", err)
+	}
+	category.Path = path
+	category.Depth = pathDepth(path)
+	return nil
+}
+
+// UpdateCategory renames or moves a category and rewrites the paths of
+// its descendants. Callers must make sure the new parent is not inside the
+// category's own subtree.
+func (r *CategoryRepository) UpdateCategory(category *models.Category) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldPath string
+	err = tx.QueryRow(`SELECT path FROM categories WHERE id = $1 FOR UPDATE`, category.ID).Scan(&oldPath)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("category %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get category: %w", err)
+	}
+
+	path, err := categoryPath(tx, category.ParentID, category.Slug)
+	if err != nil {
+		return err
+	}
+
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityCategory, category.ID, func() error {
+		query := `
+			UPDATE categories
+			SET name = $2, slug = $3, parent_id = $4, path = $5, updated_at = $6
+			WHERE id = $1`
+
+		_, err := tx.Exec(query,
+			category.ID,
+			category.Name,
+			category.Slug,
+			category.ParentID,
+			path,
+			category.UpdatedAt,
+		)
+
+		if isUniqueViolation(err) {
+			return fmt.Errorf("category %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update category: %w", err)
+		}
+
+		if path != oldPath {
+			// Slugs only contain letters, digits and hyphens, so LIKE needs no escaping
+			_, err = tx.Exec(`
+				UPDATE categories
+				SET path = $2 || SUBSTRING(path FROM LENGTH($1) + 1)
+				WHERE path LIKE $1 || '/%'`,
+				oldPath, path)
+			if err != nil {
+				return fmt.Errorf("failed to update category paths: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit category: %w", err)
+	}
+	category.Path = path
+	category.Depth = pathDepth(path)
+	return nil
+}
+
+// DeleteCategory deletes a category. Categories with children or books are
+// rejected with ErrInUse.
+func (r *CategoryRepository) DeleteCategory(id string) error {
+	return audited(r.db, r.audit, models.AuditActionDelete, models.AuditEntityCategory, id, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM categories WHERE id = $1`, id)
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("category %w", ErrInUse)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete category: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("category %w", ErrNotFound)
+		}
+
+		return nil
+	})
+}
+
+// setBookCategories replaces the categories of a book. References are
+// resolved by ID or slug; an unknown one fails with ErrNotFound. It
+// returns the stored list.
+func setBookCategories(tx *sql.Tx, bookID string, refs []models.CategoryRef) ([]models.CategoryRef, error) {
+	if _, err := tx.Exec(`DELETE FROM book_categories WHERE book_id = $1`, bookID); err != nil {
+		return nil, fmt.Errorf("failed to clear book categories: %w", err)
+	}
+
+	stored := make([]models.CategoryRef, 0, len(refs))
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		query := `SELECT id, name, slug, path FROM categories WHERE slug = $1`
+		key := ref.Slug
+		if ref.ID != "" {
+			query = `SELECT id, name, slug, path FROM categories WHERE id = $1`
+			key = ref.ID
+		}
+		err := tx.QueryRow(query, key).Scan(&ref.ID, &ref.Name, &ref.Slug, &ref.Path)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("category %s %w", key, ErrNotFound)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get category: %w", err)
+		}
+		if seen[ref.ID] {
+			continue
+		}
+		seen[ref.ID] = true
+
+		_, err = tx.Exec(`INSERT INTO book_categories (book_id, category_id) VALUES ($1, $2)`, bookID, ref.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to link book category: %w", err)
+		}
+		stored = append(stored, ref)
+	}
+
+	return stored, nil
+}
+
+// attachCategories loads the categories of books with a single query
+func attachCategories(db querier, books []*models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	ids := make([]string, len(books))
+	byID := make(map[string]*models.Book, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+		byID[book.ID] = book
+		book.Categories = nil
+	}
+
+	rows, err := db.Query(`
+		SELECT bc.book_id, c.id, c.name, c.slug, c.path
+		FROM book_categories bc
+		JOIN categories c ON c.id = bc.category_id
+		WHERE bc.book_id = ANY($1)
+		ORDER BY bc.book_id, c.path`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query book categories: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID string
+		var ref models.CategoryRef
+		if err := rows.Scan(&bookID, &ref.ID, &ref.Name, &ref.Slug, &ref.Path); err != nil {
+			return fmt.Errorf("failed to scan book category: %w", err)
+		}
+		if book, ok := byID[bookID]; ok {
+			book.Categories = append(book.Categories, ref)
+		}
+	}
+
+	return rows.Err()
+}
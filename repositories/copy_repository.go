@@ -0,0 +1,256 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type CopyRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewCopyRepository(db *sql.DB) *CopyRepository {
+	return &CopyRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository that records its changes in
+// the audit log as made in the context a
+func (r *CopyRepository) WithAudit(a *models.AuditContext) *CopyRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// copyColumns is the select list matching scanCopy
+const copyColumns = `id, book_id, barcode, condition, COALESCE(location, ''), status, created_at, updated_at`
+
+func scanCopy(row rowScanner) (*models.Copy, error) {
+	c := &models.Copy{}
+	err := row.Scan(
+		&c.ID,
+		&c.BookID,
+		&c.Barcode,
+		&c.Condition,
+		&c.Location,
+		&c.Status,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	return c, err
+}
+
+// GetCopiesByBook retrieves the copies of a book ordered by barcode
+func (r *CopyRepository) GetCopiesByBook(bookID string) ([]*models.Copy, error) {
+	rows, err := r.db.Query(`SELECT `+copyColumns+` FROM copies WHERE book_id = $1 ORDER BY barcode`, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query copies: %w", err)
+	}
+	defer rows.Close()
+
+	var copies []*models.Copy
+	for rows.Next() {
+		c, err := scanCopy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan copy: %w", err)
+		}
+		copies = append(copies, c)
+	}
+
+	return copies, rows.Err()
+}
+
+// GetCopyByID retrieves a copy by ID
+func (r *CopyRepository) GetCopyByID(id string) (*models.Copy, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("copy %w", ErrNotFound)
+	}
+
+	c, err := scanCopy(r.db.QueryRow(`SELECT `+copyColumns+` FROM copies WHERE id = $1`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("copy %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get copy: %w", err)
+	}
+
+	return c, nil
+}
+
+// GetCopyByBarcode retrieves a copy by barcode
+func (r *CopyRepository) GetCopyByBarcode(barcode string) (*models.Copy, error) {
+	c, err := scanCopy(r.db.QueryRow(`SELECT `+copyColumns+` FROM copies WHERE barcode = $1`, barcode))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("copy %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get copy: %w", err)
+	}
+
+	return c, nil
+}
+
+// CreateCopy adds a copy; a barcode already in use is rejected with
+// ErrDuplicate
+func (r *CopyRepository) CreateCopy(c *models.Copy) error {
+	return audited(r.db, r.audit, models.AuditActionCreate, models.AuditEntityCopy, c.ID, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO copies (id, book_id, barcode, condition, location, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)`
+
+		_, err := tx.Exec(query,
+			c.ID,
+			c.BookID,
+			c.Barcode,
+			c.Condition,
+			c.Location,
+			c.Status,
+			c.CreatedAt,
+			c.UpdatedAt,
+		)
+
+		if isUniqueViolation(err) {
+			return fmt.Errorf("barcode %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create copy: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// UpdateCopy updates a copy. Loan and hold status are owned by
+// circulation: a copy on loan or on hold keeps its status and those
+// statuses are never written here. c.Status is set to the stored status.
+func (r *CopyRepository) UpdateCopy(c *models.Copy) error {
+	return audited(r.db, r.audit, models.AuditActionUpdate, models.AuditEntityCopy, c.ID, func(tx *sql.Tx) error {
+		query := `
+			UPDATE copies
+			SET barcode = $2, condition = $3, location = NULLIF($4, ''),
+				status = CASE WHEN status IN ('on_loan', 'on_hold') OR $5 IN ('on_loan', 'on_hold') THEN status ELSE $5 END,
+				updated_at = $6
+			WHERE id = $1
+			RETURNING status`
+
+		err := tx.QueryRow(query,
+			c.ID,
+			c.Barcode,
+			c.Condition,
+			c.Location,
+			c.Status,
+			c.UpdatedAt,
+		).Scan(&c.Status)
+
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("copy %w", ErrNotFound)
+		}
+		if isUniqueViolation(err) {
+			return fmt.Errorf("barcode %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update copy: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// DeleteCopy deletes a copy that has never been lent; copies with loan
+// history are rejected with ErrInUse and should be withdrawn instead
+func (r *CopyRepository) DeleteCopy(id string) error {
+	return audited(r.db, r.audit, models.AuditActionDelete, models.AuditEntityCopy, id, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM copies WHERE id = $1`, id)
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("copy %w", ErrInUse)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete copy: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("copy %w", ErrNotFound)
+		}
+
+		return nil
+	})
+}
+
+// GetAvailability counts the copies of a book by circulation state and the
+// members waiting for one. Withdrawn copies are not counted.
+func (r *CopyRepository) GetAvailability(bookID string) (*models.BookAvailability, error) {
+	query := `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE status = 'available'),
+			COUNT(*) FILTER (WHERE status = 'on_loan'),
+			COUNT(*) FILTER (WHERE status = 'on_hold'),
+			(SELECT COUNT(*) FROM holds WHERE book_id = $1 AND status = 'waiting')
+		FROM copies
+		WHERE book_id = $1 AND status <> 'withdrawn'`
+
+	availability := &models.BookAvailability{}
+	err := r.db.QueryRow(query, bookID).Scan(
+		&availability.Total,
+		&availability.Available,
+		&availability.OnLoan,
+		&availability.OnHold,
+		&availability.Holds,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count copies: %w", err)
+	}
+
+	return availability, nil
+}
+
+// GetAvailabilities counts the copies of several books in one query, keyed
+// by book ID. Books without copies have an all-zero availability.
+func (r *CopyRepository) GetAvailabilities(bookIDs []string) (map[string]*models.BookAvailability, error) {
+	query := `
+		SELECT b.id,
+			COUNT(c.id),
+			COUNT(c.id) FILTER (WHERE c.status = 'available'),
+			COUNT(c.id) FILTER (WHERE c.status = 'on_loan'),
+			COUNT(c.id) FILTER (WHERE c.status = 'on_hold'),
+			(SELECT COUNT(*) FROM holds h WHERE h.book_id = b.id AND h.status = 'waiting')
+		FROM UNNEST($1::uuid[]) AS b(id)
+		LEFT JOIN copies c ON c.book_id = b.id AND c.status <> 'withdrawn'
+		GROUP BY b.id`
+
+	rows, err := r.db.Query(query, pq.Array(bookIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count copies: %w", err)
+	}
+	defer rows.Close()
+
+	availabilities := map[string]*models.BookAvailability{}
+	for rows.Next() {
+		var bookID string
+		availability := &models.BookAvailability{}
+		err := rows.Scan(
+			&bookID,
+			&availability.Total,
+			&availability.Available,
+			&availability.OnLoan,
+			&availability.OnHold,
+			&availability.Holds,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan availability: %w", err)
+		}
+		availabilities[bookID] = availability
+	}
+
+	return availabilities, rows.Err()
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ErrNotFound is wrapped by lookups that find no row, so callers can tell
+// a missing record apart from a database failure with errors.Is
+var ErrNotFound = errors.New("not found")
+
+// ErrDuplicate is wrapped when a write violates a unique constraint
+var ErrDuplicate = errors.New("already exists")
+
+// ErrInUse is wrapped when a delete is blocked by rows that reference the
+// record
+var ErrInUse = errors.New("is still referenced")
+
+// ErrNotAvailable is wrapped when a copy or loan is not in a state that
+// allows the requested circulation action
+var ErrNotAvailable = errors.New("is not available")
+
+// ErrLimitReached is wrapped when a member policy limit would be exceeded
+var ErrLimitReached = errors.New("limit reached")
+
+// ErrExceedsBalance is wrapped when a payment or waiver is larger than the
+// member's outstanding balance
+var ErrExceedsBalance = errors.New("is more than the outstanding balance")
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err is a PostgreSQL
+// foreign_key_violation
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
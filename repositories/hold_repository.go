@@ -0,0 +1,406 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+type HoldRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewHoldRepository(db *sql.DB) *HoldRepository {
+	return &HoldRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository whose changes are recorded in
+// the audit log as made in the context a
+func (r *HoldRepository) WithAudit(a *models.AuditContext) *HoldRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// holdColumns is the select list matching scanHold, used with holdFrom.
+// position is the 1-based place of a waiting hold in its book's queue.
+const holdColumns = `h.id, h.book_id, b.judul, h.user_id, h.status,
+		CASE WHEN h.status = 'waiting' THEN (
+			SELECT COUNT(*) FROM holds w
+			WHERE w.book_id = h.book_id AND w.status = 'waiting'
+				AND (w.created_at, w.id) <= (h.created_at, h.id)
+		) ELSE 0 END,
+		COALESCE(h.copy_id::text, ''), COALESCE(c.barcode, ''),
+		h.created_at, h.ready_at, h.expires_at, h.closed_at`
+
+const holdFrom = `
+		FROM holds h
+		JOIN books b ON b.id = h.book_id
+		LEFT JOIN copies c ON c.id = h.copy_id`
+
+func scanHold(row rowScanner) (*models.Hold, error) {
+	hold := &models.Hold{}
+	err := row.Scan(
+		&hold.ID,
+		&hold.BookID,
+		&hold.BookTitle,
+		&hold.UserID,
+		&hold.Status,
+		&hold.Position,
+		&hold.CopyID,
+		&hold.Barcode,
+		&hold.CreatedAt,
+		&hold.ReadyAt,
+		&hold.ExpiresAt,
+		&hold.ClosedAt,
+	)
+	return hold, err
+}
+
+// ListHolds retrieves holds matching filter, oldest first
+func (r *HoldRepository) ListHolds(filter models.HoldFilter) ([]*models.Hold, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != "" {
+		add("h.user_id = $%d", filter.UserID)
+	}
+	if filter.BookID != "" {
+		add("h.book_id = $%d", filter.BookID)
+	}
+	if filter.Status != "" {
+		add("h.status = $%d", filter.Status)
+	}
+
+	query := `SELECT ` + holdColumns + holdFrom
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY h.created_at, h.id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holds: %w", err)
+	}
+	defer rows.Close()
+
+	var holds []*models.Hold
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan hold: %w", err)
+		}
+		holds = append(holds, hold)
+	}
+
+	return holds, rows.Err()
+}
+
+// GetHoldByID retrieves a hold by ID
+func (r *HoldRepository) GetHoldByID(id string) (*models.Hold, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("hold %w", ErrNotFound)
+	}
+
+	hold, err := scanHold(r.db.QueryRow(`SELECT `+holdColumns+holdFrom+` WHERE h.id = $1`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("hold %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hold: %w", err)
+	}
+
+	return hold, nil
+}
+
+// PlaceHold adds a member to the end of a book's queue. If a copy is
+// available it is set aside right away and the hold is returned ready. A
+// member with an open hold on the book fails with ErrDuplicate.
+func (r *HoldRepository) PlaceHold(bookID, userID string) (*models.Hold, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var active bool
+	err = tx.QueryRow(`SELECT is_active FROM users WHERE id = $1`, userID).Scan(&active)
+	if err == sql.ErrNoRows || (err == nil && !active) {
+		return nil, fmt.Errorf("member %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+
+	holdID := uuid.New().String()
+	now := time.Now()
+	err = auditTx(tx, r.audit, models.AuditActionCreate, models.AuditEntityHold, holdID, func() error {
+		_, err := tx.Exec(`
+			INSERT INTO holds (id, book_id, user_id, status, created_at)
+			VALUES ($1, $2, $3, 'waiting', $4)`, holdID, bookID, userID, now)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("hold %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create hold: %w", err)
+		}
+
+		var copyID string
+		err = tx.QueryRow(`
+			SELECT id FROM copies
+			WHERE book_id = $1 AND status = 'available'
+			ORDER BY barcode
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED`, bookID).Scan(&copyID)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to find available copy: %w", err)
+		}
+		if err == nil {
+			if err := releaseCopy(tx, copyID, bookID, now); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit hold: %w", err)
+	}
+	return r.GetHoldByID(holdID)
+}
+
+// CancelHold cancels an open hold. A copy set aside for it passes to the
+// next member in the queue. Closed holds fail with ErrNotAvailable.
+func (r *HoldRepository) CancelHold(id string) (*models.Hold, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityHold, id, func() error {
+		return closeHold(tx, id, models.HoldStatusCancelled, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit hold: %w", err)
+	}
+	return r.GetHoldByID(id)
+}
+
+// ExpireHolds closes ready holds whose pickup window has passed and passes
+// their copies on to the next members in the queue. Each expiry is audited
+// as a change of its hold. It returns the number of holds expired.
+func (r *HoldRepository) ExpireHolds() (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	rows, err := tx.Query(`
+		SELECT id FROM holds
+		WHERE status = 'ready' AND expires_at < $1
+		ORDER BY expires_at
+		FOR UPDATE SKIP LOCKED`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expired holds: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan hold: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query expired holds: %w", err)
+	}
+
+	for _, id := range ids {
+		err := auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityHold, id, func() error {
+			return closeHold(tx, id, models.HoldStatusExpired, now)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit expired holds: %w", err)
+	}
+	return len(ids), nil
+}
+
+// FillHolds sets aside available copies of a book for waiting holds, for
+// when copies become available outside of a return, such as a new copy or
+// one back from maintenance. Copies beyond the waiting queue stay
+// available, and each hold made ready is audited as a change of that
+// hold. It returns the number of holds made ready.
+func (r *HoldRepository) FillHolds(bookID string) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id FROM copies
+		WHERE book_id = $1 AND status = 'available'
+		ORDER BY barcode
+		LIMIT (SELECT COUNT(*) FROM holds WHERE book_id = $1 AND status = 'waiting')
+		FOR UPDATE SKIP LOCKED`, bookID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query available copies: %w", err)
+	}
+	var copyIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan copy: %w", err)
+		}
+		copyIDs = append(copyIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query available copies: %w", err)
+	}
+
+	now := time.Now()
+	filled := 0
+	for _, copyID := range copyIDs {
+		holdID, role, err := nextWaitingHold(tx, bookID)
+		if err == sql.ErrNoRows {
+			// The rest of the queue is being served elsewhere
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityHold, holdID, func() error {
+			return setAsideCopy(tx, copyID, holdID, role, now)
+		})
+		if err != nil {
+			return 0, err
+		}
+		filled++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit holds: %w", err)
+	}
+	return filled, nil
+}
+
+// closeHold locks an open hold, sets its final status and releases the
+// copy set aside for it
+func closeHold(tx *sql.Tx, id, status string, now time.Time) error {
+	var bookID, currentStatus string
+	var copyID sql.NullString
+	err := tx.QueryRow(`
+		SELECT book_id, status, copy_id FROM holds
+		WHERE id = $1 FOR UPDATE`, id).Scan(&bookID, &currentStatus, &copyID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("hold %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get hold: %w", err)
+	}
+	if currentStatus != models.HoldStatusWaiting && currentStatus != models.HoldStatusReady {
+		return fmt.Errorf("closed hold %w", ErrNotAvailable)
+	}
+
+	if _, err := tx.Exec(`UPDATE holds SET status = $2, closed_at = $3 WHERE id = $1`, id, status, now); err != nil {
+		return fmt.Errorf("failed to close hold: %w", err)
+	}
+
+	if currentStatus == models.HoldStatusReady && copyID.Valid {
+		var copyStatus string
+		err := tx.QueryRow(`SELECT status FROM copies WHERE id = $1 FOR UPDATE`, copyID.String).Scan(&copyStatus)
+		if err != nil {
+			return fmt.Errorf("failed to get copy: %w", err)
+		}
+		if copyStatus == models.CopyStatusOnHold {
+			if err := releaseCopy(tx, copyID.String, bookID, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// releaseCopy hands a copy that just became free to the oldest waiting
+// hold on its book, starting that member's pickup window, or marks it
+// available if nobody is waiting. The copy must be locked by the caller.
+func releaseCopy(tx *sql.Tx, copyID, bookID string, now time.Time) error {
+	holdID, role, err := nextWaitingHold(tx, bookID)
+	if err == sql.ErrNoRows {
+		if _, err := tx.Exec(`UPDATE copies SET status = 'available' WHERE id = $1`, copyID); err != nil {
+			return fmt.Errorf("failed to update copy: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return setAsideCopy(tx, copyID, holdID, role, now)
+}
+
+// nextWaitingHold locks the oldest waiting hold on a book and returns it
+// with the role of its member, or sql.ErrNoRows if nobody is waiting
+func nextWaitingHold(tx *sql.Tx, bookID string) (holdID, role string, err error) {
+	err = tx.QueryRow(`
+		SELECT h.id, u.role FROM holds h
+		JOIN users u ON u.id = h.user_id
+		WHERE h.book_id = $1 AND h.status = 'waiting'
+		ORDER BY h.created_at, h.id
+		LIMIT 1
+		FOR UPDATE OF h SKIP LOCKED`, bookID).Scan(&holdID, &role)
+	if err != nil && err != sql.ErrNoRows {
+		return "", "", fmt.Errorf("failed to get next hold: %w", err)
+	}
+	return holdID, role, err
+}
+
+// setAsideCopy makes a waiting hold ready with the copy, starting the
+// pickup window of the member's role
+func setAsideCopy(tx *sql.Tx, copyID, holdID, role string, now time.Time) error {
+	policy, err := policyFor(tx, role)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE holds SET status = 'ready', copy_id = $2, ready_at = $3, expires_at = $4
+		WHERE id = $1`, holdID, copyID, now, now.AddDate(0, 0, policy.HoldPickupDays))
+	if err != nil {
+		return fmt.Errorf("failed to update hold: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE copies SET status = 'on_hold' WHERE id = $1`, copyID); err != nil {
+		return fmt.Errorf("failed to update copy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"database/sql"
+	"testing"
+
+	"rest-api-golang/internal/testdb"
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+// holdFixture creates a book with a waiting hold of the seeded member
+func holdFixture(t *testing.T, db *sql.DB) (bookID, holdID string) {
+	t.Helper()
+	book := &models.Book{Judul: "Bumi Manusia", Author: "Pramoedya Ananta Toer", TahunTerbit: 1980}
+	if err := NewBookRepository(db).CreateBook(book); err != nil {
+		t.Fatal(err)
+	}
+	var userID string
+	if err := db.QueryRow(`SELECT id FROM users WHERE username = 'user'`).Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+	hold, err := NewHoldRepository(db).PlaceHold(book.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Status != models.HoldStatusWaiting {
+		t.Fatalf("new hold is %s", hold.Status)
+	}
+	return book.ID, hold.ID
+}
+
+// addCopy adds an available copy of a book
+func addCopy(t *testing.T, db *sql.DB, bookID, barcode string) string {
+	t.Helper()
+	id := uuid.New().String()
+	if _, err := db.Exec(`INSERT INTO copies (id, book_id, barcode, status) VALUES ($1, $2, $3, 'available')`, id, bookID, barcode); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestFillHoldsCountsHoldsMadeReady(t *testing.T) {
+	db := testdb.Open(t)
+	bookID, holdID := holdFixture(t, db)
+	holds := NewHoldRepository(db)
+	first := addCopy(t, db, bookID, "B-0001")
+	second := addCopy(t, db, bookID, "B-0002")
+
+	// A hold another transaction is serving is skipped, and the copy it
+	// would have taken is not counted
+	other, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Exec(`SELECT id FROM holds WHERE id = $1 FOR UPDATE`, holdID); err != nil {
+		t.Fatal(err)
+	}
+	filled, err := holds.FillHolds(bookID)
+	other.Rollback()
+	if err != nil || filled != 0 {
+		t.Fatalf("FillHolds with the queue locked = %d, %v", filled, err)
+	}
+
+	// One waiting hold takes one copy, by barcode; the other stays available
+	if filled, err = holds.FillHolds(bookID); err != nil || filled != 1 {
+		t.Fatalf("FillHolds = %d, %v", filled, err)
+	}
+	hold, err := holds.GetHoldByID(holdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Status != models.HoldStatusReady || hold.CopyID != first {
+		t.Errorf("hold is %s with copy %q, want ready with %q", hold.Status, hold.CopyID, first)
+	}
+	var status string
+	if err := db.QueryRow(`SELECT status FROM copies WHERE id = $1`, second).Scan(&status); err != nil || status != models.CopyStatusAvailable {
+		t.Errorf("second copy is %q, %v", status, err)
+	}
+
+	if filled, err = holds.FillHolds(bookID); err != nil || filled != 0 {
+		t.Errorf("FillHolds with nobody waiting = %d, %v", filled, err)
+	}
+}
+
+// holdEvents returns the number of audit events recorded for a hold, the
+// user agent of the last one and the webhook event types queued, oldest
+// first
+func holdEvents(t *testing.T, db *sql.DB, holdID string) (audits int, userAgent string, webhooks string) {
+	t.Helper()
+	err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE((ARRAY_AGG(user_agent ORDER BY created_at DESC))[1], '')
+		FROM audit_events
+		WHERE entity_type = 'hold' AND entity_id = $1`, holdID).Scan(&audits, &userAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.QueryRow(`
+		SELECT COALESCE(STRING_AGG(event_type, ',' ORDER BY created_at), '') FROM webhook_deliveries
+		WHERE payload->'data'->>'id' = $1`, holdID).Scan(&webhooks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return audits, userAgent, webhooks
+}
+
+func TestFillAndExpireHoldsAreAudited(t *testing.T) {
+	db := testdb.Open(t)
+	if _, err := db.Exec(`INSERT INTO webhook_subscriptions (url, events, secret) VALUES ('http://127.0.0.1:1/hook', '{hold.*}', 'secret')`); err != nil {
+		t.Fatal(err)
+	}
+	bookID, holdID := holdFixture(t, db)
+	system := &models.AuditContext{UserAgent: "hold expiry"}
+	holds := NewHoldRepository(db).WithAudit(system)
+
+	// A copy that becomes available outside of a return goes to the queue
+	copyID := addCopy(t, db, bookID, "B-0001")
+	filled, err := holds.FillHolds(bookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hold, err := holds.GetHoldByID(holdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filled != 1 || hold.Status != models.HoldStatusReady || hold.CopyID != copyID {
+		t.Fatalf("filled %d holds, hold is %s with copy %q", filled, hold.Status, hold.CopyID)
+	}
+	if audits, agent, webhooks := holdEvents(t, db, holdID); audits != 2 || agent != "hold expiry" || webhooks != "hold.ready" {
+		t.Errorf("after filling: %d audit events by %q, webhooks %q", audits, agent, webhooks)
+	}
+
+	if _, err := db.Exec(`UPDATE holds SET expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1`, holdID); err != nil {
+		t.Fatal(err)
+	}
+	expired, err := holds.ExpireHolds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold, err = holds.GetHoldByID(holdID); err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 || hold.Status != models.HoldStatusExpired {
+		t.Fatalf("expired %d holds, hold is %s", expired, hold.Status)
+	}
+	if audits, _, webhooks := holdEvents(t, db, holdID); audits != 3 || webhooks != "hold.ready,hold.expired" {
+		t.Errorf("after expiry: %d audit events, webhooks %q", audits, webhooks)
+	}
+
+	var after string
+	err = db.QueryRow(`
+		SELECT after->>'status' FROM audit_events
+		WHERE entity_type = 'hold' AND entity_id = $1
+		ORDER BY created_at DESC LIMIT 1`, holdID).Scan(&after)
+	if err != nil || after != models.HoldStatusExpired {
+		t.Errorf("last audit event sets status %q, %v", after, err)
+	}
+
+	// Nobody else waits, so the copy is available again
+	var status string
+	if err := db.QueryRow(`SELECT status FROM copies WHERE id = $1`, copyID).Scan(&status); err != nil || status != models.CopyStatusAvailable {
+		t.Errorf("copy is %q, %v", status, err)
+	}
+}
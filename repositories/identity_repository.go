@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rest-api-golang/models"
+)
+
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+// GetIdentity retrieves an identity by issuer and subject
+func (r *IdentityRepository) GetIdentity(issuer, subject string) (*models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, issuer, subject, COALESCE(email, ''), created_at, last_login
+		FROM user_identities
+		WHERE issuer = $1 AND subject = $2`
+
+	identity := &models.UserIdentity{}
+	err := r.db.QueryRow(query, issuer, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Issuer,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+		&identity.LastLogin,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("identity not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	return identity, nil
+}
+
+// CreateIdentity links a new external identity to a user
+func (r *IdentityRepository) CreateIdentity(identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (id, user_id, issuer, subject, email, created_at, last_login)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.Exec(query,
+		identity.ID,
+		identity.UserID,
+		identity.Issuer,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+		identity.LastLogin,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateLastLogin records a sign-in through an identity
+func (r *IdentityRepository) UpdateLastLogin(id, email string) error {
+	query := `UPDATE user_identities SET last_login = $2, email = $3 WHERE id = $1`
+
+	_, err := r.db.Exec(query, id, time.Now(), email)
+	if err != nil {
+		return fmt.Errorf("failed to update identity last login: %w", err)
+	}
+
+	return nil
+}
+
+// CreateLoginState stores the state, nonce and PKCE verifier of a login
+func (r *IdentityRepository) CreateLoginState(state *models.OIDCLoginState) error {
+	query := `
+		INSERT INTO oidc_login_states (state, nonce, code_verifier, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.db.Exec(query,
+		state.State,
+		state.Nonce,
+		state.CodeVerifier,
+		state.ExpiresAt,
+		state.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create login state: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeLoginState deletes and returns an unexpired login state so that
+// each state value can be redeemed only once
+func (r *IdentityRepository) ConsumeLoginState(stateValue string) (*models.OIDCLoginState, error) {
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state = $1
+		RETURNING state, nonce, code_verifier, expires_at, created_at`
+
+	state := &models.OIDCLoginState{}
+	err := r.db.QueryRow(query, stateValue).Scan(
+		&state.State,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.ExpiresAt,
+		&state.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("login state not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login state: %w", err)
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, fmt.Errorf("login state expired")
+	}
+
+	return state, nil
+}
+
+// CleanupExpiredLoginStates removes abandoned login attempts
+func (r *IdentityRepository) CleanupExpiredLoginStates() error {
+	query := `DELETE FROM oidc_login_states WHERE expires_at < $1`
+
+	_, err := r.db.Exec(query, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to cleanup expired login states: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+type ImportRepository struct {
+	db *sql.DB
+}
+
+func NewImportRepository(db *sql.DB) *ImportRepository {
+	return &ImportRepository{db: db}
+}
+
+// CreateImportJob stores a new import job
+func (r *ImportRepository) CreateImportJob(job *models.ImportJob) error {
+	query := `
+		INSERT INTO import_jobs (id, user_id, status, format, file_name, dry_run, upsert, total, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9)`
+
+	_, err := r.db.Exec(query,
+		job.ID,
+		job.UserID,
+		job.Status,
+		job.Format,
+		job.FileName,
+		job.DryRun,
+		job.Upsert,
+		job.Total,
+		job.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create import job: %w", err)
+	}
+	return nil
+}
+
+// GetImportJob retrieves an import job with its report
+func (r *ImportRepository) GetImportJob(id string) (*models.ImportJob, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("import job %w", ErrNotFound)
+	}
+
+	query := `
+		SELECT id, COALESCE(user_id::text, ''), status, format, COALESCE(file_name, ''), dry_run, upsert,
+			total, processed, created, updated, failed, errors, errors_truncated, COALESCE(message, ''),
+			created_at, started_at, finished_at
+		FROM import_jobs
+		WHERE id = $1`
+
+	job := &models.ImportJob{}
+	var errorsJSON []byte
+	err := r.db.QueryRow(query, id).Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Status,
+		&job.Format,
+		&job.FileName,
+		&job.DryRun,
+		&job.Upsert,
+		&job.Total,
+		&job.Processed,
+		&job.Created,
+		&job.Updated,
+		&job.Failed,
+		&errorsJSON,
+		&job.ErrorsTruncated,
+		&job.Message,
+		&job.CreatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("import job %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	if err := json.Unmarshal(errorsJSON, &job.Errors); err != nil {
+		return nil, fmt.Errorf("failed to decode import errors: %w", err)
+	}
+
+	return job, nil
+}
+
+// UpdateImportJob stores the status and progress of a job
+func (r *ImportRepository) UpdateImportJob(job *models.ImportJob) error {
+	errorsJSON, err := json.Marshal(job.Errors)
+	if err != nil {
+		return fmt.Errorf("failed to encode import errors: %w", err)
+	}
+
+	query := `
+		UPDATE import_jobs
+		SET status = $2, processed = $3, created = $4, updated = $5, failed = $6,
+			errors = $7, errors_truncated = $8, message = NULLIF($9, ''),
+			started_at = $10, finished_at = $11
+		WHERE id = $1`
+
+	_, err = r.db.Exec(query,
+		job.ID,
+		job.Status,
+		job.Processed,
+		job.Created,
+		job.Updated,
+		job.Failed,
+		errorsJSON,
+		job.ErrorsTruncated,
+		job.Message,
+		job.StartedAt,
+		job.FinishedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update import job: %w", err)
+	}
+	return nil
+}
+
+// FailInterruptedJobs marks jobs that were queued or running when the
+// server stopped as failed; their upload was only held in memory and the
+// job cannot be resumed. It is meant to run at startup of a single
+// instance, as it cannot tell jobs of other running instances apart.
+func (r *ImportRepository) FailInterruptedJobs() (int, error) {
+	query := `
+		UPDATE import_jobs
+		SET status = 'failed', message = 'Interrupted by a server restart', finished_at = $1
+		WHERE status IN ('queued', 'running')`
+
+	result, err := r.db.Exec(query, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted import jobs: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(n), nil
+}
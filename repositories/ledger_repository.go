@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+type LedgerRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewLedgerRepository(db *sql.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository recording the entries it adds
+// in the audit log as made in the context a
+func (r *LedgerRepository) WithAudit(a *models.AuditContext) *LedgerRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// accountBalance sums a member's ledger
+func accountBalance(q queryRower, userID string) (int64, error) {
+	var balance int64
+	err := q.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM account_entries WHERE user_id = $1`, userID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+	return balance, nil
+}
+
+// addAccountEntry appends e to the ledger, audited as made in the context
+// a, and sets its ID
+func addAccountEntry(tx *sql.Tx, a *models.AuditContext, e *models.AccountEntry) error {
+	e.ID = uuid.New().String()
+	return auditTx(tx, a, models.AuditActionCreate, models.AuditEntityAccount, e.ID, func() error {
+		_, err := tx.Exec(`
+			INSERT INTO account_entries (id, user_id, loan_id, type, amount, note, created_by, created_at)
+			VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5, NULLIF($6, ''), NULLIF($7, '')::uuid, $8)`,
+			e.ID, e.UserID, e.LoanID, e.Type, e.Amount, e.Note, e.CreatedBy, e.CreatedAt)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("loan charge %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add account entry: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetBalance returns a member's outstanding balance in minor units
+func (r *LedgerRepository) GetBalance(userID string) (int64, error) {
+	return accountBalance(r.db, userID)
+}
+
+// ListEntries retrieves a member's ledger, newest first, with the running
+// balance after each entry
+func (r *LedgerRepository) ListEntries(userID string) ([]*models.AccountEntry, error) {
+	query := `
+		SELECT id, user_id, COALESCE(loan_id::text, ''), type, amount,
+			SUM(amount) OVER (ORDER BY created_at, id),
+			COALESCE(note, ''), COALESCE(created_by::text, ''), created_at
+		FROM account_entries
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.AccountEntry{}
+	for rows.Next() {
+		e := &models.AccountEntry{}
+		err := rows.Scan(&e.ID, &e.UserID, &e.LoanID, &e.Type, &e.Amount, &e.Balance, &e.Note, &e.CreatedBy, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan account entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Credit records a payment or waiver of amount against a member's balance.
+// The member row is locked so concurrent credits cannot take the balance
+// below zero; an amount over the balance fails with ErrExceedsBalance. A
+// loanID, if given, must be a loan of the member.
+func (r *LedgerRepository) Credit(userID, entryType string, amount int64, loanID, note, staffID string) (*models.AccountEntry, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked string
+	err = tx.QueryRow(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("member %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+
+	if loanID != "" {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM loans WHERE id = $1 AND user_id = $2)`, loanID, userID).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get loan: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("loan %w", ErrNotFound)
+		}
+	}
+
+	balance, err := accountBalance(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if amount > balance {
+		return nil, fmt.Errorf("amount %w of %d", ErrExceedsBalance, balance)
+	}
+
+	entry := &models.AccountEntry{
+		UserID:    userID,
+		LoanID:    loanID,
+		Type:      entryType,
+		Amount:    -amount,
+		Balance:   balance - amount,
+		Note:      note,
+		CreatedBy: staffID,
+		CreatedAt: time.Now(),
+	}
+	if err := addAccountEntry(tx, r.audit, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit account entry: %w", err)
+	}
+	return entry, nil
+}
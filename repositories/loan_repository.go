@@ -0,0 +1,532 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+type LoanRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewLoanRepository(db *sql.DB) *LoanRepository {
+	return &LoanRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository that audits its changes as
+// made in the context a
+func (r *LoanRepository) WithAudit(a *models.AuditContext) *LoanRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// policyColumns is the select list matching scanPolicy
+const policyColumns = `role, loan_days, renewal_days, max_renewals, max_loans, hold_pickup_days,
+		fine_daily_rate, fine_grace_days, fine_cap, max_balance, updated_at`
+
+func scanPolicy(row rowScanner) (*models.LoanPolicy, error) {
+	p := &models.LoanPolicy{}
+	err := row.Scan(
+		&p.Role,
+		&p.LoanDays,
+		&p.RenewalDays,
+		&p.MaxRenewals,
+		&p.MaxLoans,
+		&p.HoldPickupDays,
+		&p.FineDailyRate,
+		&p.FineGraceDays,
+		&p.FineCap,
+		&p.MaxBalance,
+		&p.UpdatedAt,
+	)
+	return p, err
+}
+
+// ListPolicies retrieves all loan policies ordered by role
+func (r *LoanRepository) ListPolicies() ([]*models.LoanPolicy, error) {
+	rows, err := r.db.Query(`SELECT ` + policyColumns + ` FROM loan_policies ORDER BY role`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query loan policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*models.LoanPolicy
+	for rows.Next() {
+		p, err := scanPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan loan policy: %w", err)
+		}
+		policies = append(policies, p)
+	}
+
+	return policies, rows.Err()
+}
+
+// queryRower is implemented by *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// policyFor returns the loan policy of role, falling back to the default
+// policy
+func policyFor(q queryRower, role string) (*models.LoanPolicy, error) {
+	query := `
+		SELECT ` + policyColumns + `
+		FROM loan_policies
+		WHERE role = $1 OR role = $2
+		ORDER BY role = $1 DESC
+		LIMIT 1`
+
+	p, err := scanPolicy(q.QueryRow(query, role, models.DefaultLoanPolicyRole))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("loan policy %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan policy: %w", err)
+	}
+	return p, nil
+}
+
+// GetPolicy returns the loan policy that applies to role
+func (r *LoanRepository) GetPolicy(role string) (*models.LoanPolicy, error) {
+	return policyFor(r.db, role)
+}
+
+// SavePolicy creates or replaces the loan policy of p.Role
+func (r *LoanRepository) SavePolicy(p *models.LoanPolicy) error {
+	return audited(r.db, r.audit, models.AuditActionUpdate, models.AuditEntityLoanPolicy, p.Role, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO loan_policies (role, loan_days, renewal_days, max_renewals, max_loans, hold_pickup_days,
+				fine_daily_rate, fine_grace_days, fine_cap, max_balance, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (role) DO UPDATE SET
+				loan_days = EXCLUDED.loan_days, renewal_days = EXCLUDED.renewal_days,
+				max_renewals = EXCLUDED.max_renewals, max_loans = EXCLUDED.max_loans,
+				hold_pickup_days = EXCLUDED.hold_pickup_days,
+				fine_daily_rate = EXCLUDED.fine_daily_rate, fine_grace_days = EXCLUDED.fine_grace_days,
+				fine_cap = EXCLUDED.fine_cap, max_balance = EXCLUDED.max_balance,
+				updated_at = EXCLUDED.updated_at`
+
+		_, err := tx.Exec(query,
+			p.Role,
+			p.LoanDays,
+			p.RenewalDays,
+			p.MaxRenewals,
+			p.MaxLoans,
+			p.HoldPickupDays,
+			p.FineDailyRate,
+			p.FineGraceDays,
+			p.FineCap,
+			p.MaxBalance,
+			p.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save loan policy: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// DeletePolicy deletes the policy of a role, which then falls back to the
+// default policy. The default policy itself cannot be deleted.
+func (r *LoanRepository) DeletePolicy(role string) error {
+	if role == models.DefaultLoanPolicyRole {
+		return fmt.Errorf("default loan policy %w", ErrInUse)
+	}
+
+	return audited(r.db, r.audit, models.AuditActionDelete, models.AuditEntityLoanPolicy, role, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM loan_policies WHERE role = $1`, role)
+		if err != nil {
+			return fmt.Errorf("failed to delete loan policy: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("loan policy %w", ErrNotFound)
+		}
+
+		return nil
+	})
+}
+
+// loanColumns is the select list matching scanLoan, used with loanFrom
+const loanColumns = `l.id, l.copy_id, l.book_id, l.user_id, c.barcode, b.judul,
+		l.checked_out_at, l.due_at, l.returned_at, l.renewal_count, COALESCE(l.checked_out_by::text, ''),
+		COALESCE((SELECT e.amount FROM account_entries e WHERE e.loan_id = l.id AND e.type = 'charge'), 0)`
+
+const loanFrom = `
+		FROM loans l
+		JOIN copies c ON c.id = l.copy_id
+		JOIN books b ON b.id = l.book_id`
+
+func scanLoan(row rowScanner) (*models.Loan, error) {
+	loan := &models.Loan{}
+	err := row.Scan(
+		&loan.ID,
+		&loan.CopyID,
+		&loan.BookID,
+		&loan.UserID,
+		&loan.Barcode,
+		&loan.BookTitle,
+		&loan.CheckedOutAt,
+		&loan.DueAt,
+		&loan.ReturnedAt,
+		&loan.RenewalCount,
+		&loan.CheckedOutBy,
+		&loan.Fine,
+	)
+	loan.SetOverdue(time.Now())
+	return loan, err
+}
+
+// ListLoans retrieves loans matching filter, newest first
+func (r *LoanRepository) ListLoans(filter models.LoanFilter) ([]*models.Loan, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != "" {
+		add("l.user_id = $%d", filter.UserID)
+	}
+	if filter.BookID != "" {
+		add("l.book_id = $%d", filter.BookID)
+	}
+	switch filter.Status {
+	case models.LoanStatusActive:
+		conditions = append(conditions, "l.returned_at IS NULL")
+	case models.LoanStatusOverdue:
+		conditions = append(conditions, "l.returned_at IS NULL AND l.due_at < NOW()")
+	case models.LoanStatusReturned:
+		conditions = append(conditions, "l.returned_at IS NOT NULL")
+	}
+
+	query := `SELECT ` + loanColumns + loanFrom
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY l.checked_out_at DESC`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query loans: %w", err)
+	}
+	defer rows.Close()
+
+	var loans []*models.Loan
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan loan: %w", err)
+		}
+		loans = append(loans, loan)
+	}
+
+	return loans, rows.Err()
+}
+
+// GetLoanByID retrieves a loan by ID
+func (r *LoanRepository) GetLoanByID(id string) (*models.Loan, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("loan %w", ErrNotFound)
+	}
+
+	loan, err := scanLoan(r.db.QueryRow(`SELECT `+loanColumns+loanFrom+` WHERE l.id = $1`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("loan %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	return loan, nil
+}
+
+// CheckOut lends a copy to a member under the member's loan policy and
+// fulfils the member's hold on the book. The member and copy rows are
+// locked, so concurrent checkouts of the same copy or beyond the member's
+// loan limit cannot both succeed. Unavailable copies, including copies
+// held for someone else, fail with ErrNotAvailable, a full loan limit with
+// ErrLimitReached.
+func (r *LoanRepository) CheckOut(copyID, userID, staffID string) (*models.Loan, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var role string
+	var active bool
+	err = tx.QueryRow(`SELECT role, is_active FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&role, &active)
+	if err == sql.ErrNoRows || (err == nil && !active) {
+		return nil, fmt.Errorf("member %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+
+	policy, err := policyFor(tx, role)
+	if err != nil {
+		return nil, err
+	}
+
+	var openLoans int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM loans WHERE user_id = $1 AND returned_at IS NULL`, userID).Scan(&openLoans); err != nil {
+		return nil, fmt.Errorf("failed to count loans: %w", err)
+	}
+	if openLoans >= policy.MaxLoans {
+		return nil, fmt.Errorf("loan %w", ErrLimitReached)
+	}
+
+	balance, err := accountBalance(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if balance > policy.MaxBalance {
+		return nil, fmt.Errorf("member owes %d, more than the %d allowed; checkout %w", balance, policy.MaxBalance, ErrLimitReached)
+	}
+
+	var bookID, status string
+	err = tx.QueryRow(`SELECT book_id, status FROM copies WHERE id = $1 FOR UPDATE`, copyID).Scan(&bookID, &status)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("copy %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get copy: %w", err)
+	}
+	switch status {
+	case models.CopyStatusAvailable:
+		// Lending an available copy also fulfils the member's waiting hold
+		_, err = tx.Exec(`
+			UPDATE holds SET status = 'fulfilled', closed_at = NOW()
+			WHERE book_id = $1 AND user_id = $2 AND status = 'waiting'`, bookID, userID)
+	case models.CopyStatusOnHold:
+		// A copy held for pickup can only be lent to the member it is held for
+		var result sql.Result
+		result, err = tx.Exec(`
+			UPDATE holds SET status = 'fulfilled', closed_at = NOW()
+			WHERE copy_id = $1 AND user_id = $2 AND status = 'ready'`, copyID, userID)
+		if err == nil {
+			if n, _ := result.RowsAffected(); n == 0 {
+				return nil, fmt.Errorf("copy is held for another member and %w", ErrNotAvailable)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("copy %w", ErrNotAvailable)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fulfil hold: %w", err)
+	}
+
+	now := time.Now()
+	loanID := uuid.New().String()
+	err = auditTx(tx, r.audit, models.AuditActionCreate, models.AuditEntityLoan, loanID, func() error {
+		_, err := tx.Exec(`
+			INSERT INTO loans (id, copy_id, book_id, user_id, checked_out_at, due_at, checked_out_by)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::uuid)`,
+			loanID, copyID, bookID, userID, now, now.AddDate(0, 0, policy.LoanDays), staffID)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("copy %w", ErrNotAvailable)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create loan: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`UPDATE copies SET status = 'on_loan' WHERE id = $1`, copyID); err != nil {
+		return nil, fmt.Errorf("failed to update copy: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit loan: %w", err)
+	}
+	return r.GetLoanByID(loanID)
+}
+
+// openLoan is the locked state of a loan being returned or renewed
+type openLoan struct {
+	copyID   string
+	bookID   string
+	userID   string
+	dueAt    time.Time
+	renewals int
+}
+
+// lockOpenLoan locks an open loan. A loan that is already returned fails
+// with ErrNotAvailable.
+func lockOpenLoan(tx *sql.Tx, loanID string) (*openLoan, error) {
+	loan := &openLoan{}
+	var returnedAt *time.Time
+	err := tx.QueryRow(`
+		SELECT copy_id, book_id, user_id, due_at, renewal_count, returned_at
+		FROM loans WHERE id = $1 FOR UPDATE`, loanID).
+		Scan(&loan.copyID, &loan.bookID, &loan.userID, &loan.dueAt, &loan.renewals, &returnedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("loan %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+	if returnedAt != nil {
+		return nil, fmt.Errorf("returned loan %w", ErrNotAvailable)
+	}
+	return loan, nil
+}
+
+// ReturnLoan closes a loan and charges the member's overdue fine under
+// their loan policy. The copy goes to the next waiting hold on the book, or
+// becomes available. condition, if not empty, records the condition the
+// copy came back in.
+func (r *LoanRepository) ReturnLoan(loanID, condition string) (*models.Loan, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	loan, err := lockOpenLoan(tx, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityLoan, loanID, func() error {
+		if _, err := tx.Exec(`UPDATE loans SET returned_at = $2 WHERE id = $1`, loanID, now); err != nil {
+			return fmt.Errorf("failed to return loan: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if days := models.DaysOverdue(loan.dueAt, now); days > 0 {
+		var role string
+		if err := tx.QueryRow(`SELECT role FROM users WHERE id = $1`, loan.userID).Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to get member: %w", err)
+		}
+		policy, err := policyFor(tx, role)
+		if err != nil {
+			return nil, err
+		}
+		if fine := policy.Fine(days); fine > 0 {
+			entry := &models.AccountEntry{
+				UserID:    loan.userID,
+				LoanID:    loanID,
+				Type:      models.EntryTypeCharge,
+				Amount:    fine,
+				Note:      overdueNote(days),
+				CreatedAt: now,
+			}
+			if err := addAccountEntry(tx, r.audit, entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var status string
+	err = tx.QueryRow(`
+		UPDATE copies SET condition = COALESCE(NULLIF($2, ''), condition)
+		WHERE id = $1
+		RETURNING status`, loan.copyID, condition).Scan(&status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update copy: %w", err)
+	}
+	// A copy marked lost or sent to maintenance while on loan keeps that status
+	if status == models.CopyStatusOnLoan {
+		if err := releaseCopy(tx, loan.copyID, loan.bookID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit return: %w", err)
+	}
+	return r.GetLoanByID(loanID)
+}
+
+// overdueNote describes an overdue fine charge
+func overdueNote(days int) string {
+	if days == 1 {
+		return "Overdue fine: 1 day late"
+	}
+	return fmt.Sprintf("Overdue fine: %d days late", days)
+}
+
+// RenewLoan extends the due date of an open loan by the member's renewal
+// period. Overdue loans and loans of books other members are waiting for
+// fail with ErrNotAvailable, loans at the policy's renewal limit with
+// ErrLimitReached.
+func (r *LoanRepository) RenewLoan(loanID string) (*models.Loan, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	loan, err := lockOpenLoan(tx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(loan.dueAt) {
+		return nil, fmt.Errorf("overdue loan %w", ErrNotAvailable)
+	}
+
+	var waiting bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM holds WHERE book_id = $1 AND status = 'waiting')`, loan.bookID).Scan(&waiting)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check holds: %w", err)
+	}
+	if waiting {
+		return nil, fmt.Errorf("book has waiting holds, renewal %w", ErrNotAvailable)
+	}
+
+	var role string
+	if err := tx.QueryRow(`SELECT role FROM users WHERE id = $1`, loan.userID).Scan(&role); err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+	policy, err := policyFor(tx, role)
+	if err != nil {
+		return nil, err
+	}
+	if loan.renewals >= policy.MaxRenewals {
+		return nil, fmt.Errorf("renewal %w", ErrLimitReached)
+	}
+
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityLoan, loanID, func() error {
+		_, err := tx.Exec(`
+			UPDATE loans SET due_at = $2, renewal_count = renewal_count + 1
+			WHERE id = $1`, loanID, loan.dueAt.AddDate(0, 0, policy.RenewalDays))
+		if err != nil {
+			return fmt.Errorf("failed to renew loan: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit renewal: %w", err)
+	}
+	return r.GetLoanByID(loanID)
+}
@@ -0,0 +1,320 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+type MFARepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewMFARepository(db *sql.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// WithAudit returns a copy of the repository auditing enrollment changes
+// as made in the context a
+func (r *MFARepository) WithAudit(a *models.AuditContext) *MFARepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// GetMFA retrieves the TOTP enrollment of a user
+func (r *MFARepository) GetMFA(userID string) (*models.UserMFA, error) {
+	query := `
+		SELECT user_id, secret, enabled, last_used_step, confirmed_at, created_at, updated_at
+		FROM user_mfa
+		WHERE user_id = $1`
+
+	mfa := &models.UserMFA{}
+	err := r.db.QueryRow(query, userID).Scan(
+		&mfa.UserID,
+		&mfa.Secret,
+		&mfa.Enabled,
+		&mfa.LastUsedStep,
+		&mfa.ConfirmedAt,
+		&mfa.CreatedAt,
+		&mfa.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("mfa %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mfa: %w", err)
+	}
+
+	return mfa, nil
+}
+
+// SavePendingSecret stores a new, not yet confirmed secret. An enabled
+// enrollment is never overwritten.
+func (r *MFARepository) SavePendingSecret(userID, secret string) error {
+	query := `
+		INSERT INTO user_mfa (user_id, secret, enabled, last_used_step, created_at, updated_at)
+		VALUES ($1, $2, false, 0, $3, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, confirmed_at = NULL
+		WHERE user_mfa.enabled = false`
+
+	result, err := r.db.Exec(query, userID, secret, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save mfa secret: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("mfa already enabled")
+	}
+
+	return nil
+}
+
+// Enable confirms the enrollment and replaces the recovery codes in one
+// transaction
+func (r *MFARepository) Enable(userID string, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityMFA, userID, func() error {
+		result, err := tx.Exec(`
+			UPDATE user_mfa
+			SET enabled = true, confirmed_at = $2, last_used_step = $3
+			WHERE user_id = $1 AND enabled = false`, userID, time.Now(), step)
+		if err != nil {
+			return fmt.Errorf("failed to enable mfa: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("mfa not found")
+		}
+
+		return replaceRecoveryCodes(tx, userID, recoveryCodeHashes)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Disable removes the enrollment and all recovery codes of a user
+func (r *MFARepository) Disable(userID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = auditTx(tx, r.audit, models.AuditActionDelete, models.AuditEntityMFA, userID, func() error {
+		if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("failed to disable mfa: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MarkStepUsed records an accepted TOTP step. It fails if the step is not
+// newer than the last accepted one, which rejects replayed codes even
+// under concurrent requests.
+func (r *MFARepository) MarkStepUsed(userID string, step int64) error {
+	query := `UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`
+
+	result, err := r.db.Exec(query, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record mfa step: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("code already used")
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes discards all recovery codes and stores new hashes
+func (r *MFARepository) ReplaceRecoveryCodes(userID string, hashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, hashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID string, hashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	now := time.Now()
+	for _, hash := range hashes {
+		_, err := tx.Exec(`
+			INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at)
+			VALUES ($1, $2, $3, $4)`, uuid.New().String(), userID, hash, now)
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used
+func (r *MFARepository) UseRecoveryCode(userID, hash string) error {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	result, err := r.db.Exec(query, userID, hash, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("recovery code not found")
+	}
+
+	return nil
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes
+func (r *MFARepository) CountRecoveryCodes(userID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// CreateChallenge stores a new login challenge
+func (r *MFARepository) CreateChallenge(challenge *models.MFAChallenge) error {
+	query := `
+		INSERT INTO mfa_challenges (id, token_hash, user_id, attempts, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.Exec(query,
+		challenge.ID,
+		challenge.TokenHash,
+		challenge.UserID,
+		challenge.Attempts,
+		challenge.ExpiresAt,
+		challenge.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create mfa challenge: %w", err)
+	}
+
+	return nil
+}
+
+// GetChallenge retrieves an unexpired challenge by token hash
+func (r *MFARepository) GetChallenge(tokenHash string) (*models.MFAChallenge, error) {
+	query := `
+		SELECT id, token_hash, user_id, attempts, expires_at, created_at
+		FROM mfa_challenges
+		WHERE token_hash = $1 AND expires_at > $2`
+
+	challenge := &models.MFAChallenge{}
+	err := r.db.QueryRow(query, tokenHash, time.Now()).Scan(
+		&challenge.ID,
+		&challenge.TokenHash,
+		&challenge.UserID,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("mfa challenge %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mfa challenge: %w", err)
+	}
+
+	return challenge, nil
+}
+
+// IncrementChallengeAttempts counts a failed verification and returns the
+// new number of attempts
+func (r *MFARepository) IncrementChallengeAttempts(id string) (int, error) {
+	var attempts int
+	err := r.db.QueryRow(`UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = $1 RETURNING attempts`, id).Scan(&attempts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update mfa challenge: %w", err)
+	}
+	return attempts, nil
+}
+
+// DeleteChallenge removes a challenge once it is redeemed or exhausted
+func (r *MFARepository) DeleteChallenge(id string) error {
+	result, err := r.db.Exec(`DELETE FROM mfa_challenges WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete mfa challenge: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("mfa challenge not found")
+	}
+
+	return nil
+}
+
+// CleanupExpiredChallenges removes expired login challenges
+func (r *MFARepository) CleanupExpiredChallenges() error {
+	_, err := r.db.Exec(`DELETE FROM mfa_challenges WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to cleanup expired mfa challenges: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"rest-api-golang/internal/testdb"
+	"rest-api-golang/totp"
+)
+
+func TestMarkStepUsedRejectsReplay(t *testing.T) {
+	db := testdb.Open(t)
+	var userID string
+	if err := db.QueryRow(`SELECT id FROM users WHERE username = 'user'`).Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewMFARepository(db)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SavePendingSecret(userID, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirming the enrollment uses up its step
+	step := totp.Step(time.Now())
+	if err := repo.Enable(userID, step, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkStepUsed(userID, step); err == nil {
+		t.Error("the enrollment code was accepted again")
+	}
+
+	if err := repo.MarkStepUsed(userID, step+1); err != nil {
+		t.Fatalf("next step: %v", err)
+	}
+	for _, replayed := range []int64{step + 1, step, step - 1} {
+		if err := repo.MarkStepUsed(userID, replayed); err == nil {
+			t.Errorf("step %d accepted after step %d", replayed, step+1)
+		}
+	}
+
+	// Only one of two concurrent logins with the same code gets through
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- repo.MarkStepUsed(userID, step+2) }()
+	}
+	if err1, err2 := <-errs, <-errs; (err1 == nil) == (err2 == nil) {
+		t.Errorf("concurrent use of one code: %v, %v", err1, err2)
+	}
+
+	mfa, err := repo.GetMFA(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mfa.Enabled || mfa.LastUsedStep != step+2 {
+		t.Errorf("enrollment %+v", mfa)
+	}
+}
@@ -0,0 +1,211 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rest-api-golang/models"
+)
+
+// outboxRelayLock is the advisory lock key held while relaying, so only one
+// API process publishes at a time and events leave in order
+const outboxRelayLock = 0x6f7574626f78
+
+// outboxAggregates are the audited entity types published through the
+// outbox
+var outboxAggregates = map[string]string{
+	models.AuditEntityBook: models.AggregateBook,
+	models.AuditEntityUser: models.AggregateUser,
+}
+
+// outboxVerbs name the event of each audited action
+var outboxVerbs = map[string]string{
+	models.AuditActionCreate:  "created",
+	models.AuditActionUpdate:  "updated",
+	models.AuditActionDelete:  "deleted",
+	models.AuditActionRestore: "restored",
+}
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// insertOutboxEvent writes the change event of an audited change to the
+// outbox within tx, so it is published exactly when the change commits.
+// The audit event ID doubles as the event's deduplication key.
+func insertOutboxEvent(tx *sql.Tx, e *models.AuditEvent, before, after json.RawMessage) error {
+	aggregate, ok := outboxAggregates[e.EntityType]
+	verb := outboxVerbs[e.Action]
+	if !ok || verb == "" {
+		return nil
+	}
+	payload := after
+	if payload == nil {
+		payload = before
+	}
+	_, err := tx.Exec(`
+		INSERT INTO outbox (event_id, aggregate_type, aggregate_id, event_type, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		e.ID, aggregate, e.EntityID, aggregate+"."+verb, nullJSON(payload), e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to write outbox event: %w", err)
+	}
+	return nil
+}
+
+// outboxColumns is the select list matching scanOutboxEvent
+const outboxColumns = `id, event_id, aggregate_type, aggregate_id, event_type, COALESCE(payload, 'null'), created_at`
+
+func scanOutboxEvent(row rowScanner) (*models.OutboxEvent, error) {
+	e := &models.OutboxEvent{}
+	var payload []byte
+	err := row.Scan(&e.Sequence, &e.ID, &e.AggregateType, &e.AggregateID, &e.Type, &payload, &e.CreatedAt)
+	e.Payload = payload
+	return e, err
+}
+
+// PublishPending passes up to limit unpublished events to publish, oldest
+// first, and marks those it accepts as published. Once publishing an event
+// fails, later events of the same aggregate wait for the next run, so each
+// aggregate's events go out in order. It does nothing while another
+// process is relaying. An event published just before a crash is
+// published again on the next run.
+func (r *OutboxRepository) PublishPending(limit int, publish func(*models.OutboxEvent) error) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, outboxRelayLock).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT `+outboxColumns+`
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		e, err := scanOutboxEvent(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query outbox: %w", err)
+	}
+
+	published := 0
+	blocked := map[string]bool{}
+	for _, e := range events {
+		aggregate := e.AggregateType + ":" + e.AggregateID
+		if blocked[aggregate] {
+			continue
+		}
+		if err := publish(e); err != nil {
+			blocked[aggregate] = true
+			message := err.Error()
+			if len(message) > 1000 {
+				message = message[:1000]
+			}
+			_, err := tx.Exec(`UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1`, e.Sequence, message)
+			if err != nil {
+				return published, fmt.Errorf("failed to record outbox failure: %w", err)
+			}
+			continue
+		}
+		_, err := tx.Exec(`
+			UPDATE outbox SET attempts = attempts + 1, last_error = NULL, published_at = CURRENT_TIMESTAMP
+			WHERE id = $1`, e.Sequence)
+		if err != nil {
+			return published, fmt.Errorf("failed to mark outbox event published: %w", err)
+		}
+		published++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit outbox: %w", err)
+	}
+	return published, nil
+}
+
+// GetOutboxEvent retrieves an event by its sequence number
+func (r *OutboxRepository) GetOutboxEvent(sequence int64) (*models.OutboxEvent, error) {
+	e, err := scanOutboxEvent(r.db.QueryRow(`SELECT `+outboxColumns+` FROM outbox WHERE id = $1`, sequence))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("event %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox event: %w", err)
+	}
+	return e, nil
+}
+
+// ListOutboxEvents retrieves up to limit events of an aggregate type that
+// may have committed after the event with sequence number after, in
+// sequence order. Sequence numbers are taken before commit, so besides the
+// higher ones this includes lower ones whose transaction was still running
+// when that event was written. Those may have been seen before it as well,
+// so readers skip sequence numbers they already have. Events purged from
+// the outbox are no longer found.
+func (r *OutboxRepository) ListOutboxEvents(aggregateType string, after int64, limit int) ([]*models.OutboxEvent, error) {
+	rows, err := r.db.Query(`
+		SELECT `+outboxColumns+`
+		FROM outbox
+		WHERE id IN (
+			(SELECT id FROM outbox
+			WHERE aggregate_type = $1 AND id > $2
+			ORDER BY id
+			LIMIT $3)
+			UNION ALL
+			SELECT o.id FROM outbox o
+			JOIN outbox seen ON seen.id = $2
+			WHERE o.aggregate_type = $1 AND o.id < $2
+				AND o.txid >= pg_snapshot_xmin(seen.snapshot) AND o.txid <> seen.txid
+				AND NOT pg_visible_in_snapshot(o.txid, seen.snapshot))
+		ORDER BY id
+		LIMIT $3`, aggregateType, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		e, err := scanOutboxEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// PurgePublished deletes events published before the given time and
+// returns how many were removed
+func (r *OutboxRepository) PurgePublished(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge outbox: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+
+	"rest-api-golang/internal/testdb"
+	"rest-api-golang/models"
+)
+
+// insertOutbox adds a book event within q and returns its sequence number
+func insertOutbox(t *testing.T, q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, aggregateID string) int64 {
+	t.Helper()
+	var id int64
+	err := q.QueryRow(`
+		INSERT INTO outbox (event_id, aggregate_type, aggregate_id, event_type, payload)
+		VALUES (gen_random_uuid(), 'book', $1, 'book.updated', '{}')
+		RETURNING id`, aggregateID).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func listedSequences(t *testing.T, outbox *OutboxRepository, after int64) []int64 {
+	t.Helper()
+	events, err := outbox.ListOutboxEvents(models.AggregateBook, after, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := []int64{}
+	for _, e := range events {
+		list = append(list, e.Sequence)
+	}
+	return list
+}
+
+func TestListOutboxEventsFindsLateCommits(t *testing.T) {
+	db := testdb.Open(t)
+	outbox := NewOutboxRepository(db)
+	before := insertOutbox(t, db, "before")
+
+	// slow takes a sequence number first but commits after fast
+	slow, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Rollback()
+	late := insertOutbox(t, slow, "late")
+	fast := insertOutbox(t, db, "fast")
+	if err := slow.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Events of the reader's own transaction went out with it
+	together, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer together.Rollback()
+	first := insertOutbox(t, together, "first")
+	second := insertOutbox(t, together, "second")
+	if err := together.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		after int64
+		want  []int64
+	}{
+		{before, []int64{late, fast, first, second}},
+		{fast, []int64{late, first, second}},
+		{late, []int64{fast, first, second}},
+		{second, []int64{}},
+		{first, []int64{second}},
+	}
+	for _, tt := range tests {
+		if got := listedSequences(t, outbox, tt.after); !slices.Equal(got, tt.want) {
+			t.Errorf("after %d: %v, want %v", tt.after, got, tt.want)
+		}
+	}
+}
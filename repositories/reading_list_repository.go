@@ -0,0 +1,426 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type ReadingListRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewReadingListRepository(db *sql.DB) *ReadingListRepository {
+	return &ReadingListRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository whose changes are recorded in
+// the audit log as made in the context a. Changes to the items of a list
+// are recorded as updates of the list.
+func (r *ReadingListRepository) WithAudit(a *models.AuditContext) *ReadingListRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// readingListColumns is the select list matching scanReadingList. Items of
+// soft-deleted books are not counted.
+const readingListColumns = `l.id, l.user_id, l.name, COALESCE(l.description, ''), l.visibility,
+		COALESCE(l.share_token, ''),
+		(SELECT COUNT(*) FROM reading_list_items i JOIN books b ON b.id = i.book_id
+			WHERE i.list_id = l.id AND b.deleted_at IS NULL),
+		l.created_at, l.updated_at`
+
+func scanReadingList(row rowScanner) (*models.ReadingList, error) {
+	l := &models.ReadingList{}
+	err := row.Scan(
+		&l.ID,
+		&l.UserID,
+		&l.Name,
+		&l.Description,
+		&l.Visibility,
+		&l.ShareToken,
+		&l.ItemCount,
+		&l.CreatedAt,
+		&l.UpdatedAt,
+	)
+	return l, err
+}
+
+// ListReadingLists retrieves the lists of a member ordered by name. With
+// publicOnly only public lists are returned.
+func (r *ReadingListRepository) ListReadingLists(userID string, publicOnly bool) ([]*models.ReadingList, error) {
+	query := `SELECT ` + readingListColumns + ` FROM reading_lists l WHERE l.user_id = $1`
+	if publicOnly {
+		query += ` AND l.visibility = 'public'`
+	}
+	query += ` ORDER BY LOWER(l.name)`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading lists: %w", err)
+	}
+	defer rows.Close()
+
+	var lists []*models.ReadingList
+	for rows.Next() {
+		l, err := scanReadingList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reading list: %w", err)
+		}
+		lists = append(lists, l)
+	}
+
+	return lists, rows.Err()
+}
+
+// GetReadingList retrieves a list by ID, without its items
+func (r *ReadingListRepository) GetReadingList(id string) (*models.ReadingList, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("reading list %w", ErrNotFound)
+	}
+	return r.getReadingList(`l.id = $1`, id)
+}
+
+// GetSharedReadingList retrieves a shared list by its share token
+func (r *ReadingListRepository) GetSharedReadingList(token string) (*models.ReadingList, error) {
+	return r.getReadingList(`l.share_token = $1 AND l.visibility = 'shared'`, token)
+}
+
+func (r *ReadingListRepository) getReadingList(condition string, arg interface{}) (*models.ReadingList, error) {
+	l, err := scanReadingList(r.db.QueryRow(`SELECT `+readingListColumns+` FROM reading_lists l WHERE `+condition, arg))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("reading list %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reading list: %w", err)
+	}
+	return l, nil
+}
+
+// itemScanner reads the item columns that precede bookColumns in a row
+type itemScanner struct {
+	row  rowScanner
+	item *models.ReadingListItem
+}
+
+func (s itemScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append([]interface{}{&s.item.Position, &s.item.Note, &s.item.AddedAt}, dest...)...)
+}
+
+// ListItems retrieves the books of a list in order. Soft-deleted books are
+// left out, and Position counts only the books returned.
+func (r *ReadingListRepository) ListItems(listID string) ([]*models.ReadingListItem, error) {
+	query := `
+		SELECT ROW_NUMBER() OVER (ORDER BY i.position, i.added_at), COALESCE(i.note, ''), i.added_at,
+			` + bookColumns + `
+		FROM reading_list_items i
+		JOIN books ON books.id = i.book_id
+		WHERE i.list_id = $1 AND books.deleted_at IS NULL
+		ORDER BY i.position, i.added_at`
+
+	rows, err := r.db.Query(query, listID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading list items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*models.ReadingListItem
+	var books []*models.Book
+	for rows.Next() {
+		item := &models.ReadingListItem{}
+		book, err := scanBook(itemScanner{row: rows, item: item})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reading list item: %w", err)
+		}
+		item.BookID = book.ID
+		item.Book = book
+		items = append(items, item)
+		books = append(books, book)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query reading list items: %w", err)
+	}
+
+	if err := NewBookRepository(r.db).attachRelations(books); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// CreateReadingList creates a list; a member's list names are unique
+// ignoring case
+func (r *ReadingListRepository) CreateReadingList(l *models.ReadingList) error {
+	return audited(r.db, r.audit, models.AuditActionCreate, models.AuditEntityReadingList, l.ID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO reading_lists (id, user_id, name, description, visibility, share_token, created_at, updated_at)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''), $7, $8)`,
+			l.ID, l.UserID, l.Name, l.Description, l.Visibility, l.ShareToken, l.CreatedAt, l.UpdatedAt)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("reading list %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create reading list: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateReadingList saves the name, description, visibility and share
+// token of a list
+func (r *ReadingListRepository) UpdateReadingList(l *models.ReadingList) error {
+	return audited(r.db, r.audit, models.AuditActionUpdate, models.AuditEntityReadingList, l.ID, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			UPDATE reading_lists
+			SET name = $2, description = NULLIF($3, ''), visibility = $4, share_token = NULLIF($5, '')
+			WHERE id = $1`, l.ID, l.Name, l.Description, l.Visibility, l.ShareToken)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("reading list %w", ErrDuplicate)
+		}
+		return checkListWrite(result, err, "update reading list")
+	})
+}
+
+// DeleteReadingList deletes a list and its items
+func (r *ReadingListRepository) DeleteReadingList(id string) error {
+	return audited(r.db, r.audit, models.AuditActionDelete, models.AuditEntityReadingList, id, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM reading_lists WHERE id = $1`, id)
+		return checkListWrite(result, err, "delete reading list")
+	})
+}
+
+func checkListWrite(result sql.Result, err error, action string) error {
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("reading list %w", ErrNotFound)
+	}
+	return nil
+}
+
+// lockList touches a list's updated_at, which also locks it for the rest
+// of the transaction so item positions are changed one writer at a time
+func lockList(tx *sql.Tx, listID string) error {
+	result, err := tx.Exec(`UPDATE reading_lists SET updated_at = NOW() WHERE id = $1`, listID)
+	return checkListWrite(result, err, "lock reading list")
+}
+
+// slotFor returns the stored position for a book placed at the 1-based
+// visible position, making room for it. 0 or a position past the end
+// appends. exceptBookID, if set, is a book being moved and is ignored.
+func slotFor(tx *sql.Tx, listID, exceptBookID string, position int) (int, error) {
+	var slot int
+	err := sql.ErrNoRows
+	if position > 0 {
+		err = tx.QueryRow(`
+			SELECT i.position FROM reading_list_items i
+			JOIN books b ON b.id = i.book_id
+			WHERE i.list_id = $1 AND i.book_id::text <> $2 AND b.deleted_at IS NULL
+			ORDER BY i.position, i.added_at
+			OFFSET $3 LIMIT 1`, listID, exceptBookID, position-1).Scan(&slot)
+	}
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`SELECT COALESCE(MAX(position), 0) + 1 FROM reading_list_items WHERE list_id = $1`, listID).Scan(&slot)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get list end: %w", err)
+		}
+		return slot, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get list position: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE reading_list_items SET position = position + 1
+		WHERE list_id = $1 AND book_id::text <> $2 AND position >= $3`, listID, exceptBookID, slot)
+	if err != nil {
+		return 0, fmt.Errorf("failed to shift list items: %w", err)
+	}
+	return slot, nil
+}
+
+// AddItem adds a book to a list at a 1-based position, or at the end if
+// position is 0. A book already in the list fails with ErrDuplicate.
+func (r *ReadingListRepository) AddItem(listID, bookID, note string, position int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockList(tx, listID); err != nil {
+		return err
+	}
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityReadingList, listID, func() error {
+		slot, err := slotFor(tx, listID, "", position)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO reading_list_items (list_id, book_id, position, note, added_at)
+			VALUES ($1, $2, $3, NULLIF($4, ''), NOW())`, listID, bookID, slot, note)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("book in list %w", ErrDuplicate)
+		}
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("book %w", ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add book to list: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit reading list: %w", err)
+	}
+	return nil
+}
+
+// UpdateItem changes the note of a book in a list if note is not nil, and
+// moves it to a 1-based position if position is not 0
+func (r *ReadingListRepository) UpdateItem(listID, bookID string, note *string, position int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockList(tx, listID); err != nil {
+		return err
+	}
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityReadingList, listID, func() error {
+		if note != nil {
+			result, err := tx.Exec(`
+				UPDATE reading_list_items SET note = NULLIF($3, '')
+				WHERE list_id = $1 AND book_id = $2`, listID, bookID, *note)
+			if err := checkItemWrite(result, err); err != nil {
+				return err
+			}
+		}
+
+		if position > 0 {
+			slot, err := slotFor(tx, listID, bookID, position)
+			if err != nil {
+				return err
+			}
+			result, err := tx.Exec(`UPDATE reading_list_items SET position = $3 WHERE list_id = $1 AND book_id = $2`, listID, bookID, slot)
+			if err := checkItemWrite(result, err); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit reading list: %w", err)
+	}
+	return nil
+}
+
+func checkItemWrite(result sql.Result, err error) error {
+	if err != nil {
+		return fmt.Errorf("failed to update reading list item: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("book in list %w", ErrNotFound)
+	}
+	return nil
+}
+
+// RemoveItem removes a book from a list
+func (r *ReadingListRepository) RemoveItem(listID, bookID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockList(tx, listID); err != nil {
+		return err
+	}
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityReadingList, listID, func() error {
+		result, err := tx.Exec(`DELETE FROM reading_list_items WHERE list_id = $1 AND book_id = $2`, listID, bookID)
+		if err := checkItemWrite(result, err); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit reading list: %w", err)
+	}
+	return nil
+}
+
+// ReorderItems puts the books of a list in the order of bookIDs. Books not
+// listed, such as soft-deleted ones, keep their relative order after them.
+func (r *ReadingListRepository) ReorderItems(listID string, bookIDs []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockList(tx, listID); err != nil {
+		return err
+	}
+	err = auditTx(tx, r.audit, models.AuditActionUpdate, models.AuditEntityReadingList, listID, func() error {
+		_, err := tx.Exec(`
+			UPDATE reading_list_items i SET position = o.ord
+			FROM unnest($2::uuid[]) WITH ORDINALITY AS o(book_id, ord)
+			WHERE i.list_id = $1 AND i.book_id = o.book_id`, listID, pq.Array(bookIDs))
+		if err != nil {
+			return fmt.Errorf("failed to reorder list: %w", err)
+		}
+		_, err = tx.Exec(`
+			UPDATE reading_list_items i SET position = $3 + r.rn
+			FROM (
+				SELECT book_id, ROW_NUMBER() OVER (ORDER BY position, added_at) AS rn
+				FROM reading_list_items
+				WHERE list_id = $1 AND NOT (book_id = ANY($2::uuid[]))
+			) r
+			WHERE i.list_id = $1 AND i.book_id = r.book_id`, listID, pq.Array(bookIDs), len(bookIDs))
+		if err != nil {
+			return fmt.Errorf("failed to reorder list: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit reading list: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+type ReviewRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewReviewRepository(db *sql.DB) *ReviewRepository {
+	return &ReviewRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository recording its changes in the
+// audit log as made in the context a
+func (r *ReviewRepository) WithAudit(a *models.AuditContext) *ReviewRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// reviewColumns is the select list matching scanReview, used with
+// reviewFrom
+const reviewColumns = `rv.id, rv.book_id, rv.user_id, u.username, rv.rating,
+		COALESCE(rv.title, ''), COALESCE(rv.body, ''), rv.status,
+		COALESCE(rv.moderated_by::text, ''), rv.moderated_at, rv.created_at, rv.updated_at`
+
+const reviewFrom = `
+		FROM reviews rv
+		JOIN users u ON u.id = rv.user_id`
+
+func scanReview(row rowScanner) (*models.Review, error) {
+	review := &models.Review{}
+	err := row.Scan(
+		&review.ID,
+		&review.BookID,
+		&review.UserID,
+		&review.Username,
+		&review.Rating,
+		&review.Title,
+		&review.Body,
+		&review.Status,
+		&review.ModeratedBy,
+		&review.ModeratedAt,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+	)
+	return review, err
+}
+
+// ListReviews retrieves reviews matching filter, newest first
+func (r *ReviewRepository) ListReviews(filter models.ReviewFilter) ([]*models.Review, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.BookID != "" {
+		add("rv.book_id = $%d", filter.BookID)
+	}
+	if filter.UserID != "" {
+		add("rv.user_id = $%d", filter.UserID)
+	}
+	if filter.Status != "" {
+		add("rv.status = $%d", filter.Status)
+	}
+	if filter.VisibleTo != "" {
+		add("(rv.status = 'approved' OR rv.user_id = $%d)", filter.VisibleTo)
+	}
+
+	query := `SELECT ` + reviewColumns + reviewFrom
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY rv.created_at DESC, rv.id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reviews: %w", err)
+	}
+	defer rows.Close()
+
+	var reviews []*models.Review
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review: %w", err)
+		}
+		reviews = append(reviews, review)
+	}
+
+	return reviews, rows.Err()
+}
+
+// GetReviewByID retrieves a review by ID
+func (r *ReviewRepository) GetReviewByID(id string) (*models.Review, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("review %w", ErrNotFound)
+	}
+
+	review, err := scanReview(r.db.QueryRow(`SELECT `+reviewColumns+reviewFrom+` WHERE rv.id = $1`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("review %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review: %w", err)
+	}
+
+	return review, nil
+}
+
+// CreateReview adds a review and updates the book's rating. A member who
+// already reviewed the book fails with ErrDuplicate.
+func (r *ReviewRepository) CreateReview(review *models.Review) error {
+	return r.inBookTx(review.BookID, models.AuditActionCreate, review.ID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO reviews (id, book_id, user_id, rating, title, body, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9)`,
+			review.ID, review.BookID, review.UserID, review.Rating, review.Title, review.Body,
+			review.Status, review.CreatedAt, review.UpdatedAt)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("review %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create review: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateReview saves the rating, text and status of a review and updates
+// the book's rating
+func (r *ReviewRepository) UpdateReview(review *models.Review) error {
+	return r.inBookTx(review.BookID, models.AuditActionUpdate, review.ID, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			UPDATE reviews SET rating = $2, title = NULLIF($3, ''), body = NULLIF($4, ''), status = $5
+			WHERE id = $1`, review.ID, review.Rating, review.Title, review.Body, review.Status)
+		return checkReviewWrite(result, err, "update")
+	})
+}
+
+// ModerateReview sets the status of a review on behalf of moderatorID and
+// updates the book's rating
+func (r *ReviewRepository) ModerateReview(review *models.Review, moderatorID string) error {
+	return r.inBookTx(review.BookID, models.AuditActionUpdate, review.ID, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			UPDATE reviews SET status = $2, moderated_by = $3, moderated_at = NOW()
+			WHERE id = $1`, review.ID, review.Status, moderatorID)
+		return checkReviewWrite(result, err, "moderate")
+	})
+}
+
+// DeleteReview deletes a review and updates the book's rating
+func (r *ReviewRepository) DeleteReview(review *models.Review) error {
+	return r.inBookTx(review.BookID, models.AuditActionDelete, review.ID, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM reviews WHERE id = $1`, review.ID)
+		return checkReviewWrite(result, err, "delete")
+	})
+}
+
+// inBookTx runs write in a transaction holding the book row lock, audited
+// as action on the review reviewID, and then recomputes the book's rating
+func (r *ReviewRepository) inBookTx(bookID, action, reviewID string, write func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockBook(tx, bookID); err != nil {
+		return err
+	}
+	err = auditTx(tx, r.audit, action, models.AuditEntityReview, reviewID, func() error { return write(tx) })
+	if err != nil {
+		return err
+	}
+	if err := refreshRating(tx, bookID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit review: %w", err)
+	}
+	return nil
+}
+
+func checkReviewWrite(result sql.Result, err error, action string) error {
+	if err != nil {
+		return fmt.Errorf("failed to %s review: %w", action, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("review %w", ErrNotFound)
+	}
+	return nil
+}
+
+// lockBook locks a non-deleted book row. Review writes take it first so
+// concurrent writes recompute the rating one after another and none is
+// lost.
+func lockBook(tx *sql.Tx, bookID string) error {
+	var id string
+	err := tx.QueryRow(`SELECT id FROM books WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, bookID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("book %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock book: %w", err)
+	}
+	return nil
+}
+
+// refreshRating recomputes a book's average_rating and rating_count from
+// its approved reviews
+func refreshRating(tx *sql.Tx, bookID string) error {
+	_, err := tx.Exec(`
+		UPDATE books SET average_rating = s.average, rating_count = s.count
+		FROM (
+			SELECT COALESCE(ROUND(AVG(rating), 2), 0) AS average, COUNT(*) AS count
+			FROM reviews WHERE book_id = $1 AND status = 'approved'
+		) s
+		WHERE id = $1`, bookID)
+	if err != nil {
+		return fmt.Errorf("failed to update book rating: %w", err)
+	}
+	return nil
+}
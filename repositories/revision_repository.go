@@ -0,0 +1,289 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+)
+
+// loadBook reads a book and its relations through q, deleted or not
+func loadBook(q querier, id string) (*models.Book, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+	book, err := scanBook(q.QueryRow(`SELECT `+bookColumns+` FROM books WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book: %w", err)
+	}
+	if err := attachBookRelations(q, []*models.Book{book}); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// insertBookRevision stores book as its next revision, dated at the book's
+// updated_at. The book row must be locked by tx.
+func insertBookRevision(tx *sql.Tx, book *models.Book, changedBy string, revertedFrom int) error {
+	snapshot, err := json.Marshal(models.NewBookSnapshot(book))
+	if err != nil {
+		return fmt.Errorf("failed to encode book revision: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO book_revisions (book_id, revision, snapshot, changed_by, reverted_from, created_at)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, NULLIF($3, '')::uuid, NULLIF($4, 0), $5
+		FROM book_revisions WHERE book_id = $1`,
+		book.ID, snapshot, changedBy, revertedFrom, book.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store book revision: %w", err)
+	}
+	return nil
+}
+
+// addBookRevision stores the current state of a book within tx as its next
+// revision, made in the context a. revertedFrom is the revision it
+// restores, or 0.
+func addBookRevision(tx *sql.Tx, a *models.AuditContext, bookID string, revertedFrom int) error {
+	book, err := loadBook(tx, bookID)
+	if err != nil {
+		return err
+	}
+	changedBy := ""
+	if a != nil {
+		changedBy = a.ActorID
+	}
+	return insertBookRevision(tx, book, changedBy, revertedFrom)
+}
+
+// ensureBookRevision stores the current state of a book as its first
+// revision if it has none, so a book created before revisions were kept
+// can still be viewed and reverted to as it was before its next change
+func ensureBookRevision(tx *sql.Tx, bookID string) error {
+	// Lock the book first so concurrent updates do not both add it
+	if _, err := tx.Exec(`SELECT 1 FROM books WHERE id = $1 FOR UPDATE`, bookID); err != nil {
+		return fmt.Errorf("failed to lock book: %w", err)
+	}
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM book_revisions WHERE book_id = $1)`, bookID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check book revisions: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	book, err := loadBook(tx, bookID)
+	if err != nil {
+		return err
+	}
+	return insertBookRevision(tx, book, "", 0)
+}
+
+// bookRevisionColumns is the select list matching scanBookRevision
+const bookRevisionColumns = `v.book_id, v.revision, v.snapshot, COALESCE(v.changed_by::text, ''),
+		COALESCE(u.username, ''), COALESCE(v.reverted_from, 0), v.created_at`
+
+const bookRevisionFrom = `
+		FROM book_revisions v
+		LEFT JOIN users u ON u.id = v.changed_by`
+
+// scanBookRevision reads a row selected with bookRevisionColumns
+func scanBookRevision(row rowScanner) (*models.BookRevision, error) {
+	v := &models.BookRevision{}
+	var snapshot []byte
+	err := row.Scan(&v.BookID, &v.Revision, &snapshot, &v.ChangedByID, &v.ChangedBy, &v.RevertedFrom, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	v.Book = &models.BookSnapshot{}
+	if err := json.Unmarshal(snapshot, v.Book); err != nil {
+		return nil, fmt.Errorf("failed to decode book revision: %w", err)
+	}
+	return v, nil
+}
+
+// changedFields names the fields of a diff
+func changedFields(changes []models.BookFieldChange) []string {
+	fields := make([]string, len(changes))
+	for i, c := range changes {
+		fields[i] = c.Field
+	}
+	return fields
+}
+
+// ListBookRevisions retrieves the revisions of a book, oldest first, with
+// the fields each one changed
+func (r *BookRepository) ListBookRevisions(bookID string) ([]*models.BookRevision, error) {
+	rows, err := r.db.Query(`
+		SELECT `+bookRevisionColumns+bookRevisionFrom+`
+		WHERE v.book_id = $1
+		ORDER BY v.revision`, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query book revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []*models.BookRevision{}
+	var previous *models.BookSnapshot
+	for rows.Next() {
+		v, err := scanBookRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan book revision: %w", err)
+		}
+		v.ChangedFields = changedFields(models.DiffBookSnapshots(previous, v.Book))
+		previous = v.Book
+		revisions = append(revisions, v)
+	}
+	return revisions, rows.Err()
+}
+
+// GetBookRevision retrieves revision n of a book with its snapshot and the
+// fields it changed
+func (r *BookRepository) GetBookRevision(bookID string, n int) (*models.BookRevision, error) {
+	rows, err := r.db.Query(`
+		SELECT `+bookRevisionColumns+bookRevisionFrom+`
+		WHERE v.book_id = $1 AND v.revision IN ($2, $2 - 1)
+		ORDER BY v.revision`, bookID, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book revision: %w", err)
+	}
+	defer rows.Close()
+
+	var previous, revision *models.BookRevision
+	for rows.Next() {
+		v, err := scanBookRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan book revision: %w", err)
+		}
+		if v.Revision == n {
+			revision = v
+		} else {
+			previous = v
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get book revision: %w", err)
+	}
+	if revision == nil {
+		return nil, fmt.Errorf("revision %w", ErrNotFound)
+	}
+
+	var before *models.BookSnapshot
+	if previous != nil {
+		before = previous.Book
+	}
+	revision.ChangedFields = changedFields(models.DiffBookSnapshots(before, revision.Book))
+	return revision, nil
+}
+
+// DiffBookRevisions compares revision from of a book with revision to
+func (r *BookRepository) DiffBookRevisions(bookID string, from, to int) (*models.BookRevisionDiff, error) {
+	a, err := r.GetBookRevision(bookID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.GetBookRevision(bookID, to)
+	if err != nil {
+		return nil, err
+	}
+	return &models.BookRevisionDiff{
+		BookID:  bookID,
+		From:    from,
+		To:      to,
+		Changes: models.DiffBookSnapshots(a.Book, b.Book),
+	}, nil
+}
+
+// LatestBookRevision returns the number of the newest revision of a book,
+// or 0 when it has none
+func (r *BookRepository) LatestBookRevision(bookID string) (int, error) {
+	var n int
+	err := r.db.QueryRow(`SELECT COALESCE(MAX(revision), 0) FROM book_revisions WHERE book_id = $1`, bookID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get book revision: %w", err)
+	}
+	return n, nil
+}
+
+// GetBookAsOf returns a book as it was at the time at: its current record
+// with the editable fields of the newest revision made by then, and that
+// revision. A book without revisions is returned as it is now. Books that
+// did not exist yet, or whose state at that time is unknown, fail with
+// ErrNotFound.
+func (r *BookRepository) GetBookAsOf(id string, at time.Time) (*models.Book, *models.BookRevision, error) {
+	book, err := r.GetBookByID(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+
+	v, err := scanBookRevision(r.db.QueryRow(`
+		SELECT `+bookRevisionColumns+bookRevisionFrom+`
+		WHERE v.book_id = $1 AND v.created_at <= $2
+		ORDER BY v.revision DESC
+		LIMIT 1`, id, at))
+	if err == sql.ErrNoRows {
+		latest, err := r.LatestBookRevision(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if latest > 0 || at.Before(book.CreatedAt) {
+			return nil, nil, fmt.Errorf("book state at that time %w", ErrNotFound)
+		}
+		return book, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get book revision: %w", err)
+	}
+
+	v.Book.Apply(book)
+	book.UpdatedAt = v.CreatedAt
+	return book, v, nil
+}
+
+// RevertBook sets the editable fields of a book back to those of revision
+// n. The result is stored as a new revision, leaving history intact. A
+// revision referring to an author or category deleted since fails with
+// ErrNotFound, and one whose ISBN is now used by another book with
+// ErrDuplicate.
+func (r *BookRepository) RevertBook(id string, n int) (*models.Book, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	book, err := loadBook(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if book.DeletedAt != nil {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+
+	v, err := scanBookRevision(tx.QueryRow(`
+		SELECT `+bookRevisionColumns+bookRevisionFrom+`
+		WHERE v.book_id = $1 AND v.revision = $2`, id, n))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("revision %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book revision: %w", err)
+	}
+
+	v.Book.Apply(book)
+	book.UpdatedAt = time.Now()
+	if err := r.updateBook(tx, book, n); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit book: %w", err)
+	}
+	return r.GetBookByID(id)
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"rest-api-golang/models"
+)
+
+type SettingsRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewSettingsRepository(db *sql.DB) *SettingsRepository {
+	return &SettingsRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository that records its changes in
+// the audit log as made in the context a
+func (r *SettingsRepository) WithAudit(a *models.AuditContext) *SettingsRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// GetSetting returns the value of a setting, or defaultValue if unset
+func (r *SettingsRepository) GetSetting(key, defaultValue string) (string, error) {
+	var value string
+	err := r.db.QueryRow(`SELECT value FROM app_settings WHERE key = $1`, key).Scan(&value)
+
+	if err == sql.ErrNoRows {
+		return defaultValue, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get setting: %w", err)
+	}
+
+	return value, nil
+}
+
+// SetSetting creates or replaces a setting
+func (r *SettingsRepository) SetSetting(key, value string) error {
+	return audited(r.db, r.audit, models.AuditActionUpdate, models.AuditEntitySetting, key, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO app_settings (key, value, updated_at)
+			VALUES ($1, $2, CURRENT_TIMESTAMP)
+			ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at`
+
+		if _, err := tx.Exec(query, key, value); err != nil {
+			return fmt.Errorf("failed to save setting: %w", err)
+		}
+
+		return nil
+	})
+}
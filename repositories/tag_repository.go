@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"rest-api-golang/models"
+
+	"github.com/lib/pq"
+)
+
+type TagRepository struct {
+	db *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+// SearchTags returns tags starting with prefix, most used first, for
+// autocomplete. Tags only used by deleted books are left out.
+func (r *TagRepository) SearchTags(prefix string, limit int) ([]*models.Tag, error) {
+	// Escape LIKE wildcards so user input is matched literally
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+
+	query := `
+		SELECT t.name, COUNT(b.id)
+		FROM tags t
+		JOIN book_tags bt ON bt.tag_id = t.id
+		JOIN books b ON b.id = bt.book_id AND b.deleted_at IS NULL
+		WHERE t.name LIKE $1 || '%'
+		GROUP BY t.name
+		ORDER BY COUNT(b.id) DESC, t.name
+		LIMIT $2`
+
+	rows, err := r.db.Query(query, escaped, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []*models.Tag
+	for rows.Next() {
+		tag := &models.Tag{}
+		if err := rows.Scan(&tag.Name, &tag.BookCount); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// setBookTags replaces the tags of a book, creating tags that do not exist
+// yet. tags must already be normalized.
+func setBookTags(tx *sql.Tx, bookID string, tags []string) error {
+	if _, err := tx.Exec(`DELETE FROM book_tags WHERE book_id = $1`, bookID); err != nil {
+		return fmt.Errorf("failed to clear book tags: %w", err)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO tags (name)
+		SELECT UNNEST($1::text[])
+		ON CONFLICT (name) DO NOTHING`, pq.Array(tags)); err != nil {
+		return fmt.Errorf("failed to create tags: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO book_tags (book_id, tag_id)
+		SELECT $1, id FROM tags WHERE name = ANY($2)`, bookID, pq.Array(tags)); err != nil {
+		return fmt.Errorf("failed to link book tags: %w", err)
+	}
+
+	return nil
+}
+
+// attachTags loads the tags of books with a single query
+func attachTags(db querier, books []*models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	ids := make([]string, len(books))
+	byID := make(map[string]*models.Book, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+		byID[book.ID] = book
+		book.Tags = nil
+	}
+
+	rows, err := db.Query(`
+		SELECT bt.book_id, t.name
+		FROM book_tags bt
+		JOIN tags t ON t.id = bt.tag_id
+		WHERE bt.book_id = ANY($1)
+		ORDER BY bt.book_id, t.name`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query book tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID, name string
+		if err := rows.Scan(&bookID, &name); err != nil {
+			return fmt.Errorf("failed to scan book tag: %w", err)
+		}
+		if book, ok := byID[bookID]; ok {
+			book.Tags = append(book.Tags, name)
+		}
+	}
+
+	return rows.Err()
+}
@@ -9,33 +9,44 @@ import (
 )
 
 type TokenRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	audit *models.AuditContext
 }
 
 func NewTokenRepository(db *sql.DB) *TokenRepository {
 	return &TokenRepository{db: db}
 }
 
-// CreateToken creates a new token
-func (r *TokenRepository) CreateToken(token *models.Token) error {
-	query := `
-		INSERT INTO tokens (id, token, user_id, expires_at, created_at, is_revoked)
-		VALUES ($1, $2, $3, $4, $5, $6)`
-
-	_, err := r.db.Exec(query,
-		token.ID,
-		token.Token,
-		token.UserID,
-		token.ExpiresAt,
-		token.CreatedAt,
-		token.IsRevoked,
-	)
-
-	if err != nil {
-		return fmt.Errorf("failed to create token: %w", err)
-	}
+// WithAudit returns a copy of the repository recording logins and logouts
+// in the audit log as made in the context a
+func (r *TokenRepository) WithAudit(a *models.AuditContext) *TokenRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
 
-	return nil
+// CreateToken creates a new token, audited as a login
+func (r *TokenRepository) CreateToken(token *models.Token) error {
+	return audited(r.db, r.audit, models.AuditActionLogin, models.AuditEntitySession, token.ID, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO tokens (id, token, user_id, expires_at, created_at, is_revoked)
+			VALUES ($1, $2, $3, $4, $5, $6)`
+
+		_, err := tx.Exec(query,
+			token.ID,
+			token.Token,
+			token.UserID,
+			token.ExpiresAt,
+			token.CreatedAt,
+			token.IsRevoked,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to create token: %w", err)
+		}
+
+		return nil
+	})
 }
 
 // GetTokenByValue retrieves a token by its value
@@ -65,35 +76,131 @@ func (r *TokenRepository) GetTokenByValue(tokenValue string) (*models.Token, err
 	return token, nil
 }
 
-// RevokeToken marks a token as revoked
+// RevokeToken marks a token as revoked, audited as a logout
 func (r *TokenRepository) RevokeToken(tokenValue string) error {
-	query := `UPDATE tokens SET is_revoked = true WHERE token = $1`
+	var id string
+	err := r.db.QueryRow(`SELECT id FROM tokens WHERE token = $1`, tokenValue).Scan(&id)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("token not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+
+	return audited(r.db, r.audit, models.AuditActionLogout, models.AuditEntitySession, id, func(tx *sql.Tx) error {
+		query := `UPDATE tokens SET is_revoked = true WHERE id = $1`
+
+		result, err := tx.Exec(query, id)
+		if err != nil {
+			return fmt.Errorf("failed to revoke token: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			return fmt.Errorf("token not found")
+		}
+
+		return nil
+	})
+}
 
-	result, err := r.db.Exec(query, tokenValue)
+// RevokeUserTokens revokes every valid session of a user, each audited as
+// a logout, and returns how many were revoked
+func (r *TokenRepository) RevokeUserTokens(userID string) (int, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to revoke token: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	rows, err := tx.Query(`SELECT id FROM tokens WHERE user_id = $1 AND is_revoked = false AND expires_at > $2`, userID, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return 0, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan token: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list tokens: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("token not found")
+	for _, id := range ids {
+		err := auditTx(tx, r.audit, models.AuditActionLogout, models.AuditEntitySession, id, func() error {
+			if _, err := tx.Exec(`UPDATE tokens SET is_revoked = true WHERE id = $1`, id); err != nil {
+				return fmt.Errorf("failed to revoke token: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit token revocation: %w", err)
+	}
+	return len(ids), nil
 }
 
-// CleanupExpiredTokens removes expired tokens
-func (r *TokenRepository) CleanupExpiredTokens() error {
+// CleanupExpiredTokens removes expired tokens and returns how many were
+// removed
+func (r *TokenRepository) CleanupExpiredTokens() (int64, error) {
 	query := `DELETE FROM tokens WHERE expires_at < $1`
 
-	_, err := r.db.Exec(query, time.Now())
+	result, err := r.db.Exec(query, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to cleanup expired tokens: %w", err)
+		return 0, fmt.Errorf("failed to cleanup expired tokens: %w", err)
 	}
 
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return removed, nil
+}
+
+// CreateLiveTicket stores a live feed ticket and removes expired ones
+func (r *TokenRepository) CreateLiveTicket(ticket *models.LiveTicket) error {
+	if _, err := r.db.Exec(`DELETE FROM live_tickets WHERE expires_at < $1`, ticket.CreatedAt); err != nil {
+		return fmt.Errorf("failed to cleanup live tickets: %w", err)
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO live_tickets (id, token_hash, session_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		ticket.ID, ticket.TokenHash, ticket.SessionID, ticket.ExpiresAt, ticket.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create live ticket: %w", err)
+	}
 	return nil
 }
+
+// RedeemLiveTicket deletes an unexpired ticket whose session is still
+// valid and returns the session's user ID. Deleting makes the ticket
+// single-use even under concurrent requests.
+func (r *TokenRepository) RedeemLiveTicket(tokenHash string) (string, error) {
+	var userID string
+	err := r.db.QueryRow(`
+		DELETE FROM live_tickets t
+		USING tokens s
+		WHERE t.token_hash = $1 AND t.expires_at > $2
+			AND s.id = t.session_id AND s.is_revoked = false AND s.expires_at > $2
+		RETURNING s.user_id`, tokenHash, time.Now()).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("live ticket %w", ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to redeem live ticket: %w", err)
+	}
+	return userID, nil
+}
@@ -9,17 +9,26 @@ import (
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	audit *models.AuditContext
 }
 
 func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// WithAudit returns a copy of the repository whose changes are recorded in
+// the audit log as made in the context a
+func (r *UserRepository) WithAudit(a *models.AuditContext) *UserRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
 // GetUserByUsername retrieves a user by username
 func (r *UserRepository) GetUserByUsername(username string) (*models.User, error) {
 	query := `
-		SELECT id, username, password, email, role, is_active, created_at, updated_at, last_login
+		SELECT id, username, password, email, role, is_active, created_at, updated_at, last_login, email_verified_at
 		FROM users 
 		WHERE username = $1 AND is_active = true`
 
@@ -34,6 +43,7 @@ func (r *UserRepository) GetUserByUsername(username string) (*models.User, error
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.LastLogin,
+		&user.EmailVerifiedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -57,3 +67,225 @@ func (r *UserRepository) UpdateLastLogin(userID string) error {
 
 	return nil
 }
+
+// GetUserByID retrieves an active user by ID
+func (r *UserRepository) GetUserByID(id string) (*models.User, error) {
+	query := `
+		SELECT id, username, password, email, role, is_active, created_at, updated_at, last_login, email_verified_at
+		FROM users 
+		WHERE id = $1 AND is_active = true`
+
+	user := &models.User{}
+	err := r.db.QueryRow(query, id).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Password,
+		&user.Email,
+		&user.Role,
+		&user.IsActive,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.LastLogin,
+		&user.EmailVerifiedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUserByEmail retrieves an active user by email (case-insensitive)
+func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
+	query := `
+		SELECT id, username, password, email, role, is_active, created_at, updated_at, last_login, email_verified_at
+		FROM users 
+		WHERE LOWER(email) = LOWER($1) AND is_active = true
+		ORDER BY created_at
+		LIMIT 1`
+
+	user := &models.User{}
+	err := r.db.QueryRow(query, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Password,
+		&user.Email,
+		&user.Role,
+		&user.IsActive,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.LastLogin,
+		&user.EmailVerifiedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// UsernameExists reports whether a username is taken, active or not
+func (r *UserRepository) UsernameExists(username string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)`, username).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check username: %w", err)
+	}
+	return exists, nil
+}
+
+// CreateUser creates a new user, storing a bcrypt hash of the plaintext
+// user.Password; user.Password is the hash afterwards
+func (r *UserRepository) CreateUser(user *models.User) error {
+	hash, err := models.HashPassword(user.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.Password = hash
+
+	return audited(r.db, r.audit, models.AuditActionCreate, models.AuditEntityUser, user.ID, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO users (id, username, password, email, role, is_active, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+		_, err := tx.Exec(query,
+			user.ID,
+			user.Username,
+			user.Password,
+			user.Email,
+			user.Role,
+			user.IsActive,
+			user.CreatedAt,
+			user.UpdatedAt,
+		)
+
+		if isUniqueViolation(err) {
+			return fmt.Errorf("username %w", ErrDuplicate)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// UpdateRole changes the role of a user
+func (r *UserRepository) UpdateRole(userID, role string) error {
+	return audited(r.db, r.audit, models.AuditActionUpdate, models.AuditEntityUser, userID, func(tx *sql.Tx) error {
+		query := `UPDATE users SET role = $2 WHERE id = $1`
+
+		result, err := tx.Exec(query, userID, role)
+		if err != nil {
+			return fmt.Errorf("failed to update role: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			return fmt.Errorf("user not found")
+		}
+
+		return nil
+	})
+}
+
+// ListUsers retrieves all users, including disabled ones, by username
+func (r *UserRepository) ListUsers() ([]*models.User, error) {
+	query := `
+		SELECT id, username, email, role, is_active, created_at, updated_at, last_login, email_verified_at
+		FROM users
+		ORDER BY username`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user := &models.User{}
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.Role,
+			&user.IsActive,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.LastLogin,
+			&user.EmailVerifiedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// DisableUser deactivates an active user, who can no longer sign in, and
+// revokes the user's sessions in the same transaction
+func (r *UserRepository) DisableUser(userID string) error {
+	return audited(r.db, r.audit, models.AuditActionUpdate, models.AuditEntityUser, userID, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`UPDATE users SET is_active = false, updated_at = $2 WHERE id = $1 AND is_active = true`, userID, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to disable user: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("user %w", ErrNotFound)
+		}
+
+		if _, err := tx.Exec(`UPDATE tokens SET is_revoked = true WHERE user_id = $1 AND is_revoked = false`, userID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		return nil
+	})
+}
+
+// SetPassword replaces the password of an active user with a bcrypt hash
+// of password and revokes the user's sessions in the same transaction
+func (r *UserRepository) SetPassword(userID, password string) error {
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	return audited(r.db, r.audit, models.AuditActionUpdate, models.AuditEntityUser, userID, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`UPDATE users SET password = $2, updated_at = $3 WHERE id = $1 AND is_active = true`, userID, hash, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("user %w", ErrNotFound)
+		}
+
+		if _, err := tx.Exec(`UPDATE tokens SET is_revoked = true WHERE user_id = $1 AND is_revoked = false`, userID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		return nil
+	})
+}
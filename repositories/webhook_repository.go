@@ -0,0 +1,445 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"rest-api-golang/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type WebhookRepository struct {
+	db    *sql.DB
+	audit *models.AuditContext
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// WithAudit returns a copy of the repository whose changes to
+// subscriptions are recorded in the audit log as made in the context a
+func (r *WebhookRepository) WithAudit(a *models.AuditContext) *WebhookRepository {
+	c := *r
+	c.audit = a
+	return &c
+}
+
+// webhookEventType names the webhook event for an audited change, or
+// returns "" when the change has none. before and after are the full
+// states read by auditTx.
+func webhookEventType(action, entityType string, before, after json.RawMessage) string {
+	switch entityType {
+	case models.AuditEntityBook:
+		switch action {
+		case models.AuditActionCreate:
+			return models.WebhookEventBookCreated
+		case models.AuditActionUpdate:
+			return models.WebhookEventBookUpdated
+		case models.AuditActionDelete:
+			return models.WebhookEventBookDeleted
+		case models.AuditActionRestore:
+			return models.WebhookEventBookRestored
+		}
+	case models.AuditEntityLoan:
+		switch action {
+		case models.AuditActionCreate:
+			return models.WebhookEventLoanCreated
+		case models.AuditActionUpdate:
+			var old, cur struct {
+				ReturnedAt   *time.Time `json:"returned_at"`
+				RenewalCount int        `json:"renewal_count"`
+			}
+			if json.Unmarshal(before, &old) != nil || json.Unmarshal(after, &cur) != nil {
+				return ""
+			}
+			if old.ReturnedAt == nil && cur.ReturnedAt != nil {
+				return models.WebhookEventLoanReturned
+			}
+			if cur.RenewalCount > old.RenewalCount {
+				return models.WebhookEventLoanRenewed
+			}
+		}
+	case models.AuditEntityHold:
+		// A hold placed while a copy is available is ready right away
+		var old, cur struct {
+			Status string `json:"status"`
+		}
+		if json.Unmarshal(after, &cur) != nil || (before != nil && json.Unmarshal(before, &old) != nil) {
+			return ""
+		}
+		if cur.Status == old.Status {
+			return ""
+		}
+		switch cur.Status {
+		case models.HoldStatusReady:
+			return models.WebhookEventHoldReady
+		case models.HoldStatusExpired:
+			return models.WebhookEventHoldExpired
+		}
+	}
+	return ""
+}
+
+// enqueueWebhooks queues the webhook event for an audited change within tx
+// for every active subscription whose filter matches it, so deliveries
+// exist exactly when the change commits. The payload carries the entity's
+// state after the change, or before it when it was removed.
+func enqueueWebhooks(tx *sql.Tx, e *models.AuditEvent, before, after json.RawMessage) error {
+	eventType := webhookEventType(e.Action, e.EntityType, before, after)
+	if eventType == "" {
+		return nil
+	}
+	data := after
+	if data == nil {
+		data = before
+	}
+	payload, err := json.Marshal(models.WebhookPayload{ID: e.ID, Type: eventType, CreatedAt: e.CreatedAt, Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	prefix, _, _ := strings.Cut(eventType, ".")
+	_, err = tx.Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+		SELECT s.id, $1, $2, $3, $4, $5, $5
+		FROM webhook_subscriptions s
+		WHERE s.active AND ($2 = ANY(s.events) OR $6 = ANY(s.events) OR '*' = ANY(s.events))`,
+		e.ID, eventType, payload, models.DeliveryStatusPending, e.CreatedAt, prefix+".*")
+	if err != nil {
+		return fmt.Errorf("failed to queue webhooks: %w", err)
+	}
+	return nil
+}
+
+// webhookColumns is the select list matching scanWebhook
+const webhookColumns = `s.id, s.url, s.events, COALESCE(s.description, ''), s.secret, s.active,
+		s.consecutive_failures, s.disabled_at, COALESCE(s.disabled_reason, ''),
+		COALESCE(s.created_by::text, ''), s.created_at, s.updated_at`
+
+func scanWebhook(row rowScanner) (*models.WebhookSubscription, error) {
+	s := &models.WebhookSubscription{}
+	err := row.Scan(
+		&s.ID,
+		&s.URL,
+		pq.Array(&s.Events),
+		&s.Description,
+		&s.Secret,
+		&s.Active,
+		&s.ConsecutiveFailures,
+		&s.DisabledAt,
+		&s.DisabledReason,
+		&s.CreatedBy,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	return s, err
+}
+
+// CreateWebhook stores a new subscription
+func (r *WebhookRepository) CreateWebhook(s *models.WebhookSubscription) error {
+	return audited(r.db, r.audit, models.AuditActionCreate, models.AuditEntityWebhook, s.ID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO webhook_subscriptions (id, url, events, description, secret, active, created_by, created_at, updated_at)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, NULLIF($7, '')::uuid, $8, $8)`,
+			s.ID, s.URL, pq.Array(s.Events), s.Description, s.Secret, s.Active, s.CreatedBy, s.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create webhook: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListWebhooks retrieves all subscriptions, oldest first
+func (r *WebhookRepository) ListWebhooks() ([]*models.WebhookSubscription, error) {
+	rows, err := r.db.Query(`SELECT ` + webhookColumns + ` FROM webhook_subscriptions s ORDER BY s.created_at, s.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*models.WebhookSubscription{}
+	for rows.Next() {
+		s, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, s)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhook retrieves a subscription by ID
+func (r *WebhookRepository) GetWebhook(id string) (*models.WebhookSubscription, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("webhook %w", ErrNotFound)
+	}
+	s, err := scanWebhook(r.db.QueryRow(`SELECT `+webhookColumns+` FROM webhook_subscriptions s WHERE s.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return s, nil
+}
+
+// UpdateWebhook saves the URL, events, description, secret and state of a
+// subscription. Enabling a subscription clears its failure count, and
+// deliveries that waited while it was disabled are sent again.
+func (r *WebhookRepository) UpdateWebhook(s *models.WebhookSubscription) error {
+	return audited(r.db, r.audit, models.AuditActionUpdate, models.AuditEntityWebhook, s.ID, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			UPDATE webhook_subscriptions SET
+				url = $2, events = $3, description = NULLIF($4, ''), secret = $5, active = $6,
+				consecutive_failures = CASE WHEN $6 AND NOT active THEN 0 ELSE consecutive_failures END,
+				disabled_at = CASE WHEN $6 THEN NULL ELSE COALESCE(disabled_at, CURRENT_TIMESTAMP) END,
+				disabled_reason = CASE WHEN $6 THEN NULL WHEN active THEN 'disabled by an administrator' ELSE disabled_reason END,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1`,
+			s.ID, s.URL, pq.Array(s.Events), s.Description, s.Secret, s.Active)
+		if err != nil {
+			return fmt.Errorf("failed to update webhook: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("webhook %w", ErrNotFound)
+		}
+		return nil
+	})
+}
+
+// DeleteWebhook removes a subscription with its delivery log
+func (r *WebhookRepository) DeleteWebhook(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("webhook %w", ErrNotFound)
+	}
+	return audited(r.db, r.audit, models.AuditActionDelete, models.AuditEntityWebhook, id, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete webhook: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("webhook %w", ErrNotFound)
+		}
+		return nil
+	})
+}
+
+// deliveryColumns is the select list matching scanDelivery
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+		d.next_attempt_at, d.last_attempt_at, COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''),
+		d.delivered_at, COALESCE(d.replay_of::text, ''), d.created_at`
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	d := &models.WebhookDelivery{}
+	var payload []byte
+	err := row.Scan(
+		&d.ID,
+		&d.SubscriptionID,
+		&d.EventID,
+		&d.EventType,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.DeliveredAt,
+		&d.ReplayOf,
+		&d.CreatedAt,
+	)
+	d.Payload = payload
+	return d, err
+}
+
+// ListDeliveries retrieves the delivery log of a subscription, newest
+// first. status, if not empty, filters on the delivery status; before
+// pages backwards from the delivery with that ID.
+func (r *WebhookRepository) ListDeliveries(subscriptionID, status, before string, limit int) ([]*models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d WHERE d.subscription_id = $1`
+	args := []interface{}{subscriptionID}
+	if status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(` AND d.status = $%d`, len(args))
+	}
+	if before != "" {
+		args = append(args, before)
+		query += fmt.Sprintf(` AND (d.created_at, d.id) < (SELECT created_at, id FROM webhook_deliveries WHERE id = $%d)`, len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY d.created_at DESC, d.id DESC LIMIT $%d`, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetDelivery retrieves a delivery of a subscription
+func (r *WebhookRepository) GetDelivery(subscriptionID, id string) (*models.WebhookDelivery, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("delivery %w", ErrNotFound)
+	}
+	d, err := scanDelivery(r.db.QueryRow(`
+		SELECT `+deliveryColumns+` FROM webhook_deliveries d
+		WHERE d.id = $1 AND d.subscription_id = $2`, id, subscriptionID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("delivery %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+// ReplayDelivery queues the event of a delivery again as a new delivery,
+// sent on the next run of the dispatcher. The original is left unchanged.
+func (r *WebhookRepository) ReplayDelivery(subscriptionID, id string) (*models.WebhookDelivery, error) {
+	original, err := r.GetDelivery(subscriptionID, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	replay := &models.WebhookDelivery{
+		ID:             uuid.New().String(),
+		SubscriptionID: subscriptionID,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		Status:         models.DeliveryStatusPending,
+		NextAttemptAt:  &now,
+		ReplayOf:       original.ID,
+		CreatedAt:      now,
+	}
+	_, err = r.db.Exec(`
+		INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload, status, next_attempt_at, replay_of, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $7)`,
+		replay.ID, replay.SubscriptionID, replay.EventID, replay.EventType, []byte(replay.Payload),
+		replay.Status, now, replay.ReplayOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+	return replay, nil
+}
+
+// ClaimDueDeliveries takes up to limit pending deliveries that are due, for
+// active subscriptions only, and postpones them by lease so that other API
+// processes skip them while they are sent. A delivery whose sender dies is
+// picked up again once its lease runs out.
+func (r *WebhookRepository) ClaimDueDeliveries(limit int, lease time.Duration) ([]*models.WebhookTarget, error) {
+	rows, err := r.db.Query(`
+		UPDATE webhook_deliveries d SET next_attempt_at = $3
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.status = $1 AND d.next_attempt_at <= CURRENT_TIMESTAMP AND s.active
+			ORDER BY d.next_attempt_at, d.created_at
+			LIMIT $2
+			FOR UPDATE OF d SKIP LOCKED)
+		RETURNING `+deliveryColumns+`, s.url, s.secret`,
+		models.DeliveryStatusPending, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []*models.WebhookTarget
+	for rows.Next() {
+		d := &models.WebhookDelivery{}
+		t := &models.WebhookTarget{Delivery: d}
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.LastAttemptAt, &d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.ReplayOf,
+			&d.CreatedAt, &t.URL, &t.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.Payload = payload
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// RecordDeliverySuccess marks a delivery as delivered and clears the
+// failure count of its subscription
+func (r *WebhookRepository) RecordDeliverySuccess(d *models.WebhookDelivery, statusCode int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE webhook_deliveries SET
+			status = $2, attempts = attempts + 1, last_attempt_at = CURRENT_TIMESTAMP,
+			last_status_code = $3, last_error = NULL, delivered_at = CURRENT_TIMESTAMP, next_attempt_at = NULL
+		WHERE id = $1`, d.ID, models.DeliveryStatusDelivered, statusCode)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE webhook_subscriptions SET consecutive_failures = 0 WHERE id = $1`, d.SubscriptionID); err != nil {
+		return fmt.Errorf("failed to reset webhook failures: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RecordDeliveryFailure records a failed attempt. The delivery is retried
+// at next, or fails for good when next is nil. Its subscription is disabled
+// once disableAfter attempts in a row have failed, which is reported.
+func (r *WebhookRepository) RecordDeliveryFailure(d *models.WebhookDelivery, statusCode int, message string, next *time.Time, disableAfter int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	status := models.DeliveryStatusPending
+	if next == nil {
+		status = models.DeliveryStatusFailed
+	}
+	if len(message) > 1000 {
+		message = message[:1000]
+	}
+	_, err = tx.Exec(`
+		UPDATE webhook_deliveries SET
+			status = $2, attempts = attempts + 1, last_attempt_at = CURRENT_TIMESTAMP,
+			last_status_code = NULLIF($3, 0), last_error = $4, next_attempt_at = $5
+		WHERE id = $1`, d.ID, status, statusCode, message, next)
+	if err != nil {
+		return false, fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+
+	var disabled bool
+	err = tx.QueryRow(`
+		UPDATE webhook_subscriptions SET
+			consecutive_failures = consecutive_failures + 1,
+			active = active AND consecutive_failures + 1 < $2,
+			disabled_at = CASE WHEN active AND consecutive_failures + 1 >= $2 THEN CURRENT_TIMESTAMP ELSE disabled_at END,
+			disabled_reason = CASE WHEN active AND consecutive_failures + 1 >= $2
+				THEN $3 ELSE disabled_reason END
+		WHERE id = $1
+		RETURNING NOT active AND consecutive_failures = $2`,
+		d.SubscriptionID, disableAfter, fmt.Sprintf("%d deliveries in a row failed", disableAfter)).Scan(&disabled)
+	if err != nil {
+		return false, fmt.Errorf("failed to count webhook failures: %w", err)
+	}
+	return disabled, tx.Commit()
+}
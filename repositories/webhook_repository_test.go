@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"encoding/json"
+	"testing"
+
+	"rest-api-golang/models"
+)
+
+func TestWebhookEventTypeForHolds(t *testing.T) {
+	hold := func(status string) json.RawMessage {
+		return json.RawMessage(`{"id": "h1", "status": "` + status + `"}`)
+	}
+	tests := []struct {
+		action        string
+		before, after json.RawMessage
+		want          string
+	}{
+		{models.AuditActionCreate, nil, hold("waiting"), ""},
+		{models.AuditActionCreate, nil, hold("ready"), models.WebhookEventHoldReady},
+		{models.AuditActionUpdate, hold("waiting"), hold("ready"), models.WebhookEventHoldReady},
+		{models.AuditActionUpdate, hold("ready"), hold("expired"), models.WebhookEventHoldExpired},
+		{models.AuditActionUpdate, hold("ready"), hold("ready"), ""},
+		{models.AuditActionUpdate, hold("ready"), hold("fulfilled"), ""},
+		{models.AuditActionUpdate, hold("waiting"), hold("cancelled"), ""},
+	}
+	for _, tt := range tests {
+		if got := webhookEventType(tt.action, models.AuditEntityHold, tt.before, tt.after); got != tt.want {
+			t.Errorf("%s %s -> %s = %q, want %q", tt.action, tt.before, tt.after, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package storage keeps binary objects such as cover images in a pluggable
+// BlobStore: a directory on disk for development, memory for tests, and an
+// S3-compatible bucket for production.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a key that has no blob
+var ErrNotFound = errors.New("blob not found")
+
+// Blob is a stored object and its media type
+type Blob struct {
+	Data        []byte
+	ContentType string
+}
+
+// BlobStore stores blobs under slash-separated keys such as
+// "covers/<hash>/small.jpg". Put overwrites an existing blob and Delete
+// of a missing key is not an error.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (*Blob, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Config selects and configures a BlobStore from environment variables
+type Config struct {
+	Driver            string // fs, memory or s3
+	Dir               string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+}
+
+// GetConfig returns blob store configuration from environment variables
+func GetConfig() *Config {
+	return &Config{
+		Driver:            getEnv("BLOB_STORE", "fs"),
+		Dir:               getEnv("BLOB_DIR", "uploads"),
+		S3Endpoint:        getEnv("S3_ENDPOINT", "http://localhost:9000"),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:          getEnv("S3_BUCKET", "bookapi"),
+		S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+	}
+}
+
+// New creates the BlobStore selected by config.Driver
+func New(config *Config) (BlobStore, error) {
+	switch config.Driver {
+	case "fs":
+		return NewFSStore(config.Dir)
+	case "memory":
+		return NewMemoryStore(), nil
+	case "s3":
+		return NewS3Store(config.S3Endpoint, config.S3Region, config.S3Bucket, config.S3AccessKeyID, config.S3SecretAccessKey)
+	}
+	return nil, fmt.Errorf("unknown blob store driver %q", config.Driver)
+}
+
+// checkKey rejects keys that could escape the store's namespace
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,81 @@
+package webhooks
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// reservedNetworks are not public although net.IP does not classify them:
+// "this network", carrier-grade NAT, IETF protocol assignments, benchmarking
+// and the reserved class E range
+var reservedNetworks = mustParseNetworks("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4")
+
+func mustParseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// publicIP reports whether ip may receive deliveries: any address in
+// allowed, otherwise only public unicast addresses. Loopback, private,
+// link-local (including cloud metadata endpoints), multicast and reserved
+// addresses are refused.
+func publicIP(ip net.IP, allowed []*net.IPNet) bool {
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialControl refuses connections to addresses publicIP rejects. It runs
+// after name resolution for every address dialed, so a host name that
+// resolves to an internal address, now or after the subscription was
+// checked, is refused too.
+func dialControl(allowed []*net.IPNet) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("webhook endpoint address %s is not an IP address", address)
+		}
+		if !publicIP(ip, allowed) {
+			return fmt.Errorf("webhook endpoint address %s is not public; allow it with WEBHOOK_ALLOWED_NETWORKS", ip)
+		}
+		return nil
+	}
+}
+
+// newTransport connects only to addresses publicIP accepts. Proxies from
+// the environment are not used, since the proxy would connect instead.
+func newTransport(allowed []*net.IPNet) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   dialControl(allowed),
+	}).DialContext
+	return transport
+}
@@ -0,0 +1,134 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"rest-api-golang/models"
+)
+
+// Queue is the durable delivery queue, implemented by the webhook
+// repository
+type Queue interface {
+	ClaimDueDeliveries(limit int, lease time.Duration) ([]*models.WebhookTarget, error)
+	RecordDeliverySuccess(d *models.WebhookDelivery, statusCode int) error
+	RecordDeliveryFailure(d *models.WebhookDelivery, statusCode int, message string, next *time.Time, disableAfter int) (bool, error)
+}
+
+// Dispatcher sends due deliveries from a Queue
+type Dispatcher struct {
+	queue  Queue
+	client *http.Client
+	config *Config
+}
+
+// NewDispatcher creates a dispatcher sending through client, or when
+// client is nil a client with config.Timeout that only connects to public
+// addresses and config.AllowedNetworks. Redirects are not followed: an
+// endpoint that moved must be updated.
+func NewDispatcher(queue Queue, client *http.Client, config *Config) *Dispatcher {
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout, Transport: newTransport(config.AllowedNetworks)}
+	}
+	c := *client
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return &Dispatcher{queue: queue, client: &c, config: config}
+}
+
+// DeliverDue sends one batch of due deliveries concurrently and records
+// their outcome. It returns how many were sent successfully.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	// A claim lasts well past the request timeout, so a batch is never
+	// claimed twice while it is being sent
+	targets, err := d.queue.ClaimDueDeliveries(d.config.BatchSize, 2*d.config.Timeout+time.Minute)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		delivered int
+		firstErr  error
+	)
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t *models.WebhookTarget) {
+			defer wg.Done()
+			ok, err := d.deliver(ctx, t)
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				delivered++
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}(t)
+	}
+	wg.Wait()
+	return delivered, firstErr
+}
+
+// deliver sends one delivery and records the outcome. The error is only
+// about recording it; a failed send is recorded and retried.
+func (d *Dispatcher) deliver(ctx context.Context, t *models.WebhookTarget) (bool, error) {
+	status, sendErr := d.Send(ctx, t)
+	if sendErr == nil {
+		return true, d.queue.RecordDeliverySuccess(t.Delivery, status)
+	}
+
+	attempts := t.Delivery.Attempts + 1
+	var next *time.Time
+	if attempts < d.config.MaxAttempts {
+		at := time.Now().Add(d.config.RetryDelay(attempts))
+		next = &at
+	}
+	disabled, err := d.queue.RecordDeliveryFailure(t.Delivery, status, sendErr.Error(), next, d.config.DisableAfter)
+	if disabled {
+		log.Printf("Disabled webhook %s after %d failed deliveries in a row", t.Delivery.SubscriptionID, d.config.DisableAfter)
+	}
+	return false, err
+}
+
+// Send posts a delivery to its endpoint and returns the response status.
+// Any status outside 2xx is an error.
+func (d *Dispatcher) Send(ctx context.Context, t *models.WebhookTarget) (int, error) {
+	body := []byte(t.Delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("invalid webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "BookAPI-Webhooks/1.0")
+	req.Header.Set(EventHeader, t.Delivery.EventType)
+	req.Header.Set(DeliveryHeader, t.Delivery.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(t.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Keep the start of the response to explain a failure
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(snippet) > 0 {
+			return resp.StatusCode, fmt.Errorf("endpoint responded %s: %s", resp.Status, snippet)
+		}
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
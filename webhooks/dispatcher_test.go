@@ -0,0 +1,362 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rest-api-golang/internal/testdb"
+	"rest-api-golang/models"
+	"rest-api-golang/repositories"
+
+	"github.com/google/uuid"
+)
+
+func testConfig() *Config {
+	return &Config{
+		MaxAttempts:  4,
+		Backoff:      30 * time.Second,
+		MaxBackoff:   90 * time.Second,
+		DisableAfter: 3,
+		Timeout:      5 * time.Second,
+		BatchSize:    10,
+		// The test receivers listen on loopback
+		AllowedNetworks: mustParseNetworks("127.0.0.0/8", "::1/128"),
+	}
+}
+
+func testTarget(url string) *models.WebhookTarget {
+	return &models.WebhookTarget{
+		URL:    url,
+		Secret: "whsec_test",
+		Delivery: &models.WebhookDelivery{
+			ID:             "d1",
+			SubscriptionID: "s1",
+			EventType:      models.WebhookEventBookCreated,
+			Payload:        json.RawMessage(`{"type":"book.created","data":{"judul":"Laskar Pelangi"}}`),
+		},
+	}
+}
+
+// failure is a RecordDeliveryFailure call
+type failure struct {
+	status       int
+	next         *time.Time
+	disableAfter int
+}
+
+// memQueue hands out its targets once and records outcomes, reporting the
+// subscription disabled after disableAfter failures in a row like the
+// repository
+type memQueue struct {
+	mu        sync.Mutex
+	targets   []*models.WebhookTarget
+	successes []int
+	failures  []failure
+	inARow    int
+}
+
+func (q *memQueue) ClaimDueDeliveries(limit int, lease time.Duration) ([]*models.WebhookTarget, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	claimed := q.targets
+	q.targets = nil
+	return claimed, nil
+}
+
+func (q *memQueue) RecordDeliverySuccess(d *models.WebhookDelivery, statusCode int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.successes = append(q.successes, statusCode)
+	q.inARow = 0
+	return nil
+}
+
+func (q *memQueue) RecordDeliveryFailure(d *models.WebhookDelivery, statusCode int, message string, next *time.Time, disableAfter int) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.failures = append(q.failures, failure{statusCode, next, disableAfter})
+	q.inARow++
+	return q.inARow == disableAfter, nil
+}
+
+func TestSendSignsDelivery(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	target := testTarget(receiver.URL)
+	status, err := NewDispatcher(&memQueue{}, nil, testConfig()).Send(context.Background(), target)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v", status, err)
+	}
+
+	if string(body) != string(target.Delivery.Payload) {
+		t.Errorf("body = %s", body)
+	}
+	if got.Header.Get(EventHeader) != "book.created" || got.Header.Get(DeliveryHeader) != "d1" || got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", got.Header)
+	}
+
+	// The receiver checks the signature and the timestamp it covers
+	signature, timestamp := got.Header.Get(SignatureHeader), got.Header.Get(TimestampHeader)
+	if !strings.HasPrefix(signature, "sha256=") {
+		t.Fatalf("signature = %q", signature)
+	}
+	if !Verify("whsec_test", signature, timestamp, body, time.Now(), time.Minute) {
+		t.Error("signature does not verify with the subscription secret")
+	}
+	if Verify("other-secret", signature, timestamp, body, time.Now(), time.Minute) {
+		t.Error("signature verifies with another secret")
+	}
+	if Verify("whsec_test", signature, timestamp, append(body, ' '), time.Now(), time.Minute) {
+		t.Error("signature verifies a changed body")
+	}
+	// A captured delivery cannot be replayed later, nor with a new timestamp
+	if Verify("whsec_test", signature, timestamp, body, time.Now().Add(10*time.Minute), 5*time.Minute) {
+		t.Error("old delivery verifies")
+	}
+	ts, _ := strconv.ParseInt(timestamp, 10, 64)
+	if Verify("whsec_test", signature, strconv.FormatInt(ts+1, 10), body, time.Now(), time.Minute) {
+		t.Error("signature verifies with another timestamp")
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	var followed atomic.Bool
+	moved := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	}))
+	defer moved.Close()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, moved.URL, http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	// Even a client that follows redirects is not allowed to
+	client := &http.Client{Timeout: time.Second}
+	status, err := NewDispatcher(&memQueue{}, client, testConfig()).Send(context.Background(), testTarget(receiver.URL))
+	if err == nil || status != http.StatusTemporaryRedirect {
+		t.Errorf("Send = %d, %v", status, err)
+	}
+	if followed.Load() {
+		t.Error("the redirect was followed")
+	}
+	if client.CheckRedirect != nil {
+		t.Error("the caller's client was changed")
+	}
+}
+
+func TestSendRefusesInternalAddresses(t *testing.T) {
+	var received atomic.Bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Store(true)
+	}))
+	defer receiver.Close()
+
+	config := testConfig()
+	config.AllowedNetworks = nil
+	d := NewDispatcher(&memQueue{}, nil, config)
+	// By IP address and by a host name that resolves to loopback
+	for _, url := range []string{receiver.URL, strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)} {
+		if _, err := d.Send(context.Background(), testTarget(url)); err == nil || !strings.Contains(err.Error(), "is not public") {
+			t.Errorf("Send to %s: %v", url, err)
+		}
+	}
+	if received.Load() {
+		t.Error("a delivery reached a loopback address")
+	}
+	// A proxy from the environment would connect in the dispatcher's place
+	if newTransport(nil).Proxy != nil {
+		t.Error("the transport uses a proxy")
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	internal := mustParseNetworks("10.1.0.0/16")
+	tests := []struct {
+		ip      string
+		allowed []*net.IPNet
+		want    bool
+	}{
+		{"93.184.216.34", nil, true},
+		{"2606:2800:220:1:248:1893:25c8:1946", nil, true},
+		{"127.0.0.1", nil, false},
+		{"::1", nil, false},
+		{"::ffff:127.0.0.1", nil, false},
+		{"10.1.2.3", nil, false},
+		{"172.16.0.1", nil, false},
+		{"192.168.1.1", nil, false},
+		{"fd00::1", nil, false},
+		{"169.254.169.254", nil, false},
+		{"fe80::1", nil, false},
+		{"0.0.0.0", nil, false},
+		{"::", nil, false},
+		{"100.64.0.1", nil, false},
+		{"224.0.0.1", nil, false},
+		{"255.255.255.255", nil, false},
+		{"10.1.2.3", internal, true},
+		{"10.2.0.1", internal, false},
+		{"127.0.0.1", internal, false},
+	}
+	for _, tt := range tests {
+		if got := publicIP(net.ParseIP(tt.ip), tt.allowed); got != tt.want {
+			t.Errorf("publicIP(%s, %v) = %v, want %v", tt.ip, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestGetConfigAllowedNetworks(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOWED_NETWORKS", "10.1.0.0/16, fd00::/8,not-a-network,")
+	var got []string
+	for _, network := range GetConfig().AllowedNetworks {
+		got = append(got, network.String())
+	}
+	if strings.Join(got, " ") != "10.1.0.0/16 fd00::/8" {
+		t.Errorf("AllowedNetworks = %v", got)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	config := testConfig()
+	want := []time.Duration{30 * time.Second, time.Minute, 90 * time.Second, 90 * time.Second, 90 * time.Second}
+	for i, w := range want {
+		if got := config.RetryDelay(i + 1); got != w {
+			t.Errorf("RetryDelay(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+	// Doubling never overflows into a short delay
+	if got := GetConfig().RetryDelay(200); got != 6*time.Hour {
+		t.Errorf("RetryDelay(200) = %s", got)
+	}
+}
+
+func TestDeliverDueRetriesWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	config := testConfig()
+	queue := &memQueue{}
+	d := NewDispatcher(queue, nil, config)
+
+	// Each round the delivery has one more failed attempt behind it
+	for attempts := 0; attempts < config.MaxAttempts; attempts++ {
+		target := testTarget(receiver.URL)
+		target.Delivery.Attempts = attempts
+		queue.targets = []*models.WebhookTarget{target}
+
+		before := time.Now()
+		delivered, err := d.DeliverDue(context.Background())
+		if err != nil || delivered != 0 {
+			t.Fatalf("DeliverDue = %d, %v", delivered, err)
+		}
+
+		f := queue.failures[len(queue.failures)-1]
+		if f.status != http.StatusServiceUnavailable || f.disableAfter != config.DisableAfter {
+			t.Errorf("attempt %d recorded as %+v", attempts+1, f)
+		}
+		if attempts+1 == config.MaxAttempts {
+			if f.next != nil {
+				t.Errorf("last attempt is retried at %s", f.next)
+			}
+			continue
+		}
+		if f.next == nil {
+			t.Fatalf("attempt %d is not retried", attempts+1)
+		}
+		delay := config.RetryDelay(attempts + 1)
+		if f.next.Before(before.Add(delay)) || f.next.After(time.Now().Add(delay)) {
+			t.Errorf("attempt %d retried in %s, want %s", attempts+1, f.next.Sub(before), delay)
+		}
+	}
+	if int(calls.Load()) != config.MaxAttempts {
+		t.Errorf("endpoint was called %d times", calls.Load())
+	}
+}
+
+func TestDeliverDueRecordsSuccess(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	queue := &memQueue{targets: []*models.WebhookTarget{testTarget(receiver.URL), testTarget(receiver.URL)}}
+	delivered, err := NewDispatcher(queue, nil, testConfig()).DeliverDue(context.Background())
+	if err != nil || delivered != 2 {
+		t.Fatalf("DeliverDue = %d, %v", delivered, err)
+	}
+	if len(queue.successes) != 2 || queue.successes[0] != http.StatusAccepted || len(queue.failures) != 0 {
+		t.Errorf("recorded %v successes and %d failures", queue.successes, len(queue.failures))
+	}
+}
+
+// TestDispatcherDisablesFailingSubscription runs the dispatcher against the
+// webhook repository
+func TestDispatcherDisablesFailingSubscription(t *testing.T) {
+	db := testdb.Open(t)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	repo := repositories.NewWebhookRepository(db)
+	sub := &models.WebhookSubscription{
+		ID: uuid.New().String(), URL: receiver.URL, Events: []string{"book.*"},
+		Secret: "whsec_test", Active: true, CreatedAt: time.Now(),
+	}
+	if err := repo.CreateWebhook(sub); err != nil {
+		t.Fatal(err)
+	}
+	config := testConfig()
+	for i := 0; i < config.DisableAfter+1; i++ {
+		_, err := db.Exec(`
+			INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, next_attempt_at)
+			VALUES ($1, gen_random_uuid(), 'book.created', '{}', 'pending', NOW())`, sub.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One delivery at a time, so the subscription is disabled before the
+	// last one is claimed
+	config.BatchSize = 1
+	d := NewDispatcher(repo, nil, config)
+	for i := 0; i < config.DisableAfter+1; i++ {
+		if _, err := d.DeliverDue(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sub, err := repo.GetWebhook(sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Active || sub.DisabledAt == nil || sub.ConsecutiveFailures != config.DisableAfter {
+		t.Errorf("subscription active=%v disabled_at=%v failures=%d", sub.Active, sub.DisabledAt, sub.ConsecutiveFailures)
+	}
+	var attempted int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE attempts > 0`).Scan(&attempted); err != nil {
+		t.Fatal(err)
+	}
+	if attempted != config.DisableAfter {
+		t.Errorf("%d deliveries were attempted, want %d", attempted, config.DisableAfter)
+	}
+}
@@ -0,0 +1,138 @@
+// Package webhooks delivers queued events to subscribed endpoints as
+// signed HTTP POST requests, retrying failed deliveries with exponential
+// backoff and disabling endpoints that keep failing.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery. The signature is "sha256=" followed by
+// the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the subscription
+// secret; the timestamp is in Unix seconds.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Sign computes the signature header value of body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery the way a receiver should: the signature must
+// match and the timestamp must be within tolerance of now, so a captured
+// request cannot be replayed later
+func Verify(secret, signature, timestamp string, body []byte, now time.Time, tolerance time.Duration) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body)))
+}
+
+// Config controls delivery and retries, read from environment variables
+type Config struct {
+	// MaxAttempts is how often a delivery is tried before it fails
+	MaxAttempts int
+	// Backoff is the wait after the first failed attempt; it doubles with
+	// every further attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// DisableAfter is how many failed attempts in a row disable a
+	// subscription
+	DisableAfter int
+	// Timeout bounds a single request to an endpoint
+	Timeout time.Duration
+	// BatchSize is how many deliveries are sent at once
+	BatchSize int
+	// AllowedNetworks may receive deliveries although they are not
+	// public, such as a receiver on the internal network
+	AllowedNetworks []*net.IPNet
+}
+
+// GetConfig returns webhook configuration from environment variables
+func GetConfig() *Config {
+	return &Config{
+		MaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		Backoff:      getEnvDuration("WEBHOOK_BACKOFF", 30*time.Second),
+		MaxBackoff:   getEnvDuration("WEBHOOK_MAX_BACKOFF", 6*time.Hour),
+		DisableAfter: getEnvInt("WEBHOOK_DISABLE_AFTER", 20),
+		Timeout:      getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		BatchSize:    getEnvInt("WEBHOOK_BATCH_SIZE", 20),
+		// Comma-separated CIDRs, e.g. "10.1.0.0/16,fd00::/8"
+		AllowedNetworks: getEnvNetworks("WEBHOOK_ALLOWED_NETWORKS"),
+	}
+}
+
+// RetryDelay is the wait before the next try of a delivery that has now
+// failed attempts times
+func (c *Config) RetryDelay(attempts int) time.Duration {
+	delay := c.Backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= c.MaxBackoff {
+			return c.MaxBackoff
+		}
+	}
+	return min(delay, c.MaxBackoff)
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Printf("Invalid %s %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvNetworks(key string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range strings.Split(os.Getenv(key), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Printf("Invalid network %q in %s, ignoring it", cidr, key)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}